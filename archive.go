@@ -0,0 +1,106 @@
+package gogrep
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"io"
+	"iter"
+)
+
+// zipMagic is the magic bytes of the local file header of zip.
+var zipMagic = []byte("PK\x03\x04")
+
+// ArchiveSeparator separates the name of an archive and the path of an entry in the names of ArchiveSources.
+const ArchiveSeparator = "!"
+
+// ArchiveSources returns the regular files in the tar or zip archive as the sources of GrepSources, as an iterator.
+// The sources are named NAME!PATH by the name of the archive and the paths of the entries.
+// The compressed tar like tar.gz is decompressed as NewDecodingReader does.
+// The zip archive is read at the offsets if the reader is a SizedReaderAt, or read into memory otherwise.
+// The reader of a source is valid until the next source is yielded.
+// An error is yielded with an empty source, and stops the iteration.
+func ArchiveSources(name string, r io.Reader) iter.Seq2[NamedSource, error] {
+	return func(yield func(NamedSource, error) bool) {
+		var err error
+		if x, ok := r.(SizedReaderAt); ok {
+			head := make([]byte, len(zipMagic))
+			n, _ := x.ReadAt(head, 0)
+			if bytes.Equal(head[:n], zipMagic) {
+				err = zipSources(name, x, yield)
+			} else {
+				err = tarSources(name, io.NewSectionReader(x, 0, x.Size()), yield)
+			}
+		} else {
+			br := bufio.NewReader(r)
+			head, _ := br.Peek(len(zipMagic))
+			if bytes.Equal(head, zipMagic) {
+				var data []byte
+				if data, err = io.ReadAll(br); err == nil {
+					err = zipSources(name, bytes.NewReader(data), yield)
+				}
+			} else {
+				err = tarSources(name, br, yield)
+			}
+		}
+		if err != nil {
+			yield(NamedSource{}, err)
+		}
+	}
+}
+
+// tarSources yields the regular files in the tar stream.
+// Returns nil if the yield stops the iteration.
+func tarSources(name string, r io.Reader, yield func(NamedSource, error) bool) error {
+	d := NewDecodingReader(r)
+	defer d.Close()
+	tr := tar.NewReader(d)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return wrapErr(err, "ArchiveSources cannot read tar %s", name)
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if !yield(NamedSource{
+			Name:   name + ArchiveSeparator + h.Name,
+			Reader: tr,
+			Info:   h.FileInfo(),
+		}, nil) {
+			return nil
+		}
+	}
+}
+
+// zipSources yields the regular files in the zip archive.
+// Returns nil if the yield stops the iteration.
+func zipSources(name string, r SizedReaderAt, yield func(NamedSource, error) bool) error {
+	zr, err := zip.NewReader(r, r.Size())
+	if err != nil {
+		return wrapErr(err, "ArchiveSources cannot read zip %s", name)
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return wrapErr(err, "ArchiveSources cannot open %s in zip %s", f.Name, name)
+		}
+		ok := yield(NamedSource{
+			Name:   name + ArchiveSeparator + f.Name,
+			Reader: rc,
+			Info:   f.FileInfo(),
+		}, nil)
+		rc.Close()
+		if !ok {
+			return nil
+		}
+	}
+	return nil
+}
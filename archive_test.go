@@ -0,0 +1,107 @@
+package gogrep_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveSources(t *testing.T) {
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"a.txt", "apple\nbanana\n"},
+		{"dir/b.txt", "cherry\n"},
+	}
+	newTar := func(t *testing.T, compress bool) []byte {
+		var buf bytes.Buffer
+		var w io.Writer = &buf
+		var gw *gzip.Writer
+		if compress {
+			gw = gzip.NewWriter(&buf)
+			w = gw
+		}
+		tw := tar.NewWriter(w)
+		assert.Nil(t, tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755}))
+		for _, f := range files {
+			assert.Nil(t, tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.content))}))
+			_, err := tw.Write([]byte(f.content))
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, tw.Close())
+		if gw != nil {
+			assert.Nil(t, gw.Close())
+		}
+		return buf.Bytes()
+	}
+	newZip := func(t *testing.T) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		_, err := zw.Create("dir/")
+		assert.Nil(t, err)
+		for _, f := range files {
+			w, err := zw.Create(f.name)
+			assert.Nil(t, err)
+			_, err = w.Write([]byte(f.content))
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, zw.Close())
+		return buf.Bytes()
+	}
+	want := map[string]string{
+		"x!a.txt":     "apple\nbanana\n",
+		"x!dir/b.txt": "cherry\n",
+	}
+	readAll := func(t *testing.T, r io.Reader) map[string]string {
+		got := map[string]string{}
+		for src, err := range gogrep.ArchiveSources("x", r) {
+			if !assert.Nil(t, err) {
+				return nil
+			}
+			b, err := io.ReadAll(src.Reader)
+			assert.Nil(t, err)
+			got[src.Name] = string(b)
+		}
+		return got
+	}
+
+	for _, tc := range []struct {
+		title string
+		data  func(*testing.T) []byte
+	}{
+		{"tar", func(t *testing.T) []byte { return newTar(t, false) }},
+		{"tar.gz", func(t *testing.T) []byte { return newTar(t, true) }},
+		{"zip", newZip},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			data := tc.data(t)
+			assert.Equal(t, want, readAll(t, bytes.NewReader(data)))
+			assert.Equal(t, want, readAll(t, bytes.NewBuffer(data)), "not a SizedReaderAt")
+		})
+	}
+
+	t.Run("break", func(t *testing.T) {
+		var n int
+		for range gogrep.ArchiveSources("x", bytes.NewReader(newZip(t))) {
+			n++
+			break
+		}
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("broken", func(t *testing.T) {
+		var errs []error
+		for src, err := range gogrep.ArchiveSources("x", bytes.NewReader(newZip(t)[:30])) {
+			assert.Equal(t, "", src.Name)
+			errs = append(errs, err)
+		}
+		assert.Equal(t, 1, len(errs))
+	})
+}
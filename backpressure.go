@@ -0,0 +1,41 @@
+package gogrep
+
+// WithMaxPendingLines bounds the lines read from a source and not matched yet by the workers
+// to maxPendingLines, instead of the chunks of the lines buffered by WithRequestBufferSize.
+// A slow consumer blocks the workers on the results, and then the reading of the source.
+// The lines are dispatched to the workers at maxPendingLines at most.
+// Not positive number means the bound by the buffers.
+//
+// The memory of a grep of a source, or of a range of GrepReaderAt, is bounded by the lines
+// up to WithMaxLineLength bytes each:
+//
+//	(RequestBufferSize + Threads + 1) * FlushPolicy.MaxLines  the lines dispatched, or
+//	2 * maxPendingLines                                       with WithMaxPendingLines,
+//	+ ResultBufferSize                                        the results keeping the lines alive, 0 with WithBlockingResults
+//
+// where RequestBufferSize is twice the threads by default.
+// GrepSources greps up to Threads sources ahead of the consumer and the small sources batched together,
+// and GrepReaderAt greps all the ranges at once, each of them bounded as above.
+// WithSharedLineBuffers keeps the buffer of 64KiB around each result alive.
+func WithMaxPendingLines(maxPendingLines int) Option {
+	return func(c *Config) {
+		c.maxPendingLines = maxPendingLines
+	}
+}
+
+// WithBlockingResults makes the channels of the results unbuffered instead of WithResultBufferSize,
+// so the grep pauses at each result until the consumer receives it.
+// See WithMaxPendingLines for the memory of a grep.
+func WithBlockingResults() Option {
+	return func(c *Config) {
+		c.blockingResults = true
+	}
+}
+
+// resultBuffer returns the buffer size of the result channels.
+func (c *Config) resultBuffer() int {
+	if c.blockingResults {
+		return 0
+	}
+	return c.resultBufferSize
+}
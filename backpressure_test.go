@@ -0,0 +1,74 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// endlessLines is the endless source of the lines of 1KiB counting the lines read.
+type endlessLines struct {
+	n int64
+}
+
+var endlessLine = strings.Repeat("x", 1023) + "\n"
+
+func (s *endlessLines) Read(p []byte) (int, error) {
+	if len(p) < len(endlessLine) {
+		return copy(p, endlessLine[:len(p)]), nil // not counted, the scanner reads more
+	}
+	atomic.AddInt64(&s.n, 1)
+	return copy(p, endlessLine), nil
+}
+
+func TestBackpressure(t *testing.T) {
+	// linesRead returns the lines read while the consumer stops after the first result.
+	linesRead := func(t *testing.T, opt ...gogrep.Option) int64 {
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+		source := &endlessLines{}
+		resultC, err := gogrep.New(append([]gogrep.Option{gogrep.WithThreads(1)}, opt...)...).
+			Grep(ctx, "x", source)
+		if !assert.Nil(t, err) {
+			return 0
+		}
+		defer func() {
+			cancel()
+			for range resultC {
+			}
+		}()
+		<-resultC
+		time.Sleep(100 * time.Millisecond)
+		return atomic.LoadInt64(&source.n)
+	}
+
+	t.Run("buffered", func(t *testing.T) {
+		// The result buffer and the chunks for the worker
+		assert.Greater(t, linesRead(t), int64(1000))
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		// The pending lines, the chunk being filled and the buffer of the scanner
+		assert.LessOrEqual(t, linesRead(t, gogrep.WithMaxPendingLines(10), gogrep.WithBlockingResults()), int64(2*10+4))
+	})
+
+	t.Run("all lines", func(t *testing.T) {
+		const n = 1000
+		resultC, err := gogrep.New(gogrep.WithMaxPendingLines(3), gogrep.WithBlockingResults()).
+			Grep(context.TODO(), "x", strings.NewReader(strings.Repeat(endlessLine, n)))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var got int
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got++
+		}
+		assert.Equal(t, n, got)
+	})
+}
@@ -0,0 +1,174 @@
+package gogrep
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// batchMaxSize is the max size of the small sources concatenated into a batch.
+const batchMaxSize = 1 << 20
+
+// batchable returns true if the small sources can be concatenated and grepped together by the config,
+// that is, no states are kept across the lines, no limits and stats are counted for each source and the sources end.
+func (c *Config) batchable() bool {
+	return !c.noBatching && !c.multiline && c.scope == "" && len(c.notInside) == 0 && c.encoding == "" &&
+		c.delimiter >= 0 && c.maxResults <= 0 && c.maxCount <= 0 && c.statsCollector == nil && c.progress == nil && c.follow <= 0 &&
+		len(c.readerMiddleware) == 0 && c.perSourceTimeout <= 0 && c.checkpointInterval <= 0 && !c.startPositioned()
+}
+
+// sameBatch returns true if the configs grep the same way except for WithSourceTag.
+func sameBatch(a, b *Config) bool {
+	if a == b {
+		return true
+	}
+	x, y := *a, *b
+	if reflect.ValueOf(x.splitFunc).Pointer() != reflect.ValueOf(y.splitFunc).Pointer() {
+		return false
+	}
+	x.splitFunc, y.splitFunc = nil, nil
+	x.sourceTag, y.sourceTag = nil, nil
+	x.sink, y.sink = nil, nil                         // used only by the Grepper
+	x.readerStrategies, y.readerStrategies = nil, nil // the batched sources are read already
+	x.concurrencyHint, y.concurrencyHint = nil, nil   // the batched sources are smaller than a range
+	x.sourceName, y.sourceName = "", ""
+	x.regexCache, y.regexCache = nil, nil // the regexes are compiled already
+	return reflect.DeepEqual(x, y)
+}
+
+// readSmall reads the whole source if it is small enough to be batched by the config.
+// Otherwise the reader of the source is replaced by the reader of the head read.
+func readSmall(c *Config, src *NamedSource) ([]byte, bool) {
+	if !c.batchable() {
+		return nil, false
+	}
+	if _, ok := src.Reader.(SizedReaderAt); ok {
+		return nil, false // grepped by the ranges
+	}
+	h, ok := src.Reader.(*headReader)
+	if !ok {
+		h = newHeadReader(src.Reader)
+		src.Reader = h
+	}
+	if h.err != io.EOF {
+		return nil, false // large or failed
+	}
+	head := h.head[:min(len(h.head), binaryBlockSize)]
+	if (c.decompression && IsCompressed(head)) || (c.binaryFiles != BinaryText && IsBinary(head)) {
+		return nil, false
+	}
+	return h.head, true
+}
+
+// sourceBatch is the small sources concatenated to be grepped as a source.
+// The records of each source are terminated by the delimiter added if missing,
+// and the lines and the offsets of the results are converted into the ones in the sources.
+type sourceBatch struct {
+	config   *Config
+	data     []byte
+	lines    int
+	sources  []*sourceGrep
+	greppers []*grepper
+	starts   []batchStart
+}
+
+// batchStart is the beginning of a source in the batch.
+type batchStart struct {
+	line   int // the number of the records before the source
+	offset int64
+	mapper OffsetMapper // nil if the source is not an OffsetMapper
+}
+
+// fits returns true if the source can be added to the batch.
+func (s *sourceBatch) fits(c *Config, size int) bool {
+	return sameBatch(s.config, c) && len(s.data)+size <= batchMaxSize
+}
+
+func (s *sourceBatch) add(g *sourceGrep, gr *grepper, src NamedSource, content []byte) {
+	if s.config == nil {
+		s.config = gr.config
+	}
+	delimiter := byte(s.config.delimiter)
+	s.sources = append(s.sources, g)
+	s.greppers = append(s.greppers, gr)
+	mapper, _ := src.Reader.(OffsetMapper)
+	s.starts = append(s.starts, batchStart{
+		line:   s.lines,
+		offset: int64(len(s.data)),
+		mapper: mapper,
+	})
+	s.data = append(s.data, content...)
+	s.lines += bytes.Count(content, []byte{delimiter})
+	if len(content) > 0 && content[len(content)-1] != delimiter {
+		s.data = append(s.data, delimiter)
+		s.lines++
+	}
+}
+
+// grep greps the batch and sets the results of each source.
+// The results are sent after the grep of the whole batch.
+func (s *sourceBatch) grep(ctx context.Context, r Matcher) {
+	c := *s.config
+	c.sourceTag = nil          // tagged by the source
+	c.binaryFiles = BinaryText // the binary sources are not batched
+	c.decompression = false    // nor the compressed sources
+	g := &grepper{config: &c}
+	// grepMatcher does not fail since the config is validated
+	resultC, _ := g.grepMatcher(ctx, r, bytes.NewReader(s.data))
+	chans := make([]chan Result, len(s.sources))
+	for i, src := range s.sources {
+		chans[i] = make(chan Result)
+		src.resultC = chans[i]
+	}
+	go func() {
+		results := make([][]Result, len(s.sources))
+		for x := range resultC {
+			i := s.index(x)
+			results[i] = append(results[i], s.greppers[i].tagged(s.localize(i, x)))
+		}
+		for i, xs := range results {
+			for _, x := range xs {
+				chans[i] <- x
+			}
+			close(chans[i])
+		}
+	}()
+}
+
+// index returns the index of the source of the result.
+// The results without the lines, e.g. the cancellation, belong to the first source.
+func (s *sourceBatch) index(r Result) int {
+	if r.Line() < 1 {
+		return 0
+	}
+	return sort.Search(len(s.starts), func(i int) bool { return s.starts[i].line >= r.Line() }) - 1
+}
+
+// localize converts the line and the offset of the result into the ones in the source.
+func (s *sourceBatch) localize(i int, r Result) Result {
+	if r.Line() < 1 {
+		return r
+	}
+	start := s.starts[i]
+	offset := r.Offset() - start.offset
+	if start.mapper != nil {
+		offset = start.mapper.MapOffset(offset)
+	}
+	return &batchResult{
+		Result: r,
+		line:   r.Line() - start.line,
+		offset: offset,
+	}
+}
+
+// batchResult is a Result of a source in a batch.
+type batchResult struct {
+	Result
+	line   int
+	offset int64
+}
+
+func (s *batchResult) Line() int     { return s.line }
+func (s *batchResult) Offset() int64 { return s.offset }
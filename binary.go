@@ -0,0 +1,49 @@
+package gogrep
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// BinaryFiles is the policy for the binary sources.
+type BinaryFiles string
+
+const (
+	// BinaryText treats the binary sources as text.
+	BinaryText BinaryFiles = "text"
+	// BinaryMatches emits a Result whose Err is ErrBinaryFile instead of the matches
+	// if a binary source matches.
+	BinaryMatches BinaryFiles = "binary"
+	// BinaryWithoutMatch assumes that the binary sources do not match.
+	BinaryWithoutMatch BinaryFiles = "without-match"
+)
+
+// ErrBinaryFile means the binary source matches with BinaryMatches.
+var ErrBinaryFile = errors.New("binary file matches")
+
+// IsBinary reports whether the data is binary, that is, it contains a NUL byte.
+func IsBinary(data []byte) bool { return bytes.IndexByte(data, 0) >= 0 }
+
+// binaryDetector detects whether the source is binary by the first block read.
+type binaryDetector struct {
+	r       io.Reader
+	checked bool
+	binary  bool
+}
+
+func (s *binaryDetector) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if !s.checked && n > 0 {
+		s.checked = true
+		s.binary = IsBinary(p[:n])
+	}
+	return n, err
+}
+
+func (s *binaryDetector) MapOffset(pos int64) int64 {
+	if m, ok := s.r.(OffsetMapper); ok {
+		return m.MapOffset(pos)
+	}
+	return pos
+}
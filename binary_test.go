@@ -0,0 +1,41 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryFiles(t *testing.T) {
+	const (
+		binary = "match\x00\nmatch\n"
+		text   = "match\nmatch\n"
+	)
+	grep := func(t *testing.T, binaryFiles gogrep.BinaryFiles, source string) []gogrep.Result {
+		resultC, err := gogrep.New(gogrep.WithBinaryFiles(binaryFiles)).Grep(context.TODO(), "match", strings.NewReader(source))
+		assert.Nil(t, err)
+		return toResultSlice(resultC)
+	}
+
+	t.Run("text", func(t *testing.T) {
+		assert.Equal(t, 2, len(grep(t, gogrep.BinaryText, binary)))
+	})
+	t.Run("binary", func(t *testing.T) {
+		results := grep(t, gogrep.BinaryMatches, binary)
+		assert.Equal(t, 1, len(results))
+		assert.ErrorIs(t, results[0].Err(), gogrep.ErrBinaryFile)
+		assert.Equal(t, 0, len(grep(t, gogrep.BinaryMatches, "\x00nothing")))
+		assert.Equal(t, 2, len(grep(t, gogrep.BinaryMatches, text)))
+	})
+	t.Run("without match", func(t *testing.T) {
+		assert.Equal(t, 0, len(grep(t, gogrep.BinaryWithoutMatch, binary)))
+		assert.Equal(t, 2, len(grep(t, gogrep.BinaryWithoutMatch, text)))
+	})
+	t.Run("unknown", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithBinaryFiles("unknown")).Grep(context.TODO(), "match", strings.NewReader(text))
+		assert.NotNil(t, err)
+	})
+}
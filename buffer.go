@@ -0,0 +1,87 @@
+package gogrep
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// lineArenaSize is the size of a buffer of lineArena.
+const lineArenaSize = 64 << 10
+
+// lineArena copies the lines into the buffers shared by the lines instead of a string per line.
+// The bytes of a buffer are never written again once the string of them is made,
+// so the strings are valid as long as they are referred.
+type lineArena struct {
+	buf []byte
+}
+
+func (s *lineArena) text(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if cap(s.buf)-len(s.buf) < len(b) {
+		size := lineArenaSize
+		if len(b) > size {
+			size = len(b)
+		}
+		s.buf = make([]byte, 0, size)
+	}
+	start := len(s.buf)
+	s.buf = append(s.buf, b...)
+	return unsafe.String(&s.buf[start], len(b))
+}
+
+// CloneResult returns a copy of the Result that does not share the memory with the other Results.
+// The Results with WithSharedLineBuffers keep the buffer of the lines around them alive,
+// so clone them to keep a few of them long.
+func CloneResult(r Result) Result {
+	c := &clonedResult{
+		text:     strings.Clone(r.Text()),
+		err:      r.Err(),
+		line:     r.Line(),
+		offset:   r.Offset(),
+		source:   r.Source(),
+		tag:      r.Tag(),
+		distance: r.Distance(),
+		context:  sourceContext(r),
+	}
+	if x := r.PatternID(); x != nil {
+		c.patterns = append([]string(nil), x...)
+	}
+	if x := r.MatchRanges(); x != nil {
+		c.ranges = append([][2]int(nil), x...)
+	}
+	if x := r.Submatches(); x != nil {
+		c.submatches = make([]string, len(x))
+		for i, s := range x {
+			c.submatches[i] = strings.Clone(s)
+		}
+	}
+	return c
+}
+
+type clonedResult struct {
+	text       string
+	err        error
+	line       int
+	offset     int64
+	ranges     [][2]int
+	submatches []string
+	source     string
+	tag        interface{}
+	patterns   []string
+	distance   int
+	context    *ResultContext
+}
+
+func (s *clonedResult) Text() string                  { return s.text }
+func (s *clonedResult) Err() error                    { return s.err }
+func (s *clonedResult) Line() int                     { return s.line }
+func (s *clonedResult) Offset() int64                 { return s.offset }
+func (s *clonedResult) MatchRanges() [][2]int         { return s.ranges }
+func (s *clonedResult) Submatches() []string          { return s.submatches }
+func (s *clonedResult) Source() string                { return s.source }
+func (s *clonedResult) Tag() interface{}              { return s.tag }
+func (s *clonedResult) PatternID() []string           { return s.patterns }
+func (s *clonedResult) Distance() int                 { return s.distance }
+func (s *clonedResult) resultContext() *ResultContext { return s.context }
@@ -0,0 +1,81 @@
+package gogrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CancellationReason is the reason why a grep ended early.
+type CancellationReason string
+
+const (
+	// CancelContext is the context canceled by the caller without the other reasons.
+	CancelContext CancellationReason = "canceled"
+	// CancelDeadline is the deadline of the context exceeded.
+	CancelDeadline CancellationReason = "deadline"
+	// CancelMaxResults is WithMaxResults reached.
+	CancelMaxResults CancellationReason = "max-results"
+	// CancelMaxCount is WithMaxCount reached.
+	CancelMaxCount CancellationReason = "max-count"
+	// CancelSignal is the signal that the process got, given by CancelCause.
+	CancelSignal CancellationReason = "signal"
+	// CancelConsumer is the consumer that stopped reading the results, given by CancelCause.
+	CancelConsumer CancellationReason = "consumer-closed"
+	// CancelTimeout is the timeout of the grep of a source, given by CancelCause.
+	CancelTimeout CancellationReason = "timeout"
+	// CancelDrain is Session.Close that abandoned the running greps by WithDrainTimeout.
+	CancelDrain CancellationReason = "drain-timeout"
+)
+
+// CancellationError is the error of the Result of a grep canceled by the context, with the reason of the cancel.
+// It unwraps into the error of the context, e.g. context.Canceled.
+type CancellationError struct {
+	Reason CancellationReason
+	Err    error
+}
+
+func (e *CancellationError) Error() string { return fmt.Sprintf("%v (%s)", e.Err, e.Reason) }
+func (e *CancellationError) Unwrap() error { return e.Err }
+
+// CancelCause returns the cause to cancel the context of a grep for the reason,
+// e.g. cancel(CancelCause(CancelSignal)) with the cancel of context.WithCancelCause,
+// or context.WithTimeoutCause(ctx, d, CancelCause(CancelTimeout)).
+func CancelCause(reason CancellationReason) error {
+	return &CancellationError{
+		Reason: reason,
+		Err:    context.Canceled,
+	}
+}
+
+// CancellationReasonOf returns the reason of the error of a canceled grep, empty if the error is not the cancel.
+func CancellationReasonOf(err error) CancellationReason {
+	var e *CancellationError
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return ""
+}
+
+// cancellationError returns the error of the context canceled with the reason by the cause of the context.
+func cancellationError(ctx context.Context) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	var e *CancellationError
+	if errors.As(context.Cause(ctx), &e) {
+		return &CancellationError{
+			Reason: e.Reason,
+			Err:    err,
+		}
+	}
+	reason := CancelContext
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason = CancelDeadline
+	}
+	return &CancellationError{
+		Reason: reason,
+		Err:    err,
+	}
+}
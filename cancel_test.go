@@ -0,0 +1,121 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancellationReason(t *testing.T) {
+	t.Run("already canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		_, err := gogrep.New().Grep(ctx, "ra", nil)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, gogrep.CancelContext, gogrep.CancellationReasonOf(err))
+	})
+
+	t.Run("cause", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.TODO())
+		cancel(gogrep.CancelCause(gogrep.CancelSignal))
+		_, err := gogrep.New().Grep(ctx, "ra", nil)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, gogrep.CancelSignal, gogrep.CancellationReasonOf(err))
+	})
+
+	t.Run("not canceled", func(t *testing.T) {
+		assert.Equal(t, gogrep.CancellationReason(""), gogrep.CancellationReasonOf(context.Canceled))
+	})
+
+	for _, tc := range []struct {
+		title   string
+		timeout func(context.Context) (context.Context, context.CancelFunc)
+		want    gogrep.CancellationReason
+	}{
+		{
+			title: "deadline",
+			timeout: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return context.WithTimeout(ctx, 100*time.Millisecond)
+			},
+			want: gogrep.CancelDeadline,
+		},
+		{
+			title: "timeout cause",
+			timeout: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return context.WithTimeoutCause(ctx, 100*time.Millisecond, gogrep.CancelCause(gogrep.CancelTimeout))
+			},
+			want: gogrep.CancelTimeout,
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			var stats gogrep.Stats
+			grepper := gogrep.New(
+				gogrep.WithResultBufferSize(1),
+				gogrep.WithStatsCollector(func(s gogrep.Stats) { stats = s }),
+			)
+			source := &delayReader{
+				reader: strings.NewReader("delayed"),
+				delay:  300 * time.Millisecond,
+			}
+			ctx, cancel := tc.timeout(context.TODO())
+			defer cancel()
+			resultC, err := grepper.Grep(ctx, `.+`, source)
+			assert.Nil(t, err)
+			results := toResultSlice(resultC)
+			assert.Equal(t, 1, len(results))
+			assert.ErrorIs(t, results[0].Err(), context.DeadlineExceeded)
+			assert.Equal(t, tc.want, gogrep.CancellationReasonOf(results[0].Err()))
+			assert.Equal(t, tc.want, stats.Cancellation)
+		})
+	}
+}
+
+func TestStatsCancellation(t *testing.T) {
+	const source = "a1\na2\na3\nb1\n"
+	for _, tc := range []struct {
+		title string
+		opt   []gogrep.Option
+		want  gogrep.CancellationReason
+	}{
+		{
+			title: "whole source",
+		},
+		{
+			title: "max results",
+			opt:   []gogrep.Option{gogrep.WithMaxResults(1)},
+			want:  gogrep.CancelMaxResults,
+		},
+		{
+			title: "max count",
+			opt:   []gogrep.Option{gogrep.WithMaxCount(2)},
+			want:  gogrep.CancelMaxCount,
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			var stats gogrep.Stats
+			opt := append([]gogrep.Option{
+				gogrep.WithStatsCollector(func(s gogrep.Stats) { stats = s }),
+			}, tc.opt...)
+			resultC, err := gogrep.New(opt...).Grep(context.TODO(), "a", strings.NewReader(source))
+			assert.Nil(t, err)
+			for x := range resultC {
+				assert.Nil(t, x.Err())
+			}
+			assert.Equal(t, tc.want, stats.Cancellation)
+		})
+	}
+
+	t.Run("add", func(t *testing.T) {
+		var total gogrep.Stats
+		total.Add(gogrep.Stats{})
+		total.Add(gogrep.Stats{Cancellation: gogrep.CancelMaxCount})
+		total.Add(gogrep.Stats{Cancellation: gogrep.CancelDeadline})
+		assert.Equal(t, gogrep.CancelMaxCount, total.Cancellation)
+	})
+}
@@ -0,0 +1,31 @@
+package gogrep
+
+import "sort"
+
+// capabilities are the optional features compiled in.
+var capabilities = map[string]bool{
+	"decompress:gzip":     true,
+	"decompress:bzip2":    true,
+	"decompress:zstd":     true,
+	"frame:bgzf":          true,
+	"frame:zstd-seekable": true,
+	"encoding":            true,
+}
+
+// registerCapability makes an optional feature listed by Capabilities.
+// It is intended to be called from init functions of build-tagged files.
+func registerCapability(name string) { capabilities[name] = true }
+
+// Capabilities returns the names of the optional features compiled in, in sorted order,
+// e.g. "engine:regexp", "decompress:zstd" and "sparse".
+func Capabilities() []string {
+	r := make([]string, 0, len(capabilities))
+	for c := range capabilities {
+		r = append(r, c)
+	}
+	for _, e := range Engines() {
+		r = append(r, "engine:"+string(e))
+	}
+	sort.Strings(r)
+	return r
+}
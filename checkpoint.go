@@ -0,0 +1,122 @@
+package gogrep
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+// ErrCheckpoint means the source is consumed up to the position of the Result, reported with WithCheckpoints.
+var ErrCheckpoint = errors.New("checkpoint")
+
+// WithCheckpoints emits a Result whose Err is ErrCheckpoint at most once per interval while a source is grepped,
+// e.g. to resume a very large scan interrupted from the position by WithStartPosition instead of starting over.
+// Result.Offset of the checkpoint is the offset of the first record not consumed yet, and Result.Line the number of the records before it,
+// and the results of the records before it are sent before the checkpoint,
+// though some results of the records after it may be sent before it too, since the records are matched in parallel.
+// No checkpoint is sent at the end of the source, whose grep ends with the channel.
+// The results are not the failures of the sources; GrepSources does not stop with ErrorStop by them.
+// The sources are grepped sequentially, neither batched nor split into the ranges.
+// Not positive interval disables them, and CheckpointEveryMark sends them as often as possible.
+func WithCheckpoints(interval time.Duration) Option {
+	return func(c *Config) {
+		c.checkpointInterval = interval
+	}
+}
+
+// CheckpointEveryMark is the interval of WithCheckpoints to send a checkpoint whenever the records before a chunk are matched,
+// regardless of the time elapsed, e.g. to keep the fewest results of the records after the checkpoint.
+const CheckpointEveryMark time.Duration = 1
+
+// WithStartPosition tells the grep that the source begins at the offset after the lines of the source,
+// e.g. a file seeked to Result.Offset of the checkpoint by WithCheckpoints with Result.Line of it,
+// so that the offsets and the line numbers of the results continue from the position.
+// GrepFile seeks the file to the offset.
+// The source is grepped sequentially, neither batched nor split into the ranges.
+func WithStartPosition(offset int64, lines int) Option {
+	return func(c *Config) {
+		c.startOffset = offset
+		c.startLine = lines
+	}
+}
+
+// startPositioned returns true if the source begins in the middle by WithStartPosition.
+func (c *Config) startPositioned() bool {
+	return c.startOffset > 0 || c.startLine > 0
+}
+
+// newCheckpointResult returns the Result of the checkpoint before the record.
+func newCheckpointResult(r pipeline.Record) Result {
+	return &result{
+		err:    ErrCheckpoint,
+		line:   r.Number - 1,
+		offset: r.Offset,
+	}
+}
+
+// startReader is the source that begins at the offset by WithStartPosition.
+type startReader struct {
+	io.Reader
+	offset int64
+}
+
+func (s *startReader) MapOffset(pos int64) int64 {
+	if m, ok := s.Reader.(OffsetMapper); ok {
+		pos = m.MapOffset(pos)
+	}
+	return s.offset + pos
+}
+
+// checkpointObserver sends the checkpoints of the marks of the pipeline by WithCheckpoints.
+type checkpointObserver struct {
+	pipeline.Observer // nil without the other observers
+	clock             Clock
+	interval          time.Duration
+	send              func(Result)
+	mux               sync.Mutex
+	last              time.Time // the time of the last checkpoint or the start
+}
+
+func (s *checkpointObserver) ObserveChunk(worker int, chunk []pipeline.Record, elapsed time.Duration) {
+	if s.Observer != nil {
+		s.Observer.ObserveChunk(worker, chunk, elapsed)
+	}
+}
+
+func (s *checkpointObserver) ObserveMark(r pipeline.Record) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := s.clock.Now()
+	if s.interval != CheckpointEveryMark && now.Sub(s.last) < s.interval {
+		return
+	}
+	s.last = now
+	s.send(newCheckpointResult(r))
+}
+
+func (s *checkpointObserver) ObserveDrop(chunk []pipeline.Record) {
+	if o, ok := s.Observer.(pipeline.DropObserver); ok {
+		o.ObserveDrop(chunk)
+	}
+}
+
+func (s *checkpointObserver) ObserveDispatch(records, bytes int, blocked time.Duration) {
+	if o, ok := s.Observer.(pipeline.DispatchObserver); ok {
+		o.ObserveDispatch(records, bytes, blocked)
+	}
+}
+
+func (s *checkpointObserver) ObserveStart(worker int) {
+	if o, ok := s.Observer.(pipeline.WorkerObserver); ok {
+		o.ObserveStart(worker)
+	}
+}
+
+func (s *checkpointObserver) ObserveEnd(worker int) {
+	if o, ok := s.Observer.(pipeline.WorkerObserver); ok {
+		o.ObserveEnd(worker)
+	}
+}
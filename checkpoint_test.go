@@ -0,0 +1,170 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// position is a Result by the line and the offset, a checkpoint if cp.
+type position struct {
+	line   int
+	offset int64
+	cp     bool
+}
+
+// frozenClock never advances.
+type frozenClock struct{}
+
+func (frozenClock) Now() time.Time                         { return time.Time{} }
+func (frozenClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func TestWithCheckpoints(t *testing.T) {
+	var (
+		b       strings.Builder
+		offsets = []int64{0} // of the lines from 1
+	)
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+		offsets = append(offsets, int64(b.Len()))
+	}
+	source := b.String()
+	collect := func(t *testing.T, resultC <-chan gogrep.Result, err error) []position {
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		var got []position
+		for r := range resultC {
+			if err := r.Err(); err != nil {
+				assert.True(t, errors.Is(err, gogrep.ErrCheckpoint), "%v", err)
+				got = append(got, position{line: r.Line(), offset: r.Offset(), cp: true})
+				continue
+			}
+			assert.Equal(t, offsets[r.Line()-1], r.Offset())
+			assert.True(t, strings.HasSuffix(r.Text(), "7"), r.Text())
+			got = append(got, position{line: r.Line(), offset: r.Offset()})
+		}
+		return got
+	}
+	options := func(opt ...gogrep.Option) []gogrep.Option {
+		return append([]gogrep.Option{
+			gogrep.WithThreads(4),
+			gogrep.WithFlushPolicy(gogrep.FlushPolicy{MaxLines: 10}),
+			gogrep.WithClock(&stepClock{}),
+		}, opt...)
+	}
+
+	var checkpoints []position
+	t.Run("grep", func(t *testing.T) {
+		resultC, err := gogrep.New(options(gogrep.WithCheckpoints(time.Second))...).Grep(context.TODO(), "7$", strings.NewReader(source))
+		got := collect(t, resultC, err)
+		var matches int
+		for i, x := range got {
+			if !x.cp {
+				matches++
+				continue
+			}
+			assert.Equal(t, offsets[x.line], x.offset, "the offset of the next line")
+			assert.True(t, matches >= x.line/10, "the lines before the checkpoint are matched %v", x)
+			for _, y := range got[i+1:] {
+				assert.True(t, y.line > x.line, "after the checkpoint %v: %v", x, y)
+			}
+			checkpoints = append(checkpoints, x)
+		}
+		assert.Equal(t, 100, matches)
+		assert.True(t, len(checkpoints) > 0)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		if len(checkpoints) == 0 {
+			t.Skip("no checkpoints")
+		}
+		cp := checkpoints[len(checkpoints)/2]
+		resultC, err := gogrep.New(options(gogrep.WithStartPosition(cp.offset, cp.line))...).
+			Grep(context.TODO(), "7$", strings.NewReader(source[cp.offset:]))
+		got := collect(t, resultC, err)
+		assert.Equal(t, 100-cp.line/10, len(got))
+		if len(got) > 0 {
+			assert.Equal(t, cp.line+7, got[0].line)
+		}
+	})
+
+	t.Run("resume file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "file")
+		if !assert.Nil(t, os.WriteFile(path, []byte(source), 0o600)) {
+			return
+		}
+		resultC, err := gogrep.New(options(gogrep.WithStartPosition(offsets[500], 500))...).GrepFile(context.TODO(), "7$", path)
+		got := collect(t, resultC, err)
+		assert.Equal(t, 50, len(got))
+		if len(got) > 0 {
+			assert.Equal(t, position{line: 507, offset: offsets[506]}, got[0])
+		}
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		resultC, err := gogrep.New(options(gogrep.WithCheckpoints(time.Second))...).GrepSources(context.TODO(), []string{"7$"}, []gogrep.NamedSource{
+			{Name: "a", Reader: strings.NewReader(source)},
+			{Name: "b", Reader: strings.NewReader("7\n")},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		var (
+			matches     int
+			checkpoints int
+		)
+		for r := range resultC {
+			switch err := r.Err(); {
+			case err == nil:
+				matches++
+			case errors.Is(err, gogrep.ErrCheckpoint):
+				assert.Equal(t, "a", r.Source())
+				checkpoints++
+			default:
+				t.Errorf("unexpected error %v", err)
+			}
+		}
+		assert.Equal(t, 101, matches)
+		assert.True(t, checkpoints > 0)
+	})
+
+	t.Run("every mark", func(t *testing.T) {
+		count := func(interval time.Duration) int {
+			resultC, err := gogrep.New(
+				gogrep.WithThreads(4),
+				gogrep.WithFlushPolicy(gogrep.FlushPolicy{MaxLines: 10}),
+				gogrep.WithClock(&frozenClock{}),
+				gogrep.WithCheckpoints(interval),
+			).Grep(context.TODO(), "7$", strings.NewReader(source))
+			var n int
+			for _, x := range collect(t, resultC, err) {
+				if x.cp {
+					n++
+				}
+			}
+			return n
+		}
+		assert.Equal(t, 0, count(time.Second), "the time does not elapse")
+		assert.True(t, count(gogrep.CheckpointEveryMark) > 0, "regardless of the time")
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		for _, opt := range [][]gogrep.Option{
+			{gogrep.WithCheckpoints(time.Second), gogrep.WithDecompression()},
+			{gogrep.WithCheckpoints(time.Second), gogrep.WithMultiline()},
+			{gogrep.WithStartPosition(10, 1), gogrep.WithEncoding("shift_jis")},
+		} {
+			_, err := gogrep.New(opt...).Grep(context.TODO(), "a", strings.NewReader("a\n"))
+			assert.NotNil(t, err)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// aggregateSpec is a function of -aggregate like count or p95(duration($2)).
+type aggregateSpec struct {
+	title    string
+	function string  // count, sum, avg, min, max or p
+	quantile float64 // of p
+	expr     *captureExpr
+}
+
+func parseAggregateSpec(spec string) (*aggregateSpec, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "count" {
+		return &aggregateSpec{
+			title:    spec,
+			function: spec,
+		}, nil
+	}
+	name, arg, ok := strings.Cut(spec, "(")
+	if !ok {
+		return nil, fmt.Errorf("invalid aggregate %s", spec)
+	}
+	arg, ok = strings.CutSuffix(arg, ")")
+	if !ok {
+		return nil, fmt.Errorf("invalid aggregate %s", spec)
+	}
+	s := &aggregateSpec{
+		title:    spec,
+		function: strings.TrimSpace(name),
+	}
+	switch s.function {
+	case "sum", "avg", "min", "max":
+	default:
+		p, ok := strings.CutPrefix(s.function, "p")
+		if !ok {
+			return nil, fmt.Errorf("invalid aggregate %s: unknown function %s", spec, s.function)
+		}
+		x, err := strconv.ParseFloat(p, 64)
+		if err != nil || x < 0 || x > 100 {
+			return nil, fmt.Errorf("invalid aggregate %s: unknown function %s", spec, s.function)
+		}
+		s.function = "p"
+		s.quantile = x / 100
+	}
+	expr, err := parseCaptureExpr(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid aggregate %s: %w", spec, err)
+	}
+	s.expr = expr
+	return s, nil
+}
+
+// splitAggregateSpecs splits the functions separated by the commas outside the parentheses.
+func splitAggregateSpecs(specs string) []string {
+	var (
+		xs    []string
+		depth int
+		start int
+	)
+	for i, c := range specs {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				xs = append(xs, specs[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(xs, specs[start:])
+}
+
+// aggregateState is the values of a function for a key.
+type aggregateState struct {
+	count  int
+	sum    float64
+	min    float64
+	max    float64
+	sketch *quantileSketch // of p
+}
+
+func (s *aggregateState) add(spec *aggregateSpec, v float64) {
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.count++
+	s.sum += v
+	if spec.function == "p" {
+		if s.sketch == nil {
+			s.sketch = newQuantileSketch()
+		}
+		s.sketch.add(v)
+	}
+}
+
+func (s *aggregateState) result(spec *aggregateSpec) float64 {
+	if spec.function != "count" && s.count == 0 {
+		return math.NaN()
+	}
+	switch spec.function {
+	case "count":
+		return float64(s.count)
+	case "sum":
+		return s.sum
+	case "avg":
+		return s.sum / float64(s.count)
+	case "min":
+		return s.min
+	case "max":
+		return s.max
+	default:
+		return s.sketch.quantile(spec.quantile)
+	}
+}
+
+// aggregator computes the functions of -aggregate of the matches grouped by the key capture group
+// to print the summary table instead of the matches.
+type aggregator struct {
+	specs   []*aggregateSpec
+	key     *captureExpr // nil without by
+	regexes []*regexp.Regexp
+	groups  map[string][]*aggregateState
+}
+
+// matchAggregate is the -aggregate, nil without -aggregate.
+var matchAggregate *aggregator
+
+// newAggregator parses -aggregate like 'count,p95(duration($2)) by $1'.
+func newAggregator(spec string, patterns []string) (*aggregator, error) {
+	regexes, err := compilePatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	s := &aggregator{
+		regexes: regexes,
+		groups:  map[string][]*aggregateState{},
+	}
+	functions := spec
+	if i := strings.LastIndex(spec, " by "); i >= 0 {
+		functions = spec[:i]
+		key, err := parseCaptureExpr(spec[i+len(" by "):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid aggregate key: %w", err)
+		}
+		if key.conv != "" {
+			return nil, fmt.Errorf("invalid aggregate key %s: want $N or ${NAME}", key.expr)
+		}
+		s.key = key
+	}
+	for _, x := range splitAggregateSpecs(functions) {
+		f, err := parseAggregateSpec(x)
+		if err != nil {
+			return nil, err
+		}
+		s.specs = append(s.specs, f)
+	}
+	return s, nil
+}
+
+// add aggregates the match.
+// The match without the key is dropped, and the values that cannot be converted are not aggregated.
+func (s *aggregator) add(m *match) {
+	var key string
+	if s.key != nil {
+		var ok bool
+		if key, ok = s.key.capture(s.regexes, m.Text); !ok {
+			return
+		}
+	}
+	states, ok := s.groups[key]
+	if !ok {
+		states = make([]*aggregateState, len(s.specs))
+		for i := range states {
+			states[i] = &aggregateState{}
+		}
+		s.groups[key] = states
+	}
+	for i, spec := range s.specs {
+		if spec.function == "count" {
+			states[i].count++
+			continue
+		}
+		if v, ok := spec.expr.eval(s.regexes, m.Text); ok {
+			states[i].add(spec, v)
+		}
+	}
+}
+
+// writeSummary writes the table of the functions by the keys in order of the keys.
+func (s *aggregator) writeSummary(w io.Writer) error {
+	keys := make([]string, 0, len(s.groups))
+	for k := range s.groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	var header []string
+	if s.key != nil {
+		header = append(header, strings.TrimSpace(s.key.expr))
+	}
+	for _, spec := range s.specs {
+		header = append(header, spec.title)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, k := range keys {
+		var row []string
+		if s.key != nil {
+			row = append(row, k)
+		}
+		for i, spec := range s.specs {
+			row = append(row, formatAggregate(s.groups[k][i].result(spec)))
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// formatAggregate formats the value rounded to 6 decimal places, or - for no values.
+func formatAggregate(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return strconv.FormatFloat(math.Round(v*1e6)/1e6, 'f', -1, 64)
+}
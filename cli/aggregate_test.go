@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantileSketch(t *testing.T) {
+	s := newQuantileSketch()
+	assert.True(t, math.IsNaN(s.quantile(0.5)))
+	for i := 1; i <= 10000; i++ {
+		s.add(float64(i))
+	}
+	for _, q := range []float64{0, 0.5, 0.95, 0.99, 1} {
+		want := math.Max(1, math.Ceil(q*10000))
+		assert.InEpsilon(t, want, s.quantile(q), sketchAccuracy, "q=%v", q)
+	}
+	assert.LessOrEqual(t, len(s.positive), 1000, "bounded buckets")
+
+	s = newQuantileSketch()
+	for _, v := range []float64{-10, -1, 0, 1, 10} {
+		s.add(v)
+	}
+	assert.Equal(t, float64(-10), s.quantile(0))
+	assert.InEpsilon(t, -1, s.quantile(0.4), sketchAccuracy)
+	assert.Equal(t, float64(0), s.quantile(0.5))
+	assert.InEpsilon(t, 1, s.quantile(0.8), sketchAccuracy)
+	assert.Equal(t, float64(10), s.quantile(1))
+}
+
+func TestAggregator(t *testing.T) {
+	s, err := newAggregator("count, sum(duration($2)), avg($3), max(${ms}), p50(len($1)) by $1", []string{`(\w+) (?P<ms>\d+ms) (\d+)`})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, text := range []string{
+		"GET 100ms 1",
+		"POST 300ms 2",
+		"GET 200ms 5",
+		"no match",
+	} {
+		s.add(&match{Text: text})
+	}
+	var b bytes.Buffer
+	assert.Nil(t, s.writeSummary(&b))
+	assert.Equal(t, `$1   count sum(duration($2)) avg($3) max(${ms}) p50(len($1))
+GET  2     0.3               3       -          3
+POST 1     0.3               2       -          4
+`, b.String())
+
+	for _, spec := range []string{
+		"count by len($1)",
+		"sum",
+		"median($1)",
+		"p101($1)",
+		"sum($1) by",
+	} {
+		_, err := newAggregator(spec, []string{`(\d+)`})
+		assert.NotNil(t, err, spec)
+	}
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/berquerant/gogrep"
+)
+
+// archiveSuffixes are the suffixes of the files grepped as the archives with -search-archives.
+var archiveSuffixes = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tar.zst", ".zip"}
+
+// isArchive returns true if the path is grepped as an archive.
+func isArchive(path string) bool {
+	if !*searchArchives || path == "" {
+		return false
+	}
+	lower := strings.ToLower(path)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// grepTargetsWithArchives greps the targets in order,
+// grepping the archives entry by entry and the other targets in parallel.
+func grepTargetsWithArchives(ctx context.Context, patterns []string, targets []*target) error {
+	var start int
+	for i, t := range targets {
+		if !isArchive(t.path) {
+			continue
+		}
+		if start < i {
+			if err := grepSources(ctx, patterns, targets[start:i]); err != nil {
+				return err
+			}
+		}
+		start = i + 1
+		if err := grepArchive(ctx, patterns, t); err != nil {
+			return err
+		}
+	}
+	if start < len(targets) {
+		return grepSources(ctx, patterns, targets[start:])
+	}
+	return nil
+}
+
+// grepArchive greps the regular files in the archive in order.
+// The entries are labeled ARCHIVE!PATH.
+func grepArchive(ctx context.Context, patterns []string, t *target) error {
+	f, err := fileSystem.Open(t.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", t.name(), err)
+		targetFailed = true
+		return nil
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if ra, ok := f.(io.ReaderAt); ok {
+		if info, err := f.Stat(); err == nil {
+			r = io.NewSectionReader(ra, 0, info.Size())
+		}
+	}
+	for src, err := range gogrep.ArchiveSources(t.path, r) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", t.name(), err)
+			targetFailed = true
+			return nil
+		}
+		if err := grepSources(ctx, patterns, []*target{{
+			path:    src.Name,
+			root:    t.root,
+			reader:  src.Reader,
+			options: t.options,
+		}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
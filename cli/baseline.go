@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// baseline suppresses the recorded matches.
+type baseline struct {
+	fingerprints map[string]bool
+	records      []*match // matches to record for -update-baseline
+}
+
+// loadBaseline reads the baseline file written by -update-baseline or -fingerprint.
+// A missing file is an empty baseline.
+func loadBaseline(file string) (*baseline, error) {
+	b := &baseline{
+		fingerprints: map[string]bool{},
+	}
+	records, err := readFingerprints(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return b, nil
+		}
+		return nil, err
+	}
+	for _, r := range records {
+		b.fingerprints[r.Fingerprint] = true
+	}
+	return b, nil
+}
+
+// suppress returns true if the match is in the baseline.
+func (s *baseline) suppress(file, text string) bool {
+	return s.fingerprints[computeFingerprint(file, text)]
+}
+
+func (s *baseline) record(m *match) {
+	if m.Fingerprint == "" {
+		m.Fingerprint = computeFingerprint(m.File, m.Text)
+	}
+	s.records = append(s.records, m)
+}
+
+// write writes the recorded matches into the file.
+func (s *baseline) write(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sortRecords(s.records)
+	for _, r := range s.records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(f, "%s\n", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
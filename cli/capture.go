@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// captureExpr is the value of a capture group of a match for -score-by and -where:
+// $N or ${NAME} is the numeric value of the capture group, and the converters apply to them:
+// len($N) is the length in bytes, duration($N) is the seconds of the duration like 350ms or 1.2s
+// and bytes($N) is the bytes of the size like 4GiB or 512K.
+type captureExpr struct {
+	expr  string
+	conv  string // the name of the converter, empty for the number
+	group int    // -1 if named
+	name  string // the name of the capture group if named
+}
+
+// captureConverters convert the texts of the capture groups into the values by the names.
+var captureConverters = map[string]func(string) (float64, error){
+	"len": func(x string) (float64, error) {
+		return float64(len(x)), nil
+	},
+	"duration": parseDurationValue,
+	"bytes":    parseBytesValue,
+}
+
+func parseCaptureExpr(expr string) (*captureExpr, error) {
+	s := &captureExpr{
+		expr:  expr,
+		group: -1,
+	}
+	x := strings.TrimSpace(expr)
+	if conv, inner, ok := strings.Cut(x, "("); ok {
+		if _, found := captureConverters[strings.TrimSpace(conv)]; !found {
+			return nil, fmt.Errorf("invalid capture expression %s: unknown converter %s", expr, conv)
+		}
+		if inner, ok = strings.CutSuffix(inner, ")"); !ok {
+			return nil, fmt.Errorf("invalid capture expression %s", expr)
+		}
+		s.conv = strings.TrimSpace(conv)
+		x = strings.TrimSpace(inner)
+	}
+	ref, ok := strings.CutPrefix(x, "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid capture expression %s: want $N, ${NAME} or CONVERTER($N) where CONVERTER is len, duration or bytes", expr)
+	}
+	if name, ok := strings.CutPrefix(ref, "{"); ok {
+		if name, ok = strings.CutSuffix(name, "}"); !ok || name == "" {
+			return nil, fmt.Errorf("invalid capture expression %s", expr)
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			ref = strconv.Itoa(n)
+		} else {
+			s.name = name
+			return s, nil
+		}
+	}
+	n, err := strconv.Atoi(ref)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid capture expression %s", expr)
+	}
+	s.group = n
+	return s, nil
+}
+
+// eval returns the value of the text by the first regex that matches.
+// Returns false if no regexes match, the capture group does not exist or the value cannot be converted.
+func (s *captureExpr) eval(regexes []*regexp.Regexp, text string) (float64, bool) {
+	x, ok := s.capture(regexes, text)
+	if !ok {
+		return 0, false
+	}
+	v, err := s.parse(x)
+	return v, err == nil
+}
+
+// capture returns the capture group of the text by the first regex that matches.
+// Returns false if no regexes match or the capture group does not exist.
+func (s *captureExpr) capture(regexes []*regexp.Regexp, text string) (string, bool) {
+	for _, re := range regexes {
+		submatches := re.FindStringSubmatch(text)
+		if submatches == nil {
+			continue
+		}
+		i := s.group
+		if s.name != "" {
+			i = re.SubexpIndex(s.name)
+		}
+		if i < 0 || i >= len(submatches) {
+			return "", false
+		}
+		return submatches[i], true
+	}
+	return "", false
+}
+
+// parse converts the text into the value by the converter.
+func (s *captureExpr) parse(x string) (float64, error) {
+	if conv, ok := captureConverters[s.conv]; ok {
+		return conv(x)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(x), 64)
+}
+
+// parseLiteral converts the value compared with the expression:
+// the number for len, or the value by the converter otherwise.
+func (s *captureExpr) parseLiteral(x string) (float64, error) {
+	if s.conv == "len" {
+		return strconv.ParseFloat(strings.TrimSpace(x), 64)
+	}
+	return s.parse(x)
+}
+
+// parseDurationValue returns the seconds of the duration like 350ms, 1.2s or 1h30m as time.ParseDuration,
+// or the number as the seconds.
+func parseDurationValue(x string) (float64, error) {
+	x = strings.TrimSpace(x)
+	if v, err := strconv.ParseFloat(x, 64); err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(x)
+	if err != nil {
+		return 0, err
+	}
+	return d.Seconds(), nil
+}
+
+// byteUnits are the multipliers of the units of the sizes, case-insensitive.
+// The units without i like K and KB are also 1024 as the sizes in logs usually are.
+var byteUnits = map[string]float64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+	"p": 1 << 50, "pb": 1 << 50, "pib": 1 << 50,
+}
+
+// parseBytesValue returns the bytes of the size like 4GiB, 1.5M or 512 KB.
+func parseBytesValue(x string) (float64, error) {
+	x = strings.TrimSpace(x)
+	i := strings.IndexFunc(x, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.')
+	})
+	if i < 0 {
+		i = len(x)
+	}
+	v, err := strconv.ParseFloat(x[:i], 64)
+	if err != nil {
+		return 0, err
+	}
+	unit := strings.ToLower(strings.TrimSpace(x[i:]))
+	m, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit of size %s", x)
+	}
+	return v * m, nil
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureExpr(t *testing.T) {
+	regexes := []*regexp.Regexp{
+		regexp.MustCompile(`took (?P<ms>\d+)ms`),
+		regexp.MustCompile(`path=(\S+)`),
+	}
+	for _, tc := range []struct {
+		expr  string
+		text  string
+		want  float64
+		found bool
+		err   bool
+	}{
+		{expr: "$1", text: "GET /a took 120ms", want: 120, found: true},
+		{expr: "${ms}", text: "GET /a took 120ms", want: 120, found: true},
+		{expr: "${1}", text: "GET /a took 120ms", want: 120, found: true},
+		{expr: "len($0)", text: "GET /a took 120ms", want: 10, found: true},
+		{expr: " len( ${ms} ) ", text: "GET /a took 120ms", want: 3, found: true},
+		{expr: "len($1)", text: "path=/index.html", want: 11, found: true},
+		{expr: "$1", text: "path=/index.html"},
+		{expr: "${ms}", text: "path=/index.html"},
+		{expr: "$2", text: "GET /a took 120ms"},
+		{expr: "$1", text: "no match"},
+		{expr: "duration($1)", text: "GET /a took 120ms", want: 120, found: true},
+		{expr: "bytes($1)", text: "GET /a took 120ms", want: 120, found: true},
+		{expr: "1", err: true},
+		{expr: "sum($1)", err: true},
+		{expr: "len($1", err: true},
+		{expr: "${}", err: true},
+		{expr: "$-1", err: true},
+	} {
+		t.Run(tc.expr+" "+tc.text, func(t *testing.T) {
+			s, err := parseCaptureExpr(tc.expr)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			got, found := s.eval(regexes, tc.text)
+			assert.Equal(t, tc.found, found)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWhereCondition(t *testing.T) {
+	regexes := []*regexp.Regexp{regexp.MustCompile(`(\d+) (\S+)`)}
+	for _, tc := range []struct {
+		cond string
+		text string
+		want bool
+		err  bool
+	}{
+		{cond: "$1 > 500", text: "501 a", want: true},
+		{cond: "$1 > 500", text: "500 a"},
+		{cond: "$1 >= 500", text: "500 a", want: true},
+		{cond: "$1<500", text: "499 a", want: true},
+		{cond: "$1 <= 499.5", text: "500 a"},
+		{cond: "$1 == 500", text: "500 a", want: true},
+		{cond: "$1 != 500", text: "500 a"},
+		{cond: "len($2) == 3", text: "1 abc", want: true},
+		{cond: "$2 > 0", text: "1 abc"},
+		{cond: "$1 > 0", text: "no match"},
+		{cond: "len($2) == 3", text: "1 abc", want: true},
+		{cond: "duration($2) > 1.5s", text: "1 1m", want: true},
+		{cond: "duration($2) <= 350ms", text: "1 0.35", want: true},
+		{cond: "bytes($2) >= 4GiB", text: "1 4096M", want: true},
+		{cond: "bytes($2) < 1KB", text: "1 2k"},
+		{cond: "$1", err: true},
+		{cond: "duration($1) > 1x", err: true},
+		{cond: "$1 > x", err: true},
+		{cond: "x > 1", err: true},
+	} {
+		t.Run(tc.cond+" "+tc.text, func(t *testing.T) {
+			c, err := parseWhereCondition(tc.cond)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			if assert.Nil(t, err) {
+				assert.Equal(t, tc.want, c.match(regexes, tc.text))
+			}
+		})
+	}
+}
+
+func TestConverters(t *testing.T) {
+	for _, tc := range []struct {
+		conv  func(string) (float64, error)
+		input string
+		want  float64
+		err   bool
+	}{
+		{conv: parseDurationValue, input: "350ms", want: 0.35},
+		{conv: parseDurationValue, input: " 1.2s ", want: 1.2},
+		{conv: parseDurationValue, input: "1h30m", want: 5400},
+		{conv: parseDurationValue, input: "2.5", want: 2.5},
+		{conv: parseDurationValue, input: "fast", err: true},
+		{conv: parseBytesValue, input: "4GiB", want: 4 << 30},
+		{conv: parseBytesValue, input: "1.5M", want: 1.5 * (1 << 20)},
+		{conv: parseBytesValue, input: "512 kb", want: 512 << 10},
+		{conv: parseBytesValue, input: "100B", want: 100},
+		{conv: parseBytesValue, input: "100", want: 100},
+		{conv: parseBytesValue, input: "4XB", err: true},
+		{conv: parseBytesValue, input: "GiB", err: true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := tc.conv(tc.input)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			if assert.Nil(t, err) {
+				assert.InDelta(t, tc.want, got, 1e-9)
+			}
+		})
+	}
+}
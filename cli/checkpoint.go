@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/berquerant/gogrep"
+)
+
+var (
+	checkpointFile = commandLine.String("checkpoint", "", "Record the byte offset reached in each file to the file about every second and at the end, e.g. to continue a very large scan interrupted by -resume instead of starting over. The compressed files are skipped as they cannot be resumed, unless -decompress=false to grep them as they are.")
+	resumeScan     = commandLine.Bool("resume", false, "Continue the scan recorded by -checkpoint: skip the files done and grep the rest from the offsets reached, not printing the matches printed already. The files truncated since are grepped from the beginning.")
+)
+
+// checkpointInterval is the interval of writing the checkpoints to -checkpoint.
+const checkpointInterval = time.Second
+
+// scanCheckpoint is nil without -checkpoint.
+var scanCheckpoint *checkpointer
+
+// checkpointState is the content of the file of -checkpoint.
+type checkpointState struct {
+	Files map[string]*fileCheckpoint `json:"files"`
+}
+
+// fileCheckpoint is the position reached in a file.
+type fileCheckpoint struct {
+	// Size is the size of the file when grepped, to start over the truncated file.
+	Size int64 `json:"size"`
+	// Offset is the offset of the first line not grepped yet, and Line the number of the lines before it.
+	Offset int64 `json:"offset"`
+	Line   int   `json:"line"`
+	// Printed are the lines after Line whose matches are printed already,
+	// as many as the lines grepped in parallel ahead of Line at most.
+	Printed []int `json:"printed,omitempty"`
+	Done    bool  `json:"done,omitempty"`
+}
+
+// checkpointer records the positions reached in the files for -checkpoint and -resume.
+type checkpointer struct {
+	mux     sync.Mutex
+	path    string
+	state   checkpointState
+	printed map[string]map[int]bool // the lines printed before -resume by the files
+	written time.Time
+	err     error // the first error of writing
+}
+
+// openCheckpoint returns the checkpointer of the file, starting from the positions in the file if resume.
+// A missing file starts over.
+func openCheckpoint(path string, resume bool) (*checkpointer, error) {
+	s := &checkpointer{
+		path: path,
+		state: checkpointState{
+			Files: map[string]*fileCheckpoint{},
+		},
+		printed: map[string]map[int]bool{},
+		written: clock.Now(),
+	}
+	if !resume {
+		return s, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.state); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	if s.state.Files == nil {
+		s.state.Files = map[string]*fileCheckpoint{}
+	}
+	for name, x := range s.state.Files {
+		lines := make(map[int]bool, len(x.Printed))
+		for _, line := range x.Printed {
+			lines[line] = true
+		}
+		s.printed[name] = lines
+	}
+	return s, nil
+}
+
+// checkpointed returns true if the positions of the target are recorded, the files on the host.
+func checkpointed(t *target) bool {
+	return t.path != "" && t.reader == nil && isHostFS()
+}
+
+// targets returns the targets not done, starting the rest from the positions reached, nil-safe.
+func (s *checkpointer) targets(targets []*target) []*target {
+	if s == nil {
+		return targets
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	r := make([]*target, 0, len(targets))
+	for _, t := range targets {
+		if !checkpointed(t) {
+			r = append(r, t)
+			continue
+		}
+		if *decompress && compressedFile(t.path) {
+			msg.Fprintf(os.Stderr, "gogrep: %s: compressed file is skipped with -checkpoint\n", t.path)
+			skipReport.reportTarget(t, skipCompressed)
+			continue
+		}
+		var size int64
+		if info, err := os.Stat(t.path); err == nil {
+			size = info.Size()
+		}
+		x := *t
+		// The checkpoint forgets the lines printed before it, so it is reported for every chunk of the lines
+		// to keep only the lines printed ahead of it, as many as the lines grepped in parallel.
+		x.options = append(x.options[:len(x.options):len(x.options)], gogrep.WithCheckpoints(gogrep.CheckpointEveryMark))
+		c, ok := s.state.Files[t.path]
+		switch {
+		case ok && c.Done:
+			continue
+		case ok && size >= c.Size:
+			x.start = c.Offset
+			x.options = append(x.options, gogrep.WithStartPosition(c.Offset, c.Line))
+		default:
+			// Not grepped yet or truncated
+			s.state.Files[t.path] = &fileCheckpoint{Size: size}
+			delete(s.printed, t.path)
+		}
+		r = append(r, &x)
+	}
+	return r
+}
+
+// compressedFile returns true if the file begins with the magic bytes of the compression formats.
+// The offsets in the decompressed data cannot be resumed, so they are not decompressed with -checkpoint.
+func compressedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false // reported by the grep
+	}
+	defer f.Close()
+	header := make([]byte, 4)
+	n, _ := io.ReadFull(f, header)
+	return gogrep.IsCompressed(header[:n])
+}
+
+// reportResult records the position if the result is a checkpoint, nil-safe.
+// Returns false if the result is not a checkpoint.
+func (s *checkpointer) reportResult(t *target, r gogrep.Result) bool {
+	if s == nil || !errors.Is(r.Err(), gogrep.ErrCheckpoint) {
+		return false
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if c, ok := s.state.Files[t.path]; ok {
+		c.Offset, c.Line = r.Offset(), r.Line()
+		printed := c.Printed[:0]
+		for _, line := range c.Printed {
+			if line > c.Line {
+				printed = append(printed, line)
+			}
+		}
+		c.Printed = printed
+	}
+	if clock.Now().Sub(s.written) >= checkpointInterval {
+		s.write()
+	}
+	return true
+}
+
+// seen records the line of the result to be printed, nil-safe.
+// Returns true if the matches of the line are printed already before -resume.
+func (s *checkpointer) seen(t *target, r gogrep.Result) bool {
+	if s == nil || r.Err() != nil {
+		return false
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c, ok := s.state.Files[t.path]
+	if !ok {
+		return false
+	}
+	line := r.Line()
+	if s.printed[t.path][line] {
+		return true
+	}
+	if n := len(c.Printed); n == 0 || c.Printed[n-1] != line {
+		c.Printed = append(c.Printed, line)
+	}
+	return false
+}
+
+// done records the target done unless failed, nil-safe.
+func (s *checkpointer) done(t *target, failed bool) {
+	if s == nil || failed {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if c, ok := s.state.Files[t.path]; ok {
+		c.Done = true
+		c.Printed = nil
+	}
+}
+
+// write writes the state after the matches printed before it.
+func (s *checkpointer) write() {
+	s.written = clock.Now()
+	if err := s.writeState(); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *checkpointer) writeState() error {
+	if w, ok := stdout.(*blockWriter); ok {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	b, err := json.Marshal(&s.state)
+	if err != nil {
+		return err
+	}
+	// Replace the file at once not to leave a broken state on a crash
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// close writes the last state, nil-safe.
+func (s *checkpointer) close() error {
+	if s == nil {
+		return nil
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.write()
+	return s.err
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointPrinted(t *testing.T) {
+	const lines = 100000
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "file")
+		b    strings.Builder
+	)
+	for i := 1; i <= lines; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	if !assert.Nil(t, os.WriteFile(path, []byte(b.String()), 0o600)) {
+		return
+	}
+	s, err := openCheckpoint(filepath.Join(dir, "state"), false)
+	if !assert.Nil(t, err) {
+		return
+	}
+	targets := s.targets([]*target{{path: path}})
+	if !assert.Equal(t, 1, len(targets)) {
+		return
+	}
+	x := targets[0]
+	resultC, err := gogrep.New(append(x.options, gogrep.WithThreads(4))...).GrepFile(context.TODO(), "line", path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var (
+		printed    int
+		maxPrinted int
+	)
+	for r := range resultC {
+		if s.reportResult(x, r) || s.seen(x, r) {
+			continue
+		}
+		printed++
+		maxPrinted = max(maxPrinted, len(s.state.Files[path].Printed))
+	}
+	assert.Equal(t, lines, printed)
+	assert.Less(t, maxPrinted, lines/10, "the printed lines are forgotten by the checkpoints")
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+var checksumAlgorithm = commandLine.String("checksum", "", "Report the digest of the content of every file scanned by the algorithm, sha256 or sha512, to prove which content was searched. The digests are printed to stderr like sha256sum, or as {\"checksum\":{...}} lines after the matches of the files with -format json. The files are read to the end even if the grep of them stops early.")
+
+// checksumAlgorithms are the algorithms of -checksum.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func checkChecksum(algorithm string) error {
+	if _, ok := checksumAlgorithms[algorithm]; algorithm != "" && !ok {
+		return fmt.Errorf("unknown checksum %s", algorithm)
+	}
+	return nil
+}
+
+// contentChecksum is the digest of the content of a target as read by the grep, before the decompression.
+type contentChecksum struct {
+	hash hash.Hash
+	done bool  // read to the end
+	err  error // the error of reading, no digest if not nil
+}
+
+// newContentChecksum returns the checksum by -checksum, nil without -checksum.
+func newContentChecksum() *contentChecksum {
+	newHash, ok := checksumAlgorithms[*checksumAlgorithm]
+	if !ok {
+		return nil
+	}
+	return &contentChecksum{hash: newHash()}
+}
+
+// wrap is the gogrep.ReaderMiddleware that computes the digest of the content.
+func (s *contentChecksum) wrap(r io.Reader) io.Reader {
+	return &checksumReader{
+		r:   r,
+		sum: s,
+	}
+}
+
+// checksumReader writes the bytes read into the hash.
+type checksumReader struct {
+	r   io.Reader
+	sum *contentChecksum
+}
+
+func (s *checksumReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.sum.hash.Write(p[:n])
+	switch {
+	case err == io.EOF:
+		s.sum.done = true
+	case err != nil:
+		s.sum.err = err
+	}
+	return n, err
+}
+
+// Close reads the rest of the content that the grep did not read, e.g. with -l.
+func (s *checksumReader) Close() error {
+	if s.sum.done || s.sum.err != nil {
+		return nil
+	}
+	if _, err := io.Copy(s.sum.hash, s.r); err != nil {
+		s.sum.err = err
+		return nil
+	}
+	s.sum.done = true
+	return nil
+}
+
+// jsonChecksumRecord is the line of the digest of a file in -format json.
+type jsonChecksumRecord struct {
+	Checksum struct {
+		File      string `json:"file"`
+		Algorithm string `json:"algorithm"`
+		Digest    string `json:"digest"`
+	} `json:"checksum"`
+}
+
+// report prints the digest of the target if the content is read to the end.
+func (s *contentChecksum) report(t *target) error {
+	if s == nil || !s.done || s.err != nil {
+		return nil
+	}
+	return reportChecksum(t, hex.EncodeToString(s.hash.Sum(nil)))
+}
+
+// reportChecksum prints the digest of the target by -checksum.
+func reportChecksum(t *target, digest string) error {
+	if _, ok := matchFormatter.(*jsonFormatter); !ok {
+		_, err := fmt.Fprintf(os.Stderr, "%s  %s\n", digest, t.name())
+		return err
+	}
+	var x jsonChecksumRecord
+	x.Checksum.File = t.name()
+	x.Checksum.Algorithm = *checksumAlgorithm
+	x.Checksum.Digest = digest
+	b, err := json.Marshal(&x)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "%s\n", b)
+	return err
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("failed") }
+
+func TestContentChecksum(t *testing.T) {
+	const (
+		content = "alpha\nbeta\n"
+		digest  = "e49c81e2d2f84e259d40e2fb8192f3bcd198b355184845d76d8f58807d0d78ee"
+	)
+	*checksumAlgorithm = "sha256"
+	defer func() { *checksumAlgorithm = "" }()
+
+	t.Run("read to the end", func(t *testing.T) {
+		sum := newContentChecksum()
+		r := sum.wrap(strings.NewReader(content))
+		b, err := io.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, content, string(b))
+		assert.True(t, sum.done)
+		assert.Equal(t, digest, hexDigest(sum))
+	})
+
+	t.Run("read partially", func(t *testing.T) {
+		sum := newContentChecksum()
+		r := sum.wrap(strings.NewReader(content))
+		_, err := r.Read(make([]byte, 3))
+		assert.Nil(t, err)
+		assert.False(t, sum.done)
+		assert.Nil(t, r.(io.Closer).Close())
+		assert.True(t, sum.done)
+		assert.Equal(t, digest, hexDigest(sum))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		sum := newContentChecksum()
+		r := sum.wrap(failingReader{})
+		_, err := r.Read(make([]byte, 3))
+		assert.NotNil(t, err)
+		assert.Nil(t, r.(io.Closer).Close())
+		assert.False(t, sum.done)
+		assert.Nil(t, sum.report(&target{path: "failed"}), "not reported")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		*checksumAlgorithm = ""
+		defer func() { *checksumAlgorithm = "sha256" }()
+		assert.Nil(t, newContentChecksum())
+	})
+
+	assert.Nil(t, checkChecksum("sha512"))
+	assert.NotNil(t, checkChecksum("md5"))
+}
+
+func hexDigest(s *contentChecksum) string { return hex.EncodeToString(s.hash.Sum(nil)) }
@@ -0,0 +1,149 @@
+// Package cli is the command line interface of gogrep, the flags, the formatters and the exit status,
+// to embed gogrep as a subcommand of the other tools, e.g. mytool grep:
+//
+//	func main() {
+//		if len(os.Args) > 1 && os.Args[1] == "grep" {
+//			os.Exit(cli.Main(os.Args[2:]))
+//		}
+//		...
+//	}
+//
+// The flags are parsed by the own flag.FlagSet, not flag.CommandLine of the tool, built for each call of Main.
+// Main keeps the state of the run in the package, so do not call it concurrently.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// commandLine is the flags of gogrep, built by newCommandLine for each run of Main.
+// Invalid flags and -h make Main return 2 and 0 instead of exiting the process.
+var commandLine = flag.NewFlagSet("gogrep", flag.ContinueOnError)
+
+// flagDefinitions is the first commandLine where the package defines the flags.
+var flagDefinitions = commandLine
+
+// resettableFlag is the flag.Value whose Set accumulates the values, not reset by Set of the default.
+type resettableFlag interface {
+	flag.Value
+	reset()
+}
+
+// newCommandLine returns the flags of flagDefinitions not parsed yet, with the values reset to the defaults.
+func newCommandLine() *flag.FlagSet {
+	fs := flag.NewFlagSet(flagDefinitions.Name(), flag.ContinueOnError)
+	flagDefinitions.VisitAll(func(f *flag.Flag) {
+		if v, ok := f.Value.(resettableFlag); ok {
+			v.reset()
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = printUsage
+	return fs
+}
+
+// resetRun clears the state of the previous run of Main, before the subcommands too.
+func resetRun() {
+	commandLine = newCommandLine()
+	stdout = os.Stdout
+	matched, targetFailed = false, false
+	matchFormatter, wantRanges, wantSubmatches, printFileName = nil, false, false, false
+	matchBaseline, matchCodeowners, matchAggregate, matchTop, matchWhere, matchWatch, matchDB = nil, nil, nil, nil, nil, nil, nil
+	scanCheckpoint, skipReport, changedManifest, runInfo, runDiagnostics, targetIdentities = nil, nil, nil, nil, nil, nil
+	notInsideDelimiters, roots = nil, nil
+	procMode, imageRef = false, ""
+	grepStats = &statsSummary{}
+}
+
+// Summary is the outcome of Main passed to the hook of OnExit.
+type Summary struct {
+	// Status is the exit status returned by Main.
+	Status int
+	// Matched is true if any line is selected.
+	Matched bool
+	// Failed is true if any target got an error.
+	Failed bool
+	// Skipped is the number of the contents not searched, reported by -report-skipped or -strict.
+	Skipped int
+}
+
+// exitHook is called by Main if not nil.
+var exitHook func(Summary)
+
+// OnExit sets the hook called with the summary just before Main returns,
+// e.g. to record the metrics of the embedded grep or to print the summary in the style of the tool.
+// Nil removes the hook.
+func OnExit(hook func(Summary)) {
+	exitHook = hook
+}
+
+// Main runs gogrep by the arguments without the name of the command and returns the exit status.
+// The subcommands like engines are dispatched by the first argument.
+func Main(args []string) (status int) {
+	defer func() {
+		if exitHook != nil {
+			exitHook(Summary{
+				Status:  status,
+				Matched: matched,
+				Failed:  targetFailed,
+				Skipped: skipReport.skipped(),
+			})
+		}
+	}()
+	resetRun()
+	if len(args) > 0 {
+		if cmd, ok := subcommands[args[0]]; ok {
+			err := cmd(args[1:])
+			var s statusError
+			switch {
+			case err == nil:
+				return 0
+			case errors.As(err, &s):
+				return int(s)
+			case errors.Is(err, errResultsDiffer):
+				return exitNotMatched
+			}
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	_ = setupMessages() // by the environment for the errors of the flags
+	if err := parseFlags(args); err != nil {
+		var s statusError
+		if errors.As(err, &s) {
+			return int(s)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if err := setupMessages(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *printVersion {
+		if err := writeVersion(os.Stdout, false); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return 0
+	}
+	return runGrep(commandLine.Args())
+}
+
+// statusError ends a subcommand with the status without printing, e.g. gogrep image by runGrep.
+type statusError int
+
+func (s statusError) Error() string { return fmt.Sprintf("exit status %d", int(s)) }
+
+// grepStatus returns the error of the status of runGrep for the subcommands, nil for 0.
+func grepStatus(status int) error {
+	if status == 0 {
+		return nil
+	}
+	return statusError(status)
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnExit(t *testing.T) {
+	var got []Summary
+	OnExit(func(s Summary) { got = append(got, s) })
+	defer OnExit(nil)
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+	same := write("same", `{"file":"a","line":1,"offset":0,"text":"alpha","fingerprint":"x"}`+"\n")
+	other := write("other", "")
+
+	assert.Equal(t, 0, Main([]string{"diff-results", same, same}))
+	assert.Equal(t, 1, Main([]string{"diff-results", same, other}))
+	assert.Equal(t, []Summary{{Status: 0}, {Status: 1}}, got)
+
+	assert.Nil(t, grepStatus(0))
+	assert.Equal(t, statusError(exitNotMatched), grepStatus(exitNotMatched))
+}
+
+func TestMainTwice(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "input")
+	assert.Nil(t, os.WriteFile(path, []byte("alpha\nbeta\nalphabet\n"), 0600))
+	// run returns the stdout and the stderr of Main
+	run := func(t *testing.T, want int, args ...string) (string, string) {
+		t.Helper()
+		out, err := os.CreateTemp(dir, "stdout")
+		if !assert.Nil(t, err) {
+			return "", ""
+		}
+		defer out.Close()
+		errOut, err := os.CreateTemp(dir, "stderr")
+		if !assert.Nil(t, err) {
+			return "", ""
+		}
+		defer errOut.Close()
+		stdoutFile, stderrFile := os.Stdout, os.Stderr
+		os.Stdout, os.Stderr = out, errOut
+		status := Main(args)
+		os.Stdout, os.Stderr = stdoutFile, stderrFile
+		assert.Equal(t, want, status, "%v", args)
+		b, err := os.ReadFile(out.Name())
+		assert.Nil(t, err)
+		e, err := os.ReadFile(errOut.Name())
+		assert.Nil(t, err)
+		return string(b), string(e)
+	}
+
+	t.Run("flags", func(t *testing.T) {
+		out, _ := run(t, 0, "-no-config", "-c", "alpha", path)
+		assert.Equal(t, "2\n", out)
+		out, _ = run(t, 1, "-no-config", "gamma", path)
+		assert.Equal(t, "", out, "neither -c nor the match of the previous run is kept")
+		out, _ = run(t, 0, "-no-config", "-e", "beta", path)
+		assert.Equal(t, "beta\n", out)
+		out, _ = run(t, 0, "-no-config", "-e", "alphabet", path)
+		assert.Equal(t, "alphabet\n", out, "-e of the previous run is not kept")
+		run(t, exitError, "-no-config", "-no-such-flag", "alpha", path)
+		run(t, 0, "-no-config", "-h")
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, errOut := run(t, 0, "-no-config", "-stats", "alpha", path)
+			assert.Contains(t, errOut, "stats: files=1 lines=3 ", "the stats of the previous runs are not kept")
+		}
+	})
+
+	t.Run("after subcommand", func(t *testing.T) {
+		proc := filepath.Join(dir, "proc")
+		assert.Nil(t, os.MkdirAll(filepath.Join(proc, "1"), 0o755))
+		assert.Nil(t, os.WriteFile(filepath.Join(proc, "1", "cmdline"), []byte("alpha\x00"), 0o600))
+		procDirOrig := procDir
+		procDir = proc
+		defer func() { procDir = procDirOrig }()
+
+		out, _ := run(t, 0, "proc", "-no-config", "alpha")
+		assert.Contains(t, out, "alpha")
+		out, _ = run(t, 0, "-no-config", "alpha", path)
+		assert.Equal(t, "alpha\nalphabet\n", out, "a file is printed without the name after proc")
+		out, _ = run(t, 0, "proc", "-no-config", "-c", "alpha")
+		out2, _ := run(t, 0, "proc", "-no-config", "alpha")
+		assert.NotEqual(t, out, out2, "-c of the previous subcommand is not kept")
+	})
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type codeownersRule struct {
+	pattern *pathPattern
+	owners  []string
+}
+
+// codeowners maps files to the owners by CODEOWNERS.
+// The paths are matched relative to the current directory.
+type codeowners struct {
+	rules []*codeownersRule
+	count map[string]int // the number of matches per owner for -group-by-owner
+}
+
+func loadCodeowners(file string) (*codeowners, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := &codeowners{
+		count: map[string]int{},
+	}
+	sc := bufio.NewScanner(f)
+	for i := 1; sc.Scan(); i++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		p, err := compilePathPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, i, err)
+		}
+		var owners []string
+		for _, x := range fields[1:] {
+			if strings.HasPrefix(x, "#") {
+				break
+			}
+			owners = append(owners, x)
+		}
+		c.rules = append(c.rules, &codeownersRule{
+			pattern: p,
+			owners:  owners,
+		})
+	}
+	return c, sc.Err()
+}
+
+// owners returns the owners of the file.
+// The last matching rule wins.
+func (s *codeowners) owners(file string) []string {
+	if file == "" {
+		return nil
+	}
+	name := filepath.ToSlash(relativePath(file))
+	for i := len(s.rules) - 1; i >= 0; i-- {
+		if r := s.rules[i]; r.pattern.matchPathOrParents(name) {
+			return r.owners
+		}
+	}
+	return nil
+}
+
+// relativePath returns the path relative to the current directory if possible.
+func relativePath(file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+	r, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return file
+	}
+	return r
+}
+
+const unowned = "(unowned)"
+
+func (s *codeowners) add(owners []string) {
+	if len(owners) == 0 {
+		s.count[unowned]++
+		return
+	}
+	for _, o := range owners {
+		s.count[o]++
+	}
+}
+
+// writeSummary writes the number of the matches per owner.
+func (s *codeowners) writeSummary(w io.Writer) error {
+	owners := make([]string, 0, len(s.count))
+	for o := range s.count {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "OWNER\tMATCHES")
+	for _, o := range owners {
+		fmt.Fprintf(tw, "%s\t%d\n", o, s.count[o])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,68 @@
+package cli
+
+import "sync"
+
+// collateBufferSize is the number of the matches of a file buffered by a collatedSource
+// before it holds the output until the end of the file.
+const collateBufferSize = 64
+
+// outputCollator serializes the matches of the sources emitted concurrently
+// so that the matches of a file are not interleaved with the others.
+type outputCollator struct {
+	mux sync.Mutex
+}
+
+// source returns the collated source to add the matches of a stream.
+func (c *outputCollator) source() *collatedSource {
+	return &collatedSource{
+		collator: c,
+	}
+}
+
+// collatedSource is a stream of the matches where the matches of a file are contiguous.
+// The small number of the matches of a file are buffered and emitted together at the end of the file,
+// and the rest of a file with more matches are emitted directly while the other sources wait.
+type collatedSource struct {
+	collator *outputCollator
+	file     string // of the buffered or emitting matches
+	buffered []*match
+	holding  bool // true if the collator is locked by the source
+}
+
+func (s *collatedSource) add(m *match) {
+	if (s.holding || len(s.buffered) > 0) && m.File != s.file {
+		s.flush()
+	}
+	s.file = m.File
+	if s.holding {
+		emitMatch(m)
+		return
+	}
+	s.buffered = append(s.buffered, m)
+	if len(s.buffered) >= collateBufferSize {
+		s.collator.mux.Lock()
+		s.holding = true
+		s.emitBuffered()
+	}
+}
+
+// flush emits the buffered matches and releases the collator.
+// It is called at the end of a file and the end of the source.
+func (s *collatedSource) flush() {
+	if !s.holding {
+		if len(s.buffered) == 0 {
+			return
+		}
+		s.collator.mux.Lock()
+	}
+	s.emitBuffered()
+	s.holding = false
+	s.collator.mux.Unlock()
+}
+
+func (s *collatedSource) emitBuffered() {
+	for _, m := range s.buffered {
+		emitMatch(m)
+	}
+	s.buffered = nil
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingFormatter records the matches instead of writing them.
+type recordingFormatter struct {
+	matches []*match
+}
+
+func (s *recordingFormatter) format(_ io.Writer, m *match) error {
+	s.matches = append(s.matches, m)
+	return nil
+}
+
+func TestOutputCollator(t *testing.T) {
+	f := &recordingFormatter{}
+	defer func(x formatter) { matchFormatter = x }(matchFormatter)
+	matchFormatter = f
+
+	const (
+		sources = 4
+		files   = 5
+	)
+	var (
+		collator outputCollator
+		wg       sync.WaitGroup
+	)
+	wg.Add(sources)
+	for i := 0; i < sources; i++ {
+		go func() {
+			defer wg.Done()
+			source := collator.source()
+			defer source.flush()
+			for j := 0; j < files; j++ {
+				// More than the buffer for some files
+				for k := 0; k < (j+1)*collateBufferSize/2; k++ {
+					source.add(&match{
+						File: fmt.Sprintf("%d/%d", i, j),
+						Line: k + 1,
+					})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var (
+		want  int
+		seen  = map[string]bool{}
+		last  string
+		lines int
+	)
+	for i := 0; i < files; i++ {
+		want += sources * (i + 1) * collateBufferSize / 2
+	}
+	assert.Equal(t, want, len(f.matches))
+	for _, m := range f.matches {
+		if m.File != last {
+			assert.False(t, seen[m.File], "%s is interleaved", m.File)
+			seen[m.File] = true
+			last = m.File
+			lines = 0
+		}
+		lines++
+		assert.Equal(t, lines, m.Line, "%s is not in order", m.File)
+	}
+	assert.Equal(t, sources*files, len(seen))
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SGR sequences like GNU grep.
+const (
+	colorMatch     = "\x1b[01;31m"
+	colorFile      = "\x1b[35m"
+	colorLine      = "\x1b[32m"
+	colorSeparator = "\x1b[36m"
+	colorReset     = "\x1b[m"
+)
+
+// useColor decides whether to colorize the output by -color.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		return isTerminal(os.Stdout), nil
+	default:
+		return false, fmt.Errorf("unknown color mode %s", mode)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func colorize(color, s string) string { return color + s + colorReset }
+
+// highlight colorizes the ranges of the text.
+func highlight(text string, ranges [][2]int) string {
+	var (
+		b    strings.Builder
+		prev int
+	)
+	for _, r := range ranges {
+		if r[0] < prev || r[1] > len(text) || r[0] == r[1] {
+			continue
+		}
+		b.WriteString(text[prev:r[0]])
+		b.WriteString(colorize(colorMatch, text[r[0]:r[1]]))
+		prev = r[1]
+	}
+	b.WriteString(text[prev:])
+	return b.String()
+}
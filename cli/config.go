@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// configPathEnv is the environment variable of the path of the config file.
+	configPathEnv = "GOGREP_CONFIG_PATH"
+	// optsEnv is the environment variable of the default flags separated by spaces.
+	optsEnv = "GOGREP_OPTS"
+)
+
+// parseFlags parses the default flags from the config file and GOGREP_OPTS and then the args,
+// so that the flags of the args override the defaults.
+// The flags that can be specified multiple times like -exclude-dir accumulate the defaults and the args.
+func parseFlags(args []string) error {
+	defaults, err := defaultArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(defaults) > 0 {
+		if err := parseCommandLine(defaults); err != nil {
+			return err
+		}
+		if commandLine.NArg() > 0 {
+			return fmt.Errorf("the default flags got the arguments %q", commandLine.Args())
+		}
+	}
+	return parseCommandLine(args)
+}
+
+// parseCommandLine parses the args by commandLine.
+func parseCommandLine(args []string) error {
+	return parseFlagSet(commandLine, args)
+}
+
+// parseFlagSet parses the args by the flags of the command line or a subcommand.
+// Returns statusError as the flags print the invalid flag and the usage, 0 for -h.
+func parseFlagSet(fs *flag.FlagSet, args []string) error {
+	switch err := fs.Parse(args); {
+	case err == nil:
+		return nil
+	case errors.Is(err, flag.ErrHelp):
+		return statusError(0)
+	default:
+		return statusError(exitError)
+	}
+}
+
+// defaultArgs returns the flags of the config file and then GOGREP_OPTS,
+// or nil if the args contain -no-config.
+func defaultArgs(args []string) ([]string, error) {
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		switch strings.TrimLeft(a, "-") {
+		case "no-config", "no-config=true":
+			return nil, nil
+		}
+	}
+	xs, err := readConfig(configPath())
+	if err != nil {
+		return nil, err
+	}
+	return append(xs, strings.Fields(os.Getenv(optsEnv))...), nil
+}
+
+// configPath returns GOGREP_CONFIG_PATH, or gogrep/config under XDG_CONFIG_HOME or ~/.config.
+// Returns empty if the home directory is unknown.
+func configPath() string {
+	if x := os.Getenv(configPathEnv); x != "" {
+		return x
+	}
+	if x := os.Getenv("XDG_CONFIG_HOME"); x != "" {
+		return filepath.Join(x, "gogrep", "config")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gogrep", "config")
+}
+
+// readConfig returns the flags of the config file, or nil if the file does not exist.
+// Each line is a flag like -j 16, -j=16 or --color always, and the empty lines and the lines beginning with # are ignored.
+func readConfig(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config: %w", err)
+	}
+	var (
+		args []string
+		sc   = bufio.NewScanner(bytes.NewReader(data))
+	)
+	for i := 1; sc.Scan(); i++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			return nil, fmt.Errorf("%s:%d: want a flag but got %s", path, i, line)
+		}
+		name, value, ok := strings.Cut(line, " ")
+		if ok && !strings.Contains(name, "=") {
+			args = append(args, name, strings.TrimSpace(value))
+			continue
+		}
+		args = append(args, line)
+	}
+	return args, sc.Err()
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/berquerant/gogrep"
+)
+
+var diagnosticsDir = commandLine.String("diagnostics", "", "Write the diagnostics bundle for the bug reports into a new directory under the directory when the run fails or panics: the stack traces of the panics and the goroutines, the errors, the options and the stats.")
+
+// runDiagnostics collects the diagnostics of the run, nil without -diagnostics.
+var runDiagnostics *diagnostics
+
+// diagnostics are the failures of the run to be written as a bundle.
+type diagnostics struct {
+	start  time.Time
+	mux    sync.Mutex
+	errors []string
+	panics []*gogrep.PanicError
+}
+
+func newDiagnostics() *diagnostics {
+	return &diagnostics{
+		start: clock.Now(),
+	}
+}
+
+// record adds the error of the target, or of the run if name is empty.
+func (s *diagnostics) record(name string, err error) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if name != "" {
+		s.errors = append(s.errors, fmt.Sprintf("%s: %v", name, err))
+	} else {
+		s.errors = append(s.errors, err.Error())
+	}
+	var p *gogrep.PanicError
+	if errors.As(err, &p) {
+		s.panics = append(s.panics, p)
+	}
+}
+
+// diagnosticsOptions are the options of the run in the bundle.
+type diagnosticsOptions struct {
+	Version string            `json:"version"`
+	Go      string            `json:"go"`
+	OS      string            `json:"os"`
+	Arch    string            `json:"arch"`
+	CPUs    int               `json:"cpus"`
+	Args    []string          `json:"args"`
+	Options map[string]string `json:"options"`
+	Start   time.Time         `json:"start"`
+	End     time.Time         `json:"end"`
+}
+
+// write writes the bundle into a new directory under dir and returns the directory.
+// recovered is the panic of the run and stack is its stack trace, nil if the run did not panic.
+func (s *diagnostics) write(dir string, recovered interface{}, stack []byte) (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	end := clock.Now()
+	bundle := filepath.Join(dir, fmt.Sprintf("gogrep-%s-%d", end.UTC().Format("20060102T150405"), os.Getpid()))
+	if err := os.MkdirAll(bundle, 0o755); err != nil {
+		return "", err
+	}
+
+	var stacks bytes.Buffer
+	if recovered != nil {
+		fmt.Fprintf(&stacks, "panic: %v\n\n%s\n", recovered, stack)
+	}
+	for _, p := range s.panics {
+		fmt.Fprintf(&stacks, "recovered: %v\n\n%s\n", p.Value, p.Stack)
+	}
+	stacks.WriteString("goroutines:\n\n")
+	stacks.Write(allStacks())
+
+	options := diagnosticsOptions{
+		Version: "(unknown)",
+		Go:      runtime.Version(),
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		CPUs:    runtime.NumCPU(),
+		Args:    os.Args,
+		Options: map[string]string{},
+		Start:   s.start.UTC(),
+		End:     end.UTC(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		options.Version = info.Main.Version
+	}
+	commandLine.Visit(func(f *flag.Flag) {
+		options.Options[f.Name] = f.Value.String()
+	})
+	optionsJSON, err := json.MarshalIndent(options, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	var stats bytes.Buffer
+	grepStats.print(&stats, end.Sub(s.start))
+
+	var errs bytes.Buffer
+	for _, x := range s.errors {
+		errs.WriteString(x + "\n")
+	}
+
+	for name, data := range map[string][]byte{
+		"stacks.txt":   stacks.Bytes(),
+		"options.json": append(optionsJSON, '\n'),
+		"stats.txt":    stats.Bytes(),
+		"errors.txt":   errs.Bytes(),
+	} {
+		if err := os.WriteFile(filepath.Join(bundle, name), data, 0o644); err != nil {
+			return "", err
+		}
+	}
+	return bundle, nil
+}
+
+// allStacks returns the stack traces of all the goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// dumpDiagnostics writes the bundle by -diagnostics if the run failed or panicked.
+// Should be deferred with the exit status and the recovered panic of the run,
+// and panics again after writing the bundle of the panic.
+func dumpDiagnostics(status int, x interface{}) {
+	if x == nil && status != exitError && !targetFailed {
+		return
+	}
+	var stack []byte
+	if x != nil {
+		stack = debug.Stack()
+	}
+	if dir, err := runDiagnostics.write(*diagnosticsDir, x, stack); err != nil {
+		msg.Fprintf(os.Stderr, "gogrep: cannot write diagnostics: %v\n", err)
+	} else {
+		msg.Fprintf(os.Stderr, "gogrep: diagnostics written to %s\n", dir)
+	}
+	if x != nil {
+		panic(x)
+	}
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const genDocsUsage = `Usage of gogrep gen-docs
+  gogrep gen-docs man|markdown
+    Write the man page or the markdown reference of the flags to stdout.`
+
+// flagSection is a section of the flags in the documents.
+type flagSection struct {
+	title string
+	flags []string
+}
+
+// flagSections are the sections of the flags of gogrep in order.
+// Every flag belongs to a section, tested to keep the documents in sync with the flags.
+var flagSections = []flagSection{
+	{
+		title: "Patterns",
+		flags: []string{"e", "f", "F", "glob", "P", "fuzzy", "i", "normalize", "engine", "explain", "prefilter", "go-ident", "U", "scope", "not-inside", "where"},
+	},
+	{
+		title: "Inputs",
+		flags: []string{
+			"root", "include", "exclude", "exclude-dir", "type", "type-not", "type-add", "hidden", "follow-symlinks",
+			"max-filesize", "max-depth", "min-depth", "respect-gitignore", "no-ignore", "files-from", "0", "search-archives", "stdin-format", "label",
+			"decompress", "binary-files", "encoding", "max-line-length", "long-lines", "z", "follow", "watch", "watch-debounce", "dedup", "changed-only",
+		},
+	},
+	{
+		title: "Selection",
+		flags: []string{"o", "group", "m", "unique", "unique-by", "unique-limit", "top", "score-by", "aggregate", "baseline", "update-baseline"},
+	},
+	{
+		title: "Output",
+		flags: []string{
+			"format", "output", "output-encoding", "n", "byte-offset", "heading", "sort", "color", "Z", "null", "c", "count-matches", "l", "L", "q", "line-buffered", "block-buffered",
+			"trim", "lower", "squeeze-space", "fingerprint", "run-metadata", "codeowners", "group-by-owner", "sqlite", "report-skipped", "checksum", "stats", "progress", "lang",
+		},
+	},
+	{
+		title: "Replacement",
+		flags: []string{"replace", "in-place", "transactional", "line-ending"},
+	},
+	{
+		title: "Performance",
+		flags: []string{"j", "b", "max-pending-lines", "batch", "readahead", "mmap", "direct", "fadvise", "remote", "cpuprofile", "memprofile", "trace"},
+	},
+	{
+		title: "Behavior",
+		flags: []string{"fail-on", "strict", "source-timeout", "checkpoint", "resume", "debug", "diagnostics", "no-config", "version"},
+	},
+}
+
+func runGenDocs(args []string) error {
+	if len(args) != 1 {
+		return errors.New(genDocsUsage)
+	}
+	switch args[0] {
+	case "man":
+		return writeManPage(os.Stdout, commandLine)
+	case "markdown":
+		return writeMarkdown(os.Stdout, commandLine)
+	default:
+		return errors.New(genDocsUsage)
+	}
+}
+
+// docFlag is a flag rendered in the documents.
+type docFlag struct {
+	name     string
+	value    string // the name of the value, empty for the bool flags
+	usage    string
+	defValue string // empty if the zero value
+}
+
+func newDocFlag(f *flag.Flag) *docFlag {
+	value, usage := flag.UnquoteUsage(f)
+	d := &docFlag{
+		name:  f.Name,
+		value: value,
+		usage: usage,
+	}
+	switch f.DefValue {
+	case "", "0", "false", "[]":
+	default:
+		d.defValue = f.DefValue
+	}
+	return d
+}
+
+// sectionFlags returns the flags of the sections defined in the flag set.
+func sectionFlags(fs *flag.FlagSet) [][]*docFlag {
+	r := make([][]*docFlag, len(flagSections))
+	for i, s := range flagSections {
+		for _, name := range s.flags {
+			if f := fs.Lookup(name); f != nil {
+				r[i] = append(r[i], newDocFlag(f))
+			}
+		}
+	}
+	return r
+}
+
+// writeManPage writes the man page in roff.
+func writeManPage(w io.Writer, fs *flag.FlagSet) error {
+	var b strings.Builder
+	b.WriteString(".TH GOGREP 1\n")
+	b.WriteString(".SH NAME\ngogrep \\- grep in parallel\n")
+	b.WriteString(".SH SYNOPSIS\n.nf\n")
+	for _, line := range strings.Split(usageSynopsis, "\n") {
+		b.WriteString(roffEscape(strings.TrimSpace(line)) + "\n")
+	}
+	b.WriteString(".fi\n")
+	b.WriteString(".SH DESCRIPTION\n")
+	for _, line := range strings.Split(usageNote, "\n") {
+		b.WriteString(roffEscape(line) + "\n")
+	}
+	b.WriteString(".SH OPTIONS\n")
+	for i, flags := range sectionFlags(fs) {
+		fmt.Fprintf(&b, ".SS %s\n", roffEscape(flagSections[i].title))
+		for _, f := range flags {
+			b.WriteString(".TP\n")
+			fmt.Fprintf(&b, ".B \\-%s", roffEscape(f.name))
+			if f.value != "" {
+				fmt.Fprintf(&b, " \\fI%s\\fR", roffEscape(f.value))
+			}
+			b.WriteString("\n" + roffEscape(f.usage))
+			if f.defValue != "" {
+				fmt.Fprintf(&b, " Default is %s.", roffEscape(f.defValue))
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// roffEscape escapes the backslashes, the hyphens and the control characters at the beginning of the line.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// writeMarkdown writes the reference of the flags in markdown.
+func writeMarkdown(w io.Writer, fs *flag.FlagSet) error {
+	var b strings.Builder
+	b.WriteString("# gogrep\n\n## Synopsis\n\n```\n")
+	b.WriteString(usageSynopsis + "\n```\n\n")
+	b.WriteString(usageNote + "\n\n## Flags\n")
+	for i, flags := range sectionFlags(fs) {
+		fmt.Fprintf(&b, "\n### %s\n\n", flagSections[i].title)
+		b.WriteString("| Flag | Default | Description |\n| --- | --- | --- |\n")
+		for _, f := range flags {
+			name := "-" + f.name
+			if f.value != "" {
+				name += " " + f.value
+			}
+			var def string
+			if f.defValue != "" {
+				def = "`" + f.defValue + "`"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", name, markdownCell(def), markdownCell(f.usage))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes the pipes and the newlines in a cell of a table.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
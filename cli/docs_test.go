@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlagSections(t *testing.T) {
+	sections := map[string]string{}
+	for _, s := range flagSections {
+		for _, name := range s.flags {
+			assert.NotNil(t, commandLine.Lookup(name), "-%s of %s is not defined", name, s.title)
+			if x, ok := sections[name]; ok {
+				t.Errorf("-%s is in %s and %s", name, x, s.title)
+			}
+			sections[name] = s.title
+		}
+	}
+	commandLine.VisitAll(func(f *flag.Flag) {
+		if _, ok := sections[f.Name]; !ok && !isTestFlag(f.Name) {
+			t.Errorf("-%s is in no sections", f.Name)
+		}
+	})
+}
+
+// isTestFlag reports whether the flag is registered by the testing package.
+func isTestFlag(name string) bool {
+	return strings.HasPrefix(name, "test.")
+}
+
+func TestGenDocs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("o", false, "Print only the matched parts of lines.")
+	fs.Int("j", 4, "The number of grep workers.")
+	fs.String("f", "", "Read patterns from the `file`.")
+
+	t.Run("man", func(t *testing.T) {
+		var b bytes.Buffer
+		assert.Nil(t, writeManPage(&b, fs))
+		got := b.String()
+		assert.Contains(t, got, ".SS Patterns\n.TP\n.B \\-f \\fIfile\\fR\nRead patterns from the file.\n")
+		assert.Contains(t, got, ".SS Selection\n.TP\n.B \\-o\nPrint only the matched parts of lines.\n")
+		assert.Contains(t, got, ".B \\-j \\fIint\\fR\nThe number of grep workers. Default is 4.\n")
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var b bytes.Buffer
+		assert.Nil(t, writeMarkdown(&b, fs))
+		got := b.String()
+		assert.Contains(t, got, "| `-f file` |  | Read patterns from the file. |\n")
+		assert.Contains(t, got, "| `-j int` | `4` | The number of grep workers. |\n")
+	})
+}
+
+func TestRoffEscape(t *testing.T) {
+	assert.Equal(t, `\&.x \- a\eb`, roffEscape(`.x - a\b`))
+}
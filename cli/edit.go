@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// editTransaction rewrites the files in place through the temporary files next to them.
+// With transactional, the files are replaced only after all the rewrites succeed by commit,
+// and restored if any replacement fails.
+// Otherwise each file is replaced as soon as it is rewritten.
+// The files can be rewritten concurrently.
+//
+// The rewrites keep the mode bits, the owner if permitted and the extended attributes including the SELinux context.
+// The files that cannot be replaced by rename, the hard links, the mount points
+// and the files in the unwritable directories, are truncated and overwritten instead.
+type editTransaction struct {
+	transactional bool
+	mux           sync.Mutex
+	staged        []*stagedEdit
+}
+
+// stagedEdit is a rewrite of a file waiting for commit.
+type stagedEdit struct {
+	path    string
+	tmp     string
+	backup  string // the original file during commit
+	inPlace bool   // overwrite the file instead of rename
+}
+
+// rewrite writes the rewritten content of the file by fn to a temporary file.
+func (s *editTransaction) rewrite(path string, fn func(r io.Reader, w io.Writer) error) error {
+	e, err := stageEdit(path, fn)
+	if err != nil {
+		return err
+	}
+	if !s.transactional {
+		return e.install()
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.staged = append(s.staged, e)
+	return nil
+}
+
+const editTempPattern = ".gogrep-*"
+
+func stageEdit(path string, fn func(r io.Reader, w io.Writer) error) (*stagedEdit, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+	e := &stagedEdit{
+		path:    path,
+		inPlace: hardLinked(info),
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+editTempPattern)
+	if err != nil {
+		// The directory is not writable but the file may be
+		if tmp, err = os.CreateTemp("", filepath.Base(path)+editTempPattern); err != nil {
+			return nil, err
+		}
+		e.inPlace = true
+	}
+	e.tmp = tmp.Name()
+	fail := func(err error) (*stagedEdit, error) {
+		tmp.Close()
+		os.Remove(e.tmp)
+		return nil, err
+	}
+	if !e.inPlace {
+		if err := copyFileMetadata(src, tmp, info); err != nil {
+			return fail(fmt.Errorf("cannot keep the attributes of %s: %w", path, err))
+		}
+	}
+	if err := fn(src, tmp); err != nil {
+		return fail(fmt.Errorf("cannot rewrite %s: %w", path, err))
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(e.tmp)
+		return nil, err
+	}
+	return e, nil
+}
+
+// copyFileMetadata copies the mode bits, the owner and the extended attributes of src to dst.
+// The owner and the attributes that are not permitted or not supported are skipped.
+func copyFileMetadata(src, dst *os.File, info os.FileInfo) error {
+	// Changing the owner clears the setuid and setgid bits
+	if err := copyFileAttrs(src, dst, info); err != nil {
+		return err
+	}
+	return dst.Chmod(info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky))
+}
+
+// commit replaces the files by the staged rewrites.
+// If a replacement fails, the replaced files are restored.
+func (s *editTransaction) commit() error {
+	defer func() { s.staged = nil }()
+	var done []*stagedEdit
+	for _, e := range s.staged {
+		if err := e.replace(); err != nil {
+			for _, d := range done {
+				d.restore()
+			}
+			s.removeTemps()
+			return fmt.Errorf("cannot replace %s, restored all the files: %w", e.path, err)
+		}
+		done = append(done, e)
+	}
+	var errs []error
+	for _, e := range done {
+		if err := os.Remove(e.backup); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollback discards the staged rewrites.
+func (s *editTransaction) rollback() {
+	s.removeTemps()
+	s.staged = nil
+}
+
+func (s *editTransaction) removeTemps() {
+	for _, e := range s.staged {
+		os.Remove(e.tmp) // already renamed if replaced
+	}
+}
+
+// install replaces the file by the rewrite without the backup.
+func (s *stagedEdit) install() error {
+	if !s.inPlace {
+		err := os.Rename(s.tmp, s.path)
+		if !isCrossDevice(err) {
+			return err
+		}
+		s.inPlace = true
+	}
+	defer os.Remove(s.tmp)
+	return overwriteFile(s.path, s.tmp)
+}
+
+// replace moves the original file to the backup and the rewrite to the file.
+// With inPlace, copies them instead.
+func (s *stagedEdit) replace() error {
+	if !s.inPlace {
+		err := s.rename()
+		if !isCrossDevice(err) {
+			return err
+		}
+		s.inPlace = true
+	}
+	backup, err := os.CreateTemp("", filepath.Base(s.path)+editTempPattern)
+	if err != nil {
+		return err
+	}
+	backup.Close()
+	if err := overwriteFile(backup.Name(), s.path); err != nil {
+		os.Remove(backup.Name())
+		return err
+	}
+	s.backup = backup.Name()
+	if err := overwriteFile(s.path, s.tmp); err != nil {
+		s.restore()
+		return err
+	}
+	os.Remove(s.tmp)
+	return nil
+}
+
+func (s *stagedEdit) rename() error {
+	s.backup = s.tmp + ".orig"
+	if err := os.Rename(s.path, s.backup); err != nil {
+		s.backup = ""
+		return err
+	}
+	if err := os.Rename(s.tmp, s.path); err != nil {
+		s.restore()
+		return err
+	}
+	return nil
+}
+
+// restore moves the backup to the file.
+func (s *stagedEdit) restore() {
+	if s.backup == "" {
+		return
+	}
+	if s.inPlace {
+		if overwriteFile(s.path, s.backup) == nil {
+			os.Remove(s.backup)
+		}
+	} else {
+		os.Rename(s.backup, s.path)
+	}
+	s.backup = ""
+}
+
+// isCrossDevice returns true if the error of rename means that the file cannot be replaced by rename,
+// e.g. a bind-mounted file.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV) || errors.Is(err, syscall.EBUSY)
+}
+
+// overwriteFile truncates the file dst and writes the content of the file src to it.
+// dst keeps its inode, so the hard links and the attributes.
+func overwriteFile(dst, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditTransaction(t *testing.T) {
+	setup := func(t *testing.T) (string, []string) {
+		dir := t.TempDir()
+		var files []string
+		for _, name := range []string{"a", "b", "c"} {
+			p := filepath.Join(dir, name)
+			assert.Nil(t, os.WriteFile(p, []byte(name), 0o644))
+			files = append(files, p)
+		}
+		return dir, files
+	}
+	upper := func(r io.Reader, w io.Writer) error {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, strings.ToUpper(string(b)))
+		return err
+	}
+	read := func(files []string) []string {
+		r := make([]string, len(files))
+		for i, f := range files {
+			b, _ := os.ReadFile(f)
+			r[i] = string(b)
+		}
+		return r
+	}
+	entries := func(dir string) int {
+		xs, _ := os.ReadDir(dir)
+		return len(xs)
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		dir, files := setup(t)
+		tx := &editTransaction{transactional: true}
+		for _, f := range files {
+			assert.Nil(t, tx.rewrite(f, upper))
+		}
+		assert.Equal(t, []string{"a", "b", "c"}, read(files), "not replaced before commit")
+		assert.Nil(t, tx.commit())
+		assert.Equal(t, []string{"A", "B", "C"}, read(files))
+		assert.Equal(t, 3, entries(dir), "no temporary files")
+	})
+
+	t.Run("rollback on failure", func(t *testing.T) {
+		dir, files := setup(t)
+		tx := &editTransaction{transactional: true}
+		errFail := errors.New("fail")
+		assert.Nil(t, tx.rewrite(files[0], upper))
+		assert.ErrorIs(t, tx.rewrite(files[1], func(io.Reader, io.Writer) error { return errFail }), errFail)
+		tx.rollback()
+		assert.Equal(t, []string{"a", "b", "c"}, read(files))
+		assert.Equal(t, 3, entries(dir), "no temporary files")
+	})
+
+	t.Run("restore on replace failure", func(t *testing.T) {
+		dir, files := setup(t)
+		tx := &editTransaction{transactional: true}
+		for _, f := range files {
+			assert.Nil(t, tx.rewrite(f, upper))
+		}
+		assert.Nil(t, os.Remove(tx.staged[2].tmp)) // make the last replacement fail
+		assert.NotNil(t, tx.commit())
+		assert.Equal(t, []string{"a", "b", "c"}, read(files))
+		assert.Equal(t, 3, entries(dir), "no temporary files")
+	})
+
+	t.Run("not transactional", func(t *testing.T) {
+		_, files := setup(t)
+		tx := &editTransaction{}
+		assert.Nil(t, tx.rewrite(files[0], upper))
+		assert.Equal(t, []string{"A", "b", "c"}, read(files))
+	})
+
+	t.Run("keep mode", func(t *testing.T) {
+		_, files := setup(t)
+		assert.Nil(t, os.Chmod(files[0], 0o640))
+		assert.Nil(t, (&editTransaction{}).rewrite(files[0], upper))
+		info, err := os.Stat(files[0])
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	})
+
+	t.Run("hard link", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("the number of the links is available on linux")
+		}
+		dir, files := setup(t)
+		link := filepath.Join(dir, "link")
+		assert.Nil(t, os.Link(files[0], link))
+		tx := &editTransaction{transactional: true}
+		assert.Nil(t, tx.rewrite(files[0], upper))
+		assert.Nil(t, tx.commit())
+		assert.Equal(t, []string{"A", "A"}, read([]string{files[0], link}), "the link is kept")
+		assert.Equal(t, 4, entries(dir), "no temporary files")
+	})
+}
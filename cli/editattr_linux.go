@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// hardLinked returns true if the file has other hard links.
+func hardLinked(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Nlink > 1
+}
+
+// copyFileAttrs copies the owner and the extended attributes, including security.selinux, of src to dst.
+func copyFileAttrs(src, dst *os.File, info os.FileInfo) error {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := dst.Chown(int(st.Uid), int(st.Gid)); err != nil && !isNotPermitted(err) {
+			return err
+		}
+	}
+	names, err := listXattrs(src)
+	if err != nil {
+		if isNotPermitted(err) {
+			return nil
+		}
+		return err
+	}
+	for _, name := range names {
+		value, err := getXattr(src, name)
+		if err != nil {
+			if isNotPermitted(err) || errors.Is(err, unix.ENODATA) {
+				continue
+			}
+			return err
+		}
+		if err := unix.Fsetxattr(int(dst.Fd()), name, value, 0); err != nil && !isNotPermitted(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func listXattrs(f *os.File) ([]string, error) {
+	for {
+		n, err := unix.Flistxattr(int(f.Fd()), nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = unix.Flistxattr(int(f.Fd()), buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue // added meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		return strings.FieldsFunc(string(buf[:n]), func(r rune) bool { return r == 0 }), nil
+	}
+}
+
+func getXattr(f *os.File, name string) ([]byte, error) {
+	for {
+		n, err := unix.Fgetxattr(int(f.Fd()), name, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = unix.Fgetxattr(int(f.Fd()), name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// isNotPermitted returns true if the error means that the attribute cannot be kept,
+// e.g. an unprivileged user or a filesystem without extended attributes.
+func isNotPermitted(err error) bool {
+	return errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES) || errors.Is(err, unix.ENOTSUP)
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestEditTransactionXattr(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a")
+	assert.Nil(t, os.WriteFile(p, []byte("a"), 0o644))
+	if err := unix.Setxattr(p, "user.gogrep", []byte("kept"), 0); err != nil {
+		t.Skipf("extended attributes are not supported: %v", err)
+	}
+	assert.Nil(t, (&editTransaction{}).rewrite(p, func(r io.Reader, w io.Writer) error {
+		_, err := io.WriteString(w, "A")
+		return err
+	}))
+	buf := make([]byte, 16)
+	n, err := unix.Getxattr(p, "user.gogrep", buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "kept", string(buf[:n]))
+}
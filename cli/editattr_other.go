@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package cli
+
+import "os"
+
+// hardLinked returns false since the number of the links is not available.
+func hardLinked(_ os.FileInfo) bool { return false }
+
+// copyFileAttrs does nothing since only the mode bits are kept.
+func copyFileAttrs(_, _ *os.File, _ os.FileInfo) error { return nil }
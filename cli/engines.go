@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/berquerant/gogrep"
+)
+
+const enginesUsage = `Usage of gogrep engines
+  gogrep engines
+    List the available engines.
+  gogrep engines bench [-j N] [-b N] FILE REGEX
+    Run REGEX against FILE through each engine and report the throughput.
+    The row aho-corasick matches REGEX as a literal by the literal set of -f with -engine fixed.`
+
+// benchLiteralSet is the row of the bench matched by WithLiteralSet instead of an engine.
+const benchLiteralSet = "aho-corasick"
+
+func runEngines(args []string) error {
+	switch {
+	case len(args) == 0:
+		for _, e := range gogrep.Engines() {
+			fmt.Println(e)
+		}
+		return nil
+	case args[0] == "bench":
+		return runBenchEngines(args[1:])
+	default:
+		return errors.New(enginesUsage)
+	}
+}
+
+func runBenchEngines(args []string) error {
+	var (
+		fs      = flag.NewFlagSet("engines bench", flag.ContinueOnError)
+		threads = fs.Int("j", 0, "The number of grep workers. 0 means auto: the number of the CPUs, tuning the workers at runtime.")
+		bufSize = fs.Int("b", 1000, "The size of grep result buffer. Positive number is valid.")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), enginesUsage)
+		fs.PrintDefaults()
+	}
+	if err := parseFlagSet(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New(enginesUsage)
+	}
+	if *threads < 0 {
+		return fmt.Errorf("invalid -j %d", *threads)
+	}
+	if *bufSize < 1 {
+		return fmt.Errorf("invalid -b %d", *bufSize)
+	}
+	opt := []gogrep.Option{
+		gogrep.WithThreads(*threads),
+		gogrep.WithResultBufferSize(*bufSize),
+	}
+	if *threads == 0 {
+		opt = append(opt, gogrep.WithAutoTune())
+	}
+	return benchEngines(fs.Arg(0), fs.Arg(1), opt)
+}
+
+// benchEngines greps the content of the file by each engine and by the literal set.
+// The file is read into memory in advance so that the throughput excludes I/O.
+func benchEngines(file, regex string, options []gogrep.Option) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "ENGINE\tMATCHES\tDURATION\tMB/s")
+	bench := func(name string, grep func(ctx context.Context, source *bytes.Reader) (<-chan gogrep.Result, error)) error {
+		start := time.Now()
+		resultC, err := grep(context.Background(), bytes.NewReader(data))
+		if err != nil {
+			// The pattern may be invalid only for some engines
+			fmt.Fprintf(w, "%s\t-\t-\t%v\n", name, err)
+			return nil
+		}
+		var matches int
+		for r := range resultC {
+			if err := r.Err(); err != nil {
+				return err
+			}
+			matches++
+		}
+		elapsed := time.Since(start)
+		fmt.Fprintf(w, "%s\t%d\t%s\t%.2f\n", name, matches, elapsed, float64(len(data))/elapsed.Seconds()/1e6)
+		return nil
+	}
+	for _, e := range gogrep.Engines() {
+		g := gogrep.New(append(options[:len(options):len(options)], gogrep.WithEngine(e))...)
+		if err := bench(string(e), func(ctx context.Context, source *bytes.Reader) (<-chan gogrep.Result, error) {
+			return g.Grep(ctx, regex, source)
+		}); err != nil {
+			return err
+		}
+	}
+	g := gogrep.New(append(options[:len(options):len(options)], gogrep.WithLiteralSet([]string{regex}))...)
+	if err := bench(benchLiteralSet, func(ctx context.Context, source *bytes.Reader) (<-chan gogrep.Result, error) {
+		return g.GrepMulti(ctx, nil, source)
+	}); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+const capabilitiesUsage = `Usage of gogrep capabilities
+  gogrep capabilities
+    List the optional features compiled in.`
+
+// cliCapabilities are the optional features of the command on the platform.
+var cliCapabilities []string
+
+func runCapabilities(args []string) error {
+	if len(args) != 0 {
+		return errors.New(capabilitiesUsage)
+	}
+	r := append(gogrep.Capabilities(), cliCapabilities...)
+	sort.Strings(r)
+	for _, c := range r {
+		fmt.Println(c)
+	}
+	return nil
+}
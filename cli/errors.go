@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/berquerant/gogrep"
+)
+
+// resultError returns the error of a result without the name of the source, printed by the callers.
+func resultError(err error) error {
+	var e *gogrep.SourceError
+	if errors.As(err, &e) {
+		return e.Err
+	}
+	return err
+}
+
+// errorMessage returns the message of the error of a result of the file, FILE:LINE: ERROR if the line cannot be read,
+// or FILE: ERROR if the file cannot be opened.
+func errorMessage(name string, err error) string {
+	err = resultError(err)
+	var open *gogrep.SourceError // by lazySource
+	if errors.As(err, &open) {
+		return fmt.Sprintf("%s: %v", name, open.Err)
+	}
+	var e *gogrep.ScanError
+	if errors.As(err, &e) {
+		return fmt.Sprintf("%s:%d: %v", name, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", name, err)
+}
@@ -0,0 +1,51 @@
+package cli
+
+import "fmt"
+
+// The values of -fail-on.
+const (
+	// failOnGrep exits like grep.
+	failOnGrep = ""
+	// failOnError fails only on the errors.
+	failOnError = "error"
+	// failOnNoMatch fails only when nothing matches.
+	failOnNoMatch = "nomatch"
+	// failOnNever never fails once the flags and the patterns are valid.
+	failOnNever = "never"
+)
+
+func checkFailOn(failOn string) error {
+	switch failOn {
+	case failOnGrep, failOnError, failOnNoMatch, failOnNever:
+		return nil
+	default:
+		return fmt.Errorf("unknown fail-on %s", failOn)
+	}
+}
+
+// exitStatus returns the exit status of the grep by -fail-on.
+// failed is true if the grep or any target got an error.
+func exitStatus(failed, matched bool) int {
+	switch *failOn {
+	case failOnError:
+		if failed {
+			return exitError
+		}
+		return 0
+	case failOnNoMatch:
+		if !matched {
+			return exitNotMatched
+		}
+		return 0
+	case failOnNever:
+		return 0
+	default:
+		if failed {
+			return exitError
+		}
+		if !matched {
+			return exitNotMatched
+		}
+		return 0
+	}
+}
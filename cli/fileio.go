@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+)
+
+// Hints for reading the files by -fadvise.
+const (
+	// adviseSequential tells the kernel to read ahead aggressively.
+	adviseSequential = "sequential"
+	// adviseDontNeed drops the pages of the files from the page cache after reading them.
+	adviseDontNeed = "dontneed"
+)
+
+// dontNeedChunkSize is the interval in bytes to drop the pages while reading a file.
+const dontNeedChunkSize = 32 << 20
+
+func checkAdvice(advice string) error {
+	switch advice {
+	case "", adviseSequential, adviseDontNeed:
+		return nil
+	default:
+		return fmt.Errorf("unknown fadvise %s", advice)
+	}
+}
+
+// adviseOpen applies -fadvise to the file just opened.
+func adviseOpen(f *os.File) {
+	if *fadviseMode == adviseSequential {
+		fadvise(f, 0, 0, adviseSequential)
+	}
+}
+
+// closeFile closes the file applying -fadvise.
+func closeFile(f *os.File) error {
+	if *fadviseMode == adviseDontNeed {
+		fadvise(f, 0, 0, adviseDontNeed)
+	}
+	return f.Close()
+}
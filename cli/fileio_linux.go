@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	cliCapabilities = append(cliCapabilities, "fadvise", "direct")
+}
+
+// fadvise gives the advice about the range of the file to the kernel by posix_fadvise.
+// The length 0 means until the end of the file.
+func fadvise(f *os.File, offset, length int64, advice string) {
+	switch advice {
+	case adviseSequential:
+		_ = unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_SEQUENTIAL)
+	case adviseDontNeed:
+		_ = unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_DONTNEED)
+	}
+}
+
+const (
+	directAlignment  = 4096
+	directBufferSize = 1 << 20
+)
+
+// openDirect opens the file with O_DIRECT.
+// Fails if the filesystem does not support it.
+func openDirect(name string) (io.ReadCloser, error) {
+	f, err := os.OpenFile(name, os.O_RDONLY|unix.O_DIRECT, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &directReader{
+		f:   f,
+		buf: alignedBuffer(directBufferSize, directAlignment),
+	}, nil
+}
+
+// alignedBuffer returns a buffer whose address is aligned as O_DIRECT requires.
+func alignedBuffer(size, alignment int) []byte {
+	b := make([]byte, size+alignment)
+	shift := 0
+	if r := int(uintptr(unsafe.Pointer(&b[0])) & uintptr(alignment-1)); r != 0 {
+		shift = alignment - r
+	}
+	return b[shift : shift+size]
+}
+
+// directReader reads the file by the aligned blocks.
+type directReader struct {
+	f       *os.File
+	buf     []byte
+	pending []byte
+	err     error
+}
+
+func (s *directReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		n, err := s.f.Read(s.buf)
+		s.pending = s.buf[:n]
+		s.err = err
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *directReader) Close() error { return s.f.Close() }
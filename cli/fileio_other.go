@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package cli
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var errDirectUnsupported = errors.New("direct I/O is not supported")
+
+// fadvise does nothing since posix_fadvise is not available.
+func fadvise(_ *os.File, _, _ int64, _ string) {}
+
+// openDirect always fails since O_DIRECT is not available.
+func openDirect(_ string) (io.ReadCloser, error) { return nil, errDirectUnsupported }
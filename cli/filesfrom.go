@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// filesFromBatchSize is the max number of the files of -files-from grepped together.
+const filesFromBatchSize = 1024
+
+// grepFilesFrom greps the targets and then the files listed by -files-from.
+// The list is read in the background and the names read so far are grepped together,
+// so the grep starts before the end of the list, e.g. the output of find still running.
+func grepFilesFrom(ctx context.Context, patterns []string, targets []*target) error {
+	var r io.Reader = os.Stdin
+	if *filesFrom != stdinPath {
+		f, err := fileSystem.Open(*filesFrom)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		for _, t := range targets {
+			if t.path == "" {
+				return errors.New("-files-from - cannot read stdin as a file too")
+			}
+		}
+	}
+	grepBatch := grepSources
+	if *searchArchives {
+		grepBatch = grepTargetsWithArchives
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stop reading the list on return
+	var (
+		delimiter byte = '\n'
+		listErr   error
+		nameC     = make(chan string, filesFromBatchSize)
+	)
+	if *nulFileList {
+		delimiter = 0
+	}
+	go func() {
+		defer close(nameC)
+		listErr = readFileList(ctx, r, delimiter, nameC)
+	}()
+
+	if len(targets) > 0 {
+		if err := grepBatch(ctx, patterns, targets); err != nil {
+			return err
+		}
+	}
+	for {
+		batch := nextFileBatch(nameC)
+		if len(batch) == 0 {
+			break
+		}
+		if batch = changedManifest.filterTargets(targetIdentities.filterTargets(batch)); len(batch) == 0 {
+			continue
+		}
+		if err := grepBatch(ctx, patterns, batch); err != nil {
+			return err
+		}
+	}
+	return listErr
+}
+
+// readFileList sends the names in r separated by the delimiter until the end or cancel.
+// The empty names are skipped.
+func readFileList(ctx context.Context, r io.Reader, delimiter byte, nameC chan<- string) error {
+	br := bufio.NewReader(r)
+	for {
+		name, err := br.ReadString(delimiter)
+		name = strings.TrimSuffix(name, string(delimiter))
+		if delimiter == '\n' {
+			name = strings.TrimSuffix(name, "\r")
+		}
+		if name != "" {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case nameC <- name:
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// nextFileBatch waits for a name and returns the targets of it and the names already read,
+// up to filesFromBatchSize.
+// Returns nil at the end of the list.
+func nextFileBatch(nameC <-chan string) []*target {
+	name, ok := <-nameC
+	if !ok {
+		return nil
+	}
+	batch := []*target{{path: name}}
+	for len(batch) < filesFromBatchSize {
+		select {
+		case name, ok := <-nameC:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, &target{path: name})
+		default:
+			return batch
+		}
+	}
+	return batch
+}
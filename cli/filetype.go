@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileTypes are the globs of the base names of the file types of -type, extended by -type-add.
+var fileTypes = map[string][]string{
+	"c":         {"*.c", "*.h"},
+	"cpp":       {"*.cc", "*.cpp", "*.cxx", "*.hh", "*.hpp", "*.hxx"},
+	"cs":        {"*.cs"},
+	"css":       {"*.css", "*.scss", "*.sass", "*.less"},
+	"csv":       {"*.csv", "*.tsv"},
+	"docker":    {"Dockerfile", "*.dockerfile"},
+	"go":        {"*.go"},
+	"html":      {"*.htm", "*.html"},
+	"java":      {"*.java"},
+	"js":        {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"json":      {"*.json", "*.jsonl"},
+	"kotlin":    {"*.kt", "*.kts"},
+	"make":      {"Makefile", "makefile", "GNUmakefile", "*.mk"},
+	"md":        {"*.md", "*.markdown"},
+	"proto":     {"*.proto"},
+	"py":        {"*.py", "*.pyi"},
+	"rb":        {"*.rb", "Gemfile", "Rakefile"},
+	"rust":      {"*.rs"},
+	"sh":        {"*.sh", "*.bash", "*.zsh"},
+	"sql":       {"*.sql"},
+	"swift":     {"*.swift"},
+	"terraform": {"*.tf", "*.tfvars"},
+	"toml":      {"*.toml"},
+	"ts":        {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"txt":       {"*.txt"},
+	"xml":       {"*.xml", "*.xsd", "*.xsl"},
+	"yaml":      {"*.yaml", "*.yml"},
+}
+
+// addFileTypes adds the globs of -type-add NAME:GLOB[,GLOB...] to fileTypes.
+func addFileTypes(defs []string) error {
+	for _, def := range defs {
+		name, globs, ok := cut(def, ":")
+		if !ok || name == "" || globs == "" {
+			return fmt.Errorf("invalid type-add %q: not NAME:GLOB", def)
+		}
+		for _, g := range strings.Split(globs, ",") {
+			if _, err := filepath.Match(g, ""); err != nil {
+				return fmt.Errorf("invalid type-add %q: %w", def, err)
+			}
+			fileTypes[name] = append(fileTypes[name], g)
+		}
+	}
+	return nil
+}
+
+// fileTypeGlobs returns the globs of the types like go,md.
+func fileTypeGlobs(types []string) ([]string, error) {
+	var globs []string
+	for _, x := range types {
+		for _, name := range strings.Split(x, ",") {
+			g, ok := fileTypes[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown type %s, one of %s", name, strings.Join(fileTypeNames(), ","))
+			}
+			globs = append(globs, g...)
+		}
+	}
+	return globs, nil
+}
+
+func fileTypeNames() []string {
+	names := make([]string, 0, len(fileTypes))
+	for name := range fileTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
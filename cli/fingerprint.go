@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// computeFingerprint returns a stable hash of the match.
+// It depends only on the file path and the matched text, so it survives line shifts.
+func computeFingerprint(file, text string) string {
+	h := sha256.New()
+	h.Write([]byte(file))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// readFingerprints reads the output of -fingerprint.
+func readFingerprints(file string) ([]*match, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		records []*match
+		sc      = bufio.NewScanner(f)
+	)
+	for i := 1; sc.Scan(); i++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var r struct {
+			match
+			Run *runMetadata `json:"run"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, i, err)
+		}
+		if r.Run != nil {
+			continue // -run-metadata
+		}
+		if r.Fingerprint == "" {
+			r.Fingerprint = computeFingerprint(r.File, r.Text)
+		}
+		records = append(records, &r.match)
+	}
+	return records, sc.Err()
+}
+
+const diffResultsUsage = `Usage of gogrep diff-results
+  gogrep diff-results OLD NEW
+    Report the matches added (+) and removed (-) between the outputs of gogrep -fingerprint.
+    Exit status is 1 if there are differences and 2 if the outputs cannot be read.`
+
+// errResultsDiffer means the outputs of diff-results differ, the exit status 1 without a message
+// since the differences are printed already.
+var errResultsDiffer = errors.New("results differ")
+
+// runDiffResults ends with 2 on the errors of reading the outputs, not to be confused with the differences.
+func runDiffResults(args []string) error {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, diffResultsUsage)
+		return statusError(exitError)
+	}
+	oldRecords, err := readFingerprints(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return statusError(exitError)
+	}
+	newRecords, err := readFingerprints(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return statusError(exitError)
+	}
+	added, removed := diffFingerprints(oldRecords, newRecords)
+	for _, r := range removed {
+		fmt.Printf("- %s:%s\n", r.File, r.Text)
+	}
+	for _, r := range added {
+		fmt.Printf("+ %s:%s\n", r.File, r.Text)
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		return errResultsDiffer
+	}
+	return nil
+}
+
+// diffFingerprints returns the records only in newRecords and the records only in oldRecords, sorted by file and text.
+// Duplicated fingerprints are counted as the multiset.
+func diffFingerprints(oldRecords, newRecords []*match) (added, removed []*match) {
+	count := map[string]int{}
+	for _, r := range oldRecords {
+		count[r.Fingerprint]++
+	}
+	for _, r := range newRecords {
+		if count[r.Fingerprint] > 0 {
+			count[r.Fingerprint]--
+			continue
+		}
+		added = append(added, r)
+	}
+	count = map[string]int{}
+	for _, r := range newRecords {
+		count[r.Fingerprint]++
+	}
+	for _, r := range oldRecords {
+		if count[r.Fingerprint] > 0 {
+			count[r.Fingerprint]--
+			continue
+		}
+		removed = append(removed, r)
+	}
+	sortRecords(added)
+	sortRecords(removed)
+	return
+}
+
+func sortRecords(records []*match) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].File != records[j].File {
+			return records[i].File < records[j].File
+		}
+		return records[i].Text < records[j].Text
+	})
+}
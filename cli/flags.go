@@ -0,0 +1,27 @@
+package cli
+
+import "strings"
+
+// stringsFlag is a flag that can be specified multiple times.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+func (s *stringsFlag) reset() { *s = nil }
+
+// templateFlag is a string flag that can be set to the empty string.
+type templateFlag struct {
+	value string
+	set   bool
+}
+
+func (s *templateFlag) String() string { return s.value }
+func (s *templateFlag) Set(v string) error {
+	s.value = v
+	s.set = true
+	return nil
+}
+func (s *templateFlag) reset() { *s = templateFlag{} }
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/berquerant/gogrep"
+)
+
+// followResult is a result of a followed target.
+type followResult struct {
+	target *target
+	result gogrep.Result
+}
+
+// grepFollow greps the targets like tail -F and prints the new matches as they are appended,
+// until the context is canceled.
+// The matches of the targets are printed as they arrive.
+func grepFollow(ctx context.Context, patterns []string, targets []*target) error {
+	if len(targets) == 0 {
+		return errors.New("-follow requires files")
+	}
+	if !isHostFS() {
+		return errors.New("-follow cannot follow the files not on the host")
+	}
+	for _, t := range targets {
+		if t.path == "" {
+			return errors.New("-follow cannot follow stdin")
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		g, regexes = newPatternGrepper(patterns)
+		wg         sync.WaitGroup
+		resultC    = make(chan *followResult)
+	)
+	for _, t := range targets {
+		source := gogrep.FollowFile(ctx, t.path, gogrep.WithFollow(0), gogrep.WithClock(clock))
+		rc, err := g.GrepSources(ctx, regexes, []gogrep.NamedSource{{
+			Name:    t.path,
+			Reader:  source,
+			Options: append(sourceOptions(t.path), t.options...),
+		}})
+		if err != nil {
+			source.Close()
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range rc {
+				select {
+				case <-ctx.Done():
+				case resultC <- &followResult{target: t, result: r}:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(resultC)
+	}()
+	states := map[*target]*targetState{}
+	for x := range resultC {
+		s, ok := states[x.target]
+		if !ok {
+			s = &targetState{}
+			states[x.target] = s
+		}
+		if err := s.add(x.target, x.result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
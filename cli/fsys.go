@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/berquerant/gogrep"
+)
+
+var (
+	// fileSystem is the file system to read the roots and the files from.
+	// Replaced in tests by fstest.MapFS whose paths are slash-separated and relative.
+	fileSystem fs.FS = hostFS{}
+	// clock is the clock of the time-dependent features.
+	clock = gogrep.SystemClock
+)
+
+// hostFS is the file system of the host that accepts the paths of the os package.
+type hostFS struct{}
+
+func (hostFS) Open(name string) (fs.File, error)     { return os.Open(name) }
+func (hostFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+// isHostFS returns true if the files are read from the host.
+func isHostFS() bool {
+	_, ok := fileSystem.(hostFS)
+	return ok
+}
+
+// walkDir walks the file tree of fileSystem.
+func walkDir(root string, fn fs.WalkDirFunc) error {
+	if isHostFS() {
+		return filepath.WalkDir(root, fn)
+	}
+	return fs.WalkDir(fileSystem, root, fn)
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const genUsage = `Usage of gogrep gen
+  gogrep gen [flags]
+    Generate a deterministic synthetic corpus of lowercase words to reproduce the performance reports.
+    The lines of -match-rate contain the -match token that the other lines never contain,
+    e.g. gogrep gen -lines 10M -match-rate 0.01 -line-len 80..400 -seed 1 > corpus.txt && gogrep -j 8 MATCH corpus.txt
+Flags:`
+
+// genConfig is the corpus of gogrep gen.
+type genConfig struct {
+	lines     int64
+	matchRate float64
+	minLen    int
+	maxLen    int
+	seed      int64
+	match     string
+}
+
+func runGen(args []string) error {
+	var (
+		fs        = flag.NewFlagSet("gen", flag.ContinueOnError)
+		lines     = fs.String("lines", "1M", "The number of the lines with the optional suffix K, M or G of 1000s.")
+		matchRate = fs.Float64("match-rate", 0.01, "The ratio of the lines that contain the -match token, from 0 to 1.")
+		lineLen   = fs.String("line-len", "80..400", "The length of the lines in bytes, MIN..MAX or N.")
+		seed      = fs.Int64("seed", 1, "The seed of the random numbers. The same flags generate the same corpus.")
+		match     = fs.String("match", "MATCH", "The token in the matching lines. It should contain a character other than the lowercase letters and spaces.")
+		files     = fs.Int("files", 0, "Split the lines into the number of the files gen-NNNNN.txt under the -output directory instead of a file.")
+		output    = fs.String("output", "", "The file or the directory with -files to write into. Default is stdout.")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), genUsage)
+		fs.PrintDefaults()
+	}
+	if err := parseFlagSet(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New(genUsage)
+	}
+	n, err := parseCount(*lines)
+	if err != nil {
+		return fmt.Errorf("invalid -lines: %w", err)
+	}
+	minLen, maxLen, err := parseLengthRange(*lineLen)
+	if err != nil {
+		return fmt.Errorf("invalid -line-len: %w", err)
+	}
+	if *matchRate < 0 || *matchRate > 1 {
+		return errors.New("-match-rate should be from 0 to 1")
+	}
+	if strings.Trim(*match, "abcdefghijklmnopqrstuvwxyz ") == "" {
+		return errors.New("-match should contain a character other than the lowercase letters and spaces")
+	}
+	if len(*match) > minLen {
+		return errors.New("-match should not be longer than the lines")
+	}
+	c := &genConfig{
+		lines:     n,
+		matchRate: *matchRate,
+		minLen:    minLen,
+		maxLen:    maxLen,
+		seed:      *seed,
+		match:     *match,
+	}
+	r := rand.New(rand.NewSource(c.seed))
+	if *files > 0 {
+		if *output == "" {
+			return errors.New("-files requires -output")
+		}
+		return c.writeFiles(r, *output, *files)
+	}
+	if *output == "" {
+		return c.write(os.Stdout, r, c.lines)
+	}
+	return c.writeFile(*output, r, c.lines)
+}
+
+// writeFiles splits the lines into the files under the directory.
+// The concatenation of the files equals the corpus of a file by the same flags.
+func (c *genConfig) writeFiles(r *rand.Rand, dir string, files int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i := 0; i < files; i++ {
+		n := c.lines*int64(i+1)/int64(files) - c.lines*int64(i)/int64(files)
+		if err := c.writeFile(filepath.Join(dir, fmt.Sprintf("gen-%05d.txt", i)), r, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *genConfig) writeFile(name string, r *rand.Rand, n int64) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := c.write(f, r, n); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// write writes the n lines by the random numbers.
+func (c *genConfig) write(w io.Writer, r *rand.Rand, n int64) error {
+	var (
+		bw  = bufio.NewWriterSize(w, 1<<20)
+		buf []byte
+	)
+	for i := int64(0); i < n; i++ {
+		buf = c.line(buf[:0], r)
+		buf = append(buf, '\n')
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// line appends a line of the words to buf.
+func (c *genConfig) line(buf []byte, r *rand.Rand) []byte {
+	var (
+		size  = c.minLen + r.Intn(c.maxLen-c.minLen+1)
+		start = len(buf)
+	)
+	for len(buf)-start < size {
+		if len(buf) > start {
+			buf = append(buf, ' ')
+		}
+		for k := 2 + r.Intn(9); k > 0 && len(buf)-start < size; k-- {
+			buf = append(buf, byte('a'+r.Intn(26)))
+		}
+	}
+	if buf[len(buf)-1] == ' ' {
+		buf[len(buf)-1] = byte('a' + r.Intn(26))
+	}
+	if r.Float64() < c.matchRate {
+		at := start + r.Intn(size-len(c.match)+1)
+		copy(buf[at:], c.match)
+	}
+	return buf
+}
+
+// parseCount parses a number with the optional suffix K, M or G of 1000s, e.g. 10M.
+func parseCount(s string) (int64, error) {
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit = 1e3
+	case strings.HasSuffix(s, "M"):
+		unit = 1e6
+	case strings.HasSuffix(s, "G"):
+		unit = 1e9
+	}
+	if unit > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errors.New("negative count")
+	}
+	return n * unit, nil
+}
+
+// parseLengthRange parses MIN..MAX or N.
+func parseLengthRange(s string) (int, int, error) {
+	lo, hi, ok := strings.Cut(s, "..")
+	if !ok {
+		hi = lo
+	}
+	minLen, err := strconv.Atoi(lo)
+	if err != nil {
+		return 0, 0, err
+	}
+	maxLen, err := strconv.Atoi(hi)
+	if err != nil {
+		return 0, 0, err
+	}
+	if minLen < 1 || maxLen < minLen {
+		return 0, 0, fmt.Errorf("%s is not a range of positive lengths", s)
+	}
+	return minLen, maxLen, nil
+}
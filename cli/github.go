@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// githubFormatter writes the matches as the warning commands of GitHub Actions
+// to annotate the lines of the pull requests.
+type githubFormatter struct {
+	patterns []string
+	regexes  []*regexp.Regexp // nil if the patterns cannot be compiled by regexp
+}
+
+func newGithubFormatter(patterns []string) *githubFormatter {
+	regexes, _ := compilePatterns(patterns)
+	return &githubFormatter{
+		patterns: patterns,
+		regexes:  regexes,
+	}
+}
+
+func (s *githubFormatter) format(w io.Writer, m *match) error {
+	var props []string
+	if m.File != "" {
+		props = append(props, "file="+escapeGithubProperty(m.File))
+	}
+	if !m.Binary {
+		props = append(props, fmt.Sprintf("line=%d", m.Line))
+		// The ranges of -o are in the matches, not in the lines
+		if len(m.ranges) > 0 && !*onlyMatching && *group < 0 {
+			props = append(props, fmt.Sprintf("col=%d", utf8.RuneCountInString(m.Text[:m.ranges[0][0]])+1))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("::warning")
+	if len(props) > 0 {
+		b.WriteString(" " + strings.Join(props, ","))
+	}
+	b.WriteString("::" + escapeGithubData("matched "+s.pattern(m.Text)) + "\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// pattern returns the first pattern that matches the text, or all the patterns if unknown.
+func (s *githubFormatter) pattern(text string) string {
+	for i, re := range s.regexes {
+		if re.MatchString(text) {
+			return s.patterns[i]
+		}
+	}
+	return strings.Join(s.patterns, " | ")
+}
+
+// escapeGithubData escapes the message of a workflow command.
+func escapeGithubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGithubProperty escapes the value of a property of a workflow command.
+func escapeGithubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,1069 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/berquerant/gogrep"
+)
+
+// usageSynopsis are the forms of the command, also rendered by gogrep gen-docs.
+const usageSynopsis = `  cat file | gogrep [flags] REGEX
+  gogrep [flags] REGEX files...
+  gogrep [flags] -e REGEX [-e REGEX...] [files...]
+  gogrep [flags] -f PATTERN_FILE [files...]
+  gogrep [flags] -root DIR[:OPTS] [-root DIR[:OPTS]...] REGEX [files...]
+  gogrep [flags] -replace TEMPLATE [-in-place] REGEX [files...]
+  gogrep -go-ident NAME [files...]
+  find . -print0 | gogrep [flags] -0 -files-from - REGEX
+  gogrep engines [bench FILE REGEX]
+  gogrep capabilities
+  gogrep image [flags] IMAGE REGEX
+  gogrep proc [flags] REGEX
+  gogrep diff-results OLD NEW
+  gogrep worker < REQUEST
+  gogrep serve [-listen ADDR] [-allow DIR...]
+  gogrep version [-verbose]
+  gogrep gen-docs man|markdown`
+
+// usageNote is the note of the usage, also rendered by gogrep gen-docs.
+const usageNote = `The default flags are read from the config file $GOGREP_CONFIG_PATH or ~/.config/gogrep/config, one flag per line,
+and then $GOGREP_OPTS, and the flags of the command line override them. -no-config ignores them.
+The matched lines are not guaranteed to be in order in which they appear in the input.
+Exit status is 0 if any line is selected, 1 if no lines were selected and 2 if an error occurred.`
+
+const usage = "Usage of gogrep\n" + usageSynopsis + "\n\nNote:\n" + usageNote + "\nFlags:"
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, translateText(usage))
+	commandLine.VisitAll(func(f *flag.Flag) {
+		f.Usage = translateText(f.Usage)
+	})
+	commandLine.PrintDefaults()
+}
+
+var (
+	threads           = commandLine.Int("j", 0, "The number of grep workers, that also grep the ranges of each large regular file in parallel. 0 means auto: the number of the CPUs, tuning the workers and the lines dispatched to them at runtime.")
+	resultBufferSize  = commandLine.Int("b", 1000, "The size of grep result buffer. Positive number is valid.")
+	maxPendingLines   = commandLine.Int("max-pending-lines", 0, "Bound the lines read and not matched yet to N and unbuffer the results instead of -b, so a slow output pauses the reading, e.g. to grep a huge pipe in a constant memory. Not positive number means the buffers by -j and -b.")
+	engine            = commandLine.String("engine", string(gogrep.EngineAuto), "The matcher implementation. See gogrep engines.")
+	fixedStrings      = commandLine.Bool("F", false, "Interpret the patterns as the fixed strings like -engine fixed. The patterns of -f are matched at once by an Aho-Corasick automaton, e.g. a list of thousands of keywords.")
+	perlRegexp        = commandLine.Bool("P", false, "Interpret the patterns as the Perl compatible regexes with the backreferences like (\\w+) \\1 and the lookarounds like foo(?!bar), matched by backtracking instead of RE2. Available with -tags pcre.")
+	globPatterns      = commandLine.Bool("glob", false, "Interpret the patterns as the shell globs matching the whole lines like -engine glob, e.g. '*error*[0-9]' for the lines containing error and ending with a digit. * matches any string, ? any character and [a-z] or [!a-z] a character in or not in the set.")
+	fuzzy             = commandLine.Int("fuzzy", -1, "Match the lines containing the patterns as the literals within the edit distance of N insertions, deletions and substitutions of the characters like agrep, e.g. 1 finds the typos. The distance is printed by -format json and the template of -format.")
+	explain           = commandLine.Bool("explain", false, "Print the matcher chosen for the regex to stderr.")
+	prefilter         = commandLine.Bool("prefilter", true, "Skip the regex on the lines without the literal the regex requires, e.g. timeout of ERROR.*timeout.")
+	batch             = commandLine.Bool("batch", true, "Concatenate the small files to grep them together, e.g. the files of node_modules, to save the setup of the greps.")
+	failOn            = commandLine.String("fail-on", "", "Exit with non-zero status only on: error, nomatch or never, e.g. error fails the CI on the unreadable files but not on zero matches. Default is like grep: 1 if nothing matches and 2 on errors. Invalid flags and patterns always exit with 2.")
+	debugEvents       = commandLine.Bool("debug", false, "Print the debug events of the greps to stderr, e.g. to diagnose a hang or a slow run: the workers started and finished, the chunks of the lines dispatched and matched, the scan errors and the cancellations.")
+	printStats        = commandLine.Bool("stats", false, "Print the summary of the files, the lines scanned, the bytes read, the lines matched, the elapsed time and the utilization of the workers to stderr. Each worker prints its own summary with -remote.")
+	onlyMatching      = commandLine.Bool("o", false, "Print only the matched parts of lines.")
+	group             = commandLine.Int("group", -1, "Print only the capture group N of the matches. Implies -o.")
+	goIdent           = commandLine.String("go-ident", "", "Search the Go identifier exactly instead of REGEX, printing line:column:text.")
+	quiet             = commandLine.Bool("q", false, "Print nothing and exit immediately with zero status if any match is found.")
+	countLines        = commandLine.Bool("c", false, "Print only the number of the matched lines of each file, prefixed with the file name if multiple files are searched.")
+	countMatches      = commandLine.Bool("count-matches", false, "Print only the number of the matches of each file like -c, counting every match in a line, e.g. a line with three matches counts three.")
+	filesWithMatches  = commandLine.Bool("l", false, "Print only the names of the files that contain matches. Stops reading a file at the first match.")
+	filesWithoutMatch = commandLine.Bool("L", false, "Print only the names of the files that contain no matches. Stops reading a file at the first match.")
+	maxCount          = commandLine.Int("m", 0, "Stop reading a file after the number of matching lines. With -j > 1, the lines are not guaranteed to be the first ones. Positive number is valid.")
+	maxLineLength     = commandLine.Int("max-line-length", bufio.MaxScanTokenSize, "The max length of a line in bytes. Positive number is valid.")
+	longLines         = commandLine.String("long-lines", string(gogrep.LongLineError), "How to handle the lines longer than -max-line-length: error, skip or truncate.")
+	fadviseMode       = commandLine.String("fadvise", "", "Advise the kernel how the files are read on Linux: sequential reads ahead aggressively and dontneed drops the read pages from the page cache not to evict the others.")
+	useMmap           = commandLine.Bool("mmap", false, "Memory-map the files and grep the ranges of each large file in parallel by the -j workers on Linux. Falls back to the normal reads where unsupported.")
+	readahead         = commandLine.Int("readahead", 0, "Open and read the heads of up to the number of the next files in the background while the files are grepped, to hide the latency of opening the files on network filesystems like NFS. Positive number is valid.")
+	directIO          = commandLine.Bool("direct", false, "Read the files with O_DIRECT bypassing the page cache on Linux. Falls back to the normal reads where unsupported. Disables detecting compressed frames and sparse files.")
+	nullData          = commandLine.Bool("z", false, "Treat the input and output as NUL-terminated records instead of lines.")
+	nullFileName      = commandLine.Bool("Z", false, "Print NUL instead of the character following a file name, the colon of the matches and -c or the newline of -l, -L and -heading, for safe piping of the file names like gogrep -l -Z REGEX | xargs -0.")
+	multiline         = commandLine.Bool("U", false, "Allow the matches to span lines like 'foo\\nbar' and print the blocks of the lines that contain the matches. The matches are searched by a single worker.")
+	decompress        = commandLine.Bool("decompress", true, "Decompress the gzip, bzip2 and zstd inputs detected by the magic bytes like zgrep.")
+	binaryFiles       = commandLine.String("binary-files", string(gogrep.BinaryMatches), "How to handle the files that contain NUL in the first block: binary prints only whether they match, text treats them as text and without-match assumes they do not match. Ignored with -z.")
+	encodingName      = commandLine.String("encoding", "", "Transcode the inputs from the encoding like utf-16le, shift_jis or latin1 to UTF-8 before matching. The BOM of UTF-8 and UTF-16 overrides it.")
+	outputEncoding    = commandLine.String("output-encoding", "utf-8", "The encoding of the printed texts: utf-8 or source. source encodes the texts back to -encoding to keep the original bytes, and requires -format text.")
+	stdinFormat       = commandLine.String("stdin-format", "raw", "The format of stdin: raw or tar. tar greps each member like the file of the member path, e.g. tar cf - dir | gogrep -stdin-format tar REGEX. The compressed tar is decompressed with -decompress.")
+	scoreBy           = commandLine.String("score-by", "", "The score of a match for -top: $N or ${NAME} is the numeric value of the capture group, len($N) is its length, duration($N) is the seconds of the duration like 350ms or 1.2s and bytes($N) is the bytes of the size like 4GiB or 512K, e.g. -score-by '$1' -top 10 for the slowest requests. The matches without the scores are dropped.")
+	topK              = commandLine.Int("top", 0, "Print only the number of the matches with the highest scores by -score-by, in descending order of the scores after all the inputs are grepped. Positive number is valid.")
+	aggregation       = commandLine.String("aggregate", "", "Print the table of the functions of the capture groups of the matches by the key instead of the matches, like 'count,sum(duration($2)),p95(duration($2)) by $1'. The functions are count, sum, avg, min, max and pN like p95 of $N, ${NAME} or the converters as -score-by. The quantiles are estimated within 1% relative error in bounded memory. The matches without the key are dropped.")
+	_                 = commandLine.Bool("no-config", false, "Ignore the default flags of the config file and GOGREP_OPTS.")
+	stdinLabel        = commandLine.String("label", "(standard input)", "The file name printed for stdin, read when no files are given or where - is given among the files.")
+	follow            = commandLine.Bool("follow", false, "Keep reading the files for the appended lines like tail -F and print the new matches as they arrive until interrupted. The truncated and rotated files are read again from the beginning.")
+	watch             = commandLine.Bool("watch", false, "Keep watching the files and the files under -root after the grep, and grep the changed files again, printing only the new matches after the headers of the times of the changes until interrupted.")
+	watchDebounce     = commandLine.Duration("watch-debounce", 200*time.Millisecond, "Wait for the duration after the last change to grep the changed files together with -watch.")
+	sourceTimeout     = commandLine.Duration("source-timeout", 0, "Give up the file not grepped within the duration, e.g. stuck on a network filesystem, reporting the timeout and grepping the rest of the files. 0 means no timeout.")
+	searchArchives    = commandLine.Bool("search-archives", false, "Grep the regular files in the .tar, .tar.gz, .tgz, .tar.bz2, .tar.zst and .zip files without extracting them, printed as ARCHIVE!PATH.")
+	unique            = commandLine.Bool("unique", false, "Print each matched line only once across the files, like sort -u but keeping the first ones in order.")
+	uniqueBy          = commandLine.String("unique-by", "", "Print the matches deduplicated by the key: text is the matched line, or the match with -o, and match is the matched substrings of the line. Implies -unique.")
+	uniqueLimit       = commandLine.Int("unique-limit", 0, "Limit the memory of the keys remembered by -unique to the bytes, forgetting the oldest keys beyond it. Not positive number means no limit.")
+	sortBy            = commandLine.String("sort", "", "Print the matches sorted by the key after all the files are searched, so the order does not depend on the workers: file, line or text. Keeps all the matches in memory.")
+	dedupFiles        = commandLine.Bool("dedup", false, "Grep the files only once even if they are reached by the different paths, e.g. the hard links, the symbolic links to the files given or the files given twice.")
+	filesFrom         = commandLine.String("files-from", "", "Grep the files listed in the file, one per line, or - for stdin. The files are grepped as the names are read, e.g. from find still running, after the files given as arguments.")
+	hiddenFiles       = commandLine.Bool("hidden", false, "Search the dotfiles and the dot-directories like .github under -root, which are skipped by default. The .git directories are still skipped by -respect-gitignore.")
+	followSymlinks    = commandLine.Bool("follow-symlinks", false, "Follow the symbolic links under -root, skipping the directories entered already to stop the loops. The -root and the files given as arguments are followed regardless.")
+	maxFilesize       = commandLine.String("max-filesize", "", "Skip the files larger than the size like 10M or 1.5GiB under -root.")
+	maxDepth          = commandLine.Int("max-depth", 0, "Search the files up to the depth under -root, where the files directly under a root are at depth 1. The deeper directories are pruned without being read. 0 means unlimited.")
+	minDepth          = commandLine.Int("min-depth", 0, "Search the files at the depth or deeper under -root, skipping the files of the top levels like the configurations directly under a root.")
+	strict            = commandLine.Bool("strict", false, "Exit with 2 if any content is skipped or unreadable, even with -q and a match. The skipped contents are reported as -report-skipped, into stderr unless -report-skipped.")
+	reportSkipped     = commandLine.String("report-skipped", "", "Write a JSON record per line into the file, or - for stderr, for each content not searched: the binary files by -binary-files without-match, the long lines by -long-lines, and the files by the ignore files, the dotfiles without -hidden, -exclude, -exclude-dir, -type-not, -max-filesize, -dedup, -changed-only, the compressed files with -checkpoint, the loops of -follow-symlinks or as the output.")
+	nulFileList       = commandLine.Bool("0", false, "Read the names of -files-from separated by NUL instead of newlines, e.g. from find -print0.")
+	heading           = commandLine.Bool("heading", false, "Print the file name on its own line before the matches of the file instead of prefixing each match like ripgrep, separating the files by empty lines. The matches of the files are not interleaved.")
+	lineNumber        = commandLine.Bool("n", false, "Print the line numbers.")
+	byteOffset        = commandLine.Bool("byte-offset", false, "Print the 0-based byte offset in the file of each matched line, or of each match with -o, after the line number, like -b of grep.")
+	colorMode         = commandLine.String("color", "auto", "Highlight the matches: auto, always or never. auto colorizes only when stdout is a terminal.")
+	format            = commandLine.String("format", "text", "The output format: text, json, github, junit or parquet. json prints a JSON object per line. github prints the warning commands of GitHub Actions to annotate the matched lines. junit writes the JUnit XML report where each matched file is a failing test case into -output or stdout. parquet writes the columnar records into -output and is available with -tags parquet. The format containing {{ is a text/template like '{{.File}}:{{.Line}}:{{.Column}}:{{.Text}}' over Root, File, Line, Column, Offset, Text, Submatches (with -o), Fingerprint, Owners, Binary and Distance (with -fuzzy), printed per line.")
+	withRunMetadata   = commandLine.Bool("run-metadata", false, "Write the metadata of the run: the patterns, the flags set, the hostname, the git commit of the searched tree and the start and end times into -format json as the last line {\"run\":{...}}, junit as the properties or parquet as the key-value metadata.")
+	outputFile        = commandLine.String("output", "", "The file to write -format parquet or junit into.")
+	fingerprint       = commandLine.Bool("fingerprint", false, "Print the matches with their stable hashes for gogrep diff-results. Implies -format json.")
+	baselineFile      = commandLine.String("baseline", "", "Suppress the matches recorded in the baseline file.")
+	updateBaseline    = commandLine.Bool("update-baseline", false, "Record all the matches into the -baseline file instead of printing them.")
+	codeownersFile    = commandLine.String("codeowners", "", "Annotate the matches with the owners from the CODEOWNERS file in -format json. Paths are relative to the current directory.")
+	groupByOwner      = commandLine.Bool("group-by-owner", false, "Print the number of the matches per owner instead of the matches. Requires -codeowners.")
+	sqliteFile        = commandLine.String("sqlite", "", "Insert the matches into the table gogrep_results of the SQLite database file instead of printing them, with the run id, the file, the line, the byte offset, the text and the submatches.")
+	respectGitignore  = commandLine.Bool("respect-gitignore", true, "Skip the files and the directories under -root matched by .gitignore and .git/info/exclude in a git repository and .ignore anywhere, like ripgrep. The .git directories are skipped too.")
+	noIgnore          = commandLine.Bool("no-ignore", false, "Do not skip the files by the ignore files. Same as -respect-gitignore=false.")
+	inPlace           = commandLine.Bool("in-place", false, "Rewrite the files with -replace instead of printing them. The files without matches and the binary files are not rewritten.")
+	transactional     = commandLine.Bool("transactional", false, "Replace the files rewritten by -in-place only after all the rewrites succeed, and restore them if any replacement fails, not to leave the files half-edited.")
+	lineEnding        = commandLine.String("line-ending", string(gogrep.LineEndingPreserve), "The line endings of the lines rewritten by -replace: preserve, lf or crlf.")
+	scope             = commandLine.String("scope", "", "Limit matching to comments, strings or code of source files. The language is detected from the file extension and files of unknown languages are not scoped.")
+)
+
+var (
+	notInside       stringsFlag
+	patternFlags    stringsFlag
+	patternFile     = commandLine.String("f", "", "Read patterns from the file, one per line.")
+	rootFlags       stringsFlag
+	includeFlags    stringsFlag
+	excludeFlags    stringsFlag
+	excludeDirFlags stringsFlag
+	remotes         stringsFlag
+	replacement     templateFlag
+	whereFlags      stringsFlag
+	typeFlags       stringsFlag
+	typeNotFlags    stringsFlag
+	typeAddFlags    stringsFlag
+)
+
+func init() {
+	commandLine.Var(&patternFlags, "e", "Use the pattern. Can be specified multiple times. A line matches if any pattern matches.")
+	commandLine.Var(&rootFlags, "root", "Search the files under the directory recursively. The format is PATH[:OPT,...] where OPT is label=NAME, include=GLOB, exclude=GLOB or exclude-dir=GLOB. Can be specified multiple times.")
+	commandLine.Var(&includeFlags, "include", "Search only the files whose base names match the glob like '*.go' under all the -root. Can be specified multiple times.")
+	commandLine.Var(&excludeFlags, "exclude", "Skip the files whose base names match the glob like '*_test.go' under all the -root. Can be specified multiple times.")
+	commandLine.Var(&excludeDirFlags, "exclude-dir", "Skip the directories whose base names match the glob like vendor under all the -root without reading them. Can be specified multiple times.")
+	commandLine.Var(&typeFlags, "type", "Search only the files of the types like go,md under all the -root, by the globs of the base names like *.go. Can be specified multiple times.")
+	commandLine.Var(&typeNotFlags, "type-not", "Skip the files of the types like go,md under all the -root. Can be specified multiple times.")
+	commandLine.Var(&typeAddFlags, "type-add", "Add the globs to the type of -type and -type-not like 'web:*.html,*.css'. Can be specified multiple times.")
+	commandLine.Var(&remotes, "remote", "Split the files across the workers started by the command like 'ssh host gogrep worker' and merge their matches. Can be specified multiple times.")
+	commandLine.Var(&replacement, "replace", "Print the inputs replacing the matches by the template where $1 or ${name} is the capture group, like sed s/REGEX/TEMPLATE/g. The patterns are applied in order to each line. The lines without matches are printed as they are.")
+	commandLine.Var(&whereFlags, "where", "Keep only the matches whose capture group satisfies the comparison like '$2 > 500' or '${status} == 503'. The operators are >, >=, <, <=, == and !=, the left side is $N, ${NAME}, len($N), duration($N) or bytes($N) as -score-by, and the right side is converted by the converter of the left side like 'duration($1) > 1.5s'. The matches without the values are dropped. Can be specified multiple times to require all.")
+	commandLine.BoolVar(nullFileName, "null", false, "Same as -Z.")
+	commandLine.Var(&notInside, "not-inside", `Suppress the matches inside the delimiters like '"..."' or '/*...*/'. Can be specified multiple times.`)
+}
+
+// subcommands are dispatched by the first argument.
+// Use -- to grep for a regex that equals to a subcommand name.
+var subcommands = map[string]func(args []string) error{
+	"engines":      runEngines,
+	"capabilities": runCapabilities,
+	"diff-results": runDiffResults,
+	"worker":       runWorker,
+	"image":        runImage,
+	"proc":         runProc,
+	"gen":          runGen,
+	"gen-docs":     runGenDocs,
+	"serve":        runServe,
+	"version":      runVersion,
+	"stress":       runStress, // hidden for the reliability tests
+}
+
+// parseGrepSubcommand parses the flags of gogrep for a subcommand like gogrep SUBCOMMAND [flags] OPERAND... REGEX
+// and returns the n operands and the arguments for runGrep.
+func parseGrepSubcommand(args []string, usage string, n int) ([]string, []string, error) {
+	if err := parseFlags(args); err != nil {
+		return nil, nil, err
+	}
+	rest := commandLine.Args()
+	want := n + 1 // REGEX
+	if len(patternFlags) > 0 || *patternFile != "" {
+		want = n
+	}
+	if len(rest) != want {
+		return nil, nil, errors.New(usage)
+	}
+	return rest[:n], rest[n:], nil
+}
+
+// interruptContext returns the context canceled by the interrupt with the cause of gogrep.CancelSignal.
+func interruptContext(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt)
+	go func() {
+		select {
+		case <-sigC:
+			cancel(gogrep.CancelCause(gogrep.CancelSignal))
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigC)
+		cancel(nil)
+	}
+}
+
+// runGrep greps by the parsed flags and the arguments and returns the exit status.
+func runGrep(args []string) (status int) {
+	ctx, stop := interruptContext(context.Background())
+	defer stop()
+
+	if err := validateFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitError
+	}
+	flushStdout := setupStdout()
+	defer func() {
+		if err := flushStdout(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitError
+		}
+	}()
+	if *diagnosticsDir != "" {
+		runDiagnostics = newDiagnostics()
+		defer func() { dumpDiagnostics(status, recover()) }()
+	}
+	stopProfiles, err := startProfiles()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer stopProfiles()
+	if *goIdent != "" {
+		found, err := grepGoIdent(ctx, *goIdent, args)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitStatus(!(*quiet && found), found)
+		}
+		return exitStatus(false, found)
+	}
+	if err := parseNotInside(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		printUsage()
+		return exitError
+	}
+	start := clock.Now()
+	err = grep(ctx, args)
+	if *printStats && len(remotes) == 0 {
+		grepStats.print(os.Stderr, clock.Now().Sub(start))
+	}
+	switch {
+	case err == nil:
+	case err == errUsage:
+		printUsage()
+		return exitError
+	case errors.Is(err, gogrep.ErrBadPattern):
+		// Invalid like the flags regardless of -fail-on
+		fmt.Fprintln(os.Stderr, err)
+		runDiagnostics.record("", err)
+		return exitError
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		runDiagnostics.record("", err)
+		printUsage()
+		return exitStatus(true, matched)
+	}
+	failed := targetFailed && !(*quiet && matched)
+	if *strict {
+		if n := skipReport.skipped(); n > 0 {
+			msg.Fprintf(os.Stderr, "gogrep: %d contents skipped\n", n)
+			failed = true
+		}
+		if targetFailed {
+			failed = true
+		}
+	}
+	return exitStatus(failed, matched)
+}
+
+// Exit status like grep.
+const (
+	exitNotMatched = 1
+	exitError      = 2
+)
+
+var (
+	// errUsage means the arguments are insufficient.
+	errUsage = errors.New("usage")
+	// errQuitMatched stops grep with -q because a match is found.
+	errQuitMatched = errors.New("matched")
+	// targetFailed is true if the grep of any target got an error.
+	targetFailed bool
+)
+
+func explainEngine(engine gogrep.Engine, regex string) {
+	var literal string
+	if *prefilter {
+		literal = gogrep.RequiredLiteral(regex)
+	}
+	if engine != gogrep.EngineAuto {
+		if engine != gogrep.EngineRegexp || literal == "" {
+			fmt.Fprintf(os.Stderr, "engine=%s (explicit)\n", engine)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "engine=%s (explicit) prefilter=%q\n", engine, literal)
+		return
+	}
+	p := gogrep.PlanPattern(regex)
+	if p.Matcher != string(gogrep.EngineRegexp) || literal == "" {
+		fmt.Fprintf(os.Stderr, "engine=%s %s\n", engine, p)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "engine=%s %s prefilter=%q\n", engine, p, literal)
+}
+
+var notInsideDelimiters []gogrep.Delimiters
+
+func parseNotInside() error {
+	for _, x := range notInside {
+		d, err := gogrep.ParseDelimiters(x)
+		if err != nil {
+			return err
+		}
+		notInsideDelimiters = append(notInsideDelimiters, d)
+	}
+	return nil
+}
+
+// grepEngine returns the engine by -engine, -F, -glob or -P.
+func grepEngine() gogrep.Engine {
+	if *fixedStrings {
+		return gogrep.EngineFixed
+	}
+	if *perlRegexp {
+		e, _ := pcreEngine() // validated
+		return e
+	}
+	if *globPatterns {
+		return gogrep.EngineGlob
+	}
+	return gogrep.Engine(*engine)
+}
+
+// newPatternGrepper returns the Grepper for the patterns and the regexes to be given to it.
+// The fixed strings from -f are matched by gogrep.WithLiteralSet instead of the regexes.
+func newPatternGrepper(patterns []string) (gogrep.Grepper, []string) {
+	if grepEngine() != gogrep.EngineFixed || *patternFile == "" {
+		return newGrepper(), patterns
+	}
+	return newGrepper(gogrep.WithLiteralSet(patterns)), nil
+}
+
+// grepWorkers returns the number of the workers by -j.
+func grepWorkers() int {
+	if *threads == 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return *threads
+}
+
+// newGrepper returns a Grepper configured by the flags and the options.
+func newGrepper(options ...gogrep.Option) gogrep.Grepper {
+	opt := []gogrep.Option{
+		gogrep.WithThreads(*threads),
+		gogrep.WithResultBufferSize(*resultBufferSize),
+		gogrep.WithEngine(grepEngine()),
+		gogrep.WithMaxLineLength(*maxLineLength),
+		gogrep.WithLongLineMode(gogrep.LongLineMode(*longLines)),
+		gogrep.WithClock(clock),
+		// Report the errors of the targets and grep the rest like grep
+		gogrep.WithErrorPolicy(gogrep.ErrorContinue),
+	}
+	if *threads == 0 {
+		opt = append(opt, gogrep.WithAutoTune())
+	}
+	if !*prefilter {
+		opt = append(opt, gogrep.WithoutPrefilter())
+	}
+	if *fuzzy >= 0 {
+		opt = append(opt, gogrep.WithFuzzy(*fuzzy))
+	}
+	opt = append(opt, normalizeOptions()...)
+	if *maxPendingLines > 0 {
+		opt = append(opt, gogrep.WithMaxPendingLines(*maxPendingLines), gogrep.WithBlockingResults())
+	}
+	if !*batch {
+		opt = append(opt, gogrep.WithoutBatching())
+	}
+	if *readahead > 0 {
+		opt = append(opt, gogrep.WithReadahead(*readahead))
+	}
+	if *follow {
+		opt = append(opt, gogrep.WithFollow(0))
+	} else if *lineBuffered {
+		// Dispatch the lines of the slow sources like the pipes soon, not reading the whole sources to batch them
+		opt = append(opt, gogrep.WithFlushPolicy(gogrep.FlushPolicy{MaxDelay: lineBufferedDelay}), gogrep.WithoutBatching())
+	}
+	if *printStats || *diagnosticsDir != "" {
+		opt = append(opt, gogrep.WithStatsCollector(grepStats.collect))
+	}
+	if *sourceTimeout > 0 {
+		opt = append(opt, gogrep.WithPerSourceTimeout(*sourceTimeout))
+	}
+	if *debugEvents {
+		opt = append(opt, gogrep.WithLogger(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+		})))
+	}
+	if !*nullData {
+		// NUL is a delimiter of the records
+		opt = append(opt, gogrep.WithBinaryFiles(gogrep.BinaryFiles(*binaryFiles)))
+	}
+	if *onlyMatching || *group >= 0 {
+		opt = append(opt, gogrep.WithOnlyMatching())
+	}
+	if *maxCount > 0 {
+		opt = append(opt, gogrep.WithMaxCount(*maxCount))
+	}
+	if *countMatches {
+		opt = append(opt, gogrep.WithCountMatches())
+	}
+	if *reportSkipped != "" || *strict {
+		opt = append(opt, gogrep.WithReportSkipped())
+	}
+	if *nullData {
+		opt = append(opt, gogrep.WithDelimiter(0))
+	}
+	if *uniqueBy != "" {
+		opt = append(opt, gogrep.WithUniqueBy(gogrep.UniqueBy(*uniqueBy)))
+	} else if *unique {
+		opt = append(opt, gogrep.WithUnique())
+	}
+	if *uniqueLimit > 0 {
+		opt = append(opt, gogrep.WithUniqueLimit(*uniqueLimit))
+	}
+	if *sortBy != "" {
+		opt = append(opt, gogrep.WithSort(gogrep.SortBy(*sortBy)))
+	}
+	if *multiline {
+		opt = append(opt, gogrep.WithMultiline())
+	}
+	if *decompress && *checkpointFile == "" {
+		opt = append(opt, gogrep.WithDecompression())
+	}
+	if *encodingName != "" {
+		opt = append(opt, gogrep.WithEncoding(*encodingName))
+	}
+	if (*quiet || *filesWithMatches || *filesWithoutMatch) && *baselineFile == "" && len(whereFlags) == 0 {
+		// The first match is enough
+		opt = append(opt, gogrep.WithMaxResults(1))
+	}
+	if len(notInsideDelimiters) > 0 {
+		opt = append(opt, gogrep.WithNotInside(notInsideDelimiters...))
+	}
+	return gogrep.New(append(opt, options...)...)
+}
+
+// sourceOptions returns the options for the file in addition to newGrepper.
+func sourceOptions(file string) []gogrep.Option {
+	var opt []gogrep.Option
+	if *scope != "" {
+		if lang, ok := gogrep.LanguageByPath(file); ok {
+			opt = append(opt, gogrep.WithScope(lang, gogrep.Scope(*scope)))
+		}
+	}
+	return opt
+}
+
+// resultText returns the text to print.
+// Returns false if the result should not be printed.
+func resultText(r gogrep.Result) (string, bool) {
+	if *group < 0 {
+		return r.Text(), true
+	}
+	// The groups that did not participate in the match or matched empty print nothing as grep -o
+	if submatches := r.Submatches(); *group < len(submatches) && submatches[*group] != "" {
+		return submatches[*group], true
+	}
+	return "", false
+}
+
+func grep(ctx context.Context, args []string) error {
+	patterns, files, err := parsePatterns(args)
+	if err != nil {
+		return err
+	}
+	if len(patterns) == 0 {
+		return errUsage
+	}
+	patterns = ignoreCasePatterns(patterns)
+	if *explain {
+		for _, p := range patterns {
+			explainEngine(grepEngine(), p)
+		}
+	}
+	switch {
+	case *updateBaseline:
+		matchBaseline = &baseline{}
+	case *baselineFile != "":
+		if matchBaseline, err = loadBaseline(*baselineFile); err != nil {
+			return err
+		}
+	}
+	if err := parseRoots(); err != nil {
+		return err
+	}
+	if *withRunMetadata {
+		runInfo = newRunMetadata(patterns, runTreeDir(files))
+	}
+	if len(whereFlags) > 0 {
+		if matchWhere, err = newWhereFilter(whereFlags, patterns); err != nil {
+			return err
+		}
+	}
+	if *aggregation != "" {
+		if matchAggregate, err = newAggregator(*aggregation, patterns); err != nil {
+			return err
+		}
+	}
+	if *topK > 0 {
+		if matchTop, err = newTopMatches(*topK, *scoreBy, patterns); err != nil {
+			return err
+		}
+	}
+	if matchFormatter, err = newFormatter(*format, patterns); err != nil {
+		return err
+	}
+	if *codeownersFile != "" {
+		if matchCodeowners, err = loadCodeowners(*codeownersFile); err != nil {
+			return err
+		}
+	}
+	if *sqliteFile != "" {
+		if matchDB, err = openSQLite(*sqliteFile); err != nil {
+			return err
+		}
+	}
+	switch {
+	case *reportSkipped != "":
+		if skipReport, err = openSkipReport(*reportSkipped); err != nil {
+			return err
+		}
+	case *strict:
+		if skipReport, err = openSkipReport(stdinPath); err != nil {
+			return err
+		}
+	}
+	if *changedOnly != "" {
+		if changedManifest, err = loadManifest(*changedOnly); err != nil {
+			return err
+		}
+	}
+	if *checkpointFile != "" {
+		if scanCheckpoint, err = openCheckpoint(*checkpointFile, *resumeScan); err != nil {
+			return err
+		}
+	}
+	targetIdentities = newFileIdentities()
+	printFileName = len(files) > 1 || len(roots) > 0 || imageRef != "" || procMode || *searchArchives || *filesFrom != "" || (len(files) == 0 && *stdinFormat == stdinTar)
+	switch {
+	case replacement.set:
+		err = replaceTargets(ctx, patterns, files)
+	case len(remotes) > 0:
+		err = grepRemote(ctx, remotes, patterns, files)
+	default:
+		err = grepTargets(ctx, patterns, files)
+	}
+	if matchTop != nil && (err == nil || err == errQuitMatched) {
+		matchTop.flush()
+	}
+	if f, ok := matchFormatter.(closingFormatter); ok {
+		if cerr := f.close(); err == nil || err == errQuitMatched {
+			err = cerr
+		}
+	}
+	if cerr := skipReport.close(); cerr != nil && (err == nil || err == errQuitMatched) {
+		err = cerr
+	}
+	if cerr := scanCheckpoint.close(); cerr != nil && (err == nil || err == errQuitMatched) {
+		err = cerr
+	}
+	if err != nil && err != errQuitMatched {
+		if matchDB != nil {
+			matchDB.rollback()
+		}
+		return err
+	}
+	if matchDB != nil {
+		if err := matchDB.commit(); err != nil {
+			return err
+		}
+	}
+	if *updateBaseline {
+		return matchBaseline.write(*baselineFile)
+	}
+	if *groupByOwner {
+		return matchCodeowners.writeSummary(stdout)
+	}
+	if matchAggregate != nil {
+		return matchAggregate.writeSummary(stdout)
+	}
+	return nil
+}
+
+// parsePatterns returns the patterns from -e, -f or the first argument, and the files to grep.
+func parsePatterns(args []string) ([]string, []string, error) {
+	if len(patternFlags) == 0 && *patternFile == "" {
+		if len(args) == 0 {
+			return nil, nil, nil
+		}
+		return args[:1], args[1:], nil
+	}
+	patterns := append([]string{}, patternFlags...)
+	if *patternFile != "" {
+		xs, err := readLines(*patternFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		patterns = append(patterns, xs...)
+	}
+	return patterns, args, nil
+}
+
+// readLines returns the lines of the file.
+func readLines(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		lines []string
+		sc    = bufio.NewScanner(f)
+	)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines, sc.Err()
+}
+
+// target is a source to grep.
+type target struct {
+	path string // empty for stdin
+	root string // label of the root where the target was found, empty if given directly
+	// reader is read instead of opening the path if not nil, and closed if it is an io.Closer
+	reader io.Reader
+	// options are added to the options for the path
+	options []gogrep.Option
+	start   int64 // the offset of the file to read from by -resume
+}
+
+// stdinPath is the file argument that reads stdin.
+const stdinPath = "-"
+
+// name returns the name of the target to print, -label for stdin.
+func (t *target) name() string {
+	if t.path == "" {
+		return *stdinLabel
+	}
+	return t.path
+}
+
+// labels returns the labels of the source of the target: root is the label of the root.
+func (t *target) labels() map[string]string {
+	if t.root == "" {
+		return nil
+	}
+	return map[string]string{"root": t.root}
+}
+
+// grepTargets greps the files and the files under the roots.
+// Reads stdin if both are empty.
+func grepTargets(ctx context.Context, patterns []string, files []string) error {
+	targets, err := collectTargets(files)
+	if err != nil {
+		return err
+	}
+	if *follow {
+		return grepFollow(ctx, patterns, targets)
+	}
+	if *watch {
+		return grepWatch(ctx, patterns, targets)
+	}
+	if *filesFrom != "" {
+		return grepFilesFrom(ctx, patterns, targets)
+	}
+	if len(targets) > 0 {
+		if *searchArchives {
+			return grepTargetsWithArchives(ctx, patterns, targets)
+		}
+		return grepSources(ctx, patterns, targets)
+	}
+	if imageRef != "" {
+		return grepImage(ctx, patterns, imageRef)
+	}
+	if procMode {
+		return grepProc(ctx, patterns)
+	}
+	if *stdinFormat == stdinTar {
+		return grepTar(ctx, patterns, os.Stdin)
+	}
+	return grepSources(ctx, patterns, []*target{{}})
+}
+
+// collectTargets returns the targets of the files and the files under the roots.
+func collectTargets(files []string) ([]*target, error) {
+	var targets []*target
+	for _, file := range files {
+		if file == stdinPath {
+			targets = append(targets, &target{})
+			continue
+		}
+		targets = append(targets, &target{path: file})
+	}
+	for _, r := range roots {
+		if err := r.walk(func(t *target) error {
+			targets = append(targets, t)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return changedManifest.filterTargets(targetIdentities.filterTargets(targets)), nil
+}
+
+// grepSources greps the targets in parallel and prints the matches in order of the targets.
+func grepSources(ctx context.Context, patterns []string, targets []*target) error {
+	targets = scanCheckpoint.targets(targets)
+	interrupted := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stop reading ahead on return
+	var progress []gogrep.Option
+	if *showProgress {
+		progress = append(progress, gogrep.WithProgress(newProgressBar(os.Stderr, targets).report))
+	}
+	var (
+		sources   = make([]gogrep.NamedSource, len(targets))
+		checksums = make([]*contentChecksum, len(targets))
+	)
+	for i, t := range targets {
+		opt := append(append(sourceOptions(t.path), t.options...), progress...)
+		if checksums[i] = newContentChecksum(); checksums[i] != nil {
+			opt = append(opt, gogrep.WithReaderMiddleware(gogrep.ReaderRaw, checksums[i].wrap))
+		}
+		sources[i] = gogrep.NamedSource{
+			Name:    t.path,
+			Reader:  newTargetSource(ctx, t),
+			Labels:  t.labels(),
+			Options: append(opt, gogrep.WithSourceTag(i)),
+		}
+	}
+	g, regexes := newPatternGrepper(patterns)
+	resultC, err := g.GrepSources(ctx, regexes, sources)
+	if err != nil {
+		return err
+	}
+	var (
+		next    int // index of the next target to be done
+		current *targetState
+	)
+	// done finishes the targets before the index
+	done := func(index int) error {
+		for ; next < index; next++ {
+			if current == nil || current.index != next {
+				current = &targetState{index: next}
+			}
+			if err := current.done(targets[next]); err != nil {
+				return err
+			}
+			// The targets not grepped by the interrupt are not done
+			scanCheckpoint.done(targets[next], current.failed || interrupted.Err() != nil)
+			if err := checksums[next].report(targets[next]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for r := range resultC {
+		index := r.Tag().(int)
+		if err := done(index); err != nil {
+			return err
+		}
+		if current == nil || current.index != index {
+			current = &targetState{index: index}
+		}
+		if err := current.add(targets[index], r); err != nil {
+			return err
+		}
+	}
+	return done(len(targets))
+}
+
+// targetState is the state of the results of a target.
+type targetState struct {
+	index    int
+	found    bool
+	count    int // the matched lines with -c or the matches with -count-matches
+	lastLine int // the line of the last result counted by -c
+	failed   bool
+}
+
+// add prints the result of the target.
+func (s *targetState) add(t *target, r gogrep.Result) error {
+	if skipReport.reportResult(t, r) || scanCheckpoint.reportResult(t, r) || scanCheckpoint.seen(t, r) {
+		return nil
+	}
+	err := r.Err()
+	if err != nil && !errors.Is(err, gogrep.ErrBinaryFile) {
+		fmt.Fprintln(os.Stderr, errorMessage(t.name(), err))
+		runDiagnostics.record(t.name(), err)
+		targetFailed = true
+		s.failed = true
+		return nil
+	}
+	if err == nil && !matchWhere.keep(r.Text()) {
+		return nil
+	}
+	s.found = true
+	if *filesWithMatches || *filesWithoutMatch {
+		return nil
+	}
+	if *countLines || *countMatches {
+		s.addCount(r)
+		return nil
+	}
+	if err != nil {
+		emitMatch(&match{
+			Root:   t.root,
+			File:   t.name(),
+			Binary: true,
+		})
+	} else if text, ok := resultText(r); ok {
+		printMatch(t, r, text)
+	}
+	if *quiet && matched {
+		return errQuitMatched
+	}
+	return nil
+}
+
+// addCount counts the result for -c or -count-matches.
+// The results of -o in the same line count as a line.
+func (s *targetState) addCount(r gogrep.Result) {
+	if r.Err() != nil {
+		s.count++ // a binary file matches
+		return
+	}
+	if *countMatches {
+		s.count += gogrep.MatchCount(r)
+		return
+	}
+	if r.Line() != s.lastLine {
+		s.count++
+		s.lastLine = r.Line()
+	}
+}
+
+// done is called when all the results of the target are added.
+func (s *targetState) done(t *target) error {
+	if *filesWithMatches || *filesWithoutMatch {
+		return listFile(t, s.found)
+	}
+	if *countLines || *countMatches {
+		return printCount(t, s.count)
+	}
+	return nil
+}
+
+// printCount prints the count of the target for -c or -count-matches.
+func printCount(t *target, count int) error {
+	if count > 0 {
+		matched = true
+	}
+	if *quiet {
+		if matched {
+			return errQuitMatched
+		}
+		return nil
+	}
+	if !printFileName {
+		fmt.Fprintln(stdout, count)
+		return nil
+	}
+	fmt.Fprintf(stdout, "%s%s%d\n", t.name(), fileNameTerminator(":"), count)
+	return nil
+}
+
+// lazySource opens the target on the first read
+// so that the files are not opened until they are grepped.
+type lazySource struct {
+	ctx    context.Context
+	target *target
+	source io.ReadCloser
+	err    error
+}
+
+func (s *lazySource) Read(p []byte) (int, error) {
+	if s.source == nil && s.err == nil {
+		if s.source, s.err = openTarget(s.ctx, s.target); s.err != nil {
+			// Not an error at a line of the source
+			s.err = &gogrep.SourceError{
+				Name: s.target.name(),
+				Err:  s.err,
+			}
+		}
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.source.Read(p)
+}
+
+// MapOffset implements gogrep.OffsetMapper if the opened source does.
+func (s *lazySource) MapOffset(offset int64) int64 {
+	if m, ok := s.source.(gogrep.OffsetMapper); ok {
+		return m.MapOffset(offset)
+	}
+	return offset
+}
+
+func (s *lazySource) Close() error {
+	if s.source == nil {
+		return nil
+	}
+	return s.source.Close()
+}
+
+// listFile prints the name of the target for -l or -L.
+func listFile(t *target, found bool) error {
+	if found != *filesWithMatches {
+		return nil
+	}
+	matched = true
+	if *quiet {
+		return errQuitMatched
+	}
+	fmt.Fprint(stdout, t.name()+fileNameTerminator("\n"))
+	return nil
+}
+
+// fileNameTerminator returns the string that follows a printed file name,
+// NUL with -Z so that the names containing the separators are parsed safely, or sep otherwise.
+func fileNameTerminator(sep string) string {
+	if *nullFileName {
+		return "\x00"
+	}
+	return sep
+}
+
+// openTarget opens the source of the target.
+// The frames of BGZF and zstd seekable files are decompressed in parallel.
+func openTarget(ctx context.Context, t *target) (io.ReadCloser, error) {
+	if t.reader != nil {
+		if r, ok := t.reader.(io.ReadCloser); ok {
+			return r, nil
+		}
+		return io.NopCloser(t.reader), nil
+	}
+	if t.path == "" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	if !isHostFS() {
+		return fileSystem.Open(t.path)
+	}
+	if scanCheckpoint != nil {
+		// The offsets of the checkpoints are in the file as it is
+		return openFileAt(t.path, t.start)
+	}
+	if *directIO {
+		// Fall back to the page cache if unsupported
+		if r, err := openDirect(t.path); err == nil {
+			return r, nil
+		}
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return f, nil
+	}
+	adviseOpen(f)
+	r, err := gogrep.NewFrameReader(ctx, f, info.Size(), *threads)
+	if err != nil {
+		return openSparse(f), nil
+	}
+	return &readCloser{
+		Reader: r,
+		close: func() error {
+			r.Close()
+			return closeFile(f)
+		},
+	}, nil
+}
+
+// openFileAt opens the file to read from the offset.
+func openFileAt(path string, offset int64) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// openSparse reads the file skipping holes if any.
+func openSparse(f *os.File) io.ReadCloser {
+	r, err := gogrep.NewSparseReader(f)
+	if err != nil {
+		return f
+	}
+	return &sparseFile{
+		SparseReader: r,
+		f:            f,
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (s *readCloser) Close() error { return s.close() }
+
+// sparseFile keeps gogrep.OffsetMapper of the reader.
+type sparseFile struct {
+	*gogrep.SparseReader
+	f       *os.File
+	read    int64
+	dropped int64 // file offset until which the pages are dropped by -fadvise dontneed
+}
+
+func (s *sparseFile) Read(p []byte) (int, error) {
+	n, err := s.SparseReader.Read(p)
+	s.read += int64(n)
+	if *fadviseMode == adviseDontNeed {
+		if offset := s.MapOffset(s.read); offset-s.dropped >= dontNeedChunkSize {
+			fadvise(s.f, s.dropped, offset-s.dropped, adviseDontNeed)
+			s.dropped = offset
+		}
+	}
+	return n, err
+}
+
+func (s *sparseFile) Close() error { return closeFile(s.f) }
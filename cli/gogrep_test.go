@@ -0,0 +1,1762 @@
+package cli_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func content() []string {
+	return []string{
+		"grand theft wumps",
+		"replublics of haskell",
+		"a sunset is a sunset because it's crimson, beautiful, and I want it to be crimson",
+		"hold fast that which is good",
+		"domains of interest to people",
+		"snowflake",
+		"strict or lazy",
+		"ehekatl of luck",
+		"crime of using a side effect",
+		"you must not request the world to stagnate",
+	}
+}
+
+// TestMain performs end to end test.
+func TestMain(t *testing.T) {
+	g, err := newGrepper()
+	fatalOnError(t, err)
+	defer g.close()
+	// prepare grep targets
+	target := strings.Join(content(), "\n")
+	fatalOnError(t, g.createFile("testmain0", target))
+	fatalOnError(t, g.copyFile("testmain1", "testmain0"))
+
+	test := func(t *testing.T, args, want []string) {
+		cmd := exec.Command(g.command, args...)
+		stdout, err := cmd.StdoutPipe()
+		fatalOnError(t, err)
+		fatalOnError(t, cmd.Start())
+		gotBytes, err := io.ReadAll(stdout)
+		fatalOnError(t, err)
+		fatalOnError(t, cmd.Wait())
+		got := strings.Split(strings.TrimSuffix(string(gotBytes), "\n"), "\n")
+		assert.Equal(t, len(want), len(got))
+		sort.Strings(want)
+		sort.Strings(got)
+		for i, w := range want {
+			g := got[i]
+			assert.Equal(t, w, g)
+		}
+	}
+
+	t.Run("files", func(t *testing.T) {
+		wantContent := []string{
+			"grand theft wumps",
+			"snowflake",
+		}
+		filenames := []string{
+			g.filePath("testmain0"),
+			g.filePath("testmain1"),
+		}
+		want := []string{}
+		for _, c := range wantContent {
+			for _, p := range filenames {
+				want = append(want, fmt.Sprintf("%s:%s", p, c))
+			}
+		}
+		args := []string{`snowflake|wumps`}
+		args = append(args, filenames...)
+		test(t, args, want)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		want := []string{
+			"grand theft wumps",
+			"snowflake",
+		}
+		args := []string{
+			`snowflake|wumps`,
+			g.filePath("testmain0"),
+		}
+		test(t, args, want)
+	})
+
+	t.Run("engines", func(t *testing.T) {
+		test(t, []string{"engines"}, []string{"auto", "fixed", "glob", "regexp"})
+	})
+
+	t.Run("engines bench", func(t *testing.T) {
+		out, err := exec.Command(g.command, "engines", "bench", "-j", "2", "-b", "10", g.filePath("testmain0"), "crim").Output()
+		fatalOnError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		assert.Equal(t, 6, len(lines))
+		assert.Contains(t, lines[0], "ENGINE")
+		assert.Contains(t, string(out), "fixed ")
+		assert.Contains(t, string(out), "regexp ")
+		assert.True(t, strings.HasPrefix(lines[5], "aho-corasick "), lines[5])
+		assert.Equal(t, strings.Fields(lines[2])[1], strings.Fields(lines[5])[1], "the same matches as fixed")
+
+		_, err = exec.Command(g.command, "engines", "bench", "-j", "-1", g.filePath("testmain0"), "crim").Output()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("subcommand flags", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"engines", "bench"},
+			{"gen"},
+			{"serve"},
+			{"stress"},
+			{"version"},
+		} {
+			var stderr bytes.Buffer
+			cmd := exec.Command(g.command, append(args, "-h")...)
+			cmd.Stderr = &stderr
+			_ = cmd.Run()
+			assert.Equal(t, 0, cmd.ProcessState.ExitCode(), "%v -h", args)
+			assert.Contains(t, stderr.String(), "Usage of ", "%v -h", args)
+			assert.NotContains(t, stderr.String(), flag.ErrHelp.Error(), "%v -h", args)
+
+			stderr.Reset()
+			cmd = exec.Command(g.command, append(args, "-no-such-flag")...)
+			cmd.Stderr = &stderr
+			_ = cmd.Run()
+			assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "%v -no-such-flag", args)
+			assert.Equal(t, 1, strings.Count(stderr.String(), "flag provided but not defined"), "%v -no-such-flag", args)
+		}
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		cmd := exec.Command(g.command, "-explain", "^grand", g.filePath("testmain0"))
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		fatalOnError(t, err)
+		assert.Equal(t, "grand theft wumps\n", string(out))
+		assert.Contains(t, stderr.String(), "matcher=prefix")
+	})
+
+	t.Run("prefilter", func(t *testing.T) {
+		explain := func(args ...string) string {
+			cmd := exec.Command(g.command, append([]string{"-explain"}, args...)...)
+			var stderr strings.Builder
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			fatalOnError(t, err)
+			assert.Equal(t, "grand theft wumps\n", string(out))
+			return stderr.String()
+		}
+		assert.Contains(t, explain("gr.*wumps", g.filePath("testmain0")), `prefilter="wumps"`)
+		assert.NotContains(t, explain("-prefilter=false", "gr.*wumps", g.filePath("testmain0")), "prefilter=")
+	})
+
+	t.Run("scope", func(t *testing.T) {
+		fatalOnError(t, g.createFile("scope.go", strings.Join([]string{
+			`// crimson comment`,
+			`s := "crimson string"`,
+			`crimson := 1`,
+		}, "\n")))
+		test(t, []string{"-scope", "comments", "crimson", g.filePath("scope.go")}, []string{"// crimson comment"})
+		test(t, []string{"-scope", "code", "crimson", g.filePath("scope.go")}, []string{"crimson := 1"})
+	})
+
+	t.Run("go-ident", func(t *testing.T) {
+		fatalOnError(t, g.createFile("ident.go", strings.Join([]string{
+			`package main`,
+			`// crimson in comment`,
+			`var crimson = "crimson"`,
+			`var crimsonRed = crimson`,
+		}, "\n")))
+		test(t, []string{"-go-ident", "crimson", g.filePath("ident.go")}, []string{
+			`3:5:var crimson = "crimson"`,
+			`4:18:var crimsonRed = crimson`,
+		})
+		run := func(args ...string) (string, int) {
+			cmd := exec.Command(g.command, args...)
+			out, _ := cmd.Output()
+			return string(out), cmd.ProcessState.ExitCode()
+		}
+		out, code := run("-go-ident", "scarlet", g.filePath("ident.go"))
+		assert.Equal(t, "", out)
+		assert.Equal(t, 1, code, "no match")
+		out, code = run("-go-ident", "crimson", "-q", g.filePath("ident.go"))
+		assert.Equal(t, "", out)
+		assert.Equal(t, 0, code, "quiet")
+		_, code = run("-go-ident", "scarlet", "-q", g.filePath("ident.go"))
+		assert.Equal(t, 1, code, "quiet no match")
+	})
+
+	t.Run("only matching", func(t *testing.T) {
+		test(t, []string{"-o", `crim\w+`, g.filePath("testmain0")}, []string{"crimson", "crimson", "crime"})
+	})
+
+	t.Run("group", func(t *testing.T) {
+		test(t, []string{"-group", "1", `(\w+) of`, g.filePath("testmain0")}, []string{"replublics", "ehekatl", "crime", "domains"})
+		fatalOnError(t, g.createFile("group alternation", "a\nb\nab\n"))
+		test(t, []string{"-group", "2", `(a)|(b)`, g.filePath("group alternation")}, []string{"b", "b"})
+	})
+
+	t.Run("only matching empty", func(t *testing.T) {
+		fatalOnError(t, g.createFile("only matching empty", "abc\nxxd\n"))
+		test(t, []string{"-o", `x*`, g.filePath("only matching empty")}, []string{"xx"})
+	})
+
+	t.Run("not inside", func(t *testing.T) {
+		fatalOnError(t, g.createFile("notinside", strings.Join([]string{
+			`"crimson" is quoted`,
+			`(crimson) is enclosed`,
+			`crimson is bare`,
+		}, "\n")))
+		test(t, []string{"-not-inside", `"..."`, "-not-inside", "(...)", "crimson", g.filePath("notinside")}, []string{
+			"crimson is bare",
+		})
+	})
+
+	t.Run("multiple patterns", func(t *testing.T) {
+		test(t, []string{"-e", "snowflake", "-e", "^grand", g.filePath("testmain0")}, []string{
+			"grand theft wumps",
+			"snowflake",
+		})
+	})
+
+	t.Run("pattern file", func(t *testing.T) {
+		fatalOnError(t, g.createFile("patterns", "snowflake\n^grand\n"))
+		test(t, []string{"-f", g.filePath("patterns"), "-e", "lazy", g.filePath("testmain0")}, []string{
+			"grand theft wumps",
+			"snowflake",
+			"strict or lazy",
+		})
+	})
+
+	t.Run("fixed strings file", func(t *testing.T) {
+		fatalOnError(t, g.createFile("keywords", "snow\n^grand\nwumps\n"))
+		test(t, []string{"-F", "-f", g.filePath("keywords"), "-e", "lazy", g.filePath("testmain0")}, []string{
+			"grand theft wumps",
+			"snowflake",
+			"strict or lazy",
+		})
+		test(t, []string{"-F", "-o", "-f", g.filePath("keywords"), g.filePath("testmain0")}, []string{
+			"wumps",
+			"snow",
+		})
+	})
+
+	t.Run("pcre unavailable", func(t *testing.T) {
+		cmd := exec.Command(g.command, "-P", `(\w)\1`, g.filePath("testmain0"))
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		assert.NotNil(t, cmd.Run())
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+		assert.Contains(t, stderr.String(), "-P requires gogrep built with -tags pcre")
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		test(t, []string{"-glob", "*of*[kl]", g.filePath("testmain0")}, []string{
+			"replublics of haskell",
+			"ehekatl of luck",
+		})
+	})
+
+	t.Run("fuzzy", func(t *testing.T) {
+		test(t, []string{"-fuzzy", "1", "snowflak", g.filePath("testmain0")}, []string{
+			"snowflake",
+		})
+		test(t, []string{"-fuzzy", "1", "-format", "{{.Distance}}:{{.Text}}", "wamp", g.filePath("testmain0")}, []string{
+			"1:grand theft wumps",
+		})
+	})
+
+	t.Run("ignore case", func(t *testing.T) {
+		fatalOnError(t, g.createFile("normalize", "Gr\u00fc\u00dfe aus der Stra\u00dfe\nSTRASSE\ncafe\u0301 au lait\n\ufb01le\n"))
+		test(t, []string{"-i", "strasse", g.filePath("normalize")}, []string{
+			"STRASSE",
+		})
+		test(t, []string{"-i", "-normalize", "nfc", "strasse", g.filePath("normalize")}, []string{
+			"Gr\u00fc\u00dfe aus der Stra\u00dfe",
+			"STRASSE",
+		})
+		test(t, []string{"-i", "-F", "STRA\u00dfE", g.filePath("normalize")}, []string{
+			"Gr\u00fc\u00dfe aus der Stra\u00dfe",
+			"STRASSE",
+		})
+	})
+
+	t.Run("normalize", func(t *testing.T) {
+		test(t, []string{"-normalize", "nfc", "-o", "caf\u00e9", g.filePath("normalize")}, []string{
+			"cafe\u0301",
+		})
+		test(t, []string{"-normalize", "nfkc", "^file$", g.filePath("normalize")}, []string{
+			"\ufb01le",
+		})
+	})
+
+	t.Run("max pending lines", func(t *testing.T) {
+		test(t, []string{"-max-pending-lines", "1", "of [hl]", g.filePath("testmain0")}, []string{
+			"replublics of haskell",
+			"ehekatl of luck",
+		})
+	})
+
+	t.Run("fingerprint", func(t *testing.T) {
+		run := func(name string, args ...string) {
+			out, err := exec.Command(g.command, append([]string{"-fingerprint"}, args...)...).Output()
+			fatalOnError(t, err)
+			fatalOnError(t, g.createFile(name, string(out)))
+		}
+		run("fp0", "snowflake|wumps", g.filePath("testmain0"))
+		run("fp1", "snowflake|lazy", g.filePath("testmain0"))
+		cmd := exec.Command(g.command, "diff-results", g.filePath("fp0"), g.filePath("fp1"))
+		out, err := cmd.Output()
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, cmd.ProcessState.ExitCode())
+		assert.Equal(t, fmt.Sprintf("- %[1]s:grand theft wumps\n+ %[1]s:strict or lazy\n", g.filePath("testmain0")), string(out))
+
+		err = exec.Command(g.command, "diff-results", g.filePath("fp0"), g.filePath("fp0")).Run()
+		assert.Nil(t, err)
+
+		var stderr bytes.Buffer
+		cmd = exec.Command(g.command, "diff-results", g.filePath("fp0"), g.filePath("not exist"))
+		cmd.Stderr = &stderr
+		out, err = cmd.Output()
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "broken input")
+		assert.Equal(t, "", string(out))
+		assert.Contains(t, stderr.String(), g.filePath("not exist"))
+
+		fatalOnError(t, g.createFile("fp broken", "not json\n"))
+		cmd = exec.Command(g.command, "diff-results", g.filePath("fp broken"), g.filePath("fp0"))
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "unparsable input")
+	})
+
+	t.Run("baseline", func(t *testing.T) {
+		baseline := g.filePath("baseline.json")
+		fatalOnError(t, exec.Command(g.command, "-baseline", baseline, "-update-baseline", "snowflake", g.filePath("testmain0")).Run())
+		test(t, []string{"-baseline", baseline, "snowflake|wumps", g.filePath("testmain0")}, []string{"grand theft wumps"})
+	})
+
+	t.Run("json", func(t *testing.T) {
+		test(t, []string{"-format", "json", "snowflake", g.filePath("testmain0")}, []string{
+			fmt.Sprintf(`{"file":%q,"line":6,"offset":181,"text":"snowflake"}`, g.filePath("testmain0")),
+		})
+	})
+
+	t.Run("github", func(t *testing.T) {
+		test(t, []string{"-format", "github", "-e", "flak", "-e", "wumps", g.filePath("testmain0")}, []string{
+			fmt.Sprintf("::warning file=%s,line=1,col=13::matched wumps", g.filePath("testmain0")),
+			fmt.Sprintf("::warning file=%s,line=6,col=5::matched flak", g.filePath("testmain0")),
+		})
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		test(t, []string{"-format", "junit", "-e", "flak", "-e", "wumps", g.filePath("testmain0")}, []string{
+			`<?xml version="1.0" encoding="UTF-8"?>`,
+			`<testsuites>`,
+			`  <testsuite name="gogrep" tests="1" failures="1">`,
+			fmt.Sprintf(`    <testcase name="%[1]s" classname="gogrep" file="%[1]s" line="1">`, g.filePath("testmain0")),
+			`      <failure message="2 matches of flak | wumps" type="match">` + g.filePath("testmain0") + `:1: grand theft wumps` + "&#xA;" + g.filePath("testmain0") + `:6: snowflake</failure>`,
+			`    </testcase>`,
+			`  </testsuite>`,
+			`</testsuites>`,
+		})
+	})
+
+	t.Run("run metadata", func(t *testing.T) {
+		cmd := exec.Command(g.command, "-format", "json", "-run-metadata", "-n", "flak", g.filePath("testmain0"))
+		out, err := cmd.Output()
+		fatalOnError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if !assert.Equal(t, 2, len(lines)) {
+			return
+		}
+		var got struct {
+			Run struct {
+				Patterns []string          `json:"patterns"`
+				Options  map[string]string `json:"options"`
+				Start    time.Time         `json:"start"`
+				End      time.Time         `json:"end"`
+			} `json:"run"`
+		}
+		fatalOnError(t, json.Unmarshal([]byte(lines[1]), &got))
+		assert.Equal(t, []string{"flak"}, got.Run.Patterns)
+		assert.Equal(t, map[string]string{"format": "json", "run-metadata": "true", "n": "true"}, got.Run.Options)
+		assert.False(t, got.Run.Start.IsZero())
+		assert.False(t, got.Run.End.Before(got.Run.Start))
+
+		cmd = exec.Command(g.command, "-run-metadata", "flak", g.filePath("testmain0"))
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "requires a structured format")
+	})
+
+	t.Run("heading", func(t *testing.T) {
+		out, err := exec.Command(g.command, "-heading", "-n", "-e", "flak", "-e", "wumps", g.filePath("testmain0"), g.filePath("testmain1")).Output()
+		fatalOnError(t, err)
+		assert.Equal(t, strings.Join([]string{
+			g.filePath("testmain0"),
+			"1:grand theft wumps",
+			"6:snowflake",
+			"",
+			g.filePath("testmain1"),
+			"1:grand theft wumps",
+			"6:snowflake",
+			"",
+		}, "\n"), string(out))
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		fatalOnError(t, g.createFile("config", strings.Join([]string{
+			"# defaults",
+			"-n",
+			"",
+			"-color never",
+		}, "\n")))
+		run := func(env []string, args ...string) (string, int) {
+			cmd := exec.Command(g.command, args...)
+			cmd.Env = append(os.Environ(), env...)
+			out, _ := cmd.Output()
+			return string(out), cmd.ProcessState.ExitCode()
+		}
+		config := "GOGREP_CONFIG_PATH=" + g.filePath("config")
+
+		out, code := run([]string{config}, "snowflake", g.filePath("testmain0"))
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "6:snowflake\n", out)
+		out, _ = run([]string{config, "GOGREP_OPTS=-o -group 1"}, "(snow)flake", g.filePath("testmain0"))
+		assert.Equal(t, "6:snow\n", out)
+		out, _ = run([]string{config, "GOGREP_OPTS=-group 1"}, "-group", "-1", "(snow)flake", g.filePath("testmain0"))
+		assert.Equal(t, "6:snowflake\n", out, "the command line overrides")
+		out, _ = run([]string{config}, "-no-config", "snowflake", g.filePath("testmain0"))
+		assert.Equal(t, "snowflake\n", out)
+
+		_, code = run([]string{"GOGREP_OPTS=crimson"}, "snowflake", g.filePath("testmain0"))
+		assert.Equal(t, 2, code, "arguments in the defaults")
+	})
+
+	t.Run("line number", func(t *testing.T) {
+		test(t, []string{"-n", "snowflake", g.filePath("testmain0")}, []string{"6:snowflake"})
+	})
+
+	t.Run("color", func(t *testing.T) {
+		test(t, []string{"-color", "always", "-n", "flak", g.filePath("testmain0")}, []string{
+			"\x1b[32m6\x1b[m\x1b[36m:\x1b[msnow\x1b[01;31mflak\x1b[me",
+		})
+		test(t, []string{"-color", "auto", "flak", g.filePath("testmain0")}, []string{"snowflake"})
+	})
+
+	t.Run("codeowners", func(t *testing.T) {
+		fatalOnError(t, g.createFile("CODEOWNERS", strings.Join([]string{
+			"# comment",
+			"* @everyone",
+			"testmain1 @one @two",
+		}, "\n")))
+		args := []string{"-codeowners", g.filePath("CODEOWNERS"), "snowflake", g.filePath("testmain0"), g.filePath("testmain1")}
+		test(t, append([]string{"-format", "json"}, args...), []string{
+			fmt.Sprintf(`{"file":%q,"line":6,"offset":181,"text":"snowflake","owners":["@everyone"]}`, g.filePath("testmain0")),
+			fmt.Sprintf(`{"file":%q,"line":6,"offset":181,"text":"snowflake","owners":["@one","@two"]}`, g.filePath("testmain1")),
+		})
+		test(t, append([]string{"-group-by-owner"}, args...), []string{
+			"OWNER     MATCHES",
+			"@everyone 1",
+			"@one      1",
+			"@two      1",
+		})
+	})
+
+	t.Run("roots", func(t *testing.T) {
+		for _, name := range []string{"r0", "r0/sub", "r0/vendor", "r1"} {
+			fatalOnError(t, os.MkdirAll(g.filePath(name), 0755))
+		}
+		fatalOnError(t, g.createFile("r0/a.go", "crimson a"))
+		fatalOnError(t, g.createFile("r0/sub/b.go", "crimson b"))
+		fatalOnError(t, g.createFile("r0/sub/b.md", "crimson b md"))
+		fatalOnError(t, g.createFile("r0/vendor/c.go", "crimson c"))
+		fatalOnError(t, g.createFile("r1/d.md", "crimson d"))
+		test(t, []string{
+			"-root", g.filePath("r0") + ":include=*.go,exclude-dir=vendor",
+			"-root", g.filePath("r1") + ":label=second",
+			"crimson",
+		}, []string{
+			g.filePath("r0/a.go") + ":crimson a",
+			g.filePath("r0/sub/b.go") + ":crimson b",
+			g.filePath("r1/d.md") + ":crimson d",
+		})
+		test(t, []string{"-format", "json", "-root", g.filePath("r1") + ":label=second", "crimson"}, []string{
+			fmt.Sprintf(`{"root":"second","file":%q,"line":1,"offset":0,"text":"crimson d"}`, g.filePath("r1/d.md")),
+		})
+	})
+
+	t.Run("quiet", func(t *testing.T) {
+		exitCode := func(args ...string) int {
+			cmd := exec.Command(g.command, args...)
+			out, _ := cmd.Output()
+			assert.Equal(t, "", string(out))
+			return cmd.ProcessState.ExitCode()
+		}
+		assert.Equal(t, 0, exitCode("-q", "crimson", g.filePath("testmain0")))
+		assert.Equal(t, 1, exitCode("-q", "nothing matches", g.filePath("testmain0")))
+		assert.Equal(t, 2, exitCode("-q", "crimson", g.filePath("not exist")))
+		assert.Equal(t, 1, exitCode("nothing matches", g.filePath("testmain0")))
+	})
+
+	t.Run("files with matches", func(t *testing.T) {
+		files := []string{g.filePath("testmain0"), g.filePath("notinside")}
+		test(t, append([]string{"-l", "snowflake"}, files...), []string{g.filePath("testmain0")})
+		test(t, append([]string{"-L", "snowflake"}, files...), []string{g.filePath("notinside")})
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		worker := g.command + " worker"
+		test(t, []string{
+			"-remote", worker, "-remote", worker, "-o",
+			"-root", g.filePath("r0") + ":include=*.go,exclude-dir=vendor",
+			"crim[a-z]+", g.filePath("testmain0"), g.filePath("testmain1"),
+		}, []string{
+			g.filePath("testmain0") + ":crimson",
+			g.filePath("testmain0") + ":crimson",
+			g.filePath("testmain0") + ":crime",
+			g.filePath("testmain1") + ":crimson",
+			g.filePath("testmain1") + ":crimson",
+			g.filePath("testmain1") + ":crime",
+			g.filePath("r0/a.go") + ":crimson",
+			g.filePath("r0/sub/b.go") + ":crimson",
+		})
+
+		// Fails as the local grep on the missing target
+		var stderr strings.Builder
+		cmd := exec.Command(g.command, "-remote", worker, "snowflake", g.filePath("not exist"), g.filePath("testmain0"))
+		cmd.Stderr = &stderr
+		out, _ := cmd.Output()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+		assert.Equal(t, g.filePath("testmain0")+":snowflake\n", string(out))
+		assert.Contains(t, stderr.String(), g.filePath("not exist"))
+	})
+
+	t.Run("bgzf", func(t *testing.T) {
+		var data []byte
+		for _, c := range content() {
+			data = append(data, bgzfBlock(t, []byte(c+"\n"))...)
+		}
+		fatalOnError(t, g.createFile("bgzf.gz", string(data)))
+		test(t, []string{"snowflake|wumps", g.filePath("bgzf.gz")}, []string{
+			"grand theft wumps",
+			"snowflake",
+		})
+	})
+
+	t.Run("sparse", func(t *testing.T) {
+		fatalOnError(t, g.createFile("sparse", "crimson\n"))
+		f, err := os.OpenFile(g.filePath("sparse"), os.O_WRONLY, 0)
+		fatalOnError(t, err)
+		_, err = f.WriteAt([]byte("\nsnowflake\n"), 40960)
+		fatalOnError(t, err)
+		fatalOnError(t, f.Close())
+		test(t, []string{"-binary-files", "text", "-format", "json", "crimson|snowflake", g.filePath("sparse")}, []string{
+			fmt.Sprintf(`{"file":%q,"line":1,"offset":0,"text":"crimson"}`, g.filePath("sparse")),
+			fmt.Sprintf(`{"file":%q,"line":3,"offset":%d,"text":"snowflake"}`, g.filePath("sparse"), 40961),
+		})
+	})
+	t.Run("long lines", func(t *testing.T) {
+		fatalOnError(t, g.createFile("longlines", "crimson\n"+strings.Repeat("crimson ", 100)+"\nsnowflake\n"))
+		test(t, []string{"-j", "1", "-max-line-length", "16", "-long-lines", "skip", "crimson|snowflake", g.filePath("longlines")}, []string{
+			"crimson",
+			"snowflake",
+		})
+		test(t, []string{"-j", "1", "-max-line-length", "16", "-long-lines", "truncate", "crimson|snowflake", g.filePath("longlines")}, []string{
+			"crimson",
+			"crimson crimson ",
+			"snowflake",
+		})
+	})
+	t.Run("errors", func(t *testing.T) {
+		run := func(args ...string) (int, string) {
+			cmd := exec.Command(g.command, args...)
+			var stderr strings.Builder
+			cmd.Stderr = &stderr
+			_ = cmd.Run()
+			return cmd.ProcessState.ExitCode(), stderr.String()
+		}
+		status, stderr := run("-fail-on", "never", "crimson(", g.filePath("longlines"))
+		assert.Equal(t, 2, status, "bad pattern")
+		assert.Contains(t, stderr, "bad pattern")
+		assert.NotContains(t, stderr, "Usage")
+
+		status, stderr = run("-j", "1", "-max-line-length", "16", "snowflake", g.filePath("longlines"))
+		assert.Equal(t, 2, status, "long line")
+		assert.Equal(t, fmt.Sprintf("%s:2: bufio.Scanner: token too long\n", g.filePath("longlines")), stderr)
+	})
+	t.Run("direct and fadvise", func(t *testing.T) {
+		want := []string{
+			"grand theft wumps",
+			"snowflake",
+		}
+		test(t, []string{"-direct", "snowflake|wumps", g.filePath("testmain0")}, want)
+		test(t, []string{"-fadvise", "sequential", "snowflake|wumps", g.filePath("testmain0")}, want)
+		test(t, []string{"-fadvise", "dontneed", "snowflake|wumps", g.filePath("testmain0")}, want)
+		test(t, []string{"-readahead", "2", "snowflake|wumps", g.filePath("testmain0")}, want)
+	})
+	t.Run("null", func(t *testing.T) {
+		fatalOnError(t, g.createFile("null", "crimson\nsnowflake\x00wumps\x00crimson\x00"))
+		output := func(args ...string) []string {
+			out, err := exec.Command(g.command, args...).Output()
+			fatalOnError(t, err)
+			got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+			sort.Strings(got)
+			return got
+		}
+		assert.Equal(t, []string{"crimson", "crimson\nsnowflake"}, output("-z", "crimson", g.filePath("null")))
+		assert.Equal(t, []string{"crimson\nsnowflake"}, output("-z", "snowflake", g.filePath("null")))
+		assert.Equal(t, []string{g.filePath("null"), g.filePath("testmain0")},
+			output("-Z", "-l", "snowflake", g.filePath("null"), g.filePath("testmain0")))
+
+		fatalOnError(t, g.createFile("null:name\nwith newline", "amaranth 1\nsnow\n"))
+		name := g.filePath("null:name\nwith newline")
+		raw := func(args ...string) string {
+			out, err := exec.Command(g.command, append(args, name, g.filePath("testmain0"))...).Output()
+			fatalOnError(t, err)
+			return string(out)
+		}
+		assert.Equal(t, name+"\x001:amaranth 1\n", raw("-Z", "-n", "amaranth"))
+		assert.Equal(t, name+"\x001\n"+g.filePath("testmain0")+"\x000\n", raw("-Z", "-c", "amaranth"))
+		assert.Equal(t, name+"\x00amaranth 1\n", raw("-Z", "-heading", "amaranth"))
+		assert.Equal(t, g.filePath("testmain0")+"\x00", raw("-null", "-L", "amaranth"))
+	})
+	t.Run("multiline", func(t *testing.T) {
+		test(t, []string{"-U", "-n", `of interest to people\nsnow`, g.filePath("testmain0")}, []string{
+			"5:domains of interest to people",
+			"snowflake",
+		})
+	})
+	t.Run("decompress", func(t *testing.T) {
+		var data bytes.Buffer
+		w := gzip.NewWriter(&data)
+		_, err := io.WriteString(w, target)
+		fatalOnError(t, err)
+		fatalOnError(t, w.Close())
+		fatalOnError(t, g.createFile("compressed.gz", data.String()))
+		test(t, []string{"snowflake|wumps", g.filePath("compressed.gz")}, []string{
+			"grand theft wumps",
+			"snowflake",
+		})
+	})
+	t.Run("binary files", func(t *testing.T) {
+		fatalOnError(t, g.createFile("binary", "crimson\x00\ncrimson\n"))
+		test(t, []string{"crimson", g.filePath("binary")}, []string{
+			fmt.Sprintf("Binary file %s matches", g.filePath("binary")),
+		})
+		test(t, []string{"-format", "json", "crimson", g.filePath("binary")}, []string{
+			fmt.Sprintf(`{"file":%q,"line":0,"offset":0,"text":"","binary":true}`, g.filePath("binary")),
+		})
+		test(t, []string{"-binary-files", "text", "crimson$", g.filePath("binary")}, []string{
+			"crimson",
+		})
+		test(t, []string{"-l", "-binary-files", "without-match", "crimson", g.filePath("binary"), g.filePath("testmain0")}, []string{
+			g.filePath("testmain0"),
+		})
+	})
+	t.Run("encoding", func(t *testing.T) {
+		// UTF-16LE with BOM
+		utf16 := []byte{0xff, 0xfe}
+		for _, c := range "crimson\nsnowflake\n" {
+			utf16 = append(utf16, byte(c), 0)
+		}
+		fatalOnError(t, g.createFile("utf16", string(utf16)))
+		test(t, []string{"-encoding", "utf-16le", "snowflake", g.filePath("utf16")}, []string{
+			"snowflake",
+		})
+	})
+	t.Run("output encoding", func(t *testing.T) {
+		const sjis = "\x93\xfa\x96\x7b crimson" // 日本 in Shift_JIS
+		fatalOnError(t, g.createFile("sjis", sjis+"\nsnowflake\n"))
+		test(t, []string{"-encoding", "shift_jis", "crimson", g.filePath("sjis")}, []string{"日本 crimson"})
+		test(t, []string{"-encoding", "shift_jis", "-output-encoding", "source", "crimson", g.filePath("sjis")}, []string{sjis})
+		err := exec.Command(g.command, "-output-encoding", "source", "-format", "json", "crimson", g.filePath("sjis")).Run()
+		assert.NotNil(t, err)
+	})
+	t.Run("flag conflicts", func(t *testing.T) {
+		stderr := func(args ...string) string {
+			cmd := exec.Command(g.command, args...)
+			var b bytes.Buffer
+			cmd.Stderr = &b
+			_ = cmd.Run()
+			assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+			return strings.SplitN(b.String(), "\n", 2)[0]
+		}
+		assert.Equal(t, "-l and -L are exclusive", stderr("-l", "-L", "crimson", g.filePath("testmain0")))
+		assert.Equal(t, "-go-ident and -e are exclusive", stderr("-go-ident", "x", "-e", "crimson", g.filePath("testmain0")))
+		assert.Equal(t, "-update-baseline requires -baseline", stderr("-update-baseline", "crimson", g.filePath("testmain0")))
+		assert.Equal(t, "-in-place requires -replace", stderr("-in-place", "crimson", g.filePath("testmain0")))
+		assert.Equal(t, "-replace and -o are exclusive", stderr("-replace", "", "-o", "crimson", g.filePath("testmain0")))
+		assert.Equal(t, "-follow and -U are exclusive", stderr("-follow", "-U", "crimson", g.filePath("testmain0")))
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		dbFile := g.filePath("results.db")
+		out, err := exec.Command(g.command, "-sqlite", dbFile, "-o", `crimson\S*`, g.filePath("testmain0")).Output()
+		fatalOnError(t, err)
+		assert.Equal(t, "", string(out))
+		db, err := sql.Open("sqlite", dbFile)
+		fatalOnError(t, err)
+		defer db.Close()
+		var n int
+		fatalOnError(t, db.QueryRow("SELECT COUNT(*) FROM gogrep_results WHERE text LIKE 'crimson%'").Scan(&n))
+		assert.Equal(t, 2, n)
+	})
+	t.Run("parquet without the build tag", func(t *testing.T) {
+		cmd := exec.Command(g.command, "-format", "parquet", "-output", g.filePath("out.parquet"), "crimson", g.filePath("testmain0"))
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+	})
+	t.Run("tar stdin", func(t *testing.T) {
+		var b bytes.Buffer
+		tw := tar.NewWriter(&b)
+		for _, f := range []struct {
+			name, body string
+		}{
+			{"dir/a.txt", "crimson\nsnow\n"},
+			{"dir/b.txt", "grand\nthe crimson king\n"},
+		} {
+			fatalOnError(t, tw.WriteHeader(&tar.Header{
+				Name:     f.name,
+				Mode:     0o644,
+				Size:     int64(len(f.body)),
+				Typeflag: tar.TypeReg,
+			}))
+			_, err := io.WriteString(tw, f.body)
+			fatalOnError(t, err)
+		}
+		fatalOnError(t, tw.Close())
+		var z bytes.Buffer
+		zw := gzip.NewWriter(&z)
+		_, err := zw.Write(b.Bytes())
+		fatalOnError(t, err)
+		fatalOnError(t, zw.Close())
+
+		for name, data := range map[string][]byte{
+			"plain": b.Bytes(),
+			"gzip":  z.Bytes(),
+		} {
+			t.Run(name, func(t *testing.T) {
+				cmd := exec.Command(g.command, "-stdin-format", "tar", "-n", "crimson")
+				cmd.Stdin = bytes.NewReader(data)
+				out, err := cmd.Output()
+				fatalOnError(t, err)
+				assert.Equal(t, "dir/a.txt:1:crimson\ndir/b.txt:2:the crimson king\n", string(out))
+			})
+		}
+
+		cmd := exec.Command(g.command, "-stdin-format", "zip", "crimson")
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+	})
+	t.Run("top", func(t *testing.T) {
+		fatalOnError(t, g.createFile("latency.log", strings.Join([]string{
+			"GET /a 120ms",
+			"GET /b 30ms",
+			"GET /c 800ms",
+			"health check",
+			"GET /d 120ms",
+			"GET /e 95ms",
+		}, "\n")))
+		testOrdered := func(args []string, want []string) {
+			out, err := exec.Command(g.command, args...).Output()
+			fatalOnError(t, err)
+			assert.Equal(t, want, strings.Split(strings.TrimSpace(string(out)), "\n"))
+		}
+		testOrdered([]string{"-n", "-score-by", "$1", "-top", "3", `(\d+)ms`, g.filePath("latency.log")}, []string{
+			"3:GET /c 800ms",
+			"1:GET /a 120ms",
+			"5:GET /d 120ms",
+		})
+		testOrdered([]string{"-score-by", "len(${path})", "-top", "1", `GET (?P<path>\S+)`, g.filePath("latency.log")}, []string{
+			"GET /a 120ms",
+		})
+
+		cmd := exec.Command(g.command, "-top", "3", "GET", g.filePath("latency.log"))
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "requires -score-by")
+	})
+
+	t.Run("where", func(t *testing.T) {
+		fatalOnError(t, g.createFile("access.log", strings.Join([]string{
+			"GET /a 200 120ms",
+			"GET /b 503 30ms",
+			"GET /c 500 800ms",
+			"GET /d 404 900ms",
+		}, "\n")))
+		pattern := `(?P<status>\d{3}) (\d+)ms`
+		test(t, []string{"-where", "${status} >= 500", "-where", "$2 > 500", pattern, g.filePath("access.log")}, []string{
+			"GET /c 500 800ms",
+		})
+		test(t, []string{"-where", "$1!=200", pattern, g.filePath("access.log")}, []string{
+			"GET /b 503 30ms",
+			"GET /c 500 800ms",
+			"GET /d 404 900ms",
+		})
+		test(t, []string{"-l", "-where", "$2 > 850", pattern, g.filePath("access.log"), g.filePath("latency.log")}, []string{
+			g.filePath("access.log"),
+		})
+
+		test(t, []string{"-where", "duration($1) > 0.5s", `(\d+ms)`, g.filePath("access.log")}, []string{
+			"GET /c 500 800ms",
+			"GET /d 404 900ms",
+		})
+
+		cmd := exec.Command(g.command, "-q", "-where", "$2 > 1000", pattern, g.filePath("access.log"))
+		_ = cmd.Run()
+		assert.Equal(t, 1, cmd.ProcessState.ExitCode(), "no matches")
+		cmd = exec.Command(g.command, "-where", "$1 ~ 5", pattern, g.filePath("access.log"))
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "invalid operator")
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		out, err := exec.Command(g.command, "-aggregate", "count,sum(duration($2)),max(duration($2)) by ${status}",
+			`(?P<status>\d{3}) (\d+ms)`, g.filePath("access.log")).Output()
+		fatalOnError(t, err)
+		assert.Equal(t, `${status} count sum(duration($2)) max(duration($2))
+200       1     0.12              0.12
+404       1     0.9               0.9
+500       1     0.8               0.8
+503       1     0.03              0.03
+`, string(out))
+	})
+
+	t.Run("follow", func(t *testing.T) {
+		name := g.filePath("follow.log")
+		fatalOnError(t, g.createFile("follow.log", "crimson 1\nsnow\n"))
+		cmd := exec.Command(g.command, "-follow", "-n", "crimson", name)
+		stdout, err := cmd.StdoutPipe()
+		fatalOnError(t, err)
+		fatalOnError(t, cmd.Start())
+		lines := bufio.NewScanner(stdout)
+		assertNext := func(want string) {
+			if assert.True(t, lines.Scan()) {
+				assert.Equal(t, want, lines.Text())
+			}
+		}
+		assertNext("1:crimson 1")
+
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0o644)
+		fatalOnError(t, err)
+		_, err = f.WriteString("snow\ncrimson 2\n")
+		f.Close()
+		fatalOnError(t, err)
+		assertNext("4:crimson 2")
+
+		fatalOnError(t, cmd.Process.Signal(os.Interrupt))
+		_ = cmd.Wait()
+		assert.Equal(t, 0, cmd.ProcessState.ExitCode())
+
+		cmd = exec.Command(g.command, "-follow", "crimson")
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "requires files")
+	})
+
+	t.Run("buffering", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 20000; i++ {
+			fmt.Fprintf(&b, "line %d crimson\n", i)
+		}
+		fatalOnError(t, g.createFile("buffering", b.String()))
+		want, err := exec.Command(g.command, "-j", "1", "-line-buffered", "-n", "crimson", g.filePath("buffering")).Output()
+		fatalOnError(t, err)
+		assert.Equal(t, 20000, strings.Count(string(want), "\n"))
+		for _, arg := range []string{"-block-buffered", "-block-buffered=1K", "-block-buffered=4M"} {
+			got, err := exec.Command(g.command, "-j", "1", arg, "-n", "crimson", g.filePath("buffering")).Output()
+			fatalOnError(t, err)
+			assert.Equal(t, string(want), string(got), arg)
+		}
+		for _, args := range [][]string{
+			{"-block-buffered=0"},
+			{"-block-buffered=x"},
+			{"-line-buffered", "-block-buffered"},
+		} {
+			cmd := exec.Command(g.command, append(args, "crimson", g.filePath("buffering"))...)
+			_ = cmd.Run()
+			assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "%v", args)
+		}
+
+		cmd := exec.Command(g.command, "-line-buffered", "crimson")
+		stdin, err := cmd.StdinPipe()
+		fatalOnError(t, err)
+		stdout, err := cmd.StdoutPipe()
+		fatalOnError(t, err)
+		fatalOnError(t, cmd.Start())
+		lines := bufio.NewScanner(stdout)
+		_, err = io.WriteString(stdin, "snow\ncrimson 1\n")
+		fatalOnError(t, err)
+		if assert.True(t, lines.Scan()) {
+			assert.Equal(t, "crimson 1", lines.Text(), "before the end of the input")
+		}
+		stdin.Close()
+		assert.Nil(t, cmd.Wait())
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		fatalOnError(t, os.MkdirAll(g.filePath("watch/dir"), 0755))
+		fatalOnError(t, g.createFile("watch/a.log", "crimson 1\nsnow\n"))
+		fatalOnError(t, g.createFile("watch/dir/b.log", "crimson b\n"))
+		name := g.filePath("watch/a.log")
+		cmd := exec.Command(g.command, "-watch", "-watch-debounce", "50ms", "-n", "-no-ignore", "-root", g.filePath("watch/dir"), "crimson", name)
+		stdout, err := cmd.StdoutPipe()
+		fatalOnError(t, err)
+		fatalOnError(t, cmd.Start())
+		lines := bufio.NewScanner(stdout)
+		assertNext := func(want string) {
+			if assert.True(t, lines.Scan()) {
+				assert.Equal(t, want, lines.Text())
+			}
+		}
+		assertHeader := func() {
+			if assert.True(t, lines.Scan()) {
+				assert.True(t, strings.HasPrefix(lines.Text(), "==> "), lines.Text())
+			}
+		}
+		assertNext(name + ":1:crimson 1")
+		assertNext(g.filePath("watch/dir/b.log") + ":1:crimson b")
+		time.Sleep(500 * time.Millisecond) // wait for the watch
+
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0o644)
+		fatalOnError(t, err)
+		_, err = f.WriteString("crimson 1\ncrimson 2\n")
+		f.Close()
+		fatalOnError(t, err)
+		assertHeader()
+		assertNext(name + ":3:crimson 1")
+		assertNext(name + ":4:crimson 2")
+
+		fatalOnError(t, g.createFile("watch/dir/c.log", "crimson c\n"))
+		assertHeader()
+		assertNext(g.filePath("watch/dir/c.log") + ":1:crimson c")
+
+		fatalOnError(t, cmd.Process.Signal(os.Interrupt))
+		_ = cmd.Wait()
+		assert.Equal(t, 0, cmd.ProcessState.ExitCode())
+
+		cmd = exec.Command(g.command, "-watch", "crimson")
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "requires files")
+	})
+
+	t.Run("diagnostics", func(t *testing.T) {
+		dir := g.filePath("diagnostics")
+		test(t, []string{"-diagnostics", dir, "theft", g.filePath("testmain0")}, []string{
+			"grand theft wumps",
+		})
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err), "no bundle on success")
+
+		cmd := exec.Command(g.command, "-diagnostics", dir, "theft", g.filePath("testmain0"), g.filePath("diagnostics-missing"))
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+		bundles, err := filepath.Glob(filepath.Join(dir, "gogrep-*"))
+		fatalOnError(t, err)
+		if assert.Equal(t, 1, len(bundles)) {
+			for _, name := range []string{"stacks.txt", "options.json", "stats.txt"} {
+				_, err := os.Stat(filepath.Join(bundles[0], name))
+				assert.Nil(t, err, name)
+			}
+			errs, err := os.ReadFile(filepath.Join(bundles[0], "errors.txt"))
+			fatalOnError(t, err)
+			assert.Contains(t, string(errs), g.filePath("diagnostics-missing"))
+			options, err := os.ReadFile(filepath.Join(bundles[0], "options.json"))
+			fatalOnError(t, err)
+			assert.Contains(t, string(options), `"diagnostics": "`+dir+`"`)
+		}
+	})
+
+	t.Run("stress", func(t *testing.T) {
+		cmd := exec.Command(g.command, "stress", "-duration", "1s", "-lines", "1K", "-workers", "4")
+		out, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(out))
+		for _, name := range []string{"full", "cancel", "abandon", "signal"} {
+			assert.Regexp(t, `(?m)^stress: `+name+`=[1-9]\d*$`, string(out))
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		out, err := exec.Command(g.command, "-version").Output()
+		fatalOnError(t, err)
+		assert.True(t, strings.HasPrefix(string(out), "gogrep "), string(out))
+		assert.Contains(t, string(out), "\ngo go")
+		assert.NotContains(t, string(out), "features:")
+
+		out, err = exec.Command(g.command, "version", "-verbose").Output()
+		fatalOnError(t, err)
+		assert.True(t, strings.HasPrefix(string(out), "gogrep "), string(out))
+		assert.Contains(t, string(out), "features:\n")
+		assert.Contains(t, string(out), "  engine:regexp\n")
+	})
+
+	t.Run("profiles", func(t *testing.T) {
+		var (
+			cpu = g.filePath("cpu.pprof")
+			mem = g.filePath("mem.pprof")
+			tr  = g.filePath("run.trace")
+		)
+		test(t, []string{"-cpuprofile", cpu, "-memprofile", mem, "-trace", tr, "theft", g.filePath("testmain0")}, []string{
+			"grand theft wumps",
+		})
+		for _, name := range []string{cpu, mem, tr} {
+			info, err := os.Stat(name)
+			if assert.Nil(t, err, name) {
+				assert.Greater(t, info.Size(), int64(0), name)
+			}
+		}
+	})
+
+	t.Run("stdin among files", func(t *testing.T) {
+		run := func(args ...string) string {
+			cmd := exec.Command(g.command, args...)
+			cmd.Stdin = strings.NewReader("the crimson king\n")
+			out, err := cmd.Output()
+			fatalOnError(t, err)
+			return string(out)
+		}
+		assert.Equal(t, strings.Join([]string{
+			g.filePath("testmain0") + ":3:a sunset is a sunset because it's crimson, beautiful, and I want it to be crimson",
+			"(standard input):1:the crimson king",
+			g.filePath("testmain0") + ":3:a sunset is a sunset because it's crimson, beautiful, and I want it to be crimson",
+			"",
+		}, "\n"), run("-n", "crimson", g.filePath("testmain0"), "-", g.filePath("testmain0")))
+		assert.Equal(t, "piped:the crimson king\n", run("-label", "piped", "king", "-", g.filePath("testmain1")))
+		assert.Equal(t, "the crimson king\n", run("crimson", "-"))
+	})
+
+	t.Run("format template", func(t *testing.T) {
+		test(t, []string{"-format", "{{.File}}|{{.Line}}|{{.Column}}|{{.Text}}", "theft", g.filePath("testmain0")}, []string{
+			g.filePath("testmain0") + "|1|7|grand theft wumps",
+		})
+		test(t, []string{"-o", "-format", "{{index .Submatches 1}}={{.Text}}", `(\w+) of`, g.filePath("testmain0")}, []string{
+			"replublics=replublics of",
+			"domains=domains of",
+			"ehekatl=ehekatl of",
+			"crime=crime of",
+		})
+	})
+
+	t.Run("unique", func(t *testing.T) {
+		test(t, []string{"-unique", "theft", g.filePath("testmain0"), g.filePath("testmain1")}, []string{
+			g.filePath("testmain0") + ":grand theft wumps",
+		})
+		test(t, []string{"-unique-by", "match", "-o", "of", g.filePath("testmain0")}, []string{
+			"of",
+		})
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		fatalOnError(t, g.createFile("sort0", "two\nzero\none\n"))
+		fatalOnError(t, g.createFile("sort1", "foo\nbar\nboo\n"))
+		sorted := func(key string) string {
+			out, err := exec.Command(g.command, "-sort", key, "-n", "o", g.filePath("sort1"), g.filePath("sort0")).Output()
+			fatalOnError(t, err)
+			return string(out)
+		}
+		assert.Equal(t, strings.Join([]string{
+			g.filePath("sort0") + ":1:two",
+			g.filePath("sort0") + ":2:zero",
+			g.filePath("sort0") + ":3:one",
+			g.filePath("sort1") + ":1:foo",
+			g.filePath("sort1") + ":3:boo",
+		}, "\n")+"\n", sorted("file"))
+		assert.Equal(t, strings.Join([]string{
+			g.filePath("sort1") + ":3:boo",
+			g.filePath("sort1") + ":1:foo",
+			g.filePath("sort0") + ":3:one",
+			g.filePath("sort0") + ":1:two",
+			g.filePath("sort0") + ":2:zero",
+		}, "\n")+"\n", sorted("text"))
+		err := exec.Command(g.command, "-sort", "size", "o", g.filePath("sort0")).Run()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("count", func(t *testing.T) {
+		fatalOnError(t, g.createFile("count", "a a a\nb\na b a\n"))
+		test(t, []string{"-c", "a", g.filePath("count")}, []string{"2"})
+		test(t, []string{"-count-matches", "a", g.filePath("count")}, []string{"5"})
+		test(t, []string{"-count-matches", "-o", "a", g.filePath("count")}, []string{"5"})
+		test(t, []string{"-c", "-o", "a b", g.filePath("count"), g.filePath("testmain0")}, []string{
+			g.filePath("count") + ":1",
+			g.filePath("testmain0") + ":0",
+		})
+	})
+
+	t.Run("byte offset", func(t *testing.T) {
+		fatalOnError(t, g.createFile("offset", "xa\nb\nya yya\n"))
+		test(t, []string{"-byte-offset", "-n", "a", g.filePath("offset")}, []string{
+			"1:0:xa",
+			"3:5:ya yya",
+		})
+		test(t, []string{"-byte-offset", "-o", "a", g.filePath("offset")}, []string{
+			"1:a",
+			"10:a",
+			"6:a",
+		})
+	})
+
+	t.Run("report skipped", func(t *testing.T) {
+		fatalOnError(t, os.MkdirAll(g.filePath("skipped"), 0755))
+		fatalOnError(t, g.createFile("skipped/a.txt", "amber a\n"))
+		fatalOnError(t, g.createFile("skipped/b.bin", "amber\x00 b\n"))
+		fatalOnError(t, g.createFile("skipped/c.log", "amber c\n"))
+		report := g.filePath("skipped.jsonl")
+		test(t, []string{
+			"-root", g.filePath("skipped"), "-no-ignore", "-exclude", "*.log",
+			"-binary-files", "without-match", "-report-skipped", report, "amber",
+		}, []string{
+			g.filePath("skipped/a.txt") + ":amber a",
+		})
+		got, err := os.ReadFile(report)
+		fatalOnError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(got)), "\n")
+		sort.Strings(lines)
+		assert.Equal(t, []string{
+			fmt.Sprintf(`{"root":%q,"file":%q,"reason":"binary","line":1}`, g.filePath("skipped"), g.filePath("skipped/b.bin")),
+			fmt.Sprintf(`{"root":%q,"file":%q,"reason":"exclude"}`, g.filePath("skipped"), g.filePath("skipped/c.log")),
+		}, lines)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		fatalOnError(t, os.MkdirAll(g.filePath("strict"), 0755))
+		fatalOnError(t, g.createFile("strict/a.txt", "amber a\n"))
+		test(t, []string{"-root", g.filePath("strict"), "-no-ignore", "-strict", "amber"}, []string{
+			g.filePath("strict/a.txt") + ":amber a",
+		})
+
+		fatalOnError(t, g.createFile("strict/b.bin", "amber\x00 b\n"))
+		cmd := exec.Command(g.command, "-root", g.filePath("strict"), "-no-ignore", "-strict", "-binary-files", "without-match", "amber")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, _ := cmd.Output()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+		assert.Equal(t, g.filePath("strict/a.txt")+":amber a\n", string(out))
+		assert.Equal(t, strings.Join([]string{
+			fmt.Sprintf(`{"root":%q,"file":%q,"reason":"binary","line":1}`, g.filePath("strict"), g.filePath("strict/b.bin")),
+			"gogrep: 1 contents skipped",
+			"",
+		}, "\n"), stderr.String())
+
+		cmd = exec.Command(g.command, "-strict", "-q", "amber", g.filePath("strict/missing"), g.filePath("strict/a.txt"))
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "unreadable")
+	})
+
+	t.Run("follow symlinks", func(t *testing.T) {
+		fatalOnError(t, os.MkdirAll(g.filePath("links/src/sub"), 0755))
+		fatalOnError(t, os.MkdirAll(g.filePath("links/other"), 0755))
+		fatalOnError(t, g.createFile("links/src/sub/a", "coral a\n"))
+		fatalOnError(t, g.createFile("links/other/b", "coral b\n"))
+		fatalOnError(t, os.Symlink(g.filePath("links/src"), g.filePath("links/src/sub/loop")))
+		fatalOnError(t, os.Symlink(g.filePath("links/other"), g.filePath("links/src/other")))
+		fatalOnError(t, os.Symlink(g.filePath("links/src"), g.filePath("links/root")))
+
+		test(t, []string{"-root", g.filePath("links/root"), "-no-ignore", "coral"}, []string{
+			g.filePath("links/root/sub/a") + ":coral a",
+		})
+		test(t, []string{"-root", g.filePath("links/root"), "-no-ignore", "-follow-symlinks", "coral"}, []string{
+			g.filePath("links/root/other/b") + ":coral b",
+			g.filePath("links/root/sub/a") + ":coral a",
+		})
+	})
+
+	t.Run("file identity", func(t *testing.T) {
+		fatalOnError(t, os.MkdirAll(g.filePath("fid"), 0755))
+		fatalOnError(t, g.createFile("fid/a", "crimson a\n"))
+		fatalOnError(t, os.Link(g.filePath("fid/a"), g.filePath("fid/b")))
+		test(t, []string{"-dedup", "crimson", g.filePath("fid/a"), g.filePath("fid/b"), g.filePath("fid/a")}, []string{
+			g.filePath("fid/a") + ":crimson a",
+		})
+
+		// The output redirected under the root is not grepped
+		out, err := os.Create(g.filePath("fid/out"))
+		fatalOnError(t, err)
+		defer out.Close()
+		fatalOnError(t, g.createFile("fid/c", "crimson c\n"))
+		cmd := exec.Command(g.command, "-root", g.filePath("fid"), "-no-ignore", "-dedup", "crimson")
+		cmd.Stdout = out
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		fatalOnError(t, cmd.Run())
+		got, err := os.ReadFile(g.filePath("fid/out"))
+		fatalOnError(t, err)
+		assert.Equal(t, strings.Join([]string{
+			g.filePath("fid/a") + ":crimson a",
+			g.filePath("fid/c") + ":crimson c",
+			"",
+		}, "\n"), string(got))
+		assert.Contains(t, stderr.String(), "input file is also the output")
+	})
+
+	t.Run("files from", func(t *testing.T) {
+		fatalOnError(t, os.MkdirAll(g.filePath("ff"), 0755))
+		fatalOnError(t, g.createFile("ff/a b.txt", "crimson a\n"))
+		fatalOnError(t, g.createFile("ff/c.txt", "snow\ncrimson c\n"))
+		run := func(stdin string, args ...string) string {
+			cmd := exec.Command(g.command, args...)
+			cmd.Stdin = strings.NewReader(stdin)
+			out, err := cmd.Output()
+			fatalOnError(t, err)
+			return string(out)
+		}
+		assert.Equal(t, strings.Join([]string{
+			g.filePath("ff/a b.txt") + ":crimson a",
+			g.filePath("ff/c.txt") + ":crimson c",
+			"",
+		}, "\n"), run(g.filePath("ff/a b.txt")+"\x00"+g.filePath("ff/c.txt")+"\x00", "-j", "1", "-0", "-files-from", "-", "crimson"))
+		fatalOnError(t, g.createFile("ff/list", g.filePath("ff/c.txt")+"\n\n"))
+		assert.Equal(t, strings.Join([]string{
+			g.filePath("ff/a b.txt") + ":1:crimson a",
+			g.filePath("ff/c.txt") + ":2:crimson c",
+			"",
+		}, "\n"), run("", "-n", "-files-from", g.filePath("ff/list"), "crimson", g.filePath("ff/a b.txt")))
+	})
+
+	t.Run("search archives", func(t *testing.T) {
+		var b bytes.Buffer
+		zw := zip.NewWriter(&b)
+		for _, f := range []struct {
+			name, body string
+		}{
+			{"dir/a.txt", "crimson\nsnow\n"},
+			{"dir/b.txt", "grand\nthe crimson king\n"},
+		} {
+			w, err := zw.Create(f.name)
+			fatalOnError(t, err)
+			_, err = io.WriteString(w, f.body)
+			fatalOnError(t, err)
+		}
+		fatalOnError(t, zw.Close())
+		fatalOnError(t, g.createFile("bundle.zip", b.String()))
+
+		test(t, []string{"-search-archives", "-n", "crimson", g.filePath("bundle.zip"), g.filePath("testmain0")}, []string{
+			g.filePath("bundle.zip") + "!dir/a.txt:1:crimson",
+			g.filePath("bundle.zip") + "!dir/b.txt:2:the crimson king",
+			g.filePath("testmain0") + ":3:a sunset is a sunset because it's crimson, beautiful, and I want it to be crimson",
+		})
+		test(t, []string{"-search-archives", "-l", "crimson", g.filePath("bundle.zip")}, []string{
+			g.filePath("bundle.zip") + "!dir/a.txt",
+			g.filePath("bundle.zip") + "!dir/b.txt",
+		})
+	})
+
+	t.Run("image", func(t *testing.T) {
+		tarball := func(files ...string) []byte {
+			var b bytes.Buffer
+			tw := tar.NewWriter(&b)
+			for i := 0; i < len(files); i += 2 {
+				fatalOnError(t, tw.WriteHeader(&tar.Header{
+					Name:     files[i],
+					Mode:     0o644,
+					Size:     int64(len(files[i+1])),
+					Typeflag: tar.TypeReg,
+				}))
+				_, err := io.WriteString(tw, files[i+1])
+				fatalOnError(t, err)
+			}
+			fatalOnError(t, tw.Close())
+			return b.Bytes()
+		}
+		image := tarball(
+			"manifest.json", `[{"Config":"config.json","Layers":["l1/layer.tar","l2/layer.tar"]}]`,
+			"config.json", `{"rootfs":{"diff_ids":["sha256:l1","sha256:l2"]}}`,
+			"l1/layer.tar", string(tarball("etc/motd", "crimson one\n", "etc/old", "crimson old\n")),
+			"l2/layer.tar", string(tarball("etc/.wh.old", "", "etc/new", "crimson new\n")),
+		)
+		fatalOnError(t, g.createFile("image.tar", string(image)))
+
+		out, err := exec.Command(g.command, "image", "-n", g.filePath("image.tar"), "crimson").Output()
+		fatalOnError(t, err)
+		assert.Equal(t, "etc/motd:1:crimson one\netc/new:1:crimson new\n", string(out))
+
+		out, err = exec.Command(g.command, "image", "-format", "json", "-e", "new", g.filePath("image.tar")).Output()
+		fatalOnError(t, err)
+		assert.Contains(t, string(out), `"root":"sha256:l2"`)
+
+		cmd := exec.Command(g.command, "image", g.filePath("not exist"), "crimson")
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+	})
+	t.Run("proc", func(t *testing.T) {
+		if _, err := os.Stat("/proc/self/environ"); err != nil {
+			t.Skip("no procfs")
+		}
+		cmd := exec.Command(g.command, "proc", "-format", "json", "GOGREP_PROC_TEST=crim[s]on")
+		cmd.Env = append(os.Environ(), "GOGREP_PROC_TEST=crimson")
+		out, err := cmd.Output()
+		fatalOnError(t, err)
+		assert.Contains(t, string(out), fmt.Sprintf(`{"root":"gogrep","file":"/proc/%d/environ",`, cmd.Process.Pid))
+		assert.Contains(t, string(out), `"text":"GOGREP_PROC_TEST=crimson"}`)
+	})
+	t.Run("mmap", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 200000; i++ {
+			fmt.Fprintf(&b, "line %d crimson\n", i)
+		}
+		fatalOnError(t, g.createFile("mmap", b.String()))
+		want, err := exec.Command(g.command, "-format", "json", "crimson$", g.filePath("mmap")).Output()
+		fatalOnError(t, err)
+		got, err := exec.Command(g.command, "-mmap", "-format", "json", "crimson$", g.filePath("mmap")).Output()
+		fatalOnError(t, err)
+		wantLines := strings.Split(strings.TrimSpace(string(want)), "\n")
+		sort.Strings(wantLines)
+		gotLines := strings.Split(strings.TrimSpace(string(got)), "\n")
+		sort.Strings(gotLines)
+		assert.Equal(t, 200000, len(gotLines))
+		assert.Equal(t, wantLines, gotLines)
+		test(t, []string{"-mmap", "snowflake", g.filePath("testmain0")}, []string{"snowflake"})
+	})
+	t.Run("ranges of file", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 200000; i++ {
+			fmt.Fprintf(&b, "line %d vermilion\n", i)
+		}
+		fatalOnError(t, g.createFile("ranges", b.String()))
+		want, err := exec.Command(g.command, "-j", "1", "-format", "json", "[13]7 vermilion$", g.filePath("ranges")).Output()
+		fatalOnError(t, err)
+		got, err := exec.Command(g.command, "-j", "4", "-format", "json", "[13]7 vermilion$", g.filePath("ranges")).Output()
+		fatalOnError(t, err)
+		wantLines := strings.Split(strings.TrimSpace(string(want)), "\n")
+		sort.Strings(wantLines)
+		gotLines := strings.Split(strings.TrimSpace(string(got)), "\n")
+		sort.Strings(gotLines)
+		assert.Equal(t, 4000, len(gotLines))
+		assert.Equal(t, wantLines, gotLines)
+	})
+	t.Run("auto workers", func(t *testing.T) {
+		want, err := exec.Command(g.command, "-j", "1", "-c", "7 vermilion$", g.filePath("ranges")).Output()
+		fatalOnError(t, err)
+		got, err := exec.Command(g.command, "-c", "7 vermilion$", g.filePath("ranges")).Output()
+		fatalOnError(t, err)
+		assert.Equal(t, "20000\n", string(want))
+		assert.Equal(t, string(want), string(got))
+		cmd := exec.Command(g.command, "-j", "-1", "x", g.filePath("ranges"))
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+	})
+	t.Run("gen", func(t *testing.T) {
+		gen := func(args ...string) string {
+			out, err := exec.Command(g.command, append([]string{"gen"}, args...)...).Output()
+			fatalOnError(t, err)
+			return string(out)
+		}
+		corpus := gen("-lines", "2K", "-match-rate", "0.1", "-line-len", "20..40", "-seed", "3")
+		assert.Equal(t, corpus, gen("-lines", "2K", "-match-rate", "0.1", "-line-len", "20..40", "-seed", "3"), "deterministic")
+		assert.NotEqual(t, corpus, gen("-lines", "2K", "-match-rate", "0.1", "-line-len", "20..40", "-seed", "4"))
+		lines := strings.Split(strings.TrimSuffix(corpus, "\n"), "\n")
+		assert.Equal(t, 2000, len(lines))
+		var matches int
+		for _, x := range lines {
+			assert.True(t, len(x) >= 20 && len(x) <= 40, x)
+			if strings.Contains(x, "MATCH") {
+				matches++
+			}
+		}
+		assert.InDelta(t, 200, matches, 60)
+
+		dir := g.filePath("gen")
+		fatalOnError(t, exec.Command(g.command, "gen", "-lines", "2K", "-match-rate", "0.1", "-line-len", "20..40", "-seed", "3",
+			"-files", "3", "-output", dir).Run())
+		var joined strings.Builder
+		for i := 0; i < 3; i++ {
+			b, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("gen-%05d.txt", i)))
+			fatalOnError(t, err)
+			joined.Write(b)
+		}
+		assert.Equal(t, corpus, joined.String())
+	})
+	t.Run("capabilities", func(t *testing.T) {
+		out, err := exec.Command(g.command, "capabilities").Output()
+		fatalOnError(t, err)
+		assert.Contains(t, strings.Split(string(out), "\n"), "engine:regexp")
+	})
+	t.Run("stats", func(t *testing.T) {
+		var stderr bytes.Buffer
+		cmd := exec.Command(g.command, "-stats", "-j", "2", "snowflake", g.filePath("testmain0"), g.filePath("testmain1"))
+		cmd.Stderr = &stderr
+		_, err := cmd.Output()
+		fatalOnError(t, err)
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if !assert.Equal(t, 3, len(lines), stderr.String()) {
+			return
+		}
+		assert.True(t, strings.HasPrefix(lines[0], "stats: files=2 lines="), lines[0])
+		assert.Contains(t, lines[0], " matched=2 ")
+		assert.True(t, strings.HasPrefix(lines[1], "stats: worker=0 "), lines[1])
+		assert.True(t, strings.HasPrefix(lines[2], "stats: worker=1 "), lines[2])
+	})
+	t.Run("debug", func(t *testing.T) {
+		var stderr bytes.Buffer
+		cmd := exec.Command(g.command, "-debug", "snowflake", g.filePath("testmain0"))
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		fatalOnError(t, err)
+		assert.Equal(t, "snowflake\n", string(out))
+		assert.Contains(t, stderr.String(), `level=DEBUG msg="grep started"`)
+		assert.Contains(t, stderr.String(), `msg="grep finished"`)
+		assert.Contains(t, stderr.String(), "source="+g.filePath("testmain0"))
+	})
+	t.Run("source timeout", func(t *testing.T) {
+		fifo := g.filePath("source timeout fifo")
+		if err := exec.Command("mkfifo", fifo).Run(); err != nil {
+			t.Skipf("mkfifo: %v", err)
+		}
+		defer os.Remove(fifo)
+		var stderr bytes.Buffer
+		// The fifo without writers blocks the open
+		cmd := exec.Command(g.command, "-source-timeout", "100ms", "snowflake", g.filePath("testmain0"), fifo, g.filePath("testmain1"))
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+		assert.Equal(t, g.filePath("testmain0")+":snowflake\n"+g.filePath("testmain1")+":snowflake\n", string(out))
+		assert.Contains(t, stderr.String(), fifo+": ")
+		assert.Contains(t, stderr.String(), "timeout")
+	})
+	t.Run("checkpoint", func(t *testing.T) {
+		var (
+			b       strings.Builder
+			offsets = []int{0} // of the lines from 1
+		)
+		for i := 1; i <= 1000; i++ {
+			fmt.Fprintf(&b, "line %d\n", i)
+			offsets = append(offsets, b.Len())
+		}
+		fatalOnError(t, g.createFile("checkpoint", b.String()))
+		var (
+			file  = g.filePath("checkpoint")
+			state = g.filePath("checkpoint state")
+		)
+		defer os.Remove(state)
+		readState := func(t *testing.T) map[string]interface{} {
+			b, err := os.ReadFile(state)
+			fatalOnError(t, err)
+			var x struct {
+				Files map[string]map[string]interface{} `json:"files"`
+			}
+			fatalOnError(t, json.Unmarshal(b, &x))
+			return x.Files[file]
+		}
+		matches := func(from int) []string {
+			var r []string
+			for i := from; i <= 1000; i += 10 {
+				r = append(r, fmt.Sprintf("%d:line %d", i, i))
+			}
+			return r
+		}
+
+		test(t, []string{"-checkpoint", state, "-n", "7$", file}, matches(7))
+		assert.Equal(t, true, readState(t)["done"])
+		cmd := exec.Command(g.command, "-checkpoint", state, "-resume", "7$", file)
+		out, _ := cmd.Output()
+		assert.Equal(t, 1, cmd.ProcessState.ExitCode(), "the file is done")
+		assert.Equal(t, "", string(out))
+
+		// Interrupted after the line 500 with the line 507 printed
+		fatalOnError(t, os.WriteFile(state, []byte(fmt.Sprintf(`{"files":{%q:{"size":%d,"offset":%d,"line":500,"printed":[507]}}}`, file, b.Len(), offsets[500])), 0o600))
+		test(t, []string{"-checkpoint", state, "-resume", "-n", "-byte-offset", "7$", file}, func() []string {
+			var r []string
+			for i := 517; i <= 1000; i += 10 {
+				r = append(r, fmt.Sprintf("%d:%d:line %d", i, offsets[i-1], i))
+			}
+			return r
+		}())
+		assert.Equal(t, true, readState(t)["done"])
+
+		// Truncated since
+		fatalOnError(t, os.WriteFile(state, []byte(fmt.Sprintf(`{"files":{%q:{"size":%d,"offset":%d,"line":500}}}`, file, b.Len()+1, offsets[500])), 0o600))
+		test(t, []string{"-checkpoint", state, "-resume", "-n", "7$", file}, matches(7))
+
+		cmd = exec.Command(g.command, "-resume", "7$", file)
+		_ = cmd.Run()
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "-resume requires -checkpoint")
+
+		t.Run("compressed", func(t *testing.T) {
+			var z bytes.Buffer
+			w := gzip.NewWriter(&z)
+			_, _ = w.Write([]byte("line 7\n"))
+			fatalOnError(t, w.Close())
+			fatalOnError(t, g.createFile("checkpoint.gz", z.String()))
+			compressed := g.filePath("checkpoint.gz")
+
+			var stderr bytes.Buffer
+			cmd := exec.Command(g.command, "-checkpoint", state, "-strict", "-n", "7$", compressed, file)
+			cmd.Stderr = &stderr
+			out, _ := cmd.Output()
+			assert.Equal(t, 2, cmd.ProcessState.ExitCode(), "the compressed file is skipped")
+			assert.Equal(t, strings.Join(matches(7), "\n")+"\n", strings.ReplaceAll(string(out), file+":", ""))
+			assert.Contains(t, stderr.String(), compressed+": compressed file is skipped with -checkpoint")
+			assert.Contains(t, stderr.String(), `"reason":"compressed"`)
+
+			stderr.Reset()
+			cmd = exec.Command(g.command, "-checkpoint", state, "-decompress=false", "-strict", "line 7", compressed)
+			cmd.Stderr = &stderr
+			out, _ = cmd.Output()
+			assert.Equal(t, 0, cmd.ProcessState.ExitCode())
+			assert.Equal(t, "Binary file "+compressed+" matches\n", string(out), "grepped as it is")
+			assert.Equal(t, "", stderr.String())
+		})
+	})
+	t.Run("continue on errors", func(t *testing.T) {
+		var stderr bytes.Buffer
+		cmd := exec.Command(g.command, "snowflake", g.filePath("not exist"), g.filePath("testmain0"))
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, cmd.ProcessState.ExitCode())
+		assert.Equal(t, g.filePath("testmain0")+":snowflake\n", string(out))
+		assert.True(t, strings.HasPrefix(stderr.String(), g.filePath("not exist")+": open "), "without the line: %s", stderr.String())
+	})
+	t.Run("fail on", func(t *testing.T) {
+		exitCode := func(args ...string) int {
+			cmd := exec.Command(g.command, args...)
+			_ = cmd.Run()
+			return cmd.ProcessState.ExitCode()
+		}
+		var (
+			matches = []string{"snowflake", g.filePath("testmain0")}
+			noMatch = []string{"nothing matches", g.filePath("testmain0")}
+			failure = []string{"snowflake", g.filePath("not exist"), g.filePath("testmain0")}
+		)
+		for _, tc := range []struct {
+			failOn                    string
+			matches, noMatch, failure int
+		}{
+			{failOn: "error", matches: 0, noMatch: 0, failure: 2},
+			{failOn: "nomatch", matches: 0, noMatch: 1, failure: 0},
+			{failOn: "never", matches: 0, noMatch: 0, failure: 0},
+		} {
+			assert.Equal(t, tc.matches, exitCode(append([]string{"-fail-on", tc.failOn}, matches...)...), tc.failOn)
+			assert.Equal(t, tc.noMatch, exitCode(append([]string{"-fail-on", tc.failOn}, noMatch...)...), tc.failOn)
+			assert.Equal(t, tc.failure, exitCode(append([]string{"-fail-on", tc.failOn}, failure...)...), tc.failOn)
+		}
+		assert.Equal(t, 2, exitCode(append([]string{"-fail-on", "never", "-l", "-L"}, matches...)...), "invalid flags")
+		assert.Equal(t, 2, exitCode(append([]string{"-fail-on", "sometimes"}, matches...)...))
+	})
+	t.Run("transforms", func(t *testing.T) {
+		fatalOnError(t, g.createFile("transforms", "  Grand   THEFT Wumps \nsnowflake\n"))
+		test(t, []string{"-trim", "-lower", "-squeeze-space", "(?i)theft", g.filePath("transforms")}, []string{
+			"grand theft wumps",
+		})
+		test(t, []string{"-lower", "-o", "THEFT", g.filePath("transforms")}, []string{
+			"theft",
+		})
+	})
+	t.Run("progress", func(t *testing.T) {
+		fatalOnError(t, g.createFile("progress", strings.Repeat("progress line\n", 100000)))
+		cmd := exec.Command(g.command, "-progress", "-c", "line", g.filePath("progress"))
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		fatalOnError(t, err)
+		assert.Equal(t, "100000\n", string(out))
+		assert.NotContains(t, stderr.String(), "\n")
+	})
+	t.Run("checksum", func(t *testing.T) {
+		fatalOnError(t, g.createFile("checksum", "alpha\nbeta\n"))
+		cmd := exec.Command(g.command, "-checksum", "sha256", "-l", "alpha", g.filePath("checksum"))
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		fatalOnError(t, err)
+		assert.Equal(t, g.filePath("checksum")+"\n", string(out))
+		assert.Equal(t, "e49c81e2d2f84e259d40e2fb8192f3bcd198b355184845d76d8f58807d0d78ee  "+g.filePath("checksum")+"\n", stderr.String())
+
+		out, err = exec.Command(g.command, "-checksum", "sha256", "-format", "json", "beta", g.filePath("checksum")).Output()
+		fatalOnError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if assert.Equal(t, 2, len(lines)) {
+			assert.Contains(t, lines[0], `"text":"beta"`)
+			assert.Contains(t, lines[1], `"checksum":{"file":"`+g.filePath("checksum")+`","algorithm":"sha256","digest":"e49c81e2d2f84e259d40e2fb8192f3bcd198b355184845d76d8f58807d0d78ee"}`)
+		}
+	})
+	t.Run("changed only", func(t *testing.T) {
+		fatalOnError(t, os.MkdirAll(g.filePath("changed"), 0755))
+		fatalOnError(t, g.createFile("changed/a.txt", "alpha\n"))
+		fatalOnError(t, g.createFile("changed/b.txt", "alpha beta\n"))
+		run := func(args ...string) (string, string) {
+			cmd := exec.Command(g.command, args...)
+			var stderr strings.Builder
+			cmd.Stderr = &stderr
+			out, _ := cmd.Output()
+			return string(out), stderr.String()
+		}
+		manifest := g.filePath("changed.manifest")
+		out, sums := run("-checksum", "sha256", "-changed-only", manifest, "-root", g.filePath("changed"), "alpha")
+		assert.Equal(t, 2, strings.Count(out, "\n"), "no manifest greps all")
+		fatalOnError(t, g.createFile("changed.manifest", sums))
+
+		fatalOnError(t, g.createFile("changed/b.txt", "alpha gamma\n"))
+		out, next := run("-checksum", "sha256", "-changed-only", manifest, "-root", g.filePath("changed"), "alpha")
+		assert.Equal(t, g.filePath("changed/b.txt")+":alpha gamma\n", out)
+		assert.Equal(t, 2, strings.Count(next, "\n"), "the unchanged files are reported again")
+	})
+
+	t.Run("max count", func(t *testing.T) {
+		test(t, []string{"-j", "1", "-m", "2", "crim", g.filePath("testmain0")}, []string{
+			"a sunset is a sunset because it's crimson, beautiful, and I want it to be crimson",
+			"crime of using a side effect",
+		})
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		output := func(args ...string) string {
+			out, err := exec.Command(g.command, args...).Output()
+			fatalOnError(t, err)
+			return string(out)
+		}
+		fatalOnError(t, os.MkdirAll(g.filePath("replace"), 0755))
+		fatalOnError(t, g.createFile("replace/a.txt", "key=value\r\nnothing\nx=y"))
+		fatalOnError(t, g.createFile("replace/b.txt", "no pairs\n"))
+		fatalOnError(t, g.createFile("replace/c.bin", "k=v\x00\n"))
+		assert.Equal(t, "value=key\r\nnothing\ny=x", output("-replace", "$2=$1", `(\w+)=(\w+)`, g.filePath("replace/a.txt")))
+		assert.Equal(t, "key\nnothing\nx", output("-replace", "", "-line-ending", "lf", `=\w+$`, g.filePath("replace/a.txt")))
+
+		stat, err := os.Stat(g.filePath("replace/b.txt"))
+		fatalOnError(t, err)
+		assert.Equal(t, "", output("-in-place", "-transactional", "-replace", "$2=$1", "-root", g.filePath("replace"), `(\w+)=(\w+)`))
+		read := func(name string) string {
+			b, err := os.ReadFile(g.filePath(name))
+			fatalOnError(t, err)
+			return string(b)
+		}
+		assert.Equal(t, "value=key\r\nnothing\ny=x", read("replace/a.txt"))
+		assert.Equal(t, "no pairs\n", read("replace/b.txt"))
+		assert.Equal(t, "k=v\x00\n", read("replace/c.bin"), "binary files are skipped")
+		after, err := os.Stat(g.filePath("replace/b.txt"))
+		fatalOnError(t, err)
+		assert.Equal(t, stat.ModTime(), after.ModTime(), "files without matches are not rewritten")
+
+		cmd := exec.Command(g.command, "-replace", "x", "nothing matches", g.filePath("replace/b.txt"))
+		out, _ := cmd.Output()
+		assert.Equal(t, "no pairs\n", string(out))
+		assert.Equal(t, 1, cmd.ProcessState.ExitCode())
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		want := []string{
+			"grand theft wumps",
+			"snowflake",
+		}
+
+		cmd := exec.Command(g.command, `snowflake|wumps`)
+		stdin, err := cmd.StdinPipe()
+		fatalOnError(t, err)
+		stdout, err := cmd.StdoutPipe()
+		fatalOnError(t, err)
+		fatalOnError(t, cmd.Start())
+		go func() {
+			defer stdin.Close()
+			_, _ = io.WriteString(stdin, target)
+		}()
+		gotBytes, err := io.ReadAll(stdout)
+		fatalOnError(t, err)
+		fatalOnError(t, cmd.Wait())
+		got := strings.Split(strings.TrimSuffix(string(gotBytes), "\n"), "\n")
+		assert.Equal(t, len(want), len(got))
+		sort.Strings(want)
+		sort.Strings(got)
+		for i, w := range want {
+			g := got[i]
+			assert.Equal(t, w, g)
+		}
+	})
+}
+
+// bgzfBlock compresses data into a BGZF block.
+func bgzfBlock(t *testing.T, data []byte) []byte {
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	fatalOnError(t, err)
+	_, _ = w.Write(data)
+	fatalOnError(t, w.Close())
+	block := []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 6, 0, 'B', 'C', 2, 0, 0, 0}
+	binary.LittleEndian.PutUint16(block[16:], uint16(len(block)+deflated.Len()+8-1))
+	block = append(block, deflated.Bytes()...)
+	trailer := make([]byte, 8)
+	binary.LittleEndian.PutUint32(trailer, crc32.ChecksumIEEE(data))
+	binary.LittleEndian.PutUint32(trailer[4:], uint32(len(data)))
+	return append(block, trailer...)
+}
+
+type grepper struct {
+	workDir string // temporary directory
+	command string // gogrep binary path
+}
+
+// newGrepper creates a temporary directory, compiles gogrep and install it into the directory.
+func newGrepper() (*grepper, error) {
+	workDir, err := os.MkdirTemp("", "gogrep")
+	if err != nil {
+		return nil, err
+	}
+	command := filepath.Join(workDir, "gogrep")
+	if err := run("go", "build", "-o", command, "../cmd/gogrep"); err != nil {
+		return nil, err
+	}
+	return &grepper{
+		workDir: workDir,
+		command: command,
+	}, nil
+}
+
+func (s *grepper) close()                         { os.RemoveAll(s.workDir) }
+func (s *grepper) filePath(name string) string    { return filepath.Join(s.workDir, name) }
+func (s *grepper) copyFile(to, from string) error { return copyFile(s.filePath(to), s.filePath(from)) }
+func (s *grepper) createFile(name string, content string) error {
+	f, err := os.Create(s.filePath(name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+func copyFile(to, from string) error {
+	toFile, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	defer toFile.Close()
+	fromFile, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer fromFile.Close()
+	_, err = io.Copy(toFile, fromFile)
+	return err
+}
+
+func run(name string, arg ...string) error {
+	cmd := exec.Command(name, arg...)
+	cmd.Dir = "."
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func fatalOnError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
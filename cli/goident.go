@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"os"
+)
+
+// grepGoIdent prints the occurrences of the Go identifier in the files.
+// Reads stdin when files are empty.
+// Returns true if any occurrence is found, stopping at the first one with -q.
+func grepGoIdent(ctx context.Context, name string, files []string) (bool, error) {
+	if len(files) == 0 {
+		return grepGoIdentSource(ctx, name, "", os.Stdin)
+	}
+	var found bool
+	for _, file := range files {
+		ok, err := func() (bool, error) {
+			f, err := os.Open(file)
+			if err != nil {
+				return false, err
+			}
+			defer f.Close()
+			label := ""
+			if len(files) > 1 {
+				label = file
+			}
+			return grepGoIdentSource(ctx, name, label, f)
+		}()
+		found = found || ok
+		if err != nil {
+			return found, err
+		}
+		if found && *quiet {
+			break
+		}
+	}
+	return found, nil
+}
+
+// grepGoIdentSource tokenizes the source by go/scanner and prints the lines that contain the identifier
+// as line:column:text, prefixed with label if not empty.
+// Returns true if the identifier is found, stopping at the first one without printing with -q.
+func grepGoIdentSource(ctx context.Context, name, label string, source io.Reader) (bool, error) {
+	src, err := io.ReadAll(source)
+	if err != nil {
+		return false, err
+	}
+	var (
+		fset  = token.NewFileSet()
+		file  = fset.AddFile(label, fset.Base(), len(src))
+		sc    scanner.Scanner
+		lines = bytes.Split(src, []byte("\n"))
+	)
+	// Ignore syntax errors, tokenize as much as possible
+	sc.Init(file, src, nil, 0)
+	var found bool
+	for {
+		if err := ctx.Err(); err != nil {
+			return found, err
+		}
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			return found, nil
+		}
+		if tok != token.IDENT || lit != name {
+			continue
+		}
+		found = true
+		if *quiet {
+			return true, nil
+		}
+		p := fset.Position(pos)
+		text := bytes.TrimSuffix(lines[p.Line-1], []byte("\r"))
+		if label != "" {
+			fmt.Fprintf(stdout, "%s:", label)
+		}
+		fmt.Fprintf(stdout, "%d:%d:%s\n", p.Line, p.Column, text)
+	}
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+var messageLang = commandLine.String("lang", "", "The language of the usage, the flag help, the errors of the flag combinations and the notices like -stats: en or ja. The other errors are in English. Default is by $LC_ALL, $LC_MESSAGES or $LANG, English if unsupported.")
+
+// msg prints the messages in the language selected by setupMessages:
+// the usage, the flag help, the errors of the flag combinations and the notices into stderr.
+// The other errors are in English, e.g. the errors of the sources and the subcommands.
+var msg = message.NewPrinter(language.English)
+
+// messageTranslations are the translations of the messages keyed by the English ones.
+// The messages without translations are printed in English.
+var messageTranslations = map[language.Tag]map[string]string{
+	language.Japanese: {
+		usage: `gogrep の使い方
+  cat file | gogrep [flags] REGEX
+  gogrep [flags] REGEX files...
+  gogrep [flags] -e REGEX [-e REGEX...] [files...]
+  gogrep [flags] -f PATTERN_FILE [files...]
+  gogrep [flags] -root DIR[:OPTS] [-root DIR[:OPTS]...] REGEX [files...]
+  gogrep [flags] -replace TEMPLATE [-in-place] REGEX [files...]
+  gogrep -go-ident NAME [files...]
+  find . -print0 | gogrep [flags] -0 -files-from - REGEX
+  gogrep engines [bench FILE REGEX]
+  gogrep capabilities
+  gogrep image [flags] IMAGE REGEX
+  gogrep proc [flags] REGEX
+  gogrep diff-results OLD NEW
+  gogrep worker < REQUEST
+  gogrep serve [-listen ADDR] [-allow DIR...]
+  gogrep version [-verbose]
+  gogrep gen-docs man|markdown
+
+注意:
+既定のフラグは設定ファイル $GOGREP_CONFIG_PATH または ~/.config/gogrep/config から 1 行に 1 つずつ読み込まれ、
+次に $GOGREP_OPTS が読み込まれます。コマンドラインのフラグはそれらを上書きします。-no-config はそれらを無視します。
+マッチした行は入力に現れた順に出力されるとは限りません。
+終了ステータスは、いずれかの行が選択されれば 0、選択されなければ 1、エラーが起きれば 2 です。
+フラグ:`,
+		"-%s requires -%s":                                          "-%s には -%s が必要です",
+		" and ":                                                     " と ",
+		"%s are exclusive":                                          "%s は同時に指定できません",
+		"gogrep: %d contents skipped\n":                             "gogrep: %d 件の内容をスキップしました\n",
+		"gogrep: cannot write diagnostics: %v\n":                    "gogrep: 診断情報を書き込めません: %v\n",
+		"gogrep: diagnostics written to %s\n":                       "gogrep: 診断情報を %s に書き込みました\n",
+		"gogrep: %s: input file is also the output\n":               "gogrep: %s: 入力ファイルが出力先でもあります\n",
+		"gogrep: %s: compressed file is skipped with -checkpoint\n": "gogrep: %s: -checkpoint では圧縮ファイルをスキップします\n",
+		"stats: files=%d lines=%d bytes=%d matched=%d elapsed=%s\n": "統計: ファイル=%d 行=%d バイト=%d マッチ=%d 経過=%s\n",
+		"stats: worker=%d chunks=%d busy=%s utilization=%.2f\n":     "統計: ワーカー=%d チャンク=%d 稼働=%s 使用率=%.2f\n",
+		"stats: canceled=%s\n":                                      "統計: 中断=%s\n",
+		"unknown language %s":                                       "不明な言語 %s",
+		// Flags
+		"The language of the usage, the flag help, the errors of the flag combinations and the notices like -stats: en or ja. The other errors are in English. Default is by $LC_ALL, $LC_MESSAGES or $LANG, English if unsupported.": "使い方、フラグの説明、フラグの組み合わせのエラー、および -stats などの通知の言語: en または ja。その他のエラーは英語です。既定は $LC_ALL、$LC_MESSAGES または $LANG により、未対応なら英語です。",
+		"Use the pattern. Can be specified multiple times. A line matches if any pattern matches.":                                                                                                                                    "パターンを使います。複数回指定できます。いずれかのパターンにマッチした行が選択されます。",
+		"Print only the matched parts of lines.":                                                              "行のマッチした部分だけを出力します。",
+		"Print nothing and exit immediately with zero status if any match is found.":                          "何も出力せず、マッチが見つかればただちにステータス 0 で終了します。",
+		"Print the line numbers.":                                                                             "行番号を出力します。",
+		"Print only the names of the files that contain matches. Stops reading a file at the first match.":    "マッチを含むファイルの名前だけを出力します。最初のマッチでファイルの読み込みを止めます。",
+		"Print only the names of the files that contain no matches. Stops reading a file at the first match.": "マッチを含まないファイルの名前だけを出力します。最初のマッチでファイルの読み込みを止めます。",
+		"The number of grep workers. Positive number is valid.":                                               "grep のワーカー数。正の数が有効です。",
+	},
+}
+
+// newMessageCatalog returns the catalog of messageTranslations.
+func newMessageCatalog() catalog.Catalog {
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+	for tag, translations := range messageTranslations {
+		for key, x := range translations {
+			_ = b.SetString(tag, key, x)
+		}
+	}
+	return b
+}
+
+// setupMessages selects the language of the messages by -lang or the environment.
+// Unknown -lang is an error, while the unsupported language of the environment means English.
+func setupMessages() error {
+	name := *messageLang
+	if name == "" {
+		name = envLang()
+	}
+	var (
+		cat = newMessageCatalog()
+		tag = language.English
+	)
+	if name != "" {
+		var (
+			c         = language.No
+			supported = append([]language.Tag{language.English}, cat.Languages()...)
+		)
+		if t, err := language.Parse(name); err == nil {
+			var i int
+			_, i, c = language.NewMatcher(supported).Match(t)
+			if c != language.No {
+				tag = supported[i]
+			}
+		}
+		if c == language.No && *messageLang != "" {
+			return errors.New(msg.Sprintf("unknown language %s", name))
+		}
+	}
+	msg = message.NewPrinter(tag, message.Catalog(cat))
+	return nil
+}
+
+// envLang returns the locale of the messages like ja_JP.UTF-8 as ja-JP.
+func envLang() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(key)
+		if v == "" {
+			continue
+		}
+		if i := strings.IndexAny(v, ".@"); i >= 0 {
+			v = v[:i]
+		}
+		if v == "C" || v == "POSIX" {
+			return ""
+		}
+		return strings.ReplaceAll(v, "_", "-")
+	}
+	return ""
+}
+
+// translateText returns the translation of the text that is not a format, e.g. the usage of a flag.
+func translateText(s string) string {
+	return msg.Sprintf(strings.ReplaceAll(s, "%", "%%"))
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupMessages(t *testing.T) {
+	defer func() {
+		*messageLang = ""
+		_ = setupMessages()
+	}()
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		t.Setenv(key, "")
+	}
+
+	for _, tc := range []struct {
+		title string
+		lang  string
+		env   string
+		want  string
+		err   bool
+	}{
+		{
+			title: "default",
+			want:  "-a requires -b",
+		},
+		{
+			title: "flag",
+			lang:  "ja",
+			want:  "-a には -b が必要です",
+		},
+		{
+			title: "env",
+			env:   "ja_JP.UTF-8",
+			want:  "-a には -b が必要です",
+		},
+		{
+			title: "flag overrides env",
+			lang:  "en",
+			env:   "ja_JP.UTF-8",
+			want:  "-a requires -b",
+		},
+		{
+			title: "unsupported env",
+			env:   "fr_FR.UTF-8",
+			want:  "-a requires -b",
+		},
+		{
+			title: "posix",
+			env:   "C",
+			want:  "-a requires -b",
+		},
+		{
+			title: "unsupported flag",
+			lang:  "fr",
+			err:   true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			*messageLang = tc.lang
+			t.Setenv("LANG", tc.env)
+			err := setupMessages()
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, msg.Sprintf("-%s requires -%s", "a", "b"))
+		})
+	}
+}
+
+func TestTranslateText(t *testing.T) {
+	assert.Equal(t, "within 1% error", translateText("within 1% error"))
+}
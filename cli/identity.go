@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/berquerant/gogrep"
+)
+
+// fileIdentities skips the targets that are the output files, e.g. the redirected stdout under a root,
+// and the targets of the files grepped already with -dedup, e.g. the hard links.
+type fileIdentities struct {
+	outputs map[gogrep.FileID]bool
+	seen    map[gogrep.FileID]bool // nil without -dedup
+}
+
+// targetIdentities is nil if no targets are skipped.
+var targetIdentities *fileIdentities
+
+// newFileIdentities returns the identities of the outputs, the redirected stdout, -output, -sqlite and -report-skipped.
+// Returns nil if nothing to skip.
+func newFileIdentities() *fileIdentities {
+	if !isHostFS() {
+		return nil
+	}
+	s := &fileIdentities{
+		outputs: map[gogrep.FileID]bool{},
+	}
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode().IsRegular() {
+		if id, err := gogrep.FileIDOf(os.Stdout); err == nil {
+			s.outputs[id] = true
+		}
+	}
+	for _, name := range []string{*outputFile, *sqliteFile, *reportSkipped} {
+		if name == "" || name == stdinPath {
+			continue
+		}
+		if id, err := gogrep.StatFileID(name); err == nil {
+			s.outputs[id] = true
+		}
+	}
+	if *dedupFiles {
+		s.seen = map[gogrep.FileID]bool{}
+	}
+	if len(s.outputs) == 0 && s.seen == nil {
+		return nil
+	}
+	return s
+}
+
+// skip returns true if the target should not be grepped.
+func (s *fileIdentities) skip(t *target) bool {
+	if s == nil || t.path == "" || t.reader != nil {
+		return false
+	}
+	id, err := gogrep.StatFileID(t.path)
+	if err != nil {
+		return false // reported by the grep
+	}
+	if s.outputs[id] {
+		msg.Fprintf(os.Stderr, "gogrep: %s: input file is also the output\n", t.path)
+		skipReport.reportTarget(t, skipOutput)
+		return true
+	}
+	if s.seen == nil {
+		return false
+	}
+	if s.seen[id] {
+		skipReport.reportTarget(t, skipDuplicate)
+		return true
+	}
+	s.seen[id] = true
+	return false
+}
+
+// filterTargets returns the targets not skipped.
+func (s *fileIdentities) filterTargets(targets []*target) []*target {
+	if s == nil {
+		return targets
+	}
+	r := targets[:0]
+	for _, t := range targets {
+		if !s.skip(t) {
+			r = append(r, t)
+		}
+	}
+	return r
+}
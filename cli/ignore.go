@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRules skip the files under a root by the ignore files like ripgrep:
+// .gitignore and .git/info/exclude in a git repository, and .ignore anywhere.
+// The ignore files of the directories above the root up to the top of the repository apply too,
+// and the patterns of the deeper directories take precedence.
+// The .git directories are skipped.
+type ignoreRules struct {
+	root    string
+	base    string // the top of the repository, or the root outside repositories
+	rootRel string // the slash separated path of the root relative to base
+	git     bool
+	cache   map[string][]*pathPattern // by the slash separated directory relative to base
+}
+
+// newIgnoreRules returns the rules of the ignore files for the root.
+func newIgnoreRules(root string) *ignoreRules {
+	s := &ignoreRules{
+		root:  root,
+		base:  root,
+		cache: map[string][]*pathPattern{},
+	}
+	abs := root
+	if isHostFS() {
+		if x, err := filepath.Abs(root); err == nil {
+			abs = x
+		}
+	}
+	top, ok := findGitTop(abs)
+	if !ok {
+		return s
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return s
+	}
+	s.git = true
+	s.base = top
+	if rel != "." {
+		s.rootRel = filepath.ToSlash(rel)
+	}
+	return s
+}
+
+// findGitTop returns the nearest directory that contains .git from dir to the top.
+func findGitTop(dir string) (string, bool) {
+	for {
+		if _, err := fs.Stat(fileSystem, filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// ignored reports whether the file or the directory under the root should be skipped.
+// The directories are expected to be pruned, so the parents of the file are not matched.
+func (s *ignoreRules) ignored(name string, isDir bool) bool {
+	if s == nil {
+		return false
+	}
+	if isDir && filepath.Base(name) == ".git" {
+		return true
+	}
+	rel, err := filepath.Rel(s.root, name)
+	if err != nil {
+		return false
+	}
+	rel = path.Join(s.rootRel, filepath.ToSlash(rel))
+	var (
+		ignored bool
+		dir     string
+		parts   = strings.Split(rel, "/")
+	)
+	// From the base to the parent of the file
+	for i := range parts {
+		target := strings.Join(parts[i:], "/")
+		for _, p := range s.patterns(dir) {
+			if p.match(target, isDir) {
+				ignored = !p.negate
+			}
+		}
+		dir = path.Join(dir, parts[i])
+	}
+	return ignored
+}
+
+// patterns returns the patterns of the ignore files in the directory in order of precedence.
+func (s *ignoreRules) patterns(dir string) []*pathPattern {
+	if ps, ok := s.cache[dir]; ok {
+		return ps
+	}
+	var (
+		ps    []*pathPattern
+		names []string
+	)
+	if s.git {
+		if dir == "" {
+			names = append(names, ".git/info/exclude")
+		}
+		names = append(names, ".gitignore")
+	}
+	names = append(names, ".ignore")
+	for _, name := range names {
+		data, err := fs.ReadFile(fileSystem, filepath.Join(s.base, filepath.FromSlash(dir), filepath.FromSlash(name)))
+		if err != nil {
+			continue // not exist or unreadable
+		}
+		ps = append(ps, parseIgnoreFile(data)...)
+	}
+	s.cache[dir] = ps
+	return ps
+}
+
+// parseIgnoreFile returns the patterns of the ignore file.
+// The invalid patterns are skipped.
+func parseIgnoreFile(data []byte) []*pathPattern {
+	var (
+		ps []*pathPattern
+		sc = bufio.NewScanner(bytes.NewReader(data))
+	)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if p, err := compilePathPattern(line); err == nil {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	defer func() {
+		fileSystem = hostFS{}
+		*hiddenFiles = false
+	}()
+	*hiddenFiles = true // list the ignore files too
+	walk := func(t *testing.T, path string) []string {
+		r := &root{
+			path:   path,
+			ignore: newIgnoreRules(path),
+		}
+		var got []string
+		assert.Nil(t, r.walk(func(t *target) error {
+			got = append(got, t.path)
+			return nil
+		}))
+		return got
+	}
+
+	t.Run("git repository", func(t *testing.T) {
+		fileSystem = fstest.MapFS{
+			"repo/.git/HEAD":          {Data: []byte("ref")},
+			"repo/.git/info/exclude":  {Data: []byte("*.tmp\n")},
+			"repo/.gitignore":         {Data: []byte("# comment\n*.log\n!keep.log\n/build/\n")},
+			"repo/a.go":               {Data: []byte("a")},
+			"repo/a.log":              {Data: []byte("a")},
+			"repo/a.tmp":              {Data: []byte("a")},
+			"repo/keep.log":           {Data: []byte("a")},
+			"repo/build/b.go":         {Data: []byte("b")},
+			"repo/src/build/c.go":     {Data: []byte("c")},
+			"repo/src/.gitignore":     {Data: []byte("secret.txt\n!debug.log\n")},
+			"repo/src/.ignore":        {Data: []byte("generated/\n")},
+			"repo/src/debug.log":      {Data: []byte("d")},
+			"repo/src/secret.txt":     {Data: []byte("s")},
+			"repo/src/d.go":           {Data: []byte("d")},
+			"repo/src/generated/e.go": {Data: []byte("e")},
+		}
+		assert.Equal(t, []string{
+			"repo/.gitignore",
+			"repo/a.go",
+			"repo/keep.log",
+			"repo/src/.gitignore",
+			"repo/src/.ignore",
+			"repo/src/build/c.go",
+			"repo/src/d.go",
+			"repo/src/debug.log",
+		}, walk(t, "repo"))
+		assert.Equal(t, []string{
+			"repo/src/.gitignore",
+			"repo/src/.ignore",
+			"repo/src/build/c.go",
+			"repo/src/d.go",
+			"repo/src/debug.log",
+		}, walk(t, "repo/src"), "the ignore files above the root apply")
+	})
+
+	t.Run("outside repositories", func(t *testing.T) {
+		fileSystem = fstest.MapFS{
+			"dir/.gitignore": {Data: []byte("*.log\n")},
+			"dir/.ignore":    {Data: []byte("*.tmp\n")},
+			"dir/a.log":      {Data: []byte("a")},
+			"dir/a.tmp":      {Data: []byte("a")},
+		}
+		assert.Equal(t, []string{"dir/.gitignore", "dir/.ignore", "dir/a.log"}, walk(t, "dir"))
+	})
+}
@@ -0,0 +1,350 @@
+package cli
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/berquerant/gogrep"
+)
+
+const imageUsage = `Usage of gogrep image
+  gogrep image [flags] IMAGE REGEX
+  gogrep image [flags] -e REGEX [-e REGEX...] IMAGE
+    Grep the files of the image, a tarball by docker save or an OCI image layout directory or tarball.
+    The layers are merged respecting the whiteouts and the matches are labeled with the layer digest as the root.
+    Pull the image in advance, e.g. docker save -o image.tar ubuntu:22.04.
+    The flags are the same as gogrep.`
+
+// imageRef is the image of gogrep image.
+var imageRef string
+
+func runImage(args []string) error {
+	operands, rest, err := parseGrepSubcommand(args, imageUsage, 1)
+	if err != nil {
+		return err
+	}
+	imageRef = operands[0]
+	return grepStatus(runGrep(rest))
+}
+
+// grepImage greps the files of the merged layers of the image.
+func grepImage(ctx context.Context, patterns []string, ref string) error {
+	layers, err := loadImage(ref)
+	if err != nil {
+		return err
+	}
+	visible, err := visibleFiles(layers)
+	if err != nil {
+		return err
+	}
+	for i, layer := range layers {
+		if len(visible[i]) == 0 {
+			continue
+		}
+		if err := layer.walk(func(name string, h *tar.Header, r io.Reader) error {
+			if !visible[i][name] || !h.FileInfo().Mode().IsRegular() {
+				return nil
+			}
+			return grepSources(ctx, patterns, []*target{{
+				path:   name,
+				root:   layer.digest,
+				reader: r,
+			}})
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageLayer is a layer of an image.
+type imageLayer struct {
+	digest string
+	open   func() (io.ReadCloser, error)
+}
+
+// walk calls fn for each entry of the layer with the cleaned path.
+func (s *imageLayer) walk(fn func(name string, h *tar.Header, r io.Reader) error) error {
+	f, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d := gogrep.NewDecodingReader(f) // layers are usually gzipped
+	defer d.Close()
+	tr := tar.NewReader(d)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read layer %s: %w", s.digest, err)
+		}
+		name := strings.TrimPrefix(path.Clean("/"+h.Name), "/")
+		if name == "" {
+			continue
+		}
+		if err := fn(name, h, tr); err != nil {
+			return err
+		}
+	}
+}
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// visibleFiles returns the paths of the entries of each layer that remain in the merged filesystem.
+// An entry is hidden by the same path in the upper layers,
+// a non-directory at its parent in the upper layers
+// and the whiteouts of it or its parent in the upper layers.
+func visibleFiles(layers []*imageLayer) ([]map[string]bool, error) {
+	var (
+		r       = make([]map[string]bool, len(layers))
+		upper   = map[string]bool{} // path to whether it is a directory
+		deleted = map[string]bool{}
+		opaque  = map[string]bool{}
+	)
+	hidden := func(name string) bool {
+		if _, ok := upper[name]; ok || deleted[name] {
+			return true
+		}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if isDir, ok := upper[dir]; (ok && !isDir) || deleted[dir] || opaque[dir] {
+				return true
+			}
+		}
+		return false
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		var (
+			files        = map[string]bool{}
+			entries      = map[string]bool{}
+			layerDeleted []string
+			layerOpaque  []string
+		)
+		if err := layers[i].walk(func(name string, h *tar.Header, _ io.Reader) error {
+			dir, base := path.Split(name)
+			dir = path.Clean(dir)
+			switch {
+			case base == whiteoutOpaque:
+				layerOpaque = append(layerOpaque, dir)
+			case strings.HasPrefix(base, whiteoutPrefix):
+				layerDeleted = append(layerDeleted, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			case !hidden(name):
+				files[name] = true
+				entries[name] = h.Typeflag == tar.TypeDir
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		r[i] = files
+		// The whiteouts and the entries apply to the lower layers
+		for name, isDir := range entries {
+			upper[name] = isDir
+		}
+		for _, name := range layerDeleted {
+			deleted[name] = true
+		}
+		for _, name := range layerOpaque {
+			opaque[name] = true
+		}
+	}
+	return r, nil
+}
+
+// loadImage returns the layers of the image from bottom to top.
+// The image is a tarball by docker save, or an OCI image layout directory or tarball.
+func loadImage(ref string) ([]*imageLayer, error) {
+	info, err := os.Stat(ref)
+	if err != nil {
+		return nil, fmt.Errorf("image %s is not a local file or directory, save it by e.g. docker save -o image.tar %s: %w", ref, ref, err)
+	}
+	var store blobStore
+	if info.IsDir() {
+		store = dirStore(ref)
+	} else {
+		s, err := newTarStore(ref)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	}
+	if layers, err := loadDockerManifest(store); !errors.Is(err, os.ErrNotExist) {
+		return layers, err
+	}
+	return loadOCIIndex(store)
+}
+
+// blobStore provides the files of an image.
+type blobStore interface {
+	open(name string) (io.ReadCloser, error)
+}
+
+type dirStore string
+
+func (s dirStore) open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(s), filepath.FromSlash(name)))
+}
+
+// tarStore provides the members of a tarball.
+type tarStore struct {
+	f       *os.File
+	members map[string][2]int64 // offset and size
+}
+
+func newTarStore(file string) (*tarStore, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	s := &tarStore{
+		f:       f,
+		members: map[string][2]int64{},
+	}
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("cannot read image %s: %w", file, err)
+		}
+		// The reader of tar does not read ahead
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.members[strings.TrimPrefix(path.Clean("/"+h.Name), "/")] = [2]int64{offset, h.Size}
+	}
+}
+
+func (s *tarStore) open(name string) (io.ReadCloser, error) {
+	m, ok := s.members[name]
+	if !ok {
+		return nil, fmt.Errorf("open %s: %w", name, os.ErrNotExist)
+	}
+	return io.NopCloser(io.NewSectionReader(s.f, m[0], m[1])), nil
+}
+
+func readJSON(store blobStore, name string, v interface{}) error {
+	f, err := store.open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("cannot read %s: %w", name, err)
+	}
+	return nil
+}
+
+// loadDockerManifest loads the first image of manifest.json by docker save.
+func loadDockerManifest(store blobStore) ([]*imageLayer, error) {
+	var manifest []struct {
+		Config string
+		Layers []string
+	}
+	if err := readJSON(store, "manifest.json", &manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest) == 0 {
+		return nil, errors.New("manifest.json has no images")
+	}
+	var config struct {
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	}
+	_ = readJSON(store, manifest[0].Config, &config) // only for the digests
+	layers := make([]*imageLayer, len(manifest[0].Layers))
+	for i, name := range manifest[0].Layers {
+		digest := name
+		if ids := config.RootFS.DiffIDs; len(ids) == len(layers) {
+			digest = ids[i]
+		}
+		layers[i] = &imageLayer{
+			digest: digest,
+			open:   func() (io.ReadCloser, error) { return store.open(name) },
+		}
+	}
+	return layers, nil
+}
+
+// ociDescriptor is a content descriptor of OCI.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+func (s *ociDescriptor) blob() string { return "blobs/" + strings.Replace(s.Digest, ":", "/", 1) }
+
+// loadOCIIndex loads the image for the platform from index.json of the OCI image layout.
+func loadOCIIndex(store blobStore) ([]*imageLayer, error) {
+	var index struct {
+		Manifests []*ociDescriptor `json:"manifests"`
+	}
+	if err := readJSON(store, "index.json", &index); err != nil {
+		return nil, fmt.Errorf("image has neither manifest.json nor index.json: %w", err)
+	}
+	for {
+		d := selectManifest(index.Manifests)
+		if d == nil {
+			return nil, errors.New("image has no manifests")
+		}
+		if strings.HasSuffix(d.MediaType, "index.v1+json") || strings.HasSuffix(d.MediaType, "manifest.list.v2+json") {
+			index.Manifests = nil
+			if err := readJSON(store, d.blob(), &index); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var manifest struct {
+			Layers []*ociDescriptor `json:"layers"`
+		}
+		if err := readJSON(store, d.blob(), &manifest); err != nil {
+			return nil, err
+		}
+		layers := make([]*imageLayer, len(manifest.Layers))
+		for i, l := range manifest.Layers {
+			name := l.blob()
+			layers[i] = &imageLayer{
+				digest: l.Digest,
+				open:   func() (io.ReadCloser, error) { return store.open(name) },
+			}
+		}
+		return layers, nil
+	}
+}
+
+// selectManifest returns the manifest for the current platform or the first one.
+func selectManifest(manifests []*ociDescriptor) *ociDescriptor {
+	for _, m := range manifests {
+		if p := m.Platform; p != nil && p.OS == "linux" && p.Architecture == runtime.GOARCH {
+			return m
+		}
+	}
+	if len(manifests) == 0 {
+		return nil
+	}
+	return manifests[0]
+}
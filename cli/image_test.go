@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// layerTar returns a tarball of the files, the directories end with a slash.
+func layerTar(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for name, body := range files {
+		h := &tar.Header{
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(body)),
+			Typeflag: tar.TypeReg,
+		}
+		if name[len(name)-1] == '/' {
+			h.Typeflag = tar.TypeDir
+			h.Size = 0
+		}
+		assert.Nil(t, tw.WriteHeader(h))
+		_, err := tw.Write([]byte(body[:h.Size]))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, tw.Close())
+	return b.Bytes()
+}
+
+func TestImage(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		lower = layerTar(t, map[string]string{
+			"etc/":          "",
+			"etc/passwd":    "root",
+			"etc/shadow":    "secret",
+			"opt/app/a.txt": "a",
+			"opt/app/b.txt": "b",
+			"usr/bin/tool":  "v1",
+		})
+		upper = layerTar(t, map[string]string{
+			"etc/.wh.shadow":         "",
+			"opt/app/.wh..wh..opq":   "",
+			"opt/app/c.txt":          "c",
+			"usr/bin/tool":           "v2",
+			"./var/log/messages.log": "log",
+		})
+	)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(upper)
+	assert.Nil(t, zw.Close())
+
+	writeBlob := func(data []byte) string {
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+		p := filepath.Join(dir, "blobs", "sha256", digest[len("sha256:"):])
+		assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.Nil(t, os.WriteFile(p, data, 0o644))
+		return digest
+	}
+	writeJSON := func(v interface{}) []byte {
+		b, err := json.Marshal(v)
+		assert.Nil(t, err)
+		return b
+	}
+	lowerDigest, upperDigest := writeBlob(lower), writeBlob(gz.Bytes())
+	manifest := writeBlob(writeJSON(map[string]interface{}{
+		"layers": []map[string]string{
+			{"mediaType": "application/vnd.oci.image.layer.v1.tar", "digest": lowerDigest},
+			{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": upperDigest},
+		},
+	}))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "index.json"), writeJSON(map[string]interface{}{
+		"manifests": []map[string]string{
+			{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": manifest},
+		},
+	}), 0o644))
+
+	layers, err := loadImage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 2, len(layers)) {
+		return
+	}
+	assert.Equal(t, lowerDigest, layers[0].digest)
+	assert.Equal(t, upperDigest, layers[1].digest)
+	visible, err := visibleFiles(layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []map[string]bool{
+		{
+			"etc":        true,
+			"etc/passwd": true,
+		},
+		{
+			"opt/app/c.txt":        true,
+			"usr/bin/tool":         true,
+			"var/log/messages.log": true,
+		},
+	}, visible)
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// junitFormatter writes the JUnit XML report at the end,
+// where each matched file is a failing test case with the matches.
+type junitFormatter struct {
+	file     string // stdout if empty
+	patterns []string
+	cases    []*junitTestCase
+	index    map[string]int // the index of the case by the file
+}
+
+func newJunitFormatter(file string, patterns []string) *junitFormatter {
+	return &junitFormatter{
+		file:     file,
+		patterns: patterns,
+		index:    map[string]int{},
+	}
+}
+
+type (
+	junitTestSuites struct {
+		XMLName xml.Name         `xml:"testsuites"`
+		Suites  []junitTestSuite `xml:"testsuite"`
+	}
+	junitTestSuite struct {
+		Name       string           `xml:"name,attr"`
+		Tests      int              `xml:"tests,attr"`
+		Failures   int              `xml:"failures,attr"`
+		Timestamp  string           `xml:"timestamp,attr,omitempty"`
+		Time       string           `xml:"time,attr,omitempty"`
+		Hostname   string           `xml:"hostname,attr,omitempty"`
+		Properties *junitProperties `xml:"properties"`
+		Cases      []*junitTestCase `xml:"testcase"`
+	}
+	// junitProperties are the metadata of the run with -run-metadata.
+	junitProperties struct {
+		Properties []junitProperty `xml:"property"`
+	}
+	junitProperty struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	}
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		Classname string        `xml:"classname,attr"`
+		File      string        `xml:"file,attr,omitempty"`
+		Line      int           `xml:"line,attr,omitempty"`
+		Failure   *junitFailure `xml:"failure"`
+		matches   int
+		lines     []string
+	}
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+func (s *junitFormatter) format(_ io.Writer, m *match) error {
+	name := m.File
+	i, ok := s.index[name]
+	if !ok {
+		classname := m.Root
+		if classname == "" {
+			classname = "gogrep"
+		}
+		i = len(s.cases)
+		s.index[name] = i
+		s.cases = append(s.cases, &junitTestCase{
+			Name:      name,
+			Classname: classname,
+			File:      m.File,
+			Line:      m.Line,
+		})
+	}
+	c := s.cases[i]
+	c.matches++
+	if m.Binary {
+		c.lines = append(c.lines, fmt.Sprintf("Binary file %s matches", name))
+	} else {
+		c.lines = append(c.lines, fmt.Sprintf("%s:%d: %s", name, m.Line, m.Text))
+	}
+	return nil
+}
+
+func (s *junitFormatter) close() error {
+	pattern := strings.Join(s.patterns, " | ")
+	for _, c := range s.cases {
+		c.Failure = &junitFailure{
+			Message: fmt.Sprintf("%d matches of %s", c.matches, pattern),
+			Type:    "match",
+			Text:    strings.Join(c.lines, "\n"),
+		}
+	}
+	suite := junitTestSuite{
+		Name:     "gogrep",
+		Tests:    len(s.cases),
+		Failures: len(s.cases),
+		Cases:    s.cases,
+	}
+	if runInfo != nil {
+		runInfo.finish()
+		suite.Timestamp = runInfo.Start.Format("2006-01-02T15:04:05")
+		suite.Time = fmt.Sprintf("%.3f", runInfo.End.Sub(runInfo.Start).Seconds())
+		suite.Hostname = runInfo.Hostname
+		suite.Properties = &junitProperties{}
+		for _, p := range runInfo.pairs() {
+			suite.Properties.Properties = append(suite.Properties.Properties, junitProperty{
+				Name:  p[0],
+				Value: p[1],
+			})
+		}
+	}
+	b, err := xml.MarshalIndent(&junitTestSuites{
+		Suites: []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append([]byte(xml.Header), append(b, '\n')...)
+	if s.file == "" {
+		_, err = stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(s.file, b, 0o644)
+}
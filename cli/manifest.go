@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+var changedOnly = commandLine.String("changed-only", "", "Grep only the files whose content changed since the checksum manifest, the output of -checksum of a previous run, e.g. the nightly scans of the mostly static trees. The files not in the manifest are grepped. The digests of the unchanged files are reported again with -checksum to make the next manifest. A missing manifest greps all the files.")
+
+// manifestEntry is a digest of a file in the manifest of -changed-only.
+type manifestEntry struct {
+	algorithm string
+	digest    string
+}
+
+// checksumManifest is the digests of the files by a previous -checksum.
+type checksumManifest struct {
+	entries map[string]*manifestEntry
+}
+
+// changedManifest is nil without -changed-only.
+var changedManifest *checksumManifest
+
+// loadManifest reads the output of -checksum, the lines like sha256sum or the lines of -format json.
+// The other lines are ignored. A missing file is an empty manifest.
+func loadManifest(file string) (*checksumManifest, error) {
+	s := &checksumManifest{
+		entries: map[string]*manifestEntry{},
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	if err := s.read(f); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *checksumManifest) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "{") {
+			var x jsonChecksumRecord
+			if err := json.Unmarshal([]byte(line), &x); err != nil || x.Checksum.File == "" {
+				continue // a match
+			}
+			s.entries[x.Checksum.File] = &manifestEntry{
+				algorithm: x.Checksum.Algorithm,
+				digest:    x.Checksum.Digest,
+			}
+			continue
+		}
+		// HEX  FILE, or HEX *FILE of the binary mode of sha256sum
+		digest, file, ok := strings.Cut(line, " ")
+		if !ok || len(file) < 2 || (file[0] != ' ' && file[0] != '*') {
+			continue
+		}
+		if _, err := hex.DecodeString(digest); err != nil {
+			continue
+		}
+		var algorithm string
+		for name, newHash := range checksumAlgorithms {
+			if newHash().Size()*2 == len(digest) {
+				algorithm = name
+			}
+		}
+		if algorithm == "" {
+			continue
+		}
+		s.entries[file[1:]] = &manifestEntry{
+			algorithm: algorithm,
+			digest:    strings.ToLower(digest),
+		}
+	}
+	return scanner.Err()
+}
+
+// unchanged returns true if the content of the target has the digest in the manifest.
+// With -checksum, the digest is reported again and the digest by the other algorithm is treated as changed.
+func (s *checksumManifest) unchanged(t *target) bool {
+	if s == nil || t.path == "" || t.reader != nil {
+		return false
+	}
+	e, ok := s.entries[t.name()]
+	if !ok {
+		return false
+	}
+	if *checksumAlgorithm != "" && *checksumAlgorithm != e.algorithm {
+		return false
+	}
+	newHash, ok := checksumAlgorithms[e.algorithm]
+	if !ok {
+		return false
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return false // reported by the grep
+	}
+	defer f.Close()
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	if hex.EncodeToString(h.Sum(nil)) != e.digest {
+		return false
+	}
+	if *checksumAlgorithm != "" {
+		if err := reportChecksum(t, e.digest); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// filterTargets returns the targets changed.
+func (s *checksumManifest) filterTargets(targets []*target) []*target {
+	if s == nil {
+		return targets
+	}
+	r := targets[:0]
+	for _, t := range targets {
+		if s.unchanged(t) {
+			skipReport.reportTarget(t, skipUnchanged)
+			continue
+		}
+		r = append(r, t)
+	}
+	return r
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumManifest(t *testing.T) {
+	const digest = "e49c81e2d2f84e259d40e2fb8192f3bcd198b355184845d76d8f58807d0d78ee" // sha256 of alpha\nbeta\n
+
+	t.Run("read", func(t *testing.T) {
+		s := &checksumManifest{entries: map[string]*manifestEntry{}}
+		assert.Nil(t, s.read(strings.NewReader(strings.Join([]string{
+			digest + "  text.txt",
+			"E49C81E2D2F84E259D40E2FB8192F3BCD198B355184845D76D8F58807D0D78EE *binary mode.txt",
+			`{"file":"match.txt","line":1,"offset":0,"text":"alpha"}`,
+			`{"checksum":{"file":"json.txt","algorithm":"sha512","digest":"00"}}`,
+			"not a digest  file.txt",
+			"abcd  short.txt",
+		}, "\n"))))
+		assert.Equal(t, map[string]*manifestEntry{
+			"text.txt":        {algorithm: "sha256", digest: digest},
+			"binary mode.txt": {algorithm: "sha256", digest: digest},
+			"json.txt":        {algorithm: "sha512", digest: "00"},
+		}, s.entries)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		dir := t.TempDir()
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+			return path
+		}
+		same := write("same", "alpha\nbeta\n")
+		changed := write("changed", "alpha\n")
+		added := write("added", "alpha\nbeta\n")
+		s := &checksumManifest{entries: map[string]*manifestEntry{
+			same:    {algorithm: "sha256", digest: digest},
+			changed: {algorithm: "sha256", digest: digest},
+		}}
+		targets := s.filterTargets([]*target{{path: same}, {path: changed}, {path: added}, {}})
+		var paths []string
+		for _, x := range targets {
+			paths = append(paths, x.path)
+		}
+		assert.Equal(t, []string{changed, added, ""}, paths)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		s, err := loadManifest(filepath.Join(t.TempDir(), "missing"))
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(s.entries))
+	})
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runInfo is the metadata of the run written into the structured outputs, nil without -run-metadata.
+var runInfo *runMetadata
+
+// runMetadata describes a run so that the archived outputs are self-describing.
+type runMetadata struct {
+	Patterns []string          `json:"patterns"`
+	Options  map[string]string `json:"options"`
+	Hostname string            `json:"hostname,omitempty"`
+	// GitCommit is the HEAD of the repository of the searched tree, empty if not found.
+	GitCommit string    `json:"git_commit,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+// newRunMetadata returns the metadata of the run starting now.
+// The git commit is searched from dir.
+func newRunMetadata(patterns []string, dir string) *runMetadata {
+	options := map[string]string{}
+	commandLine.Visit(func(f *flag.Flag) {
+		options[f.Name] = f.Value.String()
+	})
+	hostname, _ := os.Hostname()
+	return &runMetadata{
+		Patterns:  patterns,
+		Options:   options,
+		Hostname:  hostname,
+		GitCommit: gitCommit(dir),
+		Start:     clock.Now().UTC(),
+	}
+}
+
+// finish sets the end of the run to now.
+func (s *runMetadata) finish() *runMetadata {
+	s.End = clock.Now().UTC()
+	return s
+}
+
+// pairs returns the metadata as the key-value pairs with the unique keys for the outputs without nested values.
+func (s *runMetadata) pairs() [][2]string {
+	xs := [][2]string{
+		{"start", s.Start.Format(time.RFC3339Nano)},
+		{"end", s.End.Format(time.RFC3339Nano)},
+	}
+	if s.Hostname != "" {
+		xs = append(xs, [2]string{"hostname", s.Hostname})
+	}
+	if s.GitCommit != "" {
+		xs = append(xs, [2]string{"git_commit", s.GitCommit})
+	}
+	for i, p := range s.Patterns {
+		xs = append(xs, [2]string{fmt.Sprintf("pattern.%d", i), p})
+	}
+	names := make([]string, 0, len(s.Options))
+	for name := range s.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		xs = append(xs, [2]string{"option." + name, s.Options[name]})
+	}
+	return xs
+}
+
+// runTreeDir returns the directory of the searched tree: the first root, the directory of the first file or the current directory.
+func runTreeDir(files []string) string {
+	switch {
+	case len(roots) > 0:
+		return roots[0].path
+	case len(files) > 0:
+		return filepath.Dir(files[0])
+	default:
+		return "."
+	}
+}
+
+// gitCommit returns the commit of HEAD of the repository that contains dir, or empty if not found.
+func gitCommit(dir string) string {
+	if isHostFS() {
+		if x, err := filepath.Abs(dir); err == nil {
+			dir = x
+		}
+	}
+	top, ok := findGitTop(dir)
+	if !ok {
+		return ""
+	}
+	gitDir := filepath.Join(top, ".git")
+	// .git of a worktree or a submodule is a file pointing to the git directory
+	if data, err := fs.ReadFile(fileSystem, gitDir); err == nil {
+		x, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+		if !ok {
+			return ""
+		}
+		if !filepath.IsAbs(x) {
+			x = filepath.Join(top, x)
+		}
+		gitDir = x
+	}
+	head, err := fs.ReadFile(fileSystem, filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: ")
+	if !ok {
+		return strings.TrimSpace(string(head)) // detached
+	}
+	if data, err := fs.ReadFile(fileSystem, filepath.Join(gitDir, filepath.FromSlash(path.Clean(ref)))); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	packed, err := fs.ReadFile(fileSystem, filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	sc := bufio.NewScanner(bytes.NewReader(packed))
+	for sc.Scan() {
+		if commit, name, ok := strings.Cut(sc.Text(), " "); ok && name == ref {
+			return commit
+		}
+	}
+	return ""
+}
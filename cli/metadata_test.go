@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitCommit(t *testing.T) {
+	defer func() { fileSystem = hostFS{} }()
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+
+	for _, tc := range []struct {
+		title string
+		fsys  fstest.MapFS
+		want  string
+	}{
+		{
+			title: "loose ref",
+			fsys: fstest.MapFS{
+				"repo/.git/HEAD":            {Data: []byte("ref: refs/heads/main\n")},
+				"repo/.git/refs/heads/main": {Data: []byte(commit + "\n")},
+				"repo/src/a.go":             {Data: []byte("a")},
+			},
+			want: commit,
+		},
+		{
+			title: "packed ref",
+			fsys: fstest.MapFS{
+				"repo/.git/HEAD":        {Data: []byte("ref: refs/heads/main\n")},
+				"repo/.git/packed-refs": {Data: []byte("# pack-refs with: peeled\n" + commit + " refs/heads/main\n")},
+				"repo/src/a.go":         {Data: []byte("a")},
+			},
+			want: commit,
+		},
+		{
+			title: "detached",
+			fsys: fstest.MapFS{
+				"repo/.git/HEAD": {Data: []byte(commit + "\n")},
+				"repo/src/a.go":  {Data: []byte("a")},
+			},
+			want: commit,
+		},
+		{
+			title: "worktree",
+			fsys: fstest.MapFS{
+				"main/.git/worktrees/repo/HEAD": {Data: []byte(commit + "\n")},
+				"repo/.git":                     {Data: []byte("gitdir: ../main/.git/worktrees/repo\n")},
+				"repo/src/a.go":                 {Data: []byte("a")},
+			},
+			want: commit,
+		},
+		{
+			title: "no repository",
+			fsys: fstest.MapFS{
+				"repo/src/a.go": {Data: []byte("a")},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			fileSystem = tc.fsys
+			assert.Equal(t, tc.want, gitCommit("repo/src"))
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"github.com/berquerant/gogrep"
+)
+
+// newTargetSource returns the source of the target that is opened on the first use.
+func newTargetSource(ctx context.Context, t *target) io.Reader {
+	s := lazySource{
+		ctx:    ctx,
+		target: t,
+	}
+	if t.reader != nil || t.path == "" || !isHostFS() || scanCheckpoint != nil {
+		return &s
+	}
+	if *useMmap {
+		return &mmapSource{lazySource: s}
+	}
+	if !*directIO && grepWorkers() > 1 {
+		if info, err := os.Stat(t.path); err == nil && info.Mode().IsRegular() && info.Size() >= rangeMinFileSize {
+			return &rangeSource{lazySource: s}
+		}
+	}
+	return &s
+}
+
+// rangeMinFileSize is the size of the files grepped by rangeSource, 2 ranges of gogrep.Grepper.GrepReaderAt.
+// The smaller files are read by lazySource to be read ahead and batched.
+const rangeMinFileSize = 2 << 20
+
+// rangeSource opens the regular file on the first use
+// so that gogrep.Grepper greps the ranges of the file in parallel by the reads at the offsets.
+// It reads the file as lazySource does if the file is not a regular file without holes or is compressed in frames.
+type rangeSource struct {
+	lazySource
+	checked bool
+	r       *gogrep.FileReaderAt // nil unless opened
+}
+
+func (s *rangeSource) open() {
+	if s.checked {
+		return
+	}
+	s.checked = true
+	f, err := os.Open(s.target.path)
+	if err != nil {
+		return // reported by lazySource
+	}
+	r, ok := gogrep.NewFileReaderAt(f)
+	if !ok {
+		f.Close()
+		return
+	}
+	if _, err := gogrep.DetectFrames(r, r.Size()); err == nil {
+		f.Close() // decompressed in parallel by lazySource
+		return
+	}
+	adviseOpen(f)
+	s.r = r
+}
+
+// Size returns 0 unless opened to read the file by Read.
+func (s *rangeSource) Size() int64 {
+	s.open()
+	if s.r == nil {
+		return 0
+	}
+	return s.r.Size()
+}
+
+func (s *rangeSource) ReadAt(p []byte, off int64) (int, error) {
+	s.open()
+	if s.r == nil {
+		return 0, io.EOF
+	}
+	return s.r.ReadAt(p, off)
+}
+
+func (s *rangeSource) Read(p []byte) (int, error) {
+	s.open()
+	if s.r == nil {
+		return s.lazySource.Read(p)
+	}
+	return s.r.Read(p)
+}
+
+func (s *rangeSource) Close() error {
+	if s.r == nil {
+		return s.lazySource.Close()
+	}
+	f := s.r.File
+	s.r = nil
+	return closeFile(f)
+}
+
+// mmapSource maps the file into memory on the first use
+// so that gogrep.Grepper greps the ranges of the file in parallel as a gogrep.SizedReaderAt.
+// It reads the file as lazySource does if the file cannot be mapped, e.g. a pipe, an empty file or another platform.
+type mmapSource struct {
+	lazySource
+	checked bool
+	data    []byte
+	r       *bytes.Reader // nil unless mapped
+}
+
+func (s *mmapSource) mmap() {
+	if s.checked {
+		return
+	}
+	s.checked = true
+	if data, err := mapFile(s.target.path); err == nil {
+		s.data = data
+		s.r = bytes.NewReader(data)
+	}
+}
+
+// Size returns 0 unless mapped to read the file by Read.
+func (s *mmapSource) Size() int64 {
+	s.mmap()
+	if s.r == nil {
+		return 0
+	}
+	return s.r.Size()
+}
+
+func (s *mmapSource) ReadAt(p []byte, off int64) (int, error) {
+	s.mmap()
+	if s.r == nil {
+		return 0, io.EOF
+	}
+	return s.r.ReadAt(p, off)
+}
+
+func (s *mmapSource) Read(p []byte) (int, error) {
+	s.mmap()
+	if s.r == nil {
+		return s.lazySource.Read(p)
+	}
+	return s.r.Read(p)
+}
+
+func (s *mmapSource) Close() error {
+	if s.r == nil {
+		return s.lazySource.Close()
+	}
+	s.r = nil
+	return unmapFile(s.data)
+}
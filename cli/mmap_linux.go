@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	cliCapabilities = append(cliCapabilities, "mmap")
+}
+
+var errNotMappable = errors.New("not mappable")
+
+// mapFile maps the regular file into memory read-only.
+func mapFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // the mapping remains
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 || int64(int(info.Size())) != info.Size() {
+		return nil, errNotMappable
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(info.Size()), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func unmapFile(data []byte) error { return unix.Munmap(data) }
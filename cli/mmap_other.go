@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package cli
+
+import "errors"
+
+// mapFile fails to read the files as usual since mmap is not supported.
+func mapFile(_ string) ([]byte, error) { return nil, errors.New("mmap is not supported") }
+
+func unmapFile(_ []byte) error { return nil }
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/berquerant/gogrep"
+)
+
+var (
+	ignoreCase    = commandLine.Bool("i", false, "Ignore the case of the letters like (?i) of the regexes. The case is folded fully with -normalize, and with -F, -glob and -fuzzy, e.g. STRASSE matches Straße.")
+	normalization = commandLine.String("normalize", "", "Match the patterns and the lines in the Unicode normalization form: nfc or nfkc, e.g. nfc matches é with e followed by U+0301 and nfkc matches ﬁ with fi. The lines are printed as they are.")
+)
+
+func checkNormalization(form string) error {
+	switch gogrep.Normalization(form) {
+	case "", gogrep.NormalizationNFC, gogrep.NormalizationNFKC:
+		return nil
+	default:
+		return fmt.Errorf("unknown normalize %s", form)
+	}
+}
+
+// fullCaseFolding returns true if -i folds the lines and the patterns by gogrep.WithCaseFolding
+// instead of (?i) of the regexes.
+func fullCaseFolding() bool {
+	if !*ignoreCase {
+		return false
+	}
+	if *normalization != "" || *fuzzy >= 0 {
+		return true
+	}
+	switch grepEngine() {
+	case gogrep.EngineRegexp, gogrep.EngineAuto:
+		return false
+	default:
+		return !*perlRegexp
+	}
+}
+
+// normalizeOptions returns the options by -normalize and -i.
+func normalizeOptions() []gogrep.Option {
+	var opt []gogrep.Option
+	if *normalization != "" {
+		opt = append(opt, gogrep.WithNormalization(gogrep.Normalization(*normalization)))
+	}
+	if fullCaseFolding() {
+		opt = append(opt, gogrep.WithCaseFolding())
+	}
+	return opt
+}
+
+// ignoreCasePatterns returns the regexes ignoring the case by -i unless the case is folded fully.
+func ignoreCasePatterns(patterns []string) []string {
+	if !*ignoreCase || fullCaseFolding() {
+		return patterns
+	}
+	r := make([]string, len(patterns))
+	for i, p := range patterns {
+		r[i] = "(?i)" + p
+	}
+	return r
+}
@@ -0,0 +1,324 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/berquerant/gogrep"
+	"golang.org/x/text/encoding"
+)
+
+// match is a match to be printed.
+type match struct {
+	Root        string   `json:"root,omitempty"`
+	File        string   `json:"file"`
+	Line        int      `json:"line"`
+	Offset      int64    `json:"offset"`
+	Text        string   `json:"text"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	Owners      []string `json:"owners,omitempty"`
+	// Binary is true if the file is binary and matches, without Line, Offset and Text.
+	Binary bool `json:"binary,omitempty"`
+	// Distance is the edit distance of the match with -fuzzy.
+	Distance *int `json:"distance,omitempty"`
+	// ranges are the ranges of the matches in Text to be highlighted.
+	ranges [][2]int
+	// submatches are the capture groups for -sqlite and the templates of -format.
+	submatches []string
+	// byteOffset is the offset printed by -b, the offset of the match with -o.
+	byteOffset int64
+}
+
+// formatter writes matches in a format.
+type formatter interface {
+	format(w io.Writer, m *match) error
+}
+
+// closingFormatter is a formatter that finishes the output at the end.
+type closingFormatter interface {
+	formatter
+	close() error
+}
+
+// Output encodings of -output-encoding.
+const (
+	outputUTF8   = "utf-8"
+	outputSource = "source"
+)
+
+func newFormatter(format string, patterns []string) (formatter, error) {
+	switch *outputEncoding {
+	case outputUTF8:
+	case outputSource:
+		if format != "text" || *fingerprint {
+			return nil, errors.New("-output-encoding source requires -format text")
+		}
+	default:
+		return nil, fmt.Errorf("unknown output encoding %s", *outputEncoding)
+	}
+	if *fingerprint {
+		return &jsonFormatter{}, nil
+	}
+	if *withRunMetadata {
+		switch format {
+		case "json", "junit", "parquet":
+		default:
+			return nil, errors.New("-run-metadata requires -format json, junit or parquet")
+		}
+	}
+	if isTemplateFormat(format) {
+		wantRanges = true
+		wantSubmatches = true
+		return newTemplateFormatter(format)
+	}
+	switch format {
+	case "text":
+		color, err := useColor(*colorMode)
+		if err != nil {
+			return nil, err
+		}
+		wantRanges = color
+		f := &textFormatter{
+			color:   color,
+			heading: *heading,
+		}
+		if *outputEncoding == outputSource && *encodingName != "" {
+			e, err := gogrep.LookupEncoding(*encodingName)
+			if err != nil {
+				return nil, err
+			}
+			f.encoder = encoding.ReplaceUnsupported(e.NewEncoder())
+		}
+		return f, nil
+	case "json":
+		return &jsonFormatter{}, nil
+	case "junit":
+		return newJunitFormatter(*outputFile, patterns), nil
+	case "github":
+		wantRanges = true
+		return newGithubFormatter(patterns), nil
+	case "parquet":
+		if *outputFile == "" {
+			return nil, errors.New("-format parquet requires -output")
+		}
+		return newParquetFormatter(*outputFile)
+	default:
+		return nil, fmt.Errorf("unknown format %s", format)
+	}
+}
+
+// textFormatter writes the text, prefixed with the file name if printFileName
+// and the line number if -n.
+// With -heading, the file name is written on its own line before the texts of the file instead,
+// and the files are separated by empty lines.
+// The file name is terminated by NUL instead of the separator if -Z.
+// The text is terminated by NUL if -z.
+// The text is encoded back to -encoding by encoder with -output-encoding source.
+type textFormatter struct {
+	color   bool
+	encoder *encoding.Encoder
+	heading bool
+	file    *string // the file of the last heading, nil before the first heading
+}
+
+func (s *textFormatter) format(w io.Writer, m *match) error {
+	if m.Binary {
+		_, err := fmt.Fprintf(w, "Binary file %s matches\n", m.File)
+		return err
+	}
+	var b strings.Builder
+	if printFileName && s.heading {
+		if s.file == nil || *s.file != m.File {
+			if s.file != nil {
+				b.WriteString("\n")
+			}
+			s.writeFileName(&b, m.File, "\n")
+			s.file = &m.File
+		}
+	} else if printFileName {
+		s.writeFileName(&b, m.File, s.colorize(colorSeparator, ":"))
+	}
+	if *lineNumber {
+		b.WriteString(s.colorize(colorLine, strconv.Itoa(m.Line)))
+		b.WriteString(s.colorize(colorSeparator, ":"))
+	}
+	if *byteOffset {
+		b.WriteString(s.colorize(colorLine, strconv.FormatInt(m.byteOffset, 10)))
+		b.WriteString(s.colorize(colorSeparator, ":"))
+	}
+	text := m.Text
+	if s.color {
+		text = highlight(text, m.ranges)
+	}
+	if s.encoder != nil {
+		// The escape sequences of the colors are encoded together
+		x, err := s.encoder.String(text)
+		if err != nil {
+			return fmt.Errorf("cannot encode %s:%d: %w", m.File, m.Line, err)
+		}
+		text = x
+	}
+	b.WriteString(text)
+	if *nullData {
+		b.WriteByte(0)
+	} else {
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeFileName writes the file name followed by sep, or by NUL with -Z.
+func (s *textFormatter) writeFileName(b *strings.Builder, name, sep string) {
+	b.WriteString(s.colorize(colorFile, name))
+	b.WriteString(fileNameTerminator(sep))
+}
+
+func (s *textFormatter) colorize(color, x string) string {
+	if !s.color {
+		return x
+	}
+	return colorize(color, x)
+}
+
+// jsonFormatter writes a JSON object per line.
+// The metadata of the run is written as the last line {"run":{...}} with -run-metadata.
+type jsonFormatter struct{}
+
+func (*jsonFormatter) format(w io.Writer, m *match) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// jsonRunRecord is the line of the metadata of the run in -format json.
+type jsonRunRecord struct {
+	Run *runMetadata `json:"run"`
+}
+
+func (*jsonFormatter) close() error {
+	if runInfo == nil {
+		return nil
+	}
+	b, err := json.Marshal(&jsonRunRecord{Run: runInfo.finish()})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "%s\n", b)
+	return err
+}
+
+var (
+	// matchFormatter formats the matches to be printed.
+	matchFormatter formatter
+	// matchBaseline is the loaded -baseline.
+	matchBaseline *baseline
+	// matchCodeowners is the loaded -codeowners.
+	matchCodeowners *codeowners
+	// matched is true if any match is selected.
+	matched bool
+	// wantRanges is true if the formatter uses the match ranges.
+	wantRanges bool
+	// wantSubmatches is true if the formatter uses the capture groups.
+	wantSubmatches bool
+	// printFileName is true if the file names should be printed along with the matched texts.
+	printFileName bool
+)
+
+// printMatch prints the result of the target.
+func printMatch(t *target, r gogrep.Result, text string) {
+	m := newMatch(t, r, text)
+	if matchAggregate != nil {
+		matched = true
+		matchAggregate.add(m)
+		return
+	}
+	if matchTop != nil {
+		matchTop.add(t, r, m)
+		return
+	}
+	emitMatch(m)
+}
+
+// newMatch returns the match of the result of the target to be printed.
+func newMatch(t *target, r gogrep.Result, text string) *match {
+	m := &match{
+		Root:   t.root,
+		File:   t.name(),
+		Line:   r.Line(),
+		Offset: r.Offset(),
+		Text:   text,
+	}
+	if matchDB != nil || wantSubmatches {
+		m.submatches = r.Submatches()
+	}
+	if *fuzzy >= 0 {
+		d := r.Distance()
+		m.Distance = &d
+	}
+	if *byteOffset {
+		m.byteOffset = r.Offset()
+		if *onlyMatching {
+			if ranges := r.MatchRanges(); len(ranges) > 0 {
+				m.byteOffset += int64(ranges[0][0])
+			}
+		}
+	}
+	if wantRanges {
+		if *onlyMatching || *group >= 0 {
+			m.ranges = [][2]int{{0, len(text)}}
+		} else {
+			m.ranges = r.MatchRanges()
+		}
+	}
+	m.Text, m.ranges = transformText(m.Text, m.ranges)
+	return m
+}
+
+// emitMatch selects and prints the match.
+func emitMatch(m *match) {
+	if matchBaseline != nil {
+		if *updateBaseline {
+			matched = true
+			matchBaseline.record(m)
+			return
+		}
+		if matchBaseline.suppress(m.File, m.Text) {
+			return
+		}
+	}
+	if matchWatch != nil && !matchWatch.fresh(m) {
+		return
+	}
+	matched = true
+	if *quiet {
+		return
+	}
+	if *fingerprint {
+		m.Fingerprint = computeFingerprint(m.File, m.Text)
+	}
+	if matchCodeowners != nil {
+		m.Owners = matchCodeowners.owners(m.File)
+		if *groupByOwner {
+			matchCodeowners.add(m.Owners)
+			return
+		}
+	}
+	if matchDB != nil {
+		if err := matchDB.insert(m); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+	if err := matchFormatter.format(stdout, m); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
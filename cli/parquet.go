@@ -0,0 +1,65 @@
+//go:build parquet
+
+package cli
+
+import (
+	"io"
+	"os"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func init() {
+	cliCapabilities = append(cliCapabilities, "format:parquet")
+}
+
+// parquetRow is a row of -format parquet.
+type parquetRow struct {
+	Root   string `parquet:"root"`
+	File   string `parquet:"file"`
+	Line   int64  `parquet:"line"`
+	Offset int64  `parquet:"offset"`
+	Text   string `parquet:"text"`
+	Binary bool   `parquet:"binary"`
+}
+
+// parquetFormatter writes the matches into the file as columnar records.
+type parquetFormatter struct {
+	f *os.File
+	w *parquet.Writer
+}
+
+func newParquetFormatter(file string) (closingFormatter, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetFormatter{
+		f: f,
+		w: parquet.NewWriter(f, parquet.SchemaOf(parquetRow{})),
+	}, nil
+}
+
+func (s *parquetFormatter) format(_ io.Writer, m *match) error {
+	return s.w.Write(&parquetRow{
+		Root:   m.Root,
+		File:   m.File,
+		Line:   int64(m.Line),
+		Offset: m.Offset,
+		Text:   m.Text,
+		Binary: m.Binary,
+	})
+}
+
+func (s *parquetFormatter) close() error {
+	if runInfo != nil {
+		for _, p := range runInfo.finish().pairs() {
+			s.w.SetKeyValueMetadata("gogrep."+p[0], p[1])
+		}
+	}
+	if err := s.w.Close(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !parquet
+
+package cli
+
+import "errors"
+
+func newParquetFormatter(string) (closingFormatter, error) {
+	return nil, errors.New("-format parquet requires gogrep built with -tags parquet")
+}
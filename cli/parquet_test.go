@@ -0,0 +1,51 @@
+//go:build parquet
+
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParquetFormatter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out.parquet")
+	f, err := newParquetFormatter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := []*match{
+		{File: "a.txt", Line: 1, Offset: 0, Text: "crimson"},
+		{Root: "src", File: "b.bin", Binary: true},
+	}
+	for _, m := range matches {
+		assert.Nil(t, f.format(nil, m))
+	}
+	assert.Nil(t, f.close())
+
+	r, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	pr := parquet.NewReader(r)
+	defer pr.Close()
+	var got []parquetRow
+	for {
+		var row parquetRow
+		if err := pr.Read(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, row)
+	}
+	assert.Equal(t, []parquetRow{
+		{File: "a.txt", Line: 1, Text: "crimson"},
+		{Root: "src", File: "b.bin", Binary: true},
+	}, got)
+}
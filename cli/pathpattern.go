@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// pathPattern is a gitignore style path pattern.
+// It is used by CODEOWNERS and ignore files.
+type pathPattern struct {
+	raw     string
+	re      *regexp.Regexp
+	dirOnly bool // the pattern ends with /
+	negate  bool // the pattern starts with !
+}
+
+// compilePathPattern compiles a gitignore style pattern.
+//
+// A pattern that contains / except at the end is relative to the root,
+// otherwise it matches the base name at any depth.
+// * matches anything except /, ? matches a character except /, ** matches any directories.
+func compilePathPattern(pattern string) (*pathPattern, error) {
+	p := &pathPattern{
+		raw: pattern,
+	}
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			if j := strings.IndexByte(pattern[i:], ']'); j > 0 {
+				class := pattern[i+1 : i+j]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += j
+				continue
+			}
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, err
+	}
+	p.re = re
+	return p, nil
+}
+
+// match reports whether the slash separated relative path matches the pattern.
+func (p *pathPattern) match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(name)
+}
+
+// matchPathOrParents reports whether the file or any of its parent directories matches the pattern.
+func (p *pathPattern) matchPathOrParents(name string) bool {
+	if p.match(name, false) {
+		return true
+	}
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if p.match(dir, true) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPattern(t *testing.T) {
+	for _, tc := range []*struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "*.go", path: "a/b/c.go", want: true},
+		{pattern: "*.go", path: "c.go", want: true},
+		{pattern: "*.go", path: "c.golang", want: false},
+		{pattern: "/docs", path: "docs/a.md", want: true},
+		{pattern: "/docs", path: "x/docs/a.md", want: false},
+		{pattern: "docs/", path: "x/docs/a.md", want: true},
+		{pattern: "docs/", path: "docs", want: false},
+		{pattern: "a/**/b", path: "a/x/y/b", want: true},
+		{pattern: "a/**/b", path: "a/b", want: true},
+		{pattern: "**/vendor", path: "x/vendor/y.go", want: true},
+		{pattern: "c?.[ab]", path: "c1.a", want: true},
+		{pattern: "c?.[!ab]", path: "c1.a", want: false},
+	} {
+		p, err := compilePathPattern(tc.pattern)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, p.matchPathOrParents(tc.path), "%s %s", tc.pattern, tc.path)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build pcre
+
+package cli
+
+import (
+	"github.com/berquerant/gogrep"
+	"github.com/berquerant/gogrep/pcre"
+)
+
+// pcreEngine returns the engine of -P.
+func pcreEngine() (gogrep.Engine, error) { return pcre.Engine, nil }
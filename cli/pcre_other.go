@@ -0,0 +1,13 @@
+//go:build !pcre
+
+package cli
+
+import (
+	"errors"
+
+	"github.com/berquerant/gogrep"
+)
+
+func pcreEngine() (gogrep.Engine, error) {
+	return "", errors.New("-P requires gogrep built with -tags pcre")
+}
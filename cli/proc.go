@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/berquerant/gogrep"
+)
+
+const procUsage = `Usage of gogrep proc
+  gogrep proc [flags] REGEX
+  gogrep proc [flags] -e REGEX [-e REGEX...]
+    Grep the environment variables and the command line arguments of the processes
+    in /proc/PID/environ and /proc/PID/cmdline, matching each NUL-separated entry.
+    The matches are labeled with the file of the PID, and the process name as the root.
+    The processes that cannot be read are skipped.
+    The flags are the same as gogrep.`
+
+// procMode is true for gogrep proc.
+var procMode bool
+
+// procDir is the mount point of procfs.
+var procDir = "/proc"
+
+func runProc(args []string) error {
+	_, rest, err := parseGrepSubcommand(args, procUsage, 0)
+	if err != nil {
+		return err
+	}
+	procMode = true
+	return grepStatus(runGrep(rest))
+}
+
+// grepProc greps the environ and the cmdline of the processes in order of the PIDs.
+func grepProc(ctx context.Context, patterns []string) error {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return err
+	}
+	var pids []int
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil && e.IsDir() {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	var targets []*target
+	for _, pid := range pids {
+		var (
+			dir     = filepath.Join(procDir, strconv.Itoa(pid))
+			comm, _ = os.ReadFile(filepath.Join(dir, "comm"))
+			name    = strings.TrimSuffix(string(comm), "\n")
+		)
+		for _, file := range []string{"environ", "cmdline"} {
+			p := filepath.Join(dir, file)
+			targets = append(targets, &target{
+				path:   p,
+				root:   name,
+				reader: &procFile{path: p},
+				options: []gogrep.Option{
+					gogrep.WithBinaryFiles(gogrep.BinaryText),
+					gogrep.WithDelimiter(0),
+				},
+			})
+		}
+	}
+	return grepSources(ctx, patterns, targets)
+}
+
+// procFile reads the file of a process on the first read.
+// The errors are treated as EOF since the process may exit or be inaccessible.
+type procFile struct {
+	path string
+	f    *os.File
+	done bool
+}
+
+func (s *procFile) Read(p []byte) (int, error) {
+	if s.done {
+		return 0, io.EOF
+	}
+	if s.f == nil {
+		f, err := os.Open(s.path)
+		if err != nil {
+			s.done = true
+			return 0, io.EOF
+		}
+		s.f = f
+	}
+	n, err := s.f.Read(p)
+	if err != nil {
+		s.done = true
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (s *procFile) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var (
+	cpuProfile = commandLine.String("cpuprofile", "", "Write the CPU profile of the run into the file for go tool pprof.")
+	memProfile = commandLine.String("memprofile", "", "Write the heap profile at the end of the run into the file for go tool pprof.")
+	traceFile  = commandLine.String("trace", "", "Write the execution trace of the run into the file for go tool trace.")
+)
+
+// startProfiles starts -cpuprofile and -trace and returns the function to stop them and write -memprofile.
+func startProfiles() (func(), error) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("cpuprofile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("cpuprofile: %w", err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			closeProfile("cpuprofile", f)
+		})
+	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("trace: %w", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+			return nil, fmt.Errorf("trace: %w", err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			closeProfile("trace", f)
+		})
+	}
+	if *memProfile != "" {
+		stops = append(stops, writeMemProfile)
+	}
+	return stop, nil
+}
+
+// writeMemProfile writes the heap profile of -memprofile.
+func writeMemProfile() {
+	f, err := os.Create(*memProfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memprofile: %v\n", err)
+		return
+	}
+	runtime.GC() // up-to-date statistics
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		fmt.Fprintf(os.Stderr, "memprofile: %v\n", err)
+	}
+	closeProfile("memprofile", f)
+}
+
+func closeProfile(name string, f *os.File) {
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+	}
+}
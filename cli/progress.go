@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+
+	"github.com/berquerant/gogrep"
+)
+
+var showProgress = commandLine.Bool("progress", false, "Write the progress bar of the file being grepped to stderr while the file is read, for the files whose sizes are known like the regular files.")
+
+// progressBarWidth is the number of the cells of the bar.
+const progressBarWidth = 30
+
+// progressBar renders the progress of the targets on a line.
+type progressBar struct {
+	w       io.Writer
+	targets []*target // indexed by the tags of the events
+	mux     sync.Mutex
+	sizes   map[int]int64 // the sizes of the targets by the file system, -1 if unknown
+	width   int           // the width of the last line, 0 if cleared
+}
+
+func newProgressBar(w io.Writer, targets []*target) *progressBar {
+	return &progressBar{
+		w:       w,
+		targets: targets,
+		sizes:   map[int]int64{},
+	}
+}
+
+// report renders the event, called concurrently by the greps.
+func (s *progressBar) report(x gogrep.ProgressEvent) {
+	index, ok := x.Tag.(int)
+	if !ok || index < 0 || index >= len(s.targets) {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if x.Done {
+		s.clear()
+		return
+	}
+	size := x.Size
+	if size <= 0 {
+		size = s.size(index)
+	}
+	if size <= 0 {
+		return
+	}
+	ratio := float64(x.BytesRead) / float64(size)
+	if ratio > 1 {
+		ratio = 1
+	}
+	cells := int(ratio * progressBarWidth)
+	line := fmt.Sprintf("%s [%s%s] %3d%% %s/%s",
+		s.targets[index].name(),
+		strings.Repeat("=", cells), strings.Repeat(" ", progressBarWidth-cells),
+		int(ratio*100), formatBytes(x.BytesRead), formatBytes(size),
+	)
+	pad := s.width - len(line)
+	if pad < 0 {
+		pad = 0
+	}
+	fmt.Fprintf(s.w, "\r%s%s", line, strings.Repeat(" ", pad))
+	s.width = len(line)
+}
+
+// size returns the size of the regular file of the target, -1 if unknown.
+func (s *progressBar) size(index int) int64 {
+	if x, ok := s.sizes[index]; ok {
+		return x
+	}
+	var (
+		t    = s.targets[index]
+		size = int64(-1)
+	)
+	if t.reader == nil && t.path != "" {
+		if info, err := fs.Stat(fileSystem, t.path); err == nil && info.Mode().IsRegular() {
+			size = info.Size()
+		}
+	}
+	s.sizes[index] = size
+	return size
+}
+
+// clear erases the bar.
+func (s *progressBar) clear() {
+	if s.width == 0 {
+		return
+	}
+	fmt.Fprintf(s.w, "\r%s\r", strings.Repeat(" ", s.width))
+	s.width = 0
+}
+
+// formatBytes returns the size like 1.5MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	var (
+		x = float64(n) / unit
+		i int
+	)
+	for ; x >= unit && i < 4; i++ {
+		x /= unit
+	}
+	return fmt.Sprintf("%.1f%ciB", x, "KMGTP"[i])
+}
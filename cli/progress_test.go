@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressBar(t *testing.T) {
+	var (
+		w   strings.Builder
+		bar = newProgressBar(&w, []*target{{path: "big"}, {path: "pipe"}})
+	)
+	bar.report(gogrep.ProgressEvent{Tag: 0, Size: 4096, BytesRead: 1024})
+	assert.Equal(t, "\rbig [=======                       ]  25% 1.0KiB/4.0KiB", w.String())
+	w.Reset()
+	bar.report(gogrep.ProgressEvent{Tag: 1, Size: -1, BytesRead: 1024})
+	assert.Equal(t, "", w.String(), "unknown size")
+	bar.report(gogrep.ProgressEvent{Tag: 0, Size: 4096, BytesRead: 4096, Done: true})
+	assert.Equal(t, "\r"+strings.Repeat(" ", 55)+"\r", w.String())
+	w.Reset()
+	bar.report(gogrep.ProgressEvent{Tag: 0, Size: 4096, BytesRead: 4096, Done: true})
+	assert.Equal(t, "", w.String(), "cleared")
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, tc := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1536, "1.5KiB"},
+		{3 << 30, "3.0GiB"},
+	} {
+		assert.Equal(t, tc.want, formatBytes(tc.n))
+	}
+}
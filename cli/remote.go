@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// workerRequest is the input of gogrep worker.
+type workerRequest struct {
+	// Flags are the command line flags of gogrep to use.
+	Flags    []string        `json:"flags"`
+	Patterns []string        `json:"patterns"`
+	Targets  []*workerTarget `json:"targets"`
+}
+
+type workerTarget struct {
+	Path string `json:"path"`
+	Root string `json:"root,omitempty"`
+}
+
+// workerMatch is the output of gogrep worker, a JSON object per line.
+type workerMatch struct {
+	match
+	Ranges [][2]int `json:"ranges,omitempty"`
+}
+
+// workerFormatter writes the matches for the coordinator.
+type workerFormatter struct{}
+
+func (*workerFormatter) format(w io.Writer, m *match) error {
+	b, err := json.Marshal(&workerMatch{
+		match:  *m,
+		Ranges: m.ranges,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+const workerUsage = `Usage of gogrep worker
+  gogrep worker < REQUEST
+    Grep the files in the JSON request from stdin and write the matches as JSON lines.
+    This is a stateless worker spawned by gogrep -remote.`
+
+// runWorker greps files as a remote worker.
+func runWorker(args []string) error {
+	if len(args) != 0 {
+		return errors.New(workerUsage)
+	}
+	var req workerRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		return fmt.Errorf("worker cannot read request: %w", err)
+	}
+	if err := parseCommandLine(req.Flags); err != nil {
+		return err
+	}
+	if err := validateFlags(); err != nil {
+		return err
+	}
+	if err := parseNotInside(); err != nil {
+		return err
+	}
+	if len(req.Patterns) == 0 || len(req.Targets) == 0 {
+		return errors.New("worker requires patterns and targets")
+	}
+	matchFormatter = &workerFormatter{}
+	wantRanges = true
+	printFileName = true
+	targets := make([]*target, len(req.Targets))
+	for i, t := range req.Targets {
+		targets[i] = &target{
+			path: t.Path,
+			root: t.Root,
+		}
+	}
+	if err := grepSources(context.Background(), req.Patterns, targets); err != nil {
+		return err
+	}
+	if targetFailed {
+		// The errors of the targets are printed, the coordinator fails by the status
+		return statusError(exitError)
+	}
+	return nil
+}
+
+// errRemoteTargetFailed means a worker failed to grep some targets, reported by the worker to stderr.
+var errRemoteTargetFailed = errors.New("remote target failed")
+
+// remoteFlags are not forwarded to workers because the coordinator handles them.
+var remoteFlags = map[string]bool{
+	"remote":          true,
+	"root":            true,
+	"e":               true,
+	"f":               true,
+	"format":          true,
+	"color":           true,
+	"fingerprint":     true,
+	"baseline":        true,
+	"update-baseline": true,
+	"codeowners":      true,
+	"group-by-owner":  true,
+	"sqlite":          true,
+	"explain":         true,
+	"fail-on":         true,
+	"n":               true,
+}
+
+// forwardedFlags returns the flags set in the command line to be forwarded to workers.
+func forwardedFlags() []string {
+	var r []string
+	commandLine.Visit(func(f *flag.Flag) {
+		if remoteFlags[f.Name] {
+			return
+		}
+		if v, ok := f.Value.(*stringsFlag); ok {
+			for _, x := range *v {
+				r = append(r, "-"+f.Name+"="+x)
+			}
+			return
+		}
+		r = append(r, "-"+f.Name+"="+f.Value.String())
+	})
+	return r
+}
+
+// grepRemote splits the files and the files under the roots across the workers spawned by the commands
+// and prints the merged matches.
+func grepRemote(ctx context.Context, commands []string, patterns []string, files []string) error {
+	var targets []*workerTarget
+	for _, f := range files {
+		targets = append(targets, &workerTarget{Path: f})
+	}
+	for _, r := range roots {
+		if err := r.walk(func(t *target) error {
+			targets = append(targets, &workerTarget{
+				Path: t.path,
+				Root: t.root,
+			})
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if len(targets) == 0 {
+		return errors.New("-remote requires files or roots")
+	}
+	shards := make([][]*workerTarget, len(commands))
+	for i, t := range targets {
+		shards[i%len(shards)] = append(shards[i%len(shards)], t)
+	}
+	var (
+		wg       sync.WaitGroup
+		collator outputCollator // the matches of the files are not interleaved
+		errC     = make(chan error, len(commands))
+		flags    = forwardedFlags()
+		failed   atomic.Bool
+	)
+	for i, command := range commands {
+		if len(shards[i]) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(command string, targets []*workerTarget) {
+			defer wg.Done()
+			source := collator.source()
+			defer source.flush()
+			if err := runRemote(ctx, command, &workerRequest{
+				Flags:    flags,
+				Patterns: patterns,
+				Targets:  targets,
+			}, source.add); errors.Is(err, errRemoteTargetFailed) {
+				failed.Store(true)
+			} else if err != nil {
+				errC <- fmt.Errorf("remote %s: %w", command, err)
+			}
+		}(command, shards[i])
+	}
+	wg.Wait()
+	close(errC)
+	if failed.Load() {
+		targetFailed = true
+	}
+	return <-errC
+}
+
+// runRemote spawns a worker by the command and emits the matches from it.
+// Returns errRemoteTargetFailed if the worker failed to grep some targets.
+func runRemote(ctx context.Context, command string, req *workerRequest, emit func(*match)) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return errors.New("empty command")
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(string(b))
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(nil, 1<<30)
+	for sc.Scan() {
+		var m workerMatch
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return err
+		}
+		m.match.ranges = m.Ranges
+		emit(&m.match)
+	}
+	if err := sc.Err(); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == exitError {
+			return errRemoteTargetFailed
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/berquerant/gogrep"
+)
+
+func checkLineEnding(ending string) error {
+	switch gogrep.LineEnding(ending) {
+	case gogrep.LineEndingPreserve, gogrep.LineEndingLF, gogrep.LineEndingCRLF:
+		return nil
+	default:
+		return fmt.Errorf("unknown line-ending %s", ending)
+	}
+}
+
+// compilePatterns compiles the patterns by regexp, quoted with -engine fixed or -F.
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	regexes := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		if grepEngine() == gogrep.EngineFixed {
+			p = regexp.QuoteMeta(p)
+			if *ignoreCase {
+				p = "(?i)" + p
+			}
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile regex %s: %w", p, err)
+		}
+		regexes[i] = re
+	}
+	return regexes, nil
+}
+
+// replaceTargets prints the files and the files under the roots with the matches replaced by -replace,
+// or rewrites them in parallel with -in-place.
+// Reads stdin if both are empty.
+func replaceTargets(ctx context.Context, patterns []string, files []string) error {
+	regexes, err := compilePatterns(patterns)
+	if err != nil {
+		return err
+	}
+	targets, err := collectTargets(files)
+	if err != nil {
+		return err
+	}
+	if *inPlace {
+		if len(targets) == 0 {
+			return errors.New("-in-place requires files")
+		}
+		for _, t := range targets {
+			if t.path == "" {
+				return errors.New("-in-place cannot rewrite stdin")
+			}
+		}
+		return rewriteTargets(ctx, regexes, targets)
+	}
+	if len(targets) == 0 {
+		targets = []*target{{}}
+	}
+	for _, t := range targets {
+		if err := printReplaced(ctx, regexes, t); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s: %v\n", t.name(), err)
+			targetFailed = true
+		}
+	}
+	return nil
+}
+
+// printReplaced prints the target with the matches replaced.
+func printReplaced(ctx context.Context, regexes []*regexp.Regexp, t *target) error {
+	var r io.Reader = os.Stdin
+	if t.path != "" {
+		f, err := fileSystem.Open(t.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	n, err := gogrep.ReplaceLines(ctx, regexes, replacement.value, r, stdout, gogrep.LineEnding(*lineEnding))
+	if n > 0 {
+		matched = true
+	}
+	return err
+}
+
+var (
+	// errNotReplaced skips rewriting the file without matches.
+	errNotReplaced = errors.New("not replaced")
+	// errBinarySkipped skips rewriting the binary file.
+	errBinarySkipped = errors.New("binary file skipped")
+)
+
+// rewriteTargets rewrites the targets by -j workers with -in-place.
+// With -transactional, the files are replaced only if all the targets are rewritten.
+func rewriteTargets(ctx context.Context, regexes []*regexp.Regexp, targets []*target) error {
+	if !isHostFS() {
+		return errors.New("-in-place cannot rewrite the files not on the host")
+	}
+	var (
+		tx      = &editTransaction{transactional: *transactional}
+		wg      sync.WaitGroup
+		mux     sync.Mutex
+		failed  []error
+		targetC = make(chan *target)
+	)
+	workers := grepWorkers()
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for t := range targetC {
+				err := tx.rewrite(t.path, func(r io.Reader, w io.Writer) error {
+					return rewriteReplaced(ctx, regexes, r, w)
+				})
+				if errors.Is(err, errNotReplaced) || errors.Is(err, errBinarySkipped) {
+					continue
+				}
+				mux.Lock()
+				if err == nil {
+					matched = true
+				} else {
+					failed = append(failed, fmt.Errorf("%s: %w", t.name(), err))
+				}
+				mux.Unlock()
+			}
+		}()
+	}
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		targetC <- t
+	}
+	close(targetC)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		tx.rollback()
+		return ctx.Err()
+	}
+	if len(failed) > 0 {
+		if *transactional {
+			tx.rollback()
+			return fmt.Errorf("rewrote no files: %w", errors.Join(failed...))
+		}
+		for _, err := range failed {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		targetFailed = true
+	}
+	return tx.commit()
+}
+
+// rewriteReplaced writes the content with the matches replaced.
+// Returns errNotReplaced if no lines match, and errBinarySkipped for the binary content unless -binary-files text.
+func rewriteReplaced(ctx context.Context, regexes []*regexp.Regexp, r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	if gogrep.BinaryFiles(*binaryFiles) != gogrep.BinaryText {
+		head, _ := br.Peek(4096)
+		if gogrep.IsBinary(head) {
+			return errBinarySkipped
+		}
+	}
+	n, err := gogrep.ReplaceLines(ctx, regexes, replacement.value, br, w, gogrep.LineEnding(*lineEnding))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errNotReplaced
+	}
+	return nil
+}
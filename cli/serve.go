@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gogrep"
+)
+
+const serveUsage = `Usage of gogrep serve
+  gogrep serve [flags]
+    Serve the grep over HTTP. POST /grep with the JSON request
+      {"patterns": [REGEX...], "content": TEXT, "paths": [PATH...], "only_matching": BOOL, "max_count": N}
+    streams the matches of the content and the files under -allow as the JSON lines
+      {"file": PATH, "line": N, "offset": N, "text": TEXT}
+    and the errors of the files as {"file": PATH, "error": MESSAGE}.
+    The content is named - in the results.
+    The request is rejected if any path is not found or not under -allow.
+Flags:`
+
+// serveRequest is the body of POST /grep.
+type serveRequest struct {
+	Patterns []string `json:"patterns"`
+	// Content is the inline text to grep.
+	Content *string `json:"content,omitempty"`
+	// Paths are the files on the server to grep, under the directories of -allow.
+	Paths        []string `json:"paths,omitempty"`
+	OnlyMatching bool     `json:"only_matching,omitempty"`
+	// MaxCount stops reading a source after the matched lines if positive.
+	MaxCount int `json:"max_count,omitempty"`
+}
+
+// serveError is a line of the response for the error of a source.
+type serveError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// serveContentName is the name of the inline content in the results.
+const serveContentName = "-"
+
+// grepServer greps the requests.
+type grepServer struct {
+	allowed  []string // the absolute directories of -allow without the symbolic links
+	grepper  gogrep.Grepper
+	maxBytes int64 // the max size of a request
+}
+
+// newServeGrepper returns the Grepper shared by the requests to reuse the compiled patterns.
+// The results waiting for the slow clients are kept compact.
+func newServeGrepper(threads, regexCacheSize int) gogrep.Grepper {
+	return gogrep.New(
+		gogrep.WithThreads(threads),
+		gogrep.WithRegexCacheSize(regexCacheSize),
+		gogrep.WithCompactResults(),
+	)
+}
+
+func runServe(args []string) error {
+	var (
+		fs       = flag.NewFlagSet("serve", flag.ContinueOnError)
+		listen   = fs.String("listen", ":8080", "The address to listen on.")
+		allow    stringsFlag
+		threads  = fs.Int("j", 1, "The number of the grep workers of a request. Positive number is valid.")
+		maxBytes = fs.String("max-request-size", "10M", "The max size of a request like 10M or 1GiB, including the content.")
+		cache    = fs.Int("regex-cache", 256, "The number of the compiled patterns reused by the requests. 0 disables the cache.")
+	)
+	fs.Var(&allow, "allow", "The directory whose files can be grepped by the paths of the requests. Can be specified multiple times. The paths are rejected without -allow.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), serveUsage)
+		fs.PrintDefaults()
+	}
+	if err := parseFlagSet(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New(serveUsage)
+	}
+	if *threads < 1 {
+		return fmt.Errorf("invalid -j %d", *threads)
+	}
+	if *cache < 0 {
+		return fmt.Errorf("invalid -regex-cache %d", *cache)
+	}
+	size, err := parseBytesValue(*maxBytes)
+	if err != nil {
+		return fmt.Errorf("invalid -max-request-size: %w", err)
+	}
+	s := &grepServer{
+		grepper:  newServeGrepper(*threads, *cache),
+		maxBytes: int64(size),
+	}
+	for _, dir := range allow {
+		x, err := resolvePath(dir)
+		if err != nil {
+			return fmt.Errorf("invalid -allow: %w", err)
+		}
+		s.allowed = append(s.allowed, x)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/grep", s.handleGrep)
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	errC := make(chan error, 1)
+	go func() {
+		errC <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}
+}
+
+// resolvePath returns the absolute path without the symbolic links.
+func resolvePath(path string) (string, error) {
+	x, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(x)
+}
+
+// isAllowed returns true if the file is under a directory of -allow.
+func (s *grepServer) isAllowed(path string) bool {
+	x, err := resolvePath(path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range s.allowed {
+		if rel, err := filepath.Rel(dir, x); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *grepServer) handleGrep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req serveRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBytes)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Patterns) == 0 {
+		http.Error(w, "invalid request: no patterns", http.StatusBadRequest)
+		return
+	}
+	if req.Content == nil && len(req.Paths) == 0 {
+		http.Error(w, "invalid request: no content and paths", http.StatusBadRequest)
+		return
+	}
+	for _, path := range req.Paths {
+		if !s.isAllowed(path) {
+			http.Error(w, fmt.Sprintf("path not allowed: %s", path), http.StatusForbidden)
+			return
+		}
+	}
+
+	// The options of the request apply to the sources not to compile the patterns again
+	var opt []gogrep.Option
+	if req.OnlyMatching {
+		opt = append(opt, gogrep.WithOnlyMatching())
+	}
+	if req.MaxCount > 0 {
+		opt = append(opt, gogrep.WithMaxCount(req.MaxCount))
+	}
+	var (
+		sources []gogrep.NamedSource
+		errs    []*serveError // the files that cannot be opened
+	)
+	if req.Content != nil {
+		sources = append(sources, gogrep.NamedSource{
+			Name:    serveContentName,
+			Reader:  strings.NewReader(*req.Content),
+			Options: opt,
+		})
+	}
+	for _, path := range req.Paths {
+		f, err := os.Open(path)
+		if err != nil {
+			errs = append(errs, &serveError{File: path, Error: err.Error()})
+			continue
+		}
+		sources = append(sources, gogrep.NamedSource{
+			Name:    path,
+			Reader:  f, // closed by the grep
+			Options: opt,
+		})
+	}
+	// The context is canceled when the client goes away
+	resultC, err := s.grepper.GrepSources(r.Context(), req.Patterns, sources)
+	if err != nil {
+		for _, src := range sources {
+			if c, ok := src.Reader.(io.Closer); ok {
+				c.Close()
+			}
+		}
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	var (
+		enc     = json.NewEncoder(w)
+		flusher = w.(http.Flusher)
+	)
+	for _, e := range errs {
+		_ = enc.Encode(e)
+	}
+	flusher.Flush()
+	for x := range resultC {
+		var v interface{}
+		switch err := x.Err(); {
+		case errors.Is(err, gogrep.ErrBinaryFile):
+			v = &match{File: x.Source(), Binary: true}
+		case err != nil:
+			v = &serveError{File: x.Source(), Error: resultError(err).Error()}
+		default:
+			v = &match{
+				File:   x.Source(),
+				Line:   x.Line(),
+				Offset: x.Offset(),
+				Text:   x.Text(),
+			}
+		}
+		if err := enc.Encode(v); err != nil {
+			// The client went away, drain the results canceled by the context
+			for range resultC {
+			}
+			return
+		}
+		flusher.Flush()
+	}
+}
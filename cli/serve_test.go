@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeGrep(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		allowed = filepath.Join(dir, "allowed")
+		secret  = filepath.Join(dir, "secret")
+	)
+	assert.Nil(t, os.MkdirAll(allowed, 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(allowed, "a.log"), []byte("crimson a\nsnow\n"), 0o644))
+	assert.Nil(t, os.WriteFile(secret, []byte("crimson secret\n"), 0o644))
+	assert.Nil(t, os.Symlink(secret, filepath.Join(allowed, "link")))
+	dirResolved, err := resolvePath(allowed)
+	assert.Nil(t, err)
+	s := &grepServer{
+		allowed:  []string{dirResolved},
+		grepper:  newServeGrepper(2, 16),
+		maxBytes: 1 << 20,
+	}
+	server := httptest.NewServer(http.HandlerFunc(s.handleGrep))
+	defer server.Close()
+
+	post := func(body string) (int, []string) {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		sort.Strings(lines)
+		return resp.StatusCode, lines
+	}
+
+	t.Run("content", func(t *testing.T) {
+		status, lines := post(`{"patterns":["crim"],"content":"snow\ncrimson\n"}`)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, []string{`{"file":"-","line":2,"offset":5,"text":"crimson"}`}, lines)
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		status, lines := post(`{"patterns":["crim"],"only_matching":true,"paths":["` + filepath.Join(allowed, "a.log") + `","` + allowed + `"]}`)
+		assert.Equal(t, http.StatusOK, status)
+		if assert.Equal(t, 2, len(lines)) {
+			assert.Equal(t, `{"file":"`+filepath.Join(allowed, "a.log")+`","line":1,"offset":0,"text":"crim"}`, lines[1])
+			assert.Contains(t, lines[0], `"error":`)
+		}
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		for _, path := range []string{secret, filepath.Join(allowed, "link"), filepath.Join(allowed, "..", "secret"), filepath.Join(allowed, "missing")} {
+			status, _ := post(`{"patterns":["crim"],"paths":["` + path + `"]}`)
+			assert.Equal(t, http.StatusForbidden, status, path)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"content":"crimson"}`,
+			`{"patterns":["crim"]}`,
+			`{"patterns":["("],"content":"crimson"}`,
+			`{`,
+		} {
+			status, _ := post(body)
+			assert.Equal(t, http.StatusBadRequest, status, body)
+		}
+		resp, err := http.Get(server.URL)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
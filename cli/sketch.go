@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"math"
+	"sort"
+)
+
+// sketchAccuracy is the relative accuracy of the quantiles of quantileSketch.
+const sketchAccuracy = 0.01
+
+// quantileSketch estimates the quantiles of the values within the relative accuracy in bounded memory
+// by counting the values in the buckets of logarithmic widths like DDSketch.
+// The number of the buckets grows with the logarithm of the range of the values, not with the number of the values.
+type quantileSketch struct {
+	gamma    float64
+	positive map[int]int // the counts by the buckets of the values
+	negative map[int]int // the counts by the buckets of the absolute values
+	zero     int
+	count    int
+	min, max float64 // the estimates are clamped to
+}
+
+func newQuantileSketch() *quantileSketch {
+	return &quantileSketch{
+		gamma:    (1 + sketchAccuracy) / (1 - sketchAccuracy),
+		positive: map[int]int{},
+		negative: map[int]int{},
+	}
+}
+
+// bucket returns the bucket of the positive value.
+func (s *quantileSketch) bucket(v float64) int {
+	return int(math.Ceil(math.Log(v) / math.Log(s.gamma)))
+}
+
+// value returns the representative value of the bucket.
+func (s *quantileSketch) value(bucket int) float64 {
+	return 2 * math.Pow(s.gamma, float64(bucket)) / (s.gamma + 1)
+}
+
+func (s *quantileSketch) add(v float64) {
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.count++
+	switch {
+	case v > 0:
+		s.positive[s.bucket(v)]++
+	case v < 0:
+		s.negative[s.bucket(-v)]++
+	default:
+		s.zero++
+	}
+}
+
+// quantile returns the estimate of the q-quantile, 0 <= q <= 1, within the min and the max of the values.
+// Returns NaN if no values are added.
+func (s *quantileSketch) quantile(q float64) float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+	return math.Min(s.max, math.Max(s.min, s.estimate(q)))
+}
+
+func (s *quantileSketch) estimate(q float64) float64 {
+	rank := int(math.Ceil(q * float64(s.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	// From the smallest: the negative values of the largest absolute values first
+	negatives := sortedBuckets(s.negative)
+	for i := len(negatives) - 1; i >= 0; i-- {
+		if rank -= s.negative[negatives[i]]; rank <= 0 {
+			return -s.value(negatives[i])
+		}
+	}
+	if rank -= s.zero; rank <= 0 {
+		return 0
+	}
+	positives := sortedBuckets(s.positive)
+	for _, b := range positives {
+		if rank -= s.positive[b]; rank <= 0 {
+			return s.value(b)
+		}
+	}
+	return s.value(positives[len(positives)-1])
+}
+
+func sortedBuckets(m map[int]int) []int {
+	xs := make([]int, 0, len(m))
+	for b := range m {
+		xs = append(xs, b)
+	}
+	sort.Ints(xs)
+	return xs
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/berquerant/gogrep"
+)
+
+// The reasons of the skipped files in addition to gogrep.SkipReason.
+const (
+	skipIgnored    = "ignore"     // matched by the ignore files
+	skipHidden     = "hidden"     // the dotfiles and the dot-directories without -hidden
+	skipExcluded   = "exclude"    // matched by -exclude, -exclude-dir or -type-not
+	skipTooLarge   = "too-large"  // larger than -max-filesize
+	skipLoop       = "loop"       // the directory entered already by -follow-symlinks
+	skipTooDeep    = "too-deep"   // the directory at -max-depth
+	skipOutput     = "output"     // the output of the grep
+	skipDuplicate  = "duplicate"  // grepped already with -dedup
+	skipUnchanged  = "unchanged"  // the same content as the manifest of -changed-only
+	skipCompressed = "compressed" // not decompressed with -checkpoint
+)
+
+// skipRecord is a line of -report-skipped.
+type skipRecord struct {
+	Root   string `json:"root,omitempty"`
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+	// Line and Offset are of the first record skipped in the file, 0 if the whole file is skipped.
+	Line   int   `json:"line,omitempty"`
+	Offset int64 `json:"offset,omitempty"`
+}
+
+// skipReporter writes the records of -report-skipped.
+type skipReporter struct {
+	mux  sync.Mutex
+	file *os.File // nil for stderr
+	enc  *json.Encoder
+	err  error // the first error of writing
+	n    int   // the number of the records
+}
+
+// skipReport is nil without -report-skipped.
+var skipReport *skipReporter
+
+// openSkipReport opens the file of -report-skipped, - for stderr.
+func openSkipReport(name string) (*skipReporter, error) {
+	if name == stdinPath {
+		return &skipReporter{
+			enc: json.NewEncoder(os.Stderr),
+		}, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &skipReporter{
+		file: f,
+		enc:  json.NewEncoder(f),
+	}, nil
+}
+
+// report writes the record, nil-safe.
+func (s *skipReporter) report(r *skipRecord) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.n++
+	if err := s.enc.Encode(r); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// reportTarget writes the record of the target skipped, nil-safe.
+func (s *skipReporter) reportTarget(t *target, reason string) {
+	s.report(&skipRecord{
+		Root:   t.root,
+		File:   t.name(),
+		Reason: reason,
+	})
+}
+
+// reportResult writes the record if the result is a gogrep.SkipError.
+// Returns false if the result is not skipped.
+func (s *skipReporter) reportResult(t *target, r gogrep.Result) bool {
+	var err *gogrep.SkipError
+	if !errors.As(r.Err(), &err) {
+		return false
+	}
+	s.report(&skipRecord{
+		Root:   t.root,
+		File:   t.name(),
+		Reason: string(err.Reason),
+		Line:   r.Line(),
+		Offset: r.Offset(),
+	})
+	return true
+}
+
+// skipped returns the number of the contents skipped, nil-safe.
+func (s *skipReporter) skipped() int {
+	if s == nil {
+		return 0
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.n
+}
+
+func (s *skipReporter) close() error {
+	if s == nil {
+		return nil
+	}
+	if s.file != nil {
+		if err := s.file.Close(); s.err == nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
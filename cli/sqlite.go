@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/berquerant/gogrep/sqlsink"
+	_ "modernc.org/sqlite"
+)
+
+// matchDB is the -sqlite database.
+var matchDB *sqliteSink
+
+// sqliteSink inserts the matches into the database.
+type sqliteSink struct {
+	db   *sql.DB
+	sink *sqlsink.Sink
+}
+
+// openSQLite opens the database file and starts a run identified by the current time.
+func openSQLite(file string) (*sqliteSink, error) {
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := sqlsink.New(context.Background(), db, clock.Now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteSink{
+		db:   db,
+		sink: sink,
+	}, nil
+}
+
+func (s *sqliteSink) insert(m *match) error {
+	return s.sink.Insert(context.Background(), &sqlsink.Row{
+		Path:       m.File,
+		Line:       m.Line,
+		Offset:     m.Offset,
+		Text:       m.Text,
+		Submatches: m.submatches,
+	})
+}
+
+// commit commits the inserted matches and closes the database.
+func (s *sqliteSink) commit() error {
+	defer s.db.Close()
+	return s.sink.Close()
+}
+
+// rollback discards the inserted matches and closes the database.
+func (s *sqliteSink) rollback() {
+	defer s.db.Close()
+	_ = s.sink.Rollback()
+}
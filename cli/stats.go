@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/berquerant/gogrep"
+)
+
+// grepStats accumulates the stats of the targets for -stats.
+var grepStats = &statsSummary{}
+
+// statsSummary is the sum of the stats of the targets.
+type statsSummary struct {
+	mux   sync.Mutex
+	files int
+	total gogrep.Stats
+}
+
+// collect adds the stats of a target, called concurrently by the greps.
+func (s *statsSummary) collect(x gogrep.Stats) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.files++
+	s.total.Add(x)
+}
+
+// print writes the summary with the elapsed time of the whole run.
+// The utilization of a worker is the ratio of its busy time to the sum of the durations of the targets.
+func (s *statsSummary) print(w io.Writer, elapsed time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	msg.Fprintf(w, "stats: files=%d lines=%d bytes=%d matched=%d elapsed=%s\n",
+		s.files, s.total.LinesScanned, s.total.BytesRead, s.total.LinesMatched, elapsed)
+	for i, u := range s.total.Utilization() {
+		x := s.total.Workers[i]
+		msg.Fprintf(w, "stats: worker=%d chunks=%d busy=%s utilization=%.2f\n", i, x.Chunks, x.Busy, u)
+	}
+	if s.total.Cancellation != "" {
+		msg.Fprintf(w, "stats: canceled=%s\n", s.total.Cancellation)
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var blockBuffered blockSizeFlag
+
+var lineBuffered = commandLine.Bool("line-buffered", false, "Flush the output after every line and grep the lines of a slow source like a pipe soon, e.g. for tail -f log | gogrep -line-buffered REGEX | consumer. Default flushes every line only to a terminal and with -follow or -watch, and buffers the blocks of -block-buffered otherwise.")
+
+func init() {
+	commandLine.Var(&blockBuffered, "block-buffered", "Buffer the output in the blocks of the size like -block-buffered=1M, flushing them when full and at the end, for the fast output of many matches. -block-buffered alone means 64K.")
+}
+
+const (
+	// defaultBlockSize is the size of the blocks of the output by default.
+	defaultBlockSize = 64 << 10
+	// lineBufferedDelay is the max time a line read waits for the other lines to be grepped together with -line-buffered.
+	lineBufferedDelay = 50 * time.Millisecond
+)
+
+// blockSizeFlag is the size of -block-buffered, that can be set without the value like a bool flag.
+type blockSizeFlag struct {
+	value string
+	size  int
+}
+
+func (s *blockSizeFlag) String() string   { return s.value }
+func (s *blockSizeFlag) IsBoolFlag() bool { return true }
+func (s *blockSizeFlag) reset()           { *s = blockSizeFlag{} }
+func (s *blockSizeFlag) Set(v string) error {
+	switch v {
+	case "true":
+		s.value, s.size = v, defaultBlockSize
+		return nil
+	case "false":
+		s.value, s.size = "", 0
+		return nil
+	}
+	x, err := parseBytesValue(v)
+	if err != nil {
+		return err
+	}
+	if x < 1 {
+		return fmt.Errorf("invalid size %s", v)
+	}
+	s.value, s.size = v, int(x)
+	return nil
+}
+
+// stdout is the standard output of the matches, buffered by setupStdout.
+// The matches and the summaries written to os.Stdout directly would be out of order.
+var stdout io.Writer = os.Stdout
+
+// setupStdout buffers stdout by -line-buffered and -block-buffered,
+// and returns the function to flush the rest of the output at the end.
+func setupStdout() func() error {
+	size := blockBuffered.size
+	switch {
+	case *lineBuffered:
+		return func() error { return nil }
+	case size > 0:
+	case isTerminal(os.Stdout) || *follow || *watch:
+		return func() error { return nil }
+	default:
+		size = defaultBlockSize
+	}
+	w := &blockWriter{
+		w: bufio.NewWriterSize(os.Stdout, size),
+	}
+	stdout = w
+	return w.flush
+}
+
+// blockWriter buffers the output in the blocks.
+type blockWriter struct {
+	mux sync.Mutex
+	w   *bufio.Writer
+}
+
+func (s *blockWriter) Write(p []byte) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.w.Write(p)
+}
+
+func (s *blockWriter) flush() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.w.Flush()
+}
@@ -0,0 +1,279 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/berquerant/gogrep"
+)
+
+const stressUsage = `Usage of gogrep stress
+  gogrep stress [flags]
+    Run the randomized concurrent greps of the generated corpus for the reliability tests:
+    the greps consumed fully, canceled, abandoned by the consumer and interrupted by the signals.
+    Fails if a grep does not end within -timeout, a full grep gets the wrong matches
+    or the goroutines are left after the greps.
+Flags:`
+
+// stressScenario is the way to end a grep of the stress.
+type stressScenario int
+
+const (
+	stressFull    stressScenario = iota // read all the results
+	stressCancel                        // cancel after a random delay
+	stressAbandon                       // stop reading, cancel and drain later
+	stressSignal                        // interrupted by the signal sent to the process
+	stressScenarios
+)
+
+var stressScenarioNames = [...]string{"full", "cancel", "abandon", "signal"}
+
+// stressSource is a source of the stress with the number of its matched lines.
+type stressSource struct {
+	data    []byte
+	matches int
+}
+
+// stressRunner runs the greps of the stress.
+type stressRunner struct {
+	sources []*stressSource
+	match   string
+	timeout time.Duration
+	counts  [stressScenarios]int64
+}
+
+func runStress(args []string) error {
+	var (
+		fs       = flag.NewFlagSet("stress", flag.ContinueOnError)
+		duration = fs.Duration("duration", 10*time.Second, "Run the greps for the duration.")
+		workers  = fs.Int("workers", runtime.NumCPU(), "The number of the greps run concurrently.")
+		seed     = fs.Int64("seed", 1, "The seed of the random numbers of the corpus and the greps.")
+		sources  = fs.Int("sources", 16, "The number of the sources of the corpus.")
+		lines    = fs.String("lines", "10K", "The max number of the lines of a source with the optional suffix K, M or G of 1000s.")
+		timeout  = fs.Duration("timeout", 30*time.Second, "Fail if a grep does not end within the duration.")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), stressUsage)
+		fs.PrintDefaults()
+	}
+	if err := parseFlagSet(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New(stressUsage)
+	}
+	if *workers < 1 || *sources < 1 {
+		return errors.New("-workers and -sources should be positive")
+	}
+	maxLines, err := parseCount(*lines)
+	if err != nil || maxLines < 1 {
+		return fmt.Errorf("invalid -lines %s", *lines)
+	}
+
+	// Catch the interrupts sent by the greps not to be killed, so the stress ends only by -duration
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt)
+	defer signal.Stop(sigC)
+	baseline := runtime.NumGoroutine()
+	s := newStressRunner(rand.New(rand.NewSource(*seed)), *sources, maxLines, *timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		failures = make(chan error, *workers)
+	)
+	wg.Add(*workers)
+	for i := 0; i < *workers; i++ {
+		go func(r *rand.Rand) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if err := s.run(r); err != nil {
+					failures <- err
+					cancel()
+					return
+				}
+			}
+		}(rand.New(rand.NewSource(*seed + int64(i) + 1)))
+	}
+	wg.Wait()
+	close(failures)
+	for i, n := range s.counts {
+		fmt.Fprintf(os.Stderr, "stress: %s=%d\n", stressScenarioNames[i], n)
+	}
+	if err := <-failures; err != nil {
+		return err
+	}
+	return waitGoroutines(baseline, *timeout)
+}
+
+func newStressRunner(r *rand.Rand, sources int, maxLines int64, timeout time.Duration) *stressRunner {
+	c := &genConfig{
+		matchRate: 0.05,
+		minLen:    10,
+		maxLen:    200,
+		match:     "MATCH",
+	}
+	s := &stressRunner{
+		match:   c.match,
+		timeout: timeout,
+	}
+	for i := 0; i < sources; i++ {
+		var (
+			b bytes.Buffer
+			n = r.Int63n(maxLines + 1)
+		)
+		_ = c.write(&b, r, n)
+		s.sources = append(s.sources, &stressSource{
+			data:    b.Bytes(),
+			matches: strings.Count(b.String(), c.match), // a line has a token at most since the lines have no capitals
+		})
+	}
+	return s
+}
+
+// run runs a grep of a random scenario and fails if the grep does not end within the timeout.
+func (s *stressRunner) run(r *rand.Rand) error {
+	var (
+		scenario = stressScenario(r.Intn(int(stressScenarios)))
+		options  = []gogrep.Option{
+			gogrep.WithThreads(1 + r.Intn(8)),
+			gogrep.WithResultBufferSize(r.Intn(4)),
+		}
+		sources = make([]*stressSource, 1+r.Intn(len(s.sources)))
+		api     = r.Intn(3)
+		delay   = time.Duration(r.Intn(2000)) * time.Microsecond
+		keep    = r.Intn(10) // the results read before the abandonment
+	)
+	for i := range sources {
+		sources[i] = s.sources[r.Intn(len(s.sources))]
+	}
+	if api == 2 {
+		sources = sources[:1]
+	}
+	doneC := make(chan error, 1)
+	go func() {
+		doneC <- s.grep(scenario, options, sources, api, delay, keep)
+	}()
+	select {
+	case err := <-doneC:
+		if err != nil {
+			return fmt.Errorf("stress %s: %w", stressScenarioNames[scenario], err)
+		}
+		atomic.AddInt64(&s.counts[scenario], 1)
+		return nil
+	case <-time.After(s.timeout):
+		return fmt.Errorf("stress %s: grep did not end within %s\n%s", stressScenarioNames[scenario], s.timeout, allStacks())
+	}
+}
+
+// grep greps the sources by GrepSources, GrepMulti or GrepReaderAt by api and ends it by the scenario.
+func (s *stressRunner) grep(scenario stressScenario, options []gogrep.Option, sources []*stressSource, api int, delay time.Duration, keep int) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if scenario == stressSignal {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		go interrupt(ctx, delay)
+	}
+	var (
+		resultC <-chan gogrep.Result
+		err     error
+		g       = gogrep.New(options...)
+		want    int
+	)
+	for _, src := range sources {
+		want += src.matches
+	}
+	switch api {
+	case 0:
+		named := make([]gogrep.NamedSource, len(sources))
+		for i, src := range sources {
+			named[i] = gogrep.NamedSource{
+				Name:   fmt.Sprint(i),
+				Reader: bytes.NewReader(src.data),
+			}
+		}
+		resultC, err = g.GrepSources(ctx, []string{s.match}, named)
+	case 1:
+		resultC, err = g.GrepMulti(ctx, []string{s.match}, bytes.NewReader(sources[0].data))
+		want = sources[0].matches
+	default:
+		resultC, err = g.GrepReaderAt(ctx, []string{s.match}, bytes.NewReader(sources[0].data))
+		want = sources[0].matches
+	}
+	if err != nil {
+		return err
+	}
+
+	switch scenario {
+	case stressCancel:
+		time.AfterFunc(delay, cancel)
+	case stressAbandon:
+		for i := 0; i < keep; i++ {
+			if _, ok := <-resultC; !ok {
+				return nil
+			}
+		}
+		cancel()
+		time.Sleep(delay) // the workers are blocked by the results unread
+	}
+	var got int
+	for x := range resultC {
+		if err := x.Err(); err != nil {
+			if scenario == stressFull || !errors.Is(err, context.Canceled) {
+				return err
+			}
+			continue
+		}
+		if !strings.Contains(x.Text(), s.match) {
+			return fmt.Errorf("unexpected match %q", x.Text())
+		}
+		got++
+	}
+	if scenario == stressFull && got != want {
+		return fmt.Errorf("got %d matches want %d", got, want)
+	}
+	return nil
+}
+
+// interrupt sends the interrupt to the process after the delay unless the grep ends.
+func interrupt(ctx context.Context, delay time.Duration) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return
+	}
+	_ = p.Signal(os.Interrupt) // unsupported on windows, the grep ends normally
+}
+
+// waitGoroutines fails if the goroutines are more than the baseline after the timeout.
+func waitGoroutines(baseline int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= baseline {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("stress: %d goroutines leaked\n%s", n-baseline, allStacks())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
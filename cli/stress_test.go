@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStressRunner(t *testing.T) {
+	t.Run("corpus", func(t *testing.T) {
+		s := newStressRunner(rand.New(rand.NewSource(1)), 8, 1000, time.Minute)
+		assert.Equal(t, 8, len(s.sources))
+		var total int
+		for _, src := range s.sources {
+			var want int
+			for _, line := range strings.Split(string(src.data), "\n") {
+				if strings.Contains(line, s.match) {
+					want++
+				}
+			}
+			assert.Equal(t, want, src.matches)
+			total += src.matches
+		}
+		assert.True(t, total > 0)
+	})
+
+	const source = "a MATCH\nb\nMATCH c\nd\n"
+	grep := func(match string, matches int, api int) error {
+		s := &stressRunner{
+			sources: []*stressSource{{data: []byte(source), matches: matches}},
+			match:   match,
+			timeout: time.Minute,
+		}
+		return s.grep(stressFull, []gogrep.Option{gogrep.WithThreads(2)}, s.sources, api, 0, 0)
+	}
+	for api, name := range []string{"sources", "multi", "reader at"} {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, grep("MATCH", 2, api))
+			assert.EqualError(t, grep("MATCH", 3, api), "got 2 matches want 3", "the matches missed")
+			assert.EqualError(t, grep("MATCH", 1, api), "got 2 matches want 1", "the matches too many")
+			assert.EqualError(t, grep("M.TCH", 2, api), `unexpected match "a MATCH"`)
+		})
+	}
+
+	t.Run("counts", func(t *testing.T) {
+		// Not to be interrupted by the signal scenario
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, os.Interrupt)
+		defer signal.Stop(sigC)
+
+		const runs = 40
+		var (
+			r = rand.New(rand.NewSource(1))
+			s = newStressRunner(r, 4, 100, time.Minute)
+		)
+		for i := 0; i < runs; i++ {
+			if !assert.Nil(t, s.run(r)) {
+				return
+			}
+		}
+		var total int64
+		for i, n := range s.counts {
+			assert.True(t, n > 0, "scenario %s", stressScenarioNames[i])
+			total += n
+		}
+		assert.Equal(t, int64(runs), total)
+	})
+}
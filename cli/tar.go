@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/berquerant/gogrep"
+)
+
+const (
+	stdinRaw = "raw"
+	stdinTar = "tar"
+)
+
+func checkStdinFormat(format string) error {
+	switch format {
+	case stdinRaw, stdinTar:
+		return nil
+	default:
+		return fmt.Errorf("unknown stdin format %s", format)
+	}
+}
+
+// grepTar greps the regular files in the tar stream in order.
+// The members are labeled by their paths.
+func grepTar(ctx context.Context, patterns []string, r io.Reader) error {
+	if *decompress {
+		d := gogrep.NewDecodingReader(r)
+		defer d.Close()
+		r = d
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read tar: %w", err)
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if err := grepSources(ctx, patterns, []*target{{
+			path:   h.Name,
+			reader: tr,
+		}}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// isTemplateFormat returns true if -format is a template like '{{.File}}:{{.Line}}:{{.Text}}'.
+func isTemplateFormat(format string) bool {
+	return strings.Contains(format, "{{")
+}
+
+// templateFormatter writes each match by the text/template of -format,
+// terminated by a newline, or NUL if -z.
+type templateFormatter struct {
+	tmpl *template.Template
+}
+
+func newTemplateFormatter(format string) (*templateFormatter, error) {
+	tmpl, err := template.New("format").Option("missingkey=error").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format: %w", err)
+	}
+	return &templateFormatter{
+		tmpl: tmpl,
+	}, nil
+}
+
+// templateMatch is the data of the template of -format.
+type templateMatch struct {
+	Root        string
+	File        string
+	Line        int
+	Column      int // 1-based column of the first match in runes, 0 if unknown
+	Offset      int64
+	Text        string
+	Submatches  []string // the match and the capture groups with -o, nil otherwise
+	Fingerprint string
+	Owners      []string
+	Binary      bool
+	Distance    int // the edit distance of the match with -fuzzy
+}
+
+func (s *templateFormatter) format(w io.Writer, m *match) error {
+	data := &templateMatch{
+		Root:        m.Root,
+		File:        m.File,
+		Line:        m.Line,
+		Offset:      m.Offset,
+		Text:        m.Text,
+		Submatches:  m.submatches,
+		Fingerprint: m.Fingerprint,
+		Owners:      m.Owners,
+		Binary:      m.Binary,
+	}
+	if m.Distance != nil {
+		data.Distance = *m.Distance
+	}
+	if len(m.ranges) > 0 && m.ranges[0][0] <= len(m.Text) {
+		data.Column = utf8.RuneCountInString(m.Text[:m.ranges[0][0]]) + 1
+	}
+	var b strings.Builder
+	if err := s.tmpl.Execute(&b, data); err != nil {
+		return fmt.Errorf("cannot format %s:%d: %w", m.File, m.Line, err)
+	}
+	if *nullData {
+		b.WriteByte(0)
+	} else {
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
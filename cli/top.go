@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"container/heap"
+	"regexp"
+
+	"github.com/berquerant/gogrep"
+)
+
+// topMatches keeps the -top matches with the highest scores by -score-by in a heap,
+// to print them after all the targets are grepped.
+// The results of the matches are kept by gogrep.Compact and made into the matches again when printed.
+type topMatches struct {
+	k       int
+	scorer  *captureExpr
+	regexes []*regexp.Regexp
+	seq     int
+	heap    scoredMatches
+}
+
+// matchTop is the -top matches, nil without -top.
+var matchTop *topMatches
+
+func newTopMatches(k int, expr string, patterns []string) (*topMatches, error) {
+	s, err := parseCaptureExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexes, err := compilePatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return &topMatches{
+		k:       k,
+		scorer:  s,
+		regexes: regexes,
+	}, nil
+}
+
+// add keeps the result of the target if the score of the match is in the top.
+// The matches without the scores are dropped.
+func (s *topMatches) add(t *target, r gogrep.Result, m *match) {
+	score, ok := s.scorer.eval(s.regexes, m.Text)
+	if !ok {
+		return
+	}
+	s.seq++
+	x := &scoredMatch{
+		target: t,
+		score:  score,
+		seq:    s.seq,
+	}
+	if len(s.heap) < s.k {
+		x.result = gogrep.Compact(r)
+		heap.Push(&s.heap, x)
+		return
+	}
+	if s.heap.less(s.heap[0], x) {
+		x.result = gogrep.Compact(r)
+		s.heap[0] = x
+		heap.Fix(&s.heap, 0)
+	}
+}
+
+// flush prints the kept matches in descending order of the scores, the earlier first on a tie.
+func (s *topMatches) flush() {
+	xs := make([]*scoredMatch, len(s.heap))
+	for i := len(xs) - 1; i >= 0; i-- {
+		xs[i] = heap.Pop(&s.heap).(*scoredMatch)
+	}
+	for _, x := range xs {
+		if text, ok := resultText(x.result); ok {
+			emitMatch(newMatch(x.target, x.result, text))
+		}
+	}
+}
+
+type scoredMatch struct {
+	target *target
+	result gogrep.Result
+	score  float64
+	seq    int // the order of the matches added
+}
+
+// scoredMatches is a min-heap of the matches by the scores, where the later is less on a tie.
+type scoredMatches []*scoredMatch
+
+func (scoredMatches) less(a, b *scoredMatch) bool {
+	if a.score != b.score {
+		return a.score < b.score
+	}
+	return a.seq > b.seq
+}
+
+func (s scoredMatches) Len() int            { return len(s) }
+func (s scoredMatches) Less(i, j int) bool  { return s.less(s[i], s[j]) }
+func (s scoredMatches) Swap(i, j int)       { s[i], s[j] = s[j], s[i] }
+func (s *scoredMatches) Push(x interface{}) { *s = append(*s, x.(*scoredMatch)) }
+func (s *scoredMatches) Pop() interface{} {
+	old := *s
+	x := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	trimText     = commandLine.Bool("trim", false, "Remove the leading and trailing spaces of the printed texts. The matching is not affected.")
+	lowerText    = commandLine.Bool("lower", false, "Print the texts in lower case. The matching is not affected.")
+	squeezeSpace = commandLine.Bool("squeeze-space", false, "Replace each run of the spaces in the printed texts with a space. The matching is not affected.")
+)
+
+// textTransform transforms a printed text.
+// offsets[i] is the offset in the transformed text of the byte i of the text, len(text)+1 offsets,
+// to move the match ranges along with the text.
+type textTransform func(text string) (transformed string, offsets []int)
+
+// outputTransforms are the transforms of the printed texts by the flags in order of application.
+var outputTransforms = []struct {
+	enabled   *bool
+	transform textTransform
+}{
+	{trimText, trimSpaces},
+	{squeezeSpace, squeezeSpaces},
+	{lowerText, lowerCase},
+}
+
+// transformText applies the transforms of the flags to the text and the match ranges in the text.
+func transformText(text string, ranges [][2]int) (string, [][2]int) {
+	for _, x := range outputTransforms {
+		if !*x.enabled {
+			continue
+		}
+		var offsets []int
+		text, offsets = x.transform(text)
+		ranges = moveRanges(ranges, offsets)
+	}
+	return text, ranges
+}
+
+// moveRanges returns the ranges moved by the offsets of a transform, without the ranges removed by the transform.
+func moveRanges(ranges [][2]int, offsets []int) [][2]int {
+	if len(ranges) == 0 {
+		return ranges
+	}
+	r := make([][2]int, 0, len(ranges))
+	for _, x := range ranges {
+		start, end := offsets[x[0]], offsets[x[1]]
+		if start < end {
+			r = append(r, [2]int{start, end})
+		}
+	}
+	return r
+}
+
+func trimSpaces(text string) (string, []int) {
+	var (
+		left   = len(text) - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+		right  = len(strings.TrimRightFunc(text[left:], unicode.IsSpace)) + left
+		offset = make([]int, len(text)+1)
+	)
+	for i := range offset {
+		switch {
+		case i < left:
+			offset[i] = 0
+		case i > right:
+			offset[i] = right - left
+		default:
+			offset[i] = i - left
+		}
+	}
+	return text[left:right], offset
+}
+
+func squeezeSpaces(text string) (string, []int) {
+	var (
+		b      strings.Builder
+		offset = make([]int, len(text)+1)
+		space  bool // the last rune is a space
+	)
+	for i, c := range text {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		for j := 0; j < size; j++ {
+			offset[i+j] = b.Len()
+		}
+		if unicode.IsSpace(c) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteString(text[i : i+size])
+	}
+	offset[len(text)] = b.Len()
+	return b.String(), offset
+}
+
+func lowerCase(text string) (string, []int) {
+	var (
+		b      strings.Builder
+		offset = make([]int, len(text)+1)
+	)
+	for i, c := range text {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		for j := 0; j < size; j++ {
+			offset[i+j] = b.Len()
+		}
+		if c == utf8.RuneError {
+			b.WriteString(text[i : i+size]) // keep the invalid bytes
+			continue
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	offset[len(text)] = b.Len()
+	return b.String(), offset
+}
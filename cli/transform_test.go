@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformText(t *testing.T) {
+	for _, tc := range []struct {
+		title      string
+		trim       bool
+		lower      bool
+		squeeze    bool
+		text       string
+		ranges     [][2]int
+		want       string
+		wantRanges [][2]int
+	}{
+		{
+			title:      "none",
+			text:       "  Grand  Theft ",
+			ranges:     [][2]int{{2, 7}},
+			want:       "  Grand  Theft ",
+			wantRanges: [][2]int{{2, 7}},
+		},
+		{
+			title:      "trim",
+			trim:       true,
+			text:       "  Grand  Theft ",
+			ranges:     [][2]int{{0, 3}, {9, 15}},
+			want:       "Grand  Theft",
+			wantRanges: [][2]int{{0, 1}, {7, 12}},
+		},
+		{
+			title:      "trim spaces only",
+			trim:       true,
+			text:       "  ",
+			ranges:     [][2]int{{0, 2}},
+			want:       "",
+			wantRanges: [][2]int{},
+		},
+		{
+			title:      "squeeze",
+			squeeze:    true,
+			text:       "grand \t theft\n",
+			ranges:     [][2]int{{8, 13}},
+			want:       "grand theft ",
+			wantRanges: [][2]int{{6, 11}},
+		},
+		{
+			title:      "lower",
+			lower:      true,
+			text:       "GRAND İ Theft",
+			ranges:     [][2]int{{9, 14}},
+			want:       "grand i theft",
+			wantRanges: [][2]int{{8, 13}},
+		},
+		{
+			title:  "lower invalid",
+			lower:  true,
+			text:   "A\xffB",
+			want:   "a\xffb",
+			ranges: nil,
+		},
+		{
+			title:      "all",
+			trim:       true,
+			lower:      true,
+			squeeze:    true,
+			text:       " Grand   THEFT  Wumps ",
+			ranges:     [][2]int{{9, 14}},
+			want:       "grand theft wumps",
+			wantRanges: [][2]int{{6, 11}},
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			defer func(trim, lower, squeeze bool) {
+				*trimText, *lowerText, *squeezeSpace = trim, lower, squeeze
+			}(*trimText, *lowerText, *squeezeSpace)
+			*trimText, *lowerText, *squeezeSpace = tc.trim, tc.lower, tc.squeeze
+			got, gotRanges := transformText(tc.text, tc.ranges)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantRanges, gotRanges)
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/berquerant/gogrep"
+)
+
+// flagRequirements are the flags that require the other flags.
+var flagRequirements = [][2]string{
+	{"update-baseline", "baseline"},
+	{"group-by-owner", "codeowners"},
+	{"include", "root"},
+	{"exclude", "root"},
+	{"exclude-dir", "root"},
+	{"type", "root"},
+	{"type-not", "root"},
+	{"type-add", "root"},
+	{"max-filesize", "root"},
+	{"max-depth", "root"},
+	{"min-depth", "root"},
+	{"in-place", "replace"},
+	{"transactional", "in-place"},
+	{"line-ending", "replace"},
+	{"top", "score-by"},
+	{"score-by", "top"},
+	{"0", "files-from"},
+	{"watch-debounce", "watch"},
+	{"resume", "checkpoint"},
+}
+
+// flagConflicts are the sets of the flags that cannot be used together.
+var flagConflicts = [][]string{
+	{"l", "L"},
+	{"mmap", "direct"},
+	{"remote", "q"},
+	{"remote", "l"},
+	{"remote", "L"},
+	{"go-ident", "e"},
+	{"go-ident", "f"},
+	{"go-ident", "root"},
+	{"go-ident", "remote"},
+	{"sqlite", "q"},
+	{"sqlite", "l"},
+	{"sqlite", "L"},
+	{"sqlite", "update-baseline"},
+	{"sqlite", "group-by-owner"},
+	{"replace", "o"},
+	{"replace", "group"},
+	{"replace", "q"},
+	{"replace", "l"},
+	{"replace", "L"},
+	{"replace", "U"},
+	{"replace", "z"},
+	{"replace", "remote"},
+	{"replace", "go-ident"},
+	{"replace", "sqlite"},
+	{"replace", "baseline"},
+	{"replace", "format"},
+	{"replace", "stdin-format"},
+	{"replace", "search-archives"},
+	{"where", "replace"},
+	{"where", "remote"},
+	{"heading", "format"},
+	{"aggregate", "top"},
+	{"aggregate", "q"},
+	{"aggregate", "l"},
+	{"aggregate", "L"},
+	{"aggregate", "replace"},
+	{"aggregate", "follow"},
+	{"aggregate", "format"},
+	{"aggregate", "sqlite"},
+	{"aggregate", "baseline"},
+	{"aggregate", "group-by-owner"},
+	{"aggregate", "remote"},
+	{"top", "q"},
+	{"top", "l"},
+	{"top", "L"},
+	{"top", "replace"},
+	{"top", "follow"},
+	{"follow", "l"},
+	{"follow", "L"},
+	{"follow", "replace"},
+	{"follow", "remote"},
+	{"follow", "search-archives"},
+	{"follow", "stdin-format"},
+	{"follow", "update-baseline"},
+	{"follow", "group-by-owner"},
+	{"follow", "source-timeout"},
+	{"follow", "U"},
+	{"run-metadata", "q"},
+	{"run-metadata", "l"},
+	{"run-metadata", "L"},
+	{"run-metadata", "sqlite"},
+	{"run-metadata", "update-baseline"},
+	{"run-metadata", "group-by-owner"},
+	{"run-metadata", "replace"},
+	{"unique", "replace"},
+	{"unique", "remote"},
+	{"unique-by", "replace"},
+	{"unique-by", "remote"},
+	{"sort", "c"},
+	{"sort", "count-matches"},
+	{"sort", "l"},
+	{"sort", "L"},
+	{"sort", "follow"},
+	{"sort", "top"},
+	{"sort", "aggregate"},
+	{"sort", "replace"},
+	{"sort", "remote"},
+	{"c", "count-matches"},
+	{"c", "l"},
+	{"c", "L"},
+	{"c", "replace"},
+	{"c", "remote"},
+	{"c", "follow"},
+	{"c", "top"},
+	{"c", "aggregate"},
+	{"c", "format"},
+	{"c", "sqlite"},
+	{"count-matches", "l"},
+	{"count-matches", "L"},
+	{"count-matches", "replace"},
+	{"count-matches", "remote"},
+	{"count-matches", "follow"},
+	{"count-matches", "top"},
+	{"count-matches", "aggregate"},
+	{"count-matches", "format"},
+	{"count-matches", "sqlite"},
+	{"strict", "replace"},
+	{"strict", "remote"},
+	{"report-skipped", "replace"},
+	{"report-skipped", "remote"},
+	{"files-from", "replace"},
+	{"files-from", "remote"},
+	{"files-from", "follow"},
+	{"files-from", "go-ident"},
+	{"files-from", "stdin-format"},
+	{"watch", "follow"},
+	{"watch", "files-from"},
+	{"watch", "replace"},
+	{"watch", "remote"},
+	{"watch", "search-archives"},
+	{"watch", "stdin-format"},
+	{"watch", "q"},
+	{"watch", "l"},
+	{"watch", "L"},
+	{"watch", "c"},
+	{"watch", "count-matches"},
+	{"watch", "top"},
+	{"watch", "aggregate"},
+	{"watch", "update-baseline"},
+	{"watch", "group-by-owner"},
+	{"watch", "dedup"},
+	{"progress", "remote"},
+	{"trim", "replace"},
+	{"lower", "replace"},
+	{"squeeze-space", "replace"},
+	{"F", "engine"},
+	{"fuzzy", "F", "engine"},
+	{"glob", "F", "engine", "fuzzy", "replace"},
+	{"P", "F", "engine", "fuzzy", "glob", "replace"},
+	{"fuzzy", "explain"},
+	{"fuzzy", "replace"},
+	{"normalize", "explain"},
+	{"normalize", "replace"},
+	{"i", "go-ident"},
+	{"checksum", "remote"},
+	{"checksum", "follow"},
+	{"checksum", "replace"},
+	{"changed-only", "remote"},
+	{"changed-only", "follow"},
+	{"changed-only", "watch"},
+	{"line-buffered", "block-buffered"},
+	{"checkpoint", "c"},
+	{"checkpoint", "count-matches"},
+	{"checkpoint", "l"},
+	{"checkpoint", "L"},
+	{"checkpoint", "U"},
+	{"checkpoint", "encoding"},
+	{"checkpoint", "sort"},
+	{"checkpoint", "unique"},
+	{"checkpoint", "unique-by"},
+	{"checkpoint", "top"},
+	{"checkpoint", "aggregate"},
+	{"checkpoint", "follow"},
+	{"checkpoint", "watch"},
+	{"checkpoint", "replace"},
+	{"checkpoint", "remote"},
+	{"checkpoint", "search-archives"},
+	{"checkpoint", "mmap"},
+	{"checkpoint", "direct"},
+}
+
+// validateFlags rejects the invalid values and the incompatible combinations of the flags.
+func validateFlags() error {
+	for _, r := range flagRequirements {
+		if isFlagSet(r[0]) && !isFlagSet(r[1]) {
+			return errors.New(msg.Sprintf("-%s requires -%s", r[0], r[1]))
+		}
+	}
+	for _, c := range flagConflicts {
+		var set []string
+		for _, name := range c {
+			if isFlagSet(name) {
+				set = append(set, "-"+name)
+			}
+		}
+		if len(set) > 1 {
+			return errors.New(msg.Sprintf("%s are exclusive", strings.Join(set, msg.Sprintf(" and "))))
+		}
+	}
+	if *threads < 0 {
+		return fmt.Errorf("invalid j %d", *threads)
+	}
+	if *maxDepth < 0 {
+		return fmt.Errorf("invalid max-depth %d", *maxDepth)
+	}
+	if *minDepth < 0 || (*maxDepth > 0 && *minDepth > *maxDepth) {
+		return fmt.Errorf("invalid min-depth %d", *minDepth)
+	}
+	if *topK < 0 {
+		return fmt.Errorf("invalid top %d", *topK)
+	}
+	if *sourceTimeout < 0 {
+		return fmt.Errorf("invalid source-timeout %s", *sourceTimeout)
+	}
+	if *watchDebounce <= 0 {
+		return fmt.Errorf("invalid watch-debounce %s", *watchDebounce)
+	}
+	if err := checkUniqueBy(*uniqueBy); err != nil {
+		return err
+	}
+	if err := checkSort(*sortBy); err != nil {
+		return err
+	}
+	if err := checkStdinFormat(*stdinFormat); err != nil {
+		return err
+	}
+	if err := checkLineEnding(*lineEnding); err != nil {
+		return err
+	}
+	if err := checkFailOn(*failOn); err != nil {
+		return err
+	}
+	if err := checkChecksum(*checksumAlgorithm); err != nil {
+		return err
+	}
+	if err := checkNormalization(*normalization); err != nil {
+		return err
+	}
+	if *perlRegexp {
+		if _, err := pcreEngine(); err != nil {
+			return err
+		}
+	}
+	return checkAdvice(*fadviseMode)
+}
+
+// isFlagSet returns true if the flag is set to a value other than the default.
+func isFlagSet(name string) bool {
+	f := commandLine.Lookup(name)
+	if f == nil {
+		return false
+	}
+	if v, ok := f.Value.(*stringsFlag); ok {
+		return len(*v) > 0
+	}
+	if v, ok := f.Value.(*templateFlag); ok {
+		return v.set
+	}
+	return f.Value.String() != f.DefValue
+}
+
+func checkSort(by string) error {
+	switch gogrep.SortBy(by) {
+	case "", gogrep.SortByFile, gogrep.SortByLine, gogrep.SortByText:
+		return nil
+	default:
+		return fmt.Errorf("unknown sort %s", by)
+	}
+}
+
+func checkUniqueBy(by string) error {
+	switch gogrep.UniqueBy(by) {
+	case "", gogrep.UniqueText, gogrep.UniqueMatch:
+		return nil
+	default:
+		return fmt.Errorf("unknown unique-by %s", by)
+	}
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/berquerant/gogrep"
+)
+
+var printVersion = commandLine.Bool("version", false, "Print the version, the VCS revision and the Go version and exit. See gogrep version -verbose for the features compiled in.")
+
+const versionUsage = `Usage of gogrep version
+  gogrep version [-verbose]
+    Print the module version, the VCS revision and the Go version of the build.
+Flags:`
+
+func runVersion(args []string) error {
+	var (
+		fs      = flag.NewFlagSet("version", flag.ContinueOnError)
+		verbose = fs.Bool("verbose", false, "Print the build settings, the features compiled in and the dependencies too.")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), versionUsage)
+		fs.PrintDefaults()
+	}
+	if err := parseFlagSet(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New(versionUsage)
+	}
+	return writeVersion(os.Stdout, *verbose)
+}
+
+// writeVersion writes the version of the build by runtime/debug.ReadBuildInfo.
+func writeVersion(w io.Writer, verbose bool) error {
+	var (
+		b       strings.Builder
+		version = "(unknown)"
+		info, _ = debug.ReadBuildInfo()
+		setting = func(key string) string { return "" }
+	)
+	if info != nil {
+		version = info.Main.Version
+		settings := map[string]string{}
+		for _, s := range info.Settings {
+			settings[s.Key] = s.Value
+		}
+		setting = func(key string) string { return settings[key] }
+	}
+	fmt.Fprintf(&b, "gogrep %s\n", version)
+	if rev := setting("vcs.revision"); rev != "" {
+		if setting("vcs.modified") == "true" {
+			rev += " (modified)"
+		}
+		fmt.Fprintf(&b, "revision %s\n", rev)
+	}
+	if t := setting("vcs.time"); t != "" {
+		fmt.Fprintf(&b, "time %s\n", t)
+	}
+	fmt.Fprintf(&b, "go %s\n", runtime.Version())
+	fmt.Fprintf(&b, "platform %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	if verbose {
+		if info != nil {
+			b.WriteString("build:\n")
+			for _, s := range info.Settings {
+				if !strings.HasPrefix(s.Key, "vcs") {
+					fmt.Fprintf(&b, "  %s=%s\n", s.Key, s.Value)
+				}
+			}
+		}
+		features := append(gogrep.Capabilities(), cliCapabilities...)
+		sort.Strings(features)
+		b.WriteString("features:\n")
+		for _, x := range features {
+			fmt.Fprintf(&b, "  %s\n", x)
+		}
+		if info != nil && len(info.Deps) > 0 {
+			b.WriteString("dependencies:\n")
+			for _, d := range info.Deps {
+				if d.Replace != nil {
+					d = d.Replace
+				}
+				fmt.Fprintf(&b, "  %s %s\n", d.Path, d.Version)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,313 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/berquerant/gogrep"
+)
+
+// root is a directory to search recursively with its own options.
+type root struct {
+	path       string
+	label      string
+	include    []string     // globs of the base names of the files to search
+	exclude    []string     // globs of the base names of the files to skip
+	excludeDir []string     // globs of the base names of the directories to prune
+	ignore     *ignoreRules // nil with -no-ignore
+	types      []string     // globs of the base names of -type
+	typesNot   []string     // globs of the base names of -type-not
+	maxSize    int64        // the max size of the files by -max-filesize, not positive means unlimited
+	maxDepth   int          // the max depth of the files by -max-depth, not positive means unlimited
+	minDepth   int          // the min depth of the files by -min-depth
+}
+
+// roots are the parsed -root.
+var roots []*root
+
+func parseRoots() error {
+	for _, g := range append(append(append([]string{}, includeFlags...), excludeFlags...), excludeDirFlags...) {
+		if _, err := filepath.Match(g, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+	}
+	if err := addFileTypes(typeAddFlags); err != nil {
+		return err
+	}
+	types, err := fileTypeGlobs(typeFlags)
+	if err != nil {
+		return err
+	}
+	typesNot, err := fileTypeGlobs(typeNotFlags)
+	if err != nil {
+		return err
+	}
+	var maxSize int64
+	if *maxFilesize != "" {
+		v, err := parseBytesValue(*maxFilesize)
+		if err != nil {
+			return fmt.Errorf("invalid max-filesize: %w", err)
+		}
+		maxSize = int64(v)
+	}
+	for _, x := range rootFlags {
+		r, err := parseRoot(x)
+		if err != nil {
+			return err
+		}
+		r.types = types
+		r.typesNot = typesNot
+		r.maxSize = maxSize
+		r.maxDepth = *maxDepth
+		r.minDepth = *minDepth
+		// -include, -exclude and -exclude-dir apply to all the roots
+		r.include = append(r.include, includeFlags...)
+		r.exclude = append(r.exclude, excludeFlags...)
+		r.excludeDir = append(r.excludeDir, excludeDirFlags...)
+		if *respectGitignore && !*noIgnore {
+			r.ignore = newIgnoreRules(r.path)
+		}
+		roots = append(roots, r)
+	}
+	return nil
+}
+
+// parseRoot parses PATH[:OPT,...].
+func parseRoot(s string) (*root, error) {
+	var (
+		path, opts, _ = cut(s, ":")
+		r             = &root{
+			path:  path,
+			label: path,
+		}
+	)
+	if path == "" {
+		return nil, fmt.Errorf("invalid root %q: empty path", s)
+	}
+	if opts == "" {
+		return r, nil
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		k, v, ok := cut(opt, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid root %q: option %q is not KEY=VALUE", s, opt)
+		}
+		switch k {
+		case "label":
+			r.label = v
+		case "include":
+			r.include = append(r.include, v)
+		case "exclude":
+			r.exclude = append(r.exclude, v)
+		case "exclude-dir":
+			r.excludeDir = append(r.excludeDir, v)
+		default:
+			return nil, fmt.Errorf("invalid root %q: unknown option %s", s, k)
+		}
+	}
+	for _, g := range append(append(append([]string{}, r.include...), r.exclude...), r.excludeDir...) {
+		if _, err := filepath.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("invalid root %q: %w", s, err)
+		}
+	}
+	return r, nil
+}
+
+// cut slices s around the first sep.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// walk calls fn for each regular file under the root in lexical order.
+// Hidden, excluded and ignored directories and the directories at -max-depth are pruned without being read.
+// The files directly under the root are at depth 1, and the files shallower than -min-depth are not grepped.
+// The files hidden, excluded, ignored or larger than -max-filesize and the directories pruned are reported by -report-skipped.
+// The root is followed if it is a symbolic link, and the links under the root are followed with -follow-symlinks.
+func (s *root) walk(fn func(t *target) error) error {
+	w := &rootWalker{
+		root: s,
+		fn:   fn,
+	}
+	real := s.path
+	if isHostFS() {
+		if *followSymlinks {
+			w.visited = map[gogrep.FileID]bool{}
+		}
+		if x, err := filepath.EvalSymlinks(s.path); err == nil {
+			real = x
+		}
+	}
+	return w.walkTree(s.path, real, 0)
+}
+
+// rootWalker walks the tree of a root.
+type rootWalker struct {
+	root    *root
+	fn      func(t *target) error
+	visited map[gogrep.FileID]bool // the directories entered, nil without -follow-symlinks
+}
+
+// walkTree walks the directory real as the directory top at the depth, e.g. the directory linked by top.
+func (w *rootWalker) walkTree(top, real string, depth int) error {
+	return walkDir(real, func(path string, d fs.DirEntry, err error) error {
+		var rel string
+		if path != real {
+			rel, _ = filepath.Rel(real, path)
+			path = filepath.Join(top, rel)
+		} else {
+			path = top
+		}
+		if err != nil {
+			return err
+		}
+		if path == top {
+			return w.enter(path)
+		}
+		return w.visit(path, d, depth+strings.Count(rel, string(filepath.Separator))+1)
+	})
+}
+
+// enter returns filepath.SkipDir if the directory is entered already by the symbolic links.
+func (w *rootWalker) enter(dir string) error {
+	if w.visited == nil {
+		return nil
+	}
+	id, err := gogrep.StatFileID(dir)
+	if err != nil {
+		return nil // no identities on the platform
+	}
+	if w.visited[id] {
+		skipReport.reportTarget(&target{path: dir, root: w.root.label}, skipLoop)
+		return filepath.SkipDir
+	}
+	w.visited[id] = true
+	return nil
+}
+
+func (w *rootWalker) visit(path string, d fs.DirEntry, depth int) error {
+	var (
+		s    = w.root
+		name = d.Name()
+		t    = &target{
+			path: path,
+			root: s.label,
+		}
+	)
+	if d.IsDir() {
+		if s.skipDir(t, name, depth) {
+			return filepath.SkipDir
+		}
+		return w.enter(path)
+	}
+	if d.Type()&fs.ModeSymlink != 0 {
+		if w.visited == nil {
+			return nil
+		}
+		return w.visitLink(t, name, depth)
+	}
+	if !d.Type().IsRegular() || s.skipFile(t, name, depth, d.Info) {
+		return nil
+	}
+	return w.fn(t)
+}
+
+// visitLink follows the symbolic link with -follow-symlinks.
+// The broken links are the errors of the targets.
+func (w *rootWalker) visitLink(t *target, name string, depth int) error {
+	s := w.root
+	info, err := os.Stat(t.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", t.path, err)
+		targetFailed = true
+		return nil
+	}
+	if info.IsDir() {
+		if s.skipDir(t, name, depth) {
+			return nil
+		}
+		real, err := filepath.EvalSymlinks(t.path)
+		if err != nil {
+			return err
+		}
+		return w.walkTree(t.path, real, depth)
+	}
+	if !info.Mode().IsRegular() || s.skipFile(t, name, depth, func() (fs.FileInfo, error) { return info, nil }) {
+		return nil
+	}
+	return w.fn(t)
+}
+
+// skipDir returns true if the directory at the depth under the root should be pruned.
+func (s *root) skipDir(t *target, name string, depth int) bool {
+	if s.maxDepth > 0 && depth >= s.maxDepth {
+		skipReport.reportTarget(t, skipTooDeep)
+		return true
+	}
+	if isHidden(name) && !*hiddenFiles {
+		skipReport.reportTarget(t, skipHidden)
+		return true
+	}
+	if matchAny(s.excludeDir, name) {
+		skipReport.reportTarget(t, skipExcluded)
+		return true
+	}
+	if s.ignore.ignored(t.path, true) {
+		skipReport.reportTarget(t, skipIgnored)
+		return true
+	}
+	return false
+}
+
+// skipFile returns true if the file at the depth under the root should not be grepped.
+func (s *root) skipFile(t *target, name string, depth int, info func() (fs.FileInfo, error)) bool {
+	if depth < s.minDepth {
+		return true
+	}
+	if len(s.include) > 0 && !matchAny(s.include, name) {
+		return true
+	}
+	if len(s.types) > 0 && !matchAny(s.types, name) {
+		return true
+	}
+	if isHidden(name) && !*hiddenFiles {
+		skipReport.reportTarget(t, skipHidden)
+		return true
+	}
+	if matchAny(s.exclude, name) || matchAny(s.typesNot, name) {
+		skipReport.reportTarget(t, skipExcluded)
+		return true
+	}
+	if s.ignore.ignored(t.path, false) {
+		skipReport.reportTarget(t, skipIgnored)
+		return true
+	}
+	if s.maxSize > 0 {
+		x, err := info()
+		if err == nil && x.Size() > s.maxSize {
+			skipReport.reportTarget(t, skipTooLarge)
+			return true
+		}
+	}
+	return false
+}
+
+// isHidden reports whether the base name is of a dotfile or a dot-directory.
+func isHidden(name string) bool {
+	return len(name) > 1 && name[0] == '.' && name != ".."
+}
+
+// matchAny reports whether the name matches any of the globs.
+func matchAny(globs []string, name string) bool {
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootWalk(t *testing.T) {
+	defer func() { fileSystem = hostFS{} }()
+	fileSystem = fstest.MapFS{
+		"src/a.go":            {Data: []byte("a")},
+		"src/a_test.go":       {Data: []byte("a")},
+		"src/b.md":            {Data: []byte("b")},
+		"src/vendor/c.go":     {Data: []byte("c")},
+		"src/internal/d.go":   {Data: []byte("d")},
+		"src/internal/e.yaml": {Data: []byte("e")},
+	}
+	r, err := parseRoot("src:label=x,include=*.go,exclude=*_test.go,exclude-dir=vendor")
+	assert.Nil(t, err)
+	var got []string
+	assert.Nil(t, r.walk(func(t *target) error {
+		got = append(got, t.root+":"+t.path)
+		return nil
+	}))
+	assert.Equal(t, []string{"x:src/a.go", "x:src/internal/d.go"}, got)
+}
+
+func TestParseRootsGlobalFilters(t *testing.T) {
+	defer func() {
+		fileSystem = hostFS{}
+		roots, rootFlags, includeFlags, excludeFlags, excludeDirFlags = nil, nil, nil, nil, nil
+	}()
+	fileSystem = fstest.MapFS{
+		"src/a.go":          {Data: []byte("a")},
+		"src/a_test.go":     {Data: []byte("a")},
+		"src/b.md":          {Data: []byte("b")},
+		"src/vendor/c.go":   {Data: []byte("c")},
+		"src/internal/d.go": {Data: []byte("d")},
+		"doc/e.md":          {Data: []byte("e")},
+		"doc/f.go":          {Data: []byte("f")},
+	}
+	rootFlags = stringsFlag{"src:exclude=*_test.go", "doc:include=*.md"}
+	includeFlags = stringsFlag{"*.go"}
+	excludeDirFlags = stringsFlag{"vendor"}
+	assert.Nil(t, parseRoots())
+	var got []string
+	for _, r := range roots {
+		assert.Nil(t, r.walk(func(t *target) error {
+			got = append(got, t.path)
+			return nil
+		}))
+	}
+	assert.Equal(t, []string{"src/a.go", "src/internal/d.go", "doc/e.md", "doc/f.go"}, got)
+
+	roots = nil
+	excludeFlags = stringsFlag{"["}
+	assert.NotNil(t, parseRoots())
+}
+
+func TestParseRootsTypes(t *testing.T) {
+	defer func() {
+		fileSystem = hostFS{}
+		roots, rootFlags, typeFlags, typeNotFlags, typeAddFlags = nil, nil, nil, nil, nil
+		*maxFilesize = ""
+		delete(fileTypes, "web")
+	}()
+	fileSystem = fstest.MapFS{
+		"src/a.go":      {Data: []byte("a")},
+		"src/a_test.go": {Data: []byte("a")},
+		"src/b.md":      {Data: []byte("b")},
+		"src/c.html":    {Data: []byte("c")},
+		"src/d.yaml":    {Data: []byte("d")},
+		"src/e.md":      {Data: []byte("large")},
+		"src/Makefile":  {Data: []byte("f")},
+	}
+	rootFlags = stringsFlag{"src:exclude=*_test.go"}
+	typeFlags = stringsFlag{"go,md", "web", "make"}
+	typeNotFlags = stringsFlag{"make"}
+	typeAddFlags = stringsFlag{"web:*.html,*.css"}
+	*maxFilesize = "4"
+	assert.Nil(t, parseRoots())
+	var got []string
+	for _, r := range roots {
+		assert.Nil(t, r.walk(func(t *target) error {
+			got = append(got, t.path)
+			return nil
+		}))
+	}
+	assert.Equal(t, []string{"src/a.go", "src/b.md", "src/c.html"}, got)
+
+	roots = nil
+	typeFlags = stringsFlag{"unknown"}
+	assert.NotNil(t, parseRoots())
+}
+
+func TestRootWalkHidden(t *testing.T) {
+	defer func() {
+		fileSystem = hostFS{}
+		*hiddenFiles = false
+	}()
+	fileSystem = fstest.MapFS{
+		".src/a.go":           {Data: []byte("a")},
+		".src/.env":           {Data: []byte("a")},
+		".src/.github/ci.yml": {Data: []byte("b")},
+		".src/dir/c.go":       {Data: []byte("c")},
+	}
+	walk := func() []string {
+		var got []string
+		assert.Nil(t, (&root{path: ".src"}).walk(func(t *target) error {
+			got = append(got, t.path)
+			return nil
+		}))
+		return got
+	}
+	assert.Equal(t, []string{".src/a.go", ".src/dir/c.go"}, walk(), "the root is not hidden")
+	*hiddenFiles = true
+	assert.Equal(t, []string{".src/.env", ".src/.github/ci.yml", ".src/a.go", ".src/dir/c.go"}, walk())
+}
+
+// readDirFS is fstest.MapFS that records the directories read.
+type readDirFS struct {
+	fstest.MapFS
+	read []string
+}
+
+func (s *readDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	s.read = append(s.read, name)
+	return s.MapFS.ReadDir(name)
+}
+
+func TestRootWalkDepth(t *testing.T) {
+	defer func() { fileSystem = hostFS{} }()
+	fsys := &readDirFS{
+		MapFS: fstest.MapFS{
+			"src/a.go":         {Data: []byte("a")},
+			"src/x/b.go":       {Data: []byte("b")},
+			"src/x/y/c.go":     {Data: []byte("c")},
+			"src/x/y/z/d.go":   {Data: []byte("d")},
+			"src/x/y/z/w/e.go": {Data: []byte("e")},
+		},
+	}
+	fileSystem = fsys
+	walk := func(minDepth, maxDepth int) []string {
+		fsys.read = nil
+		var got []string
+		assert.Nil(t, (&root{path: "src", minDepth: minDepth, maxDepth: maxDepth}).walk(func(t *target) error {
+			got = append(got, t.path)
+			return nil
+		}))
+		return got
+	}
+	assert.Equal(t, []string{"src/a.go", "src/x/b.go", "src/x/y/c.go", "src/x/y/z/d.go", "src/x/y/z/w/e.go"}, walk(0, 0))
+	assert.Equal(t, []string{"src/a.go"}, walk(0, 1))
+	assert.Equal(t, []string{"src"}, fsys.read, "pruned without being read")
+	assert.Equal(t, []string{"src/a.go", "src/x/b.go"}, walk(0, 2))
+	assert.Equal(t, []string{"src", "src/x"}, fsys.read)
+	assert.Equal(t, []string{"src/x/y/c.go", "src/x/y/z/d.go"}, walk(3, 4))
+	assert.Equal(t, []string{"src/x/y/z/w/e.go"}, walk(5, 0))
+}
@@ -0,0 +1,291 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// watchDir is a directory watched for the changes of its files.
+type watchDir struct {
+	path      string
+	recursive bool // watch the subdirectories too, e.g. a root
+}
+
+// fileWatcher notifies the paths of the files changed under the watched directories.
+type fileWatcher interface {
+	// events returns the channel of the changed paths, closed after close.
+	events() <-chan string
+	close() error
+}
+
+// watchMatches are the texts of the matches of the files by -watch,
+// to print only the new matches on the changes.
+type watchMatches struct {
+	prev    map[string]map[string]int // the matches of the files in the last grep
+	current map[string]map[string]int // the matches of the files being grepped
+	header  bool                      // print the header before the next new match
+}
+
+// matchWatch is nil without -watch.
+var matchWatch *watchMatches
+
+func newWatchMatches() *watchMatches {
+	return &watchMatches{
+		prev:    map[string]map[string]int{},
+		current: map[string]map[string]int{},
+	}
+}
+
+// fresh returns true if the match is not in the last grep of the file.
+// The same texts in a file are counted, so a duplicated line is new.
+func (s *watchMatches) fresh(m *match) bool {
+	c, ok := s.current[m.File]
+	if !ok {
+		c = map[string]int{}
+		s.current[m.File] = c
+	}
+	c[m.Text]++
+	if c[m.Text] <= s.prev[m.File][m.Text] {
+		return false
+	}
+	if s.header {
+		s.header = false
+		if *format == "text" {
+			fmt.Fprintf(stdout, "==> %s <==\n", clock.Now().Format(time.RFC3339))
+		}
+	}
+	return true
+}
+
+// commit makes the matches of the targets grepped the last ones.
+func (s *watchMatches) commit(targets []*target) {
+	for _, t := range targets {
+		name := t.name()
+		if c, ok := s.current[name]; ok {
+			s.prev[name] = c
+			delete(s.current, name)
+		} else {
+			delete(s.prev, name)
+		}
+	}
+	s.header = true
+}
+
+// grepWatch greps the targets and then greps the files changed under the roots and the files again,
+// printing only the new matches with the time of the changes, until the context is canceled.
+// The changes are debounced by -watch-debounce.
+func grepWatch(ctx context.Context, patterns []string, targets []*target) error {
+	if !isHostFS() {
+		return errors.New("-watch cannot watch the files not on the host")
+	}
+	if len(targets) == 0 {
+		return errors.New("-watch requires files or -root")
+	}
+	files := map[string]*target{} // keyed by the cleaned paths
+	for _, t := range targets {
+		if t.path == "" {
+			return errors.New("-watch cannot watch stdin")
+		}
+		files[filepath.Clean(t.path)] = t
+	}
+	matchWatch = newWatchMatches()
+	if err := grepSources(ctx, patterns, targets); err != nil {
+		return err
+	}
+	matchWatch.commit(targets)
+
+	w, err := newFileWatcher(ctx, watchDirs(files))
+	if err != nil {
+		return err
+	}
+	defer w.close()
+	var (
+		changed = map[string]bool{}
+		timeout <-chan time.Time // the end of the debounce
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case path, ok := <-w.events():
+			if !ok {
+				return nil
+			}
+			changed[filepath.Clean(path)] = true
+			timeout = clock.After(*watchDebounce)
+		case <-timeout:
+			timeout = nil
+			batch, err := changedTargets(files, changed)
+			if err != nil {
+				return err
+			}
+			changed = map[string]bool{}
+			if len(batch) == 0 {
+				continue
+			}
+			if err := grepSources(ctx, patterns, batch); err != nil {
+				return err
+			}
+			matchWatch.commit(batch)
+		}
+	}
+}
+
+// watchDirs returns the directories of the files given as arguments and the roots to watch.
+func watchDirs(files map[string]*target) []watchDir {
+	var (
+		dirs []watchDir
+		seen = map[string]bool{}
+	)
+	for _, r := range roots {
+		dirs = append(dirs, watchDir{path: r.path, recursive: true})
+	}
+	names := make([]string, 0, len(files))
+	for name, t := range files {
+		if t.root == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dir := filepath.Dir(name)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, watchDir{path: dir})
+		}
+	}
+	return dirs
+}
+
+// changedTargets returns the targets of the changed paths, the files given as arguments
+// and the files under the roots by the filters of the roots.
+// The matches of the removed files are forgotten.
+func changedTargets(files map[string]*target, changed map[string]bool) ([]*target, error) {
+	var targets []*target
+	for path := range changed {
+		t, ok := files[path]
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			if t.root != "" {
+				delete(files, path)
+			}
+			matchWatch.commit([]*target{t})
+			continue
+		}
+		if t.root == "" {
+			targets = append(targets, &target{path: t.path})
+		}
+	}
+	for _, r := range roots {
+		err := r.walk(func(t *target) error {
+			path := filepath.Clean(t.path)
+			if x, ok := files[path]; changed[path] && (!ok || x.root != "") {
+				files[path] = t
+				targets = append(targets, t)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].path < targets[j].path })
+	return targets, nil
+}
+
+// watchPollInterval is the interval of the polling of pollingWatcher.
+const watchPollInterval = time.Second
+
+// pollingWatcher watches the directories by comparing the sizes and the modification times of the files periodically.
+type pollingWatcher struct {
+	dirs   []watchDir
+	eventC chan string
+	cancel context.CancelFunc
+}
+
+func newPollingWatcher(ctx context.Context, dirs []watchDir) *pollingWatcher {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &pollingWatcher{
+		dirs:   dirs,
+		eventC: make(chan string),
+		cancel: cancel,
+	}
+	go w.run(ctx)
+	return w
+}
+
+func (w *pollingWatcher) events() <-chan string { return w.eventC }
+
+func (w *pollingWatcher) close() error {
+	w.cancel()
+	return nil
+}
+
+// fileStamp is the state of a file compared by pollingWatcher.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func (w *pollingWatcher) run(ctx context.Context) {
+	defer close(w.eventC)
+	last := w.scan()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clock.After(watchPollInterval):
+		}
+		current := w.scan()
+		var changed []string
+		for path, x := range current {
+			if y, ok := last[path]; !ok || x != y {
+				changed = append(changed, path)
+			}
+		}
+		for path := range last {
+			if _, ok := current[path]; !ok {
+				changed = append(changed, path)
+			}
+		}
+		last = current
+		for _, path := range changed {
+			select {
+			case <-ctx.Done():
+				return
+			case w.eventC <- path:
+			}
+		}
+	}
+}
+
+// scan returns the states of the files under the directories.
+func (w *pollingWatcher) scan() map[string]fileStamp {
+	r := map[string]fileStamp{}
+	for _, d := range w.dirs {
+		_ = filepath.WalkDir(d.path, func(path string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if e.IsDir() {
+				if path != d.path && !d.recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info, err := e.Info(); err == nil {
+				r[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+			}
+			return nil
+		})
+	}
+	return r
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	cliCapabilities = append(cliCapabilities, "inotify")
+}
+
+// inotifyMask are the events of the files watched by inotifyWatcher.
+const inotifyMask = unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// inotifyPollTimeout is the timeout in milliseconds of the poll of inotifyWatcher to see the cancel.
+const inotifyPollTimeout = 200
+
+// inotifyWatcher watches the directories by inotify.
+// The subdirectories created under the recursive directories are watched too.
+type inotifyWatcher struct {
+	fd     int
+	dirs   map[int]watchDir // keyed by the watch descriptors
+	eventC chan string
+	cancel context.CancelFunc
+	doneC  chan struct{}
+}
+
+func newFileWatcher(ctx context.Context, dirs []watchDir) (fileWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return newPollingWatcher(ctx, dirs), nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w := &inotifyWatcher{
+		fd:     fd,
+		dirs:   map[int]watchDir{},
+		eventC: make(chan string),
+		cancel: cancel,
+		doneC:  make(chan struct{}),
+	}
+	for _, d := range dirs {
+		if err := w.add(d, nil); err != nil {
+			cancel()
+			unix.Close(fd)
+			return nil, err
+		}
+	}
+	go w.run(ctx)
+	return w, nil
+}
+
+func (w *inotifyWatcher) events() <-chan string { return w.eventC }
+
+func (w *inotifyWatcher) close() error {
+	w.cancel()
+	<-w.doneC
+	return nil
+}
+
+// add watches the directory and its subdirectories if recursive.
+// The files found in the subdirectories are passed to found if not nil,
+// since they may be created before the watch.
+func (w *inotifyWatcher) add(d watchDir, found func(string)) error {
+	if !d.recursive {
+		return w.addDir(d)
+	}
+	return filepath.WalkDir(d.path, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if path == d.path {
+				return err
+			}
+			return nil // removed already
+		}
+		if !e.IsDir() {
+			if found != nil {
+				found(path)
+			}
+			return nil
+		}
+		return w.addDir(watchDir{path: path, recursive: true})
+	})
+}
+
+func (w *inotifyWatcher) addDir(d watchDir) error {
+	wd, err := unix.InotifyAddWatch(w.fd, d.path, inotifyMask)
+	if err != nil {
+		return &fs.PathError{Op: "watch", Path: d.path, Err: err}
+	}
+	w.dirs[wd] = d
+	return nil
+}
+
+func (w *inotifyWatcher) run(ctx context.Context) {
+	defer close(w.doneC)
+	defer close(w.eventC)
+	defer unix.Close(w.fd)
+	var (
+		buf = make([]byte, 64*unix.SizeofInotifyEvent+unix.PathMax)
+		fds = []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}}
+	)
+	send := func(path string) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case w.eventC <- path:
+			return true
+		}
+	}
+	for ctx.Err() == nil {
+		if _, err := unix.Poll(fds, inotifyPollTimeout); err != nil && !errors.Is(err, unix.EINTR) {
+			return
+		}
+		n, err := unix.Read(w.fd, buf)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil || n < unix.SizeofInotifyEvent {
+			return
+		}
+		var paths []string
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(ev.Len)], "\x00"))
+			offset = nameStart + int(ev.Len)
+
+			d, ok := w.dirs[int(ev.Wd)]
+			if ev.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, int(ev.Wd))
+				continue
+			}
+			if !ok || name == "" {
+				continue
+			}
+			path := filepath.Join(d.path, name)
+			if ev.Mask&unix.IN_ISDIR == 0 {
+				paths = append(paths, path)
+				continue
+			}
+			if d.recursive && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				_ = w.add(watchDir{path: path, recursive: true}, func(p string) { paths = append(paths, p) })
+			}
+		}
+		for _, p := range paths {
+			if !send(p) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package cli
+
+import "context"
+
+// newFileWatcher returns the pollingWatcher since inotify is not available.
+func newFileWatcher(ctx context.Context, dirs []watchDir) (fileWatcher, error) {
+	return newPollingWatcher(ctx, dirs), nil
+}
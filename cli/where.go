@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// whereOperators are the comparison operators of -where, the longer first to be found first.
+var whereOperators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// whereCondition is a comparison of -where like '$2 > 500'.
+// The right side is converted by the converter of the left side like 'duration($1) > 1.5s'.
+type whereCondition struct {
+	expr  *captureExpr
+	op    string
+	value float64
+}
+
+func parseWhereCondition(cond string) (*whereCondition, error) {
+	for _, op := range whereOperators {
+		left, right, ok := strings.Cut(cond, op)
+		if !ok {
+			continue
+		}
+		expr, err := parseCaptureExpr(left)
+		if err != nil {
+			return nil, fmt.Errorf("invalid where %s: %w", cond, err)
+		}
+		value, err := expr.parseLiteral(right)
+		if err != nil {
+			return nil, fmt.Errorf("invalid where %s: %w", cond, err)
+		}
+		return &whereCondition{
+			expr:  expr,
+			op:    op,
+			value: value,
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid where %s: want EXPR OP VALUE where OP is one of %s", cond, strings.Join(whereOperators, " "))
+}
+
+// match returns true if the value of the text satisfies the comparison.
+// Returns false if the text has no value.
+func (s *whereCondition) match(regexes []*regexp.Regexp, text string) bool {
+	v, ok := s.expr.eval(regexes, text)
+	if !ok {
+		return false
+	}
+	switch s.op {
+	case ">=":
+		return v >= s.value
+	case "<=":
+		return v <= s.value
+	case "==":
+		return v == s.value
+	case "!=":
+		return v != s.value
+	case ">":
+		return v > s.value
+	default:
+		return v < s.value
+	}
+}
+
+// whereFilter keeps the matches that satisfy all the conditions of -where.
+type whereFilter struct {
+	conditions []*whereCondition
+	regexes    []*regexp.Regexp
+}
+
+// matchWhere is the filter of -where, nil without -where.
+var matchWhere *whereFilter
+
+func newWhereFilter(conditions []string, patterns []string) (*whereFilter, error) {
+	regexes, err := compilePatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	s := &whereFilter{
+		regexes: regexes,
+	}
+	for _, c := range conditions {
+		x, err := parseWhereCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		s.conditions = append(s.conditions, x)
+	}
+	return s, nil
+}
+
+// keep returns true if the text satisfies all the conditions.
+func (s *whereFilter) keep(text string) bool {
+	if s == nil {
+		return true
+	}
+	for _, c := range s.conditions {
+		if !c.match(s.regexes, text) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+package gogrep
+
+import "time"
+
+// Clock provides the current time and timers.
+// It can be replaced by WithClock to test the time-dependent features without sleeps.
+type Clock interface {
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock of the system time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
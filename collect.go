@@ -0,0 +1,58 @@
+package gogrep
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// GrepAll greps source by regex with the Grepper and returns the texts of all the matches
+// with the first error.
+// The texts are not guaranteed to be in order in which lines appear unless WithThreads(1).
+func GrepAll(ctx context.Context, g Grepper, regex string, source io.Reader) ([]string, error) {
+	resultC, err := g.Grep(ctx, regex, source)
+	if err != nil {
+		return nil, err
+	}
+	var texts []string
+	for r := range resultC {
+		if e := r.Err(); e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		texts = append(texts, r.Text())
+	}
+	return texts, err
+}
+
+// Stop is returned by the function of GrepFunc to stop the grep without errors.
+var Stop = errors.New("gogrep stop")
+
+// GrepFunc greps source by regex with the Grepper and calls the function for each result,
+// including the results of errors.
+// If the function returns an error, GrepFunc stops the grep and returns the error,
+// or nil if it is Stop.
+// The function is called sequentially.
+func GrepFunc(ctx context.Context, g Grepper, regex string, source io.Reader, f func(Result) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resultC, err := g.Grep(ctx, regex, source)
+	if err != nil {
+		return err
+	}
+	for r := range resultC {
+		if err := f(r); err != nil {
+			cancel()
+			for range resultC {
+				// Discard the results sent before the cancellation
+			}
+			if errors.Is(err, Stop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrepAll(t *testing.T) {
+	t.Run("matches", func(t *testing.T) {
+		texts, err := gogrep.GrepAll(context.TODO(), gogrep.New(gogrep.WithThreads(1)), "a", strings.NewReader(strings.Repeat("a\nb\nca\n", 100)))
+		assert.Nil(t, err)
+		assert.Equal(t, 200, len(texts))
+		assert.Equal(t, []string{"a", "ca"}, texts[:2])
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		texts, err := gogrep.GrepAll(context.TODO(), gogrep.New(), "x", strings.NewReader("a\n"))
+		assert.Nil(t, err)
+		assert.Empty(t, texts)
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		errSource := errors.New("source")
+		_, err := gogrep.GrepAll(context.TODO(), gogrep.New(), "x", &errReader{err: errSource})
+		assert.ErrorIs(t, err, errSource)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		_, err := gogrep.GrepAll(ctx, gogrep.New(), "x", strings.NewReader("x\n"))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalid regex", func(t *testing.T) {
+		_, err := gogrep.GrepAll(context.TODO(), gogrep.New(), "(", strings.NewReader("x\n"))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGrepFunc(t *testing.T) {
+	source := func() *strings.Reader { return strings.NewReader(strings.Repeat("a\nb\n", 10000)) }
+
+	t.Run("all", func(t *testing.T) {
+		var n int
+		err := gogrep.GrepFunc(context.TODO(), gogrep.New(), "a", source(), func(r gogrep.Result) error {
+			assert.Nil(t, r.Err())
+			n++
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 10000, n)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		var n int
+		err := gogrep.GrepFunc(context.TODO(), gogrep.New(), "a", source(), func(r gogrep.Result) error {
+			n++
+			if n == 3 {
+				return gogrep.Stop
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, n)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errFunc := errors.New("func")
+		err := gogrep.GrepFunc(context.TODO(), gogrep.New(), "a", source(), func(r gogrep.Result) error {
+			return errFunc
+		})
+		assert.ErrorIs(t, err, errFunc)
+	})
+
+	t.Run("error result", func(t *testing.T) {
+		errSource := errors.New("source")
+		var got error
+		err := gogrep.GrepFunc(context.TODO(), gogrep.New(), "a", &errReader{err: errSource}, func(r gogrep.Result) error {
+			got = r.Err()
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.ErrorIs(t, got, errSource)
+	})
+}
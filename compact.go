@@ -0,0 +1,224 @@
+package gogrep
+
+import (
+	"context"
+	"strings"
+
+	"github.com/klauspost/compress/s2"
+)
+
+// compactMinSize is the min length of a text to be compressed by Compact.
+const compactMinSize = 64
+
+// Compact returns a Result that keeps the text compressed in snappy format in memory
+// and decompresses it each time Text is called, sharing no memory with the buffers of the lines.
+// The match ranges, the pattern IDs and the distance not computed yet are computed from the decompressed line on demand,
+// and the capture groups are kept as the ranges in the text instead of the copies of them.
+// It is meant for collecting many results before sending them, e.g. WithSort and WithUnique.
+// Error results are kept as they are.
+func Compact(r Result) Result {
+	if _, ok := r.(*compactResult); ok || r.Err() != nil {
+		return r
+	}
+	text := r.Text()
+	c := &compactResult{
+		text:    newCompactText(text),
+		line:    r.Line(),
+		offset:  r.Offset(),
+		source:  r.Source(),
+		tag:     r.Tag(),
+		context: sourceContext(r),
+	}
+	if x := innerResult(r); x != nil && x.matcher != nil {
+		c.matcher = x.matcher
+		c.ranges, c.patterns = x.ranges, x.patterns
+		c.distance, c.measured = x.distance, x.measured
+		if x.view != text {
+			view := newCompactText(x.view)
+			c.view = &view
+		}
+	} else {
+		c.ranges, c.patterns = r.MatchRanges(), r.PatternID()
+		c.distance, c.measured = r.Distance(), true
+	}
+	c.groups, c.submatches = compactSubmatches(text, r.Submatches())
+	return c
+}
+
+// WithCompactResults sends the results kept by Compact, e.g. for the receivers slower than the grep,
+// where the results waiting in the buffer of WithResultBufferSize are many long lines.
+// It is applied after WithUnique and WithSort and before WithSink and WithSequence, and ignored in the options of NamedSource.
+func WithCompactResults() Option {
+	return func(c *Config) {
+		c.compactResults = true
+	}
+}
+
+// compactGrep returns the grep that sends the results of the grep kept by Compact.
+func compactGrep(c *Config, grep func(context.Context) (<-chan Result, error)) func(context.Context) (<-chan Result, error) {
+	return func(ctx context.Context) (<-chan Result, error) {
+		resultC, err := grep(ctx)
+		if err != nil {
+			return nil, err
+		}
+		compactC := make(chan Result, c.resultBuffer())
+		supervise(compactC, func() {
+			for r := range resultC {
+				compactC <- Compact(r)
+			}
+		}, func() { drainResults(resultC) })
+		return compactC, nil
+	}
+}
+
+// innerResult returns the result of the line wrapped by the Result, nil if not found.
+// The wrappers do not change the match ranges, the pattern IDs and the distance of it.
+func innerResult(r Result) *result {
+	for {
+		switch x := r.(type) {
+		case *result:
+			return x
+		case *sourceResult:
+			r = x.Result
+		case *taggedResult:
+			r = x.Result
+		case *batchResult:
+			r = x.Result
+		case *sequencedResult:
+			r = x.Result
+		default:
+			return nil
+		}
+	}
+}
+
+// compactText is a text kept compressed if it is long and compressible.
+type compactText struct {
+	raw        string
+	compressed []byte // nil if raw
+}
+
+func newCompactText(s string) compactText {
+	if len(s) >= compactMinSize {
+		if b := s2.EncodeSnappy(nil, []byte(s)); len(b) < len(s) {
+			// Not to keep the spare capacity of the encoder
+			return compactText{compressed: append([]byte(nil), b...)}
+		}
+	}
+	return compactText{raw: strings.Clone(s)}
+}
+
+func (s compactText) String() string {
+	if s.compressed == nil {
+		return s.raw
+	}
+	b, _ := s2.Decode(nil, s.compressed) // never fails since encoded by newCompactText
+	return string(b)
+}
+
+// compactKey returns the key of the string compressed by newCompactText, to keep many keys in memory.
+// The keys are equal only if the strings are equal.
+func compactKey(s string) string {
+	if x := newCompactText(s); x.compressed != nil {
+		return "c" + string(x.compressed)
+	}
+	return "r" + s
+}
+
+// compactSubmatches returns the capture groups as the ranges in the text,
+// or the copies of them if any group is out of the text.
+func compactSubmatches(text string, submatches []string) ([][2]int, []string) {
+	if submatches == nil {
+		return nil, nil
+	}
+	groups := make([][2]int, len(submatches))
+	for i, x := range submatches {
+		if x == "" {
+			continue
+		}
+		start := strings.Index(text, x)
+		if start < 0 {
+			r := make([]string, len(submatches))
+			for i, x := range submatches {
+				r[i] = strings.Clone(x)
+			}
+			return nil, r
+		}
+		groups[i] = [2]int{start, start + len(x)}
+	}
+	return groups, nil
+}
+
+type compactResult struct {
+	text       compactText
+	line       int
+	offset     int64
+	source     string
+	tag        interface{}
+	context    *ResultContext
+	groups     [][2]int // the capture groups in the text, nil if submatches
+	submatches []string
+	ranges     [][2]int
+	patterns   []string
+	distance   int
+	measured   bool
+	// matcher and view compute ranges, patterns and distance lazily as result does.
+	matcher Matcher
+	view    *compactText // nil if the same as text
+}
+
+func (s *compactResult) Text() string                  { return s.text.String() }
+func (*compactResult) Err() error                      { return nil }
+func (s *compactResult) Line() int                     { return s.line }
+func (s *compactResult) Offset() int64                 { return s.offset }
+func (s *compactResult) Source() string                { return s.source }
+func (s *compactResult) Tag() interface{}              { return s.tag }
+func (s *compactResult) resultContext() *ResultContext { return s.context }
+
+func (s *compactResult) Submatches() []string {
+	if s.groups == nil {
+		return s.submatches
+	}
+	var (
+		text = s.Text()
+		r    = make([]string, len(s.groups))
+	)
+	for i, x := range s.groups {
+		r[i] = text[x[0]:x[1]]
+	}
+	return r
+}
+
+// lazy returns the result of the line to compute ranges, patterns and distance.
+func (s *compactResult) lazy() *result {
+	view := &s.text
+	if s.view != nil {
+		view = s.view
+	}
+	return &result{
+		matcher: s.matcher,
+		view:    view.String(),
+	}
+}
+
+func (s *compactResult) MatchRanges() [][2]int {
+	if s.ranges == nil && s.matcher != nil {
+		s.ranges = s.lazy().MatchRanges()
+	}
+	return s.ranges
+}
+
+func (s *compactResult) Distance() int {
+	if !s.measured && s.matcher != nil {
+		s.distance = s.lazy().Distance()
+		s.measured = true
+	}
+	return s.distance
+}
+
+func (s *compactResult) PatternID() []string {
+	if s.patterns == nil && s.matcher != nil {
+		s.patterns = s.lazy().PatternID()
+	}
+	return s.patterns
+}
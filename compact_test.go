@@ -0,0 +1,100 @@
+package gogrep_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompact(t *testing.T) {
+	long := strings.Repeat("compressible ", 20) + "match"
+	source := "match\n" + long + "\n" + strings.Repeat("x", 100) + " matcH\n"
+
+	t.Run("lines", func(t *testing.T) {
+		results := toResultSlice(mustGrep(t, gogrep.New(gogrep.WithThreads(1)), "match", source))
+		compacts := toResultSlice(mustGrep(t, gogrep.New(gogrep.WithThreads(1), gogrep.WithCompactResults()), "match", source))
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, len(results), len(compacts))
+		for i, r := range results {
+			c := compacts[i]
+			assert.Nil(t, c.Err())
+			assert.Equal(t, r.Text(), c.Text())
+			assert.Equal(t, r.Line(), c.Line())
+			assert.Equal(t, r.Offset(), c.Offset())
+			assert.Equal(t, r.MatchRanges(), c.MatchRanges(), "computed from the compressed line")
+			assert.Equal(t, r.Submatches(), c.Submatches())
+		}
+	})
+
+	t.Run("patterns", func(t *testing.T) {
+		g := gogrep.New(gogrep.WithThreads(1), gogrep.WithFuzzy(1))
+		resultC, err := g.GrepPatterns(context.TODO(), []gogrep.Pattern{
+			{ID: "lower", Regex: "match"},
+			{ID: "any", Regex: "(?i)match"},
+		}, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		for _, r := range toResultSlice(resultC) {
+			c := gogrep.Compact(r)
+			assert.Equal(t, r.PatternID(), c.PatternID())
+			assert.Equal(t, r.Distance(), c.Distance())
+			assert.Equal(t, r.MatchRanges(), c.MatchRanges())
+		}
+	})
+
+	t.Run("only matching", func(t *testing.T) {
+		g := gogrep.New(gogrep.WithThreads(1), gogrep.WithOnlyMatching())
+		results := toResultSlice(mustGrep(t, g, `(compressible )+(m)(a)?(z)?tch`, source))
+		if !assert.Equal(t, 1, len(results)) {
+			return
+		}
+		r := results[0]
+		c := gogrep.Compact(r)
+		assert.Equal(t, r.Text(), c.Text())
+		assert.Equal(t, r.MatchRanges(), c.MatchRanges())
+		assert.Equal(t, []string{r.Text(), "compressible ", "m", "a", ""}, c.Submatches())
+	})
+}
+
+func mustGrep(t *testing.T, g gogrep.Grepper, regex, source string) <-chan gogrep.Result {
+	t.Helper()
+	resultC, err := g.Grep(context.TODO(), regex, strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resultC
+}
+
+func TestSortMemory(t *testing.T) {
+	const lines = 20000
+	var b strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&b, "%05d %s\n", lines-i, strings.Repeat("compressible ", 80))
+	}
+	source := b.String()
+
+	heapAlloc := func() int {
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return int(m.HeapAlloc)
+	}
+	retained := func(t *testing.T, opt ...gogrep.Option) int {
+		before := heapAlloc()
+		results := toResultSlice(mustGrep(t, gogrep.New(append(opt, gogrep.WithThreads(4))...), "compressible", source))
+		n := heapAlloc() - before
+		assert.Equal(t, lines, len(results))
+		runtime.KeepAlive(results)
+		return n
+	}
+	unsorted := retained(t)
+	sorted := retained(t, gogrep.WithSort(gogrep.SortByLine))
+	assert.Less(t, sorted, unsorted/2, "the sorted results are kept compact")
+	runtime.KeepAlive(source)
+}
@@ -0,0 +1,43 @@
+package gogrep
+
+import "io/fs"
+
+// ResultContext is the context of the source of a Result,
+// so that the sinks and the formatters get the information of the source without deriving it again.
+type ResultContext struct {
+	// Source is the name of the source given to GrepSources.
+	Source string
+	// Labels are NamedSource.Labels.
+	Labels map[string]string
+	// Info is NamedSource.Info, the metadata of the file of the source, nil if not given.
+	Info fs.FileInfo
+	// Tag is the value set by WithSourceTag.
+	Tag interface{}
+}
+
+// contextResult is a Result that keeps the context of the source.
+type contextResult interface {
+	resultContext() *ResultContext
+}
+
+// ContextOf returns the context of the source of the result.
+// The results not from GrepSources have the context of only the tag.
+func ContextOf(r Result) ResultContext {
+	c := ResultContext{
+		Source: r.Source(),
+		Tag:    r.Tag(),
+	}
+	if rc := sourceContext(r); rc != nil {
+		c.Labels = rc.Labels
+		c.Info = rc.Info
+	}
+	return c
+}
+
+// sourceContext returns the context kept by the result, nil if not kept.
+func sourceContext(r Result) *ResultContext {
+	if x, ok := r.(contextResult); ok {
+		return x.resultContext()
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextOf(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": &fstest.MapFile{
+			Data:    []byte("match a\n"),
+			ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+	info, err := fsys.Stat("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("sources", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithThreads(1)).GrepSources(context.TODO(), []string{"match"}, []gogrep.NamedSource{
+			{
+				Name:    "a.txt",
+				Reader:  strings.NewReader("match a\n"),
+				Labels:  map[string]string{"root": "r"},
+				Info:    info,
+				Options: []gogrep.Option{gogrep.WithSourceTag(1)},
+			},
+			{
+				Name:   "b",
+				Reader: strings.NewReader("match b\n"),
+			},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		var got []gogrep.ResultContext
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, gogrep.ContextOf(r))
+			// Kept by the copies
+			assert.Equal(t, gogrep.ContextOf(r), gogrep.ContextOf(gogrep.CloneResult(r)))
+			assert.Equal(t, gogrep.ContextOf(r), gogrep.ContextOf(gogrep.Compact(r)))
+		}
+		if assert.Equal(t, 2, len(got)) {
+			assert.Equal(t, gogrep.ResultContext{
+				Source: "a.txt",
+				Labels: map[string]string{"root": "r"},
+				Info:   info,
+				Tag:    1,
+			}, got[0])
+			assert.Equal(t, gogrep.ResultContext{Source: "b"}, got[1])
+		}
+	})
+
+	t.Run("grep", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithSourceTag("tag")).Grep(context.TODO(), "match", strings.NewReader("match\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		for r := range resultC {
+			assert.Equal(t, gogrep.ResultContext{Tag: "tag"}, gogrep.ContextOf(r))
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCountMatches(t *testing.T) {
+	const source = "a a a\nb\na b a\n"
+
+	count := func(t *testing.T, opt ...gogrep.Option) (lines, matches int) {
+		resultC, err := gogrep.New(opt...).Grep(context.TODO(), "a", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			lines++
+			matches += gogrep.MatchCount(r)
+		}
+		return
+	}
+
+	for _, tc := range []struct {
+		title   string
+		opt     []gogrep.Option
+		lines   int
+		matches int
+	}{
+		{
+			title:   "count matches",
+			opt:     []gogrep.Option{gogrep.WithCountMatches()},
+			lines:   2,
+			matches: 5,
+		},
+		{
+			title:   "only matching",
+			opt:     []gogrep.Option{gogrep.WithCountMatches(), gogrep.WithOnlyMatching()},
+			lines:   5,
+			matches: 5,
+		},
+		{
+			title:   "without option",
+			lines:   2,
+			matches: 5,
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			lines, matches := count(t, tc.opt...)
+			assert.Equal(t, tc.lines, lines)
+			assert.Equal(t, tc.matches, matches)
+		})
+	}
+}
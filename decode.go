@@ -0,0 +1,97 @@
+package gogrep
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Magic bytes of the compression formats.
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// IsCompressed returns true if the header begins with the magic bytes of gzip, bzip2 or zstd.
+func IsCompressed(header []byte) bool {
+	return bytes.HasPrefix(header, gzipMagic) || bytes.HasPrefix(header, bzip2Magic) || bytes.HasPrefix(header, zstdMagic)
+}
+
+// NewDecodingReader returns a reader that decompresses the source
+// if it begins with the magic bytes of gzip, bzip2 or zstd, or reads the source as it is otherwise.
+// The format is detected at the first Read and the errors of the detection are returned by Read.
+// It implements OffsetMapper: the offsets of the compressed source are in the decompressed data,
+// and the others are mapped by the source if the source is an OffsetMapper.
+// Close does not close the source.
+func NewDecodingReader(source io.Reader) io.ReadCloser {
+	return &decodingReader{
+		source: source,
+	}
+}
+
+type decodingReader struct {
+	source     io.Reader
+	r          io.Reader
+	close      func()
+	compressed bool
+	err        error
+}
+
+func (s *decodingReader) Read(p []byte) (int, error) {
+	if s.r == nil && s.err == nil {
+		s.err = s.detect()
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.r.Read(p)
+}
+
+func (s *decodingReader) detect() error {
+	br := bufio.NewReader(s.source)
+	header, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	s.compressed = true
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		r, err := gzip.NewReader(br)
+		if err != nil {
+			return wrapErr(err, "Grepper cannot decompress gzip")
+		}
+		s.r = r
+	case bytes.HasPrefix(header, bzip2Magic):
+		s.r = bzip2.NewReader(br)
+	case bytes.HasPrefix(header, zstdMagic):
+		r, err := zstd.NewReader(br)
+		if err != nil {
+			return wrapErr(err, "Grepper cannot decompress zstd")
+		}
+		s.r = r
+		s.close = r.Close
+	default:
+		s.compressed = false
+		s.r = br
+	}
+	return nil
+}
+
+func (s *decodingReader) MapOffset(pos int64) int64 {
+	if m, ok := s.source.(OffsetMapper); ok && !s.compressed {
+		return m.MapOffset(pos)
+	}
+	return pos
+}
+
+func (s *decodingReader) Close() error {
+	if s.close != nil {
+		s.close()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package gogrep_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+// bzip2Data is "bzip2 match\nother\n" compressed by bzip2.
+var bzip2Data = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xd7, 0xc2,
+	0xef, 0x06, 0x00, 0x00, 0x02, 0xd9, 0x80, 0x00, 0x10, 0x40, 0x00, 0x10,
+	0x00, 0x3a, 0x62, 0xd4, 0x10, 0x20, 0x00, 0x22, 0x8d, 0x1e, 0x90, 0xd3,
+	0xc5, 0x0a, 0x60, 0x00, 0x0f, 0x34, 0x7c, 0x10, 0xa8, 0x7d, 0x09, 0x16,
+	0xfe, 0x2e, 0xe4, 0x8a, 0x70, 0xa1, 0x21, 0xaf, 0x85, 0xde, 0x0c,
+}
+
+func TestDecodingReader(t *testing.T) {
+	var gzipData bytes.Buffer
+	gw := gzip.NewWriter(&gzipData)
+	_, err := gw.Write([]byte("gzip match\nother\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, gw.Close())
+
+	zw, err := zstd.NewWriter(nil)
+	assert.Nil(t, err)
+	zstdData := zw.EncodeAll([]byte("zstd match\nother\n"), nil)
+
+	for _, tc := range []*struct {
+		title string
+		data  []byte
+		want  string
+	}{
+		{title: "plain", data: []byte("plain match\nother\n"), want: "plain match"},
+		{title: "empty", data: []byte{}},
+		{title: "gzip", data: gzipData.Bytes(), want: "gzip match"},
+		{title: "bzip2", data: bzip2Data, want: "bzip2 match"},
+		{title: "zstd", data: zstdData, want: "zstd match"},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			r := gogrep.NewDecodingReader(bytes.NewReader(tc.data))
+			defer r.Close()
+			resultC, err := gogrep.New().Grep(context.TODO(), "match", r)
+			assert.Nil(t, err)
+			var got []string
+			for r := range resultC {
+				assert.Nil(t, r.Err())
+				got = append(got, r.Text())
+			}
+			if tc.want == "" {
+				assert.Equal(t, 0, len(got))
+				return
+			}
+			assert.Equal(t, []string{tc.want}, got)
+		})
+	}
+
+	t.Run("with decompression", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithDecompression()).Grep(context.TODO(), "match", bytes.NewReader(bzip2Data))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "bzip2 match", results[0].Text())
+	})
+
+	t.Run("broken", func(t *testing.T) {
+		_, err := io.ReadAll(gogrep.NewDecodingReader(strings.NewReader("\x1f\x8bbroken")))
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,101 @@
+package gogrep
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Delimiters is a pair of the opening and the closing delimiters.
+type Delimiters struct {
+	Open  string
+	Close string
+}
+
+func (d Delimiters) String() string { return d.Open + "..." + d.Close }
+
+// ParseDelimiters parses a string like "(...)" into Delimiters.
+func ParseDelimiters(s string) (Delimiters, error) {
+	xs := strings.SplitN(s, "...", 2)
+	if len(xs) != 2 || xs[0] == "" || xs[1] == "" {
+		return Delimiters{}, fmt.Errorf("invalid delimiters %q, want OPEN...CLOSE", s)
+	}
+	return Delimiters{
+		Open:  xs[0],
+		Close: xs[1],
+	}, nil
+}
+
+// lineMasker hides the parts of lines from matchers.
+type lineMasker interface {
+	// mask replaces the bytes to be hidden with spaces.
+	mask(line string) string
+}
+
+// delimiterMasker masks the regions enclosed in the delimiters, including the delimiters themselves.
+//
+// A region of asymmetric delimiters like (...) nests the same delimiters and continues across lines.
+// A region of symmetric delimiters like "..." ends at the end of the line.
+// Other delimiters inside a region are ignored.
+type delimiterMasker struct {
+	delimiters []Delimiters
+	current    int // index of the delimiters of the current region, -1 if outside
+	depth      int
+}
+
+func newDelimiterMasker(delimiters []Delimiters) *delimiterMasker {
+	return &delimiterMasker{
+		delimiters: delimiters,
+		current:    -1,
+	}
+}
+
+func (s *delimiterMasker) mask(line string) string {
+	buf := []byte(line)
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		if s.current < 0 {
+			for j, d := range s.delimiters {
+				if strings.HasPrefix(rest, d.Open) {
+					s.current, s.depth = j, 1
+					blank(buf, i, len(d.Open))
+					i += len(d.Open)
+					break
+				}
+			}
+			if s.current < 0 {
+				i++
+			}
+			continue
+		}
+		d := s.delimiters[s.current]
+		switch {
+		case strings.HasPrefix(rest, d.Close):
+			s.depth--
+			if s.depth == 0 {
+				s.current = -1
+			}
+			blank(buf, i, len(d.Close))
+			i += len(d.Close)
+		case d.Open != d.Close && strings.HasPrefix(rest, d.Open):
+			s.depth++
+			blank(buf, i, len(d.Open))
+			i += len(d.Open)
+		default:
+			blank(buf, i, 1)
+			i++
+		}
+	}
+	if s.current >= 0 {
+		if d := s.delimiters[s.current]; d.Open == d.Close {
+			s.current, s.depth = -1, 0
+		}
+	}
+	return string(buf)
+}
+
+// blank replaces buf[i:i+n] with spaces.
+func blank(buf []byte, i, n int) {
+	for j := i; j < i+n && j < len(buf); j++ {
+		buf[j] = ' '
+	}
+}
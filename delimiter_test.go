@@ -0,0 +1,41 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDelimiters(t *testing.T) {
+	d, err := gogrep.ParseDelimiters(`/*...*/`)
+	assert.Nil(t, err)
+	assert.Equal(t, gogrep.Delimiters{Open: "/*", Close: "*/"}, d)
+	_, err = gogrep.ParseDelimiters(`"`)
+	assert.NotNil(t, err)
+}
+
+func TestNotInside(t *testing.T) {
+	source := strings.Join([]string{
+		`"target" in quotes`,
+		`"unterminated target`,
+		`target "outside" too`,
+		`(nested (target) here)`,
+		`(multiline`,
+		`target)`,
+		`target`,
+	}, "\n")
+	resultC, err := gogrep.New(gogrep.WithThreads(1), gogrep.WithNotInside(
+		gogrep.Delimiters{Open: `"`, Close: `"`},
+		gogrep.Delimiters{Open: "(", Close: ")"},
+	)).Grep(context.TODO(), "target", strings.NewReader(source))
+	assert.Nil(t, err)
+	got := []string{}
+	for r := range resultC {
+		assert.Nil(t, r.Err())
+		got = append(got, r.Text())
+	}
+	assert.ElementsMatch(t, []string{`target "outside" too`, `target`}, got)
+}
@@ -0,0 +1,30 @@
+package gogrep
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// LookupEncoding returns the encoding by the name like "utf-16le", "shift_jis" or "latin1".
+// The names are from the WHATWG Encoding Standard and the IANA character sets.
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	if e, err := htmlindex.Get(name); err == nil {
+		return e, nil
+	}
+	if e, err := ianaindex.IANA.Encoding(name); err == nil && e != nil {
+		return e, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %s", name)
+}
+
+// newEncodingReader returns a reader that transcodes the source in the encoding to UTF-8.
+// The BOM of UTF-8 and UTF-16 overrides the encoding.
+func newEncodingReader(source io.Reader, e encoding.Encoding) io.Reader {
+	return transform.NewReader(source, unicode.BOMOverride(e.NewDecoder()))
+}
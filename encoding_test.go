@@ -0,0 +1,47 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestEncoding(t *testing.T) {
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String("first\nマッチ match\n")
+	assert.Nil(t, err)
+	sjis, err := japanese.ShiftJIS.NewEncoder().String("first\nマッチ match\n")
+	assert.Nil(t, err)
+
+	for _, tc := range []*struct {
+		title    string
+		encoding string
+		source   string
+		want     string
+	}{
+		{title: "utf-16 with bom", encoding: "utf-16le", source: utf16, want: "マッチ match"},
+		{title: "bom overrides", encoding: "shift_jis", source: utf16, want: "マッチ match"},
+		{title: "shift_jis", encoding: "shift_jis", source: sjis, want: "マッチ match"},
+		{title: "latin1", encoding: "latin1", source: "first\ncaf\xe9 match\n", want: "café match"},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			resultC, err := gogrep.New(gogrep.WithEncoding(tc.encoding)).Grep(context.TODO(), "match", strings.NewReader(tc.source))
+			assert.Nil(t, err)
+			results := toResultSlice(resultC)
+			assert.Equal(t, 1, len(results))
+			assert.Nil(t, results[0].Err())
+			assert.Equal(t, tc.want, results[0].Text())
+			assert.Equal(t, 2, results[0].Line())
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithEncoding("unknown")).Grep(context.TODO(), "match", strings.NewReader(""))
+		assert.NotNil(t, err)
+	})
+}
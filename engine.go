@@ -0,0 +1,123 @@
+package gogrep
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// Matcher reports whether a line matches a compiled pattern.
+	// *regexp.Regexp is a Matcher.
+	Matcher interface {
+		MatchString(s string) bool
+		// FindAllStringSubmatchIndex returns the successive matches as regexp.FindAllStringSubmatchIndex does.
+		FindAllStringSubmatchIndex(s string, n int) [][]int
+	}
+	// Engine is the name of a matcher implementation.
+	Engine string
+	// CompileFunc compiles a pattern into a Matcher.
+	CompileFunc func(pattern string) (Matcher, error)
+)
+
+const (
+	// EngineRegexp uses the standard regexp package (RE2 syntax).
+	EngineRegexp Engine = "regexp"
+	// EngineFixed treats the pattern as a literal string.
+	EngineFixed Engine = "fixed"
+)
+
+var (
+	// enginesMux guards engines registered while the other goroutines compile, e.g. by the parallel tests.
+	enginesMux sync.RWMutex
+	engines    = map[Engine]CompileFunc{
+		EngineRegexp: func(pattern string) (Matcher, error) { return regexp.Compile(pattern) },
+		EngineFixed:  func(pattern string) (Matcher, error) { return fixedMatcher(pattern), nil },
+	}
+)
+
+// RegisterEngine makes an engine available to WithEngine.
+// It is intended to be called from init functions of optional engines.
+func RegisterEngine(engine Engine, compile CompileFunc) {
+	enginesMux.Lock()
+	defer enginesMux.Unlock()
+	engines[engine] = compile
+}
+
+// Engines returns the names of the available engines in sorted order.
+func Engines() []Engine {
+	enginesMux.RLock()
+	defer enginesMux.RUnlock()
+	r := make([]Engine, 0, len(engines))
+	for e := range engines {
+		r = append(r, e)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r
+}
+
+// Compile compiles a pattern by the engine.
+func (e Engine) Compile(pattern string) (Matcher, error) {
+	enginesMux.RLock()
+	compile, ok := engines[e]
+	enginesMux.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown engine %s", e)
+	}
+	return compile(pattern)
+}
+
+type fixedMatcher string
+
+func (s fixedMatcher) MatchString(line string) bool { return strings.Contains(line, string(s)) }
+func (s fixedMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	return findAllLiterals(line, []string{string(s)}, n)
+}
+
+// multiMatcher matches if any matcher matches.
+type multiMatcher []Matcher
+
+func (s multiMatcher) MatchString(line string) bool {
+	for _, m := range s {
+		if m.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAllStringSubmatchIndex returns the non-overlapping matches of all matchers in order of position.
+// The longer match wins at the same position.
+func (s multiMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	r, _ := findAllMatchers(s, line, n)
+	return r
+}
+
+// findAllLiterals returns the successive non-overlapping matches of the literals.
+// The leftmost match wins and the earlier literal wins at the same position, like regex alternation.
+func findAllLiterals(s string, literals []string, n int) [][]int {
+	var r [][]int
+	for pos := 0; pos <= len(s) && (n < 0 || len(r) < n); {
+		start, end := -1, -1
+		for _, lit := range literals {
+			i := strings.Index(s[pos:], lit)
+			if i < 0 {
+				continue
+			}
+			if start < 0 || pos+i < start {
+				start, end = pos+i, pos+i+len(lit)
+			}
+		}
+		if start < 0 {
+			break
+		}
+		r = append(r, []int{start, end})
+		if end == start {
+			end++ // avoid infinite loop on empty match
+		}
+		pos = end
+	}
+	return r
+}
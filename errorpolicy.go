@@ -0,0 +1,15 @@
+package gogrep
+
+// ErrorPolicy is the policy for the errors while grepping.
+type ErrorPolicy string
+
+const (
+	// ErrorStop ends the grep at the first error.
+	// GrepSources does not grep the rest of the sources.
+	ErrorStop ErrorPolicy = "stop"
+	// ErrorContinue emits the errors as Results and keeps grepping where possible:
+	// the lines too long with LongLineError are skipped,
+	// and GrepSources greps the rest of the sources after an error of a source.
+	// An error of reading a source still ends the grep of the source.
+	ErrorContinue ErrorPolicy = "continue"
+)
@@ -0,0 +1,39 @@
+package gogrep
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBadPattern means a pattern cannot be compiled, e.g. a regex of invalid syntax or larger than WithMaxRegexProgramSize.
+// The error of the compile is wrapped too, e.g. *syntax.Error of the regexes.
+var ErrBadPattern = errors.New("bad pattern")
+
+// badPattern returns the error of the pattern that the caller cannot compile.
+func badPattern(err error, caller, pattern string) error {
+	return fmt.Errorf("%s cannot compile regex %s %w: %w", caller, pattern, ErrBadPattern, err)
+}
+
+// ScanError is the error of a Result of a grep that cannot read the lines of the source,
+// e.g. a line longer than WithMaxLineLength with LongLineError, or a failure of the reader.
+// It unwraps into the error, e.g. bufio.ErrTooLong.
+type ScanError struct {
+	// Line is the 1-based number of the line that cannot be read.
+	Line int
+	Err  error
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("Grepper got error from source at line %d: %v", e.Line, e.Err)
+}
+func (e *ScanError) Unwrap() error { return e.Err }
+
+// SourceError is the error of a Result of GrepSources with the name of the source that got it.
+// It unwraps into the error of the Result from the grep of the source, e.g. *ScanError.
+type SourceError struct {
+	Name string
+	Err  error
+}
+
+func (e *SourceError) Error() string { return fmt.Sprintf("%s: %v", e.Name, e.Err) }
+func (e *SourceError) Unwrap() error { return e.Err }
@@ -0,0 +1,160 @@
+package gogrep_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	t.Run("bad pattern", func(t *testing.T) {
+		for _, tc := range []*struct {
+			title string
+			opt   []gogrep.Option
+			regex string
+			is    error
+		}{
+			{
+				title: "regexp",
+				regex: "a(",
+			},
+			{
+				title: "glob",
+				opt:   []gogrep.Option{gogrep.WithEngine(gogrep.EngineGlob)},
+				regex: "[a",
+			},
+			{
+				title: "too large",
+				opt:   []gogrep.Option{gogrep.WithMaxRegexProgramSize(10)},
+				regex: "a{100}",
+				is:    gogrep.ErrRegexTooLarge,
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				_, err := gogrep.New(tc.opt...).Grep(context.TODO(), tc.regex, strings.NewReader("a\n"))
+				assert.ErrorIs(t, err, gogrep.ErrBadPattern)
+				if tc.is != nil {
+					assert.ErrorIs(t, err, tc.is)
+				}
+			})
+		}
+
+		t.Run("syntax error", func(t *testing.T) {
+			_, err := gogrep.New().GrepMulti(context.TODO(), []string{"a", "b["}, strings.NewReader("a\n"))
+			var e *syntax.Error
+			if assert.ErrorAs(t, err, &e) {
+				assert.Equal(t, syntax.ErrMissingBracket, e.Code)
+			}
+		})
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		resultC, err := gogrep.New(
+			gogrep.WithMaxLineLength(8),
+			gogrep.WithErrorPolicy(gogrep.ErrorContinue),
+		).Grep(context.TODO(), "x", io.MultiReader(
+			strings.NewReader("x\n"+strings.Repeat("x", 10)+"\nx\n"),
+			&errReader{err: io.ErrUnexpectedEOF},
+		))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var (
+			lines []int
+			errs  []error
+		)
+		for r := range resultC {
+			if err := r.Err(); err != nil {
+				var e *gogrep.ScanError
+				if assert.ErrorAs(t, err, &e) {
+					lines = append(lines, e.Line)
+					errs = append(errs, e.Err)
+				}
+			}
+		}
+		assert.Equal(t, []int{2, 4}, lines)
+		assert.Equal(t, []error{bufio.ErrTooLong, io.ErrUnexpectedEOF}, errs)
+	})
+
+	t.Run("scan error in range", func(t *testing.T) {
+		var b strings.Builder
+		for i := 1; i <= 300000; i++ {
+			if i == 250000 {
+				b.WriteString(strings.Repeat("x", 200) + "\n")
+				continue
+			}
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		resultC, err := gogrep.New(
+			gogrep.WithThreads(4),
+			gogrep.WithMaxLineLength(100),
+			gogrep.WithErrorPolicy(gogrep.ErrorContinue),
+		).GrepReaderAt(context.TODO(), []string{"^line 249999$"}, strings.NewReader(b.String()))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var (
+			texts []string
+			errs  []gogrep.Result
+		)
+		for r := range resultC {
+			if r.Err() != nil {
+				errs = append(errs, r)
+				continue
+			}
+			texts = append(texts, r.Text())
+		}
+		assert.Equal(t, []string{"line 249999"}, texts)
+		if !assert.Equal(t, 1, len(errs)) {
+			return
+		}
+		var e *gogrep.ScanError
+		if assert.ErrorAs(t, errs[0].Err(), &e) {
+			assert.Equal(t, 250000, e.Line)
+			assert.Equal(t, 250000, errs[0].Line())
+			assert.Contains(t, e.Error(), "line 250000")
+		}
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithoutBatching()).GrepSources(context.TODO(), []string{"x"}, []gogrep.NamedSource{
+			{
+				Name:   "good",
+				Reader: strings.NewReader("x\n"),
+			},
+			{
+				Name:   "bad",
+				Reader: &errReader{err: io.ErrUnexpectedEOF},
+			},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		var got []error
+		for r := range resultC {
+			if err := r.Err(); err != nil {
+				got = append(got, err)
+			}
+		}
+		if !assert.Equal(t, 1, len(got)) {
+			return
+		}
+		var e *gogrep.SourceError
+		if assert.ErrorAs(t, got[0], &e) {
+			assert.Equal(t, "bad", e.Name)
+		}
+		var s *gogrep.ScanError
+		if assert.ErrorAs(t, got[0], &s) {
+			assert.Equal(t, 1, s.Line)
+		}
+		assert.True(t, errors.Is(got[0], io.ErrUnexpectedEOF))
+	})
+}
@@ -0,0 +1,95 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+func (s *grepper) GrepFile(ctx context.Context, regex string, path string) (<-chan Result, error) {
+	// Already canceled
+	if isDone(ctx) {
+		return nil, wrapErr(cancellationError(ctx), "Grepper")
+	}
+	r, err := s.compileMulti([]string{regex})
+	if err != nil {
+		return nil, err
+	}
+	g := *s
+	if s.config.concurrencyHint != nil || s.config.logger != nil {
+		g = *s.withOptions([]Option{withSourceName(path)})
+	}
+	if err := g.config.validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	g.done = func() { f.Close() }
+	if g.config.perSourceTimeout > 0 {
+		reads := &pendingReads{}
+		g.reads = reads
+		g.done = func() { reads.close(f) }
+	}
+	var source io.Reader = f
+	if g.config.startOffset > 0 {
+		if _, err := f.Seek(g.config.startOffset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	} else {
+		source = openFile(f)
+	}
+	resultC, err := g.grepSource(ctx, r, source)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return resultC, nil
+}
+
+// NewFileReaderAt returns the FileReaderAt of the file, false unless the file is a regular file without holes.
+func NewFileReaderAt(f *os.File) (*FileReaderAt, bool) {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, false
+	}
+	size := info.Size()
+	data, hole, err := findData(f, 0, size)
+	// findData moves the offset of the file
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, false
+	}
+	if size > 0 && (err != nil || data != 0 || hole != size) {
+		return nil, false
+	}
+	return &FileReaderAt{
+		File: f,
+		size: size,
+	}, true
+}
+
+// FileReaderAt is a SizedReaderAt of a regular file whose ranges are grepped in parallel by the reads at the offsets.
+// It reads the file from the beginning as an io.Reader too, e.g. for the small files grepped sequentially.
+type FileReaderAt struct {
+	*os.File
+	size int64
+}
+
+// Size returns the size of the file when opened.
+func (s *FileReaderAt) Size() int64 { return s.size }
+
+// openFile returns the source of the file, the ranges of the regular file,
+// the data regions of the sparse file, or the file itself.
+func openFile(f *os.File) io.Reader {
+	if r, ok := NewFileReaderAt(f); ok {
+		return r
+	}
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		if r, err := NewSparseReader(f); err == nil {
+			return r
+		}
+	}
+	return f
+}
@@ -0,0 +1,94 @@
+package gogrep_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrepFile(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 200000; i++ {
+		fmt.Fprintf(&b, "line %d %s\n", i, strings.Repeat("x", i%40))
+	}
+	source := b.String()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "source")
+	if err := os.WriteFile(path, []byte(source), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	type line struct {
+		text   string
+		line   int
+		offset int64
+	}
+	collect := func(resultC <-chan gogrep.Result) []line {
+		var r []line
+		for x := range resultC {
+			assert.Nil(t, x.Err())
+			r = append(r, line{
+				text:   x.Text(),
+				line:   x.Line(),
+				offset: x.Offset(),
+			})
+		}
+		return r
+	}
+
+	t.Run("ranges", func(t *testing.T) {
+		var stats gogrep.Stats
+		resultC, err := gogrep.New(
+			gogrep.WithThreads(4),
+			gogrep.WithStatsCollector(func(s gogrep.Stats) { stats = s }),
+		).GrepFile(context.TODO(), "^line [0-9]*7 ", path)
+		if !assert.Nil(t, err) {
+			return
+		}
+		got := collect(resultC)
+		resultC, err = gogrep.New().Grep(context.TODO(), "^line [0-9]*7 ", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		want := collect(resultC)
+		sort.Slice(want, func(i, j int) bool { return want[i].line < want[j].line })
+		assert.Equal(t, 20000, len(got))
+		assert.Equal(t, want, got, "in order")
+		assert.Equal(t, int64(len(source)), stats.BytesRead)
+		assert.Equal(t, 4, len(stats.Workers))
+	})
+
+	t.Run("small", func(t *testing.T) {
+		small := filepath.Join(dir, "small")
+		if err := os.WriteFile(small, []byte("a\nb\nab\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		resultC, err := gogrep.New(gogrep.WithThreads(1)).GrepFile(context.TODO(), "a", small)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []line{{"a", 1, 0}, {"ab", 3, 4}}, collect(resultC))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := gogrep.New().GrepFile(context.TODO(), "a", filepath.Join(dir, "none"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("not regular", func(t *testing.T) {
+		f, err := os.Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		_, ok := gogrep.NewFileReaderAt(f)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,35 @@
+package gogrep
+
+import (
+	"errors"
+	"os"
+)
+
+// FileID identifies a file on the system regardless of its paths,
+// the device and the inode on Unix and the volume serial number and the file index on Windows.
+// The hard links and the paths of the same file have the same FileID.
+type FileID struct {
+	Device uint64
+	Inode  uint64
+}
+
+// ErrFileIDUnsupported is returned by FileIDOf and StatFileID on the platforms without the identities of the files.
+var ErrFileIDUnsupported = errors.New("file identity unsupported")
+
+// FileIDOf returns the identity of the opened file.
+func FileIDOf(f *os.File) (FileID, error) {
+	id, err := fileIDOf(f)
+	if err != nil {
+		return FileID{}, wrapErr(err, "FileIDOf %s", f.Name())
+	}
+	return id, nil
+}
+
+// StatFileID returns the identity of the named file, following the symbolic links.
+func StatFileID(name string) (FileID, error) {
+	id, err := statFileID(name)
+	if err != nil {
+		return FileID{}, wrapErr(err, "StatFileID %s", name)
+	}
+	return id, nil
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || windows)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!solaris,!windows
+
+package gogrep
+
+import "os"
+
+func fileIDOf(*os.File) (FileID, error) { return FileID{}, ErrFileIDUnsupported }
+
+func statFileID(string) (FileID, error) { return FileID{}, ErrFileIDUnsupported }
@@ -0,0 +1,54 @@
+package gogrep_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileID(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		a    = filepath.Join(dir, "a")
+		b    = filepath.Join(dir, "b")
+		link = filepath.Join(dir, "link")
+	)
+	for _, name := range []string{a, b} {
+		if err := os.WriteFile(name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(a, link); err != nil {
+		t.Skipf("hard links unsupported: %v", err)
+	}
+
+	idA, err := gogrep.StatFileID(a)
+	if errors.Is(err, gogrep.ErrFileIDUnsupported) {
+		t.Skip(err)
+	}
+	if !assert.Nil(t, err) {
+		return
+	}
+	idB, err := gogrep.StatFileID(b)
+	assert.Nil(t, err)
+	idLink, err := gogrep.StatFileID(link)
+	assert.Nil(t, err)
+	assert.NotEqual(t, idA, idB)
+	assert.Equal(t, idA, idLink)
+
+	f, err := os.Open(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	idF, err := gogrep.FileIDOf(f)
+	assert.Nil(t, err)
+	assert.Equal(t, idA, idF)
+
+	_, err = gogrep.StatFileID(filepath.Join(dir, "none"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,41 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris
+
+package gogrep
+
+import (
+	"os"
+	"syscall"
+)
+
+func init() {
+	registerCapability("fileid")
+}
+
+func fileIDOf(f *os.File) (FileID, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return FileID{}, err
+	}
+	return fileIDFromInfo(info)
+}
+
+func statFileID(name string) (FileID, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return FileID{}, err
+	}
+	return fileIDFromInfo(info)
+}
+
+func fileIDFromInfo(info os.FileInfo) (FileID, error) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileID{}, ErrFileIDUnsupported
+	}
+	// The types of the fields vary by the platforms
+	return FileID{
+		Device: uint64(st.Dev),
+		Inode:  uint64(st.Ino),
+	}, nil
+}
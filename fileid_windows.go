@@ -0,0 +1,33 @@
+//go:build windows
+// +build windows
+
+package gogrep
+
+import (
+	"os"
+	"syscall"
+)
+
+func init() {
+	registerCapability("fileid")
+}
+
+func fileIDOf(f *os.File) (FileID, error) {
+	var d syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &d); err != nil {
+		return FileID{}, err
+	}
+	return FileID{
+		Device: uint64(d.VolumeSerialNumber),
+		Inode:  uint64(d.FileIndexHigh)<<32 | uint64(d.FileIndexLow),
+	}, nil
+}
+
+func statFileID(name string) (FileID, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return FileID{}, err
+	}
+	defer f.Close()
+	return fileIDOf(f)
+}
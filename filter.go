@@ -0,0 +1,152 @@
+package gogrep
+
+import (
+	"context"
+	"errors"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+// Stage is a step of WithPipeline that selects or rewrites a line.
+// The zero Stage makes Grep fail.
+type Stage struct {
+	kind      stageKind
+	regex     string
+	not       *Stage
+	transform func(text string) string
+}
+
+type stageKind int
+
+const (
+	stageNone stageKind = iota
+	stageRegex
+	stageNot
+	stageTransform
+)
+
+// MatchRegex is the Stage that keeps the lines that match the regex.
+// The regex is compiled by WithEngine, WithFuzzy, WithNormalization and WithCaseFolding as the regex of a grep,
+// and the bad regex makes Grep fail.
+func MatchRegex(regex string) Stage {
+	return Stage{
+		kind:  stageRegex,
+		regex: regex,
+	}
+}
+
+// Not is the Stage that keeps the lines that the stage drops, e.g. Not(MatchRegex("DEBUG")).
+// The line rewritten by the stage is not kept, so Not of a Transform drops all the lines.
+func Not(stage Stage) Stage {
+	return Stage{
+		kind: stageNot,
+		not:  &stage,
+	}
+}
+
+// Transform is the Stage that rewrites the text of the line by f.
+// f is called concurrently by the workers.
+func Transform(f func(text string) string) Stage {
+	return Stage{
+		kind:      stageTransform,
+		transform: f,
+	}
+}
+
+// WithPipeline applies the stages in order to each line in the workers before the regex of a grep,
+// so that the lines matching a regex but not another can be rewritten in a pass over the source,
+// e.g. WithPipeline(MatchRegex("ERROR"), Not(MatchRegex("timeout")), Transform(strings.TrimSpace)).
+// The lines dropped by a stage are not matched by the regex of the grep, which can be empty to select all the lines kept,
+// and the text of the results is the one rewritten by the stages.
+// The stages see the text of the line, and the masks of WithScope and WithNotInside do not apply to the text rewritten.
+// It conflicts with WithMultiline.
+func WithPipeline(stages ...Stage) Option {
+	return func(c *Config) {
+		c.stages = stages
+	}
+}
+
+// lineStage is a compiled Stage that returns the text of the line and whether the line is kept.
+type lineStage func(text string) (string, bool)
+
+var errEmptyStage = errors.New("Grepper got an empty stage")
+
+// compileStages compiles the stages of WithPipeline.
+func (s *grepper) compileStages() ([]lineStage, error) {
+	r := make([]lineStage, len(s.config.stages))
+	for i, x := range s.config.stages {
+		f, err := s.compileStage(x)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = f
+	}
+	return r, nil
+}
+
+func (s *grepper) compileStage(x Stage) (lineStage, error) {
+	switch x.kind {
+	case stageNot:
+		f, err := s.compileStage(*x.not)
+		if err != nil {
+			return nil, err
+		}
+		return func(text string) (string, bool) {
+			_, ok := f(text)
+			return text, !ok
+		}, nil
+	case stageTransform:
+		return func(text string) (string, bool) {
+			return x.transform(text), true
+		}, nil
+	case stageRegex:
+		m, err := s.compile(x.regex)
+		if err != nil {
+			return nil, err
+		}
+		return func(text string) (string, bool) {
+			return text, m.MatchString(text)
+		}, nil
+	default:
+		return nil, errEmptyStage
+	}
+}
+
+// stageMatcher applies the stages of WithPipeline to the lines of a chunk before the matcher.
+type stageMatcher struct {
+	stages []lineStage
+	next   *lineMatcher
+}
+
+func (s *stageMatcher) Match(chunk []pipeline.Record, emit func(pipeline.Item)) {
+	s.MatchContext(context.Background(), chunk, emit)
+}
+
+// MatchContext matches the lines kept by the stages.
+// The lines are kept in the chunk, which is owned by the worker until the match ends.
+func (s *stageMatcher) MatchContext(ctx context.Context, chunk []pipeline.Record, emit func(pipeline.Item)) {
+	var n int
+	for _, l := range chunk {
+		if s.apply(&l) {
+			chunk[n] = l
+			n++
+		}
+	}
+	s.next.MatchContext(ctx, chunk[:n], emit)
+}
+
+// apply applies the stages to the line and returns true if the line is kept.
+func (s *stageMatcher) apply(l *pipeline.Record) bool {
+	text := l.Text
+	for _, f := range s.stages {
+		var ok bool
+		if text, ok = f(text); !ok {
+			return false
+		}
+	}
+	if text != l.Text {
+		// The masks are of the original text
+		l.Text, l.View = text, text
+	}
+	return true
+}
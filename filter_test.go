@@ -0,0 +1,130 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPipeline(t *testing.T) {
+	const source = "ERROR timeout a\nINFO b\nERROR disk c\nERROR disk d\nWARN disk e\n"
+	grep := func(t *testing.T, regex string, opt ...gogrep.Option) []string {
+		resultC, err := gogrep.New(append(opt, gogrep.WithThreads(4), gogrep.WithSort(gogrep.SortByLine))...).
+			Grep(context.TODO(), regex, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		var got []string
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			x := fmt.Sprintf("%d:%d:%s", r.Line(), r.Offset(), r.Text())
+			if regex != "" {
+				x += fmt.Sprintf(":%v", r.MatchRanges())
+			}
+			got = append(got, x)
+		}
+		return got
+	}
+
+	for _, tc := range []struct {
+		title  string
+		regex  string
+		stages []gogrep.Stage
+		opt    []gogrep.Option
+		want   []string
+	}{
+		{
+			title:  "match",
+			stages: []gogrep.Stage{gogrep.MatchRegex("^ERROR")},
+			want: []string{
+				"1:0:ERROR timeout a",
+				"3:23:ERROR disk c",
+				"4:36:ERROR disk d",
+			},
+		},
+		{
+			title:  "not",
+			regex:  "disk",
+			stages: []gogrep.Stage{gogrep.Not(gogrep.MatchRegex("^ERROR"))},
+			want:   []string{"5:49:WARN disk e:[[5 9]]"},
+		},
+		{
+			title: "match not and transform",
+			regex: "[cd]$",
+			stages: []gogrep.Stage{
+				gogrep.MatchRegex("ERROR"),
+				gogrep.Not(gogrep.MatchRegex("timeout")),
+				gogrep.Transform(func(text string) string { return strings.TrimPrefix(text, "ERROR ") }),
+			},
+			want: []string{
+				"3:23:disk c:[[5 6]]",
+				"4:36:disk d:[[5 6]]",
+			},
+		},
+		{
+			title: "transform before match",
+			stages: []gogrep.Stage{
+				gogrep.Transform(strings.ToLower),
+				gogrep.MatchRegex("^warn"),
+			},
+			want: []string{"5:49:warn disk e"},
+		},
+		{
+			title:  "case folding",
+			stages: []gogrep.Stage{gogrep.MatchRegex("info")},
+			opt:    []gogrep.Option{gogrep.WithCaseFolding()},
+			want:   []string{"2:16:INFO b"},
+		},
+		{
+			title:  "not transform",
+			stages: []gogrep.Stage{gogrep.Not(gogrep.Transform(strings.ToLower))},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, grep(t, tc.regex, append(tc.opt, gogrep.WithPipeline(tc.stages...))...))
+		})
+	}
+
+	t.Run("ranges", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 100000; i++ {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		resultC, err := gogrep.New(
+			gogrep.WithThreads(4),
+			gogrep.WithPipeline(gogrep.MatchRegex("7$"), gogrep.Transform(strings.ToUpper)),
+		).GrepReaderAt(context.TODO(), []string{"^LINE"}, strings.NewReader(b.String()))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var n int
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			assert.Equal(t, fmt.Sprintf("LINE %d", r.Line()-1), r.Text())
+			n++
+		}
+		assert.Equal(t, 10000, n)
+	})
+
+	t.Run("bad regex", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithPipeline(gogrep.Not(gogrep.MatchRegex("(")))).
+			Grep(context.TODO(), "", strings.NewReader(source))
+		assert.True(t, errors.Is(err, gogrep.ErrBadPattern), "%v", err)
+	})
+
+	t.Run("zero stage", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithPipeline(gogrep.Stage{})).Grep(context.TODO(), "", strings.NewReader(source))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("multiline", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithPipeline(gogrep.MatchRegex("a")), gogrep.WithMultiline()).
+			Grep(context.TODO(), "", strings.NewReader(source))
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,66 @@
+package gogrep
+
+import "time"
+
+// FlushPolicy decides when the lines read are dispatched to the workers
+// and when the results written to the Sink of WithSink are flushed.
+// A batch is dispatched or flushed when any of the limits is reached, trading the throughput for the latency.
+type FlushPolicy struct {
+	// MaxLines is the number of the lines dispatched at once, and the number of the results flushed at once.
+	// Not positive number means 100.
+	MaxLines int
+	// MaxBytes is the bytes of the lines dispatched at once, and of the texts of the results flushed at once.
+	// Not positive number means no limit.
+	MaxBytes int
+	// MaxDelay is the max time the first line or result of a batch waits for the rest of the batch,
+	// e.g. the matches of a slow source that should be seen soon.
+	// Not positive number means no limit.
+	MaxDelay time.Duration
+}
+
+// DefaultFlushPolicy is the FlushPolicy by default, dispatching and flushing every 100 lines.
+// WithFollow dispatches and flushes every line by default.
+var DefaultFlushPolicy = FlushPolicy{
+	MaxLines: grepChunkSize,
+}
+
+// WithFlushPolicy sets the policy of dispatching the lines and flushing the results.
+func WithFlushPolicy(policy FlushPolicy) Option {
+	return func(c *Config) {
+		if policy.MaxLines < 1 {
+			policy.MaxLines = grepChunkSize
+		}
+		c.flushPolicy = &policy
+	}
+}
+
+// flush returns the FlushPolicy of the config.
+func (c *Config) flush() FlushPolicy {
+	switch {
+	case c.flushPolicy != nil:
+		return *c.flushPolicy
+	case c.follow > 0:
+		return FlushPolicy{MaxLines: 1}
+	default:
+		return DefaultFlushPolicy
+	}
+}
+
+// flushCounter counts the results written to a Sink since the last flush.
+type flushCounter struct {
+	policy FlushPolicy
+	lines  int
+	bytes  int
+}
+
+// add counts the result and returns true if the results should be flushed.
+func (s *flushCounter) add(r Result) bool {
+	s.lines++
+	s.bytes += len(r.Text())
+	return s.lines >= s.policy.MaxLines || (s.policy.MaxBytes > 0 && s.bytes >= s.policy.MaxBytes)
+}
+
+func (s *flushCounter) reset() {
+	s.lines = 0
+	s.bytes = 0
+}
@@ -0,0 +1,108 @@
+package gogrep_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithFlushPolicy(t *testing.T) {
+	t.Run("max lines", func(t *testing.T) {
+		var (
+			mux     sync.Mutex
+			written int
+			flushed []int // the results written at each flush
+		)
+		sink := &testSink{
+			write: func(gogrep.Result) error {
+				mux.Lock()
+				defer mux.Unlock()
+				written++
+				return nil
+			},
+			flush: func() error {
+				mux.Lock()
+				defer mux.Unlock()
+				flushed = append(flushed, written)
+				return nil
+			},
+		}
+		g := gogrep.New(gogrep.WithSink(sink), gogrep.WithThreads(1), gogrep.WithFlushPolicy(gogrep.FlushPolicy{
+			MaxLines: 2,
+		}))
+		resultC, err := g.Grep(context.TODO(), "match", strings.NewReader(strings.Repeat("match\nskip\n", 5)))
+		if !assert.Nil(t, err) {
+			return
+		}
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+		}
+		assert.Equal(t, []int{2, 4, 5}, flushed)
+	})
+
+	t.Run("max delay", func(t *testing.T) {
+		r, w := io.Pipe()
+		defer w.Close()
+		flushC := make(chan struct{}, 10)
+		sink := &testSink{
+			write: func(gogrep.Result) error { return nil },
+			flush: func() error {
+				flushC <- struct{}{}
+				return nil
+			},
+		}
+		g := gogrep.New(gogrep.WithSink(sink), gogrep.WithFlushPolicy(gogrep.FlushPolicy{
+			MaxDelay: 10 * time.Millisecond,
+		}))
+		resultC, err := g.Grep(context.TODO(), "match", r)
+		if !assert.Nil(t, err) {
+			return
+		}
+		// Flushed before the end of the source
+		_, _ = io.WriteString(w, "match\n")
+		select {
+		case <-flushC:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+		w.Close()
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+		}
+	})
+}
+
+func TestWithAutoTune(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&b, "line %d %s\n", i, strings.Repeat("x", i%100))
+	}
+	var stats gogrep.Stats
+	resultC, err := gogrep.New(
+		gogrep.WithAutoTune(),
+		gogrep.WithThreads(4),
+		gogrep.WithStatsCollector(func(s gogrep.Stats) { stats = s }),
+	).Grep(context.TODO(), "^line [0-9]*7 ", strings.NewReader(b.String()))
+	if !assert.Nil(t, err) {
+		return
+	}
+	lines := map[int]bool{}
+	for r := range resultC {
+		assert.Nil(t, r.Err())
+		lines[r.Line()] = true
+	}
+	assert.Equal(t, 5000, len(lines))
+	assert.Equal(t, int64(50000), stats.LinesScanned)
+	var chunks int64
+	for _, w := range stats.Workers {
+		chunks += w.Chunks
+	}
+	assert.Less(t, chunks, int64(50000/100), "larger chunks than the default")
+}
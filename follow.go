@@ -0,0 +1,128 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// defaultFollowInterval is the interval of polling the followed file by default.
+const defaultFollowInterval = 250 * time.Millisecond
+
+// FollowFile returns a source that reads the file and then waits for the data appended to the file like tail -F.
+// The file is read again from the beginning if it is truncated,
+// or if another file is created at the name, e.g. by log rotation, after the rest of the old file is read.
+// The file that does not exist yet is waited for.
+// The file is polled by the interval of WithFollow and the clock of WithClock of the options.
+// Read does not return io.EOF but blocks until the data is appended, and returns the error of the context after it is canceled,
+// so grep the source WithFollow to get the matches as soon as the lines are appended.
+func FollowFile(ctx context.Context, name string, opt ...Option) io.ReadCloser {
+	c := newConfig()
+	WithFollow(0)(c)
+	for _, o := range opt {
+		o(c)
+	}
+	return &followReader{
+		ctx:      ctx,
+		name:     name,
+		interval: c.follow,
+		clock:    c.clock,
+	}
+}
+
+type followReader struct {
+	ctx      context.Context
+	name     string
+	interval time.Duration
+	clock    Clock
+	f        *os.File    // nil until the file exists
+	info     os.FileInfo // of f
+	id       *FileID     // of f, nil if unsupported
+	offset   int64       // read from f
+}
+
+func (s *followReader) Read(p []byte) (int, error) {
+	for {
+		if isDone(s.ctx) {
+			return 0, s.ctx.Err()
+		}
+		if s.f == nil {
+			if err := s.open(); err != nil && !os.IsNotExist(err) {
+				return 0, err
+			}
+		}
+		if s.f != nil {
+			n, err := s.f.Read(p)
+			s.offset += int64(n)
+			if n > 0 {
+				return n, nil
+			}
+			if err != nil && err != io.EOF {
+				return 0, err
+			}
+			if s.reset() {
+				continue
+			}
+		}
+		select {
+		case <-s.ctx.Done():
+			return 0, s.ctx.Err()
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+func (s *followReader) open() error {
+	f, err := os.Open(s.name)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f = f
+	s.info = info
+	s.id = nil
+	if id, err := FileIDOf(f); err == nil {
+		s.id = &id
+	}
+	s.offset = 0
+	return nil
+}
+
+// reset reopens or rewinds the file at the end if it is replaced or truncated.
+// Returns true if the file should be read again.
+func (s *followReader) reset() bool {
+	if s.replaced() {
+		s.f.Close()
+		s.f = nil
+		return true
+	}
+	if info, err := s.f.Stat(); err == nil && info.Size() < s.offset {
+		if _, err := s.f.Seek(0, io.SeekStart); err == nil {
+			s.offset = 0
+			return true
+		}
+	}
+	return false
+}
+
+// replaced returns true if the name refers to another file than the opened one, e.g. rotated.
+func (s *followReader) replaced() bool {
+	if s.id != nil {
+		id, err := StatFileID(s.name)
+		return err == nil && id != *s.id
+	}
+	info, err := os.Stat(s.name)
+	return err == nil && !os.SameFile(info, s.info)
+}
+
+func (s *followReader) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
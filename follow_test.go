@@ -0,0 +1,86 @@
+package gogrep_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowFile(t *testing.T) {
+	const interval = 5 * time.Millisecond
+	var (
+		dir  = t.TempDir()
+		name = filepath.Join(dir, "app.log")
+	)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	source := gogrep.FollowFile(ctx, name, gogrep.WithFollow(interval))
+	defer source.Close()
+	resultC, err := gogrep.New(gogrep.WithFollow(interval)).Grep(ctx, "match", source)
+	if !assert.Nil(t, err) {
+		return
+	}
+	next := func(t *testing.T) gogrep.Result {
+		select {
+		case r := <-resultC:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+			return nil
+		}
+	}
+	assertNext := func(t *testing.T, want string) {
+		r := next(t)
+		if assert.NotNil(t, r) {
+			assert.Nil(t, r.Err())
+			assert.Equal(t, want, r.Text())
+		}
+	}
+	appendFile := func(t *testing.T, data string) {
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("created", func(t *testing.T) {
+		appendFile(t, "match 1\nskip\n")
+		assertNext(t, "match 1")
+	})
+	t.Run("appended", func(t *testing.T) {
+		appendFile(t, "skip\nmatch 2\n")
+		assertNext(t, "match 2")
+	})
+	t.Run("truncated", func(t *testing.T) {
+		if err := os.WriteFile(name, []byte("match 3\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		assertNext(t, "match 3")
+	})
+	t.Run("rotated", func(t *testing.T) {
+		if err := os.Rename(name, name+".1"); err != nil {
+			t.Fatal(err)
+		}
+		appendFile(t, "match 4\n")
+		assertNext(t, "match 4")
+	})
+	t.Run("canceled", func(t *testing.T) {
+		cancel()
+		select {
+		case r, ok := <-resultC:
+			assert.False(t, ok, "got %v", r)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package gogrep
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrNotFramed means the source is not in a format of independently compressed frames.
+var ErrNotFramed = errors.New("not framed compression")
+
+// FrameFormat is a compression format that consists of independently compressed frames.
+type FrameFormat string
+
+const (
+	// FrameBGZF is the blocked gzip format of bgzip.
+	FrameBGZF FrameFormat = "bgzf"
+	// FrameZstdSeekable is the zstd seekable format.
+	FrameZstdSeekable FrameFormat = "zstd-seekable"
+)
+
+// frame is a compressed frame in the source.
+type frame struct {
+	offset int64
+	size   int64
+}
+
+// DetectFrames detects the format and the frames of the source.
+// Returns ErrNotFramed if the source is neither BGZF nor zstd seekable format.
+func DetectFrames(r io.ReaderAt, size int64) (FrameFormat, error) {
+	if _, err := bgzfFrames(r, size); err == nil {
+		return FrameBGZF, nil
+	}
+	if _, err := zstdSeekableFrames(r, size); err == nil {
+		return FrameZstdSeekable, nil
+	}
+	return "", ErrNotFramed
+}
+
+// NewFrameReader returns a reader of the decompressed content of the framed source.
+// The frames are decompressed in parallel by the threads and read in order,
+// so that Grep scans the content without the single-threaded decompression bottleneck.
+// Not positive threads means runtime.GOMAXPROCS(0).
+// Returns ErrNotFramed if the source is neither BGZF nor zstd seekable format.
+func NewFrameReader(ctx context.Context, r io.ReaderAt, size int64, threads int) (io.ReadCloser, error) {
+	if threads <= 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+	if frames, err := bgzfFrames(r, size); err == nil {
+		return newFrameReader(ctx, r, frames, threads, decodeGzip), nil
+	}
+	if frames, err := zstdSeekableFrames(r, size); err == nil {
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		return newFrameReader(ctx, r, frames, threads, func(b []byte) ([]byte, error) {
+			return dec.DecodeAll(b, nil)
+		}), nil
+	}
+	return nil, ErrNotFramed
+}
+
+func decodeGzip(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+// bgzfFrames returns the blocks of BGZF.
+// Each block is a gzip member with the BC extra subfield that tells the block size.
+func bgzfFrames(r io.ReaderAt, size int64) ([]frame, error) {
+	var (
+		frames []frame
+		header = make([]byte, 18)
+	)
+	for offset := int64(0); offset < size; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, ErrNotFramed
+		}
+		// ID1 ID2 CM FLG(FEXTRA) ... XLEN=6 SI1='B' SI2='C' SLEN=2 BSIZE
+		if header[0] != 0x1f || header[1] != 0x8b || header[2] != 8 || header[3]&4 == 0 ||
+			binary.LittleEndian.Uint16(header[10:]) != 6 || header[12] != 'B' || header[13] != 'C' {
+			return nil, ErrNotFramed
+		}
+		n := int64(binary.LittleEndian.Uint16(header[16:])) + 1
+		frames = append(frames, frame{
+			offset: offset,
+			size:   n,
+		})
+		offset += n
+	}
+	if len(frames) == 0 {
+		return nil, ErrNotFramed
+	}
+	return frames, nil
+}
+
+const (
+	zstdSeekableMagic    = 0x8F92EAB1
+	zstdSkippableMagic   = 0x184D2A5E
+	zstdSeekFooterSize   = 9
+	zstdSkippableHdrSize = 8
+)
+
+// zstdSeekableFrames returns the frames from the seek table at the end of the zstd seekable format.
+func zstdSeekableFrames(r io.ReaderAt, size int64) ([]frame, error) {
+	if size < zstdSeekFooterSize+zstdSkippableHdrSize {
+		return nil, ErrNotFramed
+	}
+	footer := make([]byte, zstdSeekFooterSize)
+	if _, err := r.ReadAt(footer, size-zstdSeekFooterSize); err != nil {
+		return nil, ErrNotFramed
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != zstdSeekableMagic {
+		return nil, ErrNotFramed
+	}
+	var (
+		numFrames = int64(binary.LittleEndian.Uint32(footer))
+		entrySize = int64(8)
+	)
+	if footer[4]&0x80 != 0 {
+		entrySize = 12 // with checksum
+	}
+	tableSize := numFrames*entrySize + zstdSeekFooterSize
+	tableStart := size - tableSize - zstdSkippableHdrSize
+	if tableStart < 0 {
+		return nil, ErrNotFramed
+	}
+	table := make([]byte, tableSize+zstdSkippableHdrSize)
+	if _, err := r.ReadAt(table, tableStart); err != nil {
+		return nil, ErrNotFramed
+	}
+	if binary.LittleEndian.Uint32(table) != zstdSkippableMagic {
+		return nil, ErrNotFramed
+	}
+	var (
+		frames []frame
+		offset int64
+	)
+	for i := int64(0); i < numFrames; i++ {
+		entry := table[zstdSkippableHdrSize+i*entrySize:]
+		n := int64(binary.LittleEndian.Uint32(entry))
+		frames = append(frames, frame{
+			offset: offset,
+			size:   n,
+		})
+		offset += n
+	}
+	if offset != tableStart {
+		return nil, fmt.Errorf("%w: seek table does not cover the frames", ErrNotFramed)
+	}
+	return frames, nil
+}
+
+// frameReader reads the frames decompressed in parallel in order.
+type frameReader struct {
+	cancel  func()
+	futureC <-chan chan frameResult
+	buf     []byte
+	err     error
+}
+
+type frameResult struct {
+	data []byte
+	err  error
+}
+
+func newFrameReader(ctx context.Context, r io.ReaderAt, frames []frame, threads int,
+	decode func([]byte) ([]byte, error)) *frameReader {
+	iCtx, cancel := context.WithCancel(ctx)
+	var (
+		futureC = make(chan chan frameResult, threads) // bound the frames in flight
+		sem     = make(chan struct{}, threads)
+	)
+	go func() {
+		defer close(futureC)
+		for _, f := range frames {
+			future := make(chan frameResult, 1)
+			select {
+			case <-iCtx.Done():
+				return
+			case futureC <- future:
+			}
+			sem <- struct{}{}
+			go func(f frame) {
+				defer func() { <-sem }()
+				defer func() {
+					if err := panicError(recover()); err != nil {
+						future <- frameResult{err: err}
+					}
+				}()
+				b := make([]byte, f.size)
+				if _, err := r.ReadAt(b, f.offset); err != nil && !errors.Is(err, io.EOF) {
+					future <- frameResult{err: err}
+					return
+				}
+				data, err := decode(b)
+				future <- frameResult{
+					data: data,
+					err:  err,
+				}
+			}(f)
+		}
+	}()
+	return &frameReader{
+		cancel:  cancel,
+		futureC: futureC,
+	}
+}
+
+func (s *frameReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		future, ok := <-s.futureC
+		if !ok {
+			s.err = io.EOF
+			continue
+		}
+		r := <-future
+		if r.err != nil {
+			s.err = wrapErr(r.err, "FrameReader cannot decompress frame")
+			continue
+		}
+		s.buf = r.data
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *frameReader) Close() error {
+	s.cancel()
+	// Release the pending decompressions
+	for range s.futureC {
+	}
+	return nil
+}
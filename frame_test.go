@@ -0,0 +1,119 @@
+package gogrep_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func appendUint32(b []byte, v uint32) []byte {
+	x := make([]byte, 4)
+	binary.LittleEndian.PutUint32(x, v)
+	return append(b, x...)
+}
+
+// bgzfBlock compresses data into a BGZF block.
+func bgzfBlock(t *testing.T, data []byte) []byte {
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	assert.Nil(t, err)
+	_, _ = w.Write(data)
+	assert.Nil(t, w.Close())
+	var (
+		block = []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 6, 0, 'B', 'C', 2, 0, 0, 0}
+		size  = len(block) + deflated.Len() + 8
+	)
+	binary.LittleEndian.PutUint16(block[16:], uint16(size-1))
+	block = append(block, deflated.Bytes()...)
+	block = appendUint32(block, crc32.ChecksumIEEE(data))
+	block = appendUint32(block, uint32(len(data)))
+	return block
+}
+
+// zstdSeekable compresses each chunk into a frame and appends the seek table.
+func zstdSeekable(t *testing.T, chunks [][]byte) []byte {
+	enc, err := zstd.NewWriter(nil)
+	assert.Nil(t, err)
+	var (
+		out   []byte
+		table []byte
+	)
+	for _, c := range chunks {
+		f := enc.EncodeAll(c, nil)
+		out = append(out, f...)
+		table = appendUint32(table, uint32(len(f)))
+		table = appendUint32(table, uint32(len(c)))
+	}
+	table = appendUint32(table, uint32(len(chunks)))
+	table = append(table, 0)
+	table = appendUint32(table, 0x8F92EAB1)
+	out = appendUint32(out, 0x184D2A5E)
+	out = appendUint32(out, uint32(len(table)))
+	return append(out, table...)
+}
+
+func TestFrameReader(t *testing.T) {
+	var (
+		lines  = dupStrings(1000, "alpha", "beta", "gamma")
+		text   = strings.Join(lines, "\n")
+		chunks [][]byte
+	)
+	for i := 0; i < len(text); i += 997 {
+		end := i + 997
+		if end > len(text) {
+			end = len(text)
+		}
+		chunks = append(chunks, []byte(text[i:end]))
+	}
+	var bgzf []byte
+	for _, c := range chunks {
+		bgzf = append(bgzf, bgzfBlock(t, c)...)
+	}
+
+	for _, tc := range []*struct {
+		title  string
+		data   []byte
+		format gogrep.FrameFormat
+	}{
+		{
+			title:  "bgzf",
+			data:   bgzf,
+			format: gogrep.FrameBGZF,
+		},
+		{
+			title:  "zstd seekable",
+			data:   zstdSeekable(t, chunks),
+			format: gogrep.FrameZstdSeekable,
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			source := bytes.NewReader(tc.data)
+			format, err := gogrep.DetectFrames(source, int64(len(tc.data)))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.format, format)
+
+			r, err := gogrep.NewFrameReader(context.TODO(), source, int64(len(tc.data)), 4)
+			assert.Nil(t, err)
+			got, err := io.ReadAll(r)
+			assert.Nil(t, err)
+			assert.Nil(t, r.Close())
+			assert.Equal(t, text, string(got))
+		})
+	}
+
+	t.Run("not framed", func(t *testing.T) {
+		data := []byte("plain text")
+		_, err := gogrep.NewFrameReader(context.TODO(), bytes.NewReader(data), int64(len(data)), 4)
+		assert.ErrorIs(t, err, gogrep.ErrNotFramed)
+	})
+}
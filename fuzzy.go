@@ -0,0 +1,230 @@
+package gogrep
+
+import "unicode/utf8"
+
+// WithFuzzy matches the patterns as the literals within the edit distance of maxEdits instead of WithEngine, like agrep,
+// that is, a line matches if a substring of it is turned into the pattern by up to maxEdits insertions, deletions
+// and substitutions of the runes, e.g. OCR'd texts and the logs with typos.
+// Distance of the results returns the edit distance of the match.
+// Negative maxEdits disables it.
+func WithFuzzy(maxEdits int) Option {
+	return func(c *Config) {
+		c.fuzzy = maxEdits
+	}
+}
+
+// fuzzyWordSize is the max length of the patterns matched by the bit-parallel algorithm.
+const fuzzyWordSize = 64
+
+// fuzzyMatcher finds the substrings within the edit distance of the pattern.
+type fuzzyMatcher struct {
+	pattern  []rune
+	maxEdits int
+	// The bit vectors of the positions of the runes in the pattern, for the bit-parallel algorithm.
+	ascii [utf8.RuneSelf]uint64
+	peq   map[rune]uint64
+}
+
+func newFuzzyMatcher(pattern string, maxEdits int) *fuzzyMatcher {
+	s := &fuzzyMatcher{
+		pattern:  []rune(pattern),
+		maxEdits: maxEdits,
+		peq:      map[rune]uint64{},
+	}
+	if len(s.pattern) <= fuzzyWordSize {
+		for i, c := range s.pattern {
+			if c < utf8.RuneSelf {
+				s.ascii[c] |= 1 << i
+			} else {
+				s.peq[c] |= 1 << i
+			}
+		}
+	}
+	return s
+}
+
+func (s *fuzzyMatcher) eq(c rune) uint64 {
+	if c < utf8.RuneSelf {
+		return s.ascii[c]
+	}
+	return s.peq[c]
+}
+
+// scan calls f with the end of each rune of the line, and 0 at first,
+// and the least edit distance of the pattern to the substrings ending there until f returns false.
+func (s *fuzzyMatcher) scan(line string, f func(end, distance int) bool) {
+	m := len(s.pattern)
+	if !f(0, m) || m == 0 {
+		return
+	}
+	if m > fuzzyWordSize {
+		s.scanTable(line, f)
+		return
+	}
+	// Myers' bit-parallel algorithm
+	var (
+		pv    = ^uint64(0)
+		mv    uint64
+		score = m
+		high  = uint64(1) << (m - 1)
+	)
+	for i, c := range line {
+		eq := s.eq(c)
+		xv := eq | mv
+		xh := (((eq & pv) + pv) ^ pv) | eq
+		ph := mv | ^(xh | pv)
+		mh := pv & xh
+		if ph&high != 0 {
+			score++
+		} else if mh&high != 0 {
+			score--
+		}
+		ph <<= 1
+		mh <<= 1
+		pv = mh | ^(xv | ph)
+		mv = ph & xv
+		if !f(i+utf8.RuneLen(c), score) {
+			return
+		}
+	}
+}
+
+// scanTable is scan by the dynamic programming for the long patterns.
+func (s *fuzzyMatcher) scanTable(line string, f func(end, distance int) bool) {
+	m := len(s.pattern)
+	col := make([]int, m+1)
+	for i := range col {
+		col[i] = i
+	}
+	for i, c := range line {
+		diag := col[0] // the substrings may start anywhere
+		for j := 1; j <= m; j++ {
+			d := diag
+			if s.pattern[j-1] != c {
+				d++
+			}
+			d = min(d, col[j]+1, col[j-1]+1)
+			diag, col[j] = col[j], d
+		}
+		if !f(i+utf8.RuneLen(c), col[m]) {
+			return
+		}
+	}
+}
+
+func (s *fuzzyMatcher) MatchString(line string) bool {
+	var found bool
+	s.scan(line, func(_, distance int) bool {
+		found = distance <= s.maxEdits
+		return !found
+	})
+	return found
+}
+
+// distance returns the least edit distance of the pattern to the substrings of the line.
+func (s *fuzzyMatcher) distance(line string) int {
+	r := len(s.pattern)
+	s.scan(line, func(_, distance int) bool {
+		r = min(r, distance)
+		return r > 0
+	})
+	return r
+}
+
+// FindAllStringSubmatchIndex returns the successive non-overlapping matches.
+// A match ends where the edit distance is the least locally after it gets within maxEdits,
+// and starts where the distance to the end is the least and the match is the shortest.
+func (s *fuzzyMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	if len(s.pattern) == 0 {
+		return findAllLiterals(line, []string{""}, n)
+	}
+	var r [][]int
+	for pos := 0; pos <= len(line) && (n < 0 || len(r) < n); {
+		end, best := -1, 0
+		s.scan(line[pos:], func(e, distance int) bool {
+			if end >= 0 && distance >= best {
+				return false
+			}
+			if end >= 0 || distance <= s.maxEdits {
+				end, best = e, distance
+			}
+			return true
+		})
+		if end < 0 {
+			break
+		}
+		start := pos + s.start(line[pos:pos+end], best)
+		end += pos
+		r = append(r, []int{start, end})
+		if end == start {
+			_, width := utf8.DecodeRuneInString(line[end:])
+			end += max(width, 1)
+		}
+		pos = end
+	}
+	return r
+}
+
+// start returns the start of the shortest suffix of the text within the edit distance of the pattern.
+func (s *fuzzyMatcher) start(text string, distance int) int {
+	// The distances between the suffixes of the pattern and the text by the reversed runes
+	m := len(s.pattern)
+	col := make([]int, m+1)
+	for i := range col {
+		col[i] = i
+	}
+	if col[m] <= distance {
+		return len(text)
+	}
+	for end, k := len(text), 0; end > 0 && k < m+s.maxEdits; k++ {
+		c, size := utf8.DecodeLastRuneInString(text[:end])
+		end -= size
+		diag := col[0]
+		col[0]++
+		for j := 1; j <= m; j++ {
+			d := diag
+			if s.pattern[m-j] != c {
+				d++
+			}
+			d = min(d, col[j]+1, col[j-1]+1)
+			diag, col[j] = col[j], d
+		}
+		if col[m] <= distance {
+			return end
+		}
+	}
+	return 0
+}
+
+// matchDistance returns the least edit distance of the fuzzy patterns of the matcher to the line, 0 if not fuzzy.
+func matchDistance(m Matcher, line string) int {
+	switch m := m.(type) {
+	case *fuzzyMatcher:
+		return m.distance(line)
+	case *patternMatcher:
+		return matchDistance(m.matchers, line)
+	case *normalizedMatcher:
+		return matchDistance(m.matcher, m.normalizer.text(line))
+	case multiMatcher:
+		r := -1
+		for _, x := range m {
+			if isFuzzy(x) && x.MatchString(line) {
+				if d := matchDistance(x, line); r < 0 || d < r {
+					r = d
+				}
+			}
+		}
+		return max(r, 0)
+	default:
+		return 0
+	}
+}
+
+// isFuzzy returns true if the matcher is of WithFuzzy.
+func isFuzzy(m Matcher) bool {
+	if x, ok := m.(*normalizedMatcher); ok {
+		m = x.matcher
+	}
+	_, ok := m.(*fuzzyMatcher)
+	return ok
+}
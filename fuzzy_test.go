@@ -0,0 +1,162 @@
+package gogrep_test
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithFuzzy(t *testing.T) {
+	const source = "connection refused\nconection refused\nconnectoin refused\nno connect\ndisconnected\nnothing\n"
+
+	distances := func(t *testing.T, resultC <-chan gogrep.Result) map[string]int {
+		r := map[string]int{}
+		for x := range resultC {
+			assert.Nil(t, x.Err())
+			r[x.Text()] = x.Distance()
+		}
+		return r
+	}
+
+	t.Run("lines", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithFuzzy(1)).Grep(context.TODO(), "connection", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, map[string]int{
+			"connection refused": 0,
+			"conection refused":  1,
+		}, distances(t, resultC))
+	})
+
+	t.Run("transposition", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithFuzzy(2)).Grep(context.TODO(), "connection", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 2, distances(t, resultC)["connectoin refused"])
+	})
+
+	t.Run("only matching", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithFuzzy(1), gogrep.WithOnlyMatching()).
+			Grep(context.TODO(), "color", strings.NewReader("colour and color and colr\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var (
+			texts []string
+			ds    []int
+		)
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			texts = append(texts, r.Text())
+			ds = append(ds, r.Distance())
+		}
+		assert.Equal(t, []string{"colo", "color", "colr"}, texts)
+		assert.Equal(t, []int{1, 0, 1}, ds)
+	})
+
+	t.Run("multiple patterns", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithFuzzy(1)).
+			GrepMulti(context.TODO(), []string{"refused", "nothing"}, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		got := distances(t, resultC)
+		assert.Equal(t, 4, len(got))
+		assert.Equal(t, 0, got["nothing"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithFuzzy(-1)).Grep(context.TODO(), "con+ection", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, map[string]int{
+			"connection refused": 0,
+			"conection refused":  0,
+		}, distances(t, resultC))
+	})
+
+	t.Run("same as brute force", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		randomText := func(minLen, maxLen int) string {
+			b := make([]rune, minLen+rng.Intn(maxLen-minLen+1))
+			for i := range b {
+				b[i] = []rune("abcé")[rng.Intn(4)]
+			}
+			return string(b)
+		}
+		for _, tc := range []struct {
+			name           string
+			minLen, maxLen int
+		}{
+			{name: "short", minLen: 1, maxLen: 6},
+			{name: "long", minLen: 65, maxLen: 70},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				for i := 0; i < 50; i++ {
+					pattern := randomText(tc.minLen, tc.maxLen)
+					maxEdits := rng.Intn(3)
+					var lines []string
+					for j := 0; j < 10; j++ {
+						lines = append(lines, randomText(0, tc.maxLen+8))
+					}
+					want := map[string]int{}
+					for _, line := range lines {
+						if d := fuzzyDistance(pattern, line); d <= maxEdits {
+							want[line] = d
+						}
+					}
+					resultC, err := gogrep.New(gogrep.WithFuzzy(maxEdits)).
+						Grep(context.TODO(), pattern, strings.NewReader(strings.Join(lines, "\n")+"\n"))
+					if !assert.Nil(t, err) {
+						return
+					}
+					assert.Equal(t, want, distances(t, resultC), "pattern %q edits %d lines %q", pattern, maxEdits, lines)
+				}
+			})
+		}
+	})
+}
+
+// fuzzyDistance returns the least edit distance of the pattern to the substrings of the line by brute force,
+// the distances to the prefixes of the suffixes of the line.
+func fuzzyDistance(pattern, line string) int {
+	var (
+		p = []rune(pattern)
+		l = []rune(line)
+		r = len(p)
+	)
+	for i := range l {
+		for _, d := range levenshteinPrefixes(p, l[i:]) {
+			r = min(r, d)
+		}
+	}
+	return r
+}
+
+// levenshteinPrefixes returns the edit distances of a to the prefixes of b.
+func levenshteinPrefixes(a, b []rune) []int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			d := prev[j-1]
+			if a[i-1] != b[j-1] {
+				d++
+			}
+			cur[j] = min(d, prev[j]+1, cur[j-1]+1)
+		}
+		prev = cur
+	}
+	return prev
+}
@@ -0,0 +1,198 @@
+package gogrep
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// EngineGlob treats the pattern as a shell glob that matches the whole line, e.g. *error*[0-9].
+//
+// * matches any string, ? matches any character, [abc], [a-z] and [!abc] or [^abc] match a character
+// in or not in the set, and \ escapes the next character.
+// Unlike the file names, * and ? match / too.
+const EngineGlob Engine = "glob"
+
+func init() {
+	RegisterEngine(EngineGlob, func(pattern string) (Matcher, error) {
+		return compileGlob(pattern)
+	})
+}
+
+type globTokenKind int
+
+const (
+	globLiteral globTokenKind = iota
+	globAny                   // ?
+	globStar                  // *
+	globClass                 // [...]
+)
+
+type globToken struct {
+	kind    globTokenKind
+	literal string
+	ranges  [][2]rune // of globClass
+	negate  bool      // of globClass
+}
+
+// match returns the size of the prefix of s matched by the token.
+// Not for globStar.
+func (t *globToken) match(s string) (int, bool) {
+	if t.kind == globLiteral {
+		return len(t.literal), strings.HasPrefix(s, t.literal)
+	}
+	if s == "" {
+		return 0, false
+	}
+	c, size := utf8.DecodeRuneInString(s)
+	if t.kind == globAny {
+		return size, true
+	}
+	var in bool
+	for _, r := range t.ranges {
+		if r[0] <= c && c <= r[1] {
+			in = true
+			break
+		}
+	}
+	return size, in != t.negate
+}
+
+// globMatcher matches the lines by a glob.
+type globMatcher struct {
+	tokens []*globToken
+}
+
+var (
+	errGlobTrailingBackslash = errors.New("trailing backslash")
+	errGlobMissingBracket    = errors.New("missing closing ]")
+)
+
+func compileGlob(pattern string) (*globMatcher, error) {
+	var (
+		tokens  []*globToken
+		literal strings.Builder
+	)
+	flush := func() {
+		if literal.Len() > 0 {
+			tokens = append(tokens, &globToken{kind: globLiteral, literal: literal.String()})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(pattern); {
+		c, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		switch c {
+		case '\\':
+			if i == len(pattern) {
+				return nil, wrapErr(errGlobTrailingBackslash, "invalid glob %s", pattern)
+			}
+			c, size = utf8.DecodeRuneInString(pattern[i:])
+			i += size
+			literal.WriteRune(c)
+		case '*':
+			flush()
+			if len(tokens) == 0 || tokens[len(tokens)-1].kind != globStar {
+				tokens = append(tokens, &globToken{kind: globStar})
+			}
+		case '?':
+			flush()
+			tokens = append(tokens, &globToken{kind: globAny})
+		case '[':
+			flush()
+			t, n, err := parseGlobClass(pattern[i:])
+			if err != nil {
+				return nil, wrapErr(err, "invalid glob %s", pattern)
+			}
+			tokens = append(tokens, t)
+			i += n
+		default:
+			literal.WriteRune(c)
+		}
+	}
+	flush()
+	return &globMatcher{
+		tokens: tokens,
+	}, nil
+}
+
+// parseGlobClass parses the class after [ and returns the size of it including ].
+// ] is a member at first, e.g. []a].
+func parseGlobClass(s string) (*globToken, int, error) {
+	t := &globToken{kind: globClass}
+	i := 0
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		t.negate = true
+		i++
+	}
+	next := func() (rune, bool) {
+		if i >= len(s) {
+			return 0, false
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if c == '\\' && i < len(s) {
+			c, size = utf8.DecodeRuneInString(s[i:])
+			i += size
+		}
+		return c, true
+	}
+	for first := true; ; first = false {
+		if i < len(s) && s[i] == ']' && !first {
+			return t, i + 1, nil
+		}
+		lo, ok := next()
+		if !ok {
+			return nil, 0, errGlobMissingBracket
+		}
+		hi := lo
+		if i+1 < len(s) && s[i] == '-' && s[i+1] != ']' {
+			i++
+			if hi, ok = next(); !ok {
+				return nil, 0, errGlobMissingBracket
+			}
+		}
+		t.ranges = append(t.ranges, [2]rune{lo, hi})
+	}
+}
+
+// MatchString reports whether the glob matches the whole line.
+// A mismatch after a * retries the tokens after the last * from the next character.
+func (s *globMatcher) MatchString(line string) bool {
+	var (
+		p, i       int // the token and the position of the line
+		star, mark = -1, 0
+	)
+	for i < len(line) {
+		if p < len(s.tokens) && s.tokens[p].kind == globStar {
+			star, mark = p, i
+			p++
+			continue
+		}
+		if p < len(s.tokens) {
+			if n, ok := s.tokens[p].match(line[i:]); ok {
+				p++
+				i += n
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(line[mark:])
+		mark += size
+		p, i = star+1, mark
+	}
+	for p < len(s.tokens) && s.tokens[p].kind == globStar {
+		p++
+	}
+	return p == len(s.tokens)
+}
+
+// FindAllStringSubmatchIndex returns the whole line if the glob matches.
+func (s *globMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	if n == 0 || !s.MatchString(line) {
+		return nil
+	}
+	return [][]int{{0, len(line)}}
+}
@@ -0,0 +1,115 @@
+package gogrep_test
+
+import (
+	"context"
+	"math/rand"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineGlob(t *testing.T) {
+	for _, tc := range []*struct {
+		title   string
+		pattern string
+		match   []string
+		unmatch []string
+	}{
+		{
+			title:   "literal",
+			pattern: "vanity",
+			match:   []string{"vanity"},
+			unmatch: []string{"vanity fair", "a vanity", ""},
+		},
+		{
+			title:   "star",
+			pattern: "*error*[0-9]",
+			match:   []string{"error 1", "an error occurred: code 500", "error/2"},
+			unmatch: []string{"error", "error 1 again", "no failure 1"},
+		},
+		{
+			title:   "question",
+			pattern: "v?n?ty",
+			match:   []string{"vanity", "vënity"},
+			unmatch: []string{"vnty", "vaanity"},
+		},
+		{
+			title:   "class",
+			pattern: "[a-c]x[!0-9]",
+			match:   []string{"axz", "cx-"},
+			unmatch: []string{"dxz", "ax1"},
+		},
+		{
+			title:   "bracket in class",
+			pattern: "[]!]*",
+			match:   []string{"]", "!bang"},
+			unmatch: []string{"[", "x!"},
+		},
+		{
+			title:   "escape",
+			pattern: `\*[\]]\?`,
+			match:   []string{"*]?"},
+			unmatch: []string{"a]b"},
+		},
+		{
+			title:   "stars",
+			pattern: "**",
+			match:   []string{"", "anything"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			m, err := gogrep.EngineGlob.Compile(tc.pattern)
+			if !assert.Nil(t, err) {
+				return
+			}
+			for _, s := range tc.match {
+				assert.True(t, m.MatchString(s), s)
+				assert.Equal(t, [][]int{{0, len(s)}}, m.FindAllStringSubmatchIndex(s, -1), s)
+			}
+			for _, s := range tc.unmatch {
+				assert.False(t, m.MatchString(s), s)
+				assert.Nil(t, m.FindAllStringSubmatchIndex(s, -1), s)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, p := range []string{`abc\`, "[abc", "[", "[a-"} {
+			_, err := gogrep.EngineGlob.Compile(p)
+			assert.NotNil(t, err, p)
+		}
+	})
+
+	t.Run("grep", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithEngine(gogrep.EngineGlob)).
+			Grep(context.TODO(), "*error*[0-9]", strings.NewReader("error 1\nno error\nerror: exit 2\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"error 1", "error: exit 2"}, sortedTexts(t, resultC))
+	})
+
+	t.Run("same as path.Match", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		tokens := []string{"a", "b", "*", "?", "[ab]", "[^a]", "[^b-c]"}
+		for i := 0; i < 1000; i++ {
+			var p strings.Builder
+			for j := rng.Intn(6); j >= 0; j-- {
+				p.WriteString(tokens[rng.Intn(len(tokens))])
+			}
+			b := make([]byte, rng.Intn(8))
+			for j := range b {
+				b[j] = "abc"[rng.Intn(3)]
+			}
+			m, err := gogrep.EngineGlob.Compile(p.String())
+			if !assert.Nil(t, err) {
+				return
+			}
+			want, _ := path.Match(p.String(), string(b))
+			assert.Equal(t, want, m.MatchString(string(b)), "pattern %q text %q", p.String(), b)
+		}
+	})
+}
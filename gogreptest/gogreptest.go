@@ -0,0 +1,340 @@
+// Package gogreptest provides utilities for testing the applications that use gogrep.
+package gogreptest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+)
+
+// Result is a gogrep.Result with the fixed values.
+type Result struct {
+	Value        string
+	Error        error
+	LineNumber   int
+	ByteOffset   int64
+	Ranges       [][2]int
+	Groups       []string
+	SourceName   string
+	SourceTag    interface{}
+	Patterns     []string
+	EditDistance int
+}
+
+func (s *Result) Text() string          { return s.Value }
+func (s *Result) Err() error            { return s.Error }
+func (s *Result) Line() int             { return s.LineNumber }
+func (s *Result) Offset() int64         { return s.ByteOffset }
+func (s *Result) MatchRanges() [][2]int { return s.Ranges }
+func (s *Result) Submatches() []string  { return s.Groups }
+func (s *Result) Source() string        { return s.SourceName }
+func (s *Result) Tag() interface{}      { return s.SourceTag }
+func (s *Result) PatternID() []string   { return s.Patterns }
+func (s *Result) Distance() int         { return s.EditDistance }
+
+// Match returns a result of the text at the line.
+func Match(line int, text string) gogrep.Result {
+	return &Result{
+		Value:      text,
+		LineNumber: line,
+	}
+}
+
+// Err returns an error result.
+func Err(err error) gogrep.Result { return &Result{Error: err} }
+
+// Channel returns a closed channel that yields the results in order.
+func Channel(results ...gogrep.Result) <-chan gogrep.Result {
+	c := make(chan gogrep.Result, len(results))
+	for _, r := range results {
+		c <- r
+	}
+	close(c)
+	return c
+}
+
+// Call is a call of Grepper.
+type Call struct {
+	Regexes []string
+	Source  string // read from the source
+}
+
+var _ gogrep.Grepper = (*Grepper)(nil)
+
+// Grepper is a fake gogrep.Grepper that yields the scripted results in order.
+type Grepper struct {
+	// Results are yielded by each call.
+	Results []gogrep.Result
+	// Error is returned by each call if not nil.
+	Error error
+
+	mux   sync.Mutex
+	calls []Call
+}
+
+func (s *Grepper) Grep(ctx context.Context, regex string, source io.Reader) (<-chan gogrep.Result, error) {
+	return s.GrepMulti(ctx, []string{regex}, source)
+}
+
+func (s *Grepper) GrepMulti(ctx context.Context, regexes []string, source io.Reader) (<-chan gogrep.Result, error) {
+	b, err := io.ReadAll(source)
+	if err != nil {
+		return nil, err
+	}
+	s.mux.Lock()
+	s.calls = append(s.calls, Call{
+		Regexes: regexes,
+		Source:  string(b),
+	})
+	s.mux.Unlock()
+	if s.Error != nil {
+		return nil, s.Error
+	}
+	return Channel(s.Results...), nil
+}
+
+// GrepBytes records data as the source.
+func (s *Grepper) GrepBytes(ctx context.Context, regex string, data []byte) (<-chan gogrep.Result, error) {
+	return s.GrepMulti(ctx, []string{regex}, bytes.NewReader(data))
+}
+
+// GrepLines records the lines terminated by \n as the source.
+func (s *Grepper) GrepLines(ctx context.Context, regex string, lines []string) (<-chan gogrep.Result, error) {
+	var b strings.Builder
+	for _, x := range lines {
+		b.WriteString(x)
+		b.WriteByte('\n')
+	}
+	return s.GrepMulti(ctx, []string{regex}, strings.NewReader(b.String()))
+}
+
+// GrepFile reads the whole file.
+func (s *Grepper) GrepFile(ctx context.Context, regex string, path string) (<-chan gogrep.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return s.GrepMulti(ctx, []string{regex}, f)
+}
+
+// GrepReaderAt reads the whole source.
+func (s *Grepper) GrepReaderAt(ctx context.Context, regexes []string, source gogrep.SizedReaderAt) (<-chan gogrep.Result, error) {
+	return s.GrepMulti(ctx, regexes, io.NewSectionReader(source, 0, source.Size()))
+}
+
+// GrepSources reads the sources in order.
+// It yields the scripted results for each source with the name of the source.
+func (s *Grepper) GrepSources(ctx context.Context, regexes []string, sources []gogrep.NamedSource) (<-chan gogrep.Result, error) {
+	var results []gogrep.Result
+	for _, src := range sources {
+		c, err := s.GrepMulti(ctx, regexes, src.Reader)
+		if err != nil {
+			return nil, err
+		}
+		for r := range c {
+			results = append(results, &Result{
+				Value:        r.Text(),
+				Error:        r.Err(),
+				LineNumber:   r.Line(),
+				ByteOffset:   r.Offset(),
+				Ranges:       r.MatchRanges(),
+				Groups:       r.Submatches(),
+				SourceName:   src.Name,
+				SourceTag:    r.Tag(),
+				Patterns:     r.PatternID(),
+				EditDistance: r.Distance(),
+			})
+		}
+	}
+	return Channel(results...), nil
+}
+
+// GrepPatterns records the regexes of the patterns.
+// The scripted results are yielded as they are, with their pattern IDs.
+func (s *Grepper) GrepPatterns(ctx context.Context, patterns []gogrep.Pattern, source io.Reader) (<-chan gogrep.Result, error) {
+	regexes := make([]string, len(patterns))
+	for i, p := range patterns {
+		regexes[i] = p.Regex
+	}
+	return s.GrepMulti(ctx, regexes, source)
+}
+
+// GrepRegexp records the regex.
+func (s *Grepper) GrepRegexp(ctx context.Context, re *regexp.Regexp, source io.Reader) (<-chan gogrep.Result, error) {
+	return s.GrepMulti(ctx, []string{re.String()}, source)
+}
+
+// Compile returns a Session that records the regexes for each grep.
+// It fails with Error if not nil.
+func (s *Grepper) Compile(regexes ...string) (gogrep.Session, error) {
+	if s.Error != nil {
+		return nil, s.Error
+	}
+	return &session{
+		grepper: s,
+		regexes: regexes,
+	}, nil
+}
+
+// session is the gogrep.Session of Grepper.
+type session struct {
+	grepper *Grepper
+	regexes []string
+	mux     sync.Mutex
+	closed  bool
+}
+
+var _ gogrep.Session = (*session)(nil)
+
+func (s *session) Grep(ctx context.Context, source io.Reader) (<-chan gogrep.Result, error) {
+	s.mux.Lock()
+	closed := s.closed
+	s.mux.Unlock()
+	if closed {
+		return nil, gogrep.ErrSessionClosed
+	}
+	return s.grepper.GrepMulti(ctx, s.regexes, source)
+}
+
+func (s *session) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Calls returns the calls in order.
+func (s *Grepper) Calls() []Call {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// MatcherFunc is a gogrep.Matcher that matches the whole line if the function returns true.
+type MatcherFunc func(line string) bool
+
+func (f MatcherFunc) MatchString(line string) bool { return f(line) }
+func (f MatcherFunc) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	if n == 0 || !f(line) {
+		return nil
+	}
+	return [][]int{{0, len(line)}}
+}
+
+// Engine registers the matcher as an engine that ignores the pattern,
+// so that grep results depend only on the matcher.
+func Engine(name string, matcher gogrep.Matcher) gogrep.Engine {
+	engine := gogrep.Engine(name)
+	gogrep.RegisterEngine(engine, func(string) (gogrep.Matcher, error) { return matcher, nil })
+	return engine
+}
+
+// Sequential returns a Grepper that yields the results in order in which lines appear.
+func Sequential(opt ...gogrep.Option) gogrep.Grepper {
+	return gogrep.New(append(opt, gogrep.WithThreads(1))...)
+}
+
+// Collect receives all the results and returns them with the first error.
+func Collect(resultC <-chan gogrep.Result) ([]gogrep.Result, error) {
+	var (
+		results []gogrep.Result
+		err     error
+	)
+	for r := range resultC {
+		if e := r.Err(); e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, err
+}
+
+// AssertTexts fails if the texts of the results differ from want regardless of the order.
+func AssertTexts(t testing.TB, resultC <-chan gogrep.Result, want ...string) {
+	t.Helper()
+	results, err := Collect(resultC)
+	if err != nil {
+		t.Errorf("gogreptest got error %v", err)
+		return
+	}
+	got := []string{}
+	for _, r := range results {
+		got = append(got, r.Text())
+	}
+	want = append([]string{}, want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("gogreptest got texts %q want %q", got, want)
+	}
+}
+
+// Clock is a gogrep.Clock whose time advances only by Advance.
+type Clock struct {
+	mux     sync.Mutex
+	now     time.Time
+	waiters []*clockWaiter
+}
+
+type clockWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewClock returns a new Clock at the time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{
+		now: now,
+	}
+}
+
+func (s *Clock) Now() time.Time {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.now
+}
+
+func (s *Clock) After(d time.Duration) <-chan time.Time {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	w := &clockWaiter{
+		at: s.now.Add(d),
+		c:  make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		w.c <- s.now
+		return w.c
+	}
+	s.waiters = append(s.waiters, w)
+	return w.c
+}
+
+// Advance advances the time and fires the timers that expire.
+func (s *Clock) Advance(d time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.now = s.now.Add(d)
+	var waiters []*clockWaiter
+	for _, w := range s.waiters {
+		if w.at.After(s.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.c <- s.now
+	}
+	s.waiters = waiters
+}
@@ -0,0 +1,136 @@
+package gogreptest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/berquerant/gogrep/gogreptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrepper(t *testing.T) {
+	t.Run("results", func(t *testing.T) {
+		g := &gogreptest.Grepper{
+			Results: []gogrep.Result{
+				gogreptest.Match(1, "first"),
+				gogreptest.Match(3, "second"),
+			},
+		}
+		resultC, err := g.GrepMulti(context.TODO(), []string{"a", "b"}, strings.NewReader("source"))
+		assert.Nil(t, err)
+		gogreptest.AssertTexts(t, resultC, "second", "first")
+		assert.Equal(t, []gogreptest.Call{{
+			Regexes: []string{"a", "b"},
+			Source:  "source",
+		}}, g.Calls())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		wantErr := errors.New("grep")
+		g := &gogreptest.Grepper{
+			Error: wantErr,
+		}
+		_, err := g.Grep(context.TODO(), "a", strings.NewReader(""))
+		assert.ErrorIs(t, err, wantErr)
+		_, err = g.Compile("a")
+		assert.ErrorIs(t, err, wantErr)
+	})
+
+	t.Run("patterns and regexp", func(t *testing.T) {
+		var g gogrep.Grepper = &gogreptest.Grepper{
+			Results: []gogrep.Result{gogreptest.Match(1, "first")},
+		}
+		resultC, err := g.GrepPatterns(context.TODO(), []gogrep.Pattern{{ID: "x", Regex: "a"}, {ID: "y", Regex: "b"}}, strings.NewReader("p"))
+		assert.Nil(t, err)
+		gogreptest.AssertTexts(t, resultC, "first")
+		resultC, err = g.GrepRegexp(context.TODO(), regexp.MustCompile("c+"), strings.NewReader("r"))
+		assert.Nil(t, err)
+		gogreptest.AssertTexts(t, resultC, "first")
+		assert.Equal(t, []gogreptest.Call{
+			{Regexes: []string{"a", "b"}, Source: "p"},
+			{Regexes: []string{"c+"}, Source: "r"},
+		}, g.(*gogreptest.Grepper).Calls())
+	})
+
+	t.Run("compile", func(t *testing.T) {
+		g := &gogreptest.Grepper{
+			Results: []gogrep.Result{gogreptest.Match(1, "first")},
+		}
+		session, err := g.Compile("a", "b")
+		if !assert.Nil(t, err) {
+			return
+		}
+		resultC, err := session.Grep(context.TODO(), strings.NewReader("s"))
+		assert.Nil(t, err)
+		gogreptest.AssertTexts(t, resultC, "first")
+		assert.Nil(t, session.Close())
+		_, err = session.Grep(context.TODO(), strings.NewReader("t"))
+		assert.ErrorIs(t, err, gogrep.ErrSessionClosed)
+		assert.Equal(t, []gogreptest.Call{{Regexes: []string{"a", "b"}, Source: "s"}}, g.Calls())
+	})
+}
+
+func TestCollect(t *testing.T) {
+	wantErr := errors.New("result")
+	results, err := gogreptest.Collect(gogreptest.Channel(
+		gogreptest.Match(1, "first"),
+		gogreptest.Err(wantErr),
+		gogreptest.Match(2, "second"),
+	))
+	assert.ErrorIs(t, err, wantErr)
+	assert.Equal(t, 2, len(results))
+}
+
+func TestEngine(t *testing.T) {
+	engine := gogreptest.Engine("gogreptest-prefix", gogreptest.MatcherFunc(func(line string) bool {
+		return strings.HasPrefix(line, "x")
+	}))
+	resultC, err := gogreptest.Sequential(gogrep.WithEngine(engine)).Grep(context.TODO(), "ignored", strings.NewReader("xa\nb\nxc\n"))
+	assert.Nil(t, err)
+	results, err := gogreptest.Collect(resultC)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, "xa", results[0].Text())
+	assert.Equal(t, "xc", results[1].Text())
+	assert.Equal(t, [][2]int{{0, 2}}, results[1].MatchRanges())
+}
+
+func TestEngineParallel(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		i := i
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			engine := gogreptest.Engine(fmt.Sprintf("gogreptest-parallel-%d", i), gogreptest.MatcherFunc(func(line string) bool {
+				return line == fmt.Sprint(i)
+			}))
+			resultC, err := gogrep.New(gogrep.WithEngine(engine)).Grep(context.TODO(), "ignored", strings.NewReader("0\n1\n2\n3\n"))
+			assert.Nil(t, err)
+			results, err := gogreptest.Collect(resultC)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(results))
+			assert.Contains(t, gogrep.Engines(), engine)
+		})
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := gogreptest.NewClock(start)
+	var _ gogrep.Clock = c
+	timer := c.After(time.Minute)
+	c.Advance(30 * time.Second)
+	select {
+	case <-timer:
+		t.Fatal("fired too early")
+	default:
+	}
+	c.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(time.Minute), <-timer)
+	assert.Equal(t, start.Add(time.Minute), c.Now())
+}
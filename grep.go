@@ -3,10 +3,17 @@ package gogrep
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"regexp"
-	"sync"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/berquerant/gogrep/pipeline"
+	"golang.org/x/text/encoding"
 )
 
 type (
@@ -15,6 +22,48 @@ type (
 		// Grep greps source by regex.
 		// The results are not guaranteed to be in order in which lines appear.
 		Grep(ctx context.Context, regex string, source io.Reader) (<-chan Result, error)
+		// GrepMulti greps source by regexes.
+		// A line matches if any regex matches.
+		GrepMulti(ctx context.Context, regexes []string, source io.Reader) (<-chan Result, error)
+		// GrepSources greps the sources by regexes in parallel, reading ahead up to WithThreads sources.
+		// The results of a source are sent together in order of the sources,
+		// and Source of them returns the name of the source, and Err a SourceError with the name.
+		// The limits such as WithMaxResults apply to each source.
+		// With ErrorStop, the rest of the sources are not grepped after an error of a source.
+		// The reader of a source is closed after the grep of it, or on stop, if it implements io.Closer.
+		// The readers that implement SizedReaderAt are grepped as GrepReaderAt does.
+		// The other sources smaller than 64KiB are concatenated and grepped together to save the setup of the greps
+		// unless WithoutBatching or the options that keep states across the lines or count for each source,
+		// WithMultiline, WithScope, WithNotInside, WithEncoding, WithSplitFunc, WithMaxResults, WithMaxCount, WithStatsCollector,
+		// WithProgress, WithReaderMiddleware, WithPerSourceTimeout, WithCheckpoints and WithStartPosition.
+		GrepSources(ctx context.Context, regexes []string, sources []NamedSource) (<-chan Result, error)
+		// GrepReaderAt greps source by regexes, splitting it into the ranges at the boundaries of the lines
+		// that are scanned in parallel by WithThreads workers, or by WithSourceConcurrencyHint, e.g. a memory-mapped file.
+		// The results of a range are sent together in order of the ranges.
+		// The small sources, the compressed sources with WithDecompression, the binary sources without BinaryText,
+		// the options that keep states across the lines, WithMultiline, WithScope, WithNotInside, WithEncoding and WithSplitFunc,
+		// WithReaderMiddleware, WithCheckpoints and WithStartPosition make it grep the source sequentially as GrepMulti does.
+		GrepReaderAt(ctx context.Context, regexes []string, source SizedReaderAt) (<-chan Result, error)
+		// GrepFile greps the file by regex, splitting a regular file into the ranges at the boundaries of the lines
+		// that are scanned in parallel as GrepReaderAt does, e.g. to saturate the CPUs by a huge file.
+		// The other files, e.g. pipes and sparse files, are grepped sequentially as Grep does.
+		// The file is closed after the grep.
+		GrepFile(ctx context.Context, regex string, path string) (<-chan Result, error)
+		// GrepPatterns greps source by the patterns as GrepMulti does,
+		// and PatternID of the results returns the IDs of the patterns that match.
+		// The regexes of EngineRegexp are combined to select the lines at once.
+		GrepPatterns(ctx context.Context, patterns []Pattern, source io.Reader) (<-chan Result, error)
+		// GrepBytes greps data by regex as Grep does, splitting it without a bufio.Scanner and the copies of the lines.
+		// The texts of the results share the memory of data, so data must not be modified while the results are used.
+		GrepBytes(ctx context.Context, regex string, data []byte) (<-chan Result, error)
+		// GrepLines greps the lines by regex as Grep does the lines terminated by the delimiter,
+		// so Offset of a result is the sum of len(line)+1 of the lines before it.
+		GrepLines(ctx context.Context, regex string, lines []string) (<-chan Result, error)
+		// GrepRegexp greps source by the compiled regex regardless of WithEngine.
+		GrepRegexp(ctx context.Context, re *regexp.Regexp, source io.Reader) (<-chan Result, error)
+		// Compile compiles the regexes into a Session that greps the sources as GrepMulti does
+		// with the workers shared by the greps.
+		Compile(regexes ...string) (Session, error)
 	}
 	// Result is a result of Grep.
 	Result interface {
@@ -23,28 +72,123 @@ type (
 		Text() string
 		// Err returns an error that Grep got.
 		Err() error
+		// Line returns the 1-based line number of the matched line.
+		Line() int
+		// Offset returns the byte offset of the beginning of the matched line in the source.
+		Offset() int64
+		// MatchRanges returns the start and end byte indexes of the matches within the matched line.
+		// With WithOnlyMatching, it returns the range of the match that the Result represents.
+		MatchRanges() [][2]int
+		// Submatches returns the matched substring and the capture groups
+		// as regexp.FindStringSubmatch does.
+		// It is available with WithOnlyMatching and nil otherwise.
+		Submatches() []string
+		// Source returns the name of the source given to GrepSources.
+		// It is empty for Grep and GrepMulti.
+		Source() string
+		// Tag returns the value set by WithSourceTag.
+		Tag() interface{}
+		// PatternID returns the IDs of the patterns given to GrepPatterns that match the line in order of the patterns,
+		// or the IDs of the patterns that find the match with WithOnlyMatching.
+		// With WithMultiline, it returns the IDs of the patterns that match the text.
+		// It is nil for the other greps.
+		PatternID() []string
+		// Distance returns the edit distance of the match with WithFuzzy, the least one of the matches in the line
+		// unless WithOnlyMatching. It is 0 without WithFuzzy.
+		Distance() int
 	}
 	// Config provides Grepper configuration.
 	Config struct {
-		threads          int
-		resultBufferSize int
+		threads            int
+		resultBufferSize   int
+		requestBufferSize  int
+		engine             Engine
+		language           Language
+		scope              Scope
+		onlyMatching       bool
+		notInside          []Delimiters
+		maxResults         int
+		maxCount           int
+		maxLineLength      int
+		longLineMode       LongLineMode
+		splitFunc          bufio.SplitFunc
+		delimiter          int  // the terminator of the records, -1 for WithSplitFunc
+		dropCR             bool // bufio.ScanLines, false for WithSplitFunc
+		multiline          bool
+		clock              Clock
+		decompression      bool
+		binaryFiles        BinaryFiles
+		encoding           string
+		nulDelimited       bool
+		sourceTag          interface{}
+		errorPolicy        ErrorPolicy
+		statsCollector     func(Stats)
+		progress           func(ProgressEvent)
+		sharedBuffers      bool
+		noPrefilter        bool
+		readahead          int
+		noBatching         bool
+		follow             time.Duration // the interval of polling the sources, 0 unless WithFollow
+		sink               Sink
+		flushPolicy        *FlushPolicy // nil for the default
+		unique             UniqueBy     // empty unless WithUnique
+		readerStrategies   []ReaderStrategy
+		countMatches       bool
+		uniqueLimit        int
+		reportSkipped      bool
+		maxProgramSize     int
+		concurrencyHint    func(name string, size int64) int
+		sourceName         string // the name of the source of GrepSources for concurrencyHint and the logger
+		sequence           bool
+		drainTimeout       time.Duration
+		literalSet         []string
+		readerMiddleware   []stagedMiddleware
+		fuzzy              int // the max edits of WithFuzzy, negative unless WithFuzzy
+		maxPendingLines    int
+		blockingResults    bool
+		normalization      Normalization
+		caseFolding        bool
+		sort               SortBy // empty unless WithSort
+		compactResults     bool
+		autoTune           bool
+		stages             []Stage
+		logger             *slog.Logger // nil unless WithLogger
+		perSourceTimeout   time.Duration
+		regexCache         *regexCache // nil unless WithRegexCacheSize
+		checkpointInterval time.Duration
+		startOffset        int64
+		startLine          int
 	}
 )
 
 type grepper struct {
 	config *Config
+	pool   *pipeline.Pool // the workers of the Session if not nil
+	done   func()         // called at the end of the grep if not nil
+	drops  *dropCounter   // counts the chunks dropped by the cancel if not nil
+	reads  *pendingReads  // the reads of the source abandoned by WithPerSourceTimeout if not nil
 }
 
 const (
 	grepResultBufferSize = 1000
 	grepChunkSize        = 100
-	grepMaxGoroutines    = 4
 )
 
 func newConfig() *Config {
 	return &Config{
-		threads:          grepMaxGoroutines,
+		threads:          runtime.GOMAXPROCS(0),
 		resultBufferSize: grepResultBufferSize,
+		engine:           EngineRegexp,
+		maxLineLength:    bufio.MaxScanTokenSize,
+		longLineMode:     LongLineError,
+		splitFunc:        bufio.ScanLines,
+		delimiter:        '\n',
+		dropCR:           true,
+		clock:            SystemClock,
+		binaryFiles:      BinaryText,
+		errorPolicy:      ErrorStop,
+		readerStrategies: DefaultReaderStrategies,
+		fuzzy:            -1,
 	}
 }
 
@@ -54,91 +198,526 @@ func New(opt ...Option) Grepper {
 	for _, o := range opt {
 		o(c)
 	}
-	return &grepper{
+	g := &grepper{
 		config: c,
 	}
+	if c.sink != nil || c.unique != "" || c.sort != "" || c.compactResults || c.sequence {
+		return &outputGrepper{grepper: g}
+	}
+	return g
 }
 
 func (s *grepper) Grep(ctx context.Context, regex string, source io.Reader) (<-chan Result, error) {
 	// Already canceled
 	if isDone(ctx) {
-		return nil, wrapErr(ctx.Err(), "Grepper")
+		return nil, wrapErr(cancellationError(ctx), "Grepper")
 	}
 	// Check regex
-	r, err := regexp.Compile(regex)
+	r, err := s.compileMulti([]string{regex})
+	if err != nil {
+		return nil, err
+	}
+	return s.grepMatcher(ctx, r, source)
+}
+
+func (s *grepper) GrepMulti(ctx context.Context, regexes []string, source io.Reader) (<-chan Result, error) {
+	// Already canceled
+	if isDone(ctx) {
+		return nil, wrapErr(cancellationError(ctx), "Grepper")
+	}
+	if err := s.checkRegexes(regexes); err != nil {
+		return nil, err
+	}
+	r, err := s.compileMulti(regexes)
+	if err != nil {
+		return nil, err
+	}
+	return s.grepMatcher(ctx, r, source)
+}
+
+func (s *grepper) GrepRegexp(ctx context.Context, re *regexp.Regexp, source io.Reader) (<-chan Result, error) {
+	// Already canceled
+	if isDone(ctx) {
+		return nil, wrapErr(cancellationError(ctx), "Grepper")
+	}
+	if re == nil {
+		return nil, errors.New("Grepper got nil regexp")
+	}
+	var r Matcher = re
+	if !s.config.noPrefilter {
+		r = withPrefilter(r)
+	}
+	return s.grepMatcher(ctx, r, source)
+}
+
+// compile compiles the regex by the engine, or as the fuzzy literal with WithFuzzy,
+// into the matcher of the lines normalized by WithNormalization and WithCaseFolding.
+// The matcher is cached by WithRegexCacheSize.
+func (s *grepper) compile(regex string) (Matcher, error) {
+	cache := s.config.regexCache
+	if cache == nil {
+		return s.compileNormalized(regex)
+	}
+	key := s.config.regexCacheKey(regex)
+	if m, ok := cache.get(key); ok {
+		return m, nil
+	}
+	m, err := s.compileNormalized(regex)
+	if err != nil {
+		return nil, err
+	}
+	cache.add(key, m)
+	return m, nil
+}
+
+// compileNormalized compiles the regex into the matcher of the normalized lines.
+func (s *grepper) compileNormalized(regex string) (Matcher, error) {
+	n := s.config.normalizer()
+	if n == nil {
+		return s.compileEngine(regex)
+	}
+	normalized, err := n.pattern(regex, s.config.engine, s.config.fuzzy >= 0)
+	if err != nil {
+		return nil, badPattern(err, "Grepper", regex)
+	}
+	r, err := s.compileEngine(normalized)
+	if err != nil {
+		return nil, err
+	}
+	return n.matcher(r), nil
+}
+
+// compileEngine compiles the regex by the engine, or as the fuzzy literal with WithFuzzy.
+func (s *grepper) compileEngine(regex string) (Matcher, error) {
+	if s.config.fuzzy >= 0 {
+		return newFuzzyMatcher(regex, s.config.fuzzy), nil
+	}
+	r, err := s.config.engine.Compile(regex)
+	if err != nil {
+		return nil, badPattern(err, "Grepper", regex)
+	}
+	if s.config.noPrefilter {
+		return r, nil
+	}
+	return withPrefilter(r), nil
+}
+
+// errNoRegexes is returned by the greps without the patterns.
+var errNoRegexes = errors.New("Grepper got no regexes")
+
+// checkRegexes returns an error if there are no patterns to grep.
+func (s *grepper) checkRegexes(regexes []string) error {
+	if len(regexes) == 0 && len(s.config.literalSet) == 0 {
+		return errNoRegexes
+	}
+	return nil
+}
+
+// compileMulti returns the matcher that matches if any regex or any literal of WithLiteralSet matches.
+func (s *grepper) compileMulti(regexes []string) (Matcher, error) {
+	ms, err := s.compileRegexes(regexes)
 	if err != nil {
-		return nil, wrapErr(err, "Grepper cannot compile regex %s", regex)
+		return nil, err
+	}
+	if len(s.config.literalSet) > 0 {
+		ms = append(ms, s.config.normalizer().literalSet(s.config.literalSet))
+	}
+	if len(ms) == 1 {
+		return ms[0], nil
+	}
+	return ms, nil
+}
+
+// compileRegexes compiles each regex.
+func (s *grepper) compileRegexes(regexes []string) (multiMatcher, error) {
+	if err := s.config.checkProgramSize(regexes); err != nil {
+		return nil, err
+	}
+	ms := make(multiMatcher, len(regexes))
+	for i, regex := range regexes {
+		r, err := s.compile(regex)
+		if err != nil {
+			return nil, err
+		}
+		ms[i] = r
+	}
+	return ms, nil
+}
+
+// grepMatcher greps source by the compiled matcher.
+func (s *grepper) grepMatcher(ctx context.Context, r Matcher, source io.Reader) (<-chan Result, error) {
+	if err := s.config.validate(); err != nil {
+		return nil, err
+	}
+	var enc encoding.Encoding
+	if s.config.encoding != "" {
+		enc, _ = LookupEncoding(s.config.encoding)
+	}
+	var (
+		closer io.Closer // the reader by the strategy, the source is closed by the caller
+		size   = sourceSize(source)
+	)
+	if r, ok := s.config.strategyReader(source); ok {
+		closer, _ = r.(io.Closer)
+		source = r
+		size = -1
+	}
+	if s.config.startPositioned() {
+		source = &startReader{
+			Reader: source,
+			offset: s.config.startOffset,
+		}
+	}
+	stats := s.newStats(s.config.threads, size)
+	if m, ok := source.(*memorySource); ok && stats != nil {
+		m.n = &stats.bytesRead
+	} else if stats != nil {
+		source = &countingReader{r: source, n: &stats.bytesRead}
 	}
-	// Launch workers that do grep strings
 	var (
-		wg       sync.WaitGroup
-		requestC = make(chan []string, s.config.threads*2)
-		resultC  = make(chan Result, s.config.resultBufferSize)
+		resultC      = make(chan Result, s.config.resultBuffer())
+		iCtx, cancel = context.WithCancel(ctx)
+		limit        = &limits{
+			results: newLimiter(s.config.maxResults, cancel),
+			lines:   newLimiter(s.config.maxCount, cancel),
+			stats:   stats,
+		}
 	)
-	wg.Add(s.config.threads)
-	for i := 0; i < s.config.threads; i++ {
-		go func() {
-			defer wg.Done()
-			s.grep(requestC, resultC, r)
-		}()
-	}
-	// Client worker
-	go func() {
-		var (
-			iCtx, cancel = context.WithCancel(ctx)
-			sc           = bufio.NewScanner(source)
-			buf          []string
-		)
+	if s.reads != nil {
+		source = newDeadlineReader(iCtx, source, s.reads)
+	}
+	send := func(r Result) { resultC <- s.tagged(r) }
+	stages, _ := s.compileStages() // validated
+	logger := s.config.log()
+	supervise(resultC, func() {
 		defer cancel()
-		// Split input strings by chunk size
-		for sc.Scan() {
-			buf = append(buf, sc.Text())
-			if len(buf) < grepChunkSize {
-				continue
+		if s.done != nil {
+			defer s.done()
+		}
+		if closer != nil {
+			defer closer.Close()
+		}
+		chain := &readerChain{middleware: s.config.readerMiddleware}
+		defer chain.close()
+		source = chain.wrap(ReaderRaw, source)
+		if s.config.decompression {
+			r := NewDecodingReader(source)
+			defer r.Close()
+			source = r
+		}
+		source = chain.wrap(ReaderDecompressed, source)
+		if enc != nil {
+			source = newEncodingReader(source, enc)
+		}
+		source = chain.wrap(ReaderText, source)
+		p, src := s.newPipeline(r, stages, source, send, limit)
+		if stats != nil {
+			p.Observer = stats
+		}
+		if s.drops != nil {
+			p.Observer = &dropObserver{
+				Observer: p.Observer,
+				drops:    s.drops,
 			}
-			if isDone(iCtx) {
-				// Cancel client
-				break
+		}
+		if logger != nil {
+			p.Observer = &logObserver{
+				Observer: p.Observer,
+				logger:   logger,
 			}
-			requestC <- buf // Send data to workers
-			buf = nil       // Reset buffer
 		}
-		if isDone(iCtx) {
-			resultC <- newErrResult(wrapErr(iCtx.Err(), "Grepper"))
-		} else if len(buf) > 0 {
-			requestC <- buf
+		if s.config.checkpointInterval > 0 {
+			p.Observer = &checkpointObserver{
+				Observer: p.Observer,
+				clock:    s.config.clock,
+				interval: s.config.checkpointInterval,
+				send:     send,
+				last:     s.config.clock.Now(),
+			}
 		}
-		close(requestC) // Requests are exhausted
-		wg.Wait()       // Results from workers are exhausted
-		if err := sc.Err(); err != nil {
-			resultC <- newErrResult(wrapErr(err, "Grepper got error from source"))
+		debugLog(logger, "grep started", "workers", p.Workers, "size", size)
+		start := s.config.clock.Now()
+		err := p.Run(iCtx, src)
+		switch {
+		case limit.reached():
+			// Stopped early, not an error
+		case s.config.follow > 0 && isDone(ctx):
+			// Following until canceled, not an error
+		case isDone(iCtx):
+			debugLog(logger, "grep canceled", "cause", cancellationError(iCtx))
+			send(newErrResult(wrapErr(cancellationError(iCtx), "Grepper")))
+		case errors.As(err, new(*PanicError)):
+			debugLog(logger, "grep panicked", "err", err)
+			send(newErrResult(wrapErr(err, "Grepper recovered")))
+		case err != nil:
+			e := &ScanError{
+				Line: p.Splitter.(*scanSplitter).count + 1,
+				Err:  err,
+			}
+			debugLog(logger, "scan error", "err", e)
+			send(newErrResult(e))
 		}
-		close(resultC)
-	}()
+		debugLog(logger, "grep finished", "lines", p.Splitter.(*scanSplitter).count, "elapsed", s.config.clock.Now().Sub(start))
+		s.collectStats(iCtx, stats, limit)
+	}, cancel)
 	return resultC, nil
 }
 
-// grep selects the strings that match with the regexp.
-func (s *grepper) grep(requestC <-chan []string, resultC chan<- Result, r *regexp.Regexp) {
-	for lines := range requestC {
-		for _, line := range lines {
-			if r.MatchString(line) {
-				resultC <- newResult(line)
-			}
+// newPipeline returns the stages of a grep and the source to be read.
+// The stages of WithPipeline are applied to the lines before r.
+func (s *grepper) newPipeline(r Matcher, stages []lineStage, source io.Reader, send func(Result), limit *limits) (*pipeline.Pipeline, io.Reader) {
+	var filters []pipeline.Filter
+	for _, m := range s.newMaskers() {
+		filters = append(filters, &maskFilter{masker: m})
+	}
+	if s.config.binaryFiles != BinaryText {
+		d := &binaryDetector{r: source}
+		source = d
+		filters = append(filters, &binaryFilter{
+			detector: d,
+			mode:     s.config.binaryFiles,
+			matcher:  r,
+			send:     send,
+			report:   s.config.reportSkipped,
+		})
+	}
+	lines := &lineMatcher{
+		onlyMatching: s.config.onlyMatching,
+		countMatches: s.config.countMatches,
+		fuzzy:        s.config.fuzzy >= 0,
+		matcher:      r,
+		limit:        limit,
+	}
+	var matcher pipeline.Matcher = lines
+	if len(stages) > 0 {
+		matcher = &stageMatcher{
+			stages: stages,
+			next:   lines,
 		}
 	}
+	if s.config.multiline {
+		matcher = newMultilineMatcher(s, r, limit)
+	}
+	policy := s.config.flush()
+	return &pipeline.Pipeline{
+		Splitter: &scanSplitter{
+			split:         s.config.splitFunc,
+			delimiter:     s.config.delimiter,
+			dropCR:        s.config.dropCR,
+			maxLineLength: s.config.maxLineLength,
+			longLineMode:  s.config.longLineMode,
+			errorPolicy:   s.config.errorPolicy,
+			reportSkipped: s.config.reportSkipped,
+			send:          send,
+			stats:         limit.stats,
+			sharedBuffers: s.config.sharedBuffers,
+			startLine:     s.config.startLine,
+		},
+		Filters: filters,
+		Matcher: matcher,
+		Sink: pipeline.SinkFunc(func(item pipeline.Item) {
+			send(item.(Result))
+		}),
+		Workers:           s.config.threads,
+		ChunkSize:         policy.MaxLines,
+		MaxChunkBytes:     policy.MaxBytes,
+		MaxChunkDelay:     policy.MaxDelay,
+		Ordered:           s.config.multiline, // windows are matched in order
+		RequestBufferSize: s.config.requestBufferSize,
+		MaxPendingRecords: s.config.maxPendingLines,
+		AutoTune:          s.config.autoTune && s.config.flushPolicy == nil && s.config.follow == 0,
+		ReuseChunks:       !s.config.multiline, // windows retain the chunks
+		Pool:              s.pool,
+	}, source
+}
+
+// newMaskers returns the maskers that are applied to lines in order.
+func (s *grepper) newMaskers() []lineMasker {
+	var r []lineMasker
+	if s.config.scope != "" {
+		r = append(r, newScopeLexer(s.config.language, s.config.scope))
+	}
+	if len(s.config.notInside) > 0 {
+		r = append(r, newDelimiterMasker(s.config.notInside))
+	}
+	return r
+}
+
+// limits are the limiters of a grep.
+type limits struct {
+	results *limiter
+	lines   *limiter
+	stats   *statsCounter // nil without WithStatsCollector
+}
+
+// reached returns true if any limit is reached.
+func (s *limits) reached() bool { return s.results.reached() || s.lines.reached() }
+
+// cancellation returns the reason why the grep ended early, empty if the grep was not canceled.
+func (s *limits) cancellation(ctx context.Context) CancellationReason {
+	switch {
+	case s.results.reached():
+		return CancelMaxResults
+	case s.lines.reached():
+		return CancelMaxCount
+	default:
+		return CancellationReasonOf(cancellationError(ctx))
+	}
+}
+
+// takeLine returns true if a matched line can be emitted.
+func (s *limits) takeLine() bool {
+	if !s.lines.take() {
+		return false
+	}
+	s.stats.matched()
+	return true
+}
+
+// limiter counts the results up to the limit.
+type limiter struct {
+	max    int64 // not positive means unlimited
+	count  int64
+	cancel func() // called when the count reaches the limit
+}
+
+func newLimiter(max int, cancel func()) *limiter {
+	return &limiter{
+		max:    int64(max),
+		cancel: cancel,
+	}
+}
+
+// take returns true if a result can be emitted.
+func (s *limiter) take() bool {
+	if s.max <= 0 {
+		return true
+	}
+	n := atomic.AddInt64(&s.count, 1)
+	if n == s.max {
+		s.cancel()
+	}
+	return n <= s.max
+}
+
+// reached returns true if no more results can be emitted.
+func (s *limiter) reached() bool {
+	return s.max > 0 && atomic.LoadInt64(&s.count) >= s.max
 }
 
 type result struct {
-	text string
-	err  error
+	text       string
+	err        error
+	line       int
+	offset     int64
+	submatches []string
+	ranges     [][2]int
+	patterns   []string
+	distance   int
+	measured   bool // distance is computed
+	// matcher and view compute ranges, patterns and distance lazily.
+	matcher Matcher
+	view    string
+}
+
+func newResult(l pipeline.Record, matcher Matcher) Result {
+	return &result{
+		text:    l.Text,
+		line:    l.Number,
+		offset:  l.Offset,
+		matcher: matcher,
+		view:    l.View,
+	}
+}
+
+// newRangesResult returns the result of the line with the matches found already.
+func newRangesResult(l pipeline.Record, matcher Matcher, matches [][]int) Result {
+	ranges := make([][2]int, len(matches))
+	for i, x := range matches {
+		ranges[i] = [2]int{x[0], x[1]}
+	}
+	return &result{
+		text:    l.Text,
+		line:    l.Number,
+		offset:  l.Offset,
+		ranges:  ranges,
+		matcher: matcher,
+		view:    l.View,
+	}
 }
 
-func newResult(text string) Result  { return &result{text: text} }
 func newErrResult(err error) Result { return &result{err: err} }
 
-func (s *result) Text() string { return s.text }
-func (s *result) Err() error   { return s.err }
+// newSubmatchResult returns a result of the match in the line.
+// index is an element of regexp.FindAllStringSubmatchIndex.
+func newSubmatchResult(l pipeline.Record, index []int) *result {
+	submatches := make([]string, len(index)/2)
+	for i := range submatches {
+		if start, end := index[2*i], index[2*i+1]; start >= 0 {
+			submatches[i] = l.Text[start:end]
+		}
+	}
+	return &result{
+		text:       submatches[0],
+		line:       l.Number,
+		offset:     l.Offset,
+		submatches: submatches,
+		ranges:     [][2]int{{index[0], index[1]}},
+	}
+}
+
+func (s *result) Text() string         { return s.text }
+func (s *result) Err() error           { return s.err }
+func (s *result) Line() int            { return s.line }
+func (s *result) Offset() int64        { return s.offset }
+func (s *result) Submatches() []string { return s.submatches }
+func (*result) Source() string         { return "" }
+func (*result) Tag() interface{}       { return nil }
+
+// tagged returns the result with WithSourceTag if set.
+func (s *grepper) tagged(r Result) Result {
+	if s.config.sourceTag == nil {
+		return r
+	}
+	return &taggedResult{
+		Result: r,
+		tag:    s.config.sourceTag,
+	}
+}
+
+// taggedResult is a Result with WithSourceTag.
+type taggedResult struct {
+	Result
+	tag interface{}
+}
+
+func (s *taggedResult) Tag() interface{} { return s.tag }
+
+func (s *result) MatchRanges() [][2]int {
+	if s.ranges == nil && s.matcher != nil {
+		for _, x := range s.matcher.FindAllStringSubmatchIndex(s.view, -1) {
+			s.ranges = append(s.ranges, [2]int{x[0], x[1]})
+		}
+	}
+	return s.ranges
+}
+
+func (s *result) Distance() int {
+	if !s.measured && s.matcher != nil {
+		s.distance = matchDistance(s.matcher, s.view)
+		s.measured = true
+	}
+	return s.distance
+}
+
+func (s *result) PatternID() []string {
+	if s.patterns == nil {
+		if m, ok := s.matcher.(*patternMatcher); ok {
+			s.patterns = m.matchPatterns(s.view)
+		}
+	}
+	return s.patterns
+}
 
 /* Utilities */
 
@@ -152,6 +731,10 @@ func isDone(ctx context.Context) bool {
 	}
 }
 
+// PanicError is a panic of the matcher, the sink or the other stages of a grep,
+// sent as the error of a Result instead of crashing the process.
+type PanicError = pipeline.PanicError
+
 // wrapErr wraps an error.
 func wrapErr(err error, format string, v ...interface{}) error {
 	return fmt.Errorf("%s %w", fmt.Sprintf(format, v...), err)
@@ -165,6 +748,7 @@ type (
 )
 
 // WithThreads sets the number of grep workers.
+// Default is runtime.GOMAXPROCS(0).
 // Not positive number is ignored.
 func WithThreads(threads int) Option {
 	return func(c *Config) {
@@ -174,6 +758,16 @@ func WithThreads(threads int) Option {
 	}
 }
 
+// WithAutoTune adjusts the lines dispatched to the workers at once by the lengths of the lines,
+// and the workers that match them up to WithThreads by the time to match and the rate of the matched lines,
+// e.g. fewer workers for a slow source or the lines mostly matched, and more lines at once for the short lines.
+// It is ignored with WithFlushPolicy and WithFollow, which decide the lines dispatched at once.
+func WithAutoTune() Option {
+	return func(c *Config) {
+		c.autoTune = true
+	}
+}
+
 // WithResultBufferSize sets the buffer size of the result channel.
 // Not positive number is ignored.
 func WithResultBufferSize(resultBufferSize int) Option {
@@ -183,3 +777,272 @@ func WithResultBufferSize(resultBufferSize int) Option {
 		}
 	}
 }
+
+// WithRequestBufferSize sets the number of the chunks of lines buffered for the grep workers.
+// Default is twice WithThreads.
+// Not positive number is ignored.
+func WithRequestBufferSize(requestBufferSize int) Option {
+	return func(c *Config) {
+		if requestBufferSize > 0 {
+			c.requestBufferSize = requestBufferSize
+		}
+	}
+}
+
+// WithEngine sets the matcher implementation.
+// Unknown engine makes Grep fail.
+func WithEngine(engine Engine) Option {
+	return func(c *Config) {
+		c.engine = engine
+	}
+}
+
+// WithScope limits matching to the scope of the source code written in the language.
+// Unknown language or scope makes Grep fail.
+func WithScope(lang Language, scope Scope) Option {
+	return func(c *Config) {
+		c.language = lang
+		c.scope = scope
+	}
+}
+
+// WithOnlyMatching makes each match in a line a Result
+// whose Text is the matched substring instead of the whole line.
+// The empty matches are not Results as grep -o does not print them.
+func WithOnlyMatching() Option {
+	return func(c *Config) {
+		c.onlyMatching = true
+	}
+}
+
+// WithNotInside suppresses the matches inside the delimiters.
+func WithNotInside(delimiters ...Delimiters) Option {
+	return func(c *Config) {
+		c.notInside = append(c.notInside, delimiters...)
+	}
+}
+
+// WithMaxResults stops grep after emitting the number of results.
+// The source is not read any more and the result channel is closed without errors.
+// Not positive number means unlimited.
+func WithMaxResults(maxResults int) Option {
+	return func(c *Config) {
+		c.maxResults = maxResults
+	}
+}
+
+// WithMaxCount stops grep after the number of matching lines, like grep -m.
+// The source is not read any more and the result channel is closed without errors.
+// With multiple threads, the selected lines are not guaranteed to be the first ones in the source.
+// Not positive number means unlimited.
+func WithMaxCount(maxCount int) Option {
+	return func(c *Config) {
+		c.maxCount = maxCount
+	}
+}
+
+// WithMaxLineLength sets the max length of a line in bytes, including the line terminator.
+// The longer lines are handled by WithLongLineMode.
+// Default is bufio.MaxScanTokenSize.
+// Not positive number is ignored.
+func WithMaxLineLength(maxLineLength int) Option {
+	return func(c *Config) {
+		if maxLineLength > 0 {
+			c.maxLineLength = maxLineLength
+		}
+	}
+}
+
+// WithMultiline allows the matches to span lines.
+// The lines are joined by "\n" and each Result is the block of the lines that contain the matches,
+// with Line and Offset of the first line.
+// A match is found if it ends within about 100 lines after the line it starts.
+// The lines are matched by a single worker regardless of WithThreads.
+func WithMultiline() Option {
+	return func(c *Config) {
+		c.multiline = true
+	}
+}
+
+// WithDecompression decompresses the source of gzip, bzip2 or zstd detected by the magic bytes.
+// Result.Offset is the offset in the decompressed data.
+// See NewDecodingReader.
+func WithDecompression() Option {
+	return func(c *Config) {
+		c.decompression = true
+	}
+}
+
+// WithEncoding transcodes the source in the encoding to UTF-8 before matching.
+// The name is resolved by LookupEncoding and the BOM of UTF-8 and UTF-16 overrides the encoding.
+// Result.Offset is the offset in the transcoded data.
+// Unknown encoding makes Grep fail.
+func WithEncoding(name string) Option {
+	return func(c *Config) {
+		c.encoding = name
+	}
+}
+
+// WithBinaryFiles sets the policy for the binary sources that contain a NUL byte in the first block.
+// Default is BinaryText.
+// Unknown policy makes Grep fail.
+func WithBinaryFiles(binaryFiles BinaryFiles) Option {
+	return func(c *Config) {
+		c.binaryFiles = binaryFiles
+	}
+}
+
+// WithSourceTag sets the opaque value to every Result, available by Result.Tag,
+// e.g. the id of the request that the source belongs to.
+// It is useful for NamedSource.Options of GrepSources.
+func WithSourceTag(tag interface{}) Option {
+	return func(c *Config) {
+		c.sourceTag = tag
+	}
+}
+
+// WithReadahead makes GrepSources open and read the heads of the next sources in the background
+// up to the number of the sources ahead of the sources being grepped,
+// to hide the latency of opening the sources on network filesystems.
+// The sources that implement SizedReaderAt are not read ahead, nor the sources with WithPerSourceTimeout.
+// Not positive number means the default: WithThreads if the small sources are batched, see GrepSources, or none otherwise.
+func WithReadahead(sources int) Option {
+	return func(c *Config) {
+		c.readahead = sources
+	}
+}
+
+// WithErrorPolicy sets the policy for the errors.
+// Default is ErrorStop.
+// Unknown policy makes Grep fail.
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(c *Config) {
+		c.errorPolicy = policy
+	}
+}
+
+// WithStatsCollector calls the collector with the Stats of the grep of a source
+// after all the results are sent and before the channel of the results is closed.
+// It is called for each source of GrepSources, possibly concurrently.
+// Nil is ignored.
+func WithStatsCollector(collector func(Stats)) Option {
+	return func(c *Config) {
+		if collector != nil {
+			c.statsCollector = collector
+		}
+	}
+}
+
+// WithSharedLineBuffers copies the lines into the buffers shared by the lines instead of a string per line
+// to cut the allocations on large sources.
+// The Text and the Submatches of a Result keep the buffer of up to 64KiB around the line alive as long as they are referred,
+// so use CloneResult to keep a Result long.
+func WithSharedLineBuffers() Option {
+	return func(c *Config) {
+		c.sharedBuffers = true
+	}
+}
+
+// WithoutPrefilter disables the prefilter that skips the regex on the lines without the literal the regex requires.
+// See RequiredLiteral.
+func WithoutPrefilter() Option {
+	return func(c *Config) {
+		c.noPrefilter = true
+	}
+}
+
+// WithoutBatching disables concatenating the small sources of GrepSources to grep them together.
+func WithoutBatching() Option {
+	return func(c *Config) {
+		c.noBatching = true
+	}
+}
+
+// WithFollow makes the grep wait for the lines appended to the source like tail -f,
+// matching the lines one by one as soon as they are read.
+// The grep of a source that never ends, e.g. by FollowFile, keeps the channel of the results open until the context is canceled,
+// and the cancellation is not sent as an error.
+// The interval is the interval of polling the file by FollowFile. Not positive number means the default, 250ms.
+// It conflicts with WithMultiline and WithSort.
+func WithFollow(interval time.Duration) Option {
+	return func(c *Config) {
+		if interval <= 0 {
+			interval = defaultFollowInterval
+		}
+		c.follow = interval
+	}
+}
+
+// WithCountMatches makes the workers find all the matches of the matched lines,
+// so that MatchRanges and MatchCount of the results are ready without matching the lines again.
+func WithCountMatches() Option {
+	return func(c *Config) {
+		c.countMatches = true
+	}
+}
+
+// MatchCount returns the number of the matches in the line of the result, 1 with WithOnlyMatching.
+// The matches are found by the workers with WithCountMatches.
+func MatchCount(r Result) int {
+	return len(r.MatchRanges())
+}
+
+// WithSink writes the results without errors to the sink instead of sending them to the channel,
+// and flushes the sink by WithFlushPolicy and after the last result of each grep.
+// The channel receives only the errors of the grep and the sink, and is closed after the flush.
+// The grep is canceled if the sink fails to write.
+// It is ignored in the options of NamedSource.
+// Nil is ignored.
+func WithSink(sink Sink) Option {
+	return func(c *Config) {
+		if sink != nil {
+			c.sink = sink
+		}
+	}
+}
+
+// WithClock sets the clock of the time-dependent features.
+// Default is SystemClock.
+// Nil is ignored.
+func WithClock(clock Clock) Option {
+	return func(c *Config) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// WithSplitFunc splits the source into records by the function instead of lines.
+// Result.Line is the 1-based record number and Result.Offset is the offset of the token.
+// WithLongLineMode applies to the records longer than WithMaxLineLength.
+// Nil is ignored.
+func WithSplitFunc(split bufio.SplitFunc) Option {
+	return func(c *Config) {
+		if split != nil {
+			c.splitFunc = split
+			c.delimiter = -1
+			c.dropCR = false
+			c.nulDelimited = false
+		}
+	}
+}
+
+// WithDelimiter splits the source into records terminated by the delimiter instead of lines,
+// e.g. 0 for NUL-separated records.
+func WithDelimiter(delimiter byte) Option {
+	return func(c *Config) {
+		WithSplitFunc(scanDelimiter(delimiter))(c)
+		c.delimiter = int(delimiter)
+		c.nulDelimited = delimiter == 0
+	}
+}
+
+// WithLongLineMode sets the policy for the lines longer than WithMaxLineLength.
+// Default is LongLineError.
+// LongLineTruncate may split a multibyte character at the end.
+// Unknown mode makes Grep fail.
+func WithLongLineMode(mode LongLineMode) Option {
+	return func(c *Config) {
+		c.longLineMode = mode
+	}
+}
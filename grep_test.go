@@ -1,6 +1,7 @@
 package gogrep_test
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/berquerant/gogrep"
+	"github.com/berquerant/gogrep/gogreptest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,6 +38,17 @@ type errReader struct {
 
 func (s *errReader) Read(_ []byte) (int, error) { return 0, s.err }
 
+type countReader struct {
+	n      int
+	reader io.Reader
+}
+
+func (s *countReader) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	s.n += n
+	return n, err
+}
+
 type delayReader struct {
 	delay  time.Duration
 	reader io.Reader
@@ -59,6 +72,349 @@ func TestGrepper(t *testing.T) {
 		assert.Contains(t, err.Error(), "Grepper cannot compile regex")
 	})
 
+	t.Run("unknown engine", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithEngine("unknown")).Grep(context.TODO(), "ra", nil)
+		assert.Contains(t, err.Error(), "unknown engine unknown")
+	})
+
+	t.Run("fixed engine", func(t *testing.T) {
+		source := strings.NewReader(strings.Join([]string{"a.c", "abc"}, "\n"))
+		resultC, err := gogrep.New(gogrep.WithEngine(gogrep.EngineFixed)).Grep(context.TODO(), "a.c", source)
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "a.c", results[0].Text())
+	})
+
+	t.Run("only matching", func(t *testing.T) {
+		source := strings.NewReader(strings.Join([]string{"k1=v1 k2=v2", "none", "k3="}, "\n"))
+		resultC, err := gogrep.New(gogrep.WithOnlyMatching(), gogrep.WithThreads(1)).
+			Grep(context.TODO(), `(k\d)=(v\d)?`, source)
+		assert.Nil(t, err)
+		got := [][]string{}
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			assert.Equal(t, r.Submatches()[0], r.Text())
+			got = append(got, r.Submatches())
+		}
+		assert.ElementsMatch(t, [][]string{
+			{"k1=v1", "k1", "v1"},
+			{"k2=v2", "k2", "v2"},
+			{"k3=", "k3", ""},
+		}, got)
+	})
+
+	t.Run("only matching empty", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithOnlyMatching(), gogrep.WithThreads(1)).
+			Grep(context.TODO(), `x*`, strings.NewReader("abc\nxxd\n"))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		if assert.Equal(t, 1, len(results)) {
+			assert.Equal(t, "xx", results[0].Text())
+			assert.Equal(t, 2, results[0].Line())
+		}
+	})
+
+	t.Run("source tag", func(t *testing.T) {
+		type requestID int
+		resultC, err := gogrep.New(gogrep.WithSourceTag(requestID(7))).
+			Grep(context.TODO(), "a", strings.NewReader("a\nb\na\n"))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 2, len(results))
+		for _, r := range results {
+			assert.Equal(t, requestID(7), r.Tag())
+			assert.Equal(t, requestID(7), gogrep.Compact(r).Tag())
+		}
+
+		resultC, err = gogrep.New().Grep(context.TODO(), "a", strings.NewReader("a\n"))
+		assert.Nil(t, err)
+		for r := range resultC {
+			assert.Nil(t, r.Tag())
+		}
+	})
+
+	t.Run("only matching fixed", func(t *testing.T) {
+		source := strings.NewReader("abcabc")
+		resultC, err := gogrep.New(gogrep.WithOnlyMatching(), gogrep.WithEngine(gogrep.EngineFixed)).
+			Grep(context.TODO(), "bc", source)
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 2, len(results))
+	})
+
+	t.Run("multi", func(t *testing.T) {
+		source := strings.NewReader(strings.Join([]string{"afford", "vanity", "deny"}, "\n"))
+		resultC, err := gogrep.New().GrepMulti(context.TODO(), []string{"aff", "^d"}, source)
+		assert.Nil(t, err)
+		got := []string{}
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, r.Text())
+		}
+		assert.ElementsMatch(t, []string{"afford", "deny"}, got)
+	})
+
+	t.Run("multi only matching", func(t *testing.T) {
+		source := strings.NewReader("abcdef")
+		resultC, err := gogrep.New(gogrep.WithOnlyMatching()).GrepMulti(context.TODO(), []string{"cd", "b", "bcd", "e"}, source)
+		assert.Nil(t, err)
+		got := []string{}
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, r.Text())
+		}
+		assert.ElementsMatch(t, []string{"bcd", "e"}, got)
+	})
+
+	t.Run("multi no regexes", func(t *testing.T) {
+		_, err := gogrep.New().GrepMulti(context.TODO(), nil, nil)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("line and offset", func(t *testing.T) {
+		source := strings.NewReader("vanity\r\nempty\nvanitas\n\nvanity")
+		resultC, err := gogrep.New().Grep(context.TODO(), "vanit", source)
+		assert.Nil(t, err)
+		got := [][2]int64{}
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, [2]int64{int64(r.Line()), r.Offset()})
+		}
+		assert.ElementsMatch(t, [][2]int64{{1, 0}, {3, 14}, {5, 23}}, got)
+	})
+
+	t.Run("match ranges", func(t *testing.T) {
+		resultC, err := gogrep.New().Grep(context.TODO(), "an", strings.NewReader("banana"))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, [][2]int{{1, 3}, {3, 5}}, results[0].MatchRanges())
+	})
+
+	t.Run("max results", func(t *testing.T) {
+		var (
+			input  = dupStrings(100000, "vanity")
+			source = &countReader{reader: strings.NewReader(strings.Join(input, "\n"))}
+		)
+		resultC, err := gogrep.New(gogrep.WithMaxResults(3)).Grep(context.TODO(), "vanity", source)
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 3, len(results))
+		for _, r := range results {
+			assert.Nil(t, r.Err())
+		}
+		assert.Less(t, source.n, len(input)*len("vanity\n"), "should stop reading")
+	})
+
+	t.Run("request buffer size", func(t *testing.T) {
+		for _, size := range []int{1, 64} {
+			source := strings.NewReader(strings.Join(dupStrings(10000, "a", "b"), "\n"))
+			resultC, err := gogrep.New(gogrep.WithThreads(16), gogrep.WithRequestBufferSize(size)).Grep(context.TODO(), "a", source)
+			assert.Nil(t, err)
+			assert.Equal(t, 10000, len(toResultSlice(resultC)), "size %d", size)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		var stats []gogrep.Stats
+		source := strings.Join(dupStrings(1000, "a a", "b"), "\n")
+		resultC, err := gogrep.New(
+			gogrep.WithThreads(2),
+			gogrep.WithOnlyMatching(),
+			gogrep.WithStatsCollector(func(s gogrep.Stats) { stats = append(stats, s) }),
+		).Grep(context.TODO(), "a", strings.NewReader(source))
+		assert.Nil(t, err)
+		assert.Equal(t, 2000, len(toResultSlice(resultC)))
+		if !assert.Equal(t, 1, len(stats), "collected before the results are closed") {
+			return
+		}
+		s := stats[0]
+		assert.Equal(t, int64(2000), s.LinesScanned)
+		assert.Equal(t, int64(len(source)), s.BytesRead)
+		assert.Equal(t, int64(1000), s.LinesMatched)
+		assert.Equal(t, 2, len(s.Workers))
+		var chunks int64
+		for _, w := range s.Workers {
+			chunks += w.Chunks
+		}
+		assert.Equal(t, int64(20), chunks)
+		assert.Equal(t, 2, len(s.Utilization()))
+
+		var total gogrep.Stats
+		total.Add(s)
+		total.Add(s)
+		assert.Equal(t, int64(4000), total.LinesScanned)
+		assert.Equal(t, 2*s.Duration, total.Duration)
+	})
+
+	t.Run("shared line buffers", func(t *testing.T) {
+		source := strings.Join(dupStrings(5000, "a a", "b", "ca"), "\n")
+		grep := func(opt ...gogrep.Option) []string {
+			resultC, err := gogrep.New(append(opt, gogrep.WithThreads(1), gogrep.WithOnlyMatching())...).
+				Grep(context.TODO(), "(c?)a", strings.NewReader(source))
+			assert.Nil(t, err)
+			var r []string
+			for x := range resultC {
+				c := gogrep.CloneResult(x)
+				assert.Equal(t, x.Text(), c.Text())
+				assert.Equal(t, x.Submatches(), c.Submatches())
+				r = append(r, fmt.Sprintf("%d:%d:%s:%v", c.Line(), c.Offset(), c.Text(), c.MatchRanges()))
+			}
+			return r
+		}
+		want := grep()
+		assert.Equal(t, 15000, len(want))
+		assert.Equal(t, want, grep(gogrep.WithSharedLineBuffers()))
+	})
+
+	t.Run("max count", func(t *testing.T) {
+		source := strings.NewReader(strings.Join(dupStrings(1000, "a a", "b"), "\n"))
+		resultC, err := gogrep.New(gogrep.WithMaxCount(3), gogrep.WithOnlyMatching()).Grep(context.TODO(), "a", source)
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 6, len(results), "3 lines, 2 matches per line")
+		for _, r := range results {
+			assert.Nil(t, r.Err())
+		}
+	})
+
+	t.Run("long line", func(t *testing.T) {
+		source := "short a\n" + strings.Repeat("a", 100) + "\nlast a\n"
+		grep := func(opt ...gogrep.Option) []gogrep.Result {
+			resultC, err := gogrep.New(append(opt, gogrep.WithThreads(1), gogrep.WithMaxLineLength(16))...).
+				Grep(context.TODO(), "a", strings.NewReader(source))
+			assert.Nil(t, err)
+			return toResultSlice(resultC)
+		}
+
+		t.Run("error", func(t *testing.T) {
+			results := grep()
+			assert.NotNil(t, results[len(results)-1].Err())
+			assert.ErrorIs(t, results[len(results)-1].Err(), bufio.ErrTooLong)
+		})
+		t.Run("skip", func(t *testing.T) {
+			results := grep(gogrep.WithLongLineMode(gogrep.LongLineSkip))
+			assert.Equal(t, 2, len(results))
+			assert.Equal(t, "short a", results[0].Text())
+			assert.Equal(t, "last a", results[1].Text())
+			assert.Equal(t, 3, results[1].Line())
+			assert.Equal(t, int64(109), results[1].Offset())
+		})
+		t.Run("truncate", func(t *testing.T) {
+			results := grep(gogrep.WithLongLineMode(gogrep.LongLineTruncate))
+			assert.Equal(t, 3, len(results))
+			assert.Equal(t, strings.Repeat("a", 16), results[1].Text())
+			assert.Equal(t, 2, results[1].Line())
+			assert.Equal(t, int64(8), results[1].Offset())
+			assert.Equal(t, "last a", results[2].Text())
+			assert.Equal(t, int64(109), results[2].Offset())
+		})
+		t.Run("continue", func(t *testing.T) {
+			results := grep(gogrep.WithErrorPolicy(gogrep.ErrorContinue))
+			sort.Slice(results, func(i, j int) bool { return results[i].Line() < results[j].Line() })
+			if assert.Equal(t, 3, len(results)) {
+				assert.Equal(t, "short a", results[0].Text())
+				assert.ErrorIs(t, results[1].Err(), bufio.ErrTooLong)
+				assert.Equal(t, 2, results[1].Line())
+				assert.Equal(t, int64(8), results[1].Offset())
+				assert.Equal(t, "last a", results[2].Text())
+			}
+		})
+		t.Run("unknown", func(t *testing.T) {
+			_, err := gogrep.New(gogrep.WithLongLineMode("unknown")).Grep(context.TODO(), "a", strings.NewReader(source))
+			assert.NotNil(t, err)
+			_, err = gogrep.New(gogrep.WithErrorPolicy("unknown")).Grep(context.TODO(), "a", strings.NewReader(source))
+			assert.NotNil(t, err)
+		})
+	})
+	t.Run("delimiter", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithThreads(1), gogrep.WithDelimiter(0)).
+			Grep(context.TODO(), "a", strings.NewReader("a\nb\x00c\x00\x00ba\x00"))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "a\nb", results[0].Text())
+		assert.Equal(t, 1, results[0].Line())
+		assert.Equal(t, "ba", results[1].Text())
+		assert.Equal(t, 4, results[1].Line())
+		assert.Equal(t, int64(7), results[1].Offset())
+	})
+
+	t.Run("split func", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithThreads(1), gogrep.WithSplitFunc(bufio.ScanWords)).
+			Grep(context.TODO(), "a", strings.NewReader("a  b\n  ca"))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "ca", results[1].Text())
+		assert.Equal(t, 3, results[1].Line())
+		assert.Equal(t, int64(7), results[1].Offset())
+	})
+	t.Run("panic", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithSplitFunc(func([]byte, bool) (int, []byte, error) {
+			panic("split")
+		})).Grep(context.TODO(), "a", strings.NewReader("a\n"))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		if assert.Equal(t, 1, len(results)) {
+			var e *gogrep.PanicError
+			if assert.ErrorAs(t, results[0].Err(), &e) {
+				assert.Equal(t, "split", e.Value)
+			}
+		}
+	})
+	t.Run("multiline", func(t *testing.T) {
+		lines := dupStrings(250, "x")
+		lines[0] = "foo"
+		lines[1] = "bar"
+		lines[99] = "foo" // across chunks
+		lines[100] = "bar baz"
+		lines[101] = "foo"
+		lines[199] = "foo"
+		lines[200] = "x"
+		lines[201] = "bar"
+		source := strings.Join(lines, "\n") + "\n"
+
+		t.Run("blocks", func(t *testing.T) {
+			resultC, err := gogrep.New(gogrep.WithMultiline()).Grep(context.TODO(), `foo\nbar|baz\nfoo|foo\nx\nbar`, strings.NewReader(source))
+			assert.Nil(t, err)
+			results := toResultSlice(resultC)
+			assert.Equal(t, 3, len(results))
+			assert.Equal(t, "foo\nbar", results[0].Text())
+			assert.Equal(t, 1, results[0].Line())
+			assert.Equal(t, int64(0), results[0].Offset())
+			assert.Equal(t, [][2]int{{0, 7}}, results[0].MatchRanges())
+			assert.Equal(t, "foo\nbar baz\nfoo", results[1].Text())
+			assert.Equal(t, 100, results[1].Line())
+			assert.Equal(t, int64(8+97*2), results[1].Offset())
+			assert.Equal(t, [][2]int{{0, 7}, {8, 15}}, results[1].MatchRanges())
+			assert.Equal(t, "foo\nx\nbar", results[2].Text())
+			assert.Equal(t, 200, results[2].Line())
+		})
+
+		t.Run("only matching", func(t *testing.T) {
+			resultC, err := gogrep.New(gogrep.WithMultiline(), gogrep.WithOnlyMatching()).Grep(context.TODO(), `(foo)\nbar|baz\nfoo`, strings.NewReader(source))
+			assert.Nil(t, err)
+			results := toResultSlice(resultC)
+			assert.Equal(t, 3, len(results))
+			assert.Equal(t, "foo\nbar", results[1].Text())
+			assert.Equal(t, []string{"foo\nbar", "foo"}, results[1].Submatches())
+			assert.Equal(t, 100, results[1].Line())
+			assert.Equal(t, "baz\nfoo", results[2].Text())
+			assert.Equal(t, 101, results[2].Line())
+			assert.Equal(t, [][2]int{{4, 11}}, results[2].MatchRanges())
+		})
+
+		t.Run("no duplicates", func(t *testing.T) {
+			resultC, err := gogrep.New(gogrep.WithMultiline(), gogrep.WithOnlyMatching()).Grep(context.TODO(), `x\nx`, strings.NewReader(source))
+			assert.Nil(t, err)
+			results := toResultSlice(resultC)
+			for i := 1; i < len(results); i++ {
+				assert.Less(t, results[i-1].Line(), results[i].Line())
+			}
+		})
+	})
 	t.Run("scan error", func(t *testing.T) {
 		readErr := errors.New("reader")
 		resultC, err := gogrep.New().Grep(context.TODO(), ".", &errReader{
@@ -73,6 +429,26 @@ func TestGrepper(t *testing.T) {
 		assert.Contains(t, gotErr.Error(), "Grepper got error from source")
 	})
 
+	t.Run("canceled in chunk", func(t *testing.T) {
+		// A chunk of 100 lines takes 2s to match
+		engine := gogreptest.Engine("slow", gogreptest.MatcherFunc(func(string) bool {
+			time.Sleep(20 * time.Millisecond)
+			return true
+		}))
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+		resultC, err := gogrep.New(gogrep.WithEngine(engine), gogrep.WithThreads(1)).
+			Grep(ctx, "x", strings.NewReader(strings.Repeat("x\n", 1000)))
+		assert.Nil(t, err)
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		results := toResultSlice(resultC)
+		assert.Less(t, time.Since(start), time.Second)
+		if assert.Greater(t, len(results), 0) {
+			assert.ErrorIs(t, results[len(results)-1].Err(), context.Canceled)
+		}
+	})
+
 	t.Run("canceled", func(t *testing.T) {
 		grepper := gogrep.New(gogrep.WithResultBufferSize(1))
 		source := &delayReader{
@@ -172,3 +548,28 @@ func BenchmarkGrepper(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkGrepperAllocs(b *testing.B) {
+	data := strings.Join(dupStrings(1<<16, "allocation", "freeable", "cached", "dirty", "flush memory", "NAND", "ready to write"), "\n")
+	for _, tc := range []struct {
+		name string
+		opt  []gogrep.Option
+	}{
+		{name: "default"},
+		{name: "shared line buffers", opt: []gogrep.Option{gogrep.WithSharedLineBuffers()}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			g := gogrep.New(tc.opt...)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for n := 0; n < b.N; n++ {
+				resultC, err := g.Grep(context.TODO(), "NAND", strings.NewReader(data))
+				if err != nil {
+					b.Fatal(err)
+				}
+				for range resultC {
+				}
+			}
+		})
+	}
+}
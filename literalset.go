@@ -0,0 +1,201 @@
+package gogrep
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// WithLiteralSet adds the literals to the patterns of the greps, matched at once by an Aho-Corasick automaton
+// instead of an alternation of the regexes, e.g. a list of thousands of keywords.
+// A line matches if it contains any literal or matches any regex,
+// so GrepMulti, GrepSources, GrepReaderAt and Compile accept no regexes with it.
+// It is ignored by GrepPatterns and GrepRegexp.
+func WithLiteralSet(literals []string) Option {
+	return func(c *Config) {
+		c.literalSet = literals
+	}
+}
+
+// literalSet is an Aho-Corasick automaton of the literals.
+// The matches are found as regex alternation of the literals does:
+// the leftmost match wins and the earlier literal wins at the same position.
+type literalSet struct {
+	root   [256]int32 // the transitions of the root
+	states []acState  // the root is 0
+	empty  int        // the index of the first empty literal, -1 if none
+}
+
+type acState struct {
+	edges  []acEdge // sorted by the byte
+	fail   int32    // the state of the longest proper suffix
+	output int32    // the index of the first literal that ends at the state, -1 if none
+	dict   int32    // the nearest state with an output by the fail links, -1 if none
+	depth  int32
+}
+
+type acEdge struct {
+	b  byte
+	to int32
+}
+
+func newLiteralSet(literals []string) *literalSet {
+	s := &literalSet{
+		states: []acState{{output: -1, dict: -1}},
+		empty:  -1,
+	}
+	for i, x := range literals {
+		if x == "" {
+			if s.empty < 0 {
+				s.empty = i
+			}
+			continue
+		}
+		s.insert(x, i)
+	}
+	s.link()
+	return s
+}
+
+func (s *literalSet) insert(literal string, index int) {
+	var state int32
+	for i := 0; i < len(literal); i++ {
+		next := s.edge(state, literal[i])
+		if next < 0 {
+			next = int32(len(s.states))
+			s.states = append(s.states, acState{
+				output: -1,
+				dict:   -1,
+				depth:  s.states[state].depth + 1,
+			})
+			x := &s.states[state]
+			j := sort.Search(len(x.edges), func(j int) bool { return x.edges[j].b >= literal[i] })
+			x.edges = append(x.edges, acEdge{})
+			copy(x.edges[j+1:], x.edges[j:])
+			x.edges[j] = acEdge{b: literal[i], to: next}
+		}
+		state = next
+	}
+	if s.states[state].output < 0 {
+		s.states[state].output = int32(index)
+	}
+}
+
+// link sets the fail links and the dictionary links in breadth-first order.
+func (s *literalSet) link() {
+	for _, e := range s.states[0].edges {
+		s.root[e.b] = e.to
+	}
+	queue := make([]int32, 0, len(s.states))
+	for _, e := range s.states[0].edges {
+		queue = append(queue, e.to)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, e := range s.states[state].edges {
+			child := &s.states[e.to]
+			if state != 0 {
+				child.fail = s.next(s.states[state].fail, e.b)
+			}
+			if f := s.states[child.fail]; f.output >= 0 {
+				child.dict = child.fail
+			} else {
+				child.dict = f.dict
+			}
+			queue = append(queue, e.to)
+		}
+	}
+}
+
+// edge returns the state by the byte from the state, -1 if none.
+func (s *literalSet) edge(state int32, b byte) int32 {
+	edges := s.states[state].edges
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].b >= b })
+	if i < len(edges) && edges[i].b == b {
+		return edges[i].to
+	}
+	return -1
+}
+
+// next returns the state after the byte from the state, following the fail links.
+func (s *literalSet) next(state int32, b byte) int32 {
+	for state != 0 {
+		if x := s.edge(state, b); x >= 0 {
+			return x
+		}
+		state = s.states[state].fail
+	}
+	return s.root[b]
+}
+
+func (s *literalSet) MatchString(line string) bool {
+	if s.empty >= 0 {
+		return true
+	}
+	var state int32
+	for i := 0; i < len(line); i++ {
+		state = s.next(state, line[i])
+		if x := s.states[state]; x.output >= 0 || x.dict >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAllStringSubmatchIndex returns the successive non-overlapping matches of the literals.
+func (s *literalSet) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	if n == 0 {
+		return nil
+	}
+	type found struct {
+		start, end int
+		index      int32
+	}
+	var all []found
+	if s.empty >= 0 {
+		for i := 0; i <= len(line); i++ {
+			all = append(all, found{start: i, end: i, index: int32(s.empty)})
+		}
+	}
+	var state int32
+	for i := 0; i < len(line); i++ {
+		state = s.next(state, line[i])
+		for x := state; x >= 0; x = s.states[x].dict {
+			if out := s.states[x].output; out >= 0 {
+				all = append(all, found{start: i + 1 - int(s.states[x].depth), end: i + 1, index: out})
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].start != all[j].start {
+			return all[i].start < all[j].start
+		}
+		return all[i].index < all[j].index
+	})
+	var (
+		r       [][]int
+		pos     int
+		prevEnd = -1
+	)
+	for _, x := range all {
+		if n >= 0 && len(r) >= n {
+			break
+		}
+		if x.start < pos {
+			continue // overlapping
+		}
+		if x.start == x.end {
+			// An empty match next to the previous match is ignored as regexp does
+			if x.start != prevEnd {
+				r = append(r, []int{x.start, x.end})
+			}
+			_, width := utf8.DecodeRuneInString(line[x.start:])
+			pos = x.start + max(width, 1)
+		} else {
+			r = append(r, []int{x.start, x.end})
+			pos = x.end
+		}
+		prevEnd = x.end
+	}
+	return r
+}
@@ -0,0 +1,109 @@
+package gogrep_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLiteralSet(t *testing.T) {
+	const source = "she sells\nsea shells\nby the shore\nhers\n"
+
+	t.Run("lines", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithLiteralSet([]string{"he", "hers", "shore"})).
+			GrepMulti(context.TODO(), nil, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"by the shore", "hers", "sea shells", "she sells"}, sortedTexts(t, resultC))
+	})
+
+	t.Run("with regexes", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithLiteralSet([]string{"shore"})).
+			GrepMulti(context.TODO(), []string{`^s`}, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"by the shore", "sea shells", "she sells"}, sortedTexts(t, resultC))
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithLiteralSet([]string{"sea"})).GrepSources(context.TODO(), nil, []gogrep.NamedSource{
+			{Name: "a", Reader: strings.NewReader(source)},
+			{Name: "b", Reader: strings.NewReader("seaside\n")},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"sea shells", "seaside"}, sortedTexts(t, resultC))
+	})
+
+	t.Run("no patterns", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithLiteralSet(nil)).GrepMulti(context.TODO(), nil, strings.NewReader(source))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("same as alternation", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		randomText := func(maxLen int) string {
+			b := make([]byte, rng.Intn(maxLen+1))
+			for i := range b {
+				b[i] = "abc"[rng.Intn(3)]
+			}
+			return string(b)
+		}
+		for i := 0; i < 100; i++ {
+			literals := make([]string, 1+rng.Intn(8))
+			quoted := make([]string, len(literals))
+			for j := range literals {
+				literals[j] = randomText(4)
+				quoted[j] = regexp.QuoteMeta(literals[j])
+			}
+			var lines []string
+			for j := 0; j < 10; j++ {
+				lines = append(lines, randomText(16))
+			}
+			input := strings.Join(lines, "\n") + "\n"
+
+			got, err := gogrep.New(gogrep.WithLiteralSet(literals), gogrep.WithOnlyMatching()).
+				GrepMulti(context.TODO(), nil, strings.NewReader(input))
+			if !assert.Nil(t, err) {
+				return
+			}
+			want, err := gogrep.New(gogrep.WithOnlyMatching(), gogrep.WithEngine(gogrep.EngineRegexp)).
+				Grep(context.TODO(), strings.Join(quoted, "|"), strings.NewReader(input))
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, matchPositions(t, want), matchPositions(t, got), "literals %q input %q", literals, input)
+		}
+	})
+}
+
+func sortedTexts(t *testing.T, resultC <-chan gogrep.Result) []string {
+	var texts []string
+	for r := range resultC {
+		assert.Nil(t, r.Err())
+		texts = append(texts, r.Text())
+	}
+	sort.Strings(texts)
+	return texts
+}
+
+// matchPositions returns the lines and the ranges of the matches in order.
+func matchPositions(t *testing.T, resultC <-chan gogrep.Result) []string {
+	var xs []string
+	for r := range resultC {
+		assert.Nil(t, r.Err())
+		xs = append(xs, fmt.Sprintf("%d:%v", r.Line(), r.MatchRanges()))
+	}
+	sort.Strings(xs)
+	return xs
+}
@@ -0,0 +1,70 @@
+package gogrep
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+// WithLogger emits the debug events of the greps to the handler, e.g. to diagnose a hang or a slow grep of the embedded Grepper:
+// the start and the end of the greps and the workers, the chunks of the lines dispatched to the workers and matched by them,
+// the chunks dropped and the greps stopped by the cancellations, and the scan errors.
+// The events are logged at slog.LevelDebug with the source of GrepSources and GrepFile, and the range of GrepReaderAt.
+// Nil handler disables the events.
+func WithLogger(handler slog.Handler) Option {
+	return func(c *Config) {
+		if handler == nil {
+			c.logger = nil
+			return
+		}
+		c.logger = slog.New(handler)
+	}
+}
+
+// log returns the logger of the grep, nil without WithLogger.
+func (c *Config) log() *slog.Logger {
+	if c.logger == nil || c.sourceName == "" {
+		return c.logger
+	}
+	return c.logger.With("source", c.sourceName)
+}
+
+// debugLog logs the event if the logger is not nil.
+func debugLog(logger *slog.Logger, msg string, args ...interface{}) {
+	if logger != nil {
+		logger.Debug(msg, args...)
+	}
+}
+
+// logObserver logs the events of the pipeline.
+type logObserver struct {
+	pipeline.Observer // nil without the other observers
+	logger            *slog.Logger
+}
+
+func (s *logObserver) ObserveChunk(worker int, chunk []pipeline.Record, elapsed time.Duration) {
+	if s.Observer != nil {
+		s.Observer.ObserveChunk(worker, chunk, elapsed)
+	}
+	s.logger.Debug("chunk matched", "worker", worker, "lines", len(chunk), "elapsed", elapsed)
+}
+
+func (s *logObserver) ObserveDrop(chunk []pipeline.Record) {
+	if o, ok := s.Observer.(pipeline.DropObserver); ok {
+		o.ObserveDrop(chunk)
+	}
+	s.logger.Debug("chunk dropped", "lines", len(chunk))
+}
+
+func (s *logObserver) ObserveDispatch(records, bytes int, blocked time.Duration) {
+	s.logger.Debug("chunk dispatched", "lines", records, "bytes", bytes, "blocked", blocked)
+}
+
+func (s *logObserver) ObserveStart(worker int) {
+	s.logger.Debug("worker started", "worker", worker)
+}
+
+func (s *logObserver) ObserveEnd(worker int) {
+	s.logger.Debug("worker finished", "worker", worker)
+}
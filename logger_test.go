@@ -0,0 +1,110 @@
+package gogrep_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLogger(t *testing.T) {
+	type event struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Source string `json:"source"`
+		Lines  int    `json:"lines"`
+		Err    string `json:"err"`
+	}
+	grep := func(t *testing.T, grep func(gogrep.Grepper) (<-chan gogrep.Result, error), opt ...gogrep.Option) []event {
+		var b bytes.Buffer
+		g := gogrep.New(append(opt, gogrep.WithLogger(slog.NewJSONHandler(&b, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+		})))...)
+		resultC, err := grep(g)
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		for range resultC {
+		}
+		var events []event
+		for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+			var e event
+			if !assert.Nil(t, json.Unmarshal([]byte(line), &e), line) {
+				return nil
+			}
+			assert.Equal(t, "DEBUG", e.Level)
+			events = append(events, e)
+		}
+		return events
+	}
+	count := func(events []event, msg string) int {
+		var n int
+		for _, e := range events {
+			if e.Msg == msg {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("grep", func(t *testing.T) {
+		events := grep(t, func(g gogrep.Grepper) (<-chan gogrep.Result, error) {
+			return g.Grep(context.TODO(), "a", strings.NewReader(strings.Repeat("a\nb\n", 150)))
+		}, gogrep.WithThreads(2))
+		if !assert.True(t, len(events) > 0) {
+			return
+		}
+		assert.Equal(t, "grep started", events[0].Msg)
+		last := events[len(events)-1]
+		assert.Equal(t, "grep finished", last.Msg)
+		assert.Equal(t, 300, last.Lines)
+		assert.Equal(t, 2, count(events, "worker started"))
+		assert.Equal(t, 2, count(events, "worker finished"))
+		assert.Equal(t, 3, count(events, "chunk dispatched"))
+		assert.Equal(t, 3, count(events, "chunk matched"))
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		events := grep(t, func(g gogrep.Grepper) (<-chan gogrep.Result, error) {
+			return g.GrepSources(context.TODO(), []string{"a"}, []gogrep.NamedSource{
+				{Name: "x", Reader: strings.NewReader("a\n")},
+				{Name: "y", Reader: strings.NewReader("b\n")},
+			})
+		}, gogrep.WithoutBatching())
+		sources := map[string]int{}
+		for _, e := range events {
+			sources[e.Source]++
+		}
+		assert.Equal(t, 2, len(sources), "%v", sources)
+		assert.True(t, sources["x"] > 0 && sources["y"] > 0)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		events := grep(t, func(g gogrep.Grepper) (<-chan gogrep.Result, error) {
+			return g.Grep(context.TODO(), "a", strings.NewReader("a\n"+strings.Repeat("a", 100)+"\n"))
+		}, gogrep.WithMaxLineLength(10))
+		assert.Equal(t, 1, count(events, "scan error"))
+		for _, e := range events {
+			if e.Msg == "scan error" {
+				assert.Contains(t, e.Err, "line 2")
+			}
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithLogger(nil)).Grep(context.TODO(), "a", strings.NewReader("a\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var n int
+		for range resultC {
+			n++
+		}
+		assert.Equal(t, 1, n)
+	})
+}
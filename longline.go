@@ -0,0 +1,87 @@
+package gogrep
+
+import (
+	"bufio"
+	"bytes"
+)
+
+// LongLineMode is the policy for the lines longer than the max line length.
+type LongLineMode string
+
+const (
+	// LongLineError makes Grep fail with bufio.ErrTooLong.
+	LongLineError LongLineMode = "error"
+	// LongLineSkip ignores the long lines.
+	LongLineSkip LongLineMode = "skip"
+	// LongLineTruncate matches and emits the first max line length bytes of the long lines.
+	LongLineTruncate LongLineMode = "truncate"
+)
+
+// lineSplitter is a bufio.SplitFunc by lines that tracks the offsets of the lines
+// and handles the long lines by the mode.
+type lineSplitter struct {
+	base      bufio.SplitFunc
+	maxLength int
+	mode      LongLineMode
+	consumed  int64 // number of bytes advanced
+	start     int64 // offset of the last token
+	long      bool  // the last token is a head of a long line
+	discard   bool  // discarding the rest of a long line
+}
+
+func (s *lineSplitter) split(data []byte, atEOF bool) (int, []byte, error) {
+	n, token, err := s.scan(data, atEOF)
+	if token != nil {
+		s.start = s.consumed + int64(tokenIndex(data, token))
+	}
+	s.consumed += int64(n)
+	return n, token, err
+}
+
+func (s *lineSplitter) scan(data []byte, atEOF bool) (int, []byte, error) {
+	n, token, err := s.base(data, atEOF)
+	if s.discard {
+		if token != nil || err != nil || atEOF {
+			// The end of the long line
+			s.discard = false
+			return n, nil, err
+		}
+		return len(data), nil, nil
+	}
+	s.long = false
+	if n > 0 || token != nil || err != nil || atEOF || s.mode == LongLineError || len(data) < s.maxLength {
+		return n, token, err
+	}
+	// The buffer is full of a line
+	s.long = true
+	s.discard = true
+	return len(data), data[:s.maxLength], nil
+}
+
+// tokenIndex returns the index of the token in the data if the token is a part of the data.
+func tokenIndex(data, token []byte) int {
+	if len(token) == 0 {
+		return 0
+	}
+	i := cap(data) - cap(token)
+	if i < 0 || i >= len(data) || &data[i] != &token[0] {
+		return 0
+	}
+	return i
+}
+
+// scanDelimiter returns a bufio.SplitFunc that splits records terminated by the delimiter.
+func scanDelimiter(delimiter byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.IndexByte(data, delimiter); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
@@ -0,0 +1,163 @@
+package gogrep
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+func (s *grepper) GrepBytes(ctx context.Context, regex string, data []byte) (<-chan Result, error) {
+	var text string
+	if len(data) > 0 {
+		text = unsafe.String(&data[0], len(data))
+	}
+	return s.Grep(ctx, regex, s.memorySource([]string{text}, false))
+}
+
+func (s *grepper) GrepLines(ctx context.Context, regex string, lines []string) (<-chan Result, error) {
+	return s.Grep(ctx, regex, s.memorySource(lines, true))
+}
+
+func (s *grepper) memorySource(lines []string, terminated bool) *memorySource {
+	r := &memorySource{
+		lines:      lines,
+		terminator: '\n',
+		terminated: terminated,
+	}
+	if s.config.delimiter >= 0 {
+		r.terminator = byte(s.config.delimiter)
+	}
+	return r
+}
+
+// memorySource is the source of GrepBytes and GrepLines.
+// scanSplitter splits it without a bufio.Scanner unless the options read it as a reader,
+// e.g. WithDecompression, WithEncoding, WithReaderMiddleware and WithBinaryFiles other than BinaryText.
+type memorySource struct {
+	lines      []string
+	terminator byte
+	terminated bool   // each line is followed by the terminator
+	n          *int64 // counts the bytes read if not nil
+	i, off     int    // the position of Read
+}
+
+// Size returns the size of the source including the terminators.
+func (s *memorySource) Size() int64 {
+	var n int64
+	for _, x := range s.lines {
+		n += int64(len(x))
+		if s.terminated {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *memorySource) Read(p []byte) (int, error) {
+	var n int
+	for n < len(p) && s.i < len(s.lines) {
+		line := s.lines[s.i]
+		if s.off < len(line) {
+			c := copy(p[n:], line[s.off:])
+			n += c
+			s.off += c
+			continue
+		}
+		if s.terminated {
+			p[n] = s.terminator
+			n++
+		}
+		s.i++
+		s.off = 0
+	}
+	s.count(n)
+	if n == 0 && s.i == len(s.lines) {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (s *memorySource) count(n int) {
+	if s.n != nil {
+		atomic.AddInt64(s.n, int64(n))
+	}
+}
+
+// splitMemory splits the source as Split does by the delimiter.
+// The texts of the records refer to the source.
+func (s *scanSplitter) splitMemory(source *memorySource, emit func(pipeline.Record) error) error {
+	var (
+		lineNumber = s.startLine
+		offset     int64
+		delimiter  = byte(s.delimiter)
+		reportLong = s.longLineMode == LongLineError && s.errorPolicy == ErrorContinue
+	)
+	defer func() { s.count = lineNumber }()
+	// record emits the record at the head of rest of the size including the terminator.
+	record := func(rest, text string, size int, terminated bool) error {
+		source.count(size)
+		defer func() { offset += int64(size) }()
+		long := size > s.maxLineLength || !terminated && size == s.maxLineLength
+		if long && s.longLineMode == LongLineError && !reportLong {
+			return bufio.ErrTooLong
+		}
+		lineNumber++
+		s.stats.scanned()
+		if long && s.longLineMode != LongLineTruncate {
+			if reportLong {
+				s.send(&result{
+					err: &ScanError{
+						Line: lineNumber,
+						Err:  bufio.ErrTooLong,
+					},
+					line:   lineNumber,
+					offset: offset,
+				})
+			} else if s.reportSkipped {
+				s.send(newSkipResult(SkipLongLine, lineNumber, offset))
+			}
+			return nil
+		}
+		if long {
+			if s.reportSkipped {
+				s.send(newSkipResult(SkipLongLine, lineNumber, offset+int64(s.maxLineLength)))
+			}
+			text = rest[:s.maxLineLength]
+		} else if s.dropCR {
+			text = strings.TrimSuffix(text, "\r")
+		}
+		return emit(pipeline.Record{
+			Text:   text,
+			View:   text,
+			Number: lineNumber,
+			Offset: offset,
+		})
+	}
+	for _, rest := range source.lines {
+		for {
+			var err error
+			i := strings.IndexByte(rest, delimiter)
+			switch {
+			case i >= 0:
+				err = record(rest, rest[:i], i+1, true)
+			case source.terminated:
+				err = record(rest, rest, len(rest)+1, true)
+			case rest != "":
+				err = record(rest, rest, len(rest), false)
+			}
+			if err != nil {
+				return err
+			}
+			if i < 0 {
+				break
+			}
+			rest = rest[i+1:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package gogrep_test
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryResult struct {
+	text   string
+	err    string
+	line   int
+	offset int64
+}
+
+func collectMemoryResults(t *testing.T, resultC <-chan gogrep.Result, err error) []memoryResult {
+	t.Helper()
+	if !assert.Nil(t, err) {
+		return nil
+	}
+	got := []memoryResult{}
+	for r := range resultC {
+		x := memoryResult{
+			text:   r.Text(),
+			line:   r.Line(),
+			offset: r.Offset(),
+		}
+		if err := r.Err(); err != nil {
+			x.err = err.Error()
+		}
+		got = append(got, x)
+	}
+	return got
+}
+
+func TestGrepBytes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "ab", "\r", "\n", "\n", "\r\n", "x", "", strings.Repeat("a", 12)}
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(10))
+		for i := range lines {
+			var b strings.Builder
+			for j := rng.Intn(5); j > 0; j-- {
+				b.WriteString(words[rng.Intn(len(words))])
+			}
+			lines[i] = b.String()
+		}
+		return lines
+	}
+
+	for _, tc := range []*struct {
+		title string
+		opt   []gogrep.Option
+	}{
+		{title: "default"},
+		{
+			title: "long line error",
+			opt:   []gogrep.Option{gogrep.WithMaxLineLength(10)},
+		},
+		{
+			title: "long line error continue",
+			opt:   []gogrep.Option{gogrep.WithMaxLineLength(10), gogrep.WithErrorPolicy(gogrep.ErrorContinue)},
+		},
+		{
+			title: "long line skip",
+			opt: []gogrep.Option{
+				gogrep.WithMaxLineLength(10), gogrep.WithLongLineMode(gogrep.LongLineSkip), gogrep.WithReportSkipped(),
+			},
+		},
+		{
+			title: "long line truncate",
+			opt: []gogrep.Option{
+				gogrep.WithMaxLineLength(10), gogrep.WithLongLineMode(gogrep.LongLineTruncate), gogrep.WithReportSkipped(),
+			},
+		},
+		{
+			title: "delimiter",
+			opt:   []gogrep.Option{gogrep.WithDelimiter('\r')},
+		},
+		{
+			title: "only matching",
+			opt:   []gogrep.Option{gogrep.WithOnlyMatching()},
+		},
+		{
+			title: "encoding",
+			opt:   []gogrep.Option{gogrep.WithEncoding("latin1")},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			g := gogrep.New(append(tc.opt, gogrep.WithThreads(1))...)
+			for i := 0; i < 200; i++ {
+				lines := randomLines()
+				regex := []string{"a", "^$", "b$", "a\r"}[i%4]
+
+				data := strings.Join(lines, "")
+				resultC, err := g.Grep(context.TODO(), regex, strings.NewReader(data))
+				want := collectMemoryResults(t, resultC, err)
+				resultC, err = g.GrepBytes(context.TODO(), regex, []byte(data))
+				assert.Equal(t, want, collectMemoryResults(t, resultC, err), "bytes %q", data)
+
+				var b strings.Builder
+				for _, x := range lines {
+					b.WriteString(x)
+					if tc.title == "delimiter" {
+						b.WriteByte('\r')
+					} else {
+						b.WriteByte('\n')
+					}
+				}
+				resultC, err = g.Grep(context.TODO(), regex, strings.NewReader(b.String()))
+				want = collectMemoryResults(t, resultC, err)
+				resultC, err = g.GrepLines(context.TODO(), regex, lines)
+				assert.Equal(t, want, collectMemoryResults(t, resultC, err), "lines %q", lines)
+			}
+		})
+	}
+
+	t.Run("stats", func(t *testing.T) {
+		var got gogrep.Stats
+		resultC, err := gogrep.New(gogrep.WithStatsCollector(func(s gogrep.Stats) {
+			got = s
+		})).GrepLines(context.TODO(), "b", []string{"a", "b", "c"})
+		if !assert.Nil(t, err) {
+			return
+		}
+		for range resultC {
+		}
+		assert.Equal(t, int64(3), got.LinesScanned)
+		assert.Equal(t, int64(6), got.BytesRead)
+		assert.Equal(t, int64(1), got.LinesMatched)
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		data := []byte("abc\nxyz\n")
+		resultC, err := gogrep.New().GrepBytes(context.TODO(), "x", data)
+		if !assert.Nil(t, err) {
+			return
+		}
+		var results []gogrep.Result
+		for r := range resultC {
+			results = append(results, r)
+		}
+		if !assert.Equal(t, 1, len(results)) {
+			return
+		}
+		cloned := gogrep.CloneResult(results[0])
+		copy(data[4:], "XYZ")
+		assert.Equal(t, "XYZ", results[0].Text())
+		assert.Equal(t, "xyz", cloned.Text())
+	})
+}
+
+func BenchmarkGrepBytes(b *testing.B) {
+	lines := dupStrings(1<<16, "allocation", "freeable", "cached", "dirty", "flush memory", "NAND", "ready to write")
+	data := strings.Join(lines, "\n") + "\n"
+	dataBytes := []byte(data)
+	for _, tc := range []struct {
+		name string
+		grep func(gogrep.Grepper) (<-chan gogrep.Result, error)
+	}{
+		{
+			name: "reader",
+			grep: func(g gogrep.Grepper) (<-chan gogrep.Result, error) {
+				return g.Grep(context.TODO(), "NAND", strings.NewReader(data))
+			},
+		},
+		{
+			name: "bytes",
+			grep: func(g gogrep.Grepper) (<-chan gogrep.Result, error) {
+				return g.GrepBytes(context.TODO(), "NAND", dataBytes)
+			},
+		},
+		{
+			name: "lines",
+			grep: func(g gogrep.Grepper) (<-chan gogrep.Result, error) {
+				return g.GrepLines(context.TODO(), "NAND", lines)
+			},
+		},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			g := gogrep.New()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for n := 0; n < b.N; n++ {
+				resultC, err := tc.grep(g)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for range resultC {
+				}
+			}
+		})
+	}
+}
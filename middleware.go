@@ -0,0 +1,83 @@
+package gogrep
+
+import (
+	"io"
+	"reflect"
+)
+
+// ReaderMiddleware decorates the reader of a source, e.g. decryption.
+// The reader returned is closed after the grep if it implements io.Closer and is not the given reader.
+type ReaderMiddleware func(r io.Reader) io.Reader
+
+// ReaderStage is the point in the chain of the readers of a source where the middlewares are inserted.
+//
+// A source is read by the ReaderStrategy, decorated by the middlewares of ReaderRaw,
+// decompressed with WithDecompression, decorated by the middlewares of ReaderDecompressed,
+// transcoded into UTF-8 with WithEncoding, and decorated by the middlewares of ReaderText
+// before it is split into the lines.
+type ReaderStage string
+
+const (
+	// ReaderRaw is the stage of the bytes as read from the source.
+	ReaderRaw ReaderStage = "raw"
+	// ReaderDecompressed is the stage of the decompressed bytes, before the transcoding.
+	ReaderDecompressed ReaderStage = "decompressed"
+	// ReaderText is the stage of the UTF-8 text to be split into the lines.
+	ReaderText ReaderStage = "text"
+)
+
+// WithReaderMiddleware inserts the middlewares at the stage in order, after the middlewares inserted already.
+// The middlewares make GrepReaderAt grep the sources sequentially and GrepSources not batch the small sources,
+// since they decorate the whole source.
+// Nil is ignored.
+func WithReaderMiddleware(stage ReaderStage, middleware ...ReaderMiddleware) Option {
+	return func(c *Config) {
+		// Copy not to share the middlewares with the configs of the other sources
+		xs := c.readerMiddleware[:len(c.readerMiddleware):len(c.readerMiddleware)]
+		for _, m := range middleware {
+			if m != nil {
+				xs = append(xs, stagedMiddleware{stage: stage, wrap: m})
+			}
+		}
+		c.readerMiddleware = xs
+	}
+}
+
+type stagedMiddleware struct {
+	stage ReaderStage
+	wrap  ReaderMiddleware
+}
+
+// readerChain applies the middlewares to the readers of a source and closes the readers by them.
+type readerChain struct {
+	middleware []stagedMiddleware
+	closers    []io.Closer
+}
+
+// wrap returns the reader decorated by the middlewares of the stage.
+func (s *readerChain) wrap(stage ReaderStage, r io.Reader) io.Reader {
+	for _, m := range s.middleware {
+		if m.stage != stage {
+			continue
+		}
+		x := m.wrap(r)
+		if c, ok := x.(io.Closer); ok && !sameReader(x, r) {
+			s.closers = append(s.closers, c)
+		}
+		r = x
+	}
+	return r
+}
+
+// close closes the readers by the middlewares from the outermost.
+func (s *readerChain) close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i].Close()
+	}
+}
+
+// sameReader returns true if the readers are the same value.
+func sameReader(a, b io.Reader) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
@@ -0,0 +1,121 @@
+package gogrep_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// xorReader flips the bits of the bytes, a toy encryption.
+type xorReader struct {
+	r      io.Reader
+	closed bool
+}
+
+func (s *xorReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= 0xff
+	}
+	return n, err
+}
+
+func (s *xorReader) Close() error {
+	s.closed = true
+	return nil
+}
+
+func xorBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, x := range b {
+		r[i] = x ^ 0xff
+	}
+	return r
+}
+
+func TestWithReaderMiddleware(t *testing.T) {
+	const source = "first line\nsecond line\nthird line\n"
+
+	t.Run("stages", func(t *testing.T) {
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		_, _ = w.Write([]byte(source))
+		assert.Nil(t, w.Close())
+
+		var (
+			decrypt *xorReader
+			stages  []gogrep.ReaderStage
+			record  = func(stage gogrep.ReaderStage) gogrep.ReaderMiddleware {
+				return func(r io.Reader) io.Reader {
+					stages = append(stages, stage)
+					return r
+				}
+			}
+		)
+		grepper := gogrep.New(
+			gogrep.WithDecompression(),
+			gogrep.WithReaderMiddleware(gogrep.ReaderText, record(gogrep.ReaderText), func(r io.Reader) io.Reader {
+				b, _ := io.ReadAll(r)
+				return strings.NewReader(strings.ToUpper(string(b)))
+			}),
+			gogrep.WithReaderMiddleware(gogrep.ReaderDecompressed, record(gogrep.ReaderDecompressed)),
+			gogrep.WithReaderMiddleware(gogrep.ReaderRaw, record(gogrep.ReaderRaw), func(r io.Reader) io.Reader {
+				decrypt = &xorReader{r: r}
+				return decrypt
+			}, nil),
+		)
+		resultC, err := grepper.Grep(context.TODO(), "SECOND", bytes.NewReader(xorBytes(gz.Bytes())))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"SECOND LINE"}, sortedTexts(t, resultC))
+		assert.Equal(t, []gogrep.ReaderStage{gogrep.ReaderRaw, gogrep.ReaderDecompressed, gogrep.ReaderText}, stages)
+		assert.True(t, decrypt.closed)
+	})
+
+	t.Run("source not closed", func(t *testing.T) {
+		src := &xorReader{r: bytes.NewReader(xorBytes([]byte(source)))}
+		grepper := gogrep.New(gogrep.WithReaderMiddleware(gogrep.ReaderRaw, func(r io.Reader) io.Reader { return r }))
+		resultC, err := grepper.Grep(context.TODO(), "third", src)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"third line"}, sortedTexts(t, resultC))
+		assert.False(t, src.closed)
+	})
+
+	decrypt := gogrep.WithReaderMiddleware(gogrep.ReaderRaw, func(r io.Reader) io.Reader { return &xorReader{r: r} })
+
+	t.Run("reader at", func(t *testing.T) {
+		large := strings.Repeat(source, 1<<14)
+		resultC, err := gogrep.New(decrypt, gogrep.WithThreads(4)).
+			GrepReaderAt(context.TODO(), []string{"^second"}, bytes.NewReader(xorBytes([]byte(large))))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var n int
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			assert.Equal(t, "second line", r.Text())
+			n++
+		}
+		assert.Equal(t, 1<<14, n)
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		resultC, err := gogrep.New().GrepSources(context.TODO(), []string{"line"}, []gogrep.NamedSource{
+			{Name: "plain", Reader: strings.NewReader("plain line\n")},
+			{Name: "encrypted", Reader: bytes.NewReader(xorBytes([]byte("secret line\n"))), Options: []gogrep.Option{decrypt}},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"plain line", "secret line"}, sortedTexts(t, resultC))
+	})
+}
@@ -0,0 +1,214 @@
+package gogrep
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+// multilineMatcher selects the blocks of lines that match with the matcher.
+// The lines are joined by "\n" and matched in windows of a chunk and the next chunk,
+// so that a match can span up to the next chunk.
+// The chunks must be received in order.
+type multilineMatcher struct {
+	grepper *grepper
+	matcher Matcher
+	body    []pipeline.Record
+	state   *multilineState
+}
+
+func newMultilineMatcher(grepper *grepper, matcher Matcher, limit *limits) *multilineMatcher {
+	return &multilineMatcher{
+		grepper: grepper,
+		matcher: matcher,
+		state: &multilineState{
+			limit: limit,
+		},
+	}
+}
+
+func (s *multilineMatcher) Match(tail []pipeline.Record, emit func(pipeline.Item)) {
+	if s.state.limit.reached() {
+		return
+	}
+	s.state.emit = emit
+	if s.body != nil {
+		s.grepper.grepWindow(s.body, tail, s.matcher, s.state)
+	}
+	s.body = tail
+}
+
+func (s *multilineMatcher) Flush(emit func(pipeline.Item)) {
+	s.state.emit = emit
+	if s.body != nil && !s.state.limit.reached() {
+		s.grepper.grepWindow(s.body, nil, s.matcher, s.state)
+	}
+	s.state.flush()
+}
+
+// multilineState is the state carried over the windows.
+type multilineState struct {
+	emit     func(pipeline.Item)
+	limit    *limits
+	minStart int          // the end of the last match relative to the window
+	block    *windowBlock // the block that may be extended by the next matches
+}
+
+func (s *multilineState) flush() {
+	if s.block == nil {
+		return
+	}
+	if s.limit.takeLine() && s.limit.results.take() {
+		s.emit(s.block.result())
+	}
+	s.block = nil
+}
+
+// grepWindow emits the matches that start in the body and not before the last match.
+func (s *grepper) grepWindow(body, tail []pipeline.Record, r Matcher, state *multilineState) {
+	var (
+		w       = newWindow(append(body[:len(body):len(body)], tail...))
+		bodyEnd = w.starts[len(body)] // positions from this belong to the tail
+		lastEnd = state.minStart
+	)
+	for _, m := range r.FindAllStringSubmatchIndex(w.view, -1) {
+		start, end := m[0], m[1]
+		if start < state.minStart {
+			continue // found by the previous window
+		}
+		if start >= bodyEnd && tail != nil {
+			break // left to the next window
+		}
+		lastEnd = end
+		first, last := w.lineIndex(start), w.lineIndex(end)
+		if end > start {
+			last = w.lineIndex(end - 1)
+		}
+		if s.config.onlyMatching {
+			if end == start {
+				continue // prints nothing as grep -o
+			}
+			if !state.limit.takeLine() {
+				continue
+			}
+			b := w.block(first, last)
+			index := make([]int, len(m))
+			for i, x := range m {
+				index[i] = -1
+				if x >= 0 {
+					index[i] = x - w.starts[first]
+				}
+			}
+			if state.limit.results.take() {
+				x := newSubmatchResult(b, index)
+				x.matcher, x.view = r, x.text
+				state.emit(x)
+			}
+			continue
+		}
+		if b := state.block; b != nil && first <= b.last {
+			// Overlaps the previous block
+			if last > b.last {
+				b.lines = append(b.lines, w.lines[b.last+1:last+1]...)
+				b.last = last
+			}
+			b.ranges = append(b.ranges, [2]int{start - b.base, end - b.base})
+			continue
+		}
+		state.flush()
+		state.block = &windowBlock{
+			base:    w.starts[first],
+			last:    last,
+			lines:   append([]pipeline.Record(nil), w.lines[first:last+1]...),
+			ranges:  [][2]int{{start - w.starts[first], end - w.starts[first]}},
+			matcher: r,
+		}
+	}
+	state.minStart = lastEnd - bodyEnd
+	if b := state.block; b != nil {
+		if b.last < len(body) || tail == nil {
+			state.flush()
+			return
+		}
+		// Reaches the next window
+		b.base -= bodyEnd
+		b.last -= len(body)
+	}
+}
+
+// window is the lines joined by "\n".
+type window struct {
+	lines  []pipeline.Record
+	text   string
+	view   string
+	starts []int // positions of the lines in the text
+}
+
+func newWindow(lines []pipeline.Record) *window {
+	var (
+		text, view strings.Builder
+		starts     = make([]int, len(lines)+1)
+	)
+	for i, l := range lines {
+		if i > 0 {
+			text.WriteByte('\n')
+			view.WriteByte('\n')
+		}
+		starts[i] = text.Len()
+		text.WriteString(l.Text)
+		view.WriteString(l.View)
+	}
+	starts[len(lines)] = text.Len() + 1
+	return &window{
+		lines:  lines,
+		text:   text.String(),
+		view:   view.String(),
+		starts: starts,
+	}
+}
+
+// lineIndex returns the index of the line that contains the position.
+func (s *window) lineIndex(pos int) int {
+	i := sort.Search(len(s.lines), func(i int) bool { return s.starts[i] > pos }) - 1
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// block returns the lines from first to last as a line.
+func (s *window) block(first, last int) pipeline.Record {
+	start, end := s.starts[first], s.starts[last+1]-1
+	return pipeline.Record{
+		Text:   s.text[start:end],
+		View:   s.view[start:end],
+		Number: s.lines[first].Number,
+		Offset: s.lines[first].Offset,
+	}
+}
+
+// windowBlock is the lines that contain the matches.
+type windowBlock struct {
+	base    int // position of the first line in the window
+	last    int // index of the last line in the window
+	lines   []pipeline.Record
+	ranges  [][2]int // positions of the matches in the block
+	matcher Matcher
+}
+
+func (s *windowBlock) result() Result {
+	texts := make([]string, len(s.lines))
+	for i, l := range s.lines {
+		texts[i] = l.Text
+	}
+	text := strings.Join(texts, "\n")
+	return &result{
+		text:    text,
+		line:    s.lines[0].Number,
+		offset:  s.lines[0].Offset,
+		ranges:  s.ranges,
+		matcher: s.matcher,
+		view:    text,
+	}
+}
@@ -0,0 +1,285 @@
+package gogrep
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is the Unicode normalization form of WithNormalization.
+type Normalization string
+
+const (
+	// NormalizationNFC composes the characters canonically, e.g. e and U+0301 into é.
+	NormalizationNFC Normalization = "nfc"
+	// NormalizationNFKC composes the characters by the compatibility too, e.g. ﬁ into fi and the full-width Ａ into A.
+	NormalizationNFKC Normalization = "nfkc"
+)
+
+// WithNormalization matches the patterns and the lines in the normalization form,
+// so the composed and the decomposed characters match each other.
+// The results keep the lines as they are, and MatchRanges and Submatches are of them.
+// The literals of the regexes of EngineRegexp and EngineAuto are normalized,
+// the patterns of EngineFixed, WithFuzzy and WithLiteralSet as a whole,
+// and the patterns of the other engines as a whole except the characters escaped by \.
+// It is ignored by GrepRegexp.
+func WithNormalization(form Normalization) Option {
+	return func(c *Config) {
+		c.normalization = form
+	}
+}
+
+// WithCaseFolding matches the patterns and the lines by the full Unicode case folding
+// as WithNormalization does, e.g. STRASSE matches Straße, unlike (?i) of the regexes that maps a character to a character.
+// The character classes of the regexes fold as (?i) does, e.g. [A-Z] matches a.
+func WithCaseFolding() Option {
+	return func(c *Config) {
+		c.caseFolding = true
+	}
+}
+
+// normalizer transforms the patterns and the lines by WithNormalization and WithCaseFolding.
+type normalizer struct {
+	form      norm.Form
+	normalize bool
+	fold      bool
+}
+
+// normalizer returns nil without WithNormalization and WithCaseFolding.
+func (c *Config) normalizer() *normalizer {
+	if c.normalization == "" && !c.caseFolding {
+		return nil
+	}
+	s := &normalizer{
+		form:      norm.NFC,
+		normalize: c.normalization != "",
+		fold:      c.caseFolding,
+	}
+	if c.normalization == NormalizationNFKC {
+		s.form = norm.NFKC
+	}
+	return s
+}
+
+// pattern returns the pattern of the engine matching the normalized lines.
+// literal means the pattern is a literal of WithFuzzy.
+func (s *normalizer) pattern(pattern string, engine Engine, literal bool) (string, error) {
+	switch {
+	case literal || engine == EngineFixed:
+		return s.text(pattern), nil
+	case engine == EngineRegexp || engine == EngineAuto:
+		return s.regex(pattern)
+	default:
+		return s.escaped(pattern), nil
+	}
+}
+
+// regex normalizes the literals of the regex.
+func (s *normalizer) regex(pattern string) (string, error) {
+	flags := syntax.Perl
+	if s.fold {
+		flags |= syntax.FoldCase
+	}
+	re, err := syntax.Parse(pattern, flags)
+	if err != nil {
+		return "", err
+	}
+	if !s.literals(re) && !s.fold {
+		return pattern, nil // keep the literals for EngineAuto
+	}
+	return re.String(), nil
+}
+
+// literals normalizes the literals in the regex and returns true if any of them changes.
+func (s *normalizer) literals(re *syntax.Regexp) bool {
+	var changed bool
+	if re.Op == syntax.OpLiteral {
+		if x := s.text(string(re.Rune)); x != string(re.Rune) {
+			re.Rune = []rune(x)
+			changed = true
+		}
+		if s.fold {
+			re.Flags &^= syntax.FoldCase // the lines are folded too
+		}
+	}
+	for _, x := range re.Sub {
+		changed = s.literals(x) || changed
+	}
+	return changed
+}
+
+// escaped normalizes the pattern except the characters after \.
+func (s *normalizer) escaped(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		j := strings.IndexByte(pattern[i:], '\\')
+		if j < 0 {
+			b.WriteString(s.text(pattern[i:]))
+			break
+		}
+		b.WriteString(s.text(pattern[i : i+j]))
+		_, size := utf8.DecodeRuneInString(pattern[i+j+1:])
+		b.WriteString(pattern[i+j : i+j+1+size])
+		i += j + 1 + size
+	}
+	return b.String()
+}
+
+func (s *normalizer) text(line string) string { return s.transform(line, false).text }
+
+// normalizedText is a line transformed by the normalizer.
+type normalizedText struct {
+	text string
+	// The starts of the segments of the line transformed separately, in text and in the line,
+	// followed by the ends of them. nil if the offsets are the same as the line.
+	starts, origins []int
+}
+
+// start returns the offset in the line of the start of a match in the text,
+// the start of the segment containing it.
+func (s *normalizedText) start(i int) int {
+	if s.starts == nil {
+		return i
+	}
+	return s.origins[sort.SearchInts(s.starts, i+1)-1]
+}
+
+// end returns the offset in the line of the end of a match in the text,
+// the end of the segment containing it.
+func (s *normalizedText) end(i int) int {
+	if s.starts == nil {
+		return i
+	}
+	return s.origins[sort.SearchInts(s.starts, i)]
+}
+
+// transform normalizes the line, and maps the offsets if offsets is true.
+// The line is transformed by the segments, the runes with the combining marks following them,
+// so that a segment of the text is mapped to a segment of the line.
+func (s *normalizer) transform(line string, offsets bool) normalizedText {
+	if isASCII(line) {
+		if s.fold {
+			return normalizedText{text: strings.ToLower(line)}
+		}
+		return normalizedText{text: line}
+	}
+	if !s.fold && s.form.IsNormalString(line) {
+		return normalizedText{text: line}
+	}
+	var (
+		r       normalizedText
+		b       = make([]byte, 0, len(line))
+		aligned = true
+		folder  cases.Caser
+	)
+	if s.fold {
+		folder = cases.Fold()
+	}
+	for i := 0; i < len(line); {
+		n := s.segment(line[i:])
+		x := line[i : i+n]
+		if s.normalize {
+			x = s.form.String(x)
+		}
+		if s.fold {
+			x = folder.String(x)
+			if s.normalize {
+				x = s.form.String(x) // folding may decompose, e.g. İ into i and U+0307
+			}
+		}
+		if offsets {
+			r.starts = append(r.starts, len(b))
+			r.origins = append(r.origins, i)
+		}
+		aligned = aligned && len(x) == n
+		b = append(b, x...)
+		i += n
+	}
+	r.text = string(b)
+	if !offsets || aligned {
+		r.starts, r.origins = nil, nil
+	} else {
+		r.starts = append(r.starts, len(b))
+		r.origins = append(r.origins, len(line))
+	}
+	return r
+}
+
+// segment returns the size of the first segment of the line.
+func (s *normalizer) segment(line string) int {
+	if s.normalize {
+		if n := s.form.NextBoundaryInString(line, true); n > 0 {
+			return n
+		}
+	}
+	_, n := utf8.DecodeRuneInString(line)
+	return n
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// matcher returns the matcher of the normalized lines, m itself if the normalizer is nil.
+func (s *normalizer) matcher(m Matcher) Matcher {
+	if s == nil {
+		return m
+	}
+	return &normalizedMatcher{
+		matcher:    m,
+		normalizer: s,
+	}
+}
+
+// literalSet returns the matcher of the normalized literals of WithLiteralSet.
+func (s *normalizer) literalSet(literals []string) Matcher {
+	if s == nil {
+		return newLiteralSet(literals)
+	}
+	xs := make([]string, len(literals))
+	for i, x := range literals {
+		xs[i] = s.text(x)
+	}
+	return s.matcher(newLiteralSet(xs))
+}
+
+// normalizedMatcher matches the normalized lines and maps the matches to the lines.
+type normalizedMatcher struct {
+	matcher    Matcher
+	normalizer *normalizer
+}
+
+func (s *normalizedMatcher) MatchString(line string) bool {
+	return s.matcher.MatchString(s.normalizer.text(line))
+}
+
+func (s *normalizedMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	t := s.normalizer.transform(line, true)
+	r := s.matcher.FindAllStringSubmatchIndex(t.text, n)
+	if t.starts == nil {
+		return r
+	}
+	for _, x := range r {
+		for i := 0; i+1 < len(x); i += 2 {
+			if x[i] < 0 {
+				continue
+			}
+			if x[i] == x[i+1] {
+				x[i] = t.start(x[i])
+				x[i+1] = x[i]
+				continue
+			}
+			x[i], x[i+1] = t.start(x[i]), t.end(x[i+1])
+		}
+	}
+	return r
+}
@@ -0,0 +1,174 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithNormalization(t *testing.T) {
+	const (
+		composed   = "café au lait"
+		decomposed = "cafe\u0301 au lait"
+		ligature   = "ﬁle of ＡＢＣ"
+		german     = "Grüße aus der Straße"
+	)
+	source := strings.Join([]string{composed, decomposed, ligature, german, "STRASSE", "nothing"}, "\n") + "\n"
+
+	for _, tc := range []*struct {
+		title   string
+		opt     []gogrep.Option
+		regexes []string
+		want    []string
+	}{
+		{
+			title:   "no normalization",
+			regexes: []string{"café"},
+			want:    []string{composed},
+		},
+		{
+			title:   "nfc composed",
+			opt:     []gogrep.Option{gogrep.WithNormalization(gogrep.NormalizationNFC)},
+			regexes: []string{"café"},
+			want:    []string{composed, decomposed},
+		},
+		{
+			title:   "nfc decomposed",
+			opt:     []gogrep.Option{gogrep.WithNormalization(gogrep.NormalizationNFC)},
+			regexes: []string{"cafe\u0301 a+u"},
+			want:    []string{composed, decomposed},
+		},
+		{
+			title:   "nfc compatibility",
+			opt:     []gogrep.Option{gogrep.WithNormalization(gogrep.NormalizationNFC)},
+			regexes: []string{"file", "ABC"},
+		},
+		{
+			title:   "nfkc compatibility",
+			opt:     []gogrep.Option{gogrep.WithNormalization(gogrep.NormalizationNFKC)},
+			regexes: []string{"^file of ABC$"},
+			want:    []string{ligature},
+		},
+		{
+			title:   "nfkc fixed",
+			opt:     []gogrep.Option{gogrep.WithNormalization(gogrep.NormalizationNFKC), gogrep.WithEngine(gogrep.EngineFixed)},
+			regexes: []string{"ﬁle of ABC"},
+			want:    []string{ligature},
+		},
+		{
+			title:   "simple folding",
+			regexes: []string{"(?i)strasse"},
+			want:    []string{"STRASSE"},
+		},
+		{
+			title:   "full folding",
+			opt:     []gogrep.Option{gogrep.WithCaseFolding()},
+			regexes: []string{"strasse"},
+			want:    []string{german, "STRASSE"},
+		},
+		{
+			title:   "full folding of pattern",
+			opt:     []gogrep.Option{gogrep.WithCaseFolding()},
+			regexes: []string{"GRÜßE"},
+			want:    []string{german},
+		},
+		{
+			title:   "folding class",
+			opt:     []gogrep.Option{gogrep.WithCaseFolding()},
+			regexes: []string{"^[A-Z]+SSE$"},
+			want:    []string{"STRASSE"},
+		},
+		{
+			title:   "folding fixed",
+			opt:     []gogrep.Option{gogrep.WithCaseFolding(), gogrep.WithEngine(gogrep.EngineFixed)},
+			regexes: []string{"STRASSE"},
+			want:    []string{german, "STRASSE"},
+		},
+		{
+			title:   "folding auto",
+			opt:     []gogrep.Option{gogrep.WithCaseFolding(), gogrep.WithEngine(gogrep.EngineAuto)},
+			regexes: []string{"^STRASSE$"},
+			want:    []string{"STRASSE"},
+		},
+		{
+			title:   "folding glob",
+			opt:     []gogrep.Option{gogrep.WithCaseFolding(), gogrep.WithEngine(gogrep.EngineGlob)},
+			regexes: []string{"*STRASSE"},
+			want:    []string{german, "STRASSE"},
+		},
+		{
+			title:   "folding fuzzy",
+			opt:     []gogrep.Option{gogrep.WithCaseFolding(), gogrep.WithFuzzy(1)},
+			regexes: []string{"STRASE"},
+			want:    []string{german, "STRASSE"},
+		},
+		{
+			title:   "normalization and folding",
+			opt:     []gogrep.Option{gogrep.WithNormalization(gogrep.NormalizationNFKC), gogrep.WithCaseFolding()},
+			regexes: []string{"CAFÉ", "FILE OF abc"},
+			want:    []string{composed, decomposed, ligature},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			resultC, err := gogrep.New(tc.opt...).GrepMulti(context.TODO(), tc.regexes, strings.NewReader(source))
+			if !assert.Nil(t, err) {
+				return
+			}
+			got := []string{}
+			for r := range resultC {
+				assert.Nil(t, r.Err())
+				got = append(got, r.Text())
+			}
+			want := tc.want
+			if want == nil {
+				want = []string{}
+			}
+			assert.ElementsMatch(t, want, got)
+		})
+	}
+
+	t.Run("only matching", func(t *testing.T) {
+		resultC, err := gogrep.New(
+			gogrep.WithNormalization(gogrep.NormalizationNFC),
+			gogrep.WithCaseFolding(),
+			gogrep.WithOnlyMatching(),
+		).Grep(context.TODO(), "CAFÉ|(STRA)SSE", strings.NewReader("x "+decomposed+" y "+german+"\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var (
+			texts  []string
+			ranges [][2]int
+			groups []string
+		)
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			texts = append(texts, r.Text())
+			ranges = append(ranges, r.MatchRanges()...)
+			groups = append(groups, r.Submatches()[1])
+		}
+		assert.Equal(t, []string{"cafe\u0301", "Straße"}, texts)
+		assert.Equal(t, [][2]int{{2, 8}, {35, 42}}, ranges)
+		assert.Equal(t, []string{"", "Stra"}, groups)
+	})
+
+	t.Run("match ranges", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithCaseFolding()).
+			Grep(context.TODO(), "ss", strings.NewReader("ß and SS\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			assert.Equal(t, [][2]int{{0, 2}, {7, 9}}, r.MatchRanges())
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithNormalization("nfd")).Grep(context.TODO(), "x", strings.NewReader("x\n"))
+		assert.NotNil(t, err)
+	})
+}
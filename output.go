@@ -0,0 +1,112 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+	"regexp"
+)
+
+// outputGrepper is a Grepper with the options that process the results of each call,
+// WithUnique, WithSort, WithCompactResults, WithSink and WithSequence.
+type outputGrepper struct {
+	*grepper
+}
+
+func (s *outputGrepper) Grep(ctx context.Context, regex string, source io.Reader) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.Grep(ctx, regex, source)
+	})
+}
+
+func (s *outputGrepper) GrepMulti(ctx context.Context, regexes []string, source io.Reader) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.GrepMulti(ctx, regexes, source)
+	})
+}
+
+func (s *outputGrepper) GrepSources(ctx context.Context, regexes []string, sources []NamedSource) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.GrepSources(ctx, regexes, sources)
+	})
+}
+
+func (s *outputGrepper) GrepReaderAt(ctx context.Context, regexes []string, source SizedReaderAt) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.GrepReaderAt(ctx, regexes, source)
+	})
+}
+
+func (s *outputGrepper) GrepFile(ctx context.Context, regex string, path string) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.GrepFile(ctx, regex, path)
+	})
+}
+
+func (s *outputGrepper) GrepPatterns(ctx context.Context, patterns []Pattern, source io.Reader) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.GrepPatterns(ctx, patterns, source)
+	})
+}
+
+func (s *outputGrepper) GrepBytes(ctx context.Context, regex string, data []byte) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.GrepBytes(ctx, regex, data)
+	})
+}
+
+func (s *outputGrepper) GrepLines(ctx context.Context, regex string, lines []string) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.GrepLines(ctx, regex, lines)
+	})
+}
+
+func (s *outputGrepper) GrepRegexp(ctx context.Context, re *regexp.Regexp, source io.Reader) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.grepper.GrepRegexp(ctx, re, source)
+	})
+}
+
+func (s *outputGrepper) Compile(regexes ...string) (Session, error) {
+	x, err := s.grepper.Compile(regexes...)
+	if err != nil {
+		return nil, err
+	}
+	return &outputSession{
+		Session: x,
+		config:  s.config,
+	}, nil
+}
+
+type outputSession struct {
+	Session
+	config *Config
+}
+
+func (s *outputSession) Grep(ctx context.Context, source io.Reader) (<-chan Result, error) {
+	return output(ctx, s.config, func(ctx context.Context) (<-chan Result, error) {
+		return s.Session.Grep(ctx, source)
+	})
+}
+
+// output applies WithUnique, WithSort, WithCompactResults, WithSink and WithSequence to the results of the grep.
+func output(ctx context.Context, c *Config, grep func(context.Context) (<-chan Result, error)) (<-chan Result, error) {
+	if c.unique != "" {
+		grep = uniqueGrep(c, grep)
+	}
+	if c.sort != "" {
+		grep = sortGrep(c, grep)
+	}
+	if c.compactResults {
+		grep = compactGrep(c, grep)
+	}
+	if c.sink != nil {
+		sinkGrep := grep
+		grep = func(ctx context.Context) (<-chan Result, error) {
+			return writeSink(ctx, c, sinkGrep)
+		}
+	}
+	if c.sequence {
+		grep = sequenceGrep(c, grep)
+	}
+	return grep(ctx)
+}
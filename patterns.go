@@ -0,0 +1,195 @@
+package gogrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Pattern is a regex identified by ID for GrepPatterns.
+type Pattern struct {
+	// ID is reported by PatternID of the results the regex matches.
+	ID    string
+	Regex string
+}
+
+func (s *grepper) GrepPatterns(ctx context.Context, patterns []Pattern, source io.Reader) (<-chan Result, error) {
+	// Already canceled
+	if isDone(ctx) {
+		return nil, wrapErr(cancellationError(ctx), "Grepper")
+	}
+	r, err := s.compilePatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return s.grepMatcher(ctx, r, source)
+}
+
+// compilePatterns returns the matcher that matches if any pattern matches and identifies the patterns.
+func (s *grepper) compilePatterns(patterns []Pattern) (*patternMatcher, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("Grepper got no patterns")
+	}
+	var (
+		regexes = make([]string, len(patterns))
+		ids     = make([]string, len(patterns))
+		seen    = make(map[string]bool, len(patterns))
+	)
+	for i, p := range patterns {
+		if seen[p.ID] {
+			return nil, fmt.Errorf("Grepper got duplicate pattern ID %s", p.ID)
+		}
+		seen[p.ID] = true
+		regexes[i] = p.Regex
+		ids[i] = p.ID
+	}
+	matchers, err := s.compileRegexes(regexes)
+	if err != nil {
+		return nil, err
+	}
+	return &patternMatcher{
+		any:      s.combine(regexes, matchers),
+		matchers: matchers,
+		ids:      ids,
+	}, nil
+}
+
+// combine returns the matcher that matches if any regex matches.
+// The regexes of EngineRegexp are combined into an alternation to match a line at once
+// instead of each regex.
+func (s *grepper) combine(regexes []string, matchers multiMatcher) Matcher {
+	if len(matchers) == 1 {
+		return matchers[0]
+	}
+	if s.config.engine != EngineRegexp || s.config.fuzzy >= 0 || s.config.normalizer() != nil {
+		return matchers
+	}
+	groups := make([]string, len(regexes))
+	for i, regex := range regexes {
+		groups[i] = "(?:" + regex + ")"
+	}
+	re, err := regexp.Compile(strings.Join(groups, "|"))
+	if err != nil {
+		return matchers // compiled separately already
+	}
+	if s.config.noPrefilter {
+		return re
+	}
+	return withPrefilter(re)
+}
+
+// patternMatcher matches if any pattern matches, and finds the IDs of the patterns that match.
+type patternMatcher struct {
+	any      Matcher // matches if any of matchers matches
+	matchers multiMatcher
+	ids      []string
+}
+
+func (s *patternMatcher) MatchString(line string) bool { return s.any.MatchString(line) }
+
+func (s *patternMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	return s.matchers.FindAllStringSubmatchIndex(line, n)
+}
+
+// matchPatterns returns the IDs of the patterns that match the line in order of the patterns.
+func (s *patternMatcher) matchPatterns(line string) []string {
+	var ids []string
+	for i, m := range s.matchers {
+		if m.MatchString(line) {
+			ids = append(ids, s.ids[i])
+		}
+	}
+	return ids
+}
+
+// findAllPatterns returns the matches as FindAllStringSubmatchIndex does
+// and the IDs of the patterns that find each match.
+func (s *patternMatcher) findAllPatterns(line string, n int) ([][]int, [][]string) {
+	matches, found := findAllMatchers(s.matchers, line, n)
+	ids := make([][]string, len(found))
+	for i, xs := range found {
+		ids[i] = make([]string, len(xs))
+		for j, x := range xs {
+			ids[i][j] = s.ids[x]
+		}
+	}
+	return matches, ids
+}
+
+// findAllPatterns returns the matches of the matcher and the IDs of the patterns that find them,
+// nil IDs unless the matcher is from GrepPatterns.
+func findAllPatterns(m Matcher, line string) ([][]int, [][]string) {
+	if x, ok := m.(*patternMatcher); ok {
+		return x.findAllPatterns(line, -1)
+	}
+	return m.FindAllStringSubmatchIndex(line, -1), nil
+}
+
+// nonEmptyMatches drops the zero-width matches that print nothing as grep -o,
+// and the IDs of the patterns of them if not nil.
+func nonEmptyMatches(matches [][]int, patterns [][]string) ([][]int, [][]string) {
+	var (
+		r   = matches[:0:0]
+		ids [][]string
+	)
+	for i, m := range matches {
+		if m[0] == m[1] {
+			continue
+		}
+		r = append(r, m)
+		if patterns != nil {
+			ids = append(ids, patterns[i])
+		}
+	}
+	return r, ids
+}
+
+// findAllMatchers returns the non-overlapping matches of all matchers in order of position
+// and the indexes of the matchers that find each match.
+// The longer match wins at the same position.
+func findAllMatchers(ms []Matcher, line string, n int) ([][]int, [][]int) {
+	type found struct {
+		index   []int
+		matcher int
+	}
+	var all []found
+	for i, m := range ms {
+		for _, x := range m.FindAllStringSubmatchIndex(line, -1) {
+			all = append(all, found{index: x, matcher: i})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].index[0] != all[j].index[0] {
+			return all[i].index[0] < all[j].index[0]
+		}
+		return all[i].index[1] > all[j].index[1]
+	})
+	var (
+		r        [][]int
+		matchers [][]int
+		end      = -1
+	)
+	for _, x := range all {
+		if k := len(r) - 1; k >= 0 && x.index[0] == r[k][0] && x.index[1] == r[k][1] {
+			matchers[k] = append(matchers[k], x.matcher) // the same match
+			continue
+		}
+		if n >= 0 && len(r) >= n {
+			break
+		}
+		if x.index[0] < end {
+			continue // overlapping
+		}
+		r = append(r, x.index)
+		matchers = append(matchers, []int{x.matcher})
+		end = x.index[1]
+		if x.index[0] == x.index[1] {
+			end++
+		}
+	}
+	return r, matchers
+}
@@ -0,0 +1,148 @@
+package gogrep_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrepPatterns(t *testing.T) {
+	const source = "GET /index\nPOST /login failed\nGET /login\nDELETE /index\n"
+	patterns := []gogrep.Pattern{
+		{ID: "read", Regex: `^GET `},
+		{ID: "login", Regex: `/login`},
+		{ID: "failure", Regex: `(?i)FAILED`},
+	}
+
+	type patternResult struct {
+		line int
+		text string
+		ids  []string
+	}
+	collect := func(t *testing.T, resultC <-chan gogrep.Result) []patternResult {
+		var got []patternResult
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, patternResult{
+				line: r.Line(),
+				text: r.Text(),
+				ids:  r.PatternID(),
+			})
+		}
+		sort.SliceStable(got, func(i, j int) bool { return got[i].line < got[j].line })
+		return got
+	}
+
+	for _, tc := range []struct {
+		title string
+		opt   []gogrep.Option
+	}{
+		{
+			title: "regexp",
+		},
+		{
+			title: "without prefilter",
+			opt:   []gogrep.Option{gogrep.WithoutPrefilter()},
+		},
+		{
+			title: "count matches",
+			opt:   []gogrep.Option{gogrep.WithCountMatches()},
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			resultC, err := gogrep.New(tc.opt...).GrepPatterns(context.TODO(), patterns, strings.NewReader(source))
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, []patternResult{
+				{line: 1, text: "GET /index", ids: []string{"read"}},
+				{line: 2, text: "POST /login failed", ids: []string{"login", "failure"}},
+				{line: 3, text: "GET /login", ids: []string{"read", "login"}},
+			}, collect(t, resultC))
+		})
+	}
+
+	t.Run("only matching", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithOnlyMatching(), gogrep.WithEngine(gogrep.EngineFixed)).GrepPatterns(context.TODO(), []gogrep.Pattern{
+			{ID: "a", Regex: "login"},
+			{ID: "b", Regex: "/"},
+			{ID: "c", Regex: "login"},
+		}, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		got := collect(t, resultC)
+		assert.Contains(t, got, patternResult{line: 2, text: "/", ids: []string{"b"}})
+		assert.Contains(t, got, patternResult{line: 2, text: "login", ids: []string{"a", "c"}})
+		assert.Equal(t, 6, len(got))
+	})
+
+	t.Run("multiline", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithMultiline()).GrepPatterns(context.TODO(), []gogrep.Pattern{
+			{ID: "retry", Regex: `failed\nGET`},
+			{ID: "delete", Regex: `DELETE`},
+		}, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []patternResult{
+			{line: 2, text: "POST /login failed\nGET /login", ids: []string{"retry"}},
+			{line: 4, text: "DELETE /index", ids: []string{"delete"}},
+		}, collect(t, resultC))
+	})
+
+	t.Run("other greps", func(t *testing.T) {
+		resultC, err := gogrep.New().Grep(context.TODO(), "GET", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		for r := range resultC {
+			assert.Nil(t, r.PatternID())
+		}
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithSourceTag("tag")).GrepPatterns(context.TODO(), patterns, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		for r := range resultC {
+			assert.Equal(t, "tag", r.Tag())
+			assert.NotEmpty(t, r.PatternID())
+			assert.Equal(t, r.PatternID(), gogrep.CloneResult(r).PatternID())
+		}
+	})
+
+	for _, tc := range []struct {
+		title    string
+		patterns []gogrep.Pattern
+	}{
+		{
+			title: "no patterns",
+		},
+		{
+			title: "duplicate ID",
+			patterns: []gogrep.Pattern{
+				{ID: "a", Regex: "x"},
+				{ID: "a", Regex: "y"},
+			},
+		},
+		{
+			title: "invalid regex",
+			patterns: []gogrep.Pattern{
+				{ID: "a", Regex: "("},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			_, err := gogrep.New().GrepPatterns(context.TODO(), tc.patterns, strings.NewReader(source))
+			assert.NotNil(t, err)
+		})
+	}
+}
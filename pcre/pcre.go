@@ -0,0 +1,86 @@
+// Package pcre provides the gogrep engine of the Perl compatible regexes by github.com/dlclark/regexp2,
+// the backreferences like (\w+) \1 and the lookarounds like foo(?!bar) that RE2 lacks.
+//
+// Import the package for the side effect to register the engine:
+//
+//	import _ "github.com/berquerant/gogrep/pcre"
+//
+//	gogrep.New(gogrep.WithEngine(pcre.Engine))
+//
+// The regexes are matched by backtracking, so they may take exponential time on some lines unlike RE2.
+package pcre
+
+import (
+	"unicode/utf8"
+
+	"github.com/berquerant/gogrep"
+	"github.com/dlclark/regexp2"
+)
+
+// Engine is the name of the engine.
+const Engine gogrep.Engine = "pcre"
+
+func init() {
+	gogrep.RegisterEngine(Engine, func(pattern string) (gogrep.Matcher, error) {
+		return Compile(pattern)
+	})
+}
+
+// Matcher is the gogrep.Matcher by regexp2.
+type Matcher struct {
+	re *regexp2.Regexp
+}
+
+// Compile compiles the Perl compatible regex.
+func Compile(pattern string) (*Matcher, error) {
+	re, err := regexp2.Compile(pattern, regexp2.None)
+	if err != nil {
+		return nil, err
+	}
+	return &Matcher{
+		re: re,
+	}, nil
+}
+
+func (s *Matcher) String() string { return s.re.String() }
+
+// MatchString reports whether the line contains any match.
+// The errors of regexp2 are the timeouts, not set by Compile, so they are unmatched.
+func (s *Matcher) MatchString(line string) bool {
+	ok, err := s.re.MatchString(line)
+	return ok && err == nil
+}
+
+// FindAllStringSubmatchIndex returns the successive matches and the groups as regexp does,
+// in the byte offsets converted from the rune offsets of regexp2.
+func (s *Matcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	var (
+		r       [][]int
+		offsets []int // the byte offsets of the runes and the end
+	)
+	m, err := s.re.FindStringMatch(line)
+	for ; m != nil && err == nil && (n < 0 || len(r) < n); m, err = s.re.FindNextMatch(m) {
+		if offsets == nil {
+			offsets = runeOffsets(line)
+		}
+		groups := m.Groups()
+		x := make([]int, 2*len(groups))
+		for i, g := range groups {
+			if len(g.Captures) == 0 {
+				x[2*i], x[2*i+1] = -1, -1
+				continue
+			}
+			x[2*i], x[2*i+1] = offsets[g.Index], offsets[g.Index+g.Length]
+		}
+		r = append(r, x)
+	}
+	return r
+}
+
+func runeOffsets(s string) []int {
+	r := make([]int, 0, utf8.RuneCountInString(s)+1)
+	for i := range s {
+		r = append(r, i)
+	}
+	return append(r, len(s))
+}
@@ -0,0 +1,65 @@
+package pcre_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/berquerant/gogrep/pcre"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine(t *testing.T) {
+	grep := func(t *testing.T, regex, source string, opt ...gogrep.Option) []string {
+		resultC, err := gogrep.New(append(opt, gogrep.WithEngine(pcre.Engine))...).
+			Grep(context.TODO(), regex, strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		var texts []string
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			texts = append(texts, r.Text())
+		}
+		sort.Strings(texts)
+		return texts
+	}
+
+	t.Run("backreference", func(t *testing.T) {
+		assert.Equal(t, []string{"it is is", "the the end"}, grep(t, `\b(\w+) \1\b`, "the the end\nno repeat\nit is is\n"))
+	})
+
+	t.Run("lookaround", func(t *testing.T) {
+		assert.Equal(t, []string{"foobaz"}, grep(t, `foo(?!bar)`, "foobar\nfoobaz\n"))
+		assert.Equal(t, []string{"1000 EUR"}, grep(t, `(?<!\$)\b\d+\b`, "$1000\n1000 EUR\n"))
+	})
+
+	t.Run("only matching", func(t *testing.T) {
+		assert.Equal(t, []string{"ßß", "éé"}, grep(t, `(\p{L})\1`, "aéé ßß b\n", gogrep.WithOnlyMatching()))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithEngine(pcre.Engine)).Grep(context.TODO(), `(unclosed`, strings.NewReader(""))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestMatcher(t *testing.T) {
+	m, err := pcre.Compile(`(ä)(x)?(?=b)`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	line := "äb äc äb"
+	assert.True(t, m.MatchString(line))
+	assert.Equal(t, [][]int{{0, 2, 0, 2, -1, -1}, {8, 10, 8, 10, -1, -1}}, m.FindAllStringSubmatchIndex(line, -1))
+	assert.Equal(t, [][]int{{0, 2, 0, 2, -1, -1}}, m.FindAllStringSubmatchIndex(line, 1))
+	assert.Nil(t, m.FindAllStringSubmatchIndex("no", -1))
+
+	empty, err := pcre.Compile(`x*`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, [][]int{{0, 0}, {2, 3}, {3, 3}}, empty.FindAllStringSubmatchIndex("éx", -1))
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import "sync"
+
+// markTracker finds the first record after the chunks matched in order of the chunks for MarkObserver.
+type markTracker struct {
+	observer MarkObserver
+	mux      sync.Mutex
+	pending  []*markChunk           // the chunks dispatched and not marked yet, in order
+	chunks   map[*Record]*markChunk // the chunks being matched by their first records
+	advanced bool                   // the chunks are matched since the last mark, but the next chunk is not dispatched yet
+}
+
+type markChunk struct {
+	first   Record
+	matched bool
+}
+
+// newMarkTracker returns the tracker if the observer is a MarkObserver, nil otherwise.
+func newMarkTracker(observer Observer) *markTracker {
+	o, ok := observer.(MarkObserver)
+	if !ok {
+		return nil
+	}
+	return &markTracker{
+		observer: o,
+		chunks:   map[*Record]*markChunk{},
+	}
+}
+
+// dispatched registers the chunk before it is sent to the workers, nil-safe.
+func (s *markTracker) dispatched(chunk []Record) {
+	if s == nil || len(chunk) == 0 {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c := &markChunk{first: chunk[0]}
+	s.chunks[&chunk[0]] = c
+	s.pending = append(s.pending, c)
+	if s.advanced {
+		s.advanced = false
+		s.observer.ObserveMark(c.first)
+	}
+}
+
+// matched marks the chunk matched and observes the first record of the chunk not matched
+// if the chunks before it are all matched, nil-safe.
+func (s *markTracker) matched(chunk []Record) {
+	if s == nil || len(chunk) == 0 {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c, ok := s.chunks[&chunk[0]]
+	if !ok {
+		return
+	}
+	delete(s.chunks, &chunk[0])
+	c.matched = true
+	var n int
+	for n < len(s.pending) && s.pending[n].matched {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	s.pending = s.pending[n:]
+	if len(s.pending) == 0 {
+		s.advanced = true
+		return
+	}
+	s.observer.ObserveMark(s.pending[0].first)
+}
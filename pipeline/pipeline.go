@@ -0,0 +1,606 @@
+// Package pipeline runs a grep as the stages:
+//
+//	Source → Splitter → Filter → Matcher → Transformer → Sink
+//
+// The splitter and the filters run in order of the source in a goroutine,
+// and the matcher, the transformers and the sink run in the workers.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrStop stops reading the source without errors when returned by a Filter.
+var ErrStop = errors.New("pipeline stop")
+
+// PanicError is a panic of a stage recovered by Run.
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack trace of the goroutine that panicked
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("pipeline panic: %v", e.Value) }
+
+// Unwrap returns the value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// panicHandler keeps the first panic of the stages and stops the pipeline.
+type panicHandler struct {
+	cancel context.CancelFunc
+	mux    sync.Mutex
+	err    *PanicError
+}
+
+// recover should be deferred by the stages.
+func (h *panicHandler) recover() {
+	x := recover()
+	if x == nil {
+		return
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if h.err == nil {
+		h.err = &PanicError{
+			Value: x,
+			Stack: debug.Stack(),
+		}
+	}
+	h.cancel()
+}
+
+// getErr returns the panic recovered, nil if no panics.
+func (h *panicHandler) getErr() error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if h.err == nil {
+		return nil
+	}
+	return h.err
+}
+
+// Record is a unit of the source, a line by default.
+type Record struct {
+	Text   string // original text
+	View   string // text to be matched, the same length as Text
+	Number int    // 1-based record number
+	Offset int64  // byte offset of the beginning of the record in the source
+}
+
+// Item is an output of a Matcher.
+type Item interface{}
+
+type (
+	// Splitter splits the source into the records and calls emit in order.
+	// Splitting should stop if emit returns an error, and the error should be returned.
+	Splitter interface {
+		Split(source io.Reader, emit func(Record) error) error
+	}
+	// Filter rewrites the view of the record or drops the record by returning false.
+	// Filters are called in order of the source, so they can keep states across the records.
+	Filter interface {
+		Filter(r *Record) (bool, error)
+	}
+	// Matcher selects the records of a chunk and emits the items made from them.
+	// Matchers are called concurrently by the workers unless Pipeline.Ordered.
+	Matcher interface {
+		Match(chunk []Record, emit func(Item))
+	}
+	// ContextMatcher is implemented by the Matchers that stop matching a chunk when the context is canceled,
+	// e.g. by checking the context per record, so that a canceled Run does not wait for the chunks being matched.
+	// MatchContext is called instead of Match.
+	ContextMatcher interface {
+		MatchContext(ctx context.Context, chunk []Record, emit func(Item))
+	}
+	// Flusher is implemented by the Matchers that emit the items after the last chunk.
+	Flusher interface {
+		Flush(emit func(Item))
+	}
+	// Transformer rewrites the item or drops the item by returning false.
+	Transformer interface {
+		Transform(item Item) (Item, bool)
+	}
+	// Sink receives the items.
+	// Put is called concurrently by the workers.
+	Sink interface {
+		Put(item Item)
+	}
+	// Observer receives the time that the workers spent matching the chunks.
+	// ObserveChunk is called concurrently by the workers with the 0-based index of the worker.
+	Observer interface {
+		ObserveChunk(worker int, chunk []Record, elapsed time.Duration)
+	}
+	// DropObserver is implemented by the Observers that receive the chunks dropped without matching
+	// since the context was canceled before the workers took them.
+	// ObserveDrop is called concurrently by the workers.
+	DropObserver interface {
+		ObserveDrop(chunk []Record)
+	}
+	// DispatchObserver is implemented by the Observers that receive the chunks sent to the workers,
+	// with the bytes of the texts and the time blocked until a worker or MaxPendingRecords accepted the chunk.
+	// ObserveDispatch is called in order of the chunks.
+	DispatchObserver interface {
+		ObserveDispatch(records, bytes int, blocked time.Duration)
+	}
+	// MarkObserver is implemented by the Observers that receive the progress of the source in order, e.g. to checkpoint it.
+	// ObserveMark is called with the first record of a chunk after the chunks before it are matched
+	// and their items are put into the sink, in order of the records and at most once for a chunk.
+	// The chunks matched while the context is canceled do not advance the marks.
+	MarkObserver interface {
+		ObserveMark(r Record)
+	}
+	// WorkerObserver is implemented by the Observers that receive the start and the end of the workers started by Run.
+	// The workers of the Pool are not observed.
+	WorkerObserver interface {
+		ObserveStart(worker int)
+		ObserveEnd(worker int)
+	}
+)
+
+type (
+	// SplitterFunc is a function as a Splitter.
+	SplitterFunc func(source io.Reader, emit func(Record) error) error
+	// FilterFunc is a function as a Filter.
+	FilterFunc func(r *Record) (bool, error)
+	// MatcherFunc is a function as a Matcher.
+	MatcherFunc func(chunk []Record, emit func(Item))
+	// TransformerFunc is a function as a Transformer.
+	TransformerFunc func(item Item) (Item, bool)
+	// SinkFunc is a function as a Sink.
+	SinkFunc func(item Item)
+	// ObserverFunc is a function as an Observer.
+	ObserverFunc func(worker int, chunk []Record, elapsed time.Duration)
+)
+
+func (f SplitterFunc) Split(source io.Reader, emit func(Record) error) error { return f(source, emit) }
+func (f FilterFunc) Filter(r *Record) (bool, error)                          { return f(r) }
+func (f MatcherFunc) Match(chunk []Record, emit func(Item))                  { f(chunk, emit) }
+func (f TransformerFunc) Transform(item Item) (Item, bool)                   { return f(item) }
+func (f SinkFunc) Put(item Item)                                             { f(item) }
+func (f ObserverFunc) ObserveChunk(worker int, chunk []Record, elapsed time.Duration) {
+	f(worker, chunk, elapsed)
+}
+
+// Pipeline is the composition of the stages.
+type Pipeline struct {
+	Splitter     Splitter
+	Filters      []Filter
+	Matcher      Matcher
+	Transformers []Transformer
+	Sink         Sink
+	// Workers is the number of the goroutines that run the matcher. Default is 1.
+	Workers int
+	// ChunkSize is the number of the records sent to a worker at once. Default is 100.
+	ChunkSize int
+	// MaxChunkBytes sends the chunk to a worker once the texts of the records reach the bytes
+	// before ChunkSize records if positive.
+	MaxChunkBytes int
+	// MaxChunkDelay sends the chunk to a worker once the duration elapses since the first record of the chunk
+	// before ChunkSize records if positive, not to keep the records of a slow source waiting.
+	MaxChunkDelay time.Duration
+	// Ordered makes a single worker receive the chunks in order regardless of Workers.
+	Ordered bool
+	// RequestBufferSize is the number of the chunks buffered for the workers. Default is twice the workers.
+	RequestBufferSize int
+	// MaxPendingRecords bounds the records sent to the workers and not matched yet if positive,
+	// by blocking the splitter until the workers match the chunks, e.g. while the sink blocks.
+	// The chunks are sent at the number of the records at most.
+	MaxPendingRecords int
+	// Observer observes the workers if not nil.
+	Observer Observer
+	// AutoTune adjusts the number of the records of the chunks by the lengths of the records,
+	// starting from ChunkSize, and the workers that take the chunks up to Workers
+	// by the time to match the chunks and the rate of the records that emit the items.
+	AutoTune bool
+	// ReuseChunks makes the chunks reused after Match returns to cut the allocations.
+	// The matcher must not retain the chunk.
+	ReuseChunks bool
+	// Pool runs the matcher instead of the goroutines started by Run if not nil.
+	// Workers and RequestBufferSize are ignored, and the chunks are matched in any order by the workers of the pool.
+	// Ordered pipelines do not use the pool.
+	Pool *Pool
+}
+
+const defaultChunkSize = 100
+
+// Run runs the pipeline until the source is exhausted or the context is canceled.
+// Returns the error of the context if canceled, or the error from the splitter or the filters otherwise.
+// A panic of a stage stops the pipeline and is returned as a *PanicError.
+// Run returns after all the items are put into the sink.
+func (p *Pipeline) Run(ctx context.Context, source io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		workers   = p.Workers
+		chunkSize = p.ChunkSize
+		panics    = &panicHandler{cancel: cancel}
+	)
+	if workers < 1 || p.Ordered {
+		workers = 1
+	}
+	if chunkSize < 1 {
+		chunkSize = defaultChunkSize
+	}
+	var (
+		budget   recordBudget
+		tune     *tuner
+		maxChunk = tuneMaxChunk
+		marks    = newMarkTracker(p.Observer)
+	)
+	if p.MaxPendingRecords > 0 {
+		budget = make(recordBudget, p.MaxPendingRecords)
+		chunkSize = min(chunkSize, p.MaxPendingRecords)
+		maxChunk = p.MaxPendingRecords
+	}
+	if p.AutoTune {
+		tune = newTuner(workers, maxChunk)
+	}
+	var (
+		dispatch func([]Record)
+		finish   func()
+	)
+	if p.Pool != nil && !p.Ordered {
+		dispatch, finish = p.pooled(ctx, panics, budget, tune, marks)
+	} else {
+		dispatch, finish = p.spawn(ctx, workers, panics, budget, tune, marks)
+	}
+
+	c := &chunker{
+		pipeline: p,
+		size:     chunkSize,
+		dispatch: budget.bound(dispatch),
+		tuner:    tune,
+		marks:    marks,
+		buf:      p.newChunk(chunkSize),
+	}
+	err := p.split(source, panics, func(r Record) error {
+		for _, f := range p.Filters {
+			keep, err := f.Filter(&r)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				return nil
+			}
+		}
+		return c.add(ctx, r)
+	})
+	if errors.Is(err, ErrStop) {
+		err = nil
+	}
+	canceled := isDone(ctx)
+	c.close(!canceled)
+	finish() // Results from workers are exhausted
+	if err := panics.getErr(); err != nil {
+		return err
+	}
+	if canceled || isDone(ctx) {
+		// Canceled while matching the last chunks
+		return ctx.Err()
+	}
+	return err
+}
+
+// split runs the splitter and the filters recovering the panic.
+func (p *Pipeline) split(source io.Reader, panics *panicHandler, emit func(Record) error) error {
+	defer panics.recover()
+	return p.Splitter.Split(source, emit)
+}
+
+// chunker collects the records into the chunks and sends them to the workers.
+// The chunk is sent by the timer of MaxChunkDelay too, so the fields are guarded by the mutex.
+type chunker struct {
+	pipeline *Pipeline
+	size     int
+	dispatch func([]Record)
+	tuner    *tuner       // resizes the chunks if not nil
+	marks    *markTracker // registers the chunks if not nil
+
+	mux    sync.Mutex
+	buf    []Record
+	bytes  int
+	timer  *time.Timer
+	gen    int // the generation of the chunk, to ignore the timers of the chunks already sent
+	closed bool
+}
+
+func (s *chunker) add(ctx context.Context, r Record) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.buf = append(s.buf, r)
+	s.bytes += len(r.Text)
+	if len(s.buf) < s.size && (s.pipeline.MaxChunkBytes < 1 || s.bytes < s.pipeline.MaxChunkBytes) {
+		if d := s.pipeline.MaxChunkDelay; d > 0 && s.timer == nil {
+			gen := s.gen
+			s.timer = time.AfterFunc(d, func() { s.expire(ctx, gen) })
+		}
+		return nil
+	}
+	if isDone(ctx) {
+		return ctx.Err()
+	}
+	s.flush()
+	return nil
+}
+
+// expire sends the chunk of the generation if it is not sent yet.
+func (s *chunker) expire(ctx context.Context, gen int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed || s.gen != gen || len(s.buf) == 0 || isDone(ctx) {
+		return
+	}
+	s.flush()
+}
+
+// flush sends the chunk to the workers and starts the next chunk.
+// The mutex should be locked.
+func (s *chunker) flush() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.marks.dispatched(s.buf)
+	o, observed := s.pipeline.Observer.(DispatchObserver)
+	if s.tuner == nil && !observed {
+		s.dispatch(s.buf)
+	} else {
+		records, bytes, start := len(s.buf), s.bytes, time.Now()
+		s.dispatch(s.buf)
+		blocked := time.Since(start)
+		if observed {
+			o.ObserveDispatch(records, bytes, blocked)
+		}
+		if s.tuner != nil {
+			s.size = s.tuner.sent(records, bytes, blocked)
+		}
+	}
+	s.buf = s.pipeline.newChunk(s.size)
+	s.bytes = 0
+	s.gen++
+}
+
+// close sends the last chunk if send and stops the timer.
+func (s *chunker) close(send bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if send && len(s.buf) > 0 {
+		s.flush()
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.closed = true
+}
+
+// spawn starts the workers of the pipeline.
+// Returns the function to send a chunk to the workers and the function to wait for the workers after the last chunk.
+// The workers wait while the tuner does not make them active if not nil.
+func (p *Pipeline) spawn(ctx context.Context, workers int, panics *panicHandler, budget recordBudget, tune *tuner, marks *markTracker) (func([]Record), func()) {
+	requestBufferSize := p.RequestBufferSize
+	if requestBufferSize < 1 {
+		requestBufferSize = workers * 2
+	}
+	var (
+		wg       sync.WaitGroup
+		requestC = make(chan []Record, requestBufferSize)
+	)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(worker int) {
+			defer wg.Done()
+			if o, ok := p.Observer.(WorkerObserver); ok {
+				o.ObserveStart(worker)
+				defer o.ObserveEnd(worker)
+			}
+			p.work(ctx, worker, requestC, panics, budget, tune, marks)
+		}(i)
+	}
+	return func(chunk []Record) { requestC <- chunk }, func() {
+		close(requestC) // Requests are exhausted
+		tune.close()    // The rest of the requests are taken by any worker
+		wg.Wait()
+	}
+}
+
+// pooled sends the chunks to the pool.
+func (p *Pipeline) pooled(ctx context.Context, panics *panicHandler, budget recordBudget, tune *tuner, marks *markTracker) (func([]Record), func()) {
+	var (
+		wg   sync.WaitGroup
+		emit = p.emitter()
+	)
+	return func(chunk []Record) {
+			wg.Add(1)
+			p.Pool.taskC <- func(worker int) {
+				defer wg.Done()
+				p.match(ctx, worker, chunk, emit, panics, budget, tune, marks)
+			}
+		}, func() {
+			wg.Wait()
+			p.flush(ctx, emit, panics)
+		}
+}
+
+func (p *Pipeline) work(ctx context.Context, worker int, requestC <-chan []Record, panics *panicHandler, budget recordBudget, tune *tuner, marks *markTracker) {
+	emit := p.emitter()
+	for {
+		tune.wait(worker)
+		chunk, ok := <-requestC
+		if !ok {
+			break
+		}
+		p.match(ctx, worker, chunk, emit, panics, budget, tune, marks)
+	}
+	p.flush(ctx, emit, panics)
+}
+
+// emitter returns the function that puts the item transformed into the sink.
+func (p *Pipeline) emitter() func(Item) {
+	return func(item Item) {
+		for _, t := range p.Transformers {
+			var ok bool
+			if item, ok = t.Transform(item); !ok {
+				return
+			}
+		}
+		p.Sink.Put(item)
+	}
+}
+
+func (p *Pipeline) match(ctx context.Context, worker int, chunk []Record, emit func(Item), panics *panicHandler, budget recordBudget, tune *tuner, marks *markTracker) {
+	defer budget.release(len(chunk))
+	defer p.releaseChunk(chunk)
+	defer panics.recover()
+	if isDone(ctx) {
+		// drain
+		if o, ok := p.Observer.(DropObserver); ok {
+			o.ObserveDrop(chunk)
+		}
+		return
+	}
+	if p.Observer == nil && tune == nil {
+		p.matchChunk(ctx, chunk, emit)
+		return
+	}
+	var items int
+	if tune != nil {
+		e := emit
+		emit = func(item Item) {
+			items++
+			e(item)
+		}
+	}
+	start := time.Now()
+	p.matchChunk(ctx, chunk, emit)
+	elapsed := time.Since(start)
+	if !isDone(ctx) {
+		marks.matched(chunk) // the chunk is not matched partially
+	}
+	if p.Observer != nil {
+		p.Observer.ObserveChunk(worker, chunk, elapsed)
+	}
+	if tune != nil {
+		tune.observe(len(chunk), items, elapsed)
+	}
+}
+
+func (p *Pipeline) matchChunk(ctx context.Context, chunk []Record, emit func(Item)) {
+	if m, ok := p.Matcher.(ContextMatcher); ok {
+		m.MatchContext(ctx, chunk, emit)
+		return
+	}
+	p.Matcher.Match(chunk, emit)
+}
+
+func (p *Pipeline) flush(ctx context.Context, emit func(Item), panics *panicHandler) {
+	defer panics.recover()
+	if f, ok := p.Matcher.(Flusher); ok && !isDone(ctx) {
+		f.Flush(emit)
+	}
+}
+
+// recordBudget is the tokens of the records pending in the workers by MaxPendingRecords, nil if unbounded.
+// The chunks are acquired by the chunker alone, so the partial acquisition waits only for the chunks being matched.
+type recordBudget chan struct{}
+
+// bound returns the dispatch that waits for the tokens of the chunk.
+func (b recordBudget) bound(dispatch func([]Record)) func([]Record) {
+	if b == nil {
+		return dispatch
+	}
+	return func(chunk []Record) {
+		for range chunk {
+			b <- struct{}{}
+		}
+		dispatch(chunk)
+	}
+}
+
+func (b recordBudget) release(n int) {
+	if b == nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		<-b
+	}
+}
+
+// Pool is the workers shared by the pipelines not to start the goroutines for each Run.
+type Pool struct {
+	workers int
+	taskC   chan func(worker int)
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewPool starts the workers. Default is 1.
+func NewPool(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{
+		workers: workers,
+		taskC:   make(chan func(worker int), workers*2),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(worker int) {
+			defer p.wg.Done()
+			for task := range p.taskC {
+				task(worker)
+			}
+		}(i)
+	}
+	return p
+}
+
+// Workers returns the number of the workers.
+func (p *Pool) Workers() int { return p.workers }
+
+// Close stops the workers after the chunks sent.
+// The pipelines should not Run with the pool after Close.
+func (p *Pool) Close() {
+	p.once.Do(func() {
+		close(p.taskC)
+	})
+	p.wg.Wait()
+}
+
+// chunkPool is the pool of the chunks of the pipelines with ReuseChunks.
+var chunkPool sync.Pool
+
+func (p *Pipeline) newChunk(size int) []Record {
+	if p.ReuseChunks {
+		if c, ok := chunkPool.Get().(*[]Record); ok && cap(*c) >= size {
+			return (*c)[:0]
+		}
+	}
+	return make([]Record, 0, size)
+}
+
+func (p *Pipeline) releaseChunk(chunk []Record) {
+	if !p.ReuseChunks {
+		return
+	}
+	clear(chunk) // not to keep the texts alive
+	chunk = chunk[:0]
+	chunkPool.Put(&chunk)
+}
+
+// isDone returns true if context has already canceled.
+func isDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
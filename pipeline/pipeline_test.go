@@ -0,0 +1,538 @@
+package pipeline_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+// lines splits the source into lines.
+var lines = pipeline.SplitterFunc(func(source io.Reader, emit func(pipeline.Record) error) error {
+	sc := bufio.NewScanner(source)
+	var n int
+	for sc.Scan() {
+		n++
+		if err := emit(pipeline.Record{
+			Text:   sc.Text(),
+			View:   sc.Text(),
+			Number: n,
+		}); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+})
+
+// contains emits the texts of the records whose views contain the substring.
+func contains(substr string) pipeline.Matcher {
+	return pipeline.MatcherFunc(func(chunk []pipeline.Record, emit func(pipeline.Item)) {
+		for _, r := range chunk {
+			if strings.Contains(r.View, substr) {
+				emit(r.Text)
+			}
+		}
+	})
+}
+
+// eventObserver records the dispatches and the starts and the ends of the workers.
+type eventObserver struct {
+	mux    sync.Mutex
+	events []string
+}
+
+func (*eventObserver) ObserveChunk(int, []pipeline.Record, time.Duration) {}
+
+func (s *eventObserver) add(format string, v ...interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.events = append(s.events, fmt.Sprintf(format, v...))
+}
+
+func (s *eventObserver) ObserveDispatch(records, bytes int, _ time.Duration) {
+	s.add("dispatch %d %d", records, bytes)
+}
+func (s *eventObserver) ObserveStart(worker int) { s.add("start %d", worker) }
+func (s *eventObserver) ObserveEnd(worker int)   { s.add("end %d", worker) }
+
+// markObserver checks that the records before the marks are sunk.
+type markObserver struct {
+	mux   sync.Mutex
+	sunk  map[int]bool
+	marks []int
+	errs  []string
+}
+
+func (*markObserver) ObserveChunk(int, []pipeline.Record, time.Duration) {}
+
+func (s *markObserver) put(item pipeline.Item) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.sunk[item.(int)] = true
+}
+
+func (s *markObserver) ObserveMark(r pipeline.Record) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.marks = append(s.marks, r.Number)
+	for i := 1; i < r.Number; i++ {
+		if !s.sunk[i] {
+			s.errs = append(s.errs, fmt.Sprintf("mark %d before %d is sunk", r.Number, i))
+			return
+		}
+	}
+}
+
+type collector struct {
+	mux   sync.Mutex
+	items []string
+}
+
+func (s *collector) Put(item pipeline.Item) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.items = append(s.items, item.(string))
+}
+
+func (s *collector) sorted() []string {
+	sort.Strings(s.items)
+	return s.items
+}
+
+func TestPipeline(t *testing.T) {
+	const source = "apple\nbanana\ncherry\navocado\n"
+
+	t.Run("stages", func(t *testing.T) {
+		sink := &collector{}
+		p := &pipeline.Pipeline{
+			Splitter: lines,
+			Filters: []pipeline.Filter{
+				pipeline.FilterFunc(func(r *pipeline.Record) (bool, error) {
+					return r.Text != "cherry", nil
+				}),
+			},
+			Matcher: contains("a"),
+			Transformers: []pipeline.Transformer{
+				pipeline.TransformerFunc(func(item pipeline.Item) (pipeline.Item, bool) {
+					return strings.ToUpper(item.(string)), true
+				}),
+			},
+			Sink:      sink,
+			Workers:   4,
+			ChunkSize: 1,
+		}
+		assert.Nil(t, p.Run(context.TODO(), strings.NewReader(source)))
+		assert.Equal(t, []string{"APPLE", "AVOCADO", "BANANA"}, sink.sorted())
+	})
+
+	t.Run("ordered", func(t *testing.T) {
+		sink := &collector{}
+		p := &pipeline.Pipeline{
+			Splitter:  lines,
+			Matcher:   contains("a"),
+			Sink:      sink,
+			Workers:   4,
+			ChunkSize: 1,
+			Ordered:   true,
+		}
+		assert.Nil(t, p.Run(context.TODO(), strings.NewReader(source)))
+		assert.Equal(t, []string{"apple", "banana", "avocado"}, sink.items)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		sink := &collector{}
+		p := &pipeline.Pipeline{
+			Splitter: lines,
+			Filters: []pipeline.Filter{
+				pipeline.FilterFunc(func(r *pipeline.Record) (bool, error) {
+					if r.Number > 2 {
+						return false, pipeline.ErrStop
+					}
+					return true, nil
+				}),
+			},
+			Matcher: contains("a"),
+			Sink:    sink,
+		}
+		assert.Nil(t, p.Run(context.TODO(), strings.NewReader(source)))
+		assert.Equal(t, []string{"apple", "banana"}, sink.sorted())
+	})
+
+	t.Run("observer", func(t *testing.T) {
+		var (
+			mux     sync.Mutex
+			records int
+			workers = map[int]bool{}
+		)
+		p := &pipeline.Pipeline{
+			Splitter: lines,
+			Matcher:  contains("a"),
+			Sink:     &collector{},
+			Observer: pipeline.ObserverFunc(func(worker int, chunk []pipeline.Record, elapsed time.Duration) {
+				mux.Lock()
+				defer mux.Unlock()
+				records += len(chunk)
+				workers[worker] = true
+				assert.True(t, elapsed >= 0)
+			}),
+			Workers:   2,
+			ChunkSize: 1,
+		}
+		assert.Nil(t, p.Run(context.TODO(), strings.NewReader(source)))
+		assert.Equal(t, 4, records)
+		for w := range workers {
+			assert.True(t, w == 0 || w == 1)
+		}
+	})
+
+	t.Run("dispatch and worker observer", func(t *testing.T) {
+		o := &eventObserver{}
+		p := &pipeline.Pipeline{
+			Splitter:  lines,
+			Matcher:   contains("a"),
+			Sink:      &collector{},
+			Observer:  o,
+			Workers:   2,
+			ChunkSize: 3,
+		}
+		assert.Nil(t, p.Run(context.TODO(), strings.NewReader(source)))
+		sort.Strings(o.events)
+		assert.Equal(t, []string{
+			"dispatch 1 7",
+			"dispatch 3 17",
+			"end 0",
+			"end 1",
+			"start 0",
+			"start 1",
+		}, o.events)
+	})
+
+	t.Run("mark observer", func(t *testing.T) {
+		var b strings.Builder
+		for i := 1; i <= 1000; i++ {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		o := &markObserver{sunk: map[int]bool{}}
+		p := &pipeline.Pipeline{
+			Splitter: lines,
+			Matcher: pipeline.MatcherFunc(func(chunk []pipeline.Record, emit func(pipeline.Item)) {
+				time.Sleep(time.Duration(chunk[0].Number%7) * time.Millisecond) // reorder the chunks
+				for _, r := range chunk {
+					emit(r.Number)
+				}
+			}),
+			Sink:      pipeline.SinkFunc(o.put),
+			Observer:  o,
+			Workers:   4,
+			ChunkSize: 10,
+		}
+		assert.Nil(t, p.Run(context.TODO(), strings.NewReader(b.String())))
+		assert.Equal(t, 1000, len(o.sunk))
+		assert.True(t, len(o.marks) > 0)
+		for i, m := range o.marks {
+			assert.True(t, i == 0 || o.marks[i-1] < m, "increasing %v", o.marks)
+			assert.Equal(t, 1, m%10, "the first record of a chunk %d", m)
+		}
+		assert.Empty(t, o.errs)
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		pool := pipeline.NewPool(2)
+		defer pool.Close()
+		for i := 0; i < 3; i++ {
+			sink := &collector{}
+			p := &pipeline.Pipeline{
+				Splitter:  lines,
+				Matcher:   contains("a"),
+				Sink:      sink,
+				ChunkSize: 1,
+				Pool:      pool,
+			}
+			assert.Nil(t, p.Run(context.TODO(), strings.NewReader(source)))
+			assert.Equal(t, []string{"apple", "avocado", "banana"}, sink.sorted())
+		}
+	})
+
+	t.Run("max chunk bytes", func(t *testing.T) {
+		var chunks []int
+		p := &pipeline.Pipeline{
+			Splitter: lines,
+			Matcher:  contains("a"),
+			Sink:     &collector{},
+			Observer: pipeline.ObserverFunc(func(_ int, chunk []pipeline.Record, _ time.Duration) {
+				chunks = append(chunks, len(chunk))
+			}),
+			MaxChunkBytes: 11, // apple and banana
+		}
+		assert.Nil(t, p.Run(context.TODO(), strings.NewReader(source)))
+		assert.Equal(t, []int{2, 2}, chunks)
+	})
+
+	t.Run("max chunk delay", func(t *testing.T) {
+		r, w := io.Pipe()
+		defer w.Close()
+		var (
+			itemC = make(chan string, 1)
+			errC  = make(chan error, 1)
+		)
+		p := &pipeline.Pipeline{
+			Splitter: lines,
+			Matcher:  contains("a"),
+			Sink: pipeline.SinkFunc(func(item pipeline.Item) {
+				itemC <- item.(string)
+			}),
+			MaxChunkDelay: 10 * time.Millisecond,
+		}
+		go func() {
+			errC <- p.Run(context.TODO(), r)
+		}()
+		// The chunk is sent before the end of the source
+		_, _ = io.WriteString(w, "apple\n")
+		select {
+		case item := <-itemC:
+			assert.Equal(t, "apple", item)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+		w.Close()
+		assert.Nil(t, <-errC)
+	})
+
+	t.Run("max pending records", func(t *testing.T) {
+		var (
+			read int64
+			gate = make(chan struct{})
+			errC = make(chan error, 1)
+			sink = &collector{}
+		)
+		p := &pipeline.Pipeline{
+			Splitter: pipeline.SplitterFunc(func(source io.Reader, emit func(pipeline.Record) error) error {
+				return lines.Split(source, func(r pipeline.Record) error {
+					atomic.AddInt64(&read, 1)
+					return emit(r)
+				})
+			}),
+			Matcher: contains(""),
+			Sink: pipeline.SinkFunc(func(item pipeline.Item) {
+				<-gate
+				sink.Put(item)
+			}),
+			Workers:           2,
+			MaxPendingRecords: 4,
+		}
+		go func() {
+			errC <- p.Run(context.TODO(), strings.NewReader(strings.Repeat("x\n", 1000)))
+		}()
+		time.Sleep(50 * time.Millisecond)
+		// The records pending in the workers and the chunk being filled
+		assert.LessOrEqual(t, atomic.LoadInt64(&read), int64(8))
+		close(gate)
+		assert.Nil(t, <-errC)
+		assert.Equal(t, 1000, len(sink.items))
+	})
+
+	t.Run("auto tune", func(t *testing.T) {
+		t.Run("chunk size", func(t *testing.T) {
+			var (
+				mux    sync.Mutex
+				chunks []int
+				sink   = &collector{}
+			)
+			p := &pipeline.Pipeline{
+				Splitter: lines,
+				Matcher:  contains("x"),
+				Sink:     sink,
+				Workers:  2,
+				Observer: pipeline.ObserverFunc(func(_ int, chunk []pipeline.Record, _ time.Duration) {
+					mux.Lock()
+					defer mux.Unlock()
+					chunks = append(chunks, len(chunk))
+				}),
+				AutoTune: true,
+			}
+			assert.Nil(t, p.Run(context.TODO(), strings.NewReader(strings.Repeat("x\n", 20000))))
+			assert.Equal(t, 20000, len(sink.items))
+			sort.Ints(chunks)
+			assert.Equal(t, 4096, chunks[len(chunks)-1], "short records make large chunks")
+		})
+
+		t.Run("workers", func(t *testing.T) {
+			var (
+				mux     sync.Mutex
+				workers []int
+				sink    = &collector{}
+			)
+			long := strings.Repeat("x", 8<<10)
+			p := &pipeline.Pipeline{
+				Splitter: pipeline.SplitterFunc(func(_ io.Reader, emit func(pipeline.Record) error) error {
+					for i := 1; i <= 16*40; i++ {
+						time.Sleep(100 * time.Microsecond) // slower than the matcher
+						if err := emit(pipeline.Record{Text: long, View: long, Number: i}); err != nil {
+							return err
+						}
+					}
+					return nil
+				}),
+				Matcher: contains("y"),
+				Sink:    sink,
+				Workers: 4,
+				Observer: pipeline.ObserverFunc(func(worker int, _ []pipeline.Record, _ time.Duration) {
+					mux.Lock()
+					defer mux.Unlock()
+					workers = append(workers, worker)
+				}),
+				AutoTune: true,
+			}
+			assert.Nil(t, p.Run(context.TODO(), nil))
+			assert.Equal(t, 35, len(workers), "long records make the chunks of 16 records after the first one of 100")
+			assert.Equal(t, []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, workers[len(workers)-10:], "a worker keeps up with the splitter")
+		})
+	})
+
+	t.Run("filter error", func(t *testing.T) {
+		filterErr := errors.New("filter")
+		p := &pipeline.Pipeline{
+			Splitter: lines,
+			Filters: []pipeline.Filter{
+				pipeline.FilterFunc(func(*pipeline.Record) (bool, error) {
+					return false, filterErr
+				}),
+			},
+			Matcher: contains("a"),
+			Sink:    &collector{},
+		}
+		assert.ErrorIs(t, p.Run(context.TODO(), strings.NewReader(source)), filterErr)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		sink := &collector{}
+		p := &pipeline.Pipeline{
+			Splitter:  lines,
+			Matcher:   contains("a"),
+			Sink:      sink,
+			ChunkSize: 1,
+		}
+		assert.ErrorIs(t, p.Run(ctx, strings.NewReader(source)), context.Canceled)
+		assert.Equal(t, 0, len(sink.items))
+	})
+	t.Run("canceled in chunk", func(t *testing.T) {
+		// A chunk takes 10s to match without checking the context
+		var (
+			ctx, cancel = context.WithCancel(context.TODO())
+			source      = strings.Repeat("a\n", 1000)
+			matched     int32
+		)
+		defer cancel()
+		p := &pipeline.Pipeline{
+			Splitter:  lines,
+			Matcher:   &slowMatcher{delay: 10 * time.Millisecond, matched: &matched},
+			Sink:      &collector{},
+			ChunkSize: 1000,
+		}
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		assert.ErrorIs(t, p.Run(ctx, strings.NewReader(source)), context.Canceled)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Less(t, atomic.LoadInt32(&matched), int32(100))
+	})
+	t.Run("panic", func(t *testing.T) {
+		for _, tc := range []struct {
+			title string
+			pool  bool
+			p     *pipeline.Pipeline
+		}{
+			{
+				title: "matcher",
+				p: &pipeline.Pipeline{
+					Splitter: lines,
+					Matcher: pipeline.MatcherFunc(func([]pipeline.Record, func(pipeline.Item)) {
+						panic("matcher")
+					}),
+					Workers:   2,
+					ChunkSize: 1,
+				},
+			},
+			{
+				title: "matcher in pool",
+				pool:  true,
+				p: &pipeline.Pipeline{
+					Splitter: lines,
+					Matcher: pipeline.MatcherFunc(func([]pipeline.Record, func(pipeline.Item)) {
+						panic("matcher")
+					}),
+					ChunkSize: 1,
+				},
+			},
+			{
+				title: "filter",
+				p: &pipeline.Pipeline{
+					Splitter: lines,
+					Filters: []pipeline.Filter{
+						pipeline.FilterFunc(func(*pipeline.Record) (bool, error) {
+							panic("filter")
+						}),
+					},
+					Matcher: contains("a"),
+				},
+			},
+			{
+				title: "sink",
+				p: &pipeline.Pipeline{
+					Splitter: lines,
+					Matcher:  contains("a"),
+					Sink: pipeline.SinkFunc(func(pipeline.Item) {
+						panic("sink")
+					}),
+				},
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				if tc.p.Sink == nil {
+					tc.p.Sink = &collector{}
+				}
+				if tc.pool {
+					tc.p.Pool = pipeline.NewPool(2)
+					defer tc.p.Pool.Close()
+				}
+				err := tc.p.Run(context.TODO(), strings.NewReader(source))
+				var e *pipeline.PanicError
+				if assert.ErrorAs(t, err, &e) {
+					assert.NotEmpty(t, e.Stack)
+				}
+			})
+		}
+	})
+}
+
+// slowMatcher matches every record taking the delay and stops when the context is canceled.
+type slowMatcher struct {
+	delay   time.Duration
+	matched *int32
+}
+
+func (s *slowMatcher) Match(chunk []pipeline.Record, emit func(pipeline.Item)) {
+	s.MatchContext(context.Background(), chunk, emit)
+}
+
+func (s *slowMatcher) MatchContext(ctx context.Context, chunk []pipeline.Record, emit func(pipeline.Item)) {
+	for _, r := range chunk {
+		if ctx.Err() != nil {
+			return
+		}
+		time.Sleep(s.delay)
+		atomic.AddInt32(s.matched, 1)
+		emit(r.Text)
+	}
+}
@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// tuneChunkBytes is the bytes of the texts of a chunk aimed by AutoTune.
+	tuneChunkBytes = 64 << 10
+	// tuneMinChunk and tuneMaxChunk bound the records of a chunk by AutoTune.
+	tuneMinChunk = 16
+	tuneMaxChunk = 4096
+	// tuneWarmup is the number of the chunks matched before AutoTune changes the workers.
+	tuneWarmup = 8
+	// tuneWeight is the weight of the last observation in the moving averages.
+	tuneWeight = 0.2
+)
+
+// tuner adjusts the chunk size and the active workers by AutoTune.
+//
+// The chunk size aims at tuneChunkBytes by the average length of the records.
+// The active workers are as many as match the chunks as fast as the splitter sends them,
+// by the average time to match a chunk over the average interval of the chunks,
+// and halved when most of the records emit the items, since the sink is the bottleneck then.
+// The other workers wait without taking the chunks.
+type tuner struct {
+	workers  int // the max of the active workers
+	maxChunk int
+
+	mux       sync.Mutex
+	cond      *sync.Cond
+	active    int
+	closed    bool
+	records   int64
+	bytes     int64
+	lastSent  time.Time
+	interval  float64 // the average interval of the chunks sent in nanoseconds, except the time blocked
+	matchTime float64 // the average time to match a chunk in nanoseconds
+	matched   int64   // the records matched
+	items     int64   // the items emitted from them
+	chunks    int     // the chunks matched
+}
+
+func newTuner(workers, maxChunk int) *tuner {
+	s := &tuner{
+		workers:  workers,
+		maxChunk: min(maxChunk, tuneMaxChunk),
+		active:   workers,
+	}
+	s.cond = sync.NewCond(&s.mux)
+	return s
+}
+
+// average returns the moving average with the observation.
+func average(avg, x float64) float64 {
+	if avg == 0 {
+		return x
+	}
+	return avg*(1-tuneWeight) + x*tuneWeight
+}
+
+// sent observes a chunk sent to the workers and returns the size of the next chunk.
+// blocked is the time waiting for the workers to send the chunk, excluded from the interval
+// not to take the workers being busy for the splitter being slow.
+func (s *tuner) sent(records, bytes int, blocked time.Duration) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now()
+	if !s.lastSent.IsZero() {
+		s.interval = average(s.interval, float64(max(now.Sub(s.lastSent)-blocked, 1)))
+	}
+	s.lastSent = now
+	s.records += int64(records)
+	s.bytes += int64(bytes)
+	size := tuneMaxChunk
+	if s.bytes > 0 {
+		size = int(tuneChunkBytes * s.records / s.bytes)
+	}
+	return max(min(size, s.maxChunk), min(tuneMinChunk, s.maxChunk))
+}
+
+// observe observes a chunk matched by a worker.
+func (s *tuner) observe(records, items int, elapsed time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.matchTime = average(s.matchTime, float64(elapsed))
+	s.matched += int64(records)
+	s.items += int64(items)
+	s.chunks++
+	if s.chunks < tuneWarmup || s.interval == 0 {
+		return
+	}
+	need := int(math.Ceil(s.matchTime / s.interval))
+	if s.items*2 > s.matched {
+		need = (need + 1) / 2
+	}
+	active := max(min(need, s.workers), 1)
+	if active > s.active {
+		s.cond.Broadcast()
+	}
+	s.active = active
+}
+
+// wait blocks the worker while it is not active and the workers are not closed.
+// Nil tuner does not block.
+func (s *tuner) wait(worker int) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for worker >= s.active && !s.closed {
+		s.cond.Wait()
+	}
+}
+
+// close releases the workers waiting, after the chunks are exhausted.
+func (s *tuner) close() {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+}
@@ -0,0 +1,130 @@
+package gogrep
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// EngineAuto selects a matcher by inspecting the pattern.
+// See PlanPattern.
+const EngineAuto Engine = "auto"
+
+func init() {
+	RegisterEngine(EngineAuto, func(pattern string) (Matcher, error) {
+		return PlanPattern(pattern).compile()
+	})
+}
+
+// Plan describes the matcher chosen for a pattern by EngineAuto.
+type Plan struct {
+	// Matcher is the name of the chosen matcher.
+	Matcher string
+	// Reason explains why the matcher was chosen.
+	Reason string
+
+	pattern string
+	compile func() (Matcher, error)
+}
+
+func (p Plan) String() string {
+	return fmt.Sprintf("matcher=%s reason=%q pattern=%q", p.Matcher, p.Reason, p.pattern)
+}
+
+// PlanPattern chooses the fastest matcher that is equivalent to the regex.
+//
+// A pure literal is matched by substring search, an anchored literal by prefix or suffix comparison,
+// an alternation of literals by searching each literal, and anything else by regexp.
+func PlanPattern(pattern string) Plan {
+	p := Plan{
+		pattern: pattern,
+	}
+	var (
+		hasPrefix = strings.HasPrefix(pattern, "^")
+		hasSuffix = strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, `\$`)
+		body      = pattern
+	)
+	if hasPrefix {
+		body = body[1:]
+	}
+	if hasSuffix && len(body) > 0 {
+		body = body[:len(body)-1]
+	}
+	switch {
+	case isLiteral(body) && hasPrefix && hasSuffix:
+		p.Matcher, p.Reason = "exact", "anchored literal on both sides"
+		p.compile = func() (Matcher, error) { return exactMatcher(body), nil }
+	case isLiteral(body) && hasPrefix:
+		p.Matcher, p.Reason = "prefix", "literal anchored at the beginning"
+		p.compile = func() (Matcher, error) { return prefixMatcher(body), nil }
+	case isLiteral(body) && hasSuffix:
+		p.Matcher, p.Reason = "suffix", "literal anchored at the end"
+		p.compile = func() (Matcher, error) { return suffixMatcher(body), nil }
+	case isLiteral(pattern) && pattern != "":
+		p.Matcher, p.Reason = string(EngineFixed), "pure literal"
+		p.compile = func() (Matcher, error) { return EngineFixed.Compile(pattern) }
+	case isLiteralAlternation(pattern):
+		p.Matcher, p.Reason = "literal set", "alternation of literals"
+		p.compile = func() (Matcher, error) { return literalSetMatcher(strings.Split(pattern, "|")), nil }
+	default:
+		p.Matcher, p.Reason = string(EngineRegexp), "pattern requires regex features"
+		p.compile = func() (Matcher, error) { return EngineRegexp.Compile(pattern) }
+	}
+	return p
+}
+
+// isLiteral returns true if the pattern has no regex metacharacters.
+func isLiteral(pattern string) bool { return regexp.QuoteMeta(pattern) == pattern }
+
+// isLiteralAlternation returns true if the pattern is like foo|bar.
+func isLiteralAlternation(pattern string) bool {
+	if !strings.Contains(pattern, "|") {
+		return false
+	}
+	for _, x := range strings.Split(pattern, "|") {
+		if x == "" || !isLiteral(x) {
+			return false
+		}
+	}
+	return true
+}
+
+type (
+	exactMatcher      string
+	prefixMatcher     string
+	suffixMatcher     string
+	literalSetMatcher []string
+)
+
+func (s exactMatcher) MatchString(line string) bool  { return line == string(s) }
+func (s prefixMatcher) MatchString(line string) bool { return strings.HasPrefix(line, string(s)) }
+func (s suffixMatcher) MatchString(line string) bool { return strings.HasSuffix(line, string(s)) }
+func (s exactMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	if n == 0 || !s.MatchString(line) {
+		return nil
+	}
+	return [][]int{{0, len(line)}}
+}
+func (s prefixMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	if n == 0 || !s.MatchString(line) {
+		return nil
+	}
+	return [][]int{{0, len(s)}}
+}
+func (s suffixMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	if n == 0 || !s.MatchString(line) {
+		return nil
+	}
+	return [][]int{{len(line) - len(s), len(line)}}
+}
+func (s literalSetMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	return findAllLiterals(line, s, n)
+}
+func (s literalSetMatcher) MatchString(line string) bool {
+	for _, x := range s {
+		if strings.Contains(line, x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package gogrep_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanPattern(t *testing.T) {
+	for _, tc := range []*struct {
+		title   string
+		pattern string
+		matcher string
+		match   []string
+		unmatch []string
+	}{
+		{
+			title:   "literal",
+			pattern: "vanity",
+			matcher: "fixed",
+			match:   []string{"vanity", "vanitas vanity"},
+			unmatch: []string{"vanitas"},
+		},
+		{
+			title:   "prefix",
+			pattern: "^vanity",
+			matcher: "prefix",
+			match:   []string{"vanity", "vanity fair"},
+			unmatch: []string{"a vanity"},
+		},
+		{
+			title:   "suffix",
+			pattern: "vanity$",
+			matcher: "suffix",
+			match:   []string{"vanity", "a vanity"},
+			unmatch: []string{"vanity fair"},
+		},
+		{
+			title:   "exact",
+			pattern: "^vanity$",
+			matcher: "exact",
+			match:   []string{"vanity"},
+			unmatch: []string{"a vanity", "vanity fair"},
+		},
+		{
+			title:   "literal set",
+			pattern: "afford|deny",
+			matcher: "literal set",
+			match:   []string{"affordance", "deny"},
+			unmatch: []string{"vanity"},
+		},
+		{
+			title:   "escaped anchor",
+			pattern: `price\$`,
+			matcher: "regexp",
+			match:   []string{"price$"},
+			unmatch: []string{"price"},
+		},
+		{
+			title:   "regex",
+			pattern: "a.c",
+			matcher: "regexp",
+			match:   []string{"abc"},
+			unmatch: []string{"ac"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			p := gogrep.PlanPattern(tc.pattern)
+			assert.Equal(t, tc.matcher, p.Matcher)
+			m, err := gogrep.EngineAuto.Compile(tc.pattern)
+			assert.Nil(t, err)
+			for _, x := range tc.match {
+				assert.True(t, m.MatchString(x), x)
+			}
+			for _, x := range tc.unmatch {
+				assert.False(t, m.MatchString(x), x)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package gogrep
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// withPrefilter wraps the regex by the matcher that rejects the lines without the literal required by the regex
+// before running the regex.
+// Other matchers are returned as they are.
+func withPrefilter(m Matcher) Matcher {
+	re, ok := m.(*regexp.Regexp)
+	if !ok {
+		return m
+	}
+	literal := RequiredLiteral(re.String())
+	if literal == "" {
+		return m
+	}
+	return &prefilterMatcher{
+		literal: literal,
+		m:       re,
+	}
+}
+
+// prefilterMatcher runs the matcher only on the lines that contain the literal.
+type prefilterMatcher struct {
+	literal string
+	m       Matcher
+}
+
+func (s *prefilterMatcher) MatchString(line string) bool {
+	return strings.Contains(line, s.literal) && s.m.MatchString(line)
+}
+
+func (s *prefilterMatcher) FindAllStringSubmatchIndex(line string, n int) [][]int {
+	if !strings.Contains(line, s.literal) {
+		return nil
+	}
+	return s.m.FindAllStringSubmatchIndex(line, n)
+}
+
+// RequiredLiteral returns the longest literal that every match of the regex contains,
+// e.g. timeout for ERROR.*timeout.
+// Returns the empty string if there is no such literal or the regex is invalid.
+//
+// The case-insensitive literals and the literals in the alternations are not required.
+func RequiredLiteral(regex string) string {
+	re, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	return requiredLiteral(re.Simplify())
+}
+
+func requiredLiteral(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 || slices.Contains(re.Rune, utf8.RuneError) {
+			// U+FFFD matches the invalid bytes as well
+			return ""
+		}
+		return string(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return ""
+		}
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpConcat:
+		var r string
+		for _, sub := range re.Sub {
+			if x := requiredLiteral(sub); len(x) > len(r) {
+				r = x
+			}
+		}
+		return r
+	default:
+		return ""
+	}
+}
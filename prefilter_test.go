@@ -0,0 +1,100 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequiredLiteral(t *testing.T) {
+	for _, tc := range []*struct {
+		regex string
+		want  string
+	}{
+		{regex: "ERROR.*timeout", want: "timeout"},
+		{regex: "ERROR", want: "ERROR"},
+		{regex: "^ERROR [0-9]+", want: "ERROR "},
+		{regex: "(conn(ection)?) reset", want: " reset"},
+		{regex: "(?:refused)+ by", want: "refused"},
+		{regex: "(?:refused){2,} by", want: "refused"},
+		{regex: "(?:refused)* by", want: " by"},
+		{regex: "(?:refused)?", want: ""},
+		{regex: "error|warning", want: ""},
+		{regex: "(?i)error", want: ""},
+		{regex: "[0-9]+", want: ""},
+		{regex: "�", want: ""},
+		{regex: "(", want: ""},
+	} {
+		t.Run(tc.regex, func(t *testing.T) {
+			assert.Equal(t, tc.want, gogrep.RequiredLiteral(tc.regex))
+		})
+	}
+}
+
+func TestPrefilter(t *testing.T) {
+	source := strings.Join([]string{
+		"INFO request started",
+		"ERROR read timeout",
+		"ERROR write failed",
+		"WARN timeout ERROR",
+		"error timeout",
+		"ERROR timeout of 2timeout",
+	}, "\n")
+	for _, tc := range []*struct {
+		title string
+		regex string
+	}{
+		{title: "required literal", regex: "ERROR.*timeout"},
+		{title: "submatches", regex: "ERROR.*(time)(out)"},
+		{title: "case insensitive", regex: "(?i)error.*timeout"},
+		{title: "no literal", regex: "[A-Z]+ [a-z]+ timeout"},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			grep := func(opt ...gogrep.Option) []gogrep.Result {
+				resultC, err := gogrep.New(append(opt, gogrep.WithThreads(1), gogrep.WithOnlyMatching())...).
+					Grep(context.TODO(), tc.regex, strings.NewReader(source))
+				assert.Nil(t, err)
+				return toResultSlice(resultC)
+			}
+			want := grep(gogrep.WithoutPrefilter())
+			assert.NotEmpty(t, want)
+			assert.Equal(t, want, grep())
+		})
+	}
+}
+
+func BenchmarkPrefilter(b *testing.B) {
+	data := strings.Join(dupStrings(1<<16,
+		"INFO request started id=1234 path=/api/v1/users",
+		"DEBUG cache hit key=user:1234 ttl=300",
+		"WARN slow query took 1200ms",
+		"ERROR upstream failed status=502",
+		"INFO request finished status=200 elapsed=12ms",
+		"ERROR upstream timeout after 30s",
+	), "\n")
+	for _, regex := range []string{"ERROR.*timeout", "[A-Z]+ .*timeout"} {
+		for _, enabled := range []bool{true, false} {
+			name := regex + "/prefilter"
+			opt := []gogrep.Option{gogrep.WithThreads(1)}
+			if !enabled {
+				name = regex + "/regexp"
+				opt = append(opt, gogrep.WithoutPrefilter())
+			}
+			b.Run(name, func(b *testing.B) {
+				g := gogrep.New(opt...)
+				b.SetBytes(int64(len(data)))
+				for n := 0; n < b.N; n++ {
+					resultC, err := g.Grep(context.TODO(), regex, strings.NewReader(data))
+					if err != nil {
+						b.Fatal(err)
+					}
+					for range resultC {
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package gogrep
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ProgressEvent is the progress of a grep of a source.
+type ProgressEvent struct {
+	// Tag is the value set by WithSourceTag.
+	Tag interface{}
+	// Size is the size of the source in bytes, -1 if unknown.
+	Size int64
+	// BytesRead is the number of the bytes read from the source, before the decompression and the decoding.
+	BytesRead int64
+	// LinesScanned is the number of the lines or the records split from the source.
+	LinesScanned int64
+	// LinesMatched is the number of the lines selected by the regexes.
+	LinesMatched int64
+	// Elapsed is the time from the start of the grep.
+	Elapsed time.Duration
+	// Done is true for the last event of the grep.
+	Done bool
+}
+
+// progressInterval is the min interval of the progress events of a grep except for the last one.
+const progressInterval = 100 * time.Millisecond
+
+// WithProgress calls the reporter with the progress of the grep of a source
+// at most every 100ms while the chunks of the lines are matched, and at the end with Done.
+// It is called for each source of GrepSources, possibly concurrently.
+// Nil is ignored.
+func WithProgress(reporter func(ProgressEvent)) Option {
+	return func(c *Config) {
+		if reporter != nil {
+			c.progress = reporter
+		}
+	}
+}
+
+// progressReporter reports the progress of a grep by the counts of the stats.
+type progressReporter struct {
+	report func(ProgressEvent)
+	clock  Clock
+	tag    interface{}
+	size   int64
+	mux    sync.Mutex
+	last   time.Time // the time of the last event
+}
+
+// observe reports the progress unless reported within the interval, nil-safe.
+func (s *progressReporter) observe(stats *statsCounter) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := s.clock.Now()
+	if now.Sub(s.last) < progressInterval {
+		return
+	}
+	s.last = now
+	s.report(s.event(stats, now, false))
+}
+
+// finish reports the last progress, nil-safe.
+func (s *progressReporter) finish(stats *statsCounter, end time.Time) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.report(s.event(stats, end, true))
+}
+
+func (s *progressReporter) event(stats *statsCounter, now time.Time, done bool) ProgressEvent {
+	x := stats.stats(now)
+	return ProgressEvent{
+		Tag:          s.tag,
+		Size:         s.size,
+		BytesRead:    x.BytesRead,
+		LinesScanned: x.LinesScanned,
+		LinesMatched: x.LinesMatched,
+		Elapsed:      x.Duration,
+		Done:         done,
+	}
+}
+
+// sourceSize returns the size of the source, -1 if unknown.
+func sourceSize(source io.Reader) int64 {
+	switch x := source.(type) {
+	case interface{ Size() int64 }:
+		return x.Size()
+	case interface{ Stat() (os.FileInfo, error) }:
+		if info, err := x.Stat(); err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	}
+	return -1
+}
@@ -0,0 +1,95 @@
+package gogrep_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// stepClock advances a second whenever the time is read.
+type stepClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+func (s *stepClock) Now() time.Time {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.now = s.now.Add(time.Second)
+	return s.now
+}
+
+func (s *stepClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func TestWithProgress(t *testing.T) {
+	t.Run("grep", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&b, "line%d\n", i)
+		}
+		source := b.String()
+		var events []gogrep.ProgressEvent
+		resultC, err := gogrep.New(
+			gogrep.WithThreads(1),
+			gogrep.WithClock(&stepClock{}),
+			gogrep.WithSourceTag("src"),
+			gogrep.WithProgress(func(x gogrep.ProgressEvent) { events = append(events, x) }),
+		).Grep(context.TODO(), "line1", strings.NewReader(source))
+		assert.Nil(t, err)
+		for x := range resultC {
+			assert.Nil(t, x.Err())
+		}
+		if !assert.Greater(t, len(events), 2) {
+			return
+		}
+		for i, x := range events {
+			assert.Equal(t, "src", x.Tag)
+			assert.Equal(t, int64(len(source)), x.Size)
+			assert.Equal(t, i == len(events)-1, x.Done)
+			if i > 0 {
+				assert.GreaterOrEqual(t, x.LinesScanned, events[i-1].LinesScanned)
+			}
+		}
+		last := events[len(events)-1]
+		assert.Equal(t, int64(len(source)), last.BytesRead)
+		assert.Equal(t, int64(1000), last.LinesScanned)
+		assert.Equal(t, int64(111), last.LinesMatched)
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		var (
+			mux  sync.Mutex
+			done = map[interface{}]gogrep.ProgressEvent{}
+		)
+		sources := make([]gogrep.NamedSource, 3)
+		for i := range sources {
+			sources[i] = gogrep.NamedSource{
+				Name:    fmt.Sprint(i),
+				Reader:  strings.NewReader(strings.Repeat("a\n", i+1)),
+				Options: []gogrep.Option{gogrep.WithSourceTag(i)},
+			}
+		}
+		resultC, err := gogrep.New(gogrep.WithProgress(func(x gogrep.ProgressEvent) {
+			if x.Done {
+				mux.Lock()
+				defer mux.Unlock()
+				done[x.Tag] = x
+			}
+		})).GrepSources(context.TODO(), []string{"a"}, sources)
+		assert.Nil(t, err)
+		for x := range resultC {
+			assert.Nil(t, x.Err())
+		}
+		assert.Equal(t, 3, len(done))
+		for i := range sources {
+			assert.Equal(t, int64(i+1), done[i].LinesMatched)
+			assert.Equal(t, int64(2*(i+1)), done[i].BytesRead)
+		}
+	})
+}
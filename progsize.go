@@ -0,0 +1,58 @@
+package gogrep
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+)
+
+// ErrRegexTooLarge means the regexes compile to the programs larger than WithMaxRegexProgramSize.
+var ErrRegexTooLarge = errors.New("regex program too large")
+
+// WithMaxRegexProgramSize rejects the regexes whose RE2 programs have more instructions than the size in total,
+// e.g. the huge alternations read from the files, not to spike the memory.
+// The regexes matched without RE2 by EngineAuto, the literals and the alternations of the literals,
+// are not counted, nor are the regexes of the other engines than EngineRegexp and EngineAuto.
+// The grep fails with ErrRegexTooLarge.
+// Not positive number means no limit, the default.
+func WithMaxRegexProgramSize(size int) Option {
+	return func(c *Config) {
+		c.maxProgramSize = size
+	}
+}
+
+// RegexProgramSize returns the number of the instructions of the RE2 program of the regex.
+func RegexProgramSize(regex string) (int, error) {
+	re, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return 0, err
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return 0, err
+	}
+	return len(prog.Inst), nil
+}
+
+// checkProgramSize rejects the regexes larger than WithMaxRegexProgramSize.
+func (c *Config) checkProgramSize(regexes []string) error {
+	if c.maxProgramSize <= 0 || (c.engine != EngineRegexp && c.engine != EngineAuto) || c.fuzzy >= 0 {
+		return nil
+	}
+	var total int
+	for _, regex := range regexes {
+		if c.engine == EngineAuto && PlanPattern(regex).Matcher != string(EngineRegexp) {
+			continue
+		}
+		n, err := RegexProgramSize(regex)
+		if err != nil {
+			continue // reported by the compile
+		}
+		total += n
+	}
+	if total > c.maxProgramSize {
+		return fmt.Errorf("Grepper %w %w: %d instructions exceed %d, use EngineFixed for the literals or EngineAuto for the alternations of the literals",
+			ErrBadPattern, ErrRegexTooLarge, total, c.maxProgramSize)
+	}
+	return nil
+}
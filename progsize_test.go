@@ -0,0 +1,86 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexProgramSize(t *testing.T) {
+	small, err := gogrep.RegexProgramSize("a")
+	assert.Nil(t, err)
+	large, err := gogrep.RegexProgramSize("a+b*[c-z]{10}")
+	assert.Nil(t, err)
+	assert.Less(t, small, large)
+	_, err = gogrep.RegexProgramSize("(")
+	assert.NotNil(t, err)
+}
+
+func TestWithMaxRegexProgramSize(t *testing.T) {
+	words := make([]string, 200)
+	for i := range words {
+		words[i] = strings.Repeat(string(rune('a'+i%26)), 1+i/26) + "x?"
+	}
+	alternation := strings.Join(words, "|")
+
+	for _, tc := range []struct {
+		title    string
+		engine   gogrep.Engine
+		regexes  []string
+		tooLarge bool
+	}{
+		{
+			title:    "large alternation",
+			engine:   gogrep.EngineRegexp,
+			regexes:  []string{alternation},
+			tooLarge: true,
+		},
+		{
+			title:    "many regexes in total",
+			engine:   gogrep.EngineRegexp,
+			regexes:  words,
+			tooLarge: true,
+		},
+		{
+			title:   "small",
+			engine:  gogrep.EngineRegexp,
+			regexes: []string{"crimson"},
+		},
+		{
+			title:   "fixed",
+			engine:  gogrep.EngineFixed,
+			regexes: []string{alternation},
+		},
+		{
+			title:   "literals by auto",
+			engine:  gogrep.EngineAuto,
+			regexes: []string{strings.ReplaceAll(alternation, "x?", "")},
+		},
+		{
+			title:    "regex by auto",
+			engine:   gogrep.EngineAuto,
+			regexes:  []string{alternation},
+			tooLarge: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			g := gogrep.New(gogrep.WithEngine(tc.engine), gogrep.WithMaxRegexProgramSize(500))
+			resultC, err := g.GrepMulti(context.TODO(), tc.regexes, strings.NewReader("crimson\n"))
+			if tc.tooLarge {
+				assert.ErrorIs(t, err, gogrep.ErrRegexTooLarge)
+				return
+			}
+			assert.Nil(t, err)
+			toResultSlice(resultC)
+		})
+	}
+
+	t.Run("no limit", func(t *testing.T) {
+		resultC, err := gogrep.New().Grep(context.TODO(), alternation, strings.NewReader("crimson\n"))
+		assert.Nil(t, err)
+		toResultSlice(resultC)
+	})
+}
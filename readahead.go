@@ -0,0 +1,103 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+)
+
+// readaheadSize is the size of the head of a source read ahead by WithReadahead.
+const readaheadSize = 64 << 10
+
+// readaheadSources returns the number of the sources read ahead by GrepSources.
+// The small sources are read ahead by the threads to batch them without waiting for opening them one by one.
+func (c *Config) readaheadSources() int {
+	if c.perSourceTimeout > 0 {
+		return 0 // the heads would be read out of the timeouts
+	}
+	if c.readahead < 1 && c.batchable() {
+		return c.threads
+	}
+	return c.readahead
+}
+
+// prefetch is a source of GrepSources whose head is read ahead.
+type prefetch struct {
+	source NamedSource
+	done   chan struct{} // closed after the head is read
+}
+
+// readahead reads the heads of the sources in the background, up to k sources ahead of the receiver,
+// and sends the sources in order.
+// The channel is closed after all the sources or on cancel,
+// so the receiver should wait for the done of all the received sources before closing the rest.
+// The sources that implement SizedReaderAt are sent as they are to be grepped by the ranges.
+func readahead(ctx context.Context, sources []NamedSource, k int) <-chan *prefetch {
+	aheadC := make(chan *prefetch, k)
+	go func() {
+		defer close(aheadC)
+		for _, src := range sources {
+			p := &prefetch{
+				source: src,
+				done:   make(chan struct{}),
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case aheadC <- p:
+			}
+			if _, ok := src.Reader.(SizedReaderAt); ok {
+				close(p.done)
+				continue
+			}
+			go func() {
+				defer close(p.done)
+				defer func() {
+					if err := panicError(recover()); err != nil {
+						p.source.Reader = &headReader{err: err} // fails the grep of the source
+					}
+				}()
+				p.source.Reader = newHeadReader(src.Reader)
+			}()
+		}
+	}()
+	return aheadC
+}
+
+// headReader reads the head read ahead from the source and then the rest of the source.
+type headReader struct {
+	head []byte
+	err  error // the error of reading the head
+	r    io.Reader
+}
+
+// newHeadReader reads the head of the source, opening the source if it opens on the first read.
+func newHeadReader(r io.Reader) *headReader {
+	head, err := io.ReadAll(io.LimitReader(r, readaheadSize))
+	if err == nil && len(head) < readaheadSize {
+		err = io.EOF
+	}
+	return &headReader{
+		head: head,
+		err:  err,
+		r:    r,
+	}
+}
+
+func (s *headReader) Read(p []byte) (int, error) {
+	if len(s.head) > 0 {
+		n := copy(p, s.head)
+		s.head = s.head[n:]
+		return n, nil
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.r.Read(p)
+}
+
+func (s *headReader) MapOffset(pos int64) int64 {
+	if m, ok := s.r.(OffsetMapper); ok {
+		return m.MapOffset(pos)
+	}
+	return pos
+}
@@ -0,0 +1,275 @@
+package gogrep
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+// SizedReaderAt is a source that can be read at any offset,
+// e.g. *bytes.Reader, *strings.Reader, *io.SectionReader or a memory-mapped file.
+type SizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+const (
+	// readerAtMinRange is the minimum size of a range of GrepReaderAt.
+	readerAtMinRange = 1 << 20
+	// binaryBlockSize is the size of the first block of GrepReaderAt to detect binary sources.
+	binaryBlockSize = 4096
+)
+
+// WithSourceConcurrencyHint sets the function that returns the number of the workers
+// to scan the ranges of a SizedReaderAt source instead of WithThreads,
+// e.g. to give a huge file more workers.
+// name is the name of the source of GrepSources, empty for GrepReaderAt, and size is the size of the source.
+// Not positive number of the hint means WithThreads.
+// The ranges are not smaller than 1MiB regardless of the hint.
+// Nil is ignored.
+func WithSourceConcurrencyHint(hint func(name string, size int64) int) Option {
+	return func(c *Config) {
+		if hint != nil {
+			c.concurrencyHint = hint
+		}
+	}
+}
+
+// withSourceName sets the name of the source for WithSourceConcurrencyHint.
+func withSourceName(name string) Option {
+	return func(c *Config) {
+		c.sourceName = name
+	}
+}
+
+func (s *grepper) GrepReaderAt(ctx context.Context, regexes []string, source SizedReaderAt) (<-chan Result, error) {
+	// Already canceled
+	if isDone(ctx) {
+		return nil, wrapErr(cancellationError(ctx), "Grepper")
+	}
+	if err := s.checkRegexes(regexes); err != nil {
+		return nil, err
+	}
+	r, err := s.compileMulti(regexes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.config.validate(); err != nil {
+		return nil, err
+	}
+	if ranges := s.splitRanges(source); len(ranges) > 1 {
+		return s.grepRanges(ctx, r, source, ranges), nil
+	}
+	return s.grepMatcher(ctx, r, io.NewSectionReader(source, 0, source.Size()))
+}
+
+// grepSource greps the source by the ranges if possible, or sequentially, within WithPerSourceTimeout.
+func (s *grepper) grepSource(ctx context.Context, r Matcher, source io.Reader) (<-chan Result, error) {
+	stop := func() {}
+	if s.config.perSourceTimeout > 0 {
+		ctx, s, stop = s.withSourceTimeout(ctx)
+	}
+	if x, ok := source.(SizedReaderAt); ok {
+		if ranges := s.splitRanges(x); len(ranges) > 1 {
+			return s.grepRanges(ctx, r, x, ranges), nil
+		}
+	}
+	resultC, err := s.grepMatcher(ctx, r, source)
+	if err != nil {
+		stop()
+	}
+	return resultC, err
+}
+
+// splitRanges returns the ranges of the source that begin with the records, up to the threads.
+// Returns nil if the source should be grepped sequentially.
+// The errors of reading are left to the sequential grep.
+func (s *grepper) splitRanges(source SizedReaderAt) [][2]int64 {
+	c := s.config
+	if c.multiline || c.scope != "" || len(c.notInside) > 0 || c.encoding != "" || c.delimiter < 0 || len(c.readerMiddleware) > 0 ||
+		c.checkpointInterval > 0 || c.startPositioned() {
+		return nil
+	}
+	var (
+		size = source.Size()
+		n    = int64(c.threads)
+	)
+	if c.concurrencyHint != nil {
+		if k := c.concurrencyHint(c.sourceName, size); k > 0 {
+			n = int64(k)
+		}
+	}
+	if m := size / readerAtMinRange; m < n {
+		n = m
+	}
+	if n < 2 {
+		return nil
+	}
+	head := make([]byte, binaryBlockSize)
+	k, err := source.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil
+	}
+	if (c.decompression && IsCompressed(head[:k])) || (c.binaryFiles != BinaryText && IsBinary(head[:k])) {
+		return nil
+	}
+	var (
+		ranges = make([][2]int64, 0, n)
+		start  int64
+	)
+	for i := int64(1); i < n; i++ {
+		end, err := nextRecord(source, i*size/n, size, byte(c.delimiter))
+		if err != nil {
+			return nil
+		}
+		if end <= start {
+			continue // a long record
+		}
+		ranges = append(ranges, [2]int64{start, end})
+		start = end
+	}
+	if start < size {
+		ranges = append(ranges, [2]int64{start, size})
+	}
+	return ranges
+}
+
+// nextRecord returns the offset of the first record that begins at or after pos.
+func nextRecord(source io.ReaderAt, pos, size int64, delimiter byte) (int64, error) {
+	buf := make([]byte, binaryBlockSize)
+	for offset := pos - 1; offset < size; {
+		n, err := source.ReadAt(buf, offset)
+		if i := bytes.IndexByte(buf[:n], delimiter); i >= 0 {
+			return offset + int64(i) + 1, nil
+		}
+		offset += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// grepRanges greps the ranges of the source in parallel and sends the results in order of the ranges.
+func (s *grepper) grepRanges(ctx context.Context, r Matcher, source SizedReaderAt, ranges [][2]int64) <-chan Result {
+	stats := s.newStats(len(ranges), source.Size())
+	// A range is scanned and matched in order by a worker
+	c := *s.config
+	c.threads = 1
+	c.binaryFiles = BinaryText // checked by splitRanges
+	g := &grepper{config: &c}
+	var (
+		resultC      = make(chan Result, s.config.resultBuffer())
+		iCtx, cancel = context.WithCancel(ctx)
+		limit        = &limits{
+			results: newLimiter(s.config.maxResults, cancel),
+			lines:   newLimiter(s.config.maxCount, cancel),
+			stats:   stats,
+		}
+		rangeCs = make([]chan Result, len(ranges))
+		counts  = make([]int, len(ranges)) // the number of the records of the ranges
+	)
+	stages, _ := g.compileStages() // validated
+	logger := s.config.log()
+	debugLog(logger, "grep started", "ranges", len(ranges), "size", source.Size())
+	start := s.config.clock.Now()
+	for i, x := range ranges {
+		rangeC := make(chan Result, s.config.resultBuffer())
+		rangeCs[i] = rangeC
+		i, x := i, x
+		supervise(rangeC, func() {
+			var src io.Reader = &rangeReader{
+				SectionReader: io.NewSectionReader(source, x[0], x[1]-x[0]),
+				base:          x[0],
+			}
+			if stats != nil {
+				src = &countingReader{r: src, n: &stats.bytesRead}
+			}
+			if s.reads != nil {
+				src = newDeadlineReader(iCtx, src, s.reads)
+			}
+			p, src := g.newPipeline(r, stages, src, func(r Result) { rangeC <- r }, limit)
+			if stats != nil {
+				p.Observer = pipeline.ObserverFunc(func(_ int, chunk []pipeline.Record, elapsed time.Duration) {
+					stats.ObserveChunk(i, chunk, elapsed)
+				})
+			}
+			var rangeLogger *slog.Logger
+			if logger != nil {
+				rangeLogger = logger.With("range", i)
+				p.Observer = &logObserver{
+					Observer: p.Observer,
+					logger:   rangeLogger,
+				}
+			}
+			err := p.Run(iCtx, src)
+			counts[i] = p.Splitter.(*scanSplitter).count
+			switch {
+			case err == nil:
+			case isDone(iCtx):
+				debugLog(rangeLogger, "grep canceled", "cause", cancellationError(iCtx))
+			case errors.As(err, new(*PanicError)):
+				debugLog(rangeLogger, "grep panicked", "err", err)
+				rangeC <- newErrResult(wrapErr(err, "Grepper recovered"))
+				cancel() // stop the other ranges
+			default:
+				e := &ScanError{
+					Line: counts[i] + 1,
+					Err:  err,
+				}
+				debugLog(rangeLogger, "scan error", "err", e)
+				rangeC <- newErrResult(e)
+				cancel()
+			}
+		}, cancel)
+	}
+	supervise(resultC, func() {
+		defer cancel()
+		if s.done != nil {
+			defer s.done()
+		}
+		var base int // the number of the records before the range
+		for i, rangeC := range rangeCs {
+			for r := range rangeC {
+				if x, ok := r.(*result); ok {
+					if x.line > 0 {
+						x.line += base
+					}
+					var e *ScanError
+					if errors.As(x.err, &e) {
+						e.Line += base
+					}
+				}
+				resultC <- s.tagged(r)
+			}
+			base += counts[i]
+		}
+		if !limit.reached() && isDone(ctx) {
+			resultC <- s.tagged(newErrResult(wrapErr(cancellationError(ctx), "Grepper")))
+		}
+		debugLog(logger, "grep finished", "lines", base, "elapsed", s.config.clock.Now().Sub(start))
+		s.collectStats(ctx, stats, limit)
+	}, func() {
+		cancel()
+		for _, rangeC := range rangeCs {
+			drainResults(rangeC)
+		}
+	})
+	return resultC
+}
+
+// rangeReader reads a range of the source and maps the offsets into the source.
+type rangeReader struct {
+	*io.SectionReader
+	base int64
+}
+
+func (s *rangeReader) MapOffset(pos int64) int64 { return s.base + pos }
@@ -0,0 +1,157 @@
+package gogrep_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrepReaderAt(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 200000; i++ {
+		fmt.Fprintf(&b, "line %d %s\n", i, strings.Repeat("x", i%40))
+	}
+	source := b.String()
+
+	type line struct {
+		text   string
+		line   int
+		offset int64
+	}
+	collect := func(resultC <-chan gogrep.Result) []line {
+		var r []line
+		for x := range resultC {
+			assert.Nil(t, x.Err())
+			r = append(r, line{
+				text:   x.Text(),
+				line:   x.Line(),
+				offset: x.Offset(),
+			})
+		}
+		return r
+	}
+	sequential := func(regex string) []line {
+		resultC, err := gogrep.New().Grep(context.TODO(), regex, strings.NewReader(source))
+		assert.Nil(t, err)
+		r := collect(resultC)
+		sort.Slice(r, func(i, j int) bool { return r[i].line < r[j].line })
+		return r
+	}
+
+	t.Run("ranges", func(t *testing.T) {
+		var stats gogrep.Stats
+		resultC, err := gogrep.New(
+			gogrep.WithThreads(4),
+			gogrep.WithStatsCollector(func(s gogrep.Stats) { stats = s }),
+		).GrepReaderAt(context.TODO(), []string{"^line [0-9]*7 "}, strings.NewReader(source))
+		assert.Nil(t, err)
+		got := collect(resultC)
+		assert.Equal(t, 20000, len(got))
+		assert.Equal(t, sequential("^line [0-9]*7 "), got, "in order")
+		assert.Equal(t, int64(200000), stats.LinesScanned)
+		assert.Equal(t, int64(len(source)), stats.BytesRead)
+		assert.Equal(t, 4, len(stats.Workers))
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithThreads(4)).GrepSources(context.TODO(), []string{"^line 1999.. "}, []gogrep.NamedSource{
+			{Name: "a", Reader: strings.NewReader(source)},
+			{Name: "b", Reader: strings.NewReader(source)},
+		})
+		assert.Nil(t, err)
+		var got []string
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, fmt.Sprintf("%s:%d", r.Source(), r.Line()))
+		}
+		assert.Equal(t, 200, len(got))
+		assert.Equal(t, "a:199901", got[0])
+		assert.Equal(t, "b:200000", got[199])
+	})
+
+	t.Run("concurrency hint", func(t *testing.T) {
+		var (
+			mux     sync.Mutex
+			workers []int // of the sources
+			hints   = map[string]int64{}
+		)
+		resultC, err := gogrep.New(
+			gogrep.WithThreads(2),
+			gogrep.WithStatsCollector(func(s gogrep.Stats) {
+				mux.Lock()
+				defer mux.Unlock()
+				workers = append(workers, len(s.Workers))
+			}),
+			gogrep.WithSourceConcurrencyHint(func(name string, size int64) int {
+				mux.Lock()
+				defer mux.Unlock()
+				hints[name] = size
+				if name == "big" {
+					return 5
+				}
+				return 0
+			}),
+		).GrepSources(context.TODO(), []string{"^line 1999.. "}, []gogrep.NamedSource{
+			{Name: "big", Reader: strings.NewReader(source)},
+			{Name: "small", Reader: strings.NewReader(source)},
+		})
+		assert.Nil(t, err)
+		var got int
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got++
+		}
+		assert.Equal(t, 200, got)
+		assert.Equal(t, map[string]int64{"big": int64(len(source)), "small": int64(len(source))}, hints)
+		sort.Ints(workers)
+		assert.Equal(t, []int{2, 5}, workers)
+	})
+
+	t.Run("max count", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithThreads(4), gogrep.WithMaxCount(10)).
+			GrepReaderAt(context.TODO(), []string{"line"}, strings.NewReader(source))
+		assert.Nil(t, err)
+		assert.Equal(t, 10, len(collect(resultC)))
+	})
+
+	t.Run("sequential", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithThreads(4), gogrep.WithMultiline()).
+			GrepReaderAt(context.TODO(), []string{"line 5 x+\nline 6 "}, strings.NewReader(source))
+		assert.Nil(t, err)
+		assert.Equal(t, []line{
+			{text: "line 5 xxxxx\nline 6 xxxxxx", line: 6, offset: int64(strings.Index(source, "line 5 "))},
+		}, collect(resultC))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		_, err := gogrep.New().GrepReaderAt(ctx, []string{"line"}, strings.NewReader(source))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func BenchmarkGrepReaderAt(b *testing.B) {
+	data := strings.Join(dupStrings(1<<18, "allocation", "freeable", "cached", "dirty", "flush memory", "NAND", "ready to write"), "\n")
+	for i := 0; i <= 3; i++ {
+		threads := 1 << i
+		b.Run(fmt.Sprintf("with %d threads", threads), func(b *testing.B) {
+			g := gogrep.New(gogrep.WithThreads(threads))
+			b.SetBytes(int64(len(data)))
+			for n := 0; n < b.N; n++ {
+				resultC, err := g.GrepReaderAt(context.TODO(), []string{"[cf].+sh"}, strings.NewReader(data))
+				if err != nil {
+					b.Fatal(err)
+				}
+				for range resultC {
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package gogrep
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithRegexCacheSize caches the matchers of the regexes compiled by the Grepper up to the size,
+// evicting the least recently used ones, so that the greps by the same regexes skip the compiles
+// and the derivations of the literals of the prefilters, e.g. of a service that greps by a small set of patterns repeatedly.
+// The matchers are cached by the regexes with the options that compile them, WithEngine, WithFuzzy,
+// WithNormalization, WithCaseFolding and WithoutPrefilter, and shared by the greps of the Grepper,
+// including the options of the sources of GrepSources.
+// Not positive size disables the cache, the default.
+func WithRegexCacheSize(size int) Option {
+	return func(c *Config) {
+		if size <= 0 {
+			c.regexCache = nil
+			return
+		}
+		c.regexCache = newRegexCache(size)
+	}
+}
+
+// regexCacheKey is the regex with the options that compile it.
+type regexCacheKey struct {
+	regex         string
+	engine        Engine
+	fuzzy         int
+	normalization Normalization
+	caseFolding   bool
+	noPrefilter   bool
+}
+
+func (c *Config) regexCacheKey(regex string) regexCacheKey {
+	return regexCacheKey{
+		regex:         regex,
+		engine:        c.engine,
+		fuzzy:         c.fuzzy,
+		normalization: c.normalization,
+		caseFolding:   c.caseFolding,
+		noPrefilter:   c.noPrefilter,
+	}
+}
+
+// regexCache is the LRU cache of the compiled matchers.
+type regexCache struct {
+	mux   sync.Mutex
+	size  int
+	order *list.List // of *regexCacheEntry, the most recently used first
+	items map[regexCacheKey]*list.Element
+}
+
+type regexCacheEntry struct {
+	key     regexCacheKey
+	matcher Matcher
+}
+
+func newRegexCache(size int) *regexCache {
+	return &regexCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[regexCacheKey]*list.Element, size),
+	}
+}
+
+func (s *regexCache) get(key regexCacheKey) (Matcher, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(e)
+	return e.Value.(*regexCacheEntry).matcher, true
+}
+
+func (s *regexCache) add(key regexCacheKey, matcher Matcher) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if e, ok := s.items[key]; ok {
+		// Compiled by another grep meanwhile
+		s.order.MoveToFront(e)
+		return
+	}
+	s.items[key] = s.order.PushFront(&regexCacheEntry{
+		key:     key,
+		matcher: matcher,
+	})
+	if s.order.Len() > s.size {
+		e := s.order.Back()
+		s.order.Remove(e)
+		delete(s.items, e.Value.(*regexCacheEntry).key)
+	}
+}
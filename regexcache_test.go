@@ -0,0 +1,117 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingEngine compiles the patterns as EngineRegexp does, counting the compiles.
+const countingEngine gogrep.Engine = "counting"
+
+var countingCompiles int64
+
+func init() {
+	gogrep.RegisterEngine(countingEngine, func(pattern string) (gogrep.Matcher, error) {
+		atomic.AddInt64(&countingCompiles, 1)
+		return gogrep.EngineRegexp.Compile(pattern)
+	})
+}
+
+func TestWithRegexCacheSize(t *testing.T) {
+	grep := func(t *testing.T, g gogrep.Grepper, regex string) []string {
+		resultC, err := g.Grep(context.TODO(), regex, strings.NewReader("a\nb\nc\nab\n"))
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		var got []string
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, r.Text())
+		}
+		return got
+	}
+	compiles := func(f func()) int64 {
+		n := atomic.LoadInt64(&countingCompiles)
+		f()
+		return atomic.LoadInt64(&countingCompiles) - n
+	}
+
+	t.Run("lru", func(t *testing.T) {
+		g := gogrep.New(gogrep.WithEngine(countingEngine), gogrep.WithRegexCacheSize(2), gogrep.WithThreads(1))
+		for _, tc := range []struct {
+			regex    string
+			want     []string
+			compiles int64
+		}{
+			{regex: "a", want: []string{"a", "ab"}, compiles: 1},
+			{regex: "a", want: []string{"a", "ab"}},
+			{regex: "b", want: []string{"b", "ab"}, compiles: 1},
+			{regex: "a", want: []string{"a", "ab"}},
+			{regex: "c", want: []string{"c"}, compiles: 1}, // evicts b
+			{regex: "a", want: []string{"a", "ab"}},
+			{regex: "b", want: []string{"b", "ab"}, compiles: 1},
+		} {
+			var got []string
+			assert.Equal(t, tc.compiles, compiles(func() { got = grep(t, g, tc.regex) }), tc.regex)
+			assert.Equal(t, tc.want, got, tc.regex)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		g := gogrep.New(gogrep.WithEngine(countingEngine), gogrep.WithRegexCacheSize(10), gogrep.WithThreads(1))
+		assert.Equal(t, int64(1), compiles(func() { grep(t, g, "^a") }))
+		g = gogrep.New(gogrep.WithEngine(countingEngine), gogrep.WithRegexCacheSize(10), gogrep.WithCaseFolding(), gogrep.WithThreads(1))
+		assert.Equal(t, int64(1), compiles(func() {
+			resultC, err := g.GrepSources(context.TODO(), []string{"A"}, []gogrep.NamedSource{
+				{Name: "x", Reader: strings.NewReader("a\n")},
+				{Name: "y", Reader: strings.NewReader("a\n"), Options: []gogrep.Option{gogrep.WithPipeline(gogrep.MatchRegex("A"))}},
+			})
+			if !assert.Nil(t, err) {
+				return
+			}
+			var n int
+			for r := range resultC {
+				assert.Nil(t, r.Err())
+				n++
+			}
+			assert.Equal(t, 2, n)
+		}), "the stage shares the regex of the same options")
+	})
+
+	t.Run("bad pattern", func(t *testing.T) {
+		g := gogrep.New(gogrep.WithEngine(countingEngine), gogrep.WithRegexCacheSize(10))
+		for i := 0; i < 2; i++ {
+			_, err := g.Grep(context.TODO(), "(", strings.NewReader(""))
+			assert.True(t, errors.Is(err, gogrep.ErrBadPattern), "%v", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		g := gogrep.New(gogrep.WithEngine(countingEngine), gogrep.WithRegexCacheSize(10), gogrep.WithRegexCacheSize(0))
+		assert.Equal(t, int64(2), compiles(func() {
+			grep(t, g, "a")
+			grep(t, g, "a")
+		}))
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		g := gogrep.New(gogrep.WithRegexCacheSize(2))
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			regex := []string{"a", "b", "c"}[i%3]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.Contains(t, grep(t, g, regex), regex)
+			}()
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,45 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+	"regexp"
+)
+
+// Replace copies the lines of source to dst replacing the matches of regex in each line by template,
+// where $1 or ${name} in the template is the capture group as regexp.Regexp.Expand does.
+// The lines without matches are copied as they are,
+// and the line endings and the BOM are kept as RewriteLines does with LineEndingPreserve.
+// Returns the number of the lines replaced.
+func Replace(ctx context.Context, regex, template string, source io.Reader, dst io.Writer) (int, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return 0, badPattern(err, "Replace", regex)
+	}
+	return ReplaceLines(ctx, []*regexp.Regexp{re}, template, source, dst, LineEndingPreserve)
+}
+
+// ReplaceLines copies the lines of source to dst replacing the matches of the regexes in each line by template,
+// applying the regexes in order to the line replaced by the previous ones, like the commands of sed.
+// The line endings are kept or converted by the LineEnding.
+// Returns the number of the lines replaced.
+func ReplaceLines(ctx context.Context, regexes []*regexp.Regexp, template string, source io.Reader, dst io.Writer, ending LineEnding) (int, error) {
+	var n int
+	err := RewriteLines(source, dst, ending, func(line string) (string, error) {
+		if isDone(ctx) {
+			return "", wrapErr(cancellationError(ctx), "Replace")
+		}
+		var replaced bool
+		for _, re := range regexes {
+			if re.MatchString(line) {
+				replaced = true
+				line = re.ReplaceAllString(line, template)
+			}
+		}
+		if replaced {
+			n++
+		}
+		return line, nil
+	})
+	return n, err
+}
@@ -0,0 +1,61 @@
+package gogrep_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplace(t *testing.T) {
+	for _, tc := range []struct {
+		title    string
+		regex    string
+		template string
+		input    string
+		want     string
+		n        int
+	}{
+		{"empty", "a", "b", "", "", 0},
+		{"not matched", "x", "y", "a\r\nb\n", "a\r\nb\n", 0},
+		{"all matches", "a", "b", "aa\nca\r\nc", "bb\ncb\r\nc", 2},
+		{"capture", `(\w+)=(\w+)`, "$2=$1", "\xef\xbb\xbfk=v\nx\n", "\xef\xbb\xbfv=k\nx\n", 1},
+		{"named capture", `(?P<key>\w+)=`, "${key}:", "k=v", "k:v", 1},
+		{"delete", `\s+$`, "", "a  \r\nb\t\n", "a\r\nb\n", 2},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			var b bytes.Buffer
+			n, err := gogrep.Replace(context.TODO(), tc.regex, tc.template, strings.NewReader(tc.input), &b)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.n, n)
+			assert.Equal(t, tc.want, b.String())
+		})
+	}
+
+	t.Run("invalid regex", func(t *testing.T) {
+		_, err := gogrep.Replace(context.TODO(), "(", "", strings.NewReader("a"), &bytes.Buffer{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		_, err := gogrep.Replace(ctx, "a", "b", strings.NewReader("a\n"), &bytes.Buffer{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestReplaceLines(t *testing.T) {
+	var (
+		b       bytes.Buffer
+		regexes = []*regexp.Regexp{regexp.MustCompile("a"), regexp.MustCompile("b")}
+	)
+	n, err := gogrep.ReplaceLines(context.TODO(), regexes, "b", strings.NewReader("a\r\nc\nb\n"), &b, gogrep.LineEndingLF)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "b\nc\nb\n", b.String())
+}
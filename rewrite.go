@@ -0,0 +1,96 @@
+package gogrep
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LineEnding is the policy for the line endings of the rewritten lines.
+type LineEnding string
+
+const (
+	// LineEndingPreserve keeps the LF or CRLF of each line as it is.
+	LineEndingPreserve LineEnding = "preserve"
+	// LineEndingLF terminates the lines by LF.
+	LineEndingLF LineEnding = "lf"
+	// LineEndingCRLF terminates the lines by CRLF.
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+// ErrUnknownLineEnding means the LineEnding is not one of the constants.
+var ErrUnknownLineEnding = errors.New("unknown line ending")
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// DetectLineEnding returns LineEndingCRLF if the first line of data ends with CRLF,
+// and LineEndingLF otherwise.
+func DetectLineEnding(data []byte) LineEnding {
+	if i := bytes.IndexByte(data, '\n'); i > 0 && data[i-1] == '\r' {
+		return LineEndingCRLF
+	}
+	return LineEndingLF
+}
+
+// RewriteLines copies the lines of r to w replacing each line by f.
+// f receives the line without the line ending and the BOM of UTF-8 at the beginning of r,
+// and the BOM is written back as it is.
+// The line ending of each line is kept or converted by the LineEnding.
+// The last line without a line ending is written without adding one.
+// The lines are not limited in length.
+func RewriteLines(r io.Reader, w io.Writer, ending LineEnding, f func(line string) (string, error)) error {
+	switch ending {
+	case LineEndingPreserve, LineEndingLF, LineEndingCRLF:
+	default:
+		return fmt.Errorf("%w %s", ErrUnknownLineEnding, ending)
+	}
+	var (
+		br = bufio.NewReader(r)
+		bw = bufio.NewWriter(w)
+	)
+	if head, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(head, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+		_, _ = bw.Write(utf8BOM)
+	}
+	for {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if line == "" {
+			break
+		}
+		var eol string
+		switch {
+		case strings.HasSuffix(line, "\r\n"):
+			line, eol = line[:len(line)-2], "\r\n"
+		case strings.HasSuffix(line, "\n"):
+			line, eol = line[:len(line)-1], "\n"
+		}
+		if eol != "" {
+			switch ending {
+			case LineEndingLF:
+				eol = "\n"
+			case LineEndingCRLF:
+				eol = "\r\n"
+			}
+		}
+		replaced, err := f(line)
+		if err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(replaced); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(eol); err != nil {
+			return err
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	return bw.Flush()
+}
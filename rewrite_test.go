@@ -0,0 +1,67 @@
+package gogrep_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteLines(t *testing.T) {
+	upper := func(line string) (string, error) { return strings.ToUpper(line), nil }
+	for _, tc := range []struct {
+		title  string
+		input  string
+		ending gogrep.LineEnding
+		want   string
+	}{
+		{"empty", "", gogrep.LineEndingPreserve, ""},
+		{"lf", "a\nb\n", gogrep.LineEndingPreserve, "A\nB\n"},
+		{"crlf", "a\r\nb\r\n", gogrep.LineEndingPreserve, "A\r\nB\r\n"},
+		{"mixed", "a\r\nb\nc\r\nd", gogrep.LineEndingPreserve, "A\r\nB\nC\r\nD"},
+		{"mixed to lf", "a\r\nb\nc\r\nd", gogrep.LineEndingLF, "A\nB\nC\nD"},
+		{"mixed to crlf", "a\r\nb\nc\r\nd\n", gogrep.LineEndingCRLF, "A\r\nB\r\nC\r\nD\r\n"},
+		{"lone cr", "a\rb\n", gogrep.LineEndingPreserve, "A\rB\n"},
+		{"empty lines", "\n\r\n\n", gogrep.LineEndingPreserve, "\n\r\n\n"},
+		{"bom", "\xef\xbb\xbfa\r\nb", gogrep.LineEndingPreserve, "\xef\xbb\xbfA\r\nB"},
+		{"bom only", "\xef\xbb\xbf", gogrep.LineEndingLF, "\xef\xbb\xbf"},
+		{"long line", strings.Repeat("a", 1<<20) + "\r\n", gogrep.LineEndingLF, strings.Repeat("A", 1<<20) + "\n"},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			var b bytes.Buffer
+			assert.Nil(t, gogrep.RewriteLines(strings.NewReader(tc.input), &b, tc.ending, upper))
+			assert.Equal(t, tc.want, b.String())
+		})
+	}
+
+	t.Run("bom is not passed", func(t *testing.T) {
+		var lines []string
+		assert.Nil(t, gogrep.RewriteLines(strings.NewReader("\xef\xbb\xbfa\n"), &bytes.Buffer{}, gogrep.LineEndingPreserve, func(line string) (string, error) {
+			lines = append(lines, line)
+			return line, nil
+		}))
+		assert.Equal(t, []string{"a"}, lines)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errLine := errors.New("line")
+		err := gogrep.RewriteLines(strings.NewReader("a\n"), &bytes.Buffer{}, gogrep.LineEndingPreserve, func(string) (string, error) {
+			return "", errLine
+		})
+		assert.ErrorIs(t, err, errLine)
+	})
+
+	t.Run("unknown ending", func(t *testing.T) {
+		err := gogrep.RewriteLines(strings.NewReader("a\n"), &bytes.Buffer{}, "cr", upper)
+		assert.ErrorIs(t, err, gogrep.ErrUnknownLineEnding)
+	})
+}
+
+func TestDetectLineEnding(t *testing.T) {
+	assert.Equal(t, gogrep.LineEndingLF, gogrep.DetectLineEnding([]byte("a\nb\r\n")))
+	assert.Equal(t, gogrep.LineEndingCRLF, gogrep.DetectLineEnding([]byte("a\r\nb\n")))
+	assert.Equal(t, gogrep.LineEndingLF, gogrep.DetectLineEnding([]byte("a")))
+}
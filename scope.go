@@ -0,0 +1,207 @@
+package gogrep
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Scope is a kind of the parts of source code.
+	Scope string
+	// Language is a programming language that Scope understands.
+	Language string
+)
+
+const (
+	// ScopeComments limits matching to comments.
+	ScopeComments Scope = "comments"
+	// ScopeStrings limits matching to string literals.
+	ScopeStrings Scope = "strings"
+	// ScopeCode limits matching to the parts other than comments and string literals.
+	ScopeCode Scope = "code"
+)
+
+const (
+	LanguageGo         Language = "go"
+	LanguagePython     Language = "python"
+	LanguageJavaScript Language = "javascript"
+)
+
+type (
+	stringSyntax struct {
+		open, close string
+		escape      bool
+		multiline   bool
+	}
+	langSyntax struct {
+		lineComments  []string
+		blockComments [][2]string
+		strings       []stringSyntax // longer delimiters first
+	}
+)
+
+var languages = map[Language]*langSyntax{
+	LanguageGo: {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings: []stringSyntax{
+			{open: `"`, close: `"`, escape: true},
+			{open: `'`, close: `'`, escape: true},
+			{open: "`", close: "`", multiline: true},
+		},
+	},
+	LanguagePython: {
+		lineComments: []string{"#"},
+		strings: []stringSyntax{
+			{open: `"""`, close: `"""`, escape: true, multiline: true},
+			{open: `'''`, close: `'''`, escape: true, multiline: true},
+			{open: `"`, close: `"`, escape: true},
+			{open: `'`, close: `'`, escape: true},
+		},
+	},
+	LanguageJavaScript: {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings: []stringSyntax{
+			{open: "`", close: "`", escape: true, multiline: true},
+			{open: `"`, close: `"`, escape: true},
+			{open: `'`, close: `'`, escape: true},
+		},
+	},
+}
+
+var languageExtensions = map[string]Language{
+	".go":  LanguageGo,
+	".py":  LanguagePython,
+	".js":  LanguageJavaScript,
+	".mjs": LanguageJavaScript,
+	".cjs": LanguageJavaScript,
+	".jsx": LanguageJavaScript,
+	".ts":  LanguageJavaScript,
+	".tsx": LanguageJavaScript,
+}
+
+// LanguageByPath detects the language from the file extension.
+func LanguageByPath(path string) (Language, bool) {
+	lang, ok := languageExtensions[strings.ToLower(filepath.Ext(path))]
+	return lang, ok
+}
+
+type lexState int
+
+const (
+	lexCode lexState = iota
+	lexBlockComment
+	lexString
+)
+
+// scopeLexer masks the parts of lines outside of the scope.
+// It is a lightweight lexer that tracks comments and string literals across lines.
+type scopeLexer struct {
+	syntax     *langSyntax
+	scope      Scope
+	state      lexState
+	blockClose string
+	str        stringSyntax
+}
+
+func newScopeLexer(lang Language, scope Scope) *scopeLexer {
+	return &scopeLexer{
+		syntax: languages[lang],
+		scope:  scope,
+	}
+}
+
+// mask replaces the bytes outside of the scope with spaces.
+func (s *scopeLexer) mask(line string) string {
+	var (
+		buf  = []byte(line)
+		mark = func(i, n int, scope Scope) {
+			if scope != s.scope {
+				blank(buf, i, n)
+			}
+		}
+	)
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		switch s.state {
+		case lexCode:
+			if s.startsWithLineComment(rest) {
+				mark(i, len(rest), ScopeComments)
+				i = len(line)
+				continue
+			}
+			if c, ok := s.startsWithBlockComment(rest); ok {
+				s.state = lexBlockComment
+				s.blockClose = c[1]
+				mark(i, len(c[0]), ScopeComments)
+				i += len(c[0])
+				continue
+			}
+			if x, ok := s.startsWithString(rest); ok {
+				s.state = lexString
+				s.str = x
+				mark(i, len(x.open), ScopeStrings)
+				i += len(x.open)
+				continue
+			}
+			mark(i, 1, ScopeCode)
+			i++
+		case lexBlockComment:
+			if strings.HasPrefix(rest, s.blockClose) {
+				s.state = lexCode
+				mark(i, len(s.blockClose), ScopeComments)
+				i += len(s.blockClose)
+				continue
+			}
+			mark(i, 1, ScopeComments)
+			i++
+		case lexString:
+			if s.str.escape && line[i] == '\\' {
+				mark(i, 2, ScopeStrings)
+				i += 2
+				continue
+			}
+			if strings.HasPrefix(rest, s.str.close) {
+				s.state = lexCode
+				mark(i, len(s.str.close), ScopeStrings)
+				i += len(s.str.close)
+				continue
+			}
+			mark(i, 1, ScopeStrings)
+			i++
+		}
+	}
+	if s.state == lexString && !s.str.multiline {
+		// Unterminated string literal ends at the end of the line
+		s.state = lexCode
+	}
+	return string(buf)
+}
+
+func (s *scopeLexer) startsWithLineComment(x string) bool {
+	for _, c := range s.syntax.lineComments {
+		if strings.HasPrefix(x, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scopeLexer) startsWithBlockComment(x string) ([2]string, bool) {
+	for _, c := range s.syntax.blockComments {
+		if strings.HasPrefix(x, c[0]) {
+			return c, true
+		}
+	}
+	return [2]string{}, false
+}
+
+func (s *scopeLexer) startsWithString(x string) (stringSyntax, bool) {
+	for _, c := range s.syntax.strings {
+		if strings.HasPrefix(x, c.open) {
+			return c, true
+		}
+	}
+	return stringSyntax{}, false
+}
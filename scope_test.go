@@ -0,0 +1,96 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope(t *testing.T) {
+	goSource := strings.Join([]string{
+		`// target in comment`,
+		`s := "target in string"`,
+		`target := 1 // tail`,
+		"r := `raw",
+		"target in raw string`",
+		`/* block`,
+		`target in block */ x := target`,
+		`c := '"' // target after rune`,
+	}, "\n")
+	pySource := strings.Join([]string{
+		`# target in comment`,
+		`s = """`,
+		`target in docstring"""`,
+		`target = 1`,
+	}, "\n")
+
+	for _, tc := range []*struct {
+		title  string
+		lang   gogrep.Language
+		scope  gogrep.Scope
+		source string
+		want   []string
+	}{
+		{
+			title:  "go comments",
+			lang:   gogrep.LanguageGo,
+			scope:  gogrep.ScopeComments,
+			source: goSource,
+			want: []string{
+				`// target in comment`,
+				`target in block */ x := target`,
+				`c := '"' // target after rune`,
+			},
+		},
+		{
+			title:  "go strings",
+			lang:   gogrep.LanguageGo,
+			scope:  gogrep.ScopeStrings,
+			source: goSource,
+			want: []string{
+				`s := "target in string"`,
+				"target in raw string`",
+			},
+		},
+		{
+			title:  "go code",
+			lang:   gogrep.LanguageGo,
+			scope:  gogrep.ScopeCode,
+			source: goSource,
+			want: []string{
+				`target := 1 // tail`,
+				`target in block */ x := target`,
+			},
+		},
+		{
+			title:  "python strings",
+			lang:   gogrep.LanguagePython,
+			scope:  gogrep.ScopeStrings,
+			source: pySource,
+			want: []string{
+				`target in docstring"""`,
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			resultC, err := gogrep.New(gogrep.WithThreads(1), gogrep.WithScope(tc.lang, tc.scope)).
+				Grep(context.TODO(), "target", strings.NewReader(tc.source))
+			assert.Nil(t, err)
+			got := []string{}
+			for r := range resultC {
+				assert.Nil(t, r.Err())
+				got = append(got, r.Text())
+			}
+			assert.ElementsMatch(t, tc.want, got)
+		})
+	}
+
+	t.Run("unknown scope", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithScope(gogrep.LanguageGo, "unknown")).Grep(context.TODO(), "x", nil)
+		assert.Contains(t, err.Error(), "unknown scope")
+	})
+}
@@ -0,0 +1,24 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+	"iter"
+)
+
+// GrepSeq returns the texts of the matches of regex in source like GrepAll, as an iterator.
+// An error is yielded with an empty text.
+// Breaking the loop stops the grep.
+func GrepSeq(ctx context.Context, g Grepper, regex string, source io.Reader) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		err := GrepFunc(ctx, g, regex, source, func(r Result) error {
+			if !yield(r.Text(), r.Err()) {
+				return Stop
+			}
+			return nil
+		})
+		if err != nil {
+			yield("", err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrepSeq(t *testing.T) {
+	source := func() *strings.Reader { return strings.NewReader(strings.Repeat("a\nb\n", 10000)) }
+
+	t.Run("all", func(t *testing.T) {
+		var n int
+		for text, err := range gogrep.GrepSeq(context.TODO(), gogrep.New(), "a", source()) {
+			assert.Nil(t, err)
+			assert.Equal(t, "a", text)
+			n++
+		}
+		assert.Equal(t, 10000, n)
+	})
+
+	t.Run("break", func(t *testing.T) {
+		var n int
+		for _, err := range gogrep.GrepSeq(context.TODO(), gogrep.New(), "a", source()) {
+			assert.Nil(t, err)
+			n++
+			if n == 3 {
+				break
+			}
+		}
+		assert.Equal(t, 3, n)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errSource := errors.New("source")
+		var errs []error
+		for text, err := range gogrep.GrepSeq(context.TODO(), gogrep.New(), "a", &errReader{err: errSource}) {
+			assert.Equal(t, "", text)
+			errs = append(errs, err)
+		}
+		if assert.Equal(t, 1, len(errs)) {
+			assert.ErrorIs(t, errs[0], errSource)
+		}
+	})
+
+	t.Run("invalid regex", func(t *testing.T) {
+		var errs []error
+		for _, err := range gogrep.GrepSeq(context.TODO(), gogrep.New(), "(", source()) {
+			errs = append(errs, err)
+		}
+		assert.Equal(t, 1, len(errs))
+	})
+}
@@ -0,0 +1,91 @@
+package gogrep
+
+import "context"
+
+// Summary is the last result of a grep with WithSequence.
+type Summary struct {
+	// Total is the number of the results sent before the summary.
+	Total int64
+	// Errors is the number of the results with the errors among them.
+	Errors int64
+}
+
+// WithSequence numbers the results in order of sending to the channel from 1, available by SequenceOf,
+// and sends the summary as the last result before the channel is closed, available by SummaryOf,
+// so that the consumers can detect the results dropped on the way and show the totals.
+// The summary is numbered next to the last result and returns empty Text and nil Err.
+// It is applied after WithUnique, WithSort and WithSink, and ignored in the options of NamedSource.
+func WithSequence() Option {
+	return func(c *Config) {
+		c.sequence = true
+	}
+}
+
+// SequenceOf returns the sequence number of the result by WithSequence, 0 if not numbered.
+func SequenceOf(r Result) int64 {
+	if x, ok := r.(*sequencedResult); ok {
+		return x.seq
+	}
+	return 0
+}
+
+// SummaryOf returns the summary if the result is the last one by WithSequence.
+func SummaryOf(r Result) (Summary, bool) {
+	if x, ok := r.(*sequencedResult); ok && x.summary != nil {
+		return *x.summary, true
+	}
+	return Summary{}, false
+}
+
+// sequencedResult is a Result with WithSequence.
+type sequencedResult struct {
+	Result
+	seq     int64
+	summary *Summary // nil unless the summary
+}
+
+func (s *sequencedResult) resultContext() *ResultContext { return sourceContext(s.Result) }
+
+// summaryResult is the Result of the summary.
+type summaryResult struct{}
+
+func (summaryResult) Text() string          { return "" }
+func (summaryResult) Err() error            { return nil }
+func (summaryResult) Line() int             { return 0 }
+func (summaryResult) Offset() int64         { return 0 }
+func (summaryResult) MatchRanges() [][2]int { return nil }
+func (summaryResult) Submatches() []string  { return nil }
+func (summaryResult) Source() string        { return "" }
+func (summaryResult) Tag() interface{}      { return nil }
+func (summaryResult) PatternID() []string   { return nil }
+func (summaryResult) Distance() int         { return 0 }
+
+// sequenceGrep numbers the results of the grep and sends the summary at the end.
+func sequenceGrep(c *Config, grep func(context.Context) (<-chan Result, error)) func(context.Context) (<-chan Result, error) {
+	return func(ctx context.Context) (<-chan Result, error) {
+		resultC, err := grep(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sequenceC := make(chan Result, c.resultBuffer())
+		supervise(sequenceC, func() {
+			var summary Summary
+			for r := range resultC {
+				summary.Total++
+				if r.Err() != nil {
+					summary.Errors++
+				}
+				sequenceC <- &sequencedResult{
+					Result: r,
+					seq:    summary.Total,
+				}
+			}
+			sequenceC <- &sequencedResult{
+				Result:  summaryResult{},
+				seq:     summary.Total + 1,
+				summary: &summary,
+			}
+		}, func() { drainResults(resultC) })
+		return sequenceC, nil
+	}
+}
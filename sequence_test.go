@@ -0,0 +1,59 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSequence(t *testing.T) {
+	t.Run("grep", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithSequence()).Grep(context.TODO(), "a", strings.NewReader("a1\nb\na2\na3\n"))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		if !assert.Equal(t, 4, len(results)) {
+			return
+		}
+		for i, r := range results {
+			assert.Equal(t, int64(i+1), gogrep.SequenceOf(r))
+		}
+		for _, r := range results[:3] {
+			assert.Nil(t, r.Err())
+			assert.True(t, strings.HasPrefix(r.Text(), "a"))
+			_, ok := gogrep.SummaryOf(r)
+			assert.False(t, ok)
+		}
+		summary, ok := gogrep.SummaryOf(results[3])
+		assert.True(t, ok)
+		assert.Equal(t, gogrep.Summary{Total: 3}, summary)
+		assert.Nil(t, results[3].Err())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithSequence(), gogrep.WithErrorPolicy(gogrep.ErrorContinue)).GrepSources(context.TODO(), []string{"a"}, []gogrep.NamedSource{
+			{Name: "x", Reader: strings.NewReader("a\n"), Labels: map[string]string{"k": "v"}},
+			{Name: "y", Reader: &errReader{err: errors.New("read")}},
+		})
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		if !assert.Equal(t, 3, len(results)) {
+			return
+		}
+		assert.Equal(t, map[string]string{"k": "v"}, gogrep.ContextOf(results[0]).Labels)
+		summary, ok := gogrep.SummaryOf(results[2])
+		assert.True(t, ok)
+		assert.Equal(t, gogrep.Summary{Total: 2, Errors: 1}, summary)
+	})
+
+	t.Run("without", func(t *testing.T) {
+		resultC, err := gogrep.New().Grep(context.TODO(), "a", strings.NewReader("a\n"))
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, int64(0), gogrep.SequenceOf(results[0]))
+	})
+}
@@ -0,0 +1,197 @@
+package gogrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+// Session greps the sources by the regexes compiled once by Grepper.Compile.
+// The lines of the greps are matched by the WithThreads workers shared by the greps of the session,
+// except for WithMultiline that matches the lines by a worker of each grep.
+type Session interface {
+	// Grep greps source as Grepper.GrepMulti does.
+	// The greps can run concurrently, and their results should be read concurrently
+	// since a grep waits for the workers blocked by the results of other greps not read.
+	Grep(ctx context.Context, source io.Reader) (<-chan Result, error)
+	// Close waits for the running greps and stops the workers.
+	// With WithDrainTimeout, the running greps not ended within the timeout are canceled
+	// with CancelDrain and Close returns *DrainError after they end.
+	// Grep fails after Close.
+	Close() error
+}
+
+// ErrSessionClosed is returned by Session.Grep after Close.
+var ErrSessionClosed = errors.New("session closed")
+
+// ErrDrainTimeout is the error of Session.Close that abandoned the running greps by WithDrainTimeout.
+var ErrDrainTimeout = errors.New("drain timeout")
+
+// DrainError is the error of Session.Close with the work dropped by WithDrainTimeout.
+type DrainError struct {
+	// Greps is the number of the running greps canceled.
+	Greps int
+	// Chunks is the number of the chunks of the lines dropped without matching after the cancel.
+	Chunks int64
+	// Lines is the number of the lines of the chunks dropped.
+	Lines int64
+}
+
+func (e *DrainError) Error() string {
+	return fmt.Sprintf("%v: canceled %d greps, dropped %d chunks of %d lines", ErrDrainTimeout, e.Greps, e.Chunks, e.Lines)
+}
+
+func (e *DrainError) Unwrap() error { return ErrDrainTimeout }
+
+// WithDrainTimeout makes Session.Close wait for the running greps up to the timeout,
+// and then cancel them not to wait for the chunks of the lines in flight, reporting the dropped work by *DrainError.
+// Not positive timeout means Close waits for the greps to end.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.drainTimeout = timeout
+	}
+}
+
+// dropCounter counts the work dropped by the cancel.
+type dropCounter struct {
+	chunks int64
+	lines  int64
+}
+
+// dropObserver is the Observer of the grep that counts the dropped chunks.
+type dropObserver struct {
+	pipeline.Observer // nil without the stats
+	drops             *dropCounter
+}
+
+func (s *dropObserver) ObserveChunk(worker int, chunk []pipeline.Record, elapsed time.Duration) {
+	if s.Observer != nil {
+		s.Observer.ObserveChunk(worker, chunk, elapsed)
+	}
+}
+
+func (s *dropObserver) ObserveDrop(chunk []pipeline.Record) {
+	atomic.AddInt64(&s.drops.chunks, 1)
+	atomic.AddInt64(&s.drops.lines, int64(len(chunk)))
+}
+
+func (s *grepper) Compile(regexes ...string) (Session, error) {
+	if err := s.checkRegexes(regexes); err != nil {
+		return nil, err
+	}
+	r, err := s.compileMulti(regexes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.config.validate(); err != nil {
+		return nil, err
+	}
+	return &session{
+		grepper: s,
+		matcher: r,
+		pool:    pipeline.NewPool(s.config.threads),
+		cancels: map[int]context.CancelCauseFunc{},
+	}, nil
+}
+
+type session struct {
+	grepper *grepper
+	matcher Matcher
+	pool    *pipeline.Pool
+	drops   dropCounter
+
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+	cancels map[int]context.CancelCauseFunc // of the running greps by the ids
+	nextID  int
+}
+
+func (s *session) Grep(ctx context.Context, source io.Reader) (<-chan Result, error) {
+	// Already canceled
+	if isDone(ctx) {
+		return nil, wrapErr(cancellationError(ctx), "Session")
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, wrapErr(ErrSessionClosed, "Session")
+	}
+	s.running.Add(1)
+	ctx, cancel := context.WithCancelCause(ctx)
+	id := s.nextID
+	s.nextID++
+	s.cancels[id] = cancel
+	s.mu.Unlock()
+
+	done := func() {
+		s.mu.Lock()
+		delete(s.cancels, id)
+		s.mu.Unlock()
+		cancel(nil)
+		s.running.Done()
+	}
+	g := &grepper{
+		config: s.grepper.config,
+		pool:   s.pool,
+		done:   done,
+		drops:  &s.drops,
+	}
+	resultC, err := g.grepMatcher(ctx, s.matcher, source)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return resultC, nil
+}
+
+func (s *session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	defer s.pool.Close()
+
+	timeout := s.grepper.config.drainTimeout
+	if timeout <= 0 {
+		s.running.Wait()
+		return nil
+	}
+	doneC := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(doneC)
+	}()
+	select {
+	case <-doneC:
+		return nil
+	case <-s.grepper.config.clock.After(timeout):
+	}
+
+	s.mu.Lock()
+	err := &DrainError{Greps: len(s.cancels)}
+	var (
+		chunks = atomic.LoadInt64(&s.drops.chunks)
+		lines  = atomic.LoadInt64(&s.drops.lines)
+	)
+	for _, cancel := range s.cancels {
+		cancel(CancelCause(CancelDrain))
+	}
+	s.mu.Unlock()
+	<-doneC
+	err.Chunks = atomic.LoadInt64(&s.drops.chunks) - chunks
+	err.Lines = atomic.LoadInt64(&s.drops.lines) - lines
+	if err.Greps == 0 {
+		return nil // ended just at the timeout
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package gogrep_test
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/berquerant/gogrep/gogreptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	const source = "apple\nbanana\ncherry\navocado\n"
+
+	t.Run("concurrent greps", func(t *testing.T) {
+		s, err := gogrep.New(gogrep.WithThreads(2)).Compile("an", "ch")
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resultC, err := s.Grep(context.TODO(), strings.NewReader(source))
+				if !assert.Nil(t, err) {
+					return
+				}
+				var texts []string
+				for r := range resultC {
+					assert.Nil(t, r.Err())
+					texts = append(texts, r.Text())
+				}
+				sort.Strings(texts)
+				assert.Equal(t, []string{"banana", "cherry"}, texts)
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("multiline", func(t *testing.T) {
+		s, err := gogrep.New(gogrep.WithMultiline()).Compile(`e\nb`)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		resultC, err := s.Grep(context.TODO(), strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var texts []string
+		for r := range resultC {
+			texts = append(texts, r.Text())
+		}
+		assert.Equal(t, []string{"apple\nbanana"}, texts)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		s, err := gogrep.New().Compile("a")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Nil(t, s.Close())
+		assert.Nil(t, s.Close())
+		_, err = s.Grep(context.TODO(), strings.NewReader(source))
+		assert.ErrorIs(t, err, gogrep.ErrSessionClosed)
+	})
+
+	t.Run("drain timeout", func(t *testing.T) {
+		// A chunk of 100 lines takes 1s to match
+		engine := gogreptest.Engine("slow", gogreptest.MatcherFunc(func(string) bool {
+			time.Sleep(10 * time.Millisecond)
+			return true
+		}))
+		s, err := gogrep.New(
+			gogrep.WithEngine(engine),
+			gogrep.WithThreads(1),
+			gogrep.WithDrainTimeout(50*time.Millisecond),
+		).Compile("x")
+		if !assert.Nil(t, err) {
+			return
+		}
+		resultC, err := s.Grep(context.TODO(), strings.NewReader(strings.Repeat("x\n", 1000)))
+		if !assert.Nil(t, err) {
+			return
+		}
+		resultsC := make(chan []gogrep.Result)
+		go func() {
+			resultsC <- toResultSlice(resultC)
+		}()
+		start := time.Now()
+		err = s.Close()
+		assert.Less(t, time.Since(start), time.Second)
+		assert.ErrorIs(t, err, gogrep.ErrDrainTimeout)
+		var drainErr *gogrep.DrainError
+		if assert.ErrorAs(t, err, &drainErr) {
+			assert.Equal(t, 1, drainErr.Greps)
+			assert.Greater(t, drainErr.Chunks, int64(0))
+			assert.Equal(t, drainErr.Chunks*100, drainErr.Lines)
+		}
+		results := <-resultsC
+		if assert.Greater(t, len(results), 0) {
+			assert.Equal(t, gogrep.CancelDrain, gogrep.CancellationReasonOf(results[len(results)-1].Err()))
+		}
+	})
+
+	t.Run("drained in time", func(t *testing.T) {
+		s, err := gogrep.New(gogrep.WithDrainTimeout(time.Second)).Compile("an")
+		if !assert.Nil(t, err) {
+			return
+		}
+		resultC, err := s.Grep(context.TODO(), strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 1, len(toResultSlice(resultC)))
+		assert.Nil(t, s.Close())
+	})
+
+	t.Run("invalid regex", func(t *testing.T) {
+		_, err := gogrep.New().Compile("(")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("no regexes", func(t *testing.T) {
+		_, err := gogrep.New().Compile()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGrepRegexp(t *testing.T) {
+	resultC, err := gogrep.New(gogrep.WithEngine(gogrep.EngineFixed)).GrepRegexp(
+		context.TODO(),
+		regexp.MustCompile(`^a.*e$`),
+		strings.NewReader("apple\nbanana\navocado\n"),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var texts []string
+	for r := range resultC {
+		assert.Nil(t, r.Err())
+		texts = append(texts, r.Text())
+	}
+	sort.Strings(texts)
+	assert.Equal(t, []string{"apple"}, texts)
+}
@@ -0,0 +1,214 @@
+package gogrep
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Sink is the destination of the results written by WithSink.
+// The results of a grep are written by a goroutine, and Flush is called after the last result of the grep.
+// A Sink shared by concurrent greps should be safe for concurrent use.
+type Sink interface {
+	// Write writes the result without errors.
+	Write(r Result) error
+	// Flush writes the results buffered by Write.
+	Flush() error
+}
+
+// SinkFunc is a Sink that calls the function for each result.
+type SinkFunc func(r Result) error
+
+func (f SinkFunc) Write(r Result) error { return f(r) }
+func (SinkFunc) Flush() error           { return nil }
+
+// NewChannelSink returns the Sink that sends the results to the channel.
+// Write blocks until the result is received.
+func NewChannelSink(c chan<- Result) Sink {
+	return SinkFunc(func(r Result) error {
+		c <- r
+		return nil
+	})
+}
+
+// NewWriterSink returns the Sink that writes the text of each result to w as a line.
+// The lines are buffered until Flush.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{
+		w: bufio.NewWriter(w),
+	}
+}
+
+type writerSink struct {
+	mux sync.Mutex
+	w   *bufio.Writer
+}
+
+func (s *writerSink) Write(r Result) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, err := s.w.WriteString(r.Text()); err != nil {
+		return err
+	}
+	return s.w.WriteByte('\n')
+}
+
+func (s *writerSink) Flush() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.w.Flush()
+}
+
+// WebhookResult is a result posted by the Sink of NewWebhookSink.
+// The labels, the size and the modification time are of ResultContext of the result.
+type WebhookResult struct {
+	Source     string            `json:"source,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Size       int64             `json:"size,omitempty"`
+	ModTime    *time.Time        `json:"mod_time,omitempty"`
+	Line       int               `json:"line"`
+	Offset     int64             `json:"offset"`
+	Text       string            `json:"text"`
+	Submatches []string          `json:"submatches,omitempty"`
+}
+
+// NewWebhookSink returns the Sink that posts the results buffered until Flush to the url
+// as a JSON array of WebhookResult.
+// Nothing is posted if no results are buffered.
+// The response other than 2xx is an error.
+// Nil client means http.DefaultClient.
+func NewWebhookSink(url string, client *http.Client) Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &webhookSink{
+		url:    url,
+		client: client,
+	}
+}
+
+type webhookSink struct {
+	url     string
+	client  *http.Client
+	mux     sync.Mutex
+	results []*WebhookResult
+}
+
+func (s *webhookSink) Write(r Result) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c := ContextOf(r)
+	x := &WebhookResult{
+		Source:     c.Source,
+		Labels:     c.Labels,
+		Line:       r.Line(),
+		Offset:     r.Offset(),
+		Text:       r.Text(),
+		Submatches: r.Submatches(),
+	}
+	if c.Info != nil {
+		t := c.Info.ModTime()
+		x.Size = c.Info.Size()
+		x.ModTime = &t
+	}
+	s.results = append(s.results, x)
+	return nil
+}
+
+func (s *webhookSink) Flush() error {
+	s.mux.Lock()
+	results := s.results
+	s.results = nil
+	s.mux.Unlock()
+	if len(results) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s responded %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+// writeSink writes the results of the grep to the sink and returns the channel of the errors,
+// closed after the sink is flushed.
+// The sink is flushed by the FlushPolicy too.
+// The grep is canceled if the sink fails.
+func writeSink(ctx context.Context, c *Config, grep func(context.Context) (<-chan Result, error)) (<-chan Result, error) {
+	iCtx, cancel := context.WithCancel(ctx)
+	resultC, err := grep(iCtx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	errC := make(chan Result, 1)
+	supervise(errC, func() {
+		defer cancel()
+		var (
+			sink    = c.sink
+			policy  = c.flush()
+			counter = &flushCounter{policy: policy}
+			timeout <-chan time.Time // MaxDelay of the results not flushed
+			failed  bool
+		)
+		fail := func(err error) {
+			failed = true
+			cancel()
+			errC <- newErrResult(err)
+		}
+		flush := func() {
+			counter.reset()
+			timeout = nil
+			if err := sink.Flush(); err != nil {
+				fail(wrapErr(err, "Sink cannot flush"))
+			}
+		}
+		for {
+			select {
+			case <-timeout:
+				flush()
+				continue
+			case r, ok := <-resultC:
+				if !ok {
+					flush()
+					return
+				}
+				switch {
+				case failed:
+					// Drain the results after the cancel
+				case r.Err() != nil:
+					errC <- r
+				default:
+					if err := sink.Write(r); err != nil {
+						fail(wrapErr(err, "Sink cannot write"))
+						continue
+					}
+					if counter.add(r) {
+						flush()
+					} else if timeout == nil && policy.MaxDelay > 0 {
+						timeout = c.clock.After(policy.MaxDelay)
+					}
+				}
+			}
+		}
+	}, func() {
+		cancel()
+		drainResults(resultC)
+	})
+	return errC, nil
+}
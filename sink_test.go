@@ -0,0 +1,170 @@
+package gogrep_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSink(t *testing.T) {
+	const source = "match 1\nskip\nmatch 2\n"
+
+	collect := func(t *testing.T, resultC <-chan gogrep.Result) []error {
+		var errs []error
+		for r := range resultC {
+			errs = append(errs, r.Err())
+		}
+		return errs
+	}
+
+	t.Run("func", func(t *testing.T) {
+		var (
+			mux   sync.Mutex
+			texts []string
+		)
+		sink := gogrep.SinkFunc(func(r gogrep.Result) error {
+			mux.Lock()
+			defer mux.Unlock()
+			texts = append(texts, r.Text())
+			return nil
+		})
+		resultC, err := gogrep.New(gogrep.WithSink(sink)).Grep(context.TODO(), "match", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, collect(t, resultC))
+		sort.Strings(texts)
+		assert.Equal(t, []string{"match 1", "match 2"}, texts)
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		g := gogrep.New(gogrep.WithSink(gogrep.NewWriterSink(&buf)), gogrep.WithThreads(1))
+		resultC, err := g.GrepSources(context.TODO(), []string{"match"}, []gogrep.NamedSource{
+			{Name: "a", Reader: strings.NewReader(source)},
+			{Name: "b", Reader: strings.NewReader("match 3\n")},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, collect(t, resultC))
+		assert.Equal(t, "match 1\nmatch 2\nmatch 3\n", buf.String())
+	})
+
+	t.Run("channel", func(t *testing.T) {
+		c := make(chan gogrep.Result, 10)
+		s, err := gogrep.New(gogrep.WithSink(gogrep.NewChannelSink(c))).Compile("match")
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		resultC, err := s.Grep(context.TODO(), strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, collect(t, resultC))
+		close(c)
+		var texts []string
+		for r := range c {
+			texts = append(texts, r.Text())
+		}
+		sort.Strings(texts)
+		assert.Equal(t, []string{"match 1", "match 2"}, texts)
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		var got []gogrep.WebhookResult
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			var xs []gogrep.WebhookResult
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&xs))
+			got = append(got, xs...)
+		}))
+		defer server.Close()
+		g := gogrep.New(gogrep.WithSink(gogrep.NewWebhookSink(server.URL, server.Client())), gogrep.WithThreads(1))
+		resultC, err := g.GrepSources(context.TODO(), []string{"match"}, []gogrep.NamedSource{
+			{Name: "a", Reader: strings.NewReader(source), Labels: map[string]string{"root": "r"}},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, collect(t, resultC))
+		assert.Equal(t, []gogrep.WebhookResult{
+			{Source: "a", Labels: map[string]string{"root": "r"}, Line: 1, Offset: 0, Text: "match 1"},
+			{Source: "a", Labels: map[string]string{"root": "r"}, Line: 3, Offset: 13, Text: "match 2"},
+		}, got)
+	})
+
+	t.Run("webhook error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		g := gogrep.New(gogrep.WithSink(gogrep.NewWebhookSink(server.URL, server.Client())))
+		resultC, err := g.Grep(context.TODO(), "match", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		errs := collect(t, resultC)
+		if assert.Equal(t, 1, len(errs)) {
+			assert.ErrorContains(t, errs[0], "500")
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		errWrite := errors.New("write")
+		var flushed bool
+		sink := &testSink{
+			write: func(gogrep.Result) error { return errWrite },
+			flush: func() error {
+				flushed = true
+				return nil
+			},
+		}
+		source := strings.Repeat("match\n", 10000)
+		resultC, err := gogrep.New(gogrep.WithSink(sink)).Grep(context.TODO(), "match", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return
+		}
+		errs := collect(t, resultC)
+		if assert.Equal(t, 1, len(errs)) {
+			assert.ErrorIs(t, errs[0], errWrite)
+		}
+		assert.True(t, flushed)
+	})
+
+	t.Run("grep error", func(t *testing.T) {
+		errRead := errors.New("read")
+		sink := gogrep.SinkFunc(func(gogrep.Result) error { return nil })
+		resultC, err := gogrep.New(gogrep.WithSink(sink)).Grep(context.TODO(), "match", io.MultiReader(
+			strings.NewReader(source),
+			&errReader{err: errRead},
+		))
+		if !assert.Nil(t, err) {
+			return
+		}
+		errs := collect(t, resultC)
+		if assert.Equal(t, 1, len(errs)) {
+			assert.ErrorIs(t, errs[0], errRead)
+		}
+	})
+}
+
+type testSink struct {
+	write func(gogrep.Result) error
+	flush func() error
+}
+
+func (s *testSink) Write(r gogrep.Result) error { return s.write(r) }
+func (s *testSink) Flush() error                { return s.flush() }
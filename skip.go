@@ -0,0 +1,52 @@
+package gogrep
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSkipped means a content of the source is not searched, reported with WithReportSkipped.
+var ErrSkipped = errors.New("content skipped")
+
+// SkipReason is the reason why a content is not searched.
+type SkipReason string
+
+const (
+	// SkipBinary is the binary source not searched with BinaryWithoutMatch.
+	SkipBinary SkipReason = "binary"
+	// SkipLongLine is the line longer than WithMaxLineLength not searched with LongLineSkip,
+	// or the rest of the line with LongLineTruncate.
+	SkipLongLine SkipReason = "long-line"
+)
+
+// SkipError is the error of a Result reported by WithReportSkipped.
+// Result.Line and Result.Offset are of the first record skipped.
+type SkipError struct {
+	Reason SkipReason
+}
+
+func (e *SkipError) Error() string { return fmt.Sprintf("%s: %s", ErrSkipped, e.Reason) }
+func (*SkipError) Unwrap() error   { return ErrSkipped }
+
+// WithReportSkipped emits a Result whose Err is a SkipError for each content not searched,
+// e.g. the binary sources and the long lines, to prove what was not searched.
+// The results are not the failures of the sources; GrepSources does not stop with ErrorStop by them.
+func WithReportSkipped() Option {
+	return func(c *Config) {
+		c.reportSkipped = true
+	}
+}
+
+// newSkipResult returns the Result of the content skipped at the record.
+func newSkipResult(reason SkipReason, line int, offset int64) Result {
+	return &result{
+		err:    &SkipError{Reason: reason},
+		line:   line,
+		offset: offset,
+	}
+}
+
+// isReported returns true if the error of a result does not fail the source.
+func isReported(err error) bool {
+	return errors.Is(err, ErrBinaryFile) || errors.Is(err, ErrSkipped) || errors.Is(err, ErrCheckpoint)
+}
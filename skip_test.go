@@ -0,0 +1,87 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithReportSkipped(t *testing.T) {
+	type skip struct {
+		reason gogrep.SkipReason
+		line   int
+		offset int64
+	}
+
+	grep := func(t *testing.T, source string, opt ...gogrep.Option) ([]string, []skip) {
+		opt = append(opt, gogrep.WithReportSkipped(), gogrep.WithThreads(1))
+		resultC, err := gogrep.New(opt...).Grep(context.TODO(), "x", strings.NewReader(source))
+		if !assert.Nil(t, err) {
+			return nil, nil
+		}
+		var (
+			texts []string
+			skips []skip
+		)
+		for r := range resultC {
+			var e *gogrep.SkipError
+			if errors.As(r.Err(), &e) {
+				assert.ErrorIs(t, r.Err(), gogrep.ErrSkipped)
+				skips = append(skips, skip{reason: e.Reason, line: r.Line(), offset: r.Offset()})
+				continue
+			}
+			assert.Nil(t, r.Err())
+			texts = append(texts, r.Text())
+		}
+		return texts, skips
+	}
+
+	t.Run("binary", func(t *testing.T) {
+		texts, skips := grep(t, "x\x00\nx\n", gogrep.WithBinaryFiles(gogrep.BinaryWithoutMatch))
+		assert.Empty(t, texts)
+		assert.Equal(t, []skip{{reason: gogrep.SkipBinary, line: 1}}, skips)
+	})
+
+	t.Run("long line skip", func(t *testing.T) {
+		texts, skips := grep(t, "x1\n"+strings.Repeat("x", 100)+"\nx3\n",
+			gogrep.WithMaxLineLength(10), gogrep.WithLongLineMode(gogrep.LongLineSkip))
+		assert.Equal(t, []string{"x1", "x3"}, texts)
+		assert.Equal(t, []skip{{reason: gogrep.SkipLongLine, line: 2, offset: 3}}, skips)
+	})
+
+	t.Run("long line truncate", func(t *testing.T) {
+		texts, skips := grep(t, "x1\n"+strings.Repeat("x", 100)+"\n",
+			gogrep.WithMaxLineLength(10), gogrep.WithLongLineMode(gogrep.LongLineTruncate))
+		assert.Equal(t, []string{"x1", strings.Repeat("x", 10)}, texts)
+		assert.Equal(t, []skip{{reason: gogrep.SkipLongLine, line: 2, offset: 13}}, skips)
+	})
+
+	t.Run("sources continue", func(t *testing.T) {
+		resultC, err := gogrep.New(
+			gogrep.WithReportSkipped(),
+			gogrep.WithBinaryFiles(gogrep.BinaryWithoutMatch),
+			gogrep.WithErrorPolicy(gogrep.ErrorStop),
+			gogrep.WithoutBatching(),
+		).GrepSources(context.TODO(), []string{"x"}, []gogrep.NamedSource{
+			{Name: "a", Reader: strings.NewReader("x\x00\n")},
+			{Name: "b", Reader: strings.NewReader("x\n")},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		var got []string
+		for r := range resultC {
+			if r.Err() != nil {
+				assert.ErrorIs(t, r.Err(), gogrep.ErrSkipped)
+				got = append(got, r.Source()+":skipped")
+				continue
+			}
+			got = append(got, r.Source()+":"+r.Text())
+		}
+		assert.Equal(t, []string{"a:skipped", "b:x"}, got)
+	})
+}
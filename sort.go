@@ -0,0 +1,130 @@
+package gogrep
+
+import (
+	"cmp"
+	"context"
+	"sort"
+)
+
+// SortBy is the key of the results sorted by WithSort.
+type SortBy string
+
+const (
+	// SortByFile sorts the results by Result.Source, and by the line and the match in a source.
+	SortByFile SortBy = "file"
+	// SortByLine sorts the results by Result.Line, and by the source and the match of the same line.
+	SortByLine SortBy = "line"
+	// SortByText sorts the results by Result.Text, and by the source, the line and the match of the same text.
+	SortByText SortBy = "text"
+)
+
+// WithSort sends the results of a call of the Grepper sorted by the key, so that the output does not depend on the workers.
+// The ties are broken by the other keys, the start of the match and the error, so the order is deterministic.
+// The results are collected in memory until the grep ends, so nothing is sent before the end,
+// and the memory grows with the number of the results, though they are kept by Compact.
+// It conflicts with WithFollow.
+// It is applied after WithUnique and before WithSink and WithSequence, and ignored in the options of NamedSource.
+// Unknown key makes Grep fail.
+func WithSort(by SortBy) Option {
+	return func(c *Config) {
+		c.sort = by
+	}
+}
+
+// sortGrep returns the grep that sends the results of the grep sorted by WithSort.
+func sortGrep(c *Config, grep func(context.Context) (<-chan Result, error)) func(context.Context) (<-chan Result, error) {
+	return func(ctx context.Context) (<-chan Result, error) {
+		resultC, err := grep(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sortC := make(chan Result, c.resultBuffer())
+		supervise(sortC, func() {
+			var keys []*sortKey
+			for r := range resultC {
+				keys = append(keys, newSortKey(Compact(r), c.sort))
+			}
+			less := sortLess(c.sort)
+			sort.SliceStable(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+			for _, x := range keys {
+				sortC <- x.result
+			}
+		}, func() { drainResults(resultC) })
+		return sortC, nil
+	}
+}
+
+// sortKey is the keys of a result to be sorted.
+// The keys not always compared are read from the result on demand not to keep them,
+// e.g. the text of the line is decompressed only to break the tie of the other keys.
+type sortKey struct {
+	result  Result
+	source  string
+	line    int
+	start   int // the start of the first match, -1 without the matches
+	started bool
+	text    string // kept only by SortByText, compared every time
+	hasText bool
+	err     string
+}
+
+func newSortKey(r Result, by SortBy) *sortKey {
+	k := &sortKey{
+		result: r,
+		source: r.Source(),
+		line:   r.Line(),
+	}
+	if by == SortByText {
+		k.text, k.hasText = r.Text(), true
+	}
+	if err := r.Err(); err != nil {
+		k.err = err.Error()
+	}
+	return k
+}
+
+func (s *sortKey) matchStart() int {
+	if !s.started {
+		s.start = -1
+		if x := s.result.MatchRanges(); len(x) > 0 {
+			s.start = x[0][0]
+		}
+		s.started = true
+	}
+	return s.start
+}
+
+func (s *sortKey) sortText() string {
+	if s.hasText {
+		return s.text
+	}
+	return s.result.Text()
+}
+
+// sortLess returns the order of the keys by the key of WithSort.
+func sortLess(by SortBy) func(a, b *sortKey) bool {
+	var (
+		source = func(a, b *sortKey) int { return cmp.Compare(a.source, b.source) }
+		line   = func(a, b *sortKey) int { return cmp.Compare(a.line, b.line) }
+		start  = func(a, b *sortKey) int { return cmp.Compare(a.matchStart(), b.matchStart()) }
+		text   = func(a, b *sortKey) int { return cmp.Compare(a.sortText(), b.sortText()) }
+		err    = func(a, b *sortKey) int { return cmp.Compare(a.err, b.err) }
+		keys   []func(a, b *sortKey) int
+	)
+	switch by {
+	case SortByLine:
+		keys = append(keys, line, source, start, text, err)
+	case SortByText:
+		keys = append(keys, text, source, line, start, err)
+	default:
+		keys = append(keys, source, line, start, text, err)
+	}
+	return func(a, b *sortKey) bool {
+		for _, k := range keys {
+			if x := k(a, b); x != 0 {
+				return x < 0
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,99 @@
+package gogrep_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSort(t *testing.T) {
+	grep := func(t *testing.T, opt ...gogrep.Option) []string {
+		resultC, err := gogrep.New(append(opt, gogrep.WithThreads(4))...).GrepSources(context.TODO(), []string{"o"}, []gogrep.NamedSource{
+			{Name: "y", Reader: strings.NewReader("two\nzero\none\n")},
+			{Name: "x", Reader: strings.NewReader("foo\nbar\nboo\n")},
+		})
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		var got []string
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, fmt.Sprintf("%s:%d:%s", r.Source(), r.Line(), r.Text()))
+		}
+		return got
+	}
+
+	for _, tc := range []struct {
+		title string
+		opt   []gogrep.Option
+		want  []string
+	}{
+		{
+			title: "file",
+			opt:   []gogrep.Option{gogrep.WithSort(gogrep.SortByFile)},
+			want:  []string{"x:1:foo", "x:3:boo", "y:1:two", "y:2:zero", "y:3:one"},
+		},
+		{
+			title: "line",
+			opt:   []gogrep.Option{gogrep.WithSort(gogrep.SortByLine)},
+			want:  []string{"x:1:foo", "y:1:two", "y:2:zero", "x:3:boo", "y:3:one"},
+		},
+		{
+			title: "text",
+			opt:   []gogrep.Option{gogrep.WithSort(gogrep.SortByText)},
+			want:  []string{"x:3:boo", "x:1:foo", "y:3:one", "y:1:two", "y:2:zero"},
+		},
+		{
+			title: "only matching",
+			opt:   []gogrep.Option{gogrep.WithSort(gogrep.SortByFile), gogrep.WithOnlyMatching()},
+			want:  []string{"x:1:o", "x:1:o", "x:3:o", "x:3:o", "y:1:o", "y:2:o", "y:3:o"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, grep(t, tc.opt...))
+		})
+	}
+
+	t.Run("many lines", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 10000; i++ {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		resultC, err := gogrep.New(gogrep.WithThreads(4), gogrep.WithSort(gogrep.SortByLine)).
+			Grep(context.TODO(), "line", strings.NewReader(b.String()))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var want int
+		for r := range resultC {
+			want++
+			assert.Equal(t, want, r.Line())
+		}
+		assert.Equal(t, 10000, want)
+	})
+
+	t.Run("sequence", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithSort(gogrep.SortByText), gogrep.WithSequence()).
+			Grep(context.TODO(), "", strings.NewReader("b\na\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var got []string
+		for r := range resultC {
+			if _, ok := gogrep.SummaryOf(r); ok {
+				continue
+			}
+			got = append(got, fmt.Sprintf("%d:%s", gogrep.SequenceOf(r), r.Text()))
+		}
+		assert.Equal(t, []string{"1:a", "2:b"}, got)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithSort("size")).Grep(context.TODO(), "x", strings.NewReader("x\n"))
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,236 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+	"io/fs"
+)
+
+// NamedSource is a source of GrepSources.
+type NamedSource struct {
+	// Name is set to the results from the source.
+	Name   string
+	Reader io.Reader
+	// Labels are set to ResultContext of the results from the source, e.g. the root directory where the file was found.
+	Labels map[string]string
+	// Info is set to ResultContext of the results from the source if not nil.
+	Info fs.FileInfo
+	// Options are applied to the grep of the source in addition to the options of the Grepper.
+	Options []Option
+}
+
+func (s *grepper) GrepSources(ctx context.Context, regexes []string, sources []NamedSource) (<-chan Result, error) {
+	// Already canceled
+	if isDone(ctx) {
+		return nil, wrapErr(cancellationError(ctx), "Grepper")
+	}
+	if err := s.checkRegexes(regexes); err != nil {
+		return nil, err
+	}
+	r, err := s.compileMulti(regexes)
+	if err != nil {
+		return nil, err
+	}
+	greppers := make([]*grepper, len(sources))
+	for i, src := range sources {
+		g := s.withOptions(src.Options)
+		if g.config.concurrencyHint != nil || g.config.logger != nil {
+			g = g.withOptions([]Option{withSourceName(src.Name)})
+		}
+		if err := g.config.validate(); err != nil {
+			return nil, err
+		}
+		greppers[i] = g
+	}
+
+	var (
+		iCtx, cancel = context.WithCancel(ctx)
+		resultC      = make(chan Result, s.config.resultBuffer())
+		// The channels of the sources being grepped, in order
+		queue = make(chan *sourceGrep, s.config.threads)
+	)
+	go func() {
+		defer close(queue)
+		var (
+			i      int
+			aheadC <-chan *prefetch // nil without WithReadahead
+			batch  *sourceBatch     // the small sources not grepped yet
+		)
+		defer func() {
+			// e.g. a Reader of a source or WithSourceConcurrencyHint panicked
+			if err := panicError(recover()); err != nil {
+				cancel()
+				queue <- &sourceGrep{
+					context:   &ResultContext{},
+					errResult: newErrResult(wrapErr(err, "Grepper recovered")),
+				}
+			}
+		}()
+		if k := s.config.readaheadSources(); k > 0 {
+			aheadC = readahead(iCtx, sources, k)
+		}
+		flush := func() {
+			if batch == nil {
+				return
+			}
+			batch.grep(iCtx, r)
+			for _, g := range batch.sources {
+				queue <- g
+			}
+			batch = nil
+		}
+		for ; i < len(sources); i++ {
+			src := sources[i]
+			g := &sourceGrep{context: &ResultContext{
+				Source: src.Name,
+				Labels: src.Labels,
+				Info:   src.Info,
+			}}
+			if c, ok := src.Reader.(io.Closer); ok {
+				g.closer = c
+			}
+			if aheadC != nil {
+				p, ok := <-aheadC
+				if ok {
+					<-p.done
+					src = p.source
+				}
+			}
+			if isDone(iCtx) {
+				flush()
+				g.errResult = greppers[i].tagged(newErrResult(wrapErr(cancellationError(iCtx), "Grepper")))
+				queue <- g
+				break
+			}
+			if content, ok := readSmall(greppers[i].config, &src); ok {
+				if batch != nil && !batch.fits(greppers[i].config, len(content)) {
+					flush()
+				}
+				if batch == nil {
+					batch = &sourceBatch{}
+				}
+				batch.add(g, greppers[i], src, content)
+				continue
+			}
+			flush()
+			gr := greppers[i]
+			if gr.config.perSourceTimeout > 0 {
+				g.reads = &pendingReads{}
+				x := *gr
+				x.reads = g.reads
+				gr = &x
+			}
+			// grepMatcher does not fail since the config is validated
+			g.resultC, _ = gr.grepSource(iCtx, r, src.Reader)
+			queue <- g
+		}
+		flush()
+		if aheadC != nil {
+			for p := range aheadC {
+				<-p.done // not to close the sources being read ahead
+			}
+		}
+		if i < len(sources) {
+			for _, src := range sources[i+1:] {
+				if c, ok := src.Reader.(io.Closer); ok {
+					c.Close()
+				}
+			}
+		}
+	}()
+	// Send the results of a source together while the next sources are read ahead
+	var current *sourceGrep // the source being sent
+	supervise(resultC, func() {
+		defer cancel()
+		var stopped bool
+		for current = range queue {
+			if current.drain(resultC, !stopped) && s.config.errorPolicy == ErrorStop && !stopped {
+				// Discard the sources read ahead
+				stopped = true
+				cancel()
+			}
+		}
+	}, func() {
+		cancel()
+		if current != nil && current.resultC != nil {
+			drainResults(current.resultC)
+		}
+		for g := range queue {
+			g.drain(nil, false)
+		}
+	})
+	return resultC, nil
+}
+
+// withOptions returns a grepper with the options applied in addition to the config.
+func (s *grepper) withOptions(opt []Option) *grepper {
+	if len(opt) == 0 {
+		return s
+	}
+	c := *s.config
+	c.notInside = c.notInside[:len(c.notInside):len(c.notInside)] // copy on append
+	for _, o := range opt {
+		o(&c)
+	}
+	return &grepper{config: &c}
+}
+
+// sourceGrep is a grep of a NamedSource.
+type sourceGrep struct {
+	context   *ResultContext
+	resultC   <-chan Result
+	errResult Result // the result instead of resultC if the grep did not start
+	closer    io.Closer
+	reads     *pendingReads // closes the source after the reads abandoned by WithPerSourceTimeout if not nil
+}
+
+// drain sends the results tagged with the name of the source if forward, and closes the source.
+// Returns true if the source got an error.
+func (s *sourceGrep) drain(resultC chan<- Result, forward bool) bool {
+	if s.closer != nil {
+		defer s.reads.close(s.closer)
+	}
+	if s.errResult != nil {
+		if forward {
+			resultC <- &sourceResult{
+				Result:  s.errResult,
+				context: s.context,
+			}
+		}
+		return true
+	}
+	var failed bool
+	for r := range s.resultC {
+		if err := r.Err(); err != nil && !isReported(err) {
+			failed = true
+		}
+		if forward {
+			resultC <- &sourceResult{
+				Result:  r,
+				context: s.context,
+			}
+		}
+	}
+	return failed
+}
+
+// sourceResult is a Result of GrepSources.
+type sourceResult struct {
+	Result
+	context *ResultContext
+}
+
+func (s *sourceResult) Source() string                { return s.context.Source }
+func (s *sourceResult) resultContext() *ResultContext { return s.context }
+
+// Err returns the error of the source as a SourceError.
+func (s *sourceResult) Err() error {
+	err := s.Result.Err()
+	if err == nil {
+		return nil
+	}
+	return &SourceError{
+		Name: s.context.Source,
+		Err:  err,
+	}
+}
@@ -0,0 +1,336 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+type closeReader struct {
+	*strings.Reader
+	closed bool
+}
+
+func (s *closeReader) Close() error {
+	s.closed = true
+	return nil
+}
+
+// slowOpenReader sleeps on the first read like opening a file on a network filesystem.
+type slowOpenReader struct {
+	r      io.Reader
+	delay  time.Duration
+	opened bool
+	closed bool
+}
+
+func (s *slowOpenReader) Read(p []byte) (int, error) {
+	if !s.opened {
+		s.opened = true
+		time.Sleep(s.delay)
+	}
+	return s.r.Read(p)
+}
+
+func (s *slowOpenReader) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestGrepSources(t *testing.T) {
+	t.Run("tagged in order", func(t *testing.T) {
+		var (
+			sources []gogrep.NamedSource
+			want    []string
+		)
+		for i := 0; i < 20; i++ {
+			name := fmt.Sprintf("src%d", i)
+			sources = append(sources, gogrep.NamedSource{
+				Name:   name,
+				Reader: strings.NewReader(strings.Repeat("match\nskip\n", 50)),
+			})
+			want = append(want, name)
+		}
+		resultC, err := gogrep.New(gogrep.WithMaxCount(2)).GrepSources(context.Background(), []string{"match"}, sources)
+		assert.Nil(t, err)
+		var got []string
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			assert.Equal(t, "match", r.Text())
+			if len(got) == 0 || got[len(got)-1] != r.Source() {
+				got = append(got, r.Source())
+			}
+		}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("source options", func(t *testing.T) {
+		resultC, err := gogrep.New().GrepSources(context.Background(), []string{"x", "y"}, []gogrep.NamedSource{
+			{
+				Name:   "all",
+				Reader: strings.NewReader("x\ny\n"),
+			},
+			{
+				Name:    "first",
+				Reader:  strings.NewReader("x\ny\n"),
+				Options: []gogrep.Option{gogrep.WithMaxResults(1)},
+			},
+		})
+		assert.Nil(t, err)
+		var got []string
+		for r := range resultC {
+			got = append(got, r.Source())
+		}
+		assert.Equal(t, []string{"all", "all", "first"}, got)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithSourceTag("default")).GrepSources(context.Background(), []string{"x"}, []gogrep.NamedSource{
+			{
+				Name:   "a",
+				Reader: strings.NewReader("x\n"),
+			},
+			{
+				Name:    "b",
+				Reader:  &errReader{err: errors.New("source")},
+				Options: []gogrep.Option{gogrep.WithSourceTag(2)},
+			},
+		})
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		if assert.Equal(t, 2, len(results)) {
+			assert.Equal(t, "default", results[0].Tag())
+			assert.Equal(t, 2, results[1].Tag())
+			assert.NotNil(t, results[1].Err())
+		}
+	})
+
+	t.Run("close and error", func(t *testing.T) {
+		var (
+			errSource = errors.New("source")
+			r         = &closeReader{Reader: strings.NewReader("x\n")}
+		)
+		resultC, err := gogrep.New(gogrep.WithErrorPolicy(gogrep.ErrorContinue)).GrepSources(context.Background(), []string{"x"}, []gogrep.NamedSource{
+			{
+				Name:   "bad",
+				Reader: &errReader{err: errSource},
+			},
+			{
+				Name:   "good",
+				Reader: r,
+			},
+		})
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		if assert.Equal(t, 2, len(results)) {
+			assert.Equal(t, "bad", results[0].Source())
+			assert.ErrorIs(t, results[0].Err(), errSource)
+			assert.Equal(t, "good", results[1].Source())
+			assert.Equal(t, "x", results[1].Text())
+		}
+		assert.True(t, r.closed)
+	})
+
+	t.Run("stop at error", func(t *testing.T) {
+		var (
+			errSource = errors.New("source")
+			sources   = []gogrep.NamedSource{
+				{
+					Name:   "good",
+					Reader: strings.NewReader("x\n"),
+				},
+				{
+					Name:   "bad",
+					Reader: &errReader{err: errSource},
+				},
+			}
+			rest []*closeReader
+		)
+		for i := 0; i < 10; i++ {
+			r := &closeReader{Reader: strings.NewReader("x\n")}
+			rest = append(rest, r)
+			sources = append(sources, gogrep.NamedSource{
+				Name:   "rest",
+				Reader: r,
+			})
+		}
+		resultC, err := gogrep.New().GrepSources(context.Background(), []string{"x"}, sources)
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		if assert.Equal(t, 2, len(results)) {
+			assert.Equal(t, "good", results[0].Source())
+			assert.Equal(t, "bad", results[1].Source())
+			assert.ErrorIs(t, results[1].Err(), errSource)
+		}
+		for _, r := range rest {
+			assert.True(t, r.closed, "read ahead sources are closed")
+		}
+	})
+
+	t.Run("readahead", func(t *testing.T) {
+		var (
+			sources []gogrep.NamedSource
+			readers []*slowOpenReader
+			want    []string
+		)
+		for i := 0; i < 20; i++ {
+			r := &slowOpenReader{r: strings.NewReader(strings.Repeat(fmt.Sprintf("match%d\nskip\n", i), 10000))}
+			readers = append(readers, r)
+			sources = append(sources, gogrep.NamedSource{
+				Name:   fmt.Sprintf("src%d", i),
+				Reader: r,
+			})
+			for j := 0; j < 10000; j++ {
+				want = append(want, fmt.Sprintf("src%d:match%d", i, i))
+			}
+		}
+		resultC, err := gogrep.New(gogrep.WithReadahead(3), gogrep.WithThreads(1)).GrepSources(context.Background(), []string{"match"}, sources)
+		assert.Nil(t, err)
+		var got []string
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			got = append(got, r.Source()+":"+r.Text())
+		}
+		assert.Equal(t, want, got)
+		for _, r := range readers {
+			assert.True(t, r.closed)
+		}
+	})
+
+	t.Run("readahead stop at error", func(t *testing.T) {
+		sources := []gogrep.NamedSource{
+			{
+				Name:   "bad",
+				Reader: &errReader{err: errors.New("source")},
+			},
+		}
+		var rest []*slowOpenReader
+		for i := 0; i < 10; i++ {
+			r := &slowOpenReader{r: strings.NewReader("x\n")}
+			rest = append(rest, r)
+			sources = append(sources, gogrep.NamedSource{
+				Name:   "rest",
+				Reader: r,
+			})
+		}
+		resultC, err := gogrep.New(gogrep.WithReadahead(4)).GrepSources(context.Background(), []string{"x"}, sources)
+		assert.Nil(t, err)
+		results := toResultSlice(resultC)
+		if assert.Equal(t, 1, len(results)) {
+			assert.Equal(t, "bad", results[0].Source())
+		}
+		for _, r := range rest {
+			assert.True(t, r.closed, "read ahead sources are closed")
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		contents := []string{
+			"x1\ny\nx2\n",
+			"",
+			"y\nx3",
+			"x4\r\ny\r\n",
+			"\x00x5\n",
+			strings.Repeat("y\n", 40000) + "x6\n",
+			"y\n\nx7\n",
+		}
+		grep := func(opt ...gogrep.Option) []string {
+			sources := make([]gogrep.NamedSource, len(contents))
+			for i, c := range contents {
+				sources[i] = gogrep.NamedSource{
+					Name:    fmt.Sprintf("src%d", i),
+					Reader:  &slowOpenReader{r: strings.NewReader(c)},
+					Options: []gogrep.Option{gogrep.WithSourceTag(i)},
+				}
+			}
+			opt = append(opt, gogrep.WithBinaryFiles(gogrep.BinaryMatches))
+			resultC, err := gogrep.New(opt...).GrepSources(context.Background(), []string{"x"}, sources)
+			assert.Nil(t, err)
+			var got []string
+			for r := range resultC {
+				got = append(got, fmt.Sprintf("%s:%v:%d:%d:%s:%v:%v", r.Source(), r.Tag(), r.Line(), r.Offset(), r.Text(), r.MatchRanges(), r.Err()))
+			}
+			sort.Strings(got)
+			return got
+		}
+		want := grep(gogrep.WithoutBatching())
+		assert.Equal(t, 7, len(want))
+		assert.Equal(t, want, grep())
+		assert.Equal(t, want, grep(gogrep.WithReadahead(2)))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := gogrep.New().GrepSources(context.Background(), nil, nil)
+		assert.NotNil(t, err)
+		_, err = gogrep.New().GrepSources(context.Background(), []string{"("}, nil)
+		assert.NotNil(t, err)
+		_, err = gogrep.New().GrepSources(context.Background(), []string{"x"}, []gogrep.NamedSource{
+			{
+				Reader:  strings.NewReader("x"),
+				Options: []gogrep.Option{gogrep.WithEncoding("unknown")},
+			},
+		})
+		assert.NotNil(t, err)
+	})
+}
+
+func BenchmarkGrepSourcesReadahead(b *testing.B) {
+	data := strings.Join(dupStrings(1<<10, "allocation", "freeable", "cached", "dirty", "flush memory", "NAND", "ready to write"), "\n")
+	for _, k := range []int{0, 4, 16} {
+		b.Run(fmt.Sprintf("readahead %d", k), func(b *testing.B) {
+			g := gogrep.New(gogrep.WithReadahead(k), gogrep.WithoutBatching())
+			for n := 0; n < b.N; n++ {
+				sources := make([]gogrep.NamedSource, 64)
+				for i := range sources {
+					sources[i] = gogrep.NamedSource{
+						Reader: &slowOpenReader{
+							r:     strings.NewReader(data),
+							delay: time.Millisecond, // the latency of opening a file on NFS
+						},
+					}
+				}
+				resultC, err := g.GrepSources(context.TODO(), []string{"[cf].+sh"}, sources)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for range resultC {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGrepSourcesBatch(b *testing.B) {
+	data := strings.Join(dupStrings(1<<1, "allocation", "freeable", "cached", "dirty", "flush memory", "NAND", "ready to write"), "\n")
+	for _, batch := range []bool{false, true} {
+		b.Run(fmt.Sprintf("batch %v", batch), func(b *testing.B) {
+			var opt []gogrep.Option
+			if !batch {
+				opt = append(opt, gogrep.WithoutBatching())
+			}
+			g := gogrep.New(opt...)
+			for n := 0; n < b.N; n++ {
+				sources := make([]gogrep.NamedSource, 10000)
+				for i := range sources {
+					sources[i] = gogrep.NamedSource{
+						Reader: &slowOpenReader{r: strings.NewReader(data)},
+					}
+				}
+				resultC, err := g.GrepSources(context.TODO(), []string{"[cf].+sh"}, sources)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for range resultC {
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package gogrep
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// OffsetMapper is implemented by sources whose read positions differ from the offsets in the underlying file.
+// Grep reports Result.Offset mapped by it.
+type OffsetMapper interface {
+	// MapOffset converts the number of bytes read before a position into the offset in the file.
+	MapOffset(pos int64) int64
+}
+
+// SparseReader reads the data regions of a sparse file and skips its holes.
+// The holes are regions of zeros without newlines, so skipping them keeps line numbers,
+// and the offsets are mapped back to the file by MapOffset.
+// On platforms without SEEK_DATA and SEEK_HOLE it reads the whole file.
+type SparseReader struct {
+	f        *os.File
+	size     int64
+	pos      int64 // position in the file
+	end      int64 // end of the current data region
+	read     int64 // number of bytes read
+	segments []sparseSegment
+}
+
+// sparseSegment is the beginning of a data region.
+type sparseSegment struct {
+	read   int64 // number of bytes read before the region
+	offset int64 // offset of the region in the file
+}
+
+// NewSparseReader returns a new SparseReader of the file from the beginning.
+func NewSparseReader(f *os.File) (*SparseReader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &SparseReader{
+		f:    f,
+		size: info.Size(),
+	}, nil
+}
+
+func (s *SparseReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if s.pos >= s.end {
+		data, hole, err := findData(s.f, s.pos, s.size)
+		if err != nil {
+			return 0, err
+		}
+		s.pos, s.end = data, hole
+		s.segments = append(s.segments, sparseSegment{
+			read:   s.read,
+			offset: data,
+		})
+	}
+	if rest := s.end - s.pos; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := s.f.ReadAt(p, s.pos)
+	s.pos += int64(n)
+	s.read += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (s *SparseReader) MapOffset(pos int64) int64 {
+	i := sort.Search(len(s.segments), func(i int) bool { return s.segments[i].read > pos }) - 1
+	if i < 0 {
+		return pos
+	}
+	return s.segments[i].offset + pos - s.segments[i].read
+}
@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!solaris
+
+package gogrep
+
+import (
+	"io"
+	"os"
+)
+
+// findData returns the rest of the file since holes cannot be detected.
+func findData(_ *os.File, offset, size int64) (int64, int64, error) {
+	if offset >= size {
+		return 0, 0, io.EOF
+	}
+	return offset, size, nil
+}
@@ -0,0 +1,37 @@
+package gogrep_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparseReader(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "sparse")
+	f, err := os.Create(name)
+	assert.Nil(t, err)
+	defer f.Close()
+	_, err = f.WriteString("abc\n")
+	assert.Nil(t, err)
+	// A hole from the next block to the offset
+	_, err = f.WriteAt([]byte("\nmatch\n"), 40960)
+	assert.Nil(t, err)
+
+	r, err := gogrep.NewSparseReader(f)
+	assert.Nil(t, err)
+	resultC, err := gogrep.New().Grep(context.TODO(), "match|abc", r)
+	assert.Nil(t, err)
+	got := map[string][2]int64{}
+	for r := range resultC {
+		assert.Nil(t, r.Err())
+		got[r.Text()] = [2]int64{int64(r.Line()), r.Offset()}
+	}
+	assert.Equal(t, map[string][2]int64{
+		"abc":   {1, 0},
+		"match": {3, 40961},
+	}, got)
+}
@@ -0,0 +1,38 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris
+
+package gogrep
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	registerCapability("sparse")
+}
+
+// findData returns the data region at or after the offset.
+func findData(f *os.File, offset, size int64) (int64, int64, error) {
+	if offset >= size {
+		return 0, 0, io.EOF
+	}
+	data, err := unix.Seek(int(f.Fd()), offset, unix.SEEK_DATA)
+	if err != nil {
+		if errors.Is(err, unix.ENXIO) {
+			return 0, 0, io.EOF // only a hole remains
+		}
+		if errors.Is(err, unix.EINVAL) {
+			return offset, size, nil // not supported by the filesystem
+		}
+		return 0, 0, err
+	}
+	hole, err := unix.Seek(int(f.Fd()), data, unix.SEEK_HOLE)
+	if err != nil {
+		return data, size, nil
+	}
+	return data, hole, nil
+}
@@ -0,0 +1,169 @@
+// Package sqlsink writes the results of gogrep into a database by database/sql.
+//
+// The results are inserted into the table, gogrep_results by default:
+//
+//	CREATE TABLE IF NOT EXISTS gogrep_results (
+//	  run_id      TEXT NOT NULL,    -- Sink.RunID, the same for the results of a run
+//	  path        TEXT NOT NULL,    -- the source of the result, empty for stdin
+//	  line        INTEGER NOT NULL, -- Result.Line
+//	  byte_offset INTEGER NOT NULL, -- Result.Offset
+//	  text        TEXT NOT NULL,    -- Result.Text
+//	  submatches  TEXT              -- Result.Submatches as a JSON array, NULL if not available
+//	)
+//
+// The package does not import any driver.
+package sqlsink
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/berquerant/gogrep"
+)
+
+// DefaultTable is the default name of the table.
+const DefaultTable = "gogrep_results"
+
+// Row is a row of the table.
+type Row struct {
+	Path       string
+	Line       int
+	Offset     int64
+	Text       string
+	Submatches []string
+}
+
+// NewRow returns the row of the result from the path.
+func NewRow(path string, r gogrep.Result) *Row {
+	return &Row{
+		Path:       path,
+		Line:       r.Line(),
+		Offset:     r.Offset(),
+		Text:       r.Text(),
+		Submatches: r.Submatches(),
+	}
+}
+
+type (
+	// Config provides Sink configuration.
+	Config struct {
+		table       string
+		placeholder func(n int) string
+	}
+	Option func(*Config)
+)
+
+// WithTable sets the name of the table.
+// Default is DefaultTable.
+func WithTable(table string) Option {
+	return func(c *Config) {
+		c.table = table
+	}
+}
+
+// WithPlaceholder sets the function that returns the placeholder of the 1-based n-th parameter,
+// e.g. "$1" for PostgreSQL.
+// Default is "?".
+func WithPlaceholder(placeholder func(n int) string) Option {
+	return func(c *Config) {
+		if placeholder != nil {
+			c.placeholder = placeholder
+		}
+	}
+}
+
+// Sink inserts the rows into the table in a transaction.
+// It is a gogrep.Sink for gogrep.WithSink that inserts the results with the sources as the paths.
+// It is not safe for concurrent use.
+type Sink struct {
+	RunID string
+	tx    *sql.Tx
+	stmt  *sql.Stmt
+}
+
+// New creates the table if not exists and returns a Sink of the run.
+var _ gogrep.Sink = (*Sink)(nil)
+
+// Write inserts the row of the result from the source of it.
+func (s *Sink) Write(r gogrep.Result) error {
+	return s.Insert(context.Background(), NewRow(gogrep.ContextOf(r).Source, r))
+}
+
+// Flush does nothing since the rows are committed by Close.
+func (*Sink) Flush() error { return nil }
+
+// Close commits the rows.
+func New(ctx context.Context, db *sql.DB, runID string, opt ...Option) (*Sink, error) {
+	c := &Config{
+		table:       DefaultTable,
+		placeholder: func(int) string { return "?" },
+	}
+	for _, o := range opt {
+		o(c)
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  run_id      TEXT NOT NULL,
+  path        TEXT NOT NULL,
+  line        INTEGER NOT NULL,
+  byte_offset INTEGER NOT NULL,
+  text        TEXT NOT NULL,
+  submatches  TEXT
+)`, c.table)); err != nil {
+		return nil, fmt.Errorf("Sink cannot create table %s: %w", c.table, err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Sink cannot begin: %w", err)
+	}
+	params := make([]string, 6)
+	for i := range params {
+		params[i] = c.placeholder(i + 1)
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (run_id, path, line, byte_offset, text, submatches) VALUES (%s)",
+		c.table, strings.Join(params, ", "),
+	))
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("Sink cannot prepare insert: %w", err)
+	}
+	return &Sink{
+		RunID: runID,
+		tx:    tx,
+		stmt:  stmt,
+	}, nil
+}
+
+// Insert inserts the row.
+func (s *Sink) Insert(ctx context.Context, row *Row) error {
+	var submatches interface{}
+	if row.Submatches != nil {
+		b, err := json.Marshal(row.Submatches)
+		if err != nil {
+			return err
+		}
+		submatches = string(b)
+	}
+	if _, err := s.stmt.ExecContext(ctx, s.RunID, row.Path, row.Line, row.Offset, row.Text, submatches); err != nil {
+		return fmt.Errorf("Sink cannot insert: %w", err)
+	}
+	return nil
+}
+
+// Close commits the inserted rows.
+func (s *Sink) Close() error {
+	_ = s.stmt.Close()
+	if err := s.tx.Commit(); err != nil {
+		return fmt.Errorf("Sink cannot commit: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards the inserted rows.
+func (s *Sink) Rollback() error {
+	_ = s.stmt.Close()
+	return s.tx.Rollback()
+}
@@ -0,0 +1,105 @@
+package sqlsink_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/berquerant/gogrep/sqlsink"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestSink(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	insert := func(runID string, rollback bool) {
+		sink, err := sqlsink.New(ctx, db, runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resultC, err := gogrep.New(gogrep.WithOnlyMatching(), gogrep.WithThreads(1)).
+			Grep(ctx, `k(\d)`, strings.NewReader("k1\nnone\nk2\n"))
+		assert.Nil(t, err)
+		for r := range resultC {
+			assert.Nil(t, sink.Insert(ctx, sqlsink.NewRow("file", r)))
+		}
+		if rollback {
+			assert.Nil(t, sink.Rollback())
+		} else {
+			assert.Nil(t, sink.Close())
+		}
+	}
+	insert("run1", false)
+	insert("run2", true)
+
+	rows, err := db.Query("SELECT run_id, path, line, byte_offset, text, submatches FROM gogrep_results ORDER BY line")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type row struct {
+		runID, path string
+		line        int
+		offset      int64
+		text        string
+		submatches  sql.NullString
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		assert.Nil(t, rows.Scan(&r.runID, &r.path, &r.line, &r.offset, &r.text, &r.submatches))
+		got = append(got, r)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []row{
+		{"run1", "file", 1, 0, "k1", sql.NullString{String: `["k1","1"]`, Valid: true}},
+		{"run1", "file", 3, 8, "k2", sql.NullString{String: `["k2","2"]`, Valid: true}},
+	}, got)
+}
+
+func TestSinkWithSink(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	sink, err := sqlsink.New(ctx, db, "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultC, err := gogrep.New(gogrep.WithSink(sink), gogrep.WithThreads(1)).
+		GrepSources(ctx, []string{"k"}, []gogrep.NamedSource{
+			{Name: "a", Reader: strings.NewReader("k1\nnone\n")},
+			{Name: "b", Reader: strings.NewReader("k2\n")},
+		})
+	assert.Nil(t, err)
+	for r := range resultC {
+		assert.Nil(t, r.Err())
+	}
+	assert.Nil(t, sink.Close())
+
+	rows, err := db.Query("SELECT path, text FROM gogrep_results ORDER BY path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got [][2]string
+	for rows.Next() {
+		var r [2]string
+		assert.Nil(t, rows.Scan(&r[0], &r[1]))
+		got = append(got, r)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, [][2]string{{"a", "k1"}, {"b", "k2"}}, got)
+}
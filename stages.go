@@ -0,0 +1,189 @@
+package gogrep
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+// scanSplitter splits the source into lines or the records by the split function.
+type scanSplitter struct {
+	split         bufio.SplitFunc
+	delimiter     int  // the terminator of the records, -1 for WithSplitFunc
+	dropCR        bool // drop the \r at the end of the lines as bufio.ScanLines does
+	maxLineLength int
+	longLineMode  LongLineMode
+	errorPolicy   ErrorPolicy
+	reportSkipped bool
+	send          func(Result) // sends the errors or the skips of the long lines
+	stats         *statsCounter
+	count         int // the number of the records split, set by Split
+	sharedBuffers bool
+	startLine     int // the number of the records before the source by WithStartPosition
+}
+
+func (s *scanSplitter) Split(source io.Reader, emit func(pipeline.Record) error) error {
+	if m, ok := source.(*memorySource); ok && s.delimiter >= 0 {
+		return s.splitMemory(m, emit)
+	}
+	var (
+		sc         = bufio.NewScanner(source)
+		lineNumber = s.startLine
+		mode       = s.longLineMode
+		reportLong = mode == LongLineError && s.errorPolicy == ErrorContinue
+		arena      *lineArena
+	)
+	if s.sharedBuffers {
+		arena = &lineArena{}
+	}
+	if reportLong {
+		mode = LongLineSkip
+	}
+	splitter := &lineSplitter{
+		base:      s.split,
+		maxLength: s.maxLineLength,
+		mode:      mode,
+	}
+	defer func() { s.count = lineNumber }()
+	mapper, _ := source.(OffsetMapper)
+	sc.Buffer(nil, s.maxLineLength)
+	sc.Split(splitter.split)
+	for sc.Scan() {
+		lineNumber++
+		s.stats.scanned()
+		offset := splitter.start
+		if mapper != nil {
+			offset = mapper.MapOffset(offset)
+		}
+		if splitter.long && mode == LongLineSkip {
+			if reportLong {
+				s.send(&result{
+					err: &ScanError{
+						Line: lineNumber,
+						Err:  bufio.ErrTooLong,
+					},
+					line:   lineNumber,
+					offset: offset,
+				})
+			} else if s.reportSkipped {
+				s.send(newSkipResult(SkipLongLine, lineNumber, offset))
+			}
+			continue
+		}
+		if splitter.long && s.reportSkipped {
+			// The rest of the truncated line
+			s.send(newSkipResult(SkipLongLine, lineNumber, offset+int64(s.maxLineLength)))
+		}
+		var text string
+		if arena != nil {
+			text = arena.text(sc.Bytes())
+		} else {
+			text = sc.Text()
+		}
+		r := pipeline.Record{
+			Text:   text,
+			View:   text,
+			Number: lineNumber,
+			Offset: offset,
+		}
+		if err := emit(r); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// maskFilter masks the views of the records.
+type maskFilter struct {
+	masker lineMasker
+}
+
+func (s *maskFilter) Filter(r *pipeline.Record) (bool, error) {
+	r.View = s.masker.mask(r.View)
+	return true, nil
+}
+
+// binaryFilter stops reading the binary source by the policy.
+type binaryFilter struct {
+	detector *binaryDetector
+	mode     BinaryFiles
+	matcher  Matcher
+	send     func(Result)
+	report   bool // report the skipped source with WithReportSkipped
+}
+
+func (s *binaryFilter) Filter(r *pipeline.Record) (bool, error) {
+	if !s.detector.binary {
+		return true, nil
+	}
+	if s.mode == BinaryWithoutMatch {
+		if s.report {
+			s.send(newSkipResult(SkipBinary, r.Number, r.Offset))
+		}
+		return false, pipeline.ErrStop
+	}
+	if s.matcher.MatchString(r.View) {
+		s.send(newErrResult(ErrBinaryFile))
+		return false, pipeline.ErrStop
+	}
+	return false, nil
+}
+
+// lineMatcher selects the lines that match with the matcher.
+type lineMatcher struct {
+	onlyMatching bool
+	countMatches bool // find all the matches of the lines
+	fuzzy        bool // the matches have the edit distances
+	matcher      Matcher
+	limit        *limits
+}
+
+func (s *lineMatcher) Match(chunk []pipeline.Record, emit func(pipeline.Item)) {
+	s.MatchContext(context.Background(), chunk, emit)
+}
+
+// MatchContext stops matching the chunk at the line when the context is canceled.
+func (s *lineMatcher) MatchContext(ctx context.Context, chunk []pipeline.Record, emit func(pipeline.Item)) {
+	if s.limit.reached() {
+		return
+	}
+	done := ctx.Done()
+	for _, l := range chunk {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if s.countMatches && !s.onlyMatching {
+			matches := s.matcher.FindAllStringSubmatchIndex(l.View, -1)
+			if len(matches) > 0 && s.limit.takeLine() && s.limit.results.take() {
+				emit(newRangesResult(l, s.matcher, matches))
+			}
+			continue
+		}
+		if !s.onlyMatching {
+			if s.matcher.MatchString(l.View) && s.limit.takeLine() && s.limit.results.take() {
+				emit(newResult(l, s.matcher))
+			}
+			continue
+		}
+		matches, patterns := nonEmptyMatches(findAllPatterns(s.matcher, l.View))
+		if len(matches) == 0 || !s.limit.takeLine() {
+			continue
+		}
+		for i, m := range matches {
+			if s.limit.results.take() {
+				r := newSubmatchResult(l, m)
+				if s.fuzzy {
+					r.matcher, r.view = s.matcher, r.text
+				}
+				if patterns != nil {
+					r.patterns = patterns[i]
+				}
+				emit(r)
+			}
+		}
+	}
+}
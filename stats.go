@@ -0,0 +1,179 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/berquerant/gogrep/pipeline"
+)
+
+// Stats is the statistics of a grep of a source.
+type Stats struct {
+	// LinesScanned is the number of the lines or the records split from the source.
+	LinesScanned int64
+	// BytesRead is the number of the bytes read from the source, before the decompression and the decoding.
+	BytesRead int64
+	// LinesMatched is the number of the lines selected by the regexes.
+	LinesMatched int64
+	// Duration is the time from the start to the end of the grep.
+	Duration time.Duration
+	// Workers are the stats of the workers by WithThreads.
+	Workers []WorkerStats
+	// Cancellation is the reason why the grep ended early, empty if the grep read the whole source.
+	Cancellation CancellationReason
+}
+
+// WorkerStats is the statistics of a worker of a grep.
+type WorkerStats struct {
+	// Chunks is the number of the chunks of the lines that the worker matched.
+	Chunks int64
+	// Busy is the time that the worker spent matching.
+	Busy time.Duration
+}
+
+// Utilization returns the ratio of the busy time of each worker to the duration.
+func (s *Stats) Utilization() []float64 {
+	r := make([]float64, len(s.Workers))
+	if s.Duration <= 0 {
+		return r
+	}
+	for i, w := range s.Workers {
+		r[i] = float64(w.Busy) / float64(s.Duration)
+	}
+	return r
+}
+
+// Add adds the stats of another grep, e.g. of the sources of GrepSources.
+// The durations are summed, so the utilization is the average over the greps.
+// The cancellation is the first one of the greps.
+func (s *Stats) Add(x Stats) {
+	if s.Cancellation == "" {
+		s.Cancellation = x.Cancellation
+	}
+	s.LinesScanned += x.LinesScanned
+	s.BytesRead += x.BytesRead
+	s.LinesMatched += x.LinesMatched
+	s.Duration += x.Duration
+	for len(s.Workers) < len(x.Workers) {
+		s.Workers = append(s.Workers, WorkerStats{})
+	}
+	for i, w := range x.Workers {
+		s.Workers[i].Chunks += w.Chunks
+		s.Workers[i].Busy += w.Busy
+	}
+}
+
+// statsCounter counts the stats of a grep.
+type statsCounter struct {
+	start        time.Time
+	linesScanned int64
+	bytesRead    int64
+	linesMatched int64
+	chunks       []int64
+	busy         []int64           // nanoseconds
+	progress     *progressReporter // nil without WithProgress
+}
+
+func newStatsCounter(start time.Time, workers int) *statsCounter {
+	if workers < 1 {
+		workers = 1
+	}
+	return &statsCounter{
+		start:  start,
+		chunks: make([]int64, workers),
+		busy:   make([]int64, workers),
+	}
+}
+
+func (s *statsCounter) ObserveChunk(worker int, _ []pipeline.Record, elapsed time.Duration) {
+	atomic.AddInt64(&s.chunks[worker], 1)
+	atomic.AddInt64(&s.busy[worker], int64(elapsed))
+	s.progress.observe(s)
+}
+
+// newStats returns the counter of the stats of a grep of the source of the size by the workers,
+// nil without WithStatsCollector and WithProgress.
+func (s *grepper) newStats(workers int, size int64) *statsCounter {
+	c := s.config
+	if c.statsCollector == nil && c.progress == nil {
+		return nil
+	}
+	stats := newStatsCounter(c.clock.Now(), workers)
+	if c.progress != nil {
+		stats.progress = &progressReporter{
+			report: c.progress,
+			clock:  c.clock,
+			tag:    c.sourceTag,
+			size:   size,
+			last:   stats.start,
+		}
+	}
+	return stats
+}
+
+// collectStats reports the stats and the last progress at the end of the grep, nil-safe.
+func (s *grepper) collectStats(ctx context.Context, stats *statsCounter, limit *limits) {
+	if stats == nil {
+		return
+	}
+	end := s.config.clock.Now()
+	stats.progress.finish(stats, end)
+	if s.config.statsCollector != nil {
+		x := stats.stats(end)
+		x.Cancellation = limit.cancellation(ctx)
+		s.config.statsCollector(x)
+	}
+}
+
+// matched counts a matched line, nil-safe.
+func (s *statsCounter) matched() {
+	if s != nil {
+		atomic.AddInt64(&s.linesMatched, 1)
+	}
+}
+
+// scanned counts a split line, nil-safe.
+func (s *statsCounter) scanned() {
+	if s != nil {
+		atomic.AddInt64(&s.linesScanned, 1)
+	}
+}
+
+// stats returns the stats at the end of the grep.
+func (s *statsCounter) stats(end time.Time) Stats {
+	r := Stats{
+		LinesScanned: atomic.LoadInt64(&s.linesScanned),
+		BytesRead:    atomic.LoadInt64(&s.bytesRead),
+		LinesMatched: atomic.LoadInt64(&s.linesMatched),
+		Duration:     end.Sub(s.start),
+		Workers:      make([]WorkerStats, len(s.chunks)),
+	}
+	for i := range s.chunks {
+		r.Workers[i] = WorkerStats{
+			Chunks: atomic.LoadInt64(&s.chunks[i]),
+			Busy:   time.Duration(atomic.LoadInt64(&s.busy[i])),
+		}
+	}
+	return r
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (s *countingReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	atomic.AddInt64(s.n, int64(n))
+	return n, err
+}
+
+func (s *countingReader) MapOffset(pos int64) int64 {
+	if m, ok := s.r.(OffsetMapper); ok {
+		return m.MapOffset(pos)
+	}
+	return pos
+}
@@ -0,0 +1,154 @@
+package gogrep
+
+import (
+	"io"
+	"os"
+	"unsafe"
+)
+
+// ReaderStrategy provides the reader specialized for a kind of source, e.g. files or in-memory sources,
+// instead of reading the source as it is.
+// The reader is closed after the grep if it implements io.Closer, without closing the source.
+type ReaderStrategy interface {
+	// Reader returns the reader of the source, or false if the source is not of the kind.
+	Reader(source io.Reader) (io.Reader, bool)
+}
+
+// ReaderStrategyFunc is a ReaderStrategy by the function.
+type ReaderStrategyFunc func(source io.Reader) (io.Reader, bool)
+
+func (f ReaderStrategyFunc) Reader(source io.Reader) (io.Reader, bool) { return f(source) }
+
+var (
+	// FileReaderStrategy reads the regular *os.File from the current position
+	// by pread into a large buffer aligned to the pages, cutting the syscalls of the small reads.
+	// The position of the file is not advanced.
+	FileReaderStrategy ReaderStrategy = ReaderStrategyFunc(newFileReader)
+	// WriterToReaderStrategy reads the io.WriterTo source by its WriteTo
+	// that writes the content at once or in the large chunks.
+	// The sources that implement io.ReaderAt, e.g. strings.Reader, are read as they are since they are in memory.
+	WriterToReaderStrategy ReaderStrategy = ReaderStrategyFunc(newWriterToReader)
+	// DefaultReaderStrategies are the strategies by default.
+	DefaultReaderStrategies = []ReaderStrategy{
+		FileReaderStrategy,
+		WriterToReaderStrategy,
+	}
+)
+
+// WithReaderStrategies sets the strategies of reading the sources, tried in order.
+// The source is read as it is if no strategies apply.
+// No strategies disable them.
+// Default is DefaultReaderStrategies.
+func WithReaderStrategies(strategies ...ReaderStrategy) Option {
+	return func(c *Config) {
+		c.readerStrategies = strategies
+	}
+}
+
+// strategyReader returns the reader of the source by the first strategy that applies.
+// Returns false if no strategies apply.
+func (c *Config) strategyReader(source io.Reader) (io.Reader, bool) {
+	for _, x := range c.readerStrategies {
+		if r, ok := x.Reader(source); ok {
+			return r, true
+		}
+	}
+	return source, false
+}
+
+const (
+	fileReaderBufferSize = 1 << 20
+	fileReaderAlignment  = 4096
+)
+
+// fileReader reads the file by pread into the aligned buffer.
+type fileReader struct {
+	f      *os.File
+	offset int64 // the offset of the next pread
+	buf    []byte
+	start  int
+	end    int
+	err    error
+}
+
+func newFileReader(source io.Reader) (io.Reader, bool) {
+	f, ok := source.(*os.File)
+	if !ok {
+		return nil, false
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, false
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false
+	}
+	return &fileReader{
+		f:      f,
+		offset: offset,
+		buf:    alignedBuffer(fileReaderBufferSize, fileReaderAlignment),
+	}, true
+}
+
+// alignedBuffer returns the buffer of the size whose address is aligned.
+func alignedBuffer(size, alignment int) []byte {
+	b := make([]byte, size+alignment)
+	var skip int
+	if r := int(uintptr(unsafe.Pointer(&b[0])) & uintptr(alignment-1)); r != 0 {
+		skip = alignment - r
+	}
+	return b[skip : skip+size : skip+size]
+}
+
+func (s *fileReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if s.start == s.end {
+		if s.err != nil {
+			return 0, s.err
+		}
+		n, err := s.f.ReadAt(s.buf, s.offset)
+		s.offset += int64(n)
+		s.start, s.end = 0, n
+		s.err = err
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, s.buf[s.start:s.end])
+	s.start += n
+	return n, nil
+}
+
+// writerToReader reads the content written by WriteTo of the source through a pipe.
+type writerToReader struct {
+	r *io.PipeReader
+}
+
+func newWriterToReader(source io.Reader) (io.Reader, bool) {
+	w, ok := source.(io.WriterTo)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := source.(io.ReaderAt); ok {
+		return nil, false
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() {
+			if err := panicError(recover()); err != nil {
+				pw.CloseWithError(err)
+			}
+		}()
+		_, err := w.WriteTo(pw)
+		pw.CloseWithError(err) // EOF if nil
+	}()
+	return &writerToReader{r: pr}, true
+}
+
+func (s *writerToReader) Read(p []byte) (int, error) { return s.r.Read(p) }
+
+// Close stops WriteTo of the source.
+func (s *writerToReader) Close() error { return s.r.Close() }
@@ -0,0 +1,129 @@
+package gogrep_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// infiniteWriterTo writes the lines until the writer fails.
+type infiniteWriterTo struct {
+	done chan error
+}
+
+func (*infiniteWriterTo) Read([]byte) (int, error) { panic("read") }
+
+func (s *infiniteWriterTo) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for {
+		m, err := io.WriteString(w, "match\n")
+		n += int64(m)
+		if err != nil {
+			s.done <- err
+			return n, err
+		}
+	}
+}
+
+// upperReader is a source of the test strategy.
+type upperReader struct {
+	io.Reader
+}
+
+func TestReaderStrategies(t *testing.T) {
+	collect := func(t *testing.T, resultC <-chan gogrep.Result) []gogrep.Result {
+		var rs []gogrep.Result
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			rs = append(rs, r)
+		}
+		return rs
+	}
+
+	t.Run("file", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "big")
+		var b strings.Builder
+		b.WriteString("head\n")
+		for i := 0; i < 200000; i++ {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		b.WriteString("match\n")
+		if err := os.WriteFile(name, []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		// Read from the current position
+		if _, err := f.Seek(5, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		resultC, err := gogrep.New().Grep(context.TODO(), "^(match|head)$", f)
+		if !assert.Nil(t, err) {
+			return
+		}
+		rs := collect(t, resultC)
+		if assert.Equal(t, 1, len(rs)) {
+			assert.Equal(t, "match", rs[0].Text())
+			assert.Equal(t, 200001, rs[0].Line())
+			assert.Equal(t, int64(b.Len()-len("match\n")-5), rs[0].Offset())
+		}
+	})
+
+	t.Run("writer to", func(t *testing.T) {
+		resultC, err := gogrep.New().Grep(context.TODO(), "match", bytes.NewBufferString("skip\nmatch\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		rs := collect(t, resultC)
+		if assert.Equal(t, 1, len(rs)) {
+			assert.Equal(t, 2, rs[0].Line())
+		}
+	})
+
+	t.Run("writer to stopped", func(t *testing.T) {
+		source := &infiniteWriterTo{done: make(chan error, 1)}
+		resultC, err := gogrep.New(gogrep.WithMaxResults(1)).Grep(context.TODO(), "match", source)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 1, len(collect(t, resultC)))
+		select {
+		case err := <-source.done:
+			assert.ErrorIs(t, err, io.ErrClosedPipe)
+		case <-time.After(5 * time.Second):
+			t.Fatal("WriteTo did not stop")
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		upper := gogrep.ReaderStrategyFunc(func(source io.Reader) (io.Reader, bool) {
+			r, ok := source.(*upperReader)
+			if !ok {
+				return nil, false
+			}
+			b, err := io.ReadAll(r.Reader)
+			if err != nil {
+				return nil, false
+			}
+			return bytes.NewReader(bytes.ToUpper(b)), true
+		})
+		g := gogrep.New(gogrep.WithReaderStrategies(append([]gogrep.ReaderStrategy{upper}, gogrep.DefaultReaderStrategies...)...))
+		resultC, err := g.Grep(context.TODO(), "MATCH", &upperReader{Reader: strings.NewReader("match\n")})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, 1, len(collect(t, resultC)))
+	})
+}
@@ -0,0 +1,41 @@
+package gogrep
+
+import "runtime/debug"
+
+// panicError returns the value recovered as *PanicError with the stack trace, nil if not panicking.
+// It should be called with recover() in the deferred function.
+func panicError(x interface{}) *PanicError {
+	if x == nil {
+		return nil
+	}
+	return &PanicError{
+		Value: x,
+		Stack: debug.Stack(),
+	}
+}
+
+// supervise runs f that sends the results to resultC in a goroutine and closes resultC after f returns on every path.
+// A panic of f, e.g. by a Sink, a Reader of a source or a callback of the options,
+// is sent to resultC as the error Result with *PanicError instead of crashing the process,
+// and then recovered is called if not nil, e.g. to cancel the grep and drain the results of the upstream
+// not to leak the goroutines sending them.
+func supervise(resultC chan<- Result, f func(), recovered func()) {
+	go func() {
+		defer close(resultC)
+		defer func() {
+			if err := panicError(recover()); err != nil {
+				resultC <- newErrResult(wrapErr(err, "Grepper recovered"))
+				if recovered != nil {
+					recovered()
+				}
+			}
+		}()
+		f()
+	}()
+}
+
+// drainResults discards the rest of the results.
+func drainResults(resultC <-chan Result) {
+	for range resultC {
+	}
+}
@@ -0,0 +1,96 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// panicReader panics on read.
+type panicReader struct{}
+
+func (panicReader) Read([]byte) (int, error) { panic("read") }
+
+func TestSupervise(t *testing.T) {
+	bigSource := strings.Repeat("line a\n", 1<<19) // grepped by the ranges
+
+	for _, tc := range []struct {
+		title string
+		grep  func(context.Context) (<-chan gogrep.Result, error)
+		want  interface{} // the value of the panic
+	}{
+		{
+			title: "stats collector",
+			grep: func(ctx context.Context) (<-chan gogrep.Result, error) {
+				return gogrep.New(gogrep.WithStatsCollector(func(gogrep.Stats) { panic("stats") })).
+					Grep(ctx, "a", strings.NewReader("a\nb\n"))
+			},
+			want: "stats",
+		},
+		{
+			title: "stats collector of ranges",
+			grep: func(ctx context.Context) (<-chan gogrep.Result, error) {
+				return gogrep.New(gogrep.WithThreads(4), gogrep.WithStatsCollector(func(gogrep.Stats) { panic("stats") })).
+					GrepReaderAt(ctx, []string{"a"}, strings.NewReader(bigSource))
+			},
+			want: "stats",
+		},
+		{
+			title: "sink",
+			grep: func(ctx context.Context) (<-chan gogrep.Result, error) {
+				return gogrep.New(gogrep.WithSink(gogrep.SinkFunc(func(gogrep.Result) error { panic("sink") }))).
+					Grep(ctx, "a", strings.NewReader(strings.Repeat("a\n", 10000)))
+			},
+			want: "sink",
+		},
+		{
+			title: "reader read ahead",
+			grep: func(ctx context.Context) (<-chan gogrep.Result, error) {
+				return gogrep.New().GrepSources(ctx, []string{"a"}, []gogrep.NamedSource{
+					{Name: "a", Reader: strings.NewReader("a\n")},
+					{Name: "panic", Reader: panicReader{}},
+				})
+			},
+			want: "read",
+		},
+		{
+			title: "concurrency hint",
+			grep: func(ctx context.Context) (<-chan gogrep.Result, error) {
+				return gogrep.New(gogrep.WithSourceConcurrencyHint(func(string, int64) int { panic("hint") })).
+					GrepSources(ctx, []string{"a"}, []gogrep.NamedSource{
+						{Name: "big", Reader: strings.NewReader(bigSource)},
+					})
+			},
+			want: "hint",
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			baseline := runtime.NumGoroutine()
+			resultC, err := tc.grep(context.TODO())
+			assert.Nil(t, err)
+			var recovered []*gogrep.PanicError
+			for r := range resultC {
+				var e *gogrep.PanicError
+				if errors.As(r.Err(), &e) {
+					recovered = append(recovered, e)
+				}
+			}
+			if assert.Equal(t, 1, len(recovered)) {
+				assert.Equal(t, tc.want, recovered[0].Value)
+				assert.NotEmpty(t, recovered[0].Stack)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "goroutines leaked")
+		})
+	}
+}
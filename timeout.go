@@ -0,0 +1,147 @@
+package gogrep
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// WithPerSourceTimeout limits the grep of each source of GrepSources and the file of GrepFile to the timeout
+// independently of the context, e.g. not to let a file stuck on a network filesystem stall the rest of the sources.
+// The grep of the source not ended within the timeout ends with the error Result of CancellationError of CancelTimeout,
+// and the rest of the sources are grepped as the ErrorPolicy decides, e.g. ErrorContinue moves on to the next source.
+// The read of the source stuck at the timeout is abandoned in the background, and the source is closed after it returns.
+// The sources are neither batched nor read ahead with the timeout, since the reads of them would not be limited by it.
+// Not positive timeout disables it.
+func WithPerSourceTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.perSourceTimeout = timeout
+	}
+}
+
+// withSourceTimeout returns the context of the grep of a source canceled by WithPerSourceTimeout,
+// the grepper that ends the context at the end of the grep, and the function to end it.
+// The source is read by deadlineReader if the grepper has the pendingReads of the source.
+func (s *grepper) withSourceTimeout(ctx context.Context) (context.Context, *grepper, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.config.clock.After(s.config.perSourceTimeout):
+			cancel(CancelCause(CancelTimeout))
+		}
+	}()
+	stop := func() { cancel(nil) }
+	g := *s
+	g.done = func() {
+		stop()
+		if s.done != nil {
+			s.done()
+		}
+	}
+	return ctx, &g, stop
+}
+
+// pendingReads counts the reads of a source by deadlineReader in the background
+// to close the source after the reads abandoned by the timeout return.
+type pendingReads struct {
+	mux    sync.Mutex
+	n      int
+	closer io.Closer // closed when the reads end if not nil
+}
+
+func (s *pendingReads) add() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.n++
+}
+
+func (s *pendingReads) done() {
+	s.mux.Lock()
+	s.n--
+	var c io.Closer
+	if s.n == 0 {
+		c, s.closer = s.closer, nil
+	}
+	s.mux.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+// close closes the source now, or after the pending reads if any.
+func (s *pendingReads) close(c io.Closer) error {
+	if s == nil {
+		return c.Close()
+	}
+	s.mux.Lock()
+	if s.n > 0 {
+		s.closer = c
+		s.mux.Unlock()
+		return nil
+	}
+	s.mux.Unlock()
+	return c.Close()
+}
+
+// deadlineReader reads the source in the background to return the error of the context as soon as it is done,
+// even if the read of the source does not return, e.g. of a file stuck on a network filesystem.
+type deadlineReader struct {
+	ctx   context.Context
+	r     io.Reader
+	reads *pendingReads
+	buf   []byte // the buffer of the read, not reused after the read is abandoned
+	readC chan deadlineRead
+}
+
+type deadlineRead struct {
+	n   int
+	err error
+}
+
+func newDeadlineReader(ctx context.Context, r io.Reader, reads *pendingReads) *deadlineReader {
+	return &deadlineReader{
+		ctx:   ctx,
+		r:     r,
+		reads: reads,
+		readC: make(chan deadlineRead, 1),
+	}
+}
+
+func (s *deadlineReader) Read(p []byte) (int, error) {
+	if isDone(s.ctx) {
+		return 0, s.ctx.Err()
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if cap(s.buf) < len(p) {
+		s.buf = make([]byte, len(p))
+	}
+	buf := s.buf[:len(p)]
+	s.reads.add()
+	go func() {
+		defer s.reads.done()
+		defer func() {
+			if err := panicError(recover()); err != nil {
+				s.readC <- deadlineRead{err: err}
+			}
+		}()
+		n, err := s.r.Read(buf)
+		s.readC <- deadlineRead{n: n, err: err}
+	}()
+	select {
+	case <-s.ctx.Done():
+		return 0, s.ctx.Err()
+	case x := <-s.readC:
+		return copy(p, buf[:x.n]), x.err
+	}
+}
+
+func (s *deadlineReader) MapOffset(pos int64) int64 {
+	if m, ok := s.r.(OffsetMapper); ok {
+		return m.MapOffset(pos)
+	}
+	return pos
+}
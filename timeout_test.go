@@ -0,0 +1,140 @@
+package gogrep_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+// stuckReader blocks on the read after the head until released, like a file stuck on a network filesystem.
+type stuckReader struct {
+	head     io.Reader
+	released chan struct{}
+	closed   chan struct{} // closed by Close if not nil
+}
+
+func (s *stuckReader) Read(p []byte) (int, error) {
+	if n, _ := s.head.Read(p); n > 0 {
+		return n, nil
+	}
+	<-s.released
+	return 0, io.EOF
+}
+
+func (s *stuckReader) Close() error {
+	if s.closed != nil {
+		close(s.closed)
+	}
+	return nil
+}
+
+func TestWithPerSourceTimeout(t *testing.T) {
+	grep := func(t *testing.T, opt ...gogrep.Option) []string {
+		stuck := &stuckReader{
+			head:     strings.NewReader("stuck a\n"),
+			released: make(chan struct{}),
+		}
+		defer close(stuck.released)
+		resultC, err := gogrep.New(append(opt, gogrep.WithPerSourceTimeout(50*time.Millisecond))...).
+			GrepSources(context.TODO(), []string{"a"}, []gogrep.NamedSource{
+				{Name: "first", Reader: strings.NewReader("first a\n")},
+				{Name: "stuck", Reader: stuck},
+				{Name: "last", Reader: strings.NewReader("last a\n")},
+			})
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		var got []string
+		for r := range resultC {
+			if err := r.Err(); err != nil {
+				var e *gogrep.SourceError
+				assert.True(t, errors.As(err, &e), "%v", err)
+				got = append(got, fmt.Sprintf("%s:%s", r.Source(), gogrep.CancellationReasonOf(err)))
+				continue
+			}
+			got = append(got, r.Text())
+		}
+		return got
+	}
+
+	t.Run("continue", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"first a",
+			"stuck a",
+			"stuck:timeout",
+			"last a",
+		}, grep(t, gogrep.WithErrorPolicy(gogrep.ErrorContinue), gogrep.WithFlushPolicy(gogrep.FlushPolicy{MaxLines: 1})))
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"first a",
+			"stuck:timeout",
+		}, grep(t))
+	})
+
+	t.Run("close after read", func(t *testing.T) {
+		stuck := &stuckReader{
+			head:     strings.NewReader(""),
+			released: make(chan struct{}),
+			closed:   make(chan struct{}),
+		}
+		resultC, err := gogrep.New(gogrep.WithPerSourceTimeout(10*time.Millisecond)).
+			GrepSources(context.TODO(), []string{"a"}, []gogrep.NamedSource{{Name: "stuck", Reader: stuck}})
+		if !assert.Nil(t, err) {
+			return
+		}
+		for r := range resultC {
+			assert.Equal(t, gogrep.CancelTimeout, gogrep.CancellationReasonOf(r.Err()))
+		}
+		select {
+		case <-stuck.closed:
+			t.Fatal("closed while reading")
+		default:
+		}
+		close(stuck.released)
+		<-stuck.closed
+	})
+
+	t.Run("within timeout", func(t *testing.T) {
+		resultC, err := gogrep.New(gogrep.WithPerSourceTimeout(time.Minute)).GrepSources(context.TODO(), []string{"a"}, []gogrep.NamedSource{
+			{Name: "slow", Reader: &slowOpenReader{r: strings.NewReader("a\nb\na\n"), delay: 10 * time.Millisecond}},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		var n int
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			n++
+		}
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "file")
+		if !assert.Nil(t, os.WriteFile(path, []byte(strings.Repeat("a\nb\n", 1<<19)), 0o600)) {
+			return
+		}
+		resultC, err := gogrep.New(gogrep.WithThreads(4), gogrep.WithPerSourceTimeout(time.Minute)).
+			GrepFile(context.TODO(), "a", path)
+		if !assert.Nil(t, err) {
+			return
+		}
+		var n int
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			n++
+		}
+		assert.Equal(t, 1<<19, n)
+	})
+}
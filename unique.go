@@ -0,0 +1,117 @@
+package gogrep
+
+import (
+	"context"
+	"strings"
+)
+
+// UniqueBy is the key of the results deduplicated by WithUniqueBy.
+type UniqueBy string
+
+const (
+	// UniqueText deduplicates the results by Result.Text, the matched lines or the matches with WithOnlyMatching.
+	UniqueText UniqueBy = "text"
+	// UniqueMatch deduplicates the results by the matched substrings of the lines.
+	UniqueMatch UniqueBy = "match"
+)
+
+// WithUnique suppresses the results whose texts are sent already by the same call of the Grepper,
+// including the results of the other sources of GrepSources.
+// Same as WithUniqueBy(UniqueText).
+func WithUnique() Option {
+	return WithUniqueBy(UniqueText)
+}
+
+// WithUniqueBy suppresses the results whose keys are sent already by the same call of the Grepper.
+// Unknown key makes Grep fail.
+func WithUniqueBy(by UniqueBy) Option {
+	return func(c *Config) {
+		c.unique = by
+	}
+}
+
+// WithUniqueLimit limits the memory of the keys kept by WithUnique to the bytes.
+// The oldest keys are forgotten beyond the limit, so the duplicates far apart may be sent again.
+// Not positive number means no limit.
+func WithUniqueLimit(bytes int) Option {
+	return func(c *Config) {
+		c.uniqueLimit = bytes
+	}
+}
+
+// uniqueKey returns the key of the result to be deduplicated.
+// The text of WithOnlyMatching is the match.
+func uniqueKey(c *Config, r Result) string {
+	text := r.Text()
+	if c.unique == UniqueText || c.onlyMatching {
+		return text
+	}
+	ranges := r.MatchRanges()
+	if len(ranges) == 0 {
+		return text
+	}
+	var b strings.Builder
+	for i, x := range ranges {
+		if i > 0 {
+			b.WriteByte(0)
+		}
+		b.WriteString(text[x[0]:x[1]])
+	}
+	return b.String()
+}
+
+// seenSet is the keys of the results sent.
+type seenSet struct {
+	limit int
+	keys  map[string]struct{}
+	order []string // the keys in order of insertion with the limit
+	size  int      // the bytes of the keys
+}
+
+func newSeenSet(limit int) *seenSet {
+	return &seenSet{
+		limit: limit,
+		keys:  map[string]struct{}{},
+	}
+}
+
+// add returns true if the key is not seen, and adds it.
+func (s *seenSet) add(key string) bool {
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+	s.keys[key] = struct{}{}
+	if s.limit < 1 {
+		return true
+	}
+	s.order = append(s.order, key)
+	s.size += len(key)
+	for s.size > s.limit && len(s.order) > 1 {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		s.size -= len(oldest)
+		delete(s.keys, oldest)
+	}
+	return true
+}
+
+// uniqueGrep returns the grep that drops the duplicated results of the grep.
+// The errors are sent as they are.
+func uniqueGrep(c *Config, grep func(context.Context) (<-chan Result, error)) func(context.Context) (<-chan Result, error) {
+	return func(ctx context.Context) (<-chan Result, error) {
+		resultC, err := grep(ctx)
+		if err != nil {
+			return nil, err
+		}
+		uniqueC := make(chan Result, c.resultBuffer())
+		supervise(uniqueC, func() {
+			seen := newSeenSet(c.uniqueLimit)
+			for r := range resultC {
+				if r.Err() != nil || seen.add(compactKey(uniqueKey(c, r))) {
+					uniqueC <- r
+				}
+			}
+		}, func() { drainResults(resultC) })
+		return uniqueC, nil
+	}
+}
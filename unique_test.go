@@ -0,0 +1,92 @@
+package gogrep_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithUnique(t *testing.T) {
+	const source = "id=1 a\nid=2 b\nid=1 a\nid=1 c\nid=2 b\n"
+
+	grep := func(t *testing.T, opt ...gogrep.Option) []string {
+		resultC, err := gogrep.New(opt...).GrepSources(context.TODO(), []string{`id=\d`}, []gogrep.NamedSource{
+			{Name: "x", Reader: strings.NewReader(source)},
+			{Name: "y", Reader: strings.NewReader("id=3 d\nid=1 a\n")},
+		})
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		var texts []string
+		for r := range resultC {
+			assert.Nil(t, r.Err())
+			texts = append(texts, r.Text())
+		}
+		sort.Strings(texts)
+		return texts
+	}
+
+	for _, tc := range []struct {
+		title string
+		opt   []gogrep.Option
+		want  []string
+	}{
+		{
+			title: "text",
+			opt:   []gogrep.Option{gogrep.WithUnique()},
+			want:  []string{"id=1 a", "id=1 c", "id=2 b", "id=3 d"},
+		},
+		{
+			title: "match",
+			opt:   []gogrep.Option{gogrep.WithUniqueBy(gogrep.UniqueMatch)},
+			want:  []string{"id=1 a", "id=2 b", "id=3 d"},
+		},
+		{
+			title: "only matching",
+			opt:   []gogrep.Option{gogrep.WithUnique(), gogrep.WithOnlyMatching()},
+			want:  []string{"id=1", "id=2", "id=3"},
+		},
+		{
+			title: "match only matching",
+			opt:   []gogrep.Option{gogrep.WithUniqueBy(gogrep.UniqueMatch), gogrep.WithOnlyMatching()},
+			want:  []string{"id=1", "id=2", "id=3"},
+		},
+		{
+			title: "limit",
+			// Keeps only the last key, so the duplicates apart are sent again
+			opt:  []gogrep.Option{gogrep.WithUnique(), gogrep.WithUniqueLimit(1), gogrep.WithThreads(1)},
+			want: []string{"id=1 a", "id=1 a", "id=1 a", "id=1 c", "id=2 b", "id=2 b", "id=3 d"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, grep(t, tc.opt...))
+		})
+	}
+
+	t.Run("long lines", func(t *testing.T) {
+		// The keys of the long lines are kept compressed
+		var (
+			a = "id=1 " + strings.Repeat("a", 100)
+			b = "id=1 " + strings.Repeat("a", 99) + "b"
+		)
+		resultC, err := gogrep.New(gogrep.WithUnique()).Grep(context.TODO(), `id=\d`, strings.NewReader(strings.Join([]string{a, b, a, "id=2", b}, "\n")+"\n"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		var texts []string
+		for r := range resultC {
+			texts = append(texts, r.Text())
+		}
+		sort.Strings(texts)
+		assert.Equal(t, []string{a, b, "id=2"}, texts)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := gogrep.New(gogrep.WithUniqueBy("line")).Grep(context.TODO(), "a", strings.NewReader("a\n"))
+		assert.NotNil(t, err)
+	})
+}
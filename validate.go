@@ -0,0 +1,139 @@
+package gogrep
+
+import "fmt"
+
+// validate rejects the unknown values and the incompatible combinations of the options.
+func (c *Config) validate() error {
+	if c.scope != "" {
+		if _, ok := languages[c.language]; !ok {
+			return fmt.Errorf("Grepper unknown language %s", c.language)
+		}
+		switch c.scope {
+		case ScopeComments, ScopeStrings, ScopeCode:
+		default:
+			return fmt.Errorf("Grepper unknown scope %s", c.scope)
+		}
+	}
+	switch c.longLineMode {
+	case LongLineError, LongLineSkip, LongLineTruncate:
+	default:
+		return fmt.Errorf("Grepper unknown long line mode %s", c.longLineMode)
+	}
+	switch c.errorPolicy {
+	case ErrorStop, ErrorContinue:
+	default:
+		return fmt.Errorf("Grepper unknown error policy %s", c.errorPolicy)
+	}
+	switch c.binaryFiles {
+	case BinaryText, BinaryMatches, BinaryWithoutMatch:
+	default:
+		return fmt.Errorf("Grepper unknown binary files %s", c.binaryFiles)
+	}
+	switch c.unique {
+	case "", UniqueText, UniqueMatch:
+	default:
+		return fmt.Errorf("Grepper unknown unique key %s", c.unique)
+	}
+	switch c.sort {
+	case "", SortByFile, SortByLine, SortByText:
+	default:
+		return fmt.Errorf("Grepper unknown sort key %s", c.sort)
+	}
+	switch c.normalization {
+	case "", NormalizationNFC, NormalizationNFKC:
+	default:
+		return fmt.Errorf("Grepper unknown normalization %s", c.normalization)
+	}
+	if c.encoding != "" {
+		if _, err := LookupEncoding(c.encoding); err != nil {
+			return wrapErr(err, "Grepper")
+		}
+	}
+	if _, err := (&grepper{config: c}).compileStages(); err != nil {
+		return err
+	}
+	for _, x := range c.conflicts() {
+		if x.enabled {
+			return fmt.Errorf("Grepper %s conflicts with %s", x.a, x.b)
+		}
+	}
+	return nil
+}
+
+// conflict is a pair of the options that cannot be used together.
+type conflict struct {
+	a, b    string
+	enabled bool
+}
+
+func (c *Config) conflicts() []conflict {
+	return []conflict{
+		{
+			// Every record would be binary
+			a:       "WithBinaryFiles(" + string(c.binaryFiles) + ")",
+			b:       "WithDelimiter(0)",
+			enabled: c.binaryFiles != BinaryText && c.nulDelimited,
+		},
+		{
+			// The windows are matched as a whole
+			a:       "WithPipeline",
+			b:       "WithMultiline",
+			enabled: len(c.stages) > 0 && c.multiline,
+		},
+		{
+			// The last lines wait for the next ones, and a window is a chunk of a line or so
+			a:       "WithFollow",
+			b:       "WithMultiline",
+			enabled: c.follow > 0 && c.multiline,
+		},
+		{
+			// Nothing is sent before the end
+			a:       "WithFollow",
+			b:       "WithSort",
+			enabled: c.follow > 0 && c.sort != "",
+		},
+		{
+			// The windows span the checkpoints
+			a:       "WithCheckpoints",
+			b:       "WithMultiline",
+			enabled: c.checkpointInterval > 0 && c.multiline,
+		},
+		{
+			// The results before the checkpoints are held back
+			a:       "WithCheckpoints",
+			b:       "WithSort",
+			enabled: c.checkpointInterval > 0 && c.sort != "",
+		},
+		{
+			a:       "WithCheckpoints",
+			b:       "WithUnique",
+			enabled: c.checkpointInterval > 0 && c.unique != "",
+		},
+		{
+			a:       "WithCheckpoints",
+			b:       "WithSink",
+			enabled: c.checkpointInterval > 0 && c.sink != nil,
+		},
+		{
+			// The offsets are not in the source
+			a:       "WithCheckpoints",
+			b:       "WithDecompression",
+			enabled: c.checkpointInterval > 0 && c.decompression,
+		},
+		{
+			a:       "WithCheckpoints",
+			b:       "WithEncoding",
+			enabled: c.checkpointInterval > 0 && c.encoding != "",
+		},
+		{
+			a:       "WithStartPosition",
+			b:       "WithDecompression",
+			enabled: c.startPositioned() && c.decompression,
+		},
+		{
+			a:       "WithStartPosition",
+			b:       "WithEncoding",
+			enabled: c.startPositioned() && c.encoding != "",
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package gogrep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gogrep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []*struct {
+		title string
+		opt   []gogrep.Option
+		err   string
+	}{
+		{title: "default"},
+		{
+			title: "unknown scope",
+			opt:   []gogrep.Option{gogrep.WithScope(gogrep.LanguageGo, "unknown")},
+			err:   "Grepper unknown scope unknown",
+		},
+		{
+			title: "binary files with nul delimiter",
+			opt:   []gogrep.Option{gogrep.WithBinaryFiles(gogrep.BinaryMatches), gogrep.WithDelimiter(0)},
+			err:   "Grepper WithBinaryFiles(binary) conflicts with WithDelimiter(0)",
+		},
+		{
+			title: "binary files with other delimiter",
+			opt:   []gogrep.Option{gogrep.WithBinaryFiles(gogrep.BinaryMatches), gogrep.WithDelimiter(0), gogrep.WithDelimiter(';')},
+		},
+		{
+			title: "pipeline with multiline",
+			opt:   []gogrep.Option{gogrep.WithPipeline(gogrep.MatchRegex("x")), gogrep.WithMultiline()},
+			err:   "Grepper WithPipeline conflicts with WithMultiline",
+		},
+		{
+			title: "follow with multiline",
+			opt:   []gogrep.Option{gogrep.WithFollow(0), gogrep.WithMultiline()},
+			err:   "Grepper WithFollow conflicts with WithMultiline",
+		},
+		{
+			title: "follow with sort",
+			opt:   []gogrep.Option{gogrep.WithFollow(0), gogrep.WithSort(gogrep.SortByLine)},
+			err:   "Grepper WithFollow conflicts with WithSort",
+		},
+		{
+			title: "checkpoints with sort",
+			opt:   []gogrep.Option{gogrep.WithCheckpoints(time.Second), gogrep.WithSort(gogrep.SortByLine)},
+			err:   "Grepper WithCheckpoints conflicts with WithSort",
+		},
+		{
+			title: "checkpoints with unique",
+			opt:   []gogrep.Option{gogrep.WithCheckpoints(time.Second), gogrep.WithUnique()},
+			err:   "Grepper WithCheckpoints conflicts with WithUnique",
+		},
+		{
+			title: "checkpoints with sink",
+			opt:   []gogrep.Option{gogrep.WithCheckpoints(time.Second), gogrep.WithSink(gogrep.SinkFunc(func(gogrep.Result) error { return nil }))},
+			err:   "Grepper WithCheckpoints conflicts with WithSink",
+		},
+		{
+			title: "checkpoints with encoding",
+			opt:   []gogrep.Option{gogrep.WithCheckpoints(time.Second), gogrep.WithEncoding("shift_jis")},
+			err:   "Grepper WithCheckpoints conflicts with WithEncoding",
+		},
+		{
+			title: "start position with decompression",
+			opt:   []gogrep.Option{gogrep.WithStartPosition(1, 1), gogrep.WithDecompression()},
+			err:   "Grepper WithStartPosition conflicts with WithDecompression",
+		},
+		{
+			title: "multiline with only matching",
+			opt:   []gogrep.Option{gogrep.WithMultiline(), gogrep.WithOnlyMatching()},
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			resultC, err := gogrep.New(tc.opt...).Grep(context.TODO(), "x", strings.NewReader("x"))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			toResultSlice(resultC)
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	got := gogrep.Capabilities()
+	assert.Contains(t, got, "engine:regexp")
+	assert.Contains(t, got, "decompress:zstd")
+	assert.IsIncreasing(t, got)
+}